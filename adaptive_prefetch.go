@@ -0,0 +1,156 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// adaptivePrefetchHorizon is how much handler time the credit of an adaptive receiver aims to cover
+	adaptivePrefetchHorizon = time.Second
+	// adaptivePrefetchSmoothing is the weight of the latest handler time in the moving average
+	adaptivePrefetchSmoothing = 0.1
+)
+
+type (
+	// adaptivePrefetch sizes the credit window of a receiver to the handler time of its events. The window is the
+	// credit granted to the link plus the events received but not yet completed by the handler.
+	adaptivePrefetch struct {
+		min uint32
+		max uint32
+
+		mu          sync.Mutex
+		handlerTime time.Duration
+		window      uint32
+	}
+)
+
+// ReceiveWithAdaptivePrefetch configures the receiver to adjust its prefetch count, the number of events it asks the
+// service for ahead of the handler, between min and max from the handler time of events. The receiver aims to hold
+// about a second of handler work: a slow handler keeps few events buffered, which the service can otherwise deliver
+// to a consumer taking over the partition, while a fast handler gets enough events to never wait for the next one.
+// The prefetch count starts at the one of ReceiveWithPrefetchCount, within min and max. Like ReceiveWithDrain, this
+// manages link credit on the client, so listeners can be paused and are drained on close.
+func ReceiveWithAdaptivePrefetch(min, max uint32) ReceiveOption {
+	return func(receiver *receiver) error {
+		if min < 1 || max < min {
+			return fmt.Errorf("adaptive prefetch needs 1 <= min <= max, got min %d and max %d", min, max)
+		}
+		receiver.manualCredit = true
+		receiver.adaptive = &adaptivePrefetch{min: min, max: max}
+		return nil
+	}
+}
+
+// linkCredit returns the maximum credit of the link of the receiver, which bounds its buffer of received events
+func (r *receiver) linkCredit() uint32 {
+	if r.adaptive != nil {
+		return r.adaptive.max
+	}
+	return atomic.LoadUint32(&r.prefetchCount)
+}
+
+// initialCredit returns the credit to grant a new or resumed link
+func (r *receiver) initialCredit() uint32 {
+	if r.adaptive != nil {
+		return r.adaptive.reset(atomic.LoadUint32(&r.prefetchCount))
+	}
+	return atomic.LoadUint32(&r.prefetchCount)
+}
+
+// completionCredit returns the credit to grant when the handler completes an event
+func (r *receiver) completionCredit() uint32 {
+	if r.adaptive != nil {
+		return r.adaptive.complete()
+	}
+	return 1
+}
+
+// observeHandlerTime records the time the handler took for an event
+func (r *receiver) observeHandlerTime(d time.Duration) {
+	if r.adaptive != nil {
+		r.adaptive.observe(d)
+	}
+}
+
+// reset starts a new window of the given size, within the bounds, and returns it
+func (a *adaptivePrefetch) reset(size uint32) uint32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.window = a.clamp(size)
+	return a.window
+}
+
+func (a *adaptivePrefetch) observe(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.handlerTime == 0 {
+		a.handlerTime = d
+		return
+	}
+	a.handlerTime += time.Duration(adaptivePrefetchSmoothing * float64(d-a.handlerTime))
+}
+
+// target returns the window holding about adaptivePrefetchHorizon of handler time
+func (a *adaptivePrefetch) target() uint32 {
+	if a.handlerTime <= 0 {
+		return a.max
+	}
+	return a.clamp(uint32(adaptivePrefetchHorizon / a.handlerTime))
+}
+
+// complete accounts for an event leaving the window and returns the credit to grant to bring the window back to the
+// target. A window above the target shrinks by not granting credit for completed events.
+func (a *adaptivePrefetch) complete() uint32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.window > 0 {
+		a.window--
+	}
+
+	target := a.target()
+	if a.window >= target {
+		return 0
+	}
+	credit := target - a.window
+	a.window = target
+	return credit
+}
+
+func (a *adaptivePrefetch) clamp(size uint32) uint32 {
+	switch {
+	case size < a.min:
+		return a.min
+	case size > a.max:
+		return a.max
+	default:
+		return size
+	}
+}
@@ -0,0 +1,52 @@
+package eventhub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiveWithAdaptivePrefetch(t *testing.T) {
+	r := &receiver{prefetchCount: defaultPrefetchCount}
+	assert.Error(t, ReceiveWithAdaptivePrefetch(0, 10)(r))
+	assert.Error(t, ReceiveWithAdaptivePrefetch(10, 5)(r))
+
+	require.NoError(t, ReceiveWithAdaptivePrefetch(10, 500)(r))
+	assert.True(t, r.manualCredit)
+	assert.EqualValues(t, 500, r.linkCredit(), "the link buffer holds the largest window")
+	assert.EqualValues(t, 500, r.initialCredit(), "the prefetch count is kept within the bounds")
+}
+
+func TestAdaptivePrefetch_GrowsForFastHandlers(t *testing.T) {
+	a := &adaptivePrefetch{min: 10, max: 500}
+	assert.EqualValues(t, 50, a.reset(50))
+
+	a.observe(time.Millisecond)
+	// one event left the window of 50 and the target for 1ms handlers is capped at 500
+	assert.EqualValues(t, 451, a.complete())
+	assert.EqualValues(t, 1, a.complete(), "a full window grants the credit of each completed event")
+}
+
+func TestAdaptivePrefetch_ShrinksForSlowHandlers(t *testing.T) {
+	a := &adaptivePrefetch{min: 2, max: 500}
+	a.reset(10)
+
+	a.observe(250 * time.Millisecond)
+	assert.EqualValues(t, 4, a.target())
+	for i := 0; i < 6; i++ {
+		assert.Zero(t, a.complete(), "no credit is granted while the window is above the target")
+	}
+	assert.EqualValues(t, 1, a.complete(), "credit is granted again once the window is down to the target")
+
+	a.observe(10 * time.Second)
+	assert.EqualValues(t, 2, a.reset(0), "the window never drops below the minimum")
+}
+
+func TestAdaptivePrefetch_SmoothsHandlerTime(t *testing.T) {
+	a := &adaptivePrefetch{min: 1, max: 1000}
+	a.observe(10 * time.Millisecond)
+	a.observe(110 * time.Millisecond)
+	assert.Equal(t, 20*time.Millisecond, a.handlerTime)
+}
@@ -24,12 +24,21 @@ package eventhub
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	common "github.com/Azure/azure-amqp-common-go/v3"
+	"github.com/Azure/azure-amqp-common-go/v3/auth"
 	"github.com/Azure/azure-amqp-common-go/v3/rpc"
 	"github.com/Azure/go-amqp"
 	"github.com/devigned/tab"
+	"github.com/jpillora/backoff"
 	"github.com/mitchellh/mapstructure"
 )
 
@@ -45,6 +54,13 @@ const (
 	operationKey        = "operation"
 	readOperationKey    = "READ"
 	address             = "$management"
+
+	// defaultManagementAttemptTimeout bounds a single management request attempt so a management node which does not
+	// respond cannot stall the caller
+	defaultManagementAttemptTimeout = 30 * time.Second
+
+	// partitionInfoConcurrency bounds the number of partition runtime information requests in flight at once
+	partitionInfoConcurrency = 8
 )
 
 type (
@@ -52,6 +68,41 @@ type (
 	client struct {
 		namespace *namespace
 		hubName   string
+
+		retryOptions *managementRetryOptions
+
+		// cache holds runtime information between requests; nil disables caching
+		cache *runtimeInfoCache
+
+		// decodeDiagnostics reports unexpected and missing keys in management responses
+		decodeDiagnostics bool
+
+		// breaker fails requests fast while the management node is unhealthy; nil disables it
+		breaker *managementCircuitBreaker
+
+		// throttle holds requests back while the service reports it is busy; shared with the Hub's senders and
+		// receivers
+		throttle *serverBusyThrottle
+
+		// timeout bounds requests whose context has no deadline; 0 leaves them bound only by their context
+		timeout time.Duration
+	}
+
+	managementRetryOptions struct {
+		backoff *backoff.Backoff
+
+		// maxRetries controls how many times a request is retried (in addition to the first attempt)
+		// 0 indicates no retries, and < 0 will retry until the context or timeout expires.
+		// Defaults to 2.
+		maxRetries int
+
+		// timeout bounds the total time spent on a request, including all retries. 0 means the request is only
+		// bound by the context.
+		timeout time.Duration
+
+		// attemptTimeout bounds each attempt of a request. 0 means an attempt is only bound by timeout and the
+		// context. Defaults to 30 seconds.
+		attemptTimeout time.Duration
 	}
 
 	// HubRuntimeInformation provides management node information about a given Event Hub instance
@@ -76,19 +127,33 @@ type (
 // newClient constructs a new AMQP management client
 func newClient(namespace *namespace, hubName string) *client {
 	return &client{
-		namespace: namespace,
-		hubName:   hubName,
+		namespace:    namespace,
+		hubName:      hubName,
+		retryOptions: newManagementRetryOptions(),
+	}
+}
+
+func newManagementRetryOptions() *managementRetryOptions {
+	return &managementRetryOptions{
+		backoff: &backoff.Backoff{
+			Min:    1 * time.Second,
+			Max:    8 * time.Second,
+			Jitter: true,
+		},
+		maxRetries:     2,
+		attemptTimeout: defaultManagementAttemptTimeout,
 	}
 }
 
 // GetHubRuntimeInformation requests runtime information for an Event Hub
 func (c *client) GetHubRuntimeInformation(ctx context.Context, conn *amqp.Client) (*HubRuntimeInformation, error) {
-	ctx, span := tab.StartSpan(ctx, "eh.mgmt.client.GetHubRuntimeInformation")
+	span, ctx := c.startSpanFromContext(ctx, "eh.mgmt.client.GetHubRuntimeInformation")
 	defer span.End()
 
-	rpcLink, err := rpc.NewLink(conn, address)
-	if err != nil {
-		return nil, err
+	if c.cache != nil {
+		if info, ok := c.cache.getHub(); ok {
+			return info, nil
+		}
 	}
 
 	msg := &amqp.Message{
@@ -98,31 +163,37 @@ func (c *client) GetHubRuntimeInformation(ctx context.Context, conn *amqp.Client
 			entityNameKey: c.hubName,
 		},
 	}
-	msg, err = c.addSecurityToken(msg)
+	msg, err := c.addSecurityToken(msg)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := rpcLink.RetryableRPC(ctx, 3, 1*time.Second, msg)
+	res, err := c.rpc(ctx, conn, msg)
 	if err != nil {
 		return nil, err
 	}
 
-	hubRuntimeInfo, err := newHubRuntimeInformation(res.Message)
+	hubRuntimeInfo, err := newHubRuntimeInformation(ctx, res.Message, c.decodeDiagnostics)
 	if err != nil {
 		return nil, err
 	}
+
+	if c.cache != nil {
+		c.cache.setHub(hubRuntimeInfo)
+	}
 	return hubRuntimeInfo, nil
 }
 
 // GetHubPartitionRuntimeInformation fetches runtime information from the AMQP management node for a given partition
 func (c *client) GetHubPartitionRuntimeInformation(ctx context.Context, conn *amqp.Client, partitionID string) (*HubPartitionRuntimeInformation, error) {
-	ctx, span := tab.StartSpan(ctx, "eh.mgmt.client.GetHubPartitionRuntimeInformation")
+	span, ctx := c.startSpanFromContext(ctx, "eh.mgmt.client.GetHubPartitionRuntimeInformation")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition_id", partitionID))
 
-	rpcLink, err := rpc.NewLink(conn, address)
-	if err != nil {
-		return nil, err
+	if c.cache != nil {
+		if info, ok := c.cache.getPartition(partitionID); ok {
+			return info, nil
+		}
 	}
 
 	msg := &amqp.Message{
@@ -133,56 +204,399 @@ func (c *client) GetHubPartitionRuntimeInformation(ctx context.Context, conn *am
 			partitionNameKey: partitionID,
 		},
 	}
-	msg, err = c.addSecurityToken(msg)
+	msg, err := c.addSecurityToken(msg)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := rpcLink.RetryableRPC(ctx, 3, 1*time.Second, msg)
+	res, err := c.rpc(ctx, conn, msg)
 	if err != nil {
 		return nil, err
 	}
 
-	hubPartitionRuntimeInfo, err := newHubPartitionRuntimeInformation(res.Message)
+	hubPartitionRuntimeInfo, err := newHubPartitionRuntimeInformation(ctx, res.Message, c.decodeDiagnostics)
 	if err != nil {
 		return nil, err
 	}
+
+	if c.cache != nil {
+		c.cache.setPartition(hubPartitionRuntimeInfo)
+	}
 	return hubPartitionRuntimeInfo, nil
 }
 
+// Invoke sends an arbitrary operation to the management node and returns the raw response message. The operation and
+// entity type are set as application properties along with the name of the Event Hub; any additional properties, such
+// as a partition, are merged in and may override the entity name. The security token and client request ID are added
+// automatically.
+func (c *client) Invoke(ctx context.Context, conn *amqp.Client, operation, entityType string, properties map[string]interface{}) (*amqp.Message, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.mgmt.client.Invoke")
+	defer span.End()
+	span.AddAttributes(
+		tab.StringAttribute("eh.mgmt.operation", operation),
+		tab.StringAttribute("eh.mgmt.entity_type", entityType),
+	)
+
+	if operation == "" {
+		return nil, errors.New("operation must not be empty")
+	}
+
+	appProps := map[string]interface{}{
+		operationKey:  operation,
+		entityTypeKey: entityType,
+		entityNameKey: c.hubName,
+	}
+	for k, v := range properties {
+		if k == operationKey || k == entityTypeKey || k == securityTokenKey || k == requestIDKey {
+			return nil, fmt.Errorf("property %q is managed by Invoke and must not be set", k)
+		}
+		appProps[k] = v
+	}
+
+	msg, err := c.addSecurityToken(&amqp.Message{ApplicationProperties: appProps})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.rpc(ctx, conn, msg)
+	if err != nil {
+		return nil, err
+	}
+	return res.Message, nil
+}
+
+// GetAllPartitionsRuntimeInformation fetches runtime information for every partition of the Event Hub. The partition
+// requests are issued concurrently over the shared management link and the results are keyed by partition ID.
+func (c *client) GetAllPartitionsRuntimeInformation(ctx context.Context, conn *amqp.Client) (map[string]*HubPartitionRuntimeInformation, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.mgmt.client.GetAllPartitionsRuntimeInformation")
+	defer span.End()
+
+	hubInfo, err := c.GetHubRuntimeInformation(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		infos    = make(map[string]*HubPartitionRuntimeInformation, len(hubInfo.PartitionIDs))
+		sem      = make(chan struct{}, partitionInfoConcurrency)
+	)
+
+	for _, partitionID := range hubInfo.PartitionIDs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(partitionID string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			info, err := c.GetHubPartitionRuntimeInformation(ctx, conn, partitionID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to fetch runtime information for partition %q: %v", partitionID, err)
+					cancel()
+				}
+				return
+			}
+			infos[partitionID] = info
+		}(partitionID)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		tab.For(ctx).Error(firstErr)
+		return nil, firstErr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+// uncached returns a copy of the client which serves every request from the management node, leaving the cache as is
+func (c *client) uncached() *client {
+	uncached := *c
+	uncached.cache = nil
+	return &uncached
+}
+
+// Invalidate drops any cached runtime information so the next request is served by the management node
+func (c *client) Invalidate() {
+	if c.cache != nil {
+		c.cache.invalidate()
+	}
+}
+
+// rpc sends a request to the management node over the RPC link shared by the users of the connection. If the request fails because of the link, the
+// link is discarded and the request is retried on a newly attached link.
+func (c *client) rpc(ctx context.Context, conn *amqp.Client, msg *amqp.Message) (*rpc.Response, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.mgmt.client.rpc")
+	defer span.End()
+
+	// the request ID stays the same across attempts, so the service's logs of every attempt share it
+	ctx, requestID := StartRequest(ctx, span)
+	if msg.ApplicationProperties == nil {
+		msg.ApplicationProperties = make(map[string]interface{})
+	}
+	msg.ApplicationProperties[requestIDKey] = requestID
+
+	ctx, cancel := withDefaultTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if op, ok := msg.ApplicationProperties[operationKey].(string); ok {
+		span.AddAttributes(tab.StringAttribute("eh.mgmt.operation", op))
+	}
+
+	var attempts int64
+	defer func() {
+		span.AddAttributes(tab.Int64Attribute("eh.mgmt.attempts", attempts))
+	}()
+
+	if c.breaker != nil {
+		if err := c.breaker.allow(); err != nil {
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+	}
+
+	res, err := retryManagementRequest(ctx, c.retryOptions, func(ctx context.Context) (*rpc.Response, error) {
+		attempts++
+		if err := c.throttle.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		if c.retryOptions != nil && c.retryOptions.attemptTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.retryOptions.attemptTimeout)
+			defer cancel()
+		}
+
+		links := c.namespace.rpcLinks()
+		link, err := links.get(conn, address)
+		if err != nil {
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+
+		res, err := link.RPC(ctx, msg)
+		if err != nil {
+			tab.For(ctx).Error(err)
+			if closeErr := links.discardFailed(conn, address, link, err); closeErr != nil {
+				tab.For(ctx).Debug(fmt.Sprintf("failed to close management link: %v", closeErr))
+			}
+			c.throttle.observe(err)
+			// errors the service classified keep their type, so only retryable conditions are retried
+			if mapped, ok := fromAMQPError(err).(retryableError); ok {
+				return nil, mapped
+			}
+			return nil, common.Retryable(err.Error())
+		}
+
+		tab.For(ctx).Debug(fmt.Sprintf("management request attempt %d completed with status code %d", attempts, res.Code))
+		err = managementErrorFromStatus(res.Code, res.Description)
+		c.throttle.observe(err)
+		if err != nil {
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+		return res, nil
+	})
+
+	if c.breaker != nil {
+		c.breaker.record(err)
+	}
+	return res, err
+}
+
+// managementErrorFromStatus maps the status code of a management response to a typed error, returning nil for success
+func managementErrorFromStatus(code int, description string) error {
+	switch {
+	case code >= 200 && code < 300:
+		return nil
+	case code == http.StatusNotFound:
+		return ErrNotFound{Description: description}
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ErrUnauthorized{Description: description}
+	case code == http.StatusServiceUnavailable:
+		return ErrServerBusy{Description: description}
+	default:
+		return ManagementError{Code: code, Description: description}
+	}
+}
+
+// isRetryableManagementError indicates whether a failed management request may succeed if it is sent again
+func isRetryableManagementError(err error) bool {
+	if _, ok := err.(common.Retryable); ok {
+		return true
+	}
+	return IsRetryable(err)
+}
+
+// retryManagementRequest calls try until it succeeds, returns an error which is not retryable, or the retry options are
+// exhausted. The delay between attempts is cut short if the context expires.
+func retryManagementRequest(ctx context.Context, opts *managementRetryOptions, try func(ctx context.Context) (*rpc.Response, error)) (*rpc.Response, error) {
+	if opts == nil {
+		opts = newManagementRetryOptions()
+	}
+
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+
+	// create a per call copy as Duration() modifies its state
+	backoff := opts.backoff.Copy()
+
+	var lastErr error
+	// maxRetries >= 0 == finite retries
+	// maxRetries < 0 == retry until the context expires
+	for i := 0; i < opts.maxRetries+1 || opts.maxRetries < 0; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(backoff.Duration()):
+			case <-ctx.Done():
+				if lastErr != nil {
+					return nil, lastErr
+				}
+				return nil, ctx.Err()
+			}
+		}
+
+		res, err := try(ctx)
+		if err == nil {
+			return res, nil
+		}
+
+		lastErr = err
+		if !isRetryableManagementError(err) || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+
+	return nil, lastErr
+}
+
 func (c *client) addSecurityToken(msg *amqp.Message) (*amqp.Message, error) {
 	token, err := c.namespace.tokenProvider.GetToken(c.getTokenAudience())
 	if err != nil {
 		return nil, err
 	}
-	msg.ApplicationProperties[securityTokenKey] = token.Token
+
+	switch token.TokenType {
+	case auth.CBSTokenTypeSAS, auth.CBSTokenTypeJWT:
+		// the management node expects the raw token value for both SAS signatures and AAD bearer tokens; the token
+		// type is only communicated for CBS put-token requests
+		msg.ApplicationProperties[securityTokenKey] = token.Token
+	default:
+		return nil, fmt.Errorf("token type %q is not supported for management requests", token.TokenType)
+	}
 
 	return msg, nil
 }
 
+// getTokenAudience returns the audience used to request a token for the management node. SAS tokens are signed for
+// this resource URI, while JWT providers scope tokens to the Event Hubs resource and ignore the audience.
 func (c *client) getTokenAudience() string {
 	return c.namespace.getAmqpHostURI() + c.hubName
 }
 
-func newHubPartitionRuntimeInformation(msg *amqp.Message) (*HubPartitionRuntimeInformation, error) {
-	values, ok := msg.Value.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("values were not map[string]interface{}, it was: %v", values)
-	}
-
+func newHubPartitionRuntimeInformation(ctx context.Context, msg *amqp.Message, diagnostics bool) (*HubPartitionRuntimeInformation, error) {
 	var partitionInfo HubPartitionRuntimeInformation
-	err := mapstructure.Decode(values, &partitionInfo)
-	return &partitionInfo, err
+	if err := decodeManagementResponse(ctx, msg, &partitionInfo, diagnostics); err != nil {
+		return nil, err
+	}
+	return &partitionInfo, nil
 }
 
 // newHubRuntimeInformation constructs a new HubRuntimeInformation from an AMQP message
-func newHubRuntimeInformation(msg *amqp.Message) (*HubRuntimeInformation, error) {
+func newHubRuntimeInformation(ctx context.Context, msg *amqp.Message, diagnostics bool) (*HubRuntimeInformation, error) {
+	var runtimeInfo HubRuntimeInformation
+	if err := decodeManagementResponse(ctx, msg, &runtimeInfo, diagnostics); err != nil {
+		return nil, err
+	}
+	return &runtimeInfo, nil
+}
+
+// decodeManagementResponse decodes the map carried by a management response into out. With diagnostics enabled, keys
+// in the response which out does not define and fields of out which the response did not provide are reported as
+// debug events, which helps spot drift between this client and the management protocol.
+func decodeManagementResponse(ctx context.Context, msg *amqp.Message, out interface{}, diagnostics bool) error {
+	if msg == nil {
+		return errors.New("management response did not contain a message")
+	}
+
 	values, ok := msg.Value.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("values were not map[string]interface{}, it was: %v", values)
+		return fmt.Errorf("management response value was expected to be map[string]interface{}, but was %T: %v", msg.Value, msg.Value)
 	}
 
-	var runtimeInfo HubRuntimeInformation
-	err := mapstructure.Decode(values, &runtimeInfo)
-	return &runtimeInfo, err
+	var md mapstructure.Metadata
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Metadata: &md,
+		Result:   out,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := decoder.Decode(values); err != nil {
+		return fmt.Errorf("failed to decode management response into %T: %v", out, err)
+	}
+
+	if diagnostics {
+		if len(md.Unused) > 0 {
+			sort.Strings(md.Unused)
+			tab.For(ctx).Debug(fmt.Sprintf("management response contained keys not decoded into %T: %v", out, md.Unused))
+		}
+
+		if missing := missingResponseKeys(out, values); len(missing) > 0 {
+			tab.For(ctx).Debug(fmt.Sprintf("management response was missing keys expected by %T: %v", out, missing))
+		}
+	}
+	return nil
+}
+
+// missingResponseKeys returns the mapstructure keys of the struct pointed to by out which are absent from values
+func missingResponseKeys(out interface{}, values map[string]interface{}) []string {
+	t := reflect.TypeOf(out)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var missing []string
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("mapstructure"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+
+		if _, ok := values[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	return missing
 }
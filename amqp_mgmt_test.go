@@ -0,0 +1,236 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	common "github.com/Azure/azure-amqp-common-go/v3"
+	"github.com/Azure/azure-amqp-common-go/v3/auth"
+	"github.com/Azure/azure-amqp-common-go/v3/rpc"
+	"github.com/Azure/go-amqp"
+	"github.com/jpillora/backoff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTokenProvider struct {
+	tokenType auth.TokenType
+	audiences []string
+}
+
+func (tp *fakeTokenProvider) GetToken(uri string) (*auth.Token, error) {
+	tp.audiences = append(tp.audiences, uri)
+	return auth.NewToken(tp.tokenType, "token-value", "0"), nil
+}
+
+func newTestManagementClient(tp auth.TokenProvider) *client {
+	ns := &namespace{
+		name:          "ns",
+		host:          "amqps://ns.servicebus.windows.net",
+		tokenProvider: tp,
+	}
+	return newClient(ns, "hub")
+}
+
+func TestManagementClient_AddSecurityToken(t *testing.T) {
+	for _, tokenType := range []auth.TokenType{auth.CBSTokenTypeSAS, auth.CBSTokenTypeJWT} {
+		t.Run(string(tokenType), func(t *testing.T) {
+			tp := &fakeTokenProvider{tokenType: tokenType}
+			c := newTestManagementClient(tp)
+
+			msg, err := c.addSecurityToken(&amqp.Message{ApplicationProperties: map[string]interface{}{}})
+			require.NoError(t, err)
+			assert.Equal(t, "token-value", msg.ApplicationProperties[securityTokenKey])
+			assert.Equal(t, []string{"amqp://ns.servicebus.windows.net/hub"}, tp.audiences)
+		})
+	}
+
+	t.Run("Unsupported", func(t *testing.T) {
+		c := newTestManagementClient(&fakeTokenProvider{tokenType: "unknown"})
+		_, err := c.addSecurityToken(&amqp.Message{ApplicationProperties: map[string]interface{}{}})
+		assert.Error(t, err)
+	})
+}
+
+func TestRetryManagementRequest(t *testing.T) {
+	newOpts := func(maxRetries int) *managementRetryOptions {
+		return &managementRetryOptions{
+			backoff:    &backoff.Backoff{Min: time.Millisecond, Max: time.Millisecond},
+			maxRetries: maxRetries,
+		}
+	}
+
+	t.Run("SucceedsAfterRetryableErrors", func(t *testing.T) {
+		calls := 0
+		res, err := retryManagementRequest(context.Background(), newOpts(2), func(ctx context.Context) (*rpc.Response, error) {
+			calls++
+			if calls < 3 {
+				return nil, common.Retryable("busy")
+			}
+			return &rpc.Response{Code: 200}, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("ExceedsRetries", func(t *testing.T) {
+		calls := 0
+		_, err := retryManagementRequest(context.Background(), newOpts(1), func(ctx context.Context) (*rpc.Response, error) {
+			calls++
+			return nil, common.Retryable("busy")
+		})
+		assert.EqualError(t, err, "busy")
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("StopsOnNonRetryableError", func(t *testing.T) {
+		calls := 0
+		_, err := retryManagementRequest(context.Background(), newOpts(5), func(ctx context.Context) (*rpc.Response, error) {
+			calls++
+			return nil, errors.New("fatal")
+		})
+		assert.EqualError(t, err, "fatal")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("RetriesServerBusyButNotNotFound", func(t *testing.T) {
+		calls := 0
+		_, err := retryManagementRequest(context.Background(), newOpts(5), func(ctx context.Context) (*rpc.Response, error) {
+			calls++
+			if calls == 1 {
+				return nil, managementErrorFromStatus(503, "busy")
+			}
+			return nil, managementErrorFromStatus(404, "no such hub")
+		})
+		assert.Equal(t, ErrNotFound{Description: "no such hub"}, err)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("HonorsTimeoutBetweenAttempts", func(t *testing.T) {
+		opts := newOpts(-1)
+		opts.backoff = &backoff.Backoff{Min: time.Hour, Max: time.Hour}
+		opts.timeout = 10 * time.Millisecond
+
+		calls := 0
+		start := time.Now()
+		_, err := retryManagementRequest(context.Background(), opts, func(ctx context.Context) (*rpc.Response, error) {
+			calls++
+			return nil, common.Retryable("busy")
+		})
+		assert.EqualError(t, err, "busy")
+		assert.Equal(t, 1, calls)
+		assert.True(t, time.Since(start) < time.Minute)
+	})
+}
+
+func TestManagementErrorFromStatus(t *testing.T) {
+	assert.NoError(t, managementErrorFromStatus(200, "ok"))
+	assert.NoError(t, managementErrorFromStatus(202, "accepted"))
+	assert.Equal(t, ErrNotFound{Description: "d"}, managementErrorFromStatus(404, "d"))
+	assert.Equal(t, ErrUnauthorized{Description: "d"}, managementErrorFromStatus(401, "d"))
+	assert.Equal(t, ErrUnauthorized{Description: "d"}, managementErrorFromStatus(403, "d"))
+	assert.Equal(t, ErrServerBusy{Description: "d"}, managementErrorFromStatus(503, "d"))
+
+	err := managementErrorFromStatus(500, "d")
+	assert.Equal(t, ManagementError{Code: 500, Description: "d"}, err)
+	assert.True(t, isRetryableManagementError(err))
+	assert.False(t, isRetryableManagementError(managementErrorFromStatus(400, "d")))
+
+	var notFound ErrNotFound
+	assert.True(t, errors.As(managementErrorFromStatus(404, "d"), &notFound))
+}
+
+func TestManagementClient_InvokeRejectsReservedProperties(t *testing.T) {
+	c := newTestManagementClient(&fakeTokenProvider{tokenType: auth.CBSTokenTypeSAS})
+
+	_, err := c.Invoke(context.Background(), nil, "", eventHubEntityType, nil)
+	assert.Error(t, err)
+
+	for _, key := range []string{operationKey, entityTypeKey, securityTokenKey, requestIDKey} {
+		_, err := c.Invoke(context.Background(), nil, readOperationKey, eventHubEntityType, map[string]interface{}{key: "value"})
+		assert.Error(t, err, key)
+	}
+}
+
+func TestDecodeManagementResponse(t *testing.T) {
+	_, err := newHubRuntimeInformation(context.Background(), &amqp.Message{Value: "not a map"}, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "string")
+
+	_, err = newHubRuntimeInformation(context.Background(), nil, false)
+	assert.Error(t, err)
+
+	msg := &amqp.Message{Value: map[string]interface{}{
+		"name":            "hub",
+		"type":            eventHubEntityType,
+		"partition_count": int32(2),
+		"partition_ids":   []string{"0", "1"},
+	}}
+	info, err := newHubRuntimeInformation(context.Background(), msg, true)
+	require.NoError(t, err)
+	assert.Equal(t, "hub", info.Path)
+	assert.Equal(t, 2, info.PartitionCount)
+
+	assert.Equal(t, []string{"created_at"}, missingResponseKeys(info, msg.Value.(map[string]interface{})))
+}
+
+func TestFromAMQPError(t *testing.T) {
+	busy := &amqp.Error{Condition: errorServerBusy, Description: "try later"}
+	err := fromAMQPError(busy)
+	assert.True(t, errors.Is(err, ErrServerBusy{}))
+	assert.True(t, IsRetryable(err))
+	var raw *amqp.Error
+	require.True(t, errors.As(err, &raw), "the original AMQP error stays reachable")
+	assert.Same(t, busy, raw)
+
+	detached := fromAMQPError(&amqp.DetachError{RemoteError: &amqp.Error{Condition: conditionDetachForced}})
+	assert.True(t, errors.Is(detached, ErrLinkDetached{}))
+	assert.True(t, IsRetryable(detached))
+
+	stolen := fromAMQPError(&amqp.DetachError{RemoteError: &amqp.Error{Condition: conditionLinkStolen}})
+	var linkErr ErrLinkDetached
+	require.True(t, errors.As(stolen, &linkErr))
+	assert.True(t, linkErr.Stolen)
+	assert.False(t, IsRetryable(stolen))
+
+	assert.True(t, errors.Is(fromAMQPError(&amqp.Error{Condition: conditionResourceLimitExceeded}), ErrResourceLimitExceeded{}))
+	assert.False(t, IsRetryable(fromAMQPError(&amqp.Error{Condition: conditionUnauthorizedAccess})))
+	assert.True(t, errors.Is(fromAMQPError(&amqp.Error{Condition: conditionUnauthorizedAccess}), ErrUnauthorized{}))
+
+	var generic AMQPError
+	require.True(t, errors.As(fromAMQPError(&amqp.Error{Condition: "amqp:precondition-failed"}), &generic))
+	assert.Equal(t, "amqp:precondition-failed", generic.Condition)
+	assert.False(t, generic.Retryable())
+
+	plain := errors.New("boom")
+	assert.Equal(t, plain, fromAMQPError(plain))
+	assert.False(t, IsRetryable(plain))
+	assert.True(t, IsRetryable(fmt.Errorf("wrapped: %w", ManagementError{Code: 500})))
+}
+
+func TestSentinelErrors(t *testing.T) {
+	tooLarge := fromAMQPError(&amqp.Error{Condition: conditionMessageSizeExceeded, Description: "exceeds 1MB"})
+	assert.True(t, errors.Is(tooLarge, ErrMessageTooLarge), "messages the service rejects for their size match")
+	assert.True(t, errors.Is(tooLarge, ErrMessageIsTooBig))
+	assert.False(t, errors.Is(fromAMQPError(&amqp.Error{Condition: "amqp:precondition-failed"}), ErrMessageTooLarge))
+
+	outOfRange := &amqp.DetachError{RemoteError: &amqp.Error{Condition: conditionArgumentOutOfRange, Description: "should be between 0 and 3"}}
+	err := partitionNotFound("7", outOfRange)
+	var notFound ErrPartitionNotFound
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "7", notFound.PartitionID)
+	assert.True(t, errors.Is(err, ErrPartitionNotFound{}))
+	assert.True(t, errors.Is(err, ErrNotFound{}), "a missing partition is a missing entity")
+	assert.False(t, IsRetryable(err))
+	var detach *amqp.DetachError
+	assert.True(t, errors.As(err, &detach))
+
+	assert.True(t, errors.Is(partitionNotFound("7", managementErrorFromStatus(404, "no partition")), ErrPartitionNotFound{}))
+	busy := ErrServerBusy{Description: "try later"}
+	assert.Equal(t, busy, partitionNotFound("7", busy))
+	assert.False(t, errors.Is(ErrNotFound{}, ErrPartitionNotFound{}), "other missing entities aren't partitions")
+}
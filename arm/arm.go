@@ -0,0 +1,146 @@
+// Package arm provides Azure Resource Manager based management of Event Hubs, consumer groups and authorization rules.
+package arm
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	mgmt "github.com/Azure/azure-sdk-for-go/services/eventhub/mgmt/2017-04-01/eventhub"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	azauth "github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+type (
+	// Client manages the entities of an Event Hubs namespace through Azure Resource Manager
+	Client struct {
+		subscriptionID string
+		resourceGroup  string
+		namespace      string
+		env            azure.Environment
+		authorizer     autorest.Authorizer
+
+		hubs           mgmt.EventHubsClient
+		consumerGroups mgmt.ConsumerGroupsClient
+		namespaces     mgmt.NamespacesClient
+		geoDR          mgmt.DisasterRecoveryConfigsClient
+	}
+
+	// ClientOption provides structure for configuring a new Client
+	ClientOption func(c *Client) error
+)
+
+// ClientWithAuthorizer configures the Client to authorize Azure Resource Manager requests with the given authorizer.
+//
+// By default, the authorizer is built from environment variables. See auth.NewAuthorizerFromEnvironment.
+func ClientWithAuthorizer(authorizer autorest.Authorizer) ClientOption {
+	return func(c *Client) error {
+		c.authorizer = authorizer
+		return nil
+	}
+}
+
+// ClientWithEnvironment configures the Client to use the specified Azure Environment.
+//
+// By default, the Client will use the Azure US Public cloud environment
+func ClientWithEnvironment(env azure.Environment) ClientOption {
+	return func(c *Client) error {
+		c.env = env
+		return nil
+	}
+}
+
+// NewClient creates a new Azure Resource Manager client for the Event Hubs namespace in the given subscription and
+// resource group
+func NewClient(subscriptionID, resourceGroup, namespace string, opts ...ClientOption) (*Client, error) {
+	if subscriptionID == "" || resourceGroup == "" || namespace == "" {
+		return nil, errors.New("subscriptionID, resourceGroup and namespace must not be empty")
+	}
+
+	c := &Client{
+		subscriptionID: subscriptionID,
+		resourceGroup:  resourceGroup,
+		namespace:      namespace,
+		env:            azure.PublicCloud,
+	}
+
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.authorizer == nil {
+		authorizer, err := azauth.NewAuthorizerFromEnvironmentWithResource(c.env.ResourceManagerEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		c.authorizer = authorizer
+	}
+
+	c.hubs = mgmt.NewEventHubsClientWithBaseURI(c.env.ResourceManagerEndpoint, c.subscriptionID)
+	c.hubs.Authorizer = c.authorizer
+	c.consumerGroups = mgmt.NewConsumerGroupsClientWithBaseURI(c.env.ResourceManagerEndpoint, c.subscriptionID)
+	c.consumerGroups.Authorizer = c.authorizer
+	c.namespaces = mgmt.NewNamespacesClientWithBaseURI(c.env.ResourceManagerEndpoint, c.subscriptionID)
+	c.namespaces.Authorizer = c.authorizer
+	c.geoDR = mgmt.NewDisasterRecoveryConfigsClientWithBaseURI(c.env.ResourceManagerEndpoint, c.subscriptionID)
+	c.geoDR.Authorizer = c.authorizer
+	return c, nil
+}
+
+// Namespace returns the name of the Event Hubs namespace managed by the Client
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+func (c *Client) startSpanFromContext(ctx context.Context, operationName string) (tab.Spanner, context.Context) {
+	ctx, span := tab.StartSpan(ctx, operationName)
+	eventhub.ApplyComponentInfo(span)
+	span.AddAttributes(
+		tab.StringAttribute("span.kind", "client"),
+		tab.StringAttribute("eh.namespace", c.namespace),
+	)
+	return span, ctx
+}
+
+// isNotFound indicates that the Azure Resource Manager responded with a 404
+func isNotFound(res autorest.Response, err error) bool {
+	if res.Response != nil && res.StatusCode == http.StatusNotFound {
+		return true
+	}
+
+	var detailed autorest.DetailedError
+	if errors.As(err, &detailed) {
+		if code, ok := detailed.StatusCode.(int); ok && code == http.StatusNotFound {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package arm
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	mgmt "github.com/Azure/azure-sdk-for-go/services/eventhub/mgmt/2017-04-01/eventhub"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+func TestNewClient_RequiresIdentifiers(t *testing.T) {
+	_, err := NewClient("", "rg", "ns", ClientWithAuthorizer(autorest.NullAuthorizer{}))
+	assert.Error(t, err)
+
+	c, err := NewClient("sub", "rg", "ns", ClientWithAuthorizer(autorest.NullAuthorizer{}))
+	require.NoError(t, err)
+	assert.Equal(t, "ns", c.Namespace())
+}
+
+func TestHubOptions(t *testing.T) {
+	model := mgmt.Model{Properties: new(mgmt.Properties)}
+	require.NoError(t, HubWithPartitionCount(8)(&model))
+	require.NoError(t, HubWithMessageRetentionInDays(3)(&model))
+	assert.EqualValues(t, 8, *model.PartitionCount)
+	assert.EqualValues(t, 3, *model.MessageRetentionInDays)
+}
+
+func TestIsNotFound(t *testing.T) {
+	notFound := autorest.Response{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	assert.True(t, isNotFound(notFound, nil))
+
+	detailed := autorest.DetailedError{StatusCode: http.StatusNotFound}
+	assert.True(t, isNotFound(autorest.Response{}, detailed))
+
+	ok := autorest.Response{Response: &http.Response{StatusCode: http.StatusOK}}
+	assert.False(t, isNotFound(ok, errors.New("boom")))
+}
+
+var _ eventhub.ConsumerGroupProvisioner = (*Client)(nil)
+
+func TestNewGeoDRAlias(t *testing.T) {
+	partner := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.EventHub/namespaces/secondary"
+	pending := int64(3)
+	alias := newGeoDRAlias(mgmt.ArmDisasterRecovery{
+		Name: &[]string{"alias"}[0],
+		ArmDisasterRecoveryProperties: &mgmt.ArmDisasterRecoveryProperties{
+			Role:                              mgmt.Primary,
+			PartnerNamespace:                  &partner,
+			PendingReplicationOperationsCount: &pending,
+		},
+	})
+	assert.Equal(t, "alias", alias.Name)
+	assert.EqualValues(t, 3, alias.PendingReplicationOperations)
+	assert.True(t, alias.IsPaired())
+	assert.False(t, alias.IsFailedOver())
+
+	alias.Role = mgmt.PrimaryNotReplicating
+	assert.False(t, alias.IsPaired())
+	assert.True(t, alias.IsFailedOver())
+
+	assert.Equal(t, GeoDRAlias{}, newGeoDRAlias(mgmt.ArmDisasterRecovery{}))
+}
+
+func TestNewNamespaceInfo(t *testing.T) {
+	name := "ns"
+	capacity := int32(4)
+	autoInflate := true
+	ns := mgmt.EHNamespace{
+		Name: &name,
+		Sku:  &mgmt.Sku{Name: mgmt.Standard, Tier: mgmt.SkuTierStandard, Capacity: &capacity},
+		EHNamespaceProperties: &mgmt.EHNamespaceProperties{
+			IsAutoInflateEnabled: &autoInflate,
+		},
+	}
+	four, eight := int64(4), int64(8)
+	hubs := []mgmt.Model{
+		{Properties: &mgmt.Properties{PartitionCount: &four}},
+		{Properties: &mgmt.Properties{PartitionCount: &eight}},
+		{},
+	}
+
+	info := newNamespaceInfo(ns, hubs)
+	assert.Equal(t, "ns", info.Name)
+	assert.Equal(t, mgmt.Standard, info.SKU)
+	assert.EqualValues(t, 4, info.ThroughputUnits)
+	assert.True(t, info.AutoInflateEnabled)
+	assert.Equal(t, 3, info.HubCount)
+	assert.EqualValues(t, 12, info.PartitionCount)
+	assert.EqualValues(t, 4000, info.IngressEventsPerSecond())
+	assert.EqualValues(t, 4*1024*1024, info.IngressBytesPerSecond())
+	assert.EqualValues(t, 8*1024*1024, info.EgressBytesPerSecond())
+}
+
+func TestHubWithCapture(t *testing.T) {
+	model := mgmt.Model{Properties: new(mgmt.Properties)}
+	require.Error(t, HubWithCapture("", "container")(&model))
+
+	require.NoError(t, HubWithCapture("/subscriptions/sub/storage", "container",
+		CaptureWithInterval(5*time.Minute),
+		CaptureWithSizeLimit(20*1024*1024),
+		CaptureWithSkipEmptyArchives(),
+	)(&model))
+	require.NotNil(t, model.CaptureDescription)
+	assert.True(t, *model.CaptureDescription.Enabled)
+	assert.EqualValues(t, 300, *model.CaptureDescription.IntervalInSeconds)
+	assert.EqualValues(t, 20*1024*1024, *model.CaptureDescription.SizeLimitInBytes)
+	assert.Equal(t, "container", *model.CaptureDescription.Destination.BlobContainer)
+
+	require.NoError(t, HubWithCaptureDisabled()(&model))
+	assert.False(t, *model.CaptureDescription.Enabled)
+	assert.Equal(t, "container", *model.CaptureDescription.Destination.BlobContainer, "disabling capture keeps its destination")
+
+	assert.Error(t, HubWithCapture("id", "container", CaptureWithInterval(time.Second))(&model))
+	assert.Error(t, HubWithCapture("id", "container", CaptureWithSizeLimit(1))(&model))
+}
@@ -0,0 +1,142 @@
+package arm
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"fmt"
+
+	mgmt "github.com/Azure/azure-sdk-for-go/services/eventhub/mgmt/2017-04-01/eventhub"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/devigned/tab"
+)
+
+type (
+	// ConsumerGroupOption provides structure for configuring a consumer group created or updated through Azure
+	// Resource Manager
+	ConsumerGroupOption func(cg *mgmt.ConsumerGroup) error
+)
+
+// ConsumerGroupWithUserMetadata configures the consumer group with user metadata, such as a description of the
+// consuming application
+func ConsumerGroupWithUserMetadata(metadata string) ConsumerGroupOption {
+	return func(cg *mgmt.ConsumerGroup) error {
+		cg.UserMetadata = to.StringPtr(metadata)
+		return nil
+	}
+}
+
+// PutConsumerGroup creates or updates a consumer group on an Event Hub
+func (c *Client) PutConsumerGroup(ctx context.Context, hubName, name string, opts ...ConsumerGroupOption) (*mgmt.ConsumerGroup, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.PutConsumerGroup")
+	defer span.End()
+
+	cg := mgmt.ConsumerGroup{
+		Name:                    to.StringPtr(name),
+		ConsumerGroupProperties: new(mgmt.ConsumerGroupProperties),
+	}
+
+	for _, opt := range opts {
+		if err := opt(&cg); err != nil {
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+	}
+
+	res, err := c.consumerGroups.CreateOrUpdate(ctx, c.resourceGroup, c.namespace, hubName, name, cg)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetConsumerGroup fetches a consumer group of an Event Hub by name. If the consumer group does not exist, nil is
+// returned without an error.
+func (c *Client) GetConsumerGroup(ctx context.Context, hubName, name string) (*mgmt.ConsumerGroup, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.GetConsumerGroup")
+	defer span.End()
+
+	res, err := c.consumerGroups.Get(ctx, c.resourceGroup, c.namespace, hubName, name)
+	if isNotFound(res.Response, err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListConsumerGroups fetches all of the consumer groups of an Event Hub
+func (c *Client) ListConsumerGroups(ctx context.Context, hubName string) ([]mgmt.ConsumerGroup, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.ListConsumerGroups")
+	defer span.End()
+
+	var groups []mgmt.ConsumerGroup
+	page, err := c.consumerGroups.ListByEventHub(ctx, c.resourceGroup, c.namespace, hubName, nil, nil)
+	for ; err == nil && page.NotDone(); err = page.NextWithContext(ctx) {
+		groups = append(groups, page.Values()...)
+	}
+
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	return groups, nil
+}
+
+// DeleteConsumerGroup deletes a consumer group of an Event Hub. Deleting a consumer group which does not exist is not
+// an error.
+func (c *Client) DeleteConsumerGroup(ctx context.Context, hubName, name string) error {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.DeleteConsumerGroup")
+	defer span.End()
+
+	res, err := c.consumerGroups.Delete(ctx, c.resourceGroup, c.namespace, hubName, name)
+	if err != nil && !isNotFound(res, err) {
+		tab.For(ctx).Error(err)
+		return err
+	}
+	return nil
+}
+
+// EnsureConsumerGroup creates the consumer group on the Event Hub if it does not already exist. It satisfies
+// eventhub.ConsumerGroupProvisioner, so the Client can be used with eventhub.HubWithConsumerGroupAutoCreate.
+func (c *Client) EnsureConsumerGroup(ctx context.Context, hubName, name string) error {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.EnsureConsumerGroup")
+	defer span.End()
+
+	cg, err := c.GetConsumerGroup(ctx, hubName, name)
+	if err != nil {
+		return err
+	}
+
+	if cg != nil {
+		return nil
+	}
+
+	tab.For(ctx).Debug(fmt.Sprintf("creating missing consumer group %q on %q", name, hubName))
+	_, err = c.PutConsumerGroup(ctx, hubName, name)
+	return err
+}
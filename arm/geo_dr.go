@@ -0,0 +1,141 @@
+package arm
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+
+	mgmt "github.com/Azure/azure-sdk-for-go/services/eventhub/mgmt/2017-04-01/eventhub"
+	"github.com/devigned/tab"
+)
+
+type (
+	// GeoDRAlias describes a Geo-DR alias of the namespace and the role the namespace plays in the pairing
+	GeoDRAlias struct {
+		Name string
+		// Role is Primary or Secondary while the pairing is active. After a failover, or after the pairing is broken,
+		// the namespace which remains reachable through the alias reports PrimaryNotReplicating.
+		Role mgmt.RoleDisasterRecovery
+		// PartnerNamespace is the ARM ID of the paired namespace, or empty if the namespace is not paired
+		PartnerNamespace string
+		// AlternateName is the name specified when the alias and namespace names are the same
+		AlternateName                string
+		ProvisioningState            mgmt.ProvisioningStateDR
+		PendingReplicationOperations int64
+	}
+)
+
+// IsPaired indicates that the alias is actively pairing a primary and secondary namespace
+func (a GeoDRAlias) IsPaired() bool {
+	return a.PartnerNamespace != "" && a.Role != mgmt.PrimaryNotReplicating
+}
+
+// IsFailedOver indicates that the namespace is serving the alias without replicating to a partner, which is the state
+// after a failover has been initiated. Clients connected through the namespace name, rather than the alias, should
+// reconnect through the alias to reach the new primary.
+func (a GeoDRAlias) IsFailedOver() bool {
+	return a.Role == mgmt.PrimaryNotReplicating
+}
+
+// ListGeoDRAliases fetches all of the Geo-DR aliases configured for the namespace
+func (c *Client) ListGeoDRAliases(ctx context.Context) ([]GeoDRAlias, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.ListGeoDRAliases")
+	defer span.End()
+
+	var aliases []GeoDRAlias
+	page, err := c.geoDR.List(ctx, c.resourceGroup, c.namespace)
+	for ; err == nil && page.NotDone(); err = page.NextWithContext(ctx) {
+		for _, dr := range page.Values() {
+			aliases = append(aliases, newGeoDRAlias(dr))
+		}
+	}
+
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// GetGeoDRAlias fetches a Geo-DR alias of the namespace by name. If the alias does not exist, nil is returned without
+// an error.
+func (c *Client) GetGeoDRAlias(ctx context.Context, alias string) (*GeoDRAlias, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.GetGeoDRAlias")
+	defer span.End()
+
+	res, err := c.geoDR.Get(ctx, c.resourceGroup, c.namespace, alias)
+	if isNotFound(res.Response, err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+
+	dr := newGeoDRAlias(res)
+	return &dr, nil
+}
+
+// GetGeoDRAliasConnectionString fetches the primary connection string of the alias for the given namespace
+// authorization rule. Connecting through the alias connection string follows the alias across failovers.
+func (c *Client) GetGeoDRAliasConnectionString(ctx context.Context, alias, ruleName string) (string, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.GetGeoDRAliasConnectionString")
+	defer span.End()
+
+	keys, err := c.geoDR.ListKeys(ctx, c.resourceGroup, c.namespace, alias, ruleName)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return "", err
+	}
+
+	if keys.AliasPrimaryConnectionString == nil {
+		err := errors.New("the authorization rule did not return an alias connection string")
+		tab.For(ctx).Error(err)
+		return "", err
+	}
+	return *keys.AliasPrimaryConnectionString, nil
+}
+
+func newGeoDRAlias(dr mgmt.ArmDisasterRecovery) GeoDRAlias {
+	var alias GeoDRAlias
+	if dr.Name != nil {
+		alias.Name = *dr.Name
+	}
+
+	if props := dr.ArmDisasterRecoveryProperties; props != nil {
+		alias.Role = props.Role
+		alias.ProvisioningState = props.ProvisioningState
+		if props.PartnerNamespace != nil {
+			alias.PartnerNamespace = *props.PartnerNamespace
+		}
+		if props.AlternateName != nil {
+			alias.AlternateName = *props.AlternateName
+		}
+		if props.PendingReplicationOperationsCount != nil {
+			alias.PendingReplicationOperations = *props.PendingReplicationOperationsCount
+		}
+	}
+	return alias
+}
@@ -0,0 +1,339 @@
+package arm
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	mgmt "github.com/Azure/azure-sdk-for-go/services/eventhub/mgmt/2017-04-01/eventhub"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/devigned/tab"
+)
+
+const (
+	captureDestinationBlob          = "EventHubArchive.AzureBlockBlob"
+	defaultCaptureArchiveNameFormat = "{Namespace}/{EventHub}/{PartitionId}/{Year}/{Month}/{Day}/{Hour}/{Minute}/{Second}"
+)
+
+type (
+	// HubOption provides structure for configuring an Event Hub created or updated through Azure Resource Manager
+	HubOption func(model *mgmt.Model) error
+
+	// CaptureOption provides structure for configuring Event Hubs Capture
+	CaptureOption func(capture *mgmt.CaptureDescription) error
+)
+
+// HubWithPartitionCount configures the Event Hub to have the specified number of partitions
+func HubWithPartitionCount(count int64) HubOption {
+	return func(model *mgmt.Model) error {
+		model.PartitionCount = to.Int64Ptr(count)
+		return nil
+	}
+}
+
+// HubWithMessageRetentionInDays configures the Event Hub to retain messages for the specified number of days
+func HubWithMessageRetentionInDays(days int64) HubOption {
+	return func(model *mgmt.Model) error {
+		model.MessageRetentionInDays = to.Int64Ptr(days)
+		return nil
+	}
+}
+
+// HubWithCapture configures the Event Hub to capture its events as Avro files in the given blob container of the
+// storage account identified by its ARM resource ID
+func HubWithCapture(storageAccountResourceID, blobContainer string, opts ...CaptureOption) HubOption {
+	return func(model *mgmt.Model) error {
+		if storageAccountResourceID == "" || blobContainer == "" {
+			return errors.New("capture requires a storage account resource ID and a blob container")
+		}
+
+		capture := &mgmt.CaptureDescription{
+			Enabled:  to.BoolPtr(true),
+			Encoding: mgmt.Avro,
+			Destination: &mgmt.Destination{
+				Name: to.StringPtr(captureDestinationBlob),
+				DestinationProperties: &mgmt.DestinationProperties{
+					StorageAccountResourceID: to.StringPtr(storageAccountResourceID),
+					BlobContainer:            to.StringPtr(blobContainer),
+					ArchiveNameFormat:        to.StringPtr(defaultCaptureArchiveNameFormat),
+				},
+			},
+		}
+
+		for _, opt := range opts {
+			if err := opt(capture); err != nil {
+				return err
+			}
+		}
+
+		model.CaptureDescription = capture
+		return nil
+	}
+}
+
+// HubWithCaptureDisabled disables Event Hubs Capture on the Event Hub, keeping the rest of its capture configuration
+func HubWithCaptureDisabled() HubOption {
+	return func(model *mgmt.Model) error {
+		if model.CaptureDescription == nil {
+			return nil
+		}
+		model.CaptureDescription.Enabled = to.BoolPtr(false)
+		return nil
+	}
+}
+
+// CaptureWithInterval configures how often captured events are written. The service accepts 1 to 15 minutes.
+func CaptureWithInterval(interval time.Duration) CaptureOption {
+	return func(capture *mgmt.CaptureDescription) error {
+		if interval < time.Minute || interval > 15*time.Minute {
+			return fmt.Errorf("capture interval must be between 1 and 15 minutes, got %v", interval)
+		}
+		capture.IntervalInSeconds = to.Int32Ptr(int32(interval / time.Second))
+		return nil
+	}
+}
+
+// CaptureWithSizeLimit configures how many bytes accumulate before captured events are written. The service accepts
+// 10 MiB to 500 MiB.
+func CaptureWithSizeLimit(bytes int32) CaptureOption {
+	return func(capture *mgmt.CaptureDescription) error {
+		if bytes < 10*1024*1024 || bytes > 500*1024*1024 {
+			return fmt.Errorf("capture size limit must be between 10 MiB and 500 MiB, got %d bytes", bytes)
+		}
+		capture.SizeLimitInBytes = to.Int32Ptr(bytes)
+		return nil
+	}
+}
+
+// CaptureWithArchiveNameFormat configures the blob naming convention of capture files. The format must contain all
+// of {Namespace}, {EventHub}, {PartitionId}, {Year}, {Month}, {Day}, {Hour}, {Minute} and {Second}.
+func CaptureWithArchiveNameFormat(format string) CaptureOption {
+	return func(capture *mgmt.CaptureDescription) error {
+		capture.Destination.ArchiveNameFormat = to.StringPtr(format)
+		return nil
+	}
+}
+
+// CaptureWithSkipEmptyArchives configures capture not to write files for windows without events
+func CaptureWithSkipEmptyArchives() CaptureOption {
+	return func(capture *mgmt.CaptureDescription) error {
+		capture.SkipEmptyArchives = to.BoolPtr(true)
+		return nil
+	}
+}
+
+// PutHub creates or updates an Event Hub
+func (c *Client) PutHub(ctx context.Context, name string, opts ...HubOption) (*mgmt.Model, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.PutHub")
+	defer span.End()
+
+	model := mgmt.Model{
+		Name:       to.StringPtr(name),
+		Properties: new(mgmt.Properties),
+	}
+
+	for _, opt := range opts {
+		if err := opt(&model); err != nil {
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+	}
+
+	res, err := c.hubs.CreateOrUpdate(ctx, c.resourceGroup, c.namespace, name, model)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateHub applies the options to the current configuration of an existing Event Hub, such as changing its message
+// retention, its partition count (on SKUs which allow it) or its capture settings. Settings not changed by the options
+// are preserved.
+func (c *Client) UpdateHub(ctx context.Context, name string, opts ...HubOption) (*mgmt.Model, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.UpdateHub")
+	defer span.End()
+
+	model, err := c.GetHub(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if model == nil {
+		err := fmt.Errorf("event hub %q does not exist in namespace %q", name, c.namespace)
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+
+	if model.Properties == nil {
+		model.Properties = new(mgmt.Properties)
+	}
+
+	for _, opt := range opts {
+		if err := opt(model); err != nil {
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+	}
+
+	res, err := c.hubs.CreateOrUpdate(ctx, c.resourceGroup, c.namespace, name, *model)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetHub fetches an Event Hub by name. If the Event Hub does not exist, nil is returned without an error.
+func (c *Client) GetHub(ctx context.Context, name string) (*mgmt.Model, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.GetHub")
+	defer span.End()
+
+	res, err := c.hubs.Get(ctx, c.resourceGroup, c.namespace, name)
+	if isNotFound(res.Response, err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListHubs fetches all of the Event Hubs in the namespace
+func (c *Client) ListHubs(ctx context.Context) ([]mgmt.Model, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.ListHubs")
+	defer span.End()
+
+	var hubs []mgmt.Model
+	page, err := c.hubs.ListByNamespace(ctx, c.resourceGroup, c.namespace, nil, nil)
+	for ; err == nil && page.NotDone(); err = page.NextWithContext(ctx) {
+		hubs = append(hubs, page.Values()...)
+	}
+
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	return hubs, nil
+}
+
+// DeleteHub deletes an Event Hub by name. Deleting an Event Hub which does not exist is not an error.
+func (c *Client) DeleteHub(ctx context.Context, name string) error {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.DeleteHub")
+	defer span.End()
+
+	res, err := c.hubs.Delete(ctx, c.resourceGroup, c.namespace, name)
+	if err != nil && !isNotFound(res, err) {
+		tab.For(ctx).Error(err)
+		return err
+	}
+	return nil
+}
+
+// PutHubAuthorizationRule creates or updates a shared access authorization rule on an Event Hub granting the given
+// rights
+func (c *Client) PutHubAuthorizationRule(ctx context.Context, hubName, ruleName string, rights ...mgmt.AccessRights) (*mgmt.AuthorizationRule, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.PutHubAuthorizationRule")
+	defer span.End()
+
+	rule := mgmt.AuthorizationRule{
+		Name: to.StringPtr(ruleName),
+		AuthorizationRuleProperties: &mgmt.AuthorizationRuleProperties{
+			Rights: &rights,
+		},
+	}
+
+	res, err := c.hubs.CreateOrUpdateAuthorizationRule(ctx, c.resourceGroup, c.namespace, hubName, ruleName, rule)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteHubAuthorizationRule deletes a shared access authorization rule from an Event Hub. Deleting a rule which
+// does not exist is not an error.
+func (c *Client) DeleteHubAuthorizationRule(ctx context.Context, hubName, ruleName string) error {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.DeleteHubAuthorizationRule")
+	defer span.End()
+
+	res, err := c.hubs.DeleteAuthorizationRule(ctx, c.resourceGroup, c.namespace, hubName, ruleName)
+	if err != nil && !isNotFound(res, err) {
+		tab.For(ctx).Error(err)
+		return err
+	}
+	return nil
+}
+
+// ListHubAuthorizationRuleKeys fetches the keys and connection strings of an Event Hub authorization rule
+func (c *Client) ListHubAuthorizationRuleKeys(ctx context.Context, hubName, ruleName string) (*mgmt.AccessKeys, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.ListHubAuthorizationRuleKeys")
+	defer span.End()
+
+	res, err := c.hubs.ListKeys(ctx, c.resourceGroup, c.namespace, hubName, ruleName)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	return &res, nil
+}
+
+// PutNamespaceAuthorizationRule creates or updates a shared access authorization rule on the namespace granting the
+// given rights
+func (c *Client) PutNamespaceAuthorizationRule(ctx context.Context, ruleName string, rights ...mgmt.AccessRights) (*mgmt.AuthorizationRule, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.PutNamespaceAuthorizationRule")
+	defer span.End()
+
+	rule := mgmt.AuthorizationRule{
+		Name: to.StringPtr(ruleName),
+		AuthorizationRuleProperties: &mgmt.AuthorizationRuleProperties{
+			Rights: &rights,
+		},
+	}
+
+	res, err := c.namespaces.CreateOrUpdateAuthorizationRule(ctx, c.resourceGroup, c.namespace, ruleName, rule)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteNamespaceAuthorizationRule deletes a shared access authorization rule from the namespace. Deleting a rule
+// which does not exist is not an error.
+func (c *Client) DeleteNamespaceAuthorizationRule(ctx context.Context, ruleName string) error {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.DeleteNamespaceAuthorizationRule")
+	defer span.End()
+
+	res, err := c.namespaces.DeleteAuthorizationRule(ctx, c.resourceGroup, c.namespace, ruleName)
+	if err != nil && !isNotFound(res, err) {
+		tab.For(ctx).Error(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package arm
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+
+	mgmt "github.com/Azure/azure-sdk-for-go/services/eventhub/mgmt/2017-04-01/eventhub"
+	"github.com/devigned/tab"
+)
+
+const (
+	// ingressBytesPerThroughputUnit is the ingress allowed by a single throughput unit per second
+	ingressBytesPerThroughputUnit = 1024 * 1024
+	// ingressEventsPerThroughputUnit is the number of events a single throughput unit allows per second
+	ingressEventsPerThroughputUnit = 1000
+	// egressBytesPerThroughputUnit is the egress allowed by a single throughput unit per second
+	egressBytesPerThroughputUnit = 2 * 1024 * 1024
+)
+
+type (
+	// NamespaceInfo describes the capacity and contents of an Event Hubs namespace
+	NamespaceInfo struct {
+		Name     string
+		Location string
+		SKU      mgmt.SkuName
+		Tier     mgmt.SkuTier
+		// ThroughputUnits is the number of throughput units currently provisioned for the namespace
+		ThroughputUnits int32
+		// AutoInflateEnabled indicates the service raises ThroughputUnits under load, up to MaximumThroughputUnits
+		AutoInflateEnabled     bool
+		MaximumThroughputUnits int32
+		KafkaEnabled           bool
+		ProvisioningState      string
+		// HubCount is the number of Event Hubs in the namespace
+		HubCount int
+		// PartitionCount is the total number of partitions across all of the Event Hubs in the namespace
+		PartitionCount int64
+	}
+)
+
+// IngressBytesPerSecond returns the ingress the currently provisioned throughput units allow per second
+func (ni NamespaceInfo) IngressBytesPerSecond() int64 {
+	return int64(ni.ThroughputUnits) * ingressBytesPerThroughputUnit
+}
+
+// IngressEventsPerSecond returns the number of events the currently provisioned throughput units allow per second
+func (ni NamespaceInfo) IngressEventsPerSecond() int64 {
+	return int64(ni.ThroughputUnits) * ingressEventsPerThroughputUnit
+}
+
+// EgressBytesPerSecond returns the egress the currently provisioned throughput units allow per second
+func (ni NamespaceInfo) EgressBytesPerSecond() int64 {
+	return int64(ni.ThroughputUnits) * egressBytesPerThroughputUnit
+}
+
+// GetNamespaceInfo fetches the SKU, throughput units and entity counts of the namespace
+func (c *Client) GetNamespaceInfo(ctx context.Context) (*NamespaceInfo, error) {
+	span, ctx := c.startSpanFromContext(ctx, "eh.arm.Client.GetNamespaceInfo")
+	defer span.End()
+
+	ns, err := c.namespaces.Get(ctx, c.resourceGroup, c.namespace)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+
+	hubs, err := c.ListHubs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info := newNamespaceInfo(ns, hubs)
+	return &info, nil
+}
+
+func newNamespaceInfo(ns mgmt.EHNamespace, hubs []mgmt.Model) NamespaceInfo {
+	info := NamespaceInfo{
+		Name:     fromStringPtr(ns.Name),
+		Location: fromStringPtr(ns.Location),
+		HubCount: len(hubs),
+	}
+
+	if ns.Sku != nil {
+		info.SKU = ns.Sku.Name
+		info.Tier = ns.Sku.Tier
+		if ns.Sku.Capacity != nil {
+			info.ThroughputUnits = *ns.Sku.Capacity
+		}
+	}
+
+	if props := ns.EHNamespaceProperties; props != nil {
+		info.ProvisioningState = fromStringPtr(props.ProvisioningState)
+		if props.IsAutoInflateEnabled != nil {
+			info.AutoInflateEnabled = *props.IsAutoInflateEnabled
+		}
+		if props.MaximumThroughputUnits != nil {
+			info.MaximumThroughputUnits = *props.MaximumThroughputUnits
+		}
+		if props.KafkaEnabled != nil {
+			info.KafkaEnabled = *props.KafkaEnabled
+		}
+	}
+
+	for _, hub := range hubs {
+		if hub.Properties != nil && hub.PartitionCount != nil {
+			info.PartitionCount += *hub.PartitionCount
+		}
+	}
+	return info
+}
+
+func fromStringPtr(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -2,8 +2,8 @@ package eventhub
 
 import (
 	"errors"
+	"fmt"
 
-	"github.com/Azure/azure-amqp-common-go/v3/uuid"
 	"github.com/Azure/go-amqp"
 )
 
@@ -25,7 +25,9 @@ type (
 		PartitionEventsMap map[string][]*Event
 	}
 
-	// EventBatch is a batch of Event Hubs messages to be sent
+	// EventBatch is a batch of Event Hubs messages to be sent. It is sent in the envelope the .NET and Java clients use
+	// for batches: an AMQP message of the batch message format, with the partition key of the batch as an annotation
+	// and each event encoded as a complete AMQP message in a data section of its own.
 	EventBatch struct {
 		*Event
 		marshaledMessages [][]byte
@@ -49,8 +51,16 @@ const (
 	KeyOfNoPartitionKey = "NoPartitionKey"
 )
 
-// ErrMessageIsTooBig represents the error when one single event in the batch is bigger than the maximum batch size
-var ErrMessageIsTooBig = errors.New("message is too big")
+var (
+	// ErrMessageTooLarge is returned when an event is bigger than the maximum size of a message or batch, whether the
+	// client finds out before sending or the service rejects the message
+	ErrMessageTooLarge = errors.New("message is too large")
+
+	// ErrMessageIsTooBig represents the error when one single event in the batch is bigger than the maximum batch size
+	//
+	// Deprecated: use ErrMessageTooLarge, which it is equal to
+	ErrMessageIsTooBig = ErrMessageTooLarge
+)
 
 // BatchWithMaxSizeInBytes configures the EventBatchIterator to fill the batch to the specified max size in bytes
 func BatchWithMaxSizeInBytes(sizeInBytes int) BatchOption {
@@ -125,7 +135,7 @@ func (ebi *EventBatchIterator) Next(eventID string, opts *BatchOptions) (*EventB
 		if !ok {
 			if len(eb.marshaledMessages) == 0 {
 				ebi.Cursors[key]++
-				return nil, ErrMessageIsTooBig
+				return nil, ErrMessageTooLarge
 			}
 
 			return eb, nil
@@ -157,20 +167,7 @@ func NewEventBatch(eventID string, opts *BatchOptions) *EventBatch {
 func (eb *EventBatch) Add(e *Event) (bool, error) {
 	e.PartitionKey = eb.PartitionKey
 
-	msg, err := e.toMsg()
-	if err != nil {
-		return false, err
-	}
-
-	if msg.Properties.MessageID == nil || msg.Properties.MessageID == "" {
-		uid, err := uuid.NewV4()
-		if err != nil {
-			return false, err
-		}
-		msg.Properties.MessageID = uid.String()
-	}
-
-	bin, err := msg.MarshalBinary()
+	bin, err := marshalForBatch(e)
 	if err != nil {
 		return false, err
 	}
@@ -196,13 +193,62 @@ func (eb *EventBatch) Size() int {
 	return eb.size + batchMessageWrapperSize + (len(eb.marshaledMessages) * 5)
 }
 
-func (eb *EventBatch) toMsg() (*amqp.Message, error) {
-	batchMessage := eb.amqpBatchMessage()
+// MarshalBinary encodes the batch envelope sent for the batch. The batch message format travels in the transfer of
+// the envelope rather than in its encoding, so it isn't part of the result.
+func (eb *EventBatch) MarshalBinary() ([]byte, error) {
+	msg, err := eb.toMsg()
+	if err != nil {
+		return nil, err
+	}
+	return msg.MarshalBinary()
+}
 
-	batchMessage.Data = make([][]byte, len(eb.marshaledMessages))
-	for idx, bytes := range eb.marshaledMessages {
-		batchMessage.Data[idx] = bytes
+// UnmarshalEventBatch decodes the events of a batch envelope encoded by EventBatch.MarshalBinary or by the .NET and
+// Java clients
+func UnmarshalEventBatch(bin []byte) ([]*Event, error) {
+	envelope := new(amqp.Message)
+	if err := envelope.UnmarshalBinary(bin); err != nil {
+		return nil, err
 	}
+	return eventsFromBatch(envelope)
+}
+
+// IsBatch reports whether the event is a batch envelope, which the service normally unpacks into its events before
+// delivering them
+func (e *Event) IsBatch() bool {
+	return e.message != nil && e.message.Format == batchMessageFormat
+}
+
+// BatchedEvents returns the events of a received batch envelope
+func (e *Event) BatchedEvents() ([]*Event, error) {
+	if !e.IsBatch() {
+		return nil, errors.New("event is not a batch")
+	}
+	return eventsFromBatch(e.message)
+}
+
+func eventsFromBatch(envelope *amqp.Message) ([]*Event, error) {
+	events := make([]*Event, 0, len(envelope.Data))
+	for idx, data := range envelope.Data {
+		msg := new(amqp.Message)
+		if err := msg.UnmarshalBinary(data); err != nil {
+			return nil, fmt.Errorf("event %d of the batch is not an AMQP message: %v", idx, err)
+		}
+		if len(msg.Data) == 0 {
+			msg.Data = [][]byte{nil}
+		}
+
+		event, err := eventFromMsg(msg)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (eb *EventBatch) toMsg() (*amqp.Message, error) {
+	batchMessage := eb.amqpBatchMessage()
 
 	if eb.PartitionKey != nil {
 		batchMessage.Annotations = make(amqp.Annotations)
@@ -213,8 +259,9 @@ func (eb *EventBatch) toMsg() (*amqp.Message, error) {
 }
 
 func (eb *EventBatch) amqpBatchMessage() *amqp.Message {
+	// the marshaled events are the data sections of the envelope as they are; encoding the envelope only reads them
 	return &amqp.Message{
-		Data:   make([][]byte, len(eb.marshaledMessages)),
+		Data:   eb.marshaledMessages,
 		Format: batchMessageFormat,
 		Properties: &amqp.MessageProperties{
 			MessageID: eb.ID,
@@ -4,7 +4,9 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/Azure/go-amqp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	eventhub "github.com/Azure/azure-event-hubs-go/v3"
 )
@@ -107,3 +109,65 @@ func TestOneHugeEvent(t *testing.T) {
 		assert.Equal(t, err, eventhub.ErrMessageIsTooBig)
 	}
 }
+
+func TestEventBatch_MarshalBinary(t *testing.T) {
+	partitionKey := "pk"
+	eb := eventhub.NewEventBatch("batchId", nil)
+	eb.PartitionKey = &partitionKey
+	for i := 0; i < 3; i++ {
+		event := eventhub.NewEventFromString("Foo" + strconv.Itoa(i))
+		event.ID = "event" + strconv.Itoa(i)
+		event.Properties = map[string]interface{}{"index": int64(i)}
+		ok, err := eb.Add(event)
+		require.True(t, ok)
+		require.NoError(t, err)
+	}
+
+	bin, err := eb.MarshalBinary()
+	require.NoError(t, err)
+
+	envelope := new(amqp.Message)
+	require.NoError(t, envelope.UnmarshalBinary(bin))
+	assert.Len(t, envelope.Data, 3)
+	assert.Equal(t, "pk", envelope.Annotations["x-opt-partition-key"])
+
+	events, err := eventhub.UnmarshalEventBatch(bin)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	for i, event := range events {
+		assert.Equal(t, "Foo"+strconv.Itoa(i), string(event.Data))
+		assert.Equal(t, "event"+strconv.Itoa(i), event.ID)
+		assert.Equal(t, int64(i), event.Properties["index"])
+		require.NotNil(t, event.PartitionKey)
+		assert.Equal(t, "pk", *event.PartitionKey)
+		assert.False(t, event.IsBatch())
+	}
+}
+
+func TestUnmarshalEventBatch_NotABatch(t *testing.T) {
+	_, err := eventhub.UnmarshalEventBatch([]byte("not amqp"))
+	assert.Error(t, err)
+}
+
+func BenchmarkEventBatchIterator(b *testing.B) {
+	events := make([]*eventhub.Event, 100)
+	for i := range events {
+		events[i] = eventhub.NewEvent(make([]byte, 256))
+		events[i].Properties = map[string]interface{}{"index": int64(i)}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		iterator := eventhub.NewEventBatchIterator(events...)
+		for !iterator.Done() {
+			batch, err := iterator.Next("batchId", nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := batch.MarshalBinary(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
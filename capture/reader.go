@@ -0,0 +1,199 @@
+// Package capture reads the output of Event Hubs Capture, Avro object container files of EventData records, back into
+// events, for backfilling and reprocessing events which have left the retention window of a hub.
+//
+// A Reader decodes a single Capture file. ReadSource reads every file of a Source, such as the blobs Capture wrote to a
+// storage container, in order, and Replay sends what it reads to a hub:
+//
+//	source := capture.NewBlobSource(containerURL, "mynamespace/myhub/0/2021/06")
+//	err := capture.ReadSource(ctx, source, capture.Replay(hub))
+package capture
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+const (
+	// Schema is the Avro schema of the records Capture writes, one per event
+	Schema = `{
+	"type": "record",
+	"name": "EventData",
+	"namespace": "Microsoft.ServiceBus.Messaging",
+	"fields": [
+		{"name": "SequenceNumber", "type": "long"},
+		{"name": "Offset", "type": "string"},
+		{"name": "EnqueuedTimeUtc", "type": "string"},
+		{"name": "SystemProperties", "type": {"type": "map", "values": ["long", "double", "string", "bytes"]}},
+		{"name": "Properties", "type": {"type": "map", "values": ["long", "double", "string", "bytes", "null"]}},
+		{"name": "Body", "type": ["null", "bytes"]}
+	]
+}`
+
+	// EnqueuedTimeLayout is the layout of the EnqueuedTimeUtc field of Capture records
+	EnqueuedTimeLayout = "1/2/2006 3:04:05 PM"
+
+	partitionKeyAnnotationName = "x-opt-partition-key"
+)
+
+// Reader reads the events of a Capture file
+type Reader struct {
+	ocf *goavro.OCFReader
+}
+
+// NewReader creates a new Reader of the Capture file read from r. Empty files, which Capture writes for time windows
+// without events when configured to, are read as files without events.
+func NewReader(r io.Reader) (*Reader, error) {
+	buffered := bufio.NewReader(r)
+	if _, err := buffered.Peek(1); err == io.EOF {
+		return &Reader{}, nil
+	}
+
+	ocf, err := goavro.NewOCFReader(buffered)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{ocf: ocf}, nil
+}
+
+// Next returns the next event of the file, or io.EOF after the last one
+func (r *Reader) Next() (*eventhub.Event, error) {
+	if r.ocf == nil || !r.ocf.Scan() {
+		if r.ocf != nil && r.ocf.Err() != nil {
+			return nil, r.ocf.Err()
+		}
+		return nil, io.EOF
+	}
+
+	record, err := r.ocf.Read()
+	if err != nil {
+		return nil, err
+	}
+	return eventFromRecord(record)
+}
+
+// eventFromRecord converts an EventData record to an event with its body, application properties and system
+// properties
+func eventFromRecord(record interface{}) (*eventhub.Event, error) {
+	fields, ok := record.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("capture: record is a %T, not an EventData record", record)
+	}
+
+	event := eventhub.NewEvent(nil)
+	if body, ok := unwrapUnion(fields["Body"]).([]byte); ok {
+		event.Data = body
+	}
+
+	if properties, ok := fields["Properties"].(map[string]interface{}); ok && len(properties) > 0 {
+		event.Properties = make(map[string]interface{}, len(properties))
+		for key, value := range properties {
+			event.Properties[key] = unwrapUnion(value)
+		}
+	}
+
+	annotations := make(map[string]interface{})
+	if systemProperties, ok := fields["SystemProperties"].(map[string]interface{}); ok {
+		for key, value := range systemProperties {
+			annotations[key] = unwrapUnion(value)
+		}
+	}
+
+	stringAnnotation := func(key string) *string {
+		if s, ok := annotations[key].(string); ok {
+			return &s
+		}
+		return nil
+	}
+
+	sysProps := &eventhub.SystemProperties{
+		PartitionKey:               stringAnnotation(partitionKeyAnnotationName),
+		IoTHubDeviceConnectionID:   stringAnnotation("iothub-connection-device-id"),
+		IoTHubAuthGenerationID:     stringAnnotation("iothub-connection-auth-generation-id"),
+		IoTHubConnectionAuthMethod: stringAnnotation("iothub-connection-auth-method"),
+		IoTHubConnectionModuleID:   stringAnnotation("iothub-connection-module-id"),
+		Annotations:                annotations,
+	}
+	if enqueued := stringAnnotation("iothub-enqueuedtime"); enqueued != nil {
+		if parsed, err := parseEnqueuedTime(*enqueued); err == nil {
+			sysProps.IoTHubEnqueuedTime = &parsed
+		}
+	}
+
+	sequenceNumber, ok := fields["SequenceNumber"].(int64)
+	if !ok {
+		return nil, errors.New("capture: record has no sequence number")
+	}
+	sysProps.SequenceNumber = &sequenceNumber
+
+	if offset, ok := fields["Offset"].(string); ok && offset != "" {
+		parsed, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("capture: invalid offset %q: %v", offset, err)
+		}
+		sysProps.Offset = &parsed
+	}
+
+	if enqueued, ok := fields["EnqueuedTimeUtc"].(string); ok && enqueued != "" {
+		parsed, err := parseEnqueuedTime(enqueued)
+		if err != nil {
+			return nil, err
+		}
+		sysProps.EnqueuedTime = &parsed
+	}
+
+	event.SystemProperties = sysProps
+	event.PartitionKey = sysProps.PartitionKey
+	return event, nil
+}
+
+// parseEnqueuedTime parses the enqueued time of a record, which is in UTC
+func parseEnqueuedTime(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation(EnqueuedTimeLayout, value, time.UTC); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("capture: invalid enqueued time %q", value)
+}
+
+// unwrapUnion returns the value of a union as decoded by goavro, which wraps values other than null in a map keyed by
+// the name of their type
+func unwrapUnion(value interface{}) interface{} {
+	if wrapped, ok := value.(map[string]interface{}); ok && len(wrapped) == 1 {
+		for _, v := range wrapped {
+			return v
+		}
+	}
+	return value
+}
@@ -0,0 +1,101 @@
+package capture
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func captureFile(t *testing.T, records ...map[string]interface{}) []byte {
+	var buf bytes.Buffer
+	writer, err := goavro.NewOCFWriter(goavro.OCFConfig{W: &buf, Schema: Schema, CompressionName: goavro.CompressionDeflateLabel})
+	require.NoError(t, err)
+	for _, record := range records {
+		require.NoError(t, writer.Append([]interface{}{record}))
+	}
+	return buf.Bytes()
+}
+
+func captureRecord(sequenceNumber int64, body string) map[string]interface{} {
+	return map[string]interface{}{
+		"SequenceNumber":  sequenceNumber,
+		"Offset":          "4294967296",
+		"EnqueuedTimeUtc": "6/3/2021 4:48:47 PM",
+		"SystemProperties": map[string]interface{}{
+			"x-opt-partition-key":         goavro.Union("string", "device-1"),
+			"iothub-connection-device-id": goavro.Union("string", "device-1"),
+		},
+		"Properties": map[string]interface{}{
+			"kind":  goavro.Union("string", "reading"),
+			"count": goavro.Union("long", int64(3)),
+			"none":  nil,
+		},
+		"Body": goavro.Union("bytes", []byte(body)),
+	}
+}
+
+func TestReader(t *testing.T) {
+	reader, err := NewReader(bytes.NewReader(captureFile(t, captureRecord(7, "hello"), captureRecord(8, "world"))))
+	require.NoError(t, err)
+
+	event, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(event.Data))
+	assert.Equal(t, map[string]interface{}{"kind": "reading", "count": int64(3), "none": nil}, event.Properties)
+	require.NotNil(t, event.PartitionKey)
+	assert.Equal(t, "device-1", *event.PartitionKey)
+
+	sysProps := event.SystemProperties
+	assert.Equal(t, int64(7), *sysProps.SequenceNumber)
+	assert.Equal(t, int64(4294967296), *sysProps.Offset)
+	assert.Equal(t, time.Date(2021, 6, 3, 16, 48, 47, 0, time.UTC), *sysProps.EnqueuedTime)
+	assert.Equal(t, "device-1", *sysProps.IoTHubDeviceConnectionID)
+	assert.Equal(t, "device-1", sysProps.Annotations["x-opt-partition-key"])
+
+	event, err = reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(event.Data))
+
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReader_EmptyFile(t *testing.T) {
+	for _, file := range [][]byte{nil, captureFile(t)} {
+		reader, err := NewReader(bytes.NewReader(file))
+		require.NoError(t, err)
+		_, err = reader.Next()
+		assert.Equal(t, io.EOF, err)
+	}
+
+	_, err := NewReader(bytes.NewReader([]byte("not a capture file")))
+	assert.Error(t, err)
+}
+
+func TestReader_InvalidRecord(t *testing.T) {
+	record := captureRecord(1, "hello")
+	record["Offset"] = "not a number"
+	reader, err := NewReader(bytes.NewReader(captureFile(t, record)))
+	require.NoError(t, err)
+
+	_, err = reader.Next()
+	assert.EqualError(t, err, `capture: invalid offset "not a number": strconv.ParseInt: parsing "not a number": invalid syntax`)
+}
+
+func TestParseEnqueuedTime(t *testing.T) {
+	parsed, err := parseEnqueuedTime("12/31/2020 11:59:59 AM")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 12, 31, 11, 59, 59, 0, time.UTC), parsed)
+
+	parsed, err = parseEnqueuedTime("2020-12-31T11:59:59.5Z")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 12, 31, 11, 59, 59, 5e8, time.UTC), parsed)
+
+	_, err = parseEnqueuedTime("yesterday")
+	assert.Error(t, err)
+}
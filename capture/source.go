@@ -0,0 +1,154 @@
+package capture
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+type (
+	// Source lists and opens Capture files
+	Source interface {
+		// List returns the names of the files of the source
+		List(ctx context.Context) ([]string, error)
+		// Open opens the file with the given name for reading
+		Open(ctx context.Context, name string) (io.ReadCloser, error)
+	}
+
+	// BlobSource is a Source of the blobs Capture wrote to an Azure Storage container
+	BlobSource struct {
+		container azblob.ContainerURL
+		prefix    string
+	}
+)
+
+// NewBlobSource creates a new Source of the blobs in container whose names start with prefix. Capture names blobs after
+// the namespace, hub, partition and time window of their events by default, so a prefix such as
+// mynamespace/myhub/0/2021/06 selects the events of partition 0 enqueued in June 2021.
+func NewBlobSource(container azblob.ContainerURL, prefix string) *BlobSource {
+	return &BlobSource{
+		container: container,
+		prefix:    prefix,
+	}
+}
+
+// List returns the names of the blobs of the source
+func (s *BlobSource) List(ctx context.Context) ([]string, error) {
+	var names []string
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		res, err := s.container.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{Prefix: s.prefix})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, blob := range res.Segment.BlobItems {
+			names = append(names, blob.Name)
+		}
+		marker = res.NextMarker
+	}
+	return names, nil
+}
+
+// Open downloads the blob with the given name
+func (s *BlobSource) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	res, err := s.container.NewBlobURL(name).Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3}), nil
+}
+
+// ReadSource reads the files of source in the order of their names, which is the order of their time windows for the
+// default naming of Capture, and calls handler with each of their events. Reading stops at the first error of handler.
+func ReadSource(ctx context.Context, source Source, handler eventhub.Handler) error {
+	ctx, span := tab.StartSpan(ctx, "eh.capture.ReadSource")
+	defer span.End()
+
+	names, err := source.List(ctx)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := readFile(ctx, source, name, handler); err != nil {
+			tab.For(ctx).Error(err)
+			return err
+		}
+	}
+	return nil
+}
+
+func readFile(ctx context.Context, source Source, name string, handler eventhub.Handler) error {
+	body, err := source.Open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	reader, err := NewReader(body)
+	if err != nil {
+		return fmt.Errorf("capture: failed to read %s: %v", name, err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		event, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("capture: failed to read %s: %v", name, err)
+		}
+
+		if err := handler(ctx, event); err != nil {
+			return err
+		}
+	}
+}
+
+// Replay returns a Handler which sends the events it's called with to sender, with their data, application properties
+// and partition key. System properties aren't sent, the hub assigns new ones.
+func Replay(sender eventhub.Sender, opts ...eventhub.SendOption) eventhub.Handler {
+	return func(ctx context.Context, event *eventhub.Event) error {
+		replayed := &eventhub.Event{
+			Data:         event.Data,
+			PartitionKey: event.PartitionKey,
+			Properties:   event.Properties,
+			ContentType:  event.ContentType,
+		}
+		return sender.Send(ctx, replayed, opts...)
+	}
+}
@@ -0,0 +1,83 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+type (
+	memorySource map[string][]byte
+
+	recordingSender struct {
+		events []*eventhub.Event
+	}
+)
+
+func (s memorySource) List(_ context.Context) ([]string, error) {
+	var names []string
+	for name := range s {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (s memorySource) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(s[name])), nil
+}
+
+func (s *recordingSender) Send(_ context.Context, event *eventhub.Event, _ ...eventhub.SendOption) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordingSender) SendBatch(_ context.Context, _ eventhub.BatchIterator, _ ...eventhub.BatchOption) error {
+	return errors.New("not implemented")
+}
+
+func TestReadSource(t *testing.T) {
+	source := memorySource{
+		"ns/hub/0/2021/06/03/16/50/00": captureFile(t, captureRecord(3, "three")),
+		"ns/hub/0/2021/06/03/16/45/00": captureFile(t, captureRecord(1, "one"), captureRecord(2, "two")),
+		"ns/hub/0/2021/06/03/16/55/00": nil,
+	}
+
+	var sequenceNumbers []int64
+	err := ReadSource(context.Background(), source, func(_ context.Context, event *eventhub.Event) error {
+		sequenceNumbers = append(sequenceNumbers, *event.SystemProperties.SequenceNumber)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, sequenceNumbers, "files are read in the order of their time windows")
+
+	stop := errors.New("stop")
+	err = ReadSource(context.Background(), source, func(_ context.Context, _ *eventhub.Event) error {
+		return stop
+	})
+	assert.Equal(t, stop, err)
+
+	source["ns/hub/0/2021/06/03/17/00/00"] = []byte("corrupt")
+	err = ReadSource(context.Background(), source, func(_ context.Context, _ *eventhub.Event) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestReplay(t *testing.T) {
+	source := memorySource{"ns/hub/0/2021/06/03/16/45/00": captureFile(t, captureRecord(1, "one"))}
+	sender := new(recordingSender)
+
+	require.NoError(t, ReadSource(context.Background(), source, Replay(sender)))
+	require.Len(t, sender.events, 1)
+	replayed := sender.events[0]
+	assert.Equal(t, "one", string(replayed.Data))
+	assert.Equal(t, "device-1", *replayed.PartitionKey)
+	assert.Equal(t, "reading", replayed.Properties["kind"])
+	assert.Nil(t, replayed.SystemProperties, "the hub assigns new system properties")
+}
@@ -0,0 +1,238 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+// ErrEventNotInFlight is returned by CheckpointManager.Complete for an event the manager isn't waiting for, either
+// because it wasn't received with the manager or because it was completed already
+var ErrEventNotInFlight = errors.New("event is not in flight")
+
+type (
+	// CheckpointManager lets handlers hand events to a pool of goroutines and complete them in any order without
+	// risking their loss on restart. Receivers configured with ReceiveWithCheckpointManager don't checkpoint an event
+	// when the handler returns; the checkpoint of a partition advances when Complete is called, and only through the
+	// events which have all been completed since the last checkpoint. An event which is never completed, for instance
+	// because its handler failed, holds the checkpoint back, so it and the events after it are received again after a
+	// restart. The events of a partition still in flight are dropped when its receiver recovers or closes, as they will
+	// be received again.
+	//
+	// A CheckpointManager serves the receivers of one consumer group, one per partition, and is safe for concurrent use.
+	CheckpointManager struct {
+		mu         sync.Mutex
+		partitions map[string]*partitionCompletions
+		inFlight   map[*Event]*completion
+	}
+
+	// partitionCompletions holds the events of a partition received since its last checkpoint, in the order they were
+	// received
+	partitionCompletions struct {
+		mu      sync.Mutex
+		store   func(checkpoint persist.Checkpoint) error
+		pending []*completion
+		written *persist.Checkpoint
+		// writeMu serializes the writes of the checkpoint, which happen outside of mu
+		writeMu sync.Mutex
+	}
+
+	completion struct {
+		event      *Event
+		partition  *partitionCompletions
+		checkpoint persist.Checkpoint
+		done       bool
+		// dropped is set when the event is received again, or its receiver recovered or closed, making the receipt moot
+		dropped bool
+	}
+)
+
+// NewCheckpointManager creates a CheckpointManager
+func NewCheckpointManager() *CheckpointManager {
+	return &CheckpointManager{
+		partitions: make(map[string]*partitionCompletions),
+		inFlight:   make(map[*Event]*completion),
+	}
+}
+
+// ReceiveWithCheckpointManager configures the receiver to leave checkpointing to the manager: the checkpoint of the
+// partition advances as events are completed with the manager rather than as the handler returns. Pooled events must
+// be retained by the handler and completed before they are released.
+func ReceiveWithCheckpointManager(manager *CheckpointManager) ReceiveOption {
+	return func(receiver *receiver) error {
+		if manager == nil {
+			return errors.New("checkpoint manager must not be nil")
+		}
+		receiver.checkpoints = manager
+		return nil
+	}
+}
+
+// Complete marks the event as handled. If it and every event of its partition received before it have been completed,
+// the checkpoint of the partition advances to the last of the contiguous completed events and is written, and the error
+// of the write, if any, is returned. Completing an event which was dropped, because it was received again or its
+// receiver recovered or closed, returns ErrEventNotInFlight; the redelivered event is the one to complete.
+func (m *CheckpointManager) Complete(event *Event) error {
+	m.mu.Lock()
+	c, ok := m.inFlight[event]
+	delete(m.inFlight, event)
+	m.mu.Unlock()
+	if !ok {
+		return ErrEventNotInFlight
+	}
+
+	p := c.partition
+	p.mu.Lock()
+	if c.dropped {
+		p.mu.Unlock()
+		return ErrEventNotInFlight
+	}
+	c.done = true
+
+	advanced := 0
+	for advanced < len(p.pending) && p.pending[advanced].done {
+		advanced++
+	}
+	if advanced == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+
+	checkpoint := p.pending[advanced-1].checkpoint
+	p.pending = p.pending[advanced:]
+	store := p.store
+	p.mu.Unlock()
+
+	// the store may be slow, so the checkpoint is written outside of the locks events are tracked and completed under,
+	// one write at a time, never moving the checkpoint back
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	p.mu.Lock()
+	stale := p.written != nil && p.written.SequenceNumber >= checkpoint.SequenceNumber
+	p.mu.Unlock()
+	if stale {
+		return nil
+	}
+
+	if err := store(checkpoint); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.written = &checkpoint
+	p.mu.Unlock()
+	return nil
+}
+
+// Reset drops the events of the partition in flight, for a receiver which recovered from its last checkpoint or
+// closed; they are received again. Receivers configured with ReceiveWithCheckpointManager reset their partition
+// themselves; Reset is for implementations of PartitionedReceiver other than Hub.
+func (m *CheckpointManager) Reset(partitionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.partitions[partitionID]
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m.drop(p.pending)
+	p.pending = nil
+}
+
+// drop marks completions as dropped and forgets their events; m.mu and the lock of their partition must be held
+func (m *CheckpointManager) drop(completions []*completion) {
+	for _, c := range completions {
+		c.dropped = true
+		// pooled events are reused, so the event may be in flight again as another receipt
+		if m.inFlight[c.event] == c {
+			delete(m.inFlight, c.event)
+		}
+	}
+}
+
+// Checkpoint returns the last checkpoint the manager wrote for the partition, if any
+func (m *CheckpointManager) Checkpoint(partitionID string) (persist.Checkpoint, bool) {
+	m.mu.Lock()
+	p, ok := m.partitions[partitionID]
+	m.mu.Unlock()
+	if !ok {
+		return persist.Checkpoint{}, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.written == nil {
+		return persist.Checkpoint{}, false
+	}
+	return *p.written, true
+}
+
+// Pending returns the number of events of the partition received after its last checkpoint, completed or not
+func (m *CheckpointManager) Pending(partitionID string) int {
+	m.mu.Lock()
+	p, ok := m.partitions[partitionID]
+	m.mu.Unlock()
+	if !ok {
+		return 0
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pending)
+}
+
+// Track records an event of the partition about to be handed to the handler, and store as the writer of the partition's
+// checkpoint. Receivers configured with ReceiveWithCheckpointManager track their events themselves; Track is for
+// implementations of PartitionedReceiver other than Hub. An event at or before one already in flight means the receiver
+// went back in the partition, after recovering from the last checkpoint, so the events from it onwards are dropped and
+// are tracked anew as they are received again.
+func (m *CheckpointManager) Track(partitionID string, event *Event, store func(checkpoint persist.Checkpoint) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.partitions[partitionID]
+	if !ok {
+		p = new(partitionCompletions)
+		m.partitions[partitionID] = p
+	}
+
+	c := &completion{event: event, partition: p, checkpoint: event.GetCheckpoint()}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.store = store
+	for i, pending := range p.pending {
+		if pending.checkpoint.SequenceNumber >= c.checkpoint.SequenceNumber {
+			m.drop(p.pending[i:])
+			p.pending = p.pending[:i]
+			break
+		}
+	}
+	m.inFlight[event] = c
+	p.pending = append(p.pending, c)
+}
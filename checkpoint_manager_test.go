@@ -0,0 +1,212 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+func sequencedEvent(sequenceNumber int64) *Event {
+	offset := sequenceNumber * 100
+	return &Event{SystemProperties: &SystemProperties{SequenceNumber: &sequenceNumber, Offset: &offset}}
+}
+
+func TestCheckpointManager_AdvancesThroughContiguousCompletions(t *testing.T) {
+	m := NewCheckpointManager()
+	var written []int64
+	store := func(checkpoint persist.Checkpoint) error {
+		written = append(written, checkpoint.SequenceNumber)
+		return nil
+	}
+
+	events := make([]*Event, 5)
+	for i := range events {
+		events[i] = sequencedEvent(int64(i + 1))
+		m.Track("0", events[i], store)
+	}
+
+	require.NoError(t, m.Complete(events[2]))
+	require.NoError(t, m.Complete(events[1]))
+	assert.Empty(t, written, "the first event is still in flight")
+	_, ok := m.Checkpoint("0")
+	assert.False(t, ok)
+
+	require.NoError(t, m.Complete(events[0]))
+	assert.Equal(t, []int64{3}, written, "the contiguous prefix is checkpointed at once")
+	checkpoint, ok := m.Checkpoint("0")
+	require.True(t, ok)
+	assert.Equal(t, "300", checkpoint.Offset)
+	assert.Equal(t, 2, m.Pending("0"))
+
+	require.NoError(t, m.Complete(events[4]))
+	require.NoError(t, m.Complete(events[3]))
+	assert.Equal(t, []int64{3, 5}, written)
+	assert.Zero(t, m.Pending("0"))
+
+	assert.Equal(t, ErrEventNotInFlight, m.Complete(events[3]))
+	assert.Equal(t, ErrEventNotInFlight, m.Complete(sequencedEvent(9)))
+}
+
+func TestCheckpointManager_PartitionsAreIndependent(t *testing.T) {
+	m := NewCheckpointManager()
+	written := make(map[string]int64)
+	storeFor := func(partitionID string) func(persist.Checkpoint) error {
+		return func(checkpoint persist.Checkpoint) error {
+			written[partitionID] = checkpoint.SequenceNumber
+			return nil
+		}
+	}
+
+	blocked, done := sequencedEvent(1), sequencedEvent(1)
+	m.Track("0", blocked, storeFor("0"))
+	m.Track("1", done, storeFor("1"))
+	m.Track("0", sequencedEvent(2), storeFor("0"))
+
+	require.NoError(t, m.Complete(done))
+	assert.Equal(t, map[string]int64{"1": 1}, written)
+	assert.Equal(t, 2, m.Pending("0"))
+}
+
+func TestCheckpointManager_RedeliveryDropsEarlierReceipts(t *testing.T) {
+	m := NewCheckpointManager()
+	var written []int64
+	failing := errors.New("storage unavailable")
+	var storeErr error
+	store := func(checkpoint persist.Checkpoint) error {
+		if storeErr != nil {
+			return storeErr
+		}
+		written = append(written, checkpoint.SequenceNumber)
+		return nil
+	}
+
+	first, second, third := sequencedEvent(1), sequencedEvent(2), sequencedEvent(3)
+	m.Track("0", first, store)
+	m.Track("0", second, store)
+	m.Track("0", third, store)
+
+	storeErr = failing
+	assert.Equal(t, failing, m.Complete(first))
+	storeErr = nil
+
+	// the receiver recovered from the last checkpoint and receives the second event again
+	again := sequencedEvent(2)
+	m.Track("0", again, store)
+	assert.Equal(t, ErrEventNotInFlight, m.Complete(second), "an event received again is completed through its redelivery")
+	assert.Equal(t, ErrEventNotInFlight, m.Complete(third))
+	assert.Len(t, m.inFlight, 1, "dropped events should not be kept")
+	assert.Empty(t, written)
+
+	require.NoError(t, m.Complete(again))
+	assert.Equal(t, []int64{2}, written)
+}
+
+func TestReceiver_CheckpointManagerDefersCheckpoints(t *testing.T) {
+	ns := &namespace{transport: new(memoryTransport)}
+	s, err := ns.amqpTransport().newSession(nil)
+	require.NoError(t, err)
+	link, err := s.NewReceiver()
+	require.NoError(t, err)
+
+	persister := persist.NewMemoryPersister()
+	r := &receiver{
+		hub:           &Hub{name: "hub", namespace: ns, offsetPersister: persister},
+		receiver:      link,
+		consumerGroup: DefaultConsumerGroup,
+		partitionID:   "0",
+	}
+	manager := NewCheckpointManager()
+	require.NoError(t, ReceiveWithCheckpointManager(manager)(r))
+	assert.Error(t, ReceiveWithCheckpointManager(nil)(r))
+
+	var handled []*Event
+	handler := func(_ context.Context, event *Event) error {
+		handled = append(handled, event)
+		return nil
+	}
+	for _, seq := range []int64{10, 11} {
+		r.handleMessage(context.Background(), &amqp.Message{
+			Data:        [][]byte{[]byte("event")},
+			Annotations: amqp.Annotations{sequenceNumberName: seq, offsetAnnotationName: strconv.FormatInt(seq, 10)},
+		}, handler)
+	}
+	require.Len(t, handled, 2)
+
+	read := func() persist.Checkpoint {
+		checkpoint, err := persister.Read("", "hub", DefaultConsumerGroup, "0")
+		require.NoError(t, err)
+		return checkpoint
+	}
+	assert.Equal(t, persist.StartOfStream, read().Offset, "returning from the handler doesn't checkpoint")
+
+	require.NoError(t, manager.Complete(handled[1]))
+	assert.Equal(t, persist.StartOfStream, read().Offset)
+	require.NoError(t, manager.Complete(handled[0]))
+	assert.Equal(t, "11", read().Offset)
+	assert.EqualValues(t, 11, read().SequenceNumber)
+}
+
+func TestCheckpointManager_Reset(t *testing.T) {
+	m := NewCheckpointManager()
+	var written []int64
+	store := func(checkpoint persist.Checkpoint) error {
+		written = append(written, checkpoint.SequenceNumber)
+		return nil
+	}
+	abandoned, completed := sequencedEvent(1), sequencedEvent(2)
+	m.Track("0", abandoned, store)
+	m.Track("0", completed, store)
+	m.Reset("1")
+	assert.Equal(t, 2, m.Pending("0"), "resetting a partition should leave the others alone")
+
+	m.Reset("0")
+	assert.Empty(t, m.inFlight, "events in flight should be forgotten when their receiver recovers or closes")
+	assert.Zero(t, m.Pending("0"))
+	assert.Equal(t, ErrEventNotInFlight, m.Complete(completed))
+
+	again := sequencedEvent(1)
+	m.Track("0", again, store)
+	require.NoError(t, m.Complete(again))
+	assert.Equal(t, []int64{1}, written)
+}
+
+func TestCheckpointManager_StoresOutsideTheLock(t *testing.T) {
+	m := NewCheckpointManager()
+	storing := make(chan struct{})
+	unblock := make(chan struct{})
+	slow := func(persist.Checkpoint) error {
+		close(storing)
+		<-unblock
+		return nil
+	}
+	first := sequencedEvent(1)
+	m.Track("0", first, slow)
+	completed := make(chan error)
+	go func() { completed <- m.Complete(first) }()
+	<-storing
+
+	tracked := make(chan struct{})
+	go func() {
+		m.Track("0", sequencedEvent(2), slow)
+		m.Track("1", sequencedEvent(1), slow)
+		close(tracked)
+	}()
+	select {
+	case <-tracked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow checkpoint store should not block tracking events")
+	}
+	close(unblock)
+	require.NoError(t, <-completed)
+	checkpoint, ok := m.Checkpoint("0")
+	require.True(t, ok)
+	assert.EqualValues(t, 1, checkpoint.SequenceNumber)
+}
@@ -0,0 +1,280 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+type (
+	// CheckpointStore reads and writes the checkpoints of the partitions of a consumer group, whichever store holds
+	// them. PersisterCheckpointStore adapts the persisters of Hub receivers, and the storage package's
+	// LeaserCheckpointer implements it for Event Processor Hosts.
+	CheckpointStore interface {
+		// ReadCheckpoint returns the checkpoint of the partition, and whether one was stored
+		ReadCheckpoint(ctx context.Context, partitionID string) (persist.Checkpoint, bool, error)
+		WriteCheckpoint(ctx context.Context, partitionID string, checkpoint persist.Checkpoint) error
+	}
+
+	// ResetPosition is the position ResetCheckpoints moves the checkpoints of a consumer group to
+	ResetPosition struct {
+		kind      resetKind
+		timestamp time.Time
+		offsets   map[string]string
+	}
+
+	// CheckpointReset describes the checkpoint of a partition before and after a reset
+	CheckpointReset struct {
+		PartitionID string
+		Before      persist.Checkpoint
+		// HadCheckpoint is false if no checkpoint was stored for the partition before the reset
+		HadCheckpoint bool
+		After         persist.Checkpoint
+		// Delta is the number of events the reset skips, negative when events will be received again. It is only
+		// known, and DeltaKnown set, when neither checkpoint is a point in time or an offset given without the
+		// sequence number of its event.
+		Delta      int64
+		DeltaKnown bool
+	}
+
+	// ResetOption configures ResetCheckpoints
+	ResetOption func(r *checkpointResetter) error
+
+	checkpointResetter struct {
+		dryRun bool
+	}
+
+	resetKind int
+
+	// persisterCheckpointStore is the CheckpointStore of a consumer group in a persist.CheckpointPersister
+	persisterCheckpointStore struct {
+		persister     persist.CheckpointPersister
+		namespace     string
+		hubName       string
+		consumerGroup string
+	}
+)
+
+const (
+	resetToEarliest resetKind = iota
+	resetToLatest
+	resetToTimestamp
+	resetToOffsets
+)
+
+// ResetToEarliest moves the checkpoints to the start of each partition, so every event retained is received again
+func ResetToEarliest() ResetPosition {
+	return ResetPosition{kind: resetToEarliest}
+}
+
+// ResetToLatest moves the checkpoints to the last event enqueued on each partition at the time of the reset, so only
+// events enqueued after it are received
+func ResetToLatest() ResetPosition {
+	return ResetPosition{kind: resetToLatest}
+}
+
+// ResetToTimestamp moves the checkpoints to a point in time, so events enqueued after t are received
+func ResetToTimestamp(t time.Time) ResetPosition {
+	return ResetPosition{kind: resetToTimestamp, timestamp: t}
+}
+
+// ResetToOffsets moves the checkpoint of each partition in offsets to the event at the given offset, so events after
+// it are received. The checkpoints of other partitions are left as they are.
+func ResetToOffsets(offsets map[string]string) ResetPosition {
+	return ResetPosition{kind: resetToOffsets, offsets: offsets}
+}
+
+// ResetWithDryRun computes the checkpoints a reset would write, and their deltas, without writing them
+func ResetWithDryRun() ResetOption {
+	return func(r *checkpointResetter) error {
+		r.dryRun = true
+		return nil
+	}
+}
+
+// PersisterCheckpointStore returns the CheckpointStore of a consumer group of a hub in persister
+func PersisterCheckpointStore(persister persist.CheckpointPersister, namespace, hubName, consumerGroup string) CheckpointStore {
+	return &persisterCheckpointStore{persister: persister, namespace: namespace, hubName: hubName, consumerGroup: consumerGroup}
+}
+
+// ResetCheckpoints rewrites the checkpoints of the partitions of a hub in store to position, returning the checkpoint
+// of each partition before and after the reset. The position of every partition is resolved
+// before any checkpoint is written. Consumers of the group should be stopped during the reset, as they would
+// otherwise overwrite the checkpoints with their own.
+func ResetCheckpoints(ctx context.Context, manager Manager, store CheckpointStore, position ResetPosition, opts ...ResetOption) ([]CheckpointReset, error) {
+	ctx, span := tab.StartSpan(ctx, "eh.ResetCheckpoints")
+	ApplyComponentInfo(span)
+	defer span.End()
+
+	r := new(checkpointResetter)
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+	}
+
+	hubInfo, err := manager.GetRuntimeInformation(ctx)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+
+	partitionIDs := hubInfo.PartitionIDs
+	switch position.kind {
+	case resetToTimestamp:
+		if position.timestamp.IsZero() {
+			err := errors.New("the timestamp to reset the checkpoints to must not be zero")
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+	case resetToOffsets:
+		partitionIDs, err = offsetPartitions(hubInfo.PartitionIDs, position.offsets)
+		if err != nil {
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+	}
+
+	resets := make([]CheckpointReset, 0, len(partitionIDs))
+	for _, partitionID := range partitionIDs {
+		info, err := manager.GetPartitionInformation(ctx, partitionID)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch runtime information for partition %q: %w", partitionID, err)
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+
+		before, ok, err := store.ReadCheckpoint(ctx, partitionID)
+		if err != nil {
+			err = fmt.Errorf("failed to read the checkpoint of partition %q: %w", partitionID, err)
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+		if !ok {
+			before = persist.NewCheckpointFromStartOfStream()
+		}
+
+		reset := CheckpointReset{PartitionID: partitionID, Before: before, HadCheckpoint: ok, After: position.checkpoint(partitionID, info)}
+		from, fromKnown := checkpointSequence(reset.Before, info)
+		to, toKnown := checkpointSequence(reset.After, info)
+		if fromKnown && toKnown {
+			reset.Delta, reset.DeltaKnown = to-from, true
+		}
+		resets = append(resets, reset)
+	}
+
+	if r.dryRun {
+		return resets, nil
+	}
+	for i, reset := range resets {
+		if err := store.WriteCheckpoint(ctx, reset.PartitionID, reset.After); err != nil {
+			err = fmt.Errorf("failed to write the checkpoint of partition %q: %w", reset.PartitionID, err)
+			tab.For(ctx).Error(err)
+			// the checkpoints of the partitions before it were reset
+			return resets[:i], err
+		}
+	}
+	return resets, nil
+}
+
+// offsetPartitions returns the partitions of offsets in order, if they are all partitions of the hub
+func offsetPartitions(hubPartitionIDs []string, offsets map[string]string) ([]string, error) {
+	if len(offsets) == 0 {
+		return nil, errors.New("no offsets to reset the checkpoints to")
+	}
+
+	known := make(map[string]bool, len(hubPartitionIDs))
+	for _, partitionID := range hubPartitionIDs {
+		known[partitionID] = true
+	}
+	partitionIDs := make([]string, 0, len(offsets))
+	for partitionID, offset := range offsets {
+		if !known[partitionID] {
+			return nil, fmt.Errorf("partition %q is not a partition of the hub", partitionID)
+		}
+		if offset == "" {
+			return nil, fmt.Errorf("the offset of partition %q must not be empty", partitionID)
+		}
+		partitionIDs = append(partitionIDs, partitionID)
+	}
+	sort.Strings(partitionIDs)
+	return partitionIDs, nil
+}
+
+// checkpoint returns the checkpoint of the position on the partition
+func (p ResetPosition) checkpoint(partitionID string, info *HubPartitionRuntimeInformation) persist.Checkpoint {
+	switch p.kind {
+	case resetToLatest:
+		if info.LastEnqueuedOffset == "" || info.LastSequenceNumber < info.BeginningSequenceNumber {
+			// nothing has been enqueued yet
+			return persist.NewCheckpointFromStartOfStream()
+		}
+		return persist.NewCheckpoint(info.LastEnqueuedOffset, info.LastSequenceNumber, info.LastEnqueuedTimeUtc)
+	case resetToTimestamp:
+		return persist.NewCheckpoint("", 0, p.timestamp)
+	case resetToOffsets:
+		return persist.NewCheckpoint(p.offsets[partitionID], 0, time.Time{})
+	default:
+		return persist.NewCheckpointFromStartOfStream()
+	}
+}
+
+// checkpointSequence returns the sequence number of the last event before the position of checkpoint, if it is known
+func checkpointSequence(checkpoint persist.Checkpoint, info *HubPartitionRuntimeInformation) (int64, bool) {
+	switch {
+	case checkpoint.Offset == persist.StartOfStream:
+		return info.BeginningSequenceNumber - 1, true
+	case checkpoint.Offset == persist.EndOfStream:
+		return info.LastSequenceNumber, true
+	case checkpoint.Offset == "" || checkpoint.EnqueueTime.IsZero():
+		// a point in time, or an offset without the event it was taken from
+		return 0, false
+	default:
+		return checkpoint.SequenceNumber, true
+	}
+}
+
+func (s *persisterCheckpointStore) ReadCheckpoint(_ context.Context, partitionID string) (persist.Checkpoint, bool, error) {
+	checkpoint, err := s.persister.Read(s.namespace, s.hubName, s.consumerGroup, partitionID)
+	if errors.Is(err, os.ErrNotExist) {
+		return persist.NewCheckpointFromStartOfStream(), false, nil
+	}
+	if err != nil {
+		return checkpoint, false, err
+	}
+	return checkpoint, true, nil
+}
+
+func (s *persisterCheckpointStore) WriteCheckpoint(_ context.Context, partitionID string, checkpoint persist.Checkpoint) error {
+	return s.persister.Write(s.namespace, s.hubName, s.consumerGroup, partitionID, checkpoint)
+}
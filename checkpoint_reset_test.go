@@ -0,0 +1,134 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+// fixedManager returns the same runtime information for each partition: events 10 to 49, the last enqueued at
+// lastEnqueued
+type fixedManager struct {
+	partitionIDs []string
+	lastEnqueued time.Time
+}
+
+func (m fixedManager) GetRuntimeInformation(context.Context) (*HubRuntimeInformation, error) {
+	return &HubRuntimeInformation{PartitionIDs: m.partitionIDs}, nil
+}
+
+func (m fixedManager) GetPartitionInformation(_ context.Context, partitionID string) (*HubPartitionRuntimeInformation, error) {
+	return &HubPartitionRuntimeInformation{
+		PartitionID:             partitionID,
+		BeginningSequenceNumber: 10,
+		LastSequenceNumber:      49,
+		LastEnqueuedOffset:      "4900",
+		LastEnqueuedTimeUtc:     m.lastEnqueued,
+	}, nil
+}
+
+// failingStore fails to write the checkpoint of a partition
+type failingStore struct {
+	CheckpointStore
+	partitionID string
+}
+
+func (s failingStore) WriteCheckpoint(ctx context.Context, partitionID string, checkpoint persist.Checkpoint) error {
+	if partitionID == s.partitionID {
+		return errors.New("store unavailable")
+	}
+	return s.CheckpointStore.WriteCheckpoint(ctx, partitionID, checkpoint)
+}
+
+func TestResetCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	lastEnqueued := time.Now().Truncate(time.Second)
+	manager := fixedManager{partitionIDs: []string{"0", "1"}, lastEnqueued: lastEnqueued}
+	persister := persist.NewMemoryPersister()
+	store := PersisterCheckpointStore(persister, "ns", "hub", "$Default")
+	processed := persist.NewCheckpoint("2000", 20, lastEnqueued.Add(-time.Hour))
+	require.NoError(t, store.WriteCheckpoint(ctx, "0", processed))
+
+	resets, err := ResetCheckpoints(ctx, manager, store, ResetToLatest(), ResetWithDryRun())
+	require.NoError(t, err)
+	latest := persist.NewCheckpoint("4900", 49, lastEnqueued)
+	assert.Equal(t, CheckpointReset{PartitionID: "0", Before: processed, HadCheckpoint: true, After: latest, Delta: 29, DeltaKnown: true}, resets[0])
+	assert.Equal(t, int64(40), resets[1].Delta, "the memory persister reads a missing checkpoint as the start of the stream")
+	checkpoint, err := persister.Read("ns", "hub", "$Default", "0")
+	require.NoError(t, err)
+	assert.Equal(t, processed, checkpoint, "a dry run should not write checkpoints")
+
+	resets, err = ResetCheckpoints(ctx, manager, store, ResetToEarliest())
+	require.NoError(t, err)
+	assert.Equal(t, int64(-11), resets[0].Delta, "events 10 to 20 should be received again")
+	checkpoint, err = persister.Read("ns", "hub", "$Default", "0")
+	require.NoError(t, err)
+	assert.Equal(t, persist.NewCheckpointFromStartOfStream(), checkpoint)
+
+	at := lastEnqueued.Add(-time.Minute)
+	resets, err = ResetCheckpoints(ctx, manager, store, ResetToTimestamp(at))
+	require.NoError(t, err)
+	assert.Equal(t, persist.NewCheckpoint("", 0, at), resets[1].After)
+	assert.False(t, resets[1].DeltaKnown, "the events after a point in time are not known")
+
+	resets, err = ResetCheckpoints(ctx, manager, store, ResetToOffsets(map[string]string{"1": "1200"}))
+	require.NoError(t, err)
+	require.Len(t, resets, 1)
+	assert.Equal(t, "1", resets[0].PartitionID)
+	checkpoint, _, err = store.ReadCheckpoint(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "1200", checkpoint.Offset)
+	checkpoint, _, err = store.ReadCheckpoint(ctx, "0")
+	require.NoError(t, err)
+	assert.Equal(t, persist.NewCheckpoint("", 0, at), checkpoint, "partitions without an offset should be left as they are")
+
+	resets, err = ResetCheckpoints(ctx, manager, failingStore{CheckpointStore: store, partitionID: "1"}, ResetToLatest())
+	assert.Error(t, err)
+	require.Len(t, resets, 1, "the partitions reset before the failure should be reported")
+	assert.Equal(t, "0", resets[0].PartitionID)
+}
+
+func TestResetCheckpoints_InvalidPositions(t *testing.T) {
+	ctx := context.Background()
+	manager := fixedManager{partitionIDs: []string{"0", "1"}}
+	store := PersisterCheckpointStore(persist.NewMemoryPersister(), "ns", "hub", "$Default")
+
+	for name, position := range map[string]ResetPosition{
+		"zero timestamp":    ResetToTimestamp(time.Time{}),
+		"no offsets":        ResetToOffsets(nil),
+		"unknown partition": ResetToOffsets(map[string]string{"0": "100", "7": "100"}),
+		"empty offset":      ResetToOffsets(map[string]string{"0": ""}),
+	} {
+		_, err := ResetCheckpoints(ctx, manager, store, position)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestPersisterCheckpointStore_File(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "checkpoints")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	persister, err := persist.NewFilePersister(dir)
+	require.NoError(t, err)
+	store := PersisterCheckpointStore(persister, "ns", "hub", "$Default")
+
+	_, ok, err := store.ReadCheckpoint(ctx, "0")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	checkpoint := persist.NewCheckpoint("100", 1, time.Now().Truncate(time.Second).UTC())
+	require.NoError(t, store.WriteCheckpoint(ctx, "0", checkpoint))
+	read, ok, err := store.ReadCheckpoint(ctx, "0")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, checkpoint, read)
+}
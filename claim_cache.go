@@ -0,0 +1,206 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp"
+)
+
+const (
+	// claimRenewalMargin is how long before its expiry a claim is renewed
+	claimRenewalMargin = 5 * time.Minute
+	// claimRenewalTimeout bounds renewing a claim in the background
+	claimRenewalTimeout = 30 * time.Second
+)
+
+type (
+	// claimCache remembers the claims negotiated on each connection, so a link covered by a claim negotiated for another
+	// link on the same connection attaches without negotiating again, and links attaching at once, such as the
+	// partition receivers of a host, negotiate a claim they share once rather than each. Claims are renewed ahead of
+	// their expiry, once per connection and audience, for as long as the connection is in use.
+	claimCache struct {
+		mu     sync.Mutex
+		claims map[claimKey]*claim
+		now    func() time.Time
+		after  func(d time.Duration, f func()) *time.Timer
+	}
+
+	claimKey struct {
+		conn     *amqp.Client
+		audience string
+	}
+
+	// claim is a claim negotiated, or being negotiated, on a connection. ready is closed once negotiation finishes.
+	claim struct {
+		negotiate func(ctx context.Context) (time.Time, error)
+		ready     chan struct{}
+		err       error
+		expiry    time.Time
+		renewal   *time.Timer
+	}
+)
+
+func newClaimCache() *claimCache {
+	return &claimCache{
+		claims: make(map[claimKey]*claim),
+		now:    time.Now,
+		after:  time.AfterFunc,
+	}
+}
+
+// ensure makes sure a claim for audience is in force on the connection, calling negotiate unless one is already, or
+// is being negotiated by another link. negotiate returns the expiry of the claim; claims without an expiry aren't
+// kept.
+func (c *claimCache) ensure(ctx context.Context, conn *amqp.Client, audience string, negotiate func(ctx context.Context) (time.Time, error)) error {
+	key := claimKey{conn: conn, audience: audience}
+	for {
+		c.mu.Lock()
+		cl, ok := c.claims[key]
+		if !ok || c.stale(cl) {
+			break
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-cl.ready:
+			if cl.err == nil {
+				return nil
+			}
+			// the negotiation this link waited on failed, perhaps only because its context ended; try again
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	cl := &claim{negotiate: negotiate, ready: make(chan struct{})}
+	c.replace(key, cl)
+	c.mu.Unlock()
+
+	expiry, err := negotiate(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settle(key, cl, expiry, err)
+	close(cl.ready)
+	return err
+}
+
+// stale reports whether a negotiated claim is too close to its expiry to be relied on. c.mu must be held.
+func (c *claimCache) stale(cl *claim) bool {
+	select {
+	case <-cl.ready:
+		return cl.err != nil || !c.now().Before(cl.expiry.Add(-claimRenewalMargin))
+	default:
+		return false
+	}
+}
+
+// replace makes cl the claim for key, stopping the renewal of the claim it replaces. c.mu must be held.
+func (c *claimCache) replace(key claimKey, cl *claim) {
+	if old, ok := c.claims[key]; ok && old.renewal != nil {
+		old.renewal.Stop()
+	}
+	c.claims[key] = cl
+}
+
+// settle records the outcome of negotiating cl, before its ready channel is closed, and schedules its renewal, or
+// forgets it if it failed or has no expiry. c.mu must be held.
+func (c *claimCache) settle(key claimKey, cl *claim, expiry time.Time, err error) {
+	cl.err = err
+	cl.expiry = expiry
+	if c.claims[key] != cl {
+		return
+	}
+
+	if err != nil || expiry.IsZero() {
+		delete(c.claims, key)
+		return
+	}
+
+	renewIn := expiry.Sub(c.now()) - claimRenewalMargin
+	if lifetime := expiry.Sub(c.now()); renewIn < lifetime/2 {
+		// short lived tokens are renewed half way through their life instead
+		renewIn = lifetime / 2
+	}
+	cl.renewal = c.after(renewIn, func() { c.renew(key, cl) })
+}
+
+// renew negotiates cl again ahead of its expiry, unless its connection has been forgotten or another link has
+// negotiated it since. A claim which fails to renew is forgotten, so the next link to attach negotiates it.
+func (c *claimCache) renew(key claimKey, cl *claim) {
+	c.mu.Lock()
+	if c.claims[key] != cl {
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), claimRenewalTimeout)
+	defer cancel()
+	expiry, err := cl.negotiate(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.claims[key] != cl {
+		return
+	}
+
+	// links may be reading the outcome of cl, so the renewed claim takes its place rather than changing it
+	renewed := &claim{negotiate: cl.negotiate, ready: make(chan struct{})}
+	c.claims[key] = renewed
+	c.settle(key, renewed, expiry, err)
+	close(renewed.ready)
+}
+
+// forget drops the claims of a connection which is closing and stops renewing them
+func (c *claimCache) forget(conn *amqp.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, cl := range c.claims {
+		if key.conn == conn {
+			if cl.renewal != nil {
+				cl.renewal.Stop()
+			}
+			delete(c.claims, key)
+		}
+	}
+}
+
+// claimEntityPath returns the entity a claim for a link to entityPath is negotiated for. A claim for a hub covers its
+// partitions and consumer groups, so links to those share the claim of their hub; other entities, such as publishers,
+// have claims of their own.
+func claimEntityPath(entityPath string) string {
+	lower := strings.ToLower(entityPath)
+	for _, sub := range []string{"/consumergroups/", "/partitions/"} {
+		if i := strings.Index(lower, sub); i > 0 {
+			return entityPath[:i]
+		}
+	}
+	return entityPath
+}
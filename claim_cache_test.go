@@ -0,0 +1,153 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClaimCache(now *time.Time) (*claimCache, *[]func()) {
+	var renewals []func()
+	c := newClaimCache()
+	c.now = func() time.Time { return *now }
+	c.after = func(d time.Duration, f func()) *time.Timer {
+		renewals = append(renewals, f)
+		return time.NewTimer(time.Hour)
+	}
+	return c, &renewals
+}
+
+func TestClaimCache_NegotiatesOncePerConnectionAndAudience(t *testing.T) {
+	now := time.Now()
+	c, _ := newTestClaimCache(&now)
+	conn := new(amqp.Client)
+
+	var calls int32
+	release := make(chan struct{})
+	negotiate := func(ctx context.Context) (time.Time, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return now.Add(time.Hour), nil
+	}
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, c.ensure(context.Background(), conn, "amqps://ns/hub", negotiate))
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	require.NoError(t, c.ensure(context.Background(), conn, "amqps://ns/hub", negotiate))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "a claim in force is not negotiated again")
+
+	require.NoError(t, c.ensure(context.Background(), new(amqp.Client), "amqps://ns/hub", negotiate))
+	require.NoError(t, c.ensure(context.Background(), conn, "amqps://ns/other", negotiate))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls), "claims are per connection and audience")
+}
+
+func TestClaimCache_RenegotiatesNearExpiry(t *testing.T) {
+	now := time.Now()
+	c, _ := newTestClaimCache(&now)
+	conn := new(amqp.Client)
+
+	calls := 0
+	negotiate := func(ctx context.Context) (time.Time, error) {
+		calls++
+		return now.Add(time.Hour), nil
+	}
+	require.NoError(t, c.ensure(context.Background(), conn, "aud", negotiate))
+
+	now = now.Add(time.Hour - claimRenewalMargin)
+	require.NoError(t, c.ensure(context.Background(), conn, "aud", negotiate))
+	assert.Equal(t, 2, calls)
+}
+
+func TestClaimCache_FailuresAndClaimsWithoutExpiryAreNotKept(t *testing.T) {
+	now := time.Now()
+	c, _ := newTestClaimCache(&now)
+	conn := new(amqp.Client)
+
+	failure := errors.New("unauthorized")
+	assert.Equal(t, failure, c.ensure(context.Background(), conn, "aud", func(ctx context.Context) (time.Time, error) {
+		return time.Time{}, failure
+	}))
+
+	calls := 0
+	noExpiry := func(ctx context.Context) (time.Time, error) {
+		calls++
+		return time.Time{}, nil
+	}
+	require.NoError(t, c.ensure(context.Background(), conn, "aud", noExpiry))
+	require.NoError(t, c.ensure(context.Background(), conn, "aud", noExpiry))
+	assert.Equal(t, 2, calls)
+}
+
+func TestClaimCache_Renewal(t *testing.T) {
+	now := time.Now()
+	c, renewals := newTestClaimCache(&now)
+	conn := new(amqp.Client)
+
+	calls := 0
+	var failure error
+	negotiate := func(ctx context.Context) (time.Time, error) {
+		calls++
+		return now.Add(time.Hour), failure
+	}
+	require.NoError(t, c.ensure(context.Background(), conn, "aud", negotiate))
+	require.Len(t, *renewals, 1)
+
+	(*renewals)[0]()
+	assert.Equal(t, 2, calls)
+	require.Len(t, *renewals, 2, "a renewed claim is renewed again")
+
+	now = now.Add(50 * time.Minute)
+	require.NoError(t, c.ensure(context.Background(), conn, "aud", negotiate))
+	assert.Equal(t, 2, calls, "the renewed claim is in force")
+
+	failure = errors.New("unauthorized")
+	(*renewals)[1]()
+	assert.Empty(t, c.claims, "a claim which failed to renew is forgotten")
+}
+
+func TestClaimCache_Forget(t *testing.T) {
+	now := time.Now()
+	c, renewals := newTestClaimCache(&now)
+	conn := new(amqp.Client)
+
+	calls := 0
+	negotiate := func(ctx context.Context) (time.Time, error) {
+		calls++
+		return now.Add(time.Hour), nil
+	}
+	require.NoError(t, c.ensure(context.Background(), conn, "aud", negotiate))
+	c.forget(conn)
+	assert.Empty(t, c.claims)
+
+	(*renewals)[0]()
+	assert.Equal(t, 1, calls, "claims of forgotten connections are not renewed")
+}
+
+func TestClaimEntityPath(t *testing.T) {
+	for entityPath, expected := range map[string]string{
+		"hub":              "hub",
+		"hub/Partitions/3": "hub",
+		"hub/ConsumerGroups/$Default/Partitions/0": "hub",
+		"hub/consumergroups/cg/partitions/1":       "hub",
+		"hub/Publishers/device-1":                  "hub/Publishers/device-1",
+	} {
+		assert.Equal(t, expected, claimEntityPath(entityPath), entityPath)
+	}
+}
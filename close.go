@@ -0,0 +1,95 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type (
+	// CloseError is returned by Close when parts of a component failed to close cleanly. Each of its Errors names the
+	// part which failed, such as the sender or the receiver of a partition, and wraps the error it failed with.
+	// errors.Is and errors.As match any of the errors.
+	CloseError struct {
+		Errors []error
+	}
+
+	// closeErrors collects the errors of closing the parts of a component into a CloseError
+	closeErrors struct {
+		mu   sync.Mutex
+		errs []error
+	}
+)
+
+func (e *CloseError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "failed to close cleanly: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the errors matches target
+func (e *CloseError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors which matches target, and if one does, sets target to it and returns true
+func (e *CloseError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// add records that part failed to close with err, unless err is nil or reports a connection already closed
+func (c *closeErrors) add(part string, err error) {
+	if err == nil || isConnectionClosed(err) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errs = append(c.errs, fmt.Errorf("%s: %w", part, err))
+}
+
+// err returns a CloseError of the errors recorded, or nil if there are none
+func (c *closeErrors) err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return &CloseError{Errors: append([]error(nil), c.errs...)}
+}
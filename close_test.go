@@ -0,0 +1,70 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseError(t *testing.T) {
+	errs := new(closeErrors)
+	errs.add("sender", nil)
+	errs.add("receiver of partition 0", amqp.ErrConnClosed)
+	assert.NoError(t, errs.err(), "parts closed already aren't failures")
+
+	errs.add("sender", ErrServerBusy{Description: "try later"})
+	errs.add("receiver of partition 1", ErrNotFound{Description: "gone"})
+	err := errs.err()
+	require.Error(t, err)
+	assert.Equal(t, "failed to close cleanly: sender: server busy: try later; receiver of partition 1: entity not found: gone", err.Error())
+
+	var closeErr *CloseError
+	require.True(t, errors.As(err, &closeErr))
+	assert.Len(t, closeErr.Errors, 2)
+	assert.True(t, errors.Is(err, ErrServerBusy{}))
+	var notFound ErrNotFound
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "gone", notFound.Description)
+	assert.False(t, errors.Is(err, ErrUnauthorized{}))
+}
+
+func TestConnectionPool_CloseReportsEachConnection(t *testing.T) {
+	pool, _ := newTestConnectionPool(t, 1)
+	refused := errors.New("refused")
+	pool.closeClient = func(*amqp.Client) error { return refused }
+	dial := func() (*amqp.Client, error) { return new(amqp.Client), nil }
+	_, err := pool.acquire("sender", "ns-1", dial)
+	require.NoError(t, err)
+	_, err = pool.acquire("sender", "ns-2", dial)
+	require.NoError(t, err)
+
+	err = pool.Close(context.Background())
+	var closeErr *CloseError
+	require.True(t, errors.As(err, &closeErr))
+	assert.Len(t, closeErr.Errors, 2)
+	assert.True(t, errors.Is(err, refused))
+	assert.Contains(t, err.Error(), "connection to ns-1")
+	assert.Contains(t, err.Error(), "connection to ns-2")
+}
+
+func TestConnectionPool_CloseIsBoundedByContext(t *testing.T) {
+	pool, _ := newTestConnectionPool(t, 1)
+	release := make(chan struct{})
+	defer close(release)
+	pool.closeClient = func(*amqp.Client) error {
+		<-release
+		return nil
+	}
+	_, err := pool.acquire("sender", "ns", func() (*amqp.Client, error) { return new(amqp.Client), nil })
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = pool.Close(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
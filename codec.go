@@ -0,0 +1,167 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+const (
+	// ContentTypeJSON is the content type of events encoded by JSONCodec
+	ContentTypeJSON = "application/json"
+)
+
+type (
+	// Codec marshals values to event bodies of a content type and back. Codecs are registered on a Hub with
+	// HubWithCodec, which routes received events to the codec of their content type.
+	Codec interface {
+		// ContentType returns the MIME type of the bodies the codec produces
+		ContentType() string
+		// Marshal encodes v
+		Marshal(v interface{}) ([]byte, error)
+		// Unmarshal decodes data into v
+		Unmarshal(data []byte, v interface{}) error
+	}
+
+	// JSONCodec is a Codec for values which encoding/json can marshal. It is always registered on a Hub.
+	JSONCodec struct{}
+
+	// codecRegistry holds the codecs of a Hub by media type. The zero value knows JSONCodec only.
+	codecRegistry struct {
+		sending Codec
+		byType  map[string]Codec
+	}
+)
+
+// ContentType returns ContentTypeJSON
+func (JSONCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+// Marshal encodes v as JSON
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes the JSON in data into v
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// HubWithCodec registers codec for its content type. Received events with that content type are decoded with codec by
+// Event.Decode, and SendValue encodes values with the codec registered last. JSONCodec is registered on every Hub,
+// and is used by SendValue unless another codec is registered.
+func HubWithCodec(codec Codec) HubOption {
+	return func(h *Hub) error {
+		if codec == nil {
+			return errors.New("codec must not be nil")
+		}
+		if mediaType(codec.ContentType()) == "" {
+			return errors.New("codec must have a content type")
+		}
+		h.codecs.register(codec)
+		return nil
+	}
+}
+
+// NewEventFromValue builds an Event with the value encoded by codec as its data and the content type of codec
+func NewEventFromValue(codec Codec, v interface{}) (*Event, error) {
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	event := NewEvent(data)
+	event.ContentType = codec.ContentType()
+	return event, nil
+}
+
+// SendValue encodes v with the codec of the Hub, JSONCodec unless another codec is registered with HubWithCodec, and
+// sends it as an event to the Event Hub
+func (h *Hub) SendValue(ctx context.Context, v interface{}, opts ...SendOption) error {
+	event, err := NewEventFromValue(h.codecs.forSending(), v)
+	if err != nil {
+		return err
+	}
+	return h.Send(ctx, event, opts...)
+}
+
+// Decode decodes the data of the event into v with the codec of its content type. Events received from a Hub are
+// decoded with the codecs registered on the Hub, other events with JSONCodec only. Events without a content type are
+// decoded with the codec SendValue would encode them with.
+func (e *Event) Decode(v interface{}) error {
+	codecs := e.codecs
+	if codecs == nil {
+		codecs = new(codecRegistry)
+	}
+
+	codec, err := codecs.lookup(e.ContentType)
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(e.Data, v)
+}
+
+func (r *codecRegistry) register(codec Codec) {
+	if r.byType == nil {
+		r.byType = make(map[string]Codec)
+	}
+	r.byType[mediaType(codec.ContentType())] = codec
+	r.sending = codec
+}
+
+func (r *codecRegistry) forSending() Codec {
+	if r.sending == nil {
+		return JSONCodec{}
+	}
+	return r.sending
+}
+
+func (r *codecRegistry) lookup(contentType string) (Codec, error) {
+	if contentType == "" {
+		return r.forSending(), nil
+	}
+
+	typ := mediaType(contentType)
+	if codec, ok := r.byType[typ]; ok {
+		return codec, nil
+	}
+	if typ == ContentTypeJSON {
+		return JSONCodec{}, nil
+	}
+	return nil, fmt.Errorf("no codec is registered for content type %q", contentType)
+}
+
+// mediaType returns the media type of contentType in lower case, without parameters
+func mediaType(contentType string) string {
+	typ, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		typ = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	return typ
+}
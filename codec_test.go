@@ -0,0 +1,73 @@
+package eventhub
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	reading struct {
+		XMLName     xml.Name `json:"-" xml:"reading"`
+		Device      string   `json:"device" xml:"device"`
+		Temperature float64  `json:"temperature" xml:"temperature"`
+	}
+
+	xmlCodec struct{}
+)
+
+func (xmlCodec) ContentType() string {
+	return "application/xml"
+}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+func TestNewEventFromValue(t *testing.T) {
+	event, err := NewEventFromValue(JSONCodec{}, reading{Device: "d1", Temperature: 21.5})
+	require.NoError(t, err)
+	assert.Equal(t, ContentTypeJSON, event.ContentType)
+	assert.JSONEq(t, `{"device":"d1","temperature":21.5}`, string(event.Data))
+
+	var decoded reading
+	require.NoError(t, event.Decode(&decoded))
+	assert.Equal(t, reading{Device: "d1", Temperature: 21.5}, decoded)
+
+	_, err = NewEventFromValue(JSONCodec{}, make(chan int))
+	assert.Error(t, err)
+}
+
+func TestEvent_DecodeRoutesByContentType(t *testing.T) {
+	h := new(Hub)
+	require.NoError(t, HubWithCodec(xmlCodec{})(h))
+	assert.Error(t, HubWithCodec(nil)(h))
+
+	xmlEvent, err := NewEventFromValue(xmlCodec{}, reading{Device: "d1", Temperature: 21.5})
+	require.NoError(t, err)
+	jsonEvent := &Event{Data: []byte(`{"device":"d2"}`), ContentType: "Application/JSON; charset=utf-8"}
+	untyped := &Event{Data: []byte(`<reading><device>d3</device></reading>`)}
+
+	var decoded reading
+	assert.Error(t, xmlEvent.Decode(&decoded), "events which weren't received know JSON only")
+
+	for _, event := range []*Event{xmlEvent, jsonEvent, untyped} {
+		event.codecs = &h.codecs
+	}
+
+	require.NoError(t, xmlEvent.Decode(&decoded))
+	assert.Equal(t, "d1", decoded.Device)
+	require.NoError(t, jsonEvent.Decode(&decoded))
+	assert.Equal(t, "d2", decoded.Device)
+	require.NoError(t, untyped.Decode(&decoded), "events without a content type use the codec of SendValue")
+	assert.Equal(t, "d3", decoded.Device)
+
+	unknown := &Event{Data: []byte{1}, ContentType: "application/octet-stream", codecs: &h.codecs}
+	assert.EqualError(t, unknown.Decode(&decoded), `no codec is registered for content type "application/octet-stream"`)
+}
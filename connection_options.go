@@ -0,0 +1,92 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/go-amqp"
+)
+
+// HubWithConnectionIdleTimeout configures the longest period the Hub's connections wait for a frame from the service
+// before considering the connection dead. Raising it helps on high latency links. A timeout of 0 disables the check.
+// Defaults to 1 minute.
+func HubWithConnectionIdleTimeout(timeout time.Duration) HubOption {
+	return func(h *Hub) error {
+		if timeout < 0 {
+			return fmt.Errorf("connection idle timeout must not be negative, got %v", timeout)
+		}
+		h.namespace.connOptions = append(h.namespace.connOptions, amqp.ConnIdleTimeout(timeout))
+		return nil
+	}
+}
+
+// HubWithConnectTimeout configures how long the Hub waits for the service while establishing a connection. A timeout
+// of 0 waits indefinitely.
+func HubWithConnectTimeout(timeout time.Duration) HubOption {
+	return func(h *Hub) error {
+		if timeout < 0 {
+			return fmt.Errorf("connect timeout must not be negative, got %v", timeout)
+		}
+		h.namespace.connOptions = append(h.namespace.connOptions, amqp.ConnConnectTimeout(timeout))
+		return nil
+	}
+}
+
+// HubWithMaxFrameSize configures the largest AMQP frame, in bytes, the Hub's connections accept. It must be at least
+// 512 bytes.
+func HubWithMaxFrameSize(size uint32) HubOption {
+	return func(h *Hub) error {
+		if size < 512 {
+			return fmt.Errorf("max frame size must be at least 512 bytes, got %d", size)
+		}
+		h.namespace.connOptions = append(h.namespace.connOptions, amqp.ConnMaxFrameSize(size))
+		return nil
+	}
+}
+
+// HubWithMaxSessions configures the maximum number of sessions (AMQP channels) a connection of the Hub may open. It
+// must be between 1 and 65536.
+func HubWithMaxSessions(count int) HubOption {
+	return func(h *Hub) error {
+		if count < 1 || count > 65536 {
+			return fmt.Errorf("max sessions must be between 1 and 65536, got %d", count)
+		}
+		h.namespace.connOptions = append(h.namespace.connOptions, amqp.ConnMaxSessions(count))
+		return nil
+	}
+}
+
+// HubWithContainerID configures the AMQP container ID the Hub's connections present to the service, which makes it
+// possible to identify the connections of an application in service-side diagnostics. Defaults to a random ID.
+func HubWithContainerID(id string) HubOption {
+	return func(h *Hub) error {
+		if id == "" {
+			return errors.New("container ID must not be empty")
+		}
+		h.namespace.connOptions = append(h.namespace.connOptions, amqp.ConnContainerID(id))
+		return nil
+	}
+}
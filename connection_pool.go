@@ -0,0 +1,338 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Azure/go-amqp"
+)
+
+type (
+	// ConnectionPool shares AMQP connections between Hubs. Without a pool, every sender, receiver and management client
+	// of a Hub dials its own connection; with a pool, they draw from at most size connections per namespace endpoint,
+	// handed out round robin. A single pool may be shared by Hubs for different Event Hubs in the same namespace, and
+	// by Hubs in different namespaces.
+	//
+	// Connections are dialed lazily with the settings of the Hub which first needs them. A connection which a sender or
+	// receiver discards during recovery is no longer handed out and is closed once its last user releases it. A
+	// connection which failed a keep-alive probe is closed at once, for all of its users.
+	ConnectionPool struct {
+		size        int
+		closeClient func(*amqp.Client) error
+		rpcLinks    *rpcLinkCache
+
+		mu        sync.Mutex
+		endpoints map[string]*endpointConnections
+		owners    map[*amqp.Client]*pooledConnection
+		closed    bool
+	}
+
+	endpointConnections struct {
+		conns []*pooledConnection
+		next  int
+	}
+
+	pooledConnection struct {
+		client   *amqp.Client
+		endpoint string
+		// users holds each sender, receiver or management client using the connection, so each of them releases it
+		// at most once
+		users     map[interface{}]bool
+		discarded bool
+		// dialed is closed once the connection is dialed, or dialing it failed with err
+		dialed chan struct{}
+		err    error
+	}
+)
+
+// NewConnectionPool creates a pool which holds up to size connections per namespace endpoint
+func NewConnectionPool(size int) (*ConnectionPool, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("connection pool size must be positive, got %d", size)
+	}
+
+	return &ConnectionPool{
+		size:        size,
+		closeClient: (*amqp.Client).Close,
+		rpcLinks:    newRPCLinkCache(),
+		endpoints:   make(map[string]*endpointConnections),
+		owners:      make(map[*amqp.Client]*pooledConnection),
+	}, nil
+}
+
+// Close closes every connection of the pool, including connections still in use by Hubs. Waiting for the connections
+// to close is bounded by ctx. If any of them fails to close cleanly, or ctx is done first, Close returns a *CloseError
+// describing each failure.
+func (p *ConnectionPool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	conns := make([]*pooledConnection, 0, len(p.owners))
+	for client, conn := range p.owners {
+		p.rpcLinks.forget(client)
+		conns = append(conns, conn)
+	}
+	p.endpoints = make(map[string]*endpointConnections)
+	p.owners = make(map[*amqp.Client]*pooledConnection)
+	p.mu.Unlock()
+
+	errs := new(closeErrors)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, conn := range conns {
+			errs.add("connection to "+conn.endpoint, p.closeClient(conn.client))
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs.add("connections", ctx.Err())
+	}
+	return errs.err()
+}
+
+// acquire returns a connection to the endpoint for owner, dialing one with dial if the endpoint has fewer than size
+// connections. The connection is dialed outside of the lock of the pool, so a slow endpoint doesn't hold up the other users of the
+// pool; users handed the connection while it is dialed wait for it.
+func (p *ConnectionPool) acquire(owner interface{}, endpoint string, dial func() (*amqp.Client, error)) (*amqp.Client, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errors.New("connection pool is closed")
+	}
+
+	ep, ok := p.endpoints[endpoint]
+	if !ok {
+		ep = new(endpointConnections)
+		p.endpoints[endpoint] = ep
+	}
+
+	if len(ep.conns) >= p.size {
+		pc := ep.conns[ep.next%len(ep.conns)]
+		ep.next++
+		pc.users[owner] = true
+		p.mu.Unlock()
+
+		<-pc.dialed
+		if pc.err != nil {
+			return nil, pc.err
+		}
+		return pc.client, nil
+	}
+
+	// hold the place of the connection while it is dialed
+	pc := &pooledConnection{endpoint: endpoint, users: map[interface{}]bool{owner: true}, dialed: make(chan struct{})}
+	ep.conns = append(ep.conns, pc)
+	p.mu.Unlock()
+
+	client, err := dial()
+
+	p.mu.Lock()
+	switch {
+	case err != nil:
+		pc.err = err
+	case p.closed || p.endpoints[endpoint] != ep:
+		// the pool was closed, or the endpoint evicted, while the connection was dialed
+		pc.err = errors.New("connection pool was closed or evicted while the connection was dialed")
+	default:
+		pc.client = client
+		p.owners[client] = pc
+	}
+	if pc.err != nil {
+		ep.remove(pc)
+	}
+	close(pc.dialed)
+	p.mu.Unlock()
+
+	if pc.err != nil {
+		if err == nil {
+			_ = p.closeClient(client)
+		}
+		return nil, pc.err
+	}
+	return client, nil
+}
+
+// remove stops pc from being handed out
+func (ep *endpointConnections) remove(pc *pooledConnection) {
+	for i, candidate := range ep.conns {
+		if candidate == pc {
+			ep.conns = append(ep.conns[:i], ep.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+// release returns the connection of owner to the pool. The connection stays open for other users unless it was
+// discarded. Releasing a connection owner doesn't hold does nothing.
+func (p *ConnectionPool) release(owner interface{}, client *amqp.Client) error {
+	p.mu.Lock()
+	pc, ok := p.owners[client]
+	if !ok || !pc.users[owner] {
+		p.mu.Unlock()
+		return nil
+	}
+
+	delete(pc.users, owner)
+	if !pc.discarded || len(pc.users) > 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	delete(p.owners, client)
+	p.rpcLinks.forget(client)
+	p.mu.Unlock()
+	return p.closeClient(client)
+}
+
+// discard stops the connection from being handed out and releases it for owner. Other users may keep using it; it is
+// closed when the last of them releases it.
+func (p *ConnectionPool) discard(owner interface{}, client *amqp.Client) error {
+	p.mu.Lock()
+	if pc, ok := p.owners[client]; ok && !pc.discarded {
+		pc.discarded = true
+		if ep, ok := p.endpoints[pc.endpoint]; ok {
+			ep.remove(pc)
+		}
+	}
+	p.mu.Unlock()
+
+	// connections the pool no longer holds were already closed by fail, evict or Close
+	return p.release(owner, client)
+}
+
+// fail closes a connection proven dead, for every user at once, so their pending operations fail and they recover on
+// other connections
+func (p *ConnectionPool) fail(client *amqp.Client) error {
+	p.mu.Lock()
+	pc, ok := p.owners[client]
+	if ok {
+		delete(p.owners, client)
+		if ep, ok := p.endpoints[pc.endpoint]; ok {
+			ep.remove(pc)
+		}
+	}
+	p.rpcLinks.forget(client)
+	p.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return p.closeClient(client)
+}
+
+// evict closes the connections to the namespace host, whatever endpoint they were dialed at and including connections
+// still in use, so users recovering from their failure dial new ones
+func (p *ConnectionPool) evict(host string) {
+	p.mu.Lock()
+	var clients []*amqp.Client
+	for client, pc := range p.owners {
+		if strings.HasPrefix(pc.endpoint, host+"|") {
+			clients = append(clients, client)
+			delete(p.owners, client)
+			p.rpcLinks.forget(client)
+		}
+	}
+	for endpoint := range p.endpoints {
+		if strings.HasPrefix(endpoint, host+"|") {
+			delete(p.endpoints, endpoint)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, client := range clients {
+		_ = p.closeClient(client)
+	}
+}
+
+// acquireConnection returns a connection to the namespace for owner, either from the connection pool or newly dialed
+func (ns *namespace) acquireConnection(owner interface{}) (*amqp.Client, error) {
+	if ns.pool == nil {
+		return ns.connect()
+	}
+	return ns.pool.acquire(owner, ns.poolKey(), ns.connect)
+}
+
+// releaseConnection gives up the connection of owner which is no longer needed. Unpooled connections are closed.
+func (ns *namespace) releaseConnection(owner interface{}, client *amqp.Client) error {
+	if client == nil {
+		return nil
+	}
+
+	if ns.pool == nil {
+		ns.rpcLinks().forget(client)
+		return client.Close()
+	}
+	return ns.pool.release(owner, client)
+}
+
+// discardConnection gives up the connection of owner which is believed to be broken so it is not used for new links
+func (ns *namespace) discardConnection(owner interface{}, client *amqp.Client) error {
+	if client == nil {
+		return nil
+	}
+
+	if ns.sessionMux != nil {
+		ns.sessionMux.forget(client)
+	}
+
+	if ns.pool == nil {
+		ns.rpcLinks().forget(client)
+		return client.Close()
+	}
+	return ns.pool.discard(owner, client)
+}
+
+// failConnection closes a connection proven dead for all of its users, pooled or not
+func (ns *namespace) failConnection(client *amqp.Client) error {
+	if client == nil {
+		return nil
+	}
+
+	if ns.sessionMux != nil {
+		ns.sessionMux.forget(client)
+	}
+
+	if ns.pool == nil {
+		ns.rpcLinks().forget(client)
+		return client.Close()
+	}
+	return ns.pool.fail(client)
+}
+
+// poolKey identifies connections which are interchangeable for this namespace
+func (ns *namespace) poolKey() string {
+	key := fmt.Sprintf("%s|websocket=%t", ns.host, ns.useWebSocket)
+	if ns.endpointHost != "" || ns.endpointPort != "" || ns.tlsDisabled {
+		key += fmt.Sprintf("|endpoint=%s:%s|tls=%t", ns.endpointHost, ns.endpointPort, !ns.tlsDisabled)
+	}
+	if address := ns.geoDR.redirectedAddress(); address != "" {
+		key += "|redirect=" + address
+	}
+	return key
+}
@@ -0,0 +1,236 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+func newTestConnectionPool(t *testing.T, size int) (*ConnectionPool, map[*amqp.Client]bool) {
+	pool, err := NewConnectionPool(size)
+	require.NoError(t, err)
+
+	closed := make(map[*amqp.Client]bool)
+	pool.closeClient = func(c *amqp.Client) error {
+		closed[c] = true
+		return nil
+	}
+	return pool, closed
+}
+
+func TestConnectionPool_SharesConnections(t *testing.T) {
+	pool, closed := newTestConnectionPool(t, 2)
+	dials := 0
+	dial := func() (*amqp.Client, error) {
+		dials++
+		return new(amqp.Client), nil
+	}
+
+	a, err := pool.acquire("a", "ns", dial)
+	require.NoError(t, err)
+	b, err := pool.acquire("b", "ns", dial)
+	require.NoError(t, err)
+	c, err := pool.acquire("c", "ns", dial)
+	require.NoError(t, err)
+	other, err := pool.acquire("other", "other-ns", dial)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, dials, "each endpoint dials up to the pool size")
+	assert.True(t, a != b)
+	assert.True(t, c == a || c == b)
+	assert.True(t, other != a && other != b)
+
+	require.NoError(t, pool.release("a", a))
+	assert.Empty(t, closed, "released connections stay open for reuse")
+
+	require.NoError(t, pool.Close(context.Background()))
+	assert.Len(t, closed, 3)
+
+	_, err = pool.acquire("d", "ns", dial)
+	assert.Error(t, err)
+}
+
+func TestConnectionPool_Discard(t *testing.T) {
+	pool, closed := newTestConnectionPool(t, 1)
+	dial := func() (*amqp.Client, error) {
+		return new(amqp.Client), nil
+	}
+
+	a, err := pool.acquire("a", "ns", dial)
+	require.NoError(t, err)
+	shared, err := pool.acquire("shared", "ns", dial)
+	require.NoError(t, err)
+	require.True(t, a == shared)
+
+	require.NoError(t, pool.discard("a", a))
+	assert.False(t, closed[a], "a discarded connection stays open while it is in use")
+
+	replacement, err := pool.acquire("replacement", "ns", dial)
+	require.NoError(t, err)
+	assert.True(t, replacement != a, "discarded connections are not handed out")
+
+	require.NoError(t, pool.release("shared", shared))
+	assert.True(t, closed[a], "a discarded connection is closed by its last release")
+	assert.False(t, closed[replacement])
+}
+
+func TestConnectionPool_DialError(t *testing.T) {
+	pool, _ := newTestConnectionPool(t, 1)
+	_, err := pool.acquire("a", "ns", func() (*amqp.Client, error) {
+		return nil, errors.New("dial failed")
+	})
+	assert.Error(t, err)
+
+	_, err = NewConnectionPool(0)
+	assert.Error(t, err)
+}
+
+func TestConnectionPool_DialsOutsideTheLock(t *testing.T) {
+	pool, _ := newTestConnectionPool(t, 1)
+	dialing := make(chan struct{})
+	unblock := make(chan struct{})
+	slow := new(amqp.Client)
+	go func() {
+		_, _ = pool.acquire("a", "slow", func() (*amqp.Client, error) {
+			close(dialing)
+			<-unblock
+			return slow, nil
+		})
+	}()
+	<-dialing
+
+	other, err := pool.acquire("b", "ns", func() (*amqp.Client, error) { return new(amqp.Client), nil })
+	require.NoError(t, err, "a slow dial should not hold up acquires for other endpoints")
+	require.NoError(t, pool.release("b", other))
+
+	shared := make(chan *amqp.Client)
+	go func() {
+		c, _ := pool.acquire("c", "slow", func() (*amqp.Client, error) { return new(amqp.Client), nil })
+		shared <- c
+	}()
+	select {
+	case <-shared:
+		t.Fatal("acquires handed a connection being dialed should wait for it")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(unblock)
+	assert.True(t, <-shared == slow)
+}
+
+func TestConnectionPool_DialErrorReachesWaiters(t *testing.T) {
+	pool, _ := newTestConnectionPool(t, 1)
+	dialing := make(chan struct{})
+	unblock := make(chan struct{})
+	failed := make(chan error)
+	go func() {
+		_, err := pool.acquire("a", "ns", func() (*amqp.Client, error) {
+			close(dialing)
+			<-unblock
+			return nil, errors.New("dial failed")
+		})
+		failed <- err
+	}()
+	<-dialing
+
+	waiter := make(chan error)
+	go func() {
+		_, err := pool.acquire("b", "ns", func() (*amqp.Client, error) { return nil, errors.New("waiter dialed") })
+		waiter <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(unblock)
+	assert.Error(t, <-failed)
+	assert.Error(t, <-waiter)
+
+	c, err := pool.acquire("c", "ns", func() (*amqp.Client, error) { return new(amqp.Client), nil })
+	require.NoError(t, err, "a failed dial should not keep its place in the pool")
+	assert.NotNil(t, c)
+}
+
+func TestConnectionPool_ReleasesOncePerOwner(t *testing.T) {
+	pool, closed := newTestConnectionPool(t, 1)
+	dial := func() (*amqp.Client, error) { return new(amqp.Client), nil }
+	conn, err := pool.acquire("recovering", "ns", dial)
+	require.NoError(t, err)
+	_, err = pool.acquire("receiving", "ns", dial)
+	require.NoError(t, err)
+
+	require.NoError(t, pool.discard("recovering", conn))
+	require.NoError(t, pool.discard("recovering", conn))
+	require.NoError(t, pool.release("recovering", conn))
+	assert.False(t, closed[conn], "an owner should release its share of a connection once, however often it discards it")
+
+	require.NoError(t, pool.release("receiving", conn))
+	assert.True(t, closed[conn])
+}
+
+func TestConnectionPool_Fail(t *testing.T) {
+	pool, _ := newTestConnectionPool(t, 1)
+	closes := 0
+	pool.closeClient = func(*amqp.Client) error {
+		closes++
+		return nil
+	}
+	dial := func() (*amqp.Client, error) { return new(amqp.Client), nil }
+	dead, err := pool.acquire("a", "ns", dial)
+	require.NoError(t, err)
+	_, err = pool.acquire("b", "ns", dial)
+	require.NoError(t, err)
+
+	require.NoError(t, pool.fail(dead))
+	assert.Equal(t, 1, closes, "a dead connection should be closed for all of its users at once")
+
+	require.NoError(t, pool.discard("a", dead))
+	require.NoError(t, pool.release("b", dead))
+	require.NoError(t, pool.fail(dead))
+	assert.Equal(t, 1, closes, "a failed connection should not be closed again")
+
+	next, err := pool.acquire("a", "ns", dial)
+	require.NoError(t, err)
+	assert.True(t, next != dead, "failed connections are not handed out")
+}
+
+func TestConnectionPool_ReceiverRecoversWhileAnotherReceives(t *testing.T) {
+	ctx := context.Background()
+	pool, closed := newTestConnectionPool(t, 1)
+	ns := &namespace{transport: new(memoryTransport), pool: pool}
+	hub := &Hub{name: "hub", namespace: ns, offsetPersister: persist.NewMemoryPersister()}
+	shared := new(amqp.Client)
+	newPartitionReceiver := func(partitionID string) *receiver {
+		r := &receiver{hub: hub, consumerGroup: DefaultConsumerGroup, partitionID: partitionID}
+		conn, err := pool.acquire(r, ns.poolKey(), func() (*amqp.Client, error) { return shared, nil })
+		require.NoError(t, err)
+		s, err := ns.amqpTransport().newSession(conn)
+		require.NoError(t, err)
+		link, err := s.NewReceiver()
+		require.NoError(t, err)
+		sess, err := newSession(s)
+		require.NoError(t, err)
+		r.connection, r.session, r.receiver = conn, sess, link
+		return r
+	}
+	recovering, receiving := newPartitionReceiver("0"), newPartitionReceiver("1")
+
+	// the namespace has no host, so the replacement connection can't be dialed and the receiver recovers again
+	assert.Error(t, recovering.Recover(ctx))
+	assert.Error(t, recovering.Recover(ctx))
+	assert.False(t, closed[shared], "recovering a partition should not close the connection other partitions receive on")
+
+	receiving.receiver.(*memoryReceiver).messages <- &amqp.Message{
+		Data:        [][]byte{[]byte("still receiving")},
+		Annotations: amqp.Annotations{sequenceNumberName: int64(1)},
+	}
+	msg, err := receiving.listenForMessage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "still receiving", string(msg.GetData()))
+
+	require.NoError(t, receiving.Close(ctx))
+	assert.True(t, closed[shared], "the discarded connection should be closed by the last partition using it")
+}
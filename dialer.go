@@ -0,0 +1,64 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+type (
+	// DialFunc opens a network connection to address. It has the signature of net.Dialer.DialContext.
+	DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// dialFuncDialer adapts a DialFunc to the dialer interface used for SOCKS5 proxies
+	dialFuncDialer DialFunc
+)
+
+// HubWithAMQPDialer configures the function used to open the AMQP connections of the Hub, whether direct, over
+// WebSockets or through a proxy. It allows connections to be routed through a tunnel, a unix socket or a test harness.
+// The Hub secures the returned connection with TLS itself, so the dialer should return a plain connection. HTTP clients,
+// such as those of Azure Storage, Azure AD tokens and Azure Resource Manager, keep their own transports.
+func HubWithAMQPDialer(dial DialFunc) HubOption {
+	return func(h *Hub) error {
+		if dial == nil {
+			return errors.New("dialer must not be nil")
+		}
+		h.namespace.dialer = dial
+		return nil
+	}
+}
+
+func (d dialFuncDialer) Dial(network, address string) (net.Conn, error) {
+	return d(context.Background(), network, address)
+}
+
+func (d dialFuncDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d(ctx, network, address)
+}
+
+func defaultDial(ctx context.Context, network, address string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, network, address)
+}
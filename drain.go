@@ -0,0 +1,190 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+
+	"github.com/Azure/go-amqp"
+	"github.com/devigned/tab"
+)
+
+// errDrainNotEnabled is returned when pausing a receiver which was not opened with ReceiveWithDrain
+var errDrainNotEnabled = errors.New("drain requires a receiver opened with ReceiveWithDrain")
+
+// ReceiveWithDrain configures the receiver to manage link credit itself so it can drain the link. Credit for the
+// prefetch count is granted up front and replenished as the handler completes events. Pausing or closing the listener
+// drains the link: the service uses or forfeits all outstanding credit, so no further events arrive. On Close, the
+// drained link is closed, and the events it already transferred are handed to the handler and completed before Close
+// returns.
+func ReceiveWithDrain() ReceiveOption {
+	return func(receiver *receiver) error {
+		receiver.manualCredit = true
+		return nil
+	}
+}
+
+// Pause stops granting credit and drains the link. When it returns without error, the service will transfer no
+// further events until Resume is called. Events already transferred are still handed to the handler; InFlight reports
+// which.
+func (lc *ListenerHandle) Pause(ctx context.Context) error {
+	if lc.r == nil {
+		return errDrainNotEnabled
+	}
+	return lc.r.pause(ctx)
+}
+
+// Resume grants credit for the prefetch count again after Pause. Adaptive receivers start over from their initial
+// prefetch count.
+func (lc *ListenerHandle) Resume() error {
+	if lc.r == nil {
+		return errDrainNotEnabled
+	}
+	return lc.r.resume()
+}
+
+// InFlight returns the sequence numbers of events which were received from the link but not yet completed by the
+// handler, in ascending order. Events are only tracked for receivers opened with ReceiveWithDrain.
+func (lc *ListenerHandle) InFlight() []int64 {
+	if lc.r == nil {
+		return nil
+	}
+	return lc.r.inFlightSequenceNumbers()
+}
+
+func (r *receiver) pause(ctx context.Context) error {
+	span, ctx := r.startConsumerSpanFromContext(ctx, "eh.receiver.pause")
+	defer span.End()
+
+	if !r.manualCredit {
+		return errDrainNotEnabled
+	}
+
+	atomic.StoreInt32(&r.paused, 1)
+	if err := r.currentLink().DrainCredit(ctx); err != nil {
+		tab.For(ctx).Error(err)
+		return err
+	}
+	return nil
+}
+
+func (r *receiver) resume() error {
+	if !r.manualCredit {
+		return errDrainNotEnabled
+	}
+
+	if !atomic.CompareAndSwapInt32(&r.paused, 1, 0) {
+		return nil
+	}
+	return r.currentLink().IssueCredit(r.initialCredit())
+}
+
+// awaitDrained waits, once Close drained and closed the link, until the listener has received every event the link
+// buffered, which go-amqp hands out before reporting the link closed, and the handler has completed them
+func (r *receiver) awaitDrained(ctx context.Context) error {
+	select {
+	case <-r.listening:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-r.inFlightDone():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// trackInFlight records that msg was received from the link, for receivers managing their credit
+func (r *receiver) trackInFlight(msg *amqp.Message) {
+	if !r.manualCredit {
+		return
+	}
+
+	link := r.currentLink()
+	r.inFlightMu.Lock()
+	defer r.inFlightMu.Unlock()
+
+	if r.inFlight == nil {
+		r.inFlight = make(map[*amqp.Message]amqpReceiver)
+	}
+	if len(r.inFlight) == 0 {
+		r.inFlightIdle = make(chan struct{})
+	}
+	r.inFlight[msg] = link
+}
+
+// completeInFlight records that the handler is done with msg and, unless paused, grants the credit it used back. Events
+// received on a link which has since been recovered don't grant credit, as the new link was granted its own.
+func (r *receiver) completeInFlight(ctx context.Context, msg *amqp.Message) {
+	if !r.manualCredit {
+		return
+	}
+
+	r.inFlightMu.Lock()
+	link, ok := r.inFlight[msg]
+	delete(r.inFlight, msg)
+	if ok && len(r.inFlight) == 0 {
+		close(r.inFlightIdle)
+	}
+	r.inFlightMu.Unlock()
+
+	if ok && link == r.currentLink() && atomic.LoadInt32(&r.paused) == 0 {
+		if credit := r.completionCredit(); credit > 0 {
+			if err := link.IssueCredit(credit); err != nil {
+				tab.For(ctx).Error(err)
+			}
+		}
+	}
+}
+
+// inFlightDone returns a channel which is closed once no event is in flight
+func (r *receiver) inFlightDone() <-chan struct{} {
+	r.inFlightMu.Lock()
+	defer r.inFlightMu.Unlock()
+
+	if len(r.inFlight) == 0 {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return r.inFlightIdle
+}
+
+func (r *receiver) inFlightSequenceNumbers() []int64 {
+	r.inFlightMu.Lock()
+	defer r.inFlightMu.Unlock()
+
+	seqs := make([]int64, 0, len(r.inFlight))
+	for msg := range r.inFlight {
+		if seq, ok := msg.Annotations[sequenceNumberName].(int64); ok {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs
+}
@@ -0,0 +1,126 @@
+package eventhub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+func TestReceiver_PauseDrainsAndTracksInFlight(t *testing.T) {
+	ns := &namespace{transport: new(memoryTransport)}
+	s, err := ns.amqpTransport().newSession(nil)
+	require.NoError(t, err)
+	link, err := s.NewReceiver()
+	require.NoError(t, err)
+	sess, err := newSession(s)
+	require.NoError(t, err)
+
+	memory := link.(*memoryReceiver)
+	r := &receiver{
+		hub:           &Hub{name: "hub", namespace: ns, offsetPersister: persist.NewMemoryPersister()},
+		session:       sess,
+		receiver:      link,
+		consumerGroup: DefaultConsumerGroup,
+		partitionID:   "0",
+		prefetchCount: 10,
+	}
+	require.NoError(t, ReceiveWithDrain()(r))
+
+	release := make(chan struct{})
+	handle := r.Listen(func(ctx context.Context, event *Event) error {
+		<-release
+		return nil
+	})
+
+	memory.messages <- &amqp.Message{
+		Data:        [][]byte{[]byte("in flight")},
+		Annotations: amqp.Annotations{sequenceNumberName: int64(7)},
+	}
+	require.Eventually(t, func() bool { return len(handle.InFlight()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []int64{7}, handle.InFlight())
+
+	require.NoError(t, handle.Pause(context.Background()))
+	close(release)
+	require.Eventually(t, func() bool { return len(handle.InFlight()) == 0 }, time.Second, time.Millisecond)
+	credit, drained := memory.state()
+	assert.Equal(t, 1, drained)
+	assert.Zero(t, credit, "no credit is granted while paused")
+
+	require.NoError(t, handle.Resume())
+	credit, _ = memory.state()
+	assert.EqualValues(t, 10, credit)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, handle.Close(ctx))
+	_, drained = memory.state()
+	assert.Equal(t, 2, drained, "closing drains the link")
+	assert.True(t, memory.closed)
+}
+
+func TestReceiver_PauseRequiresDrain(t *testing.T) {
+	r := &receiver{hub: &Hub{namespace: new(namespace)}}
+	assert.Equal(t, errDrainNotEnabled, r.pause(context.Background()))
+	assert.Equal(t, errDrainNotEnabled, r.resume())
+}
+
+func TestReceiver_CloseHandsBufferedEventsToHandler(t *testing.T) {
+	ns := &namespace{transport: new(memoryTransport)}
+	s, err := ns.amqpTransport().newSession(nil)
+	require.NoError(t, err)
+	link, err := s.NewReceiver()
+	require.NoError(t, err)
+	sess, err := newSession(s)
+	require.NoError(t, err)
+
+	memory := link.(*memoryReceiver)
+	r := &receiver{
+		hub:           &Hub{name: "hub", namespace: ns, offsetPersister: persist.NewMemoryPersister()},
+		session:       sess,
+		receiver:      link,
+		consumerGroup: DefaultConsumerGroup,
+		partitionID:   "0",
+		prefetchCount: 10,
+	}
+	require.NoError(t, ReceiveWithDrain()(r))
+
+	release := make(chan struct{})
+	var handled []int64
+	handle := r.Listen(func(ctx context.Context, event *Event) error {
+		<-release
+		handled = append(handled, *event.SystemProperties.SequenceNumber)
+		return nil
+	})
+	for seq := int64(1); seq <= 3; seq++ {
+		memory.messages <- &amqp.Message{
+			Data:        [][]byte{[]byte("event")},
+			Annotations: amqp.Annotations{sequenceNumberName: seq},
+		}
+	}
+
+	closed := make(chan error)
+	go func() { closed <- handle.Close(context.Background()) }()
+	close(release)
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close should return once the buffered events are handled")
+	}
+	assert.Equal(t, []int64{1, 2, 3}, handled, "events buffered before the link closed should be handed to the handler before Close returns")
+	assert.Empty(t, handle.InFlight())
+}
+
+func TestReceiver_TracksInFlightOnlyWithDrain(t *testing.T) {
+	r := &receiver{hub: new(Hub)}
+	msg := &amqp.Message{Annotations: amqp.Annotations{sequenceNumberName: int64(1)}}
+	r.trackInFlight(msg)
+	assert.Empty(t, r.inFlightSequenceNumbers())
+	r.completeInFlight(context.Background(), msg)
+}
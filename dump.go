@@ -0,0 +1,246 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/go-amqp"
+)
+
+type (
+	// hubDump is the diagnostic snapshot of a Hub written by Dump
+	hubDump struct {
+		Time        time.Time        `json:"time"`
+		Version     string           `json:"version"`
+		Namespace   string           `json:"namespace"`
+		Host        string           `json:"host"`
+		Hub         string           `json:"hub"`
+		Stats       HubStats         `json:"stats"`
+		Connections []connectionDump `json:"connections"`
+		Sender      *senderDump      `json:"sender,omitempty"`
+		Receivers   []receiverDump   `json:"receivers"`
+		Management  managementDump   `json:"management"`
+		Pool        []pooledConnDump `json:"pool,omitempty"`
+	}
+
+	// connectionDump lists the links of the Hub sharing an AMQP connection
+	connectionDump struct {
+		ID    string   `json:"id"`
+		Links []string `json:"links"`
+	}
+
+	senderDump struct {
+		Address    string `json:"address"`
+		Connection string `json:"connection,omitempty"`
+		Link       string `json:"link,omitempty"`
+		Recovering bool   `json:"recovering"`
+	}
+
+	receiverDump struct {
+		Address       string    `json:"address"`
+		ConsumerGroup string    `json:"consumerGroup"`
+		PartitionID   string    `json:"partitionId"`
+		Connection    string    `json:"connection,omitempty"`
+		Link          string    `json:"link,omitempty"`
+		Epoch         *int64    `json:"epoch,omitempty"`
+		Prefetch      uint32    `json:"prefetch"`
+		Paused        bool      `json:"paused"`
+		LastActivity  time.Time `json:"lastActivity,omitempty"`
+		Offset        string    `json:"offset,omitempty"`
+		Sequence      int64     `json:"sequenceNumber"`
+		InFlight      []int64   `json:"inFlight"`
+		LastError     string    `json:"lastError,omitempty"`
+	}
+
+	managementDump struct {
+		Connection  string `json:"connection,omitempty"`
+		CircuitOpen bool   `json:"circuitOpen"`
+		Failures    int    `json:"consecutiveFailures"`
+	}
+
+	pooledConnDump struct {
+		ID        string `json:"id"`
+		Endpoint  string `json:"endpoint"`
+		Refs      int    `json:"refs"`
+		Discarded bool   `json:"discarded"`
+	}
+)
+
+// Dump returns a JSON snapshot of the Hub's state for support tickets and postmortems: its connections and the links
+// sharing them, the sender and each receiver with its position in the partition and events being handled, the state
+// of the management circuit breaker and pooled connections, and the counters of Stats. Connections are identified by
+// opaque IDs which are only meaningful within one dump.
+func (h *Hub) Dump(ctx context.Context) ([]byte, error) {
+	span, _ := h.startSpanFromContext(ctx, "eh.Hub.Dump")
+	defer span.End()
+
+	d := hubDump{
+		Time:    time.Now().UTC(),
+		Version: Version,
+		Hub:     h.name,
+		Stats:   h.Stats(),
+	}
+	if h.namespace != nil {
+		d.Namespace = h.namespace.name
+		d.Host = strings.TrimPrefix(h.namespace.host, "amqps://")
+	}
+
+	conns := make(map[string][]string)
+	link := func(client *amqp.Client, address string) string {
+		if client == nil {
+			return ""
+		}
+		id := connectionID(client)
+		conns[id] = append(conns[id], address)
+		return id
+	}
+
+	if s := h.dumpSender(); s != nil {
+		d.Sender = s.dump
+		d.Sender.Connection = link(s.conn, d.Sender.Address)
+	}
+
+	h.receiverMu.Lock()
+	receivers := make([]*receiver, 0, len(h.receivers))
+	for _, r := range h.receivers {
+		receivers = append(receivers, r)
+	}
+	h.receiverMu.Unlock()
+
+	d.Receivers = make([]receiverDump, 0, len(receivers))
+	for _, r := range receivers {
+		rd := r.dump()
+		rd.Connection = link(r.currentConnection(), rd.Address)
+		d.Receivers = append(d.Receivers, rd)
+	}
+	sort.Slice(d.Receivers, func(i, j int) bool { return d.Receivers[i].Address < d.Receivers[j].Address })
+
+	h.mgmtMu.Lock()
+	d.Management.Connection = link(h.mgmtConn, "$management")
+	h.mgmtMu.Unlock()
+	if b := h.mgmtBreaker; b != nil {
+		b.mu.Lock()
+		d.Management.CircuitOpen = b.open
+		d.Management.Failures = b.failures
+		b.mu.Unlock()
+	}
+
+	if h.namespace != nil && h.namespace.pool != nil {
+		d.Pool = h.namespace.pool.dump()
+	}
+
+	d.Connections = make([]connectionDump, 0, len(conns))
+	for id, links := range conns {
+		sort.Strings(links)
+		d.Connections = append(d.Connections, connectionDump{ID: id, Links: links})
+	}
+	sort.Slice(d.Connections, func(i, j int) bool { return d.Connections[i].ID < d.Connections[j].ID })
+
+	return json.MarshalIndent(d, "", "  ")
+}
+
+type dumpedSender struct {
+	dump *senderDump
+	conn *amqp.Client
+}
+
+func (h *Hub) dumpSender() *dumpedSender {
+	h.senderMu.Lock()
+	s := h.sender
+	h.senderMu.Unlock()
+	if s == nil {
+		return nil
+	}
+
+	sd := &senderDump{Address: s.getAddress()}
+	if s.cond != nil {
+		s.cond.L.Lock()
+		defer s.cond.L.Unlock()
+	}
+	sd.Recovering = s.recovering
+	if link, ok := s.sender.Load().(amqpSender); ok && link != nil {
+		sd.Link = link.LinkName()
+	}
+	return &dumpedSender{dump: sd, conn: s.connection}
+}
+
+func (r *receiver) dump() receiverDump {
+	rd := receiverDump{
+		Address:       r.getAddress(),
+		ConsumerGroup: r.consumerGroup,
+		PartitionID:   r.partitionID,
+		Epoch:         r.epoch,
+		Prefetch:      atomic.LoadUint32(&r.prefetchCount),
+		Paused:        r.manualCredit && atomic.LoadInt32(&r.paused) == 1,
+		InFlight:      r.inFlightSequenceNumbers(),
+	}
+	if last := r.lastActivityTime(); last.UnixNano() > 0 {
+		rd.LastActivity = last.UTC()
+	}
+	if r.lastError != nil {
+		rd.LastError = r.lastError.Error()
+	}
+
+	r.connMu.RLock()
+	if link, ok := r.currentLink().(interface{ LinkName() string }); ok && link != nil {
+		rd.Link = link.LinkName()
+	}
+	r.connMu.RUnlock()
+
+	if r.hub != nil && r.hub.offsetPersister != nil {
+		if checkpoint, err := r.getLastReceivedCheckpoint(); err == nil {
+			rd.Offset = checkpoint.Offset
+			rd.Sequence = checkpoint.SequenceNumber
+		}
+	}
+	return rd
+}
+
+func (p *ConnectionPool) dump() []pooledConnDump {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conns := make([]pooledConnDump, 0, len(p.owners))
+	for client, pc := range p.owners {
+		conns = append(conns, pooledConnDump{
+			ID:        connectionID(client),
+			Endpoint:  pc.endpoint,
+			Refs:      len(pc.users),
+			Discarded: pc.discarded,
+		})
+	}
+	sort.Slice(conns, func(i, j int) bool { return conns[i].ID < conns[j].ID })
+	return conns
+}
+
+// connectionID identifies a connection within a dump
+func connectionID(client *amqp.Client) string {
+	return fmt.Sprintf("conn-%p", client)
+}
@@ -0,0 +1,55 @@
+package eventhub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+func TestHub_Dump(t *testing.T) {
+	h := &Hub{
+		name:            "hub",
+		namespace:       &namespace{name: "ns", host: "amqps://ns.servicebus.windows.net"},
+		offsetPersister: persist.NewMemoryPersister(),
+		mgmtBreaker:     newManagementCircuitBreaker(3, time.Minute),
+	}
+	partitionID := "0"
+	s := &sender{hub: h, partitionID: &partitionID, cond: sync.NewCond(new(sync.Mutex))}
+	s.sender.Store(amqpSender(new(testAmqpSender)))
+	h.sender = s
+
+	epoch := int64(7)
+	r := &receiver{hub: h, consumerGroup: DefaultConsumerGroup, partitionID: "1", prefetchCount: 300, epoch: &epoch, lastError: errors.New("detached")}
+	h.receivers = map[string]*receiver{r.getIdentifier(): r}
+	require.NoError(t, r.storeLastReceivedCheckpoint(persist.NewCheckpoint("4096", 42, time.Now())))
+	h.mgmtBreaker.record(ErrServerBusy{})
+
+	raw, err := h.Dump(context.Background())
+	require.NoError(t, err)
+
+	var d hubDump
+	require.NoError(t, json.Unmarshal(raw, &d))
+	assert.Equal(t, "ns", d.Namespace)
+	assert.Equal(t, "ns.servicebus.windows.net", d.Host)
+	assert.Equal(t, 1, d.Stats.ActiveSenders)
+	require.NotNil(t, d.Sender)
+	assert.Equal(t, "hub/Partitions/0", d.Sender.Address)
+	assert.Equal(t, "sender-id", d.Sender.Link)
+
+	require.Len(t, d.Receivers, 1)
+	assert.Equal(t, "hub/ConsumerGroups/$Default/Partitions/1", d.Receivers[0].Address)
+	assert.Equal(t, "4096", d.Receivers[0].Offset)
+	assert.Equal(t, int64(42), d.Receivers[0].Sequence)
+	assert.Equal(t, &epoch, d.Receivers[0].Epoch)
+	assert.Equal(t, "detached", d.Receivers[0].LastError)
+	assert.Empty(t, d.Connections, "nothing is connected")
+	assert.Equal(t, 1, d.Management.Failures)
+}
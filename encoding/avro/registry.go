@@ -0,0 +1,238 @@
+package avro
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/devigned/tab"
+)
+
+const (
+	// Resource is the Azure AD resource of Schema Registry, for which the authorizer of a Client must acquire tokens
+	Resource = "https://eventhubs.azure.net"
+
+	apiVersion        = "2021-10"
+	schemaContentType = "application/json; serialization=Avro"
+	schemaIDHeader    = "Schema-Id"
+)
+
+type (
+	// Registry looks up and registers Avro schemas. Client implements Registry for Azure Schema Registry.
+	Registry interface {
+		// GetSchema returns the schema with the given ID
+		GetSchema(ctx context.Context, id string) (string, error)
+		// GetSchemaID returns the ID of schema, registered with name in group
+		GetSchemaID(ctx context.Context, group, name, schema string) (string, error)
+		// RegisterSchema registers schema with name in group, returning its ID
+		RegisterSchema(ctx context.Context, group, name, schema string) (string, error)
+	}
+
+	// Client is a client of the Azure Schema Registry REST API of an Event Hubs namespace
+	Client struct {
+		endpoint   string
+		authorizer autorest.Authorizer
+		httpClient *http.Client
+	}
+
+	// ClientOption provides structure for configuring a new Client
+	ClientOption func(c *Client) error
+
+	// RegistryError is returned when Schema Registry rejects a request
+	RegistryError struct {
+		StatusCode int
+		Code       string
+		Message    string
+	}
+)
+
+// NewClient creates a new Client for the Schema Registry of a namespace, given by its fully qualified name, such as
+// mynamespace.servicebus.windows.net. The authorizer signs requests with tokens for Resource.
+func NewClient(namespace string, authorizer autorest.Authorizer, opts ...ClientOption) (*Client, error) {
+	if namespace == "" {
+		return nil, errors.New("avro: a namespace is required")
+	}
+	if authorizer == nil {
+		return nil, errors.New("avro: an authorizer is required")
+	}
+
+	endpoint := namespace
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	c := &Client{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		authorizer: authorizer,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// ClientWithHTTPClient configures the Client to send requests with httpClient
+func ClientWithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return errors.New("avro: http client must not be nil")
+		}
+		c.httpClient = httpClient
+		return nil
+	}
+}
+
+// GetSchema returns the schema with the given ID
+func (c *Client) GetSchema(ctx context.Context, id string) (string, error) {
+	ctx, span := tab.StartSpan(ctx, "eh.avro.Client.GetSchema")
+	defer span.End()
+
+	res, err := c.do(ctx, http.MethodGet, "/$schemaGroups/$schemas/"+url.PathEscape(id), "")
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return "", err
+	}
+	defer closeBody(res)
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return "", err
+	}
+	return string(body), nil
+}
+
+// GetSchemaID returns the ID of schema, registered with name in group
+func (c *Client) GetSchemaID(ctx context.Context, group, name, schema string) (string, error) {
+	ctx, span := tab.StartSpan(ctx, "eh.avro.Client.GetSchemaID")
+	defer span.End()
+
+	return c.schemaID(ctx, http.MethodPost, schemaPath(group, name)+":get-id", schema)
+}
+
+// RegisterSchema registers schema with name in group, returning its ID. Registering a schema which is already
+// registered returns the existing ID.
+func (c *Client) RegisterSchema(ctx context.Context, group, name, schema string) (string, error) {
+	ctx, span := tab.StartSpan(ctx, "eh.avro.Client.RegisterSchema")
+	defer span.End()
+
+	return c.schemaID(ctx, http.MethodPut, schemaPath(group, name), schema)
+}
+
+func (c *Client) schemaID(ctx context.Context, method, path, schema string) (string, error) {
+	res, err := c.do(ctx, method, path, schema)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return "", err
+	}
+	defer closeBody(res)
+
+	id := res.Header.Get(schemaIDHeader)
+	if id == "" {
+		err := fmt.Errorf("avro: the response of Schema Registry has no %s header", schemaIDHeader)
+		tab.For(ctx).Error(err)
+		return "", err
+	}
+	return id, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path, schema string) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.endpoint+path+"?api-version="+apiVersion, strings.NewReader(schema))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if schema != "" {
+		req.Header.Set("Content-Type", schemaContentType)
+	}
+
+	req, err = autorest.Prepare(req, c.authorizer.WithAuthorization())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer closeBody(res)
+		return nil, newRegistryError(res)
+	}
+	return res, nil
+}
+
+func newRegistryError(res *http.Response) *RegistryError {
+	regErr := &RegistryError{StatusCode: res.StatusCode}
+
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if data, err := ioutil.ReadAll(res.Body); err == nil && json.Unmarshal(data, &body) == nil {
+		regErr.Code = body.Error.Code
+		regErr.Message = body.Error.Message
+	}
+	return regErr
+}
+
+func schemaPath(group, name string) string {
+	return "/$schemaGroups/" + url.PathEscape(group) + "/schemas/" + url.PathEscape(name)
+}
+
+func closeBody(res *http.Response) {
+	_, _ = ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+}
+
+// Error returns a string describing the error
+func (e *RegistryError) Error() string {
+	msg := fmt.Sprintf("avro: schema registry request failed with status %d", e.StatusCode)
+	if e.Code != "" {
+		msg += " (" + e.Code + ")"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is a RegistryError for a schema or group which does not exist
+func IsNotFound(err error) bool {
+	var regErr *RegistryError
+	return errors.As(err, &regErr) && regErr.StatusCode == http.StatusNotFound
+}
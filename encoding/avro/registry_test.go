@@ -0,0 +1,68 @@
+package avro
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath()+"?"+r.URL.RawQuery)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/$schemaGroups/$schemas/abc":
+			_, _ = w.Write([]byte(`{"type":"string"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/$schemaGroups/telemetry/schemas/com.example.Reading:get-id":
+			assert.Equal(t, schemaContentType, r.Header.Get("Content-Type"))
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.Equal(t, "schema", string(body))
+			w.Header().Set(schemaIDHeader, "abc")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":"ItemNotFound","message":"schema not found"}}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, autorest.NullAuthorizer{}, ClientWithHTTPClient(server.Client()))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	schema, err := client.GetSchema(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, `{"type":"string"}`, schema)
+
+	id, err := client.GetSchemaID(ctx, "telemetry", "com.example.Reading", "schema")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", id)
+
+	_, err = client.RegisterSchema(ctx, "telemetry", "com.example.Reading", "schema")
+	require.Error(t, err)
+	assert.True(t, IsNotFound(err))
+	assert.EqualError(t, err, "avro: schema registry request failed with status 404 (ItemNotFound): schema not found")
+
+	assert.Equal(t, []string{
+		"GET /$schemaGroups/$schemas/abc?api-version=2021-10",
+		"POST /$schemaGroups/telemetry/schemas/com.example.Reading:get-id?api-version=2021-10",
+		"PUT /$schemaGroups/telemetry/schemas/com.example.Reading?api-version=2021-10",
+	}, requests)
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := NewClient("", autorest.NullAuthorizer{})
+	assert.Error(t, err)
+	_, err = NewClient("mynamespace.servicebus.windows.net", nil)
+	assert.Error(t, err)
+
+	client, err := NewClient("mynamespace.servicebus.windows.net", autorest.NullAuthorizer{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://mynamespace.servicebus.windows.net", client.endpoint)
+}
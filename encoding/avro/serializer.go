@@ -0,0 +1,257 @@
+// Package avro serializes the bodies of events as Avro with schemas kept in Azure Schema Registry.
+//
+// The wire format is that of the Schema Registry serializers of the .NET and Java Event Hubs clients: the body holds
+// the Avro binary encoding of a value, without a header, and the ID of the writer schema travels in the content type of
+// the event as avro/binary+<schema ID>. Events written by either serializer can be read by a Serializer and vice
+// versa. Events carrying the single-object header of earlier previews of those serializers, a 4 byte zero format
+// indicator followed by the 32 character schema ID, can be read as well.
+//
+//	registry, err := avro.NewClient("mynamespace.servicebus.windows.net", authorizer)
+//	serializer, err := avro.NewSerializer(registry, avro.SerializerWithGroup("telemetry"))
+//	event, err := serializer.Serialize(ctx, map[string]interface{}{"temperature": 21.5}, schema)
+//	err = hub.Send(ctx, event)
+//
+// Values are in the native form of github.com/linkedin/goavro: records are map[string]interface{}, arrays are
+// []interface{} and unions other than null are map[string]interface{} keyed by the name of the branch.
+package avro
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/linkedin/goavro/v2"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+const (
+	// ContentTypePrefix starts the content type of Avro events, which continues with the ID of the writer schema
+	ContentTypePrefix = "avro/binary+"
+
+	legacyHeaderSize = 4
+	legacyIDSize     = 32
+)
+
+type (
+	// Serializer serializes values to Avro events and back, looking up schemas in a Registry. Schemas and their IDs
+	// are cached after the first lookup, so only the first event of each schema costs a request to the registry.
+	Serializer struct {
+		registry     Registry
+		group        string
+		autoRegister bool
+
+		mu     sync.RWMutex
+		ids    map[string]string
+		codecs map[string]*goavro.Codec
+	}
+
+	// SerializerOption provides structure for configuring a new Serializer
+	SerializerOption func(s *Serializer) error
+)
+
+// NewSerializer creates a new Serializer which looks up schemas in registry
+func NewSerializer(registry Registry, opts ...SerializerOption) (*Serializer, error) {
+	if registry == nil {
+		return nil, errors.New("avro: a registry is required")
+	}
+
+	s := &Serializer{
+		registry: registry,
+		ids:      make(map[string]string),
+		codecs:   make(map[string]*goavro.Codec),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// SerializerWithGroup configures the schema group the schemas of serialized values belong to. A group is required to
+// serialize values, but not to deserialize them.
+func SerializerWithGroup(group string) SerializerOption {
+	return func(s *Serializer) error {
+		if group == "" {
+			return errors.New("avro: schema group must not be empty")
+		}
+		s.group = group
+		return nil
+	}
+}
+
+// SerializerWithAutoRegister configures the Serializer to register schemas which aren't in the schema group yet,
+// rather than failing to serialize values of them
+func SerializerWithAutoRegister() SerializerOption {
+	return func(s *Serializer) error {
+		s.autoRegister = true
+		return nil
+	}
+}
+
+// Serialize encodes value with schema into the body of a new event. The schema is registered in the schema group under
+// its full name, as the .NET and Java serializers do.
+func (s *Serializer) Serialize(ctx context.Context, value interface{}, schema string) (*eventhub.Event, error) {
+	id, codec, err := s.writerSchema(ctx, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := codec.BinaryFromNative(nil, value)
+	if err != nil {
+		return nil, fmt.Errorf("avro: failed to encode value with schema %s: %v", id, err)
+	}
+
+	event := eventhub.NewEvent(data)
+	event.ContentType = ContentTypePrefix + id
+	return event, nil
+}
+
+// Deserialize decodes the body of event with the schema the event was written with
+func (s *Serializer) Deserialize(ctx context.Context, event *eventhub.Event) (interface{}, error) {
+	id, data, err := SchemaID(event)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err := s.readerSchema(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	value, rest, err := codec.NativeFromBinary(data)
+	if err != nil {
+		return nil, fmt.Errorf("avro: failed to decode event with schema %s: %v", id, err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("avro: %d bytes left over after decoding event with schema %s", len(rest), id)
+	}
+	return value, nil
+}
+
+// SchemaID returns the ID of the schema event was written with and the Avro encoded value in its body
+func SchemaID(event *eventhub.Event) (string, []byte, error) {
+	if event == nil {
+		return "", nil, errors.New("avro: event must not be nil")
+	}
+
+	if strings.HasPrefix(strings.ToLower(event.ContentType), ContentTypePrefix) {
+		id := event.ContentType[len(ContentTypePrefix):]
+		if id == "" {
+			return "", nil, fmt.Errorf("avro: content type %q has no schema ID", event.ContentType)
+		}
+		return id, event.Data, nil
+	}
+
+	if event.ContentType == "" && len(event.Data) >= legacyHeaderSize+legacyIDSize && bytes.Equal(event.Data[:legacyHeaderSize], make([]byte, legacyHeaderSize)) {
+		id := string(event.Data[legacyHeaderSize : legacyHeaderSize+legacyIDSize])
+		return id, event.Data[legacyHeaderSize+legacyIDSize:], nil
+	}
+
+	return "", nil, fmt.Errorf("avro: event with content type %q is not Avro encoded", event.ContentType)
+}
+
+func (s *Serializer) writerSchema(ctx context.Context, schema string) (string, *goavro.Codec, error) {
+	s.mu.RLock()
+	id, ok := s.ids[schema]
+	codec := s.codecs[id]
+	s.mu.RUnlock()
+	if ok {
+		return id, codec, nil
+	}
+
+	if s.group == "" {
+		return "", nil, errors.New("avro: a schema group is required to serialize values")
+	}
+
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return "", nil, fmt.Errorf("avro: invalid schema: %v", err)
+	}
+
+	name, err := fullName(schema)
+	if err != nil {
+		return "", nil, err
+	}
+
+	id, err = s.registry.GetSchemaID(ctx, s.group, name, schema)
+	if IsNotFound(err) && s.autoRegister {
+		id, err = s.registry.RegisterSchema(ctx, s.group, name, schema)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	s.mu.Lock()
+	s.ids[schema] = id
+	s.codecs[id] = codec
+	s.mu.Unlock()
+	return id, codec, nil
+}
+
+func (s *Serializer) readerSchema(ctx context.Context, id string) (*goavro.Codec, error) {
+	s.mu.RLock()
+	codec, ok := s.codecs[id]
+	s.mu.RUnlock()
+	if ok {
+		return codec, nil
+	}
+
+	schema, err := s.registry.GetSchema(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err = goavro.NewCodec(schema)
+	if err != nil {
+		return nil, fmt.Errorf("avro: invalid schema %s: %v", id, err)
+	}
+
+	s.mu.Lock()
+	s.codecs[id] = codec
+	s.mu.Unlock()
+	return codec, nil
+}
+
+// fullName returns the full name of a named schema, joining its namespace and name unless the name is already full
+func fullName(schema string) (string, error) {
+	var named struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	}
+	if err := json.Unmarshal([]byte(schema), &named); err != nil || named.Name == "" {
+		return "", errors.New("avro: only named schemas, such as records, can be registered")
+	}
+
+	if named.Namespace == "" || strings.Contains(named.Name, ".") {
+		return named.Name, nil
+	}
+	return named.Namespace + "." + named.Name, nil
+}
@@ -0,0 +1,147 @@
+package avro
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+const readingSchema = `{
+	"type": "record",
+	"name": "Reading",
+	"namespace": "com.example",
+	"fields": [
+		{"name": "device", "type": "string"},
+		{"name": "temperature", "type": "double"}
+	]
+}`
+
+type fakeRegistry struct {
+	schemas map[string]string
+	lookups int
+}
+
+func (r *fakeRegistry) GetSchema(_ context.Context, id string) (string, error) {
+	r.lookups++
+	if schema, ok := r.schemas[id]; ok {
+		return schema, nil
+	}
+	return "", &RegistryError{StatusCode: http.StatusNotFound}
+}
+
+func (r *fakeRegistry) GetSchemaID(_ context.Context, group, name, schema string) (string, error) {
+	r.lookups++
+	if id, ok := r.schemas[group+"/"+name]; ok {
+		return id, nil
+	}
+	return "", &RegistryError{StatusCode: http.StatusNotFound}
+}
+
+func (r *fakeRegistry) RegisterSchema(_ context.Context, group, name, schema string) (string, error) {
+	id := "0123456789abcdef0123456789abcdef"
+	r.schemas[group+"/"+name] = id
+	r.schemas[id] = schema
+	return id, nil
+}
+
+func TestSerializer_RoundTrip(t *testing.T) {
+	registry := &fakeRegistry{schemas: map[string]string{}}
+	serializer, err := NewSerializer(registry, SerializerWithGroup("telemetry"))
+	require.NoError(t, err)
+	ctx := context.Background()
+	value := map[string]interface{}{"device": "d1", "temperature": 21.5}
+
+	_, err = serializer.Serialize(ctx, value, readingSchema)
+	assert.True(t, IsNotFound(err), "schemas aren't registered without auto registration")
+
+	serializer, err = NewSerializer(registry, SerializerWithGroup("telemetry"), SerializerWithAutoRegister())
+	require.NoError(t, err)
+	event, err := serializer.Serialize(ctx, value, readingSchema)
+	require.NoError(t, err)
+	assert.Equal(t, "avro/binary+0123456789abcdef0123456789abcdef", event.ContentType)
+	assert.Equal(t, "telemetry/com.example.Reading", findKey(registry.schemas, "0123456789abcdef0123456789abcdef"))
+
+	lookups := registry.lookups
+	_, err = serializer.Serialize(ctx, value, readingSchema)
+	require.NoError(t, err)
+	assert.Equal(t, lookups, registry.lookups, "schema IDs are cached")
+
+	reader, err := NewSerializer(registry)
+	require.NoError(t, err)
+	decoded, err := reader.Deserialize(ctx, event)
+	require.NoError(t, err)
+	assert.Equal(t, value, decoded)
+
+	lookups = registry.lookups
+	_, err = reader.Deserialize(ctx, event)
+	require.NoError(t, err)
+	assert.Equal(t, lookups, registry.lookups, "schemas are cached")
+
+	_, err = reader.Serialize(ctx, value, readingSchema)
+	assert.EqualError(t, err, "avro: a schema group is required to serialize values")
+}
+
+func TestSchemaID(t *testing.T) {
+	id, data, err := SchemaID(&eventhub.Event{Data: []byte{1, 2}, ContentType: "Avro/Binary+abc"})
+	require.NoError(t, err)
+	assert.Equal(t, "abc", id)
+	assert.Equal(t, []byte{1, 2}, data)
+
+	legacy := append([]byte{0, 0, 0, 0}, []byte("0123456789abcdef0123456789abcdef")...)
+	id, data, err = SchemaID(eventhub.NewEvent(append(legacy, 3)))
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", id)
+	assert.Equal(t, []byte{3}, data)
+
+	_, _, err = SchemaID(&eventhub.Event{Data: []byte("{}"), ContentType: "application/json"})
+	assert.Error(t, err)
+	_, _, err = SchemaID(&eventhub.Event{ContentType: ContentTypePrefix})
+	assert.Error(t, err)
+}
+
+func TestSerializer_Errors(t *testing.T) {
+	_, err := NewSerializer(nil)
+	assert.Error(t, err)
+
+	registry := &fakeRegistry{schemas: map[string]string{"bad": "not a schema"}}
+	serializer, err := NewSerializer(registry, SerializerWithGroup("telemetry"), SerializerWithAutoRegister())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = serializer.Serialize(ctx, "value", `{"type":"string"}`)
+	assert.EqualError(t, err, "avro: only named schemas, such as records, can be registered")
+
+	_, err = serializer.Serialize(ctx, map[string]interface{}{"device": 1}, readingSchema)
+	assert.Error(t, err)
+
+	_, err = serializer.Deserialize(ctx, &eventhub.Event{ContentType: ContentTypePrefix + "bad"})
+	assert.Error(t, err)
+	_, err = serializer.Deserialize(ctx, &eventhub.Event{ContentType: ContentTypePrefix + "missing"})
+	assert.True(t, IsNotFound(err))
+	assert.False(t, IsNotFound(errors.New("other")))
+}
+
+func TestFullName(t *testing.T) {
+	name, err := fullName(readingSchema)
+	require.NoError(t, err)
+	assert.Equal(t, "com.example.Reading", name)
+
+	name, err = fullName(`{"type":"record","name":"other.Reading","namespace":"com.example","fields":[]}`)
+	require.NoError(t, err)
+	assert.Equal(t, "other.Reading", name)
+}
+
+func findKey(m map[string]string, value string) string {
+	for k, v := range m {
+		if v == value {
+			return k
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+// Package msgpack provides an eventhub.Codec for MessagePack, a compact binary encoding of the values JSON can hold,
+// for smaller events without the schemas of protocol buffers or Avro:
+//
+//	hub, err := eventhub.NewHubFromConnectionString(connStr, eventhub.HubWithCodec(msgpack.Codec{}))
+//	err = hub.SendValue(ctx, reading)
+//
+// Struct fields are named by their msgpack tags. When Codec.JSONTags is set, fields without a msgpack tag are named by
+// their json tags, so types already tagged for eventhub.JSONCodec can be switched over without new tags.
+package msgpack
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+const (
+	// ContentType is the content type of events encoded by Codec
+	ContentType = "application/x-msgpack"
+)
+
+// Codec is an eventhub.Codec for values encoded as MessagePack
+type Codec struct {
+	// JSONTags names struct fields without a msgpack tag by their json tags
+	JSONTags bool
+}
+
+var _ eventhub.Codec = Codec{}
+
+// ContentType returns ContentType
+func (Codec) ContentType() string {
+	return ContentType
+}
+
+// Marshal encodes v as MessagePack
+func (c Codec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	if c.JSONTags {
+		enc.SetCustomStructTag("json")
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes the MessagePack in data into v
+func (c Codec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	if c.JSONTags {
+		dec.SetCustomStructTag("json")
+	}
+	return dec.Decode(v)
+}
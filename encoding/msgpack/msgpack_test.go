@@ -0,0 +1,51 @@
+package msgpack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+type reading struct {
+	Device      string  `json:"device" msgpack:"dev"`
+	Temperature float64 `json:"temperature" msgpack:"temp"`
+}
+
+func TestCodec(t *testing.T) {
+	event, err := eventhub.NewEventFromValue(Codec{}, reading{Device: "d1", Temperature: 21.5})
+	require.NoError(t, err)
+	assert.Equal(t, ContentType, event.ContentType)
+
+	decoded := make(map[string]interface{})
+	require.NoError(t, Codec{}.Unmarshal(event.Data, &decoded))
+	assert.Equal(t, map[string]interface{}{"dev": "d1", "temp": 21.5}, decoded)
+
+	var r reading
+	require.NoError(t, Codec{}.Unmarshal(event.Data, &r))
+	assert.Equal(t, reading{Device: "d1", Temperature: 21.5}, r)
+}
+
+func TestCodec_JSONTags(t *testing.T) {
+	codec := Codec{JSONTags: true}
+	data, err := codec.Marshal(struct {
+		Device      string  `json:"device"`
+		Temperature float64 `json:"temperature"`
+	}{Device: "d1", Temperature: 21.5})
+	require.NoError(t, err)
+
+	decoded := make(map[string]interface{})
+	require.NoError(t, codec.Unmarshal(data, &decoded))
+	assert.Equal(t, map[string]interface{}{"device": "d1", "temperature": 21.5}, decoded)
+}
+
+func TestCodec_SmallerThanJSON(t *testing.T) {
+	value := map[string]interface{}{"temperature": 21.5, "humidity": 40, "device": "d1"}
+	packed, err := Codec{}.Marshal(value)
+	require.NoError(t, err)
+	json, err := eventhub.JSONCodec{}.Marshal(value)
+	require.NoError(t, err)
+	assert.Less(t, len(packed), len(json))
+}
@@ -0,0 +1,70 @@
+// Package protobuf provides an eventhub.Codec for protocol buffer messages:
+//
+//	hub, err := eventhub.NewHubFromConnectionString(connStr, eventhub.HubWithCodec(protobuf.Codec{}))
+//	err = hub.SendValue(ctx, reading)
+//
+// Events of its content type received from the hub are decoded by Event.Decode into a message of the expected type.
+package protobuf
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+const (
+	// ContentType is the content type of events encoded by Codec
+	ContentType = "application/x-protobuf"
+)
+
+// Codec is an eventhub.Codec for values implementing proto.Message, encoded in the protocol buffers binary format
+type Codec struct{}
+
+var _ eventhub.Codec = Codec{}
+
+// ContentType returns ContentType
+func (Codec) ContentType() string {
+	return ContentType
+}
+
+// Marshal encodes v, which must be a proto.Message
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(msg)
+}
+
+// Unmarshal decodes data into v, which must be a proto.Message
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, msg)
+}
@@ -0,0 +1,30 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+func TestCodec(t *testing.T) {
+	event, err := eventhub.NewEventFromValue(Codec{}, wrapperspb.String("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, ContentType, event.ContentType)
+
+	decoded := new(wrapperspb.StringValue)
+	require.NoError(t, Codec{}.Unmarshal(event.Data, decoded))
+	assert.True(t, proto.Equal(wrapperspb.String("hello"), decoded))
+}
+
+func TestCodec_RequiresMessages(t *testing.T) {
+	_, err := Codec{}.Marshal("hello")
+	assert.EqualError(t, err, "protobuf: string is not a proto.Message")
+
+	var s string
+	assert.Error(t, Codec{}.Unmarshal([]byte{}, &s))
+}
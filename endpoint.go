@@ -0,0 +1,135 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	amqpPort = "5672"
+	httpPort = "80"
+)
+
+// HubWithCustomEndpoint connects the Hub to endpoint rather than the host of the namespace, such as the address of a
+// private endpoint, a gateway or a local emulator. The endpoint is a host with an optional port ("10.0.0.4",
+// "localhost:5672") or a URL ("amqps://gateway.contoso.com:5671"). Tokens are still requested for the namespace, and
+// the namespace's host is used to verify the server's TLS certificate and is announced to the service when connecting.
+func HubWithCustomEndpoint(endpoint string) HubOption {
+	return func(h *Hub) error {
+		host, port, err := parseEndpoint(endpoint)
+		if err != nil {
+			return err
+		}
+		h.namespace.endpointHost = host
+		h.namespace.endpointPort = port
+		return nil
+	}
+}
+
+// HubWithTLSDisabled connects the Hub without TLS, over AMQP on port 5672 or WebSockets on port 80 unless a custom
+// endpoint sets the port. It is meant for local emulators; never disable TLS when connecting to Azure.
+func HubWithTLSDisabled() HubOption {
+	return func(h *Hub) error {
+		h.namespace.tlsDisabled = true
+		return nil
+	}
+}
+
+// parseEndpoint splits an endpoint given as host, host:port or URL into its host and port; the port is empty when the
+// endpoint doesn't specify one
+func parseEndpoint(endpoint string) (string, string, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if endpoint == "" {
+		return "", "", errors.New("endpoint must not be empty")
+	}
+
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+		}
+		if u.Hostname() == "" {
+			return "", "", fmt.Errorf("invalid endpoint %q: missing host", endpoint)
+		}
+		return u.Hostname(), u.Port(), validatePort(endpoint, u.Port())
+	}
+
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		// without a port the whole endpoint is the host
+		if strings.Contains(endpoint, "/") {
+			return "", "", fmt.Errorf("invalid endpoint %q", endpoint)
+		}
+		return strings.Trim(endpoint, "[]"), "", nil
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("invalid endpoint %q: missing host", endpoint)
+	}
+	return host, port, validatePort(endpoint, port)
+}
+
+func validatePort(endpoint, port string) error {
+	if port == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid endpoint %q: port must be between 1 and 65535", endpoint)
+	}
+	return nil
+}
+
+// hasCustomEndpoint is true when connections don't go to the namespace's host over TLS on the standard ports, which
+// go-amqp and the websocket package can't dial on their own
+func (ns *namespace) hasCustomEndpoint() bool {
+	return ns.endpointHost != "" || ns.endpointPort != "" || ns.tlsDisabled || ns.geoDR.redirectedAddress() != ""
+}
+
+// endpointAddress returns the network address to dial for a connection to host, which would otherwise use port
+func (ns *namespace) endpointAddress(host, port string) string {
+	if ns.endpointHost == "" {
+		// the service redirected the alias to another host; custom endpoints stand in for any host, so win over it
+		if address := ns.geoDR.redirectedAddress(); address != "" {
+			return address
+		}
+	}
+
+	if ns.endpointHost != "" {
+		host = ns.endpointHost
+	}
+
+	switch {
+	case ns.endpointPort != "":
+		port = ns.endpointPort
+	case ns.tlsDisabled && port == amqpsPort:
+		port = amqpPort
+	case ns.tlsDisabled && port == httpsPort:
+		port = httpPort
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,64 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	cases := []struct {
+		endpoint, host, port string
+	}{
+		{"10.0.0.4", "10.0.0.4", ""},
+		{"localhost:5672", "localhost", "5672"},
+		{"amqps://gateway.contoso.com:5671", "gateway.contoso.com", "5671"},
+		{"amqp://localhost", "localhost", ""},
+		{"[::1]:5672", "::1", "5672"},
+	}
+	for _, c := range cases {
+		host, port, err := parseEndpoint(c.endpoint)
+		require.NoError(t, err, c.endpoint)
+		assert.Equal(t, c.host, host, c.endpoint)
+		assert.Equal(t, c.port, port, c.endpoint)
+	}
+
+	for _, endpoint := range []string{"", "localhost:0", "localhost:http", "amqp://:5672", "localhost/path"} {
+		_, _, err := parseEndpoint(endpoint)
+		assert.Error(t, err, endpoint)
+	}
+}
+
+func TestNamespace_NewConnectionUsesCustomEndpoint(t *testing.T) {
+	var dialed []string
+	refused := errors.New("refused")
+	ns := &namespace{host: "amqps://ns.servicebus.windows.net"}
+	h := &Hub{namespace: ns}
+	require.NoError(t, HubWithAMQPDialer(func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		return nil, refused
+	})(h))
+	require.NoError(t, HubWithCustomEndpoint("10.0.0.4")(h))
+
+	_, err := ns.newConnection()
+	assert.Equal(t, refused, err)
+
+	require.NoError(t, HubWithTLSDisabled()(h))
+	_, err = ns.newConnection()
+	assert.Equal(t, refused, err)
+
+	ns.useWebSocket = true
+	_, err = ns.newConnection()
+	assert.Equal(t, refused, err)
+
+	require.NoError(t, HubWithCustomEndpoint("localhost:8080")(h))
+	_, err = ns.newConnection()
+	assert.Equal(t, refused, err)
+
+	assert.Equal(t, []string{"10.0.0.4:5671", "10.0.0.4:5672", "10.0.0.4:80", "localhost:8080"}, dialed)
+	assert.Equal(t, "amqps://ns.servicebus.windows.net/hub", ns.getEntityAudience("hub"), "tokens are still for the namespace")
+}
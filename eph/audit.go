@@ -0,0 +1,190 @@
+package eph
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+
+	"github.com/devigned/tab"
+)
+
+// Audited actions
+const (
+	// AuditCheckpointWrite records a checkpoint written for a partition
+	AuditCheckpointWrite AuditAction = "checkpoint-write"
+	// AuditLeaseAcquire records the acquisition of an expired or unowned lease
+	AuditLeaseAcquire AuditAction = "lease-acquire"
+	// AuditLeaseSteal records the acquisition of a lease held by another host to rebalance partitions
+	AuditLeaseSteal AuditAction = "lease-steal"
+	// AuditLeaseRelease records the release of a lease held by the host
+	AuditLeaseRelease AuditAction = "lease-release"
+)
+
+type (
+	// AuditAction identifies a mutation of the checkpoint or lease store
+	AuditAction string
+
+	// AuditRecord describes a mutation of the checkpoint or lease store made by an EventProcessorHost: which host
+	// moved which partition's checkpoint or lease, when, and from what to what
+	AuditRecord struct {
+		Action AuditAction
+		// Time is when the mutation completed
+		Time time.Time
+		// Host is the name of the EventProcessorHost making the mutation
+		Host          string
+		Namespace     string
+		HubName       string
+		ConsumerGroup string
+		PartitionID   string
+		// CheckpointBefore and CheckpointAfter are set for checkpoint writes
+		CheckpointBefore *persist.Checkpoint
+		CheckpointAfter  *persist.Checkpoint
+		// LeaseBefore and LeaseAfter are set for lease mutations
+		LeaseBefore *Lease
+		LeaseAfter  *Lease
+		// Err is the error the mutation failed with, if any; the after values are those which were attempted
+		Err error
+		// RequestID is the client request ID the mutation was made with, which the store is sent when it supports one
+		RequestID string
+	}
+
+	// AuditHook is called with a record of every checkpoint write and lease acquisition, steal and release. It is
+	// called synchronously after the mutation, so it must not block.
+	AuditHook func(ctx context.Context, record AuditRecord)
+)
+
+// WithAuditHook will configure an EventProcessorHost to call hook on every checkpoint write and lease acquisition,
+// steal and release, with the values before and after the mutation. Recording the checkpoint before a write reads
+// it from the checkpointer first.
+func WithAuditHook(hook AuditHook) EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		if hook == nil {
+			return errors.New("audit hook must not be nil")
+		}
+		host.auditHook = hook
+		return nil
+	}
+}
+
+// updateCheckpoint writes checkpoint for partitionID, auditing the write
+func (h *EventProcessorHost) updateCheckpoint(ctx context.Context, checkpointer Checkpointer, partitionID string, checkpoint persist.Checkpoint) error {
+	span, ctx := startLeaseSpanFromContext(ctx, "eph.updateCheckpoint", partitionID)
+	defer span.End()
+	ctx, requestID := eventhub.StartRequest(ctx, span)
+
+	if h == nil || h.auditHook == nil {
+		return eventhub.NewRequestError(requestID, checkpointer.UpdateCheckpoint(ctx, partitionID, checkpoint))
+	}
+
+	var before *persist.Checkpoint
+	if previous, ok := checkpointer.GetCheckpoint(ctx, partitionID); ok {
+		before = &previous
+	}
+	err := eventhub.NewRequestError(requestID, checkpointer.UpdateCheckpoint(ctx, partitionID, checkpoint))
+	h.audit(ctx, AuditRecord{
+		Action:           AuditCheckpointWrite,
+		PartitionID:      partitionID,
+		CheckpointBefore: before,
+		CheckpointAfter:  &checkpoint,
+		Err:              err,
+		RequestID:        requestID,
+	})
+	return err
+}
+
+// acquireLease acquires the lease of the partition of previous, auditing the acquisition or steal if it succeeds or
+// fails with an error
+func (h *EventProcessorHost) acquireLease(ctx context.Context, action AuditAction, previous LeaseMarker) (LeaseMarker, bool, error) {
+	span, ctx := startLeaseSpanFromContext(ctx, "eph.acquireLease", previous.GetPartitionID())
+	defer span.End()
+	ctx, requestID := eventhub.StartRequest(ctx, span)
+
+	acquired, ok, err := h.leaser.AcquireLease(ctx, previous.GetPartitionID())
+	err = eventhub.NewRequestError(requestID, err)
+	if h.auditHook == nil || (!ok && err == nil) {
+		return acquired, ok, err
+	}
+
+	after := &Lease{PartitionID: previous.GetPartitionID(), Owner: h.name, Epoch: previous.GetEpoch() + 1}
+	if ok && acquired != nil {
+		after = leaseOf(acquired)
+	}
+	h.audit(ctx, AuditRecord{
+		Action:      action,
+		PartitionID: previous.GetPartitionID(),
+		LeaseBefore: leaseOf(previous),
+		LeaseAfter:  after,
+		Err:         err,
+		RequestID:   requestID,
+	})
+	return acquired, ok, err
+}
+
+// releaseLease releases lease, auditing the release if it succeeds or fails with an error
+func (h *EventProcessorHost) releaseLease(ctx context.Context, lease LeaseMarker) (bool, error) {
+	span, ctx := startLeaseSpanFromContext(ctx, "eph.releaseLease", lease.GetPartitionID())
+	defer span.End()
+	ctx, requestID := eventhub.StartRequest(ctx, span)
+
+	ok, err := h.leaser.ReleaseLease(ctx, lease.GetPartitionID())
+	err = eventhub.NewRequestError(requestID, err)
+	if h.auditHook == nil || (!ok && err == nil) {
+		return ok, err
+	}
+
+	h.audit(ctx, AuditRecord{
+		Action:      AuditLeaseRelease,
+		PartitionID: lease.GetPartitionID(),
+		LeaseBefore: leaseOf(lease),
+		LeaseAfter:  &Lease{PartitionID: lease.GetPartitionID(), Epoch: lease.GetEpoch()},
+		Err:         err,
+		RequestID:   requestID,
+	})
+	return ok, err
+}
+
+// audit fills in the host's identity and calls the audit hook
+func (h *EventProcessorHost) audit(ctx context.Context, record AuditRecord) {
+	record.Time = time.Now()
+	record.Host = h.name
+	record.Namespace = h.namespace
+	record.HubName = h.hubName
+	record.ConsumerGroup = h.consumerGroupName()
+	h.auditHook(ctx, record)
+}
+
+// startLeaseSpanFromContext starts the span of an operation on the lease or checkpoint of partitionID
+func startLeaseSpanFromContext(ctx context.Context, operationName, partitionID string) (tab.Spanner, context.Context) {
+	span, ctx := startConsumerSpanFromContext(ctx, operationName)
+	span.AddAttributes(tab.StringAttribute(partitionIDTag, partitionID))
+	return span, ctx
+}
+
+func leaseOf(marker LeaseMarker) *Lease {
+	return &Lease{PartitionID: marker.GetPartitionID(), Owner: marker.GetOwner(), Epoch: marker.GetEpoch()}
+}
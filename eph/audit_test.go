@@ -0,0 +1,108 @@
+package eph
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+func TestWithAuditHook(t *testing.T) {
+	ctx := context.Background()
+	host := &EventProcessorHost{namespace: "ns", hubName: "hub", name: "host-1", partitionIDs: []string{"0"}}
+	assert.Error(t, WithAuditHook(nil)(host))
+
+	var records []AuditRecord
+	require.NoError(t, WithAuditHook(func(ctx context.Context, record AuditRecord) {
+		records = append(records, record)
+	})(host))
+
+	leaserCheckpointer := newMemoryLeaserCheckpointer(DefaultLeaseDuration, new(sharedStore))
+	leaserCheckpointer.SetEventHostProcessor(host)
+	host.leaser = leaserCheckpointer
+	require.NoError(t, leaserCheckpointer.EnsureStore(ctx))
+	lease, err := leaserCheckpointer.EnsureLease(ctx, "0")
+	require.NoError(t, err)
+	_, err = leaserCheckpointer.EnsureCheckpoint(ctx, "0")
+	require.NoError(t, err)
+
+	acquired, ok, err := host.acquireLease(ctx, AuditLeaseAcquire, lease)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	checkpoint := persist.NewCheckpoint("1024", 42, time.Now())
+	persister := checkpointPersister{checkpointer: leaserCheckpointer, host: host}
+	require.NoError(t, persister.Write("ns", "hub", "$Default", "0", checkpoint))
+
+	ok, err = host.releaseLease(ctx, acquired)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.Len(t, records, 3)
+	for _, record := range records {
+		assert.Equal(t, "host-1", record.Host)
+		assert.Equal(t, "ns", record.Namespace)
+		assert.Equal(t, "hub", record.HubName)
+		assert.Equal(t, "$Default", record.ConsumerGroup)
+		assert.Equal(t, "0", record.PartitionID)
+		assert.NoError(t, record.Err)
+		assert.False(t, record.Time.IsZero())
+		assert.NotEmpty(t, record.RequestID)
+	}
+	assert.NotEqual(t, records[0].RequestID, records[2].RequestID, "each mutation should get a request ID of its own")
+
+	assert.Equal(t, AuditLeaseAcquire, records[0].Action)
+	assert.Equal(t, "", records[0].LeaseBefore.Owner)
+	assert.Equal(t, acquired.GetEpoch(), records[0].LeaseAfter.Epoch)
+
+	assert.Equal(t, AuditCheckpointWrite, records[1].Action)
+	require.NotNil(t, records[1].CheckpointBefore)
+	assert.Equal(t, persist.StartOfStream, records[1].CheckpointBefore.Offset)
+	assert.Equal(t, &checkpoint, records[1].CheckpointAfter)
+
+	assert.Equal(t, AuditLeaseRelease, records[2].Action)
+	assert.Equal(t, "", records[2].LeaseAfter.Owner)
+}
+
+// requestIDLeaser fails lease acquisitions, recording the request ID of each attempt
+type requestIDLeaser struct {
+	*memoryLeaserCheckpointer
+	requestIDs []string
+}
+
+func (l *requestIDLeaser) AcquireLease(ctx context.Context, partitionID string) (LeaseMarker, bool, error) {
+	id, _ := eventhub.RequestIDFromContext(ctx)
+	l.requestIDs = append(l.requestIDs, id)
+	return nil, false, errors.New("store unavailable")
+}
+
+func TestEventProcessorHost_LeaseRequestIDs(t *testing.T) {
+	ctx := context.Background()
+	leaser := &requestIDLeaser{memoryLeaserCheckpointer: newMemoryLeaserCheckpointer(DefaultLeaseDuration, new(sharedStore))}
+	host := &EventProcessorHost{name: "host-1", leaser: leaser}
+	leaser.SetEventHostProcessor(host)
+	require.NoError(t, leaser.EnsureStore(ctx))
+	lease, err := leaser.EnsureLease(ctx, "0")
+	require.NoError(t, err)
+
+	_, _, err = host.acquireLease(ctx, AuditLeaseAcquire, lease)
+	require.Error(t, err)
+	require.Len(t, leaser.requestIDs, 1)
+	require.NotEmpty(t, leaser.requestIDs[0], "the leaser should be given a generated request ID")
+	id, ok := eventhub.RequestIDFromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, leaser.requestIDs[0], id, "the error should carry the request ID the leaser was given")
+	assert.EqualError(t, errors.Unwrap(err), "store unavailable")
+
+	_, _, err = host.acquireLease(eventhub.WithRequestID(ctx, "support-case"), AuditLeaseAcquire, lease)
+	require.Error(t, err)
+	assert.Equal(t, "support-case", leaser.requestIDs[1], "a request ID carried by the context should be kept")
+	id, _ = eventhub.RequestIDFromError(err)
+	assert.Equal(t, "support-case", id)
+}
@@ -0,0 +1,302 @@
+package eph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/eventhubtest"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+const (
+	chaosPartitions = 8
+	chaosMaxHosts   = 4
+	chaosSteps      = 150
+)
+
+type (
+	// chaos drives hosts sharing a lease store through random lease expirations, renew failures and hosts joining and
+	// leaving, all drawn from a seed so a failing run can be replayed
+	chaos struct {
+		t       *testing.T
+		seed    int64
+		random  *rand.Rand
+		broker  *eventhubtest.Broker
+		sender  *eventhubtest.Hub
+		store   *sharedStore
+		hosts   []*chaosHost
+		joined  int
+		sent    int
+		mu      sync.Mutex
+		handled map[string]bool
+		// checkpoints holds the sequence number of the last checkpoint stored for each partition
+		checkpoints map[string]int64
+		regressions []string
+	}
+
+	chaosHost struct {
+		*EventProcessorHost
+		leaser *chaosLeaser
+	}
+
+	// chaosLeaser fails renewals on demand and reports checkpoints stored for a partition behind the previous one
+	chaosLeaser struct {
+		*memoryLeaserCheckpointer
+		chaos     *chaos
+		failRenew bool
+	}
+)
+
+func TestScheduler_Chaos(t *testing.T) {
+	seeds := []int64{1, 2, 3, 42, 2021}
+	if env := os.Getenv("EPH_CHAOS_SEED"); env != "" {
+		seed, err := strconv.ParseInt(env, 10, 64)
+		require.NoError(t, err)
+		seeds = []int64{seed}
+	}
+
+	for _, seed := range seeds {
+		seed := seed
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			c := newChaos(t, seed)
+			defer c.close()
+			c.run()
+		})
+	}
+}
+
+func newChaos(t *testing.T, seed int64) *chaos {
+	broker, err := eventhubtest.NewBroker(eventhubtest.BrokerWithPartitionCount(chaosPartitions))
+	require.NoError(t, err)
+	sender, err := broker.Hub("hub")
+	require.NoError(t, err)
+	return &chaos{
+		t:           t,
+		seed:        seed,
+		random:      rand.New(rand.NewSource(seed)),
+		broker:      broker,
+		sender:      sender,
+		store:       new(sharedStore),
+		handled:     make(map[string]bool),
+		checkpoints: make(map[string]int64),
+	}
+}
+
+func (c *chaos) run() {
+	ctx := context.Background()
+	c.join(ctx)
+	c.join(ctx)
+
+	for step := 0; step < chaosSteps; step++ {
+		var action string
+		switch c.random.Intn(6) {
+		case 0:
+			action = c.scan(ctx)
+		case 1:
+			action = c.renew(ctx)
+		case 2:
+			action = c.expire()
+		case 3:
+			if len(c.hosts) > 1 {
+				action = c.leave(ctx)
+			} else {
+				action = c.join(ctx)
+			}
+		case 4:
+			if len(c.hosts) < chaosMaxHosts {
+				action = c.join(ctx)
+			} else {
+				action = c.scan(ctx)
+			}
+		default:
+			action = c.send(ctx)
+		}
+		c.checkInvariants(fmt.Sprintf("step %d, %s", step, action))
+	}
+
+	c.converge(ctx)
+}
+
+func (c *chaos) scan(ctx context.Context) string {
+	host := c.hosts[c.random.Intn(len(c.hosts))]
+	host.scheduler.scan(ctx)
+	return fmt.Sprintf("%s scanned", host.name)
+}
+
+func (c *chaos) renew(ctx context.Context) string {
+	host := c.hosts[c.random.Intn(len(c.hosts))]
+	fail := c.random.Intn(3) == 0
+	host.leaser.failRenew = fail
+	renewLeases(ctx, host.scheduler)
+	host.leaser.failRenew = false
+	return fmt.Sprintf("%s renewed, failing: %t", host.name, fail)
+}
+
+func (c *chaos) expire() string {
+	partitionID := strconv.Itoa(c.random.Intn(chaosPartitions))
+	shard := c.store.shard(partitionID)
+	if l, ok := shard.leases[partitionID]; ok {
+		l.expiration = time.Now().Add(-time.Second)
+	}
+	shard.mu.Unlock()
+	return fmt.Sprintf("lease of partition %s expired", partitionID)
+}
+
+func (c *chaos) join(ctx context.Context) string {
+	client, err := c.broker.Hub("hub")
+	require.NoError(c.t, err)
+	leaser := &chaosLeaser{
+		memoryLeaserCheckpointer: newMemoryLeaserCheckpointer(DefaultLeaseDuration, c.store),
+		chaos:                    c,
+	}
+	c.joined++
+	name := fmt.Sprintf("host-%d", c.joined)
+	processor, err := NewWithClient(ctx, client, leaser, leaser, WithNoBanner(), func(h *EventProcessorHost) error {
+		h.name = name
+		return nil
+	})
+	require.NoError(c.t, err)
+	_, err = processor.RegisterHandler(ctx, func(_ context.Context, event *eventhub.Event) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.handled[string(event.Data)] = true
+		return nil
+	})
+	require.NoError(c.t, err)
+	require.NoError(c.t, processor.setup(ctx))
+	processor.scheduler.renewManually = true
+	processor.scheduler.random = rand.New(rand.NewSource(c.seed + int64(c.joined)))
+
+	c.hosts = append(c.hosts, &chaosHost{EventProcessorHost: processor, leaser: leaser})
+	return fmt.Sprintf("%s joined", name)
+}
+
+func (c *chaos) leave(ctx context.Context) string {
+	i := c.random.Intn(len(c.hosts))
+	host := c.hosts[i]
+	c.hosts = append(c.hosts[:i], c.hosts[i+1:]...)
+	_ = host.Close(ctx)
+	return fmt.Sprintf("%s left", host.name)
+}
+
+func (c *chaos) send(ctx context.Context) string {
+	count := 1 + c.random.Intn(5)
+	for i := 0; i < count; i++ {
+		require.NoError(c.t, c.sender.Send(ctx, eventhub.NewEventFromString(strconv.Itoa(c.sent))))
+		c.sent++
+	}
+	return fmt.Sprintf("%d events sent", count)
+}
+
+// checkInvariants asserts no partition is processed by more than one host once the receivers of stolen leases have
+// been stopped, and no checkpoint was stored behind an earlier one
+func (c *chaos) checkInvariants(step string) {
+	var owners map[string][]string
+	ok := assert.Eventually(c.t, func() bool {
+		owners = c.owners()
+		for _, names := range owners {
+			if len(names) > 1 {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, time.Millisecond)
+	require.True(c.t, ok, "seed %d, %s: partitions processed by more than one host: %v", c.seed, step, owners)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	require.Empty(c.t, c.regressions, "seed %d, %s: checkpoints regressed", c.seed, step)
+}
+
+// converge scans until the hosts share all partitions, and asserts every event sent has been handled
+func (c *chaos) converge(ctx context.Context) {
+	ok := assert.Eventually(c.t, func() bool {
+		for _, host := range c.hosts {
+			host.scheduler.scan(ctx)
+		}
+		owners := c.owners()
+		if len(owners) != chaosPartitions {
+			return false
+		}
+		for _, names := range owners {
+			if len(names) != 1 {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+	require.True(c.t, ok, "seed %d: partitions should all be owned once the chaos ends: %v", c.seed, c.owners())
+
+	ok = assert.Eventually(c.t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.handled) == c.sent
+	}, 10*time.Second, 10*time.Millisecond)
+	require.True(c.t, ok, "seed %d: all %d events sent should be handled", c.seed, c.sent)
+}
+
+// owners returns the names of the hosts running a receiver for each partition
+func (c *chaos) owners() map[string][]string {
+	owners := make(map[string][]string)
+	for _, host := range c.hosts {
+		for _, partitionID := range host.scheduler.getPartitionIDsBeingProcessed() {
+			owners[partitionID] = append(owners[partitionID], host.name)
+		}
+	}
+	return owners
+}
+
+func (c *chaos) close() {
+	ctx := context.Background()
+	for _, host := range c.hosts {
+		_ = host.Close(ctx)
+	}
+	_ = c.sender.Close(ctx)
+}
+
+// renewLeases renews the lease of each receiver of s once, as each receiver does periodically unless renewing manually
+func renewLeases(ctx context.Context, s *scheduler) {
+	s.receiverMu.Lock()
+	receivers := make([]*leasedReceiver, 0, len(s.receivers))
+	for _, lr := range s.receivers {
+		receivers = append(receivers, lr)
+	}
+	s.receiverMu.Unlock()
+
+	for _, lr := range receivers {
+		lr.renew(ctx)
+	}
+}
+
+func (l *chaosLeaser) RenewLease(ctx context.Context, partitionID string) (LeaseMarker, bool, error) {
+	if l.failRenew {
+		return nil, false, errors.New("renew failed by chaos")
+	}
+	return l.memoryLeaserCheckpointer.RenewLease(ctx, partitionID)
+}
+
+func (l *chaosLeaser) UpdateCheckpoint(ctx context.Context, partitionID string, checkpoint persist.Checkpoint) error {
+	l.chaos.mu.Lock()
+	defer l.chaos.mu.Unlock()
+
+	if err := l.memoryLeaserCheckpointer.UpdateCheckpoint(ctx, partitionID, checkpoint); err != nil {
+		return err
+	}
+	if last, ok := l.chaos.checkpoints[partitionID]; ok && checkpoint.SequenceNumber < last {
+		l.chaos.regressions = append(l.chaos.regressions,
+			fmt.Sprintf("partition %s: checkpoint %d stored after %d", partitionID, checkpoint.SequenceNumber, last))
+	}
+	l.chaos.checkpoints[partitionID] = checkpoint.SequenceNumber
+	return nil
+}
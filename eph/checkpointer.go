@@ -24,7 +24,6 @@ package eph
 
 import (
 	"context"
-	"io"
 
 	"github.com/Azure/azure-event-hubs-go/v3/persist"
 )
@@ -32,12 +31,13 @@ import (
 type (
 	// Checkpointer interface provides the ability to persist durable checkpoints for event processors
 	Checkpointer interface {
-		io.Closer
 		StoreProvisioner
 		EventProcessHostSetter
 		GetCheckpoint(ctx context.Context, partitionID string) (persist.Checkpoint, bool)
 		EnsureCheckpoint(ctx context.Context, partitionID string) (persist.Checkpoint, error)
 		UpdateCheckpoint(ctx context.Context, partitionID string, checkpoint persist.Checkpoint) error
 		DeleteCheckpoint(ctx context.Context, partitionID string) error
+		// Close releases the resources of the checkpointer, waiting for them no longer than ctx allows
+		Close(ctx context.Context) error
 	}
 )
@@ -0,0 +1,79 @@
+package eph
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/eventhubtest"
+)
+
+var _ Client = (*eventhubtest.Hub)(nil)
+
+func TestNewWithClient_ReceivesAndCheckpoints(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	broker, err := eventhubtest.NewBroker(eventhubtest.BrokerWithPartitionCount(4))
+	require.NoError(t, err)
+	sender, err := broker.Hub("hub")
+	require.NoError(t, err)
+	const count = 20
+	for i := 0; i < count; i++ {
+		require.NoError(t, sender.Send(ctx, eventhub.NewEventFromString(strconv.Itoa(i))))
+	}
+
+	client, err := broker.Hub("hub")
+	require.NoError(t, err)
+	leaserCheckpointer := newMemoryLeaserCheckpointer(DefaultLeaseDuration, new(sharedStore))
+	host, err := NewWithClient(ctx, client, leaserCheckpointer, leaserCheckpointer, WithNoBanner())
+	require.NoError(t, err)
+	assert.Equal(t, "hub", host.hubName)
+	assert.Len(t, host.GetPartitionIDs(), 4)
+
+	var mu sync.Mutex
+	received := make(map[string]bool)
+	all := make(chan struct{})
+	_, err = host.RegisterHandler(ctx, func(ctx context.Context, event *eventhub.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		received[string(event.Data)] = true
+		if len(received) == count {
+			close(all)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, host.StartNonBlocking(ctx))
+
+	select {
+	case <-all:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the events of the hub")
+	}
+
+	for _, partitionID := range host.GetPartitionIDs() {
+		events, err := broker.Events("hub", partitionID)
+		require.NoError(t, err)
+		last := events[len(events)-1].GetCheckpoint()
+
+		require.Eventually(t, func() bool {
+			checkpoint, ok := leaserCheckpointer.GetCheckpoint(ctx, partitionID)
+			return ok && checkpoint.Offset == last.Offset
+		}, 5*time.Second, 10*time.Millisecond, "partition %s should be checkpointed after its last event", partitionID)
+	}
+
+	require.NoError(t, host.Close(ctx))
+}
+
+func TestNewWithClient_RequiresClient(t *testing.T) {
+	leaserCheckpointer := newMemoryLeaserCheckpointer(DefaultLeaseDuration, new(sharedStore))
+	_, err := NewWithClient(context.Background(), nil, leaserCheckpointer, leaserCheckpointer)
+	assert.Error(t, err)
+}
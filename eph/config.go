@@ -0,0 +1,165 @@
+package eph
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+type (
+	// Config holds the settings of a host which can be changed while it runs, with SetConfig, without restarting its
+	// receivers or giving up its leases. Zero values leave a setting unchanged.
+	Config struct {
+		// PrefetchCount is the number of events receivers fetch ahead of the handlers. Receivers started afterwards
+		// use it, and with an eventhub.Hub client, so do the links running receivers attach as they recover. Receivers
+		// with adaptive prefetch start from it, within their bounds.
+		PrefetchCount uint32
+		// HandlerWorkers is the number of workers running the handlers, see WithHandlerWorkers. The workers are
+		// replaced once they finish the handlers they are running.
+		HandlerWorkers int
+		// LeaseScanInterval is the time between the scans for leases to acquire or steal. Setting it makes the host
+		// scan right away, then wait the new interval.
+		LeaseScanInterval time.Duration
+		// SinkMaxEvents and SinkMaxDelay bound the transactions of the sink configured with WithSink, from the next
+		// transaction of each partition on. SinkMaxDelay is the time after which a transaction is committed however
+		// few events it holds.
+		SinkMaxEvents int
+		SinkMaxDelay  time.Duration
+	}
+)
+
+// SetConfig changes the settings of the host while it runs. The settings of config are validated before any is
+// applied.
+func (h *EventProcessorHost) SetConfig(config Config) error {
+	switch {
+	case config.HandlerWorkers < 0:
+		return fmt.Errorf("handler workers must be at least 1, got %d", config.HandlerWorkers)
+	case config.LeaseScanInterval < 0:
+		return fmt.Errorf("lease scan interval must be positive, got %v", config.LeaseScanInterval)
+	case config.SinkMaxEvents < 0:
+		return fmt.Errorf("sink transactions must hold at least 1 event, got %d", config.SinkMaxEvents)
+	case config.SinkMaxDelay < 0:
+		return fmt.Errorf("sink transaction delay must not be negative, got %v", config.SinkMaxDelay)
+	}
+
+	if config.PrefetchCount > 0 {
+		if hub, ok := h.client.(*eventhub.Hub); ok {
+			if err := hub.SetConfig(eventhub.HubConfig{PrefetchCount: config.PrefetchCount}); err != nil {
+				return err
+			}
+		}
+	}
+
+	h.configMu.Lock()
+	if config.PrefetchCount > 0 {
+		h.prefetchCount = config.PrefetchCount
+	}
+	if config.LeaseScanInterval > 0 {
+		h.leaseScanInterval = config.LeaseScanInterval
+	}
+	if config.SinkMaxEvents > 0 {
+		h.sinkMaxEvents = config.SinkMaxEvents
+	}
+	if config.SinkMaxDelay > 0 {
+		h.sinkMaxDelay = config.SinkMaxDelay
+	}
+	h.configMu.Unlock()
+
+	if config.LeaseScanInterval > 0 {
+		h.hostMu.Lock()
+		if h.scheduler != nil {
+			h.scheduler.scanNow()
+		}
+		h.hostMu.Unlock()
+	}
+
+	if config.HandlerWorkers > 0 {
+		h.dispatcherMu.Lock()
+		h.handlerWorkers = config.HandlerWorkers
+		// the next handlers dispatched start the workers anew
+		h.dispatcher.stop()
+		h.dispatcher = nil
+		h.dispatcherMu.Unlock()
+	}
+	return nil
+}
+
+// Config returns the settings of the host which can be changed while it runs, as they currently are
+func (h *EventProcessorHost) Config() Config {
+	h.dispatcherMu.Lock()
+	workers := h.handlerWorkers
+	h.dispatcherMu.Unlock()
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	interval := h.scanInterval()
+
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+	prefetch := h.prefetchCount
+	if hub, ok := h.client.(*eventhub.Hub); ok {
+		prefetch = hub.Config().PrefetchCount
+	}
+	return Config{
+		PrefetchCount:     prefetch,
+		HandlerWorkers:    workers,
+		LeaseScanInterval: interval,
+		SinkMaxEvents:     h.sinkMaxEvents,
+		SinkMaxDelay:      h.sinkMaxDelay,
+	}
+}
+
+// receivePrefetch returns the receive option setting the prefetch count of a new receiver, if SetConfig set one and
+// the client of the host doesn't apply it itself
+func (h *EventProcessorHost) receivePrefetch() (eventhub.ReceiveOption, bool) {
+	if _, ok := h.client.(*eventhub.Hub); ok {
+		return nil, false
+	}
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+	if h.prefetchCount == 0 {
+		return nil, false
+	}
+	return eventhub.ReceiveWithPrefetchCount(h.prefetchCount), true
+}
+
+// scanInterval returns the time between lease scans
+func (h *EventProcessorHost) scanInterval() time.Duration {
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+	if h.leaseScanInterval > 0 {
+		return h.leaseScanInterval
+	}
+	return DefaultLeaseRenewalInterval
+}
+
+// sinkLimits returns the bounds of the next sink transaction
+func (h *EventProcessorHost) sinkLimits() (int, time.Duration) {
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+	return h.sinkMaxEvents, h.sinkMaxDelay
+}
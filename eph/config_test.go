@@ -0,0 +1,111 @@
+package eph
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/eventhubtest"
+)
+
+func TestEventProcessorHost_SetConfig(t *testing.T) {
+	host := new(EventProcessorHost)
+	assert.Equal(t, Config{HandlerWorkers: runtime.GOMAXPROCS(0), LeaseScanInterval: DefaultLeaseRenewalInterval}, host.Config())
+
+	for _, config := range []Config{
+		{HandlerWorkers: -1},
+		{LeaseScanInterval: -time.Second},
+		{SinkMaxEvents: -1},
+		{SinkMaxDelay: -time.Second},
+		{PrefetchCount: 10, SinkMaxEvents: -1},
+	} {
+		assert.Error(t, host.SetConfig(config), "%+v", config)
+	}
+	assert.Equal(t, uint32(0), host.Config().PrefetchCount, "an invalid config should not be applied in part")
+
+	dispatcher := host.handlerDispatcher()
+	config := Config{
+		PrefetchCount:     10,
+		HandlerWorkers:    3,
+		LeaseScanInterval: time.Second,
+		SinkMaxEvents:     20,
+		SinkMaxDelay:      time.Minute,
+	}
+	require.NoError(t, host.SetConfig(config))
+	assert.Equal(t, config, host.Config())
+	require.NoError(t, host.SetConfig(Config{}))
+	assert.Equal(t, config, host.Config(), "zero values should leave the settings unchanged")
+
+	replaced := host.handlerDispatcher()
+	assert.NotSame(t, dispatcher, replaced)
+	assert.Equal(t, 3, replaced.workers)
+	select {
+	case <-dispatcher.quit:
+	default:
+		t.Fatal("the workers of the previous dispatcher should be stopped")
+	}
+}
+
+func TestEventProcessorHost_SetConfigWhileRunning(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	h := newSinkHarness(t)
+	host, _ := h.start(ctx, 100, 0)
+	defer func() { _ = host.Close(context.Background()) }()
+	require.Eventually(t, func() bool {
+		return len(host.scheduler.getPartitionIDsBeingProcessed()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, host.SetConfig(Config{SinkMaxEvents: 2, PrefetchCount: 7}))
+	h.send(ctx, 2)
+	h.expect("0", "1")
+
+	prefetch, ok := host.receivePrefetch()
+	require.True(t, ok)
+	settings, err := eventhub.ResolveReceiveOptions(prefetch)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(7), settings.PrefetchCount)
+	assert.Equal(t, []string{"0"}, host.scheduler.getPartitionIDsBeingProcessed(), "the lease should be kept")
+}
+
+// scanCountingLeaser counts the scans of the host it serves
+type scanCountingLeaser struct {
+	*memoryLeaserCheckpointer
+	scans int32
+}
+
+func (l *scanCountingLeaser) GetLeases(ctx context.Context) ([]LeaseMarker, error) {
+	atomic.AddInt32(&l.scans, 1)
+	return l.memoryLeaserCheckpointer.GetLeases(ctx)
+}
+
+func TestEventProcessorHost_SetConfigScansAtTheNewInterval(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	broker, err := eventhubtest.NewBroker(eventhubtest.BrokerWithPartitionCount(1))
+	require.NoError(t, err)
+	client, err := broker.Hub("hub")
+	require.NoError(t, err)
+	leaser := &scanCountingLeaser{memoryLeaserCheckpointer: newMemoryLeaserCheckpointer(DefaultLeaseDuration, new(sharedStore))}
+	host, err := NewWithClient(ctx, client, leaser, leaser, WithNoBanner())
+	require.NoError(t, err)
+	require.NoError(t, host.StartNonBlocking(ctx))
+	defer func() { _ = host.Close(context.Background()) }()
+	require.Eventually(t, func() bool {
+		return len(host.scheduler.getPartitionIDsBeingProcessed()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the host would otherwise wait for the default interval before its next scan
+	require.NoError(t, host.SetConfig(Config{LeaseScanInterval: 20 * time.Millisecond}))
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&leaser.scans) >= 5
+	}, 5*time.Second, 10*time.Millisecond)
+}
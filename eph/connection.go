@@ -0,0 +1,67 @@
+package eph
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+// WithConnectionCount makes the partition receivers of the host share count AMQP connections, each receiver with a
+// session of its own. Sharing a single connection keeps the sockets of a host to one and means a dropped connection
+// is dialed again once rather than once per partition; hosts owning many busy partitions may spread them over a few
+// connections to raise throughput. By default each receiver dials a connection of its own.
+func WithConnectionCount(count int) EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		if count < 1 {
+			return fmt.Errorf("connection count must be at least 1, got %d", count)
+		}
+		host.connectionCount = count
+		return nil
+	}
+}
+
+// connectionHubOptions returns the options which make the host's Event Hub client draw its connections from the pool
+// of the host, if WithConnectionCount configured one
+func (h *EventProcessorHost) connectionHubOptions() ([]eventhub.HubOption, error) {
+	if h.connectionCount == 0 {
+		return nil, nil
+	}
+
+	pool, err := eventhub.NewConnectionPool(h.connectionCount)
+	if err != nil {
+		return nil, err
+	}
+	h.connectionPool = pool
+	return []eventhub.HubOption{eventhub.HubWithConnectionPool(pool)}, nil
+}
+
+// closeConnectionPool closes the connections of the host once its Event Hub client is closed
+func (h *EventProcessorHost) closeConnectionPool(ctx context.Context) error {
+	if h.connectionPool == nil {
+		return nil
+	}
+	return h.connectionPool.Close(ctx)
+}
@@ -0,0 +1,33 @@
+package eph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithConnectionCount(t *testing.T) {
+	host := new(EventProcessorHost)
+	assert.Error(t, WithConnectionCount(0)(host))
+	require.NoError(t, WithConnectionCount(3)(host))
+	assert.Equal(t, 3, host.connectionCount)
+}
+
+func TestConnectionHubOptions(t *testing.T) {
+	host := new(EventProcessorHost)
+	assert.NoError(t, host.closeConnectionPool(context.Background()), "hosts without a pool have nothing to close")
+
+	opts, err := host.connectionHubOptions()
+	require.NoError(t, err)
+	assert.Empty(t, opts, "receivers should dial connections of their own unless a connection count is configured")
+	assert.Nil(t, host.connectionPool)
+
+	require.NoError(t, WithConnectionCount(1)(host))
+	opts, err = host.connectionHubOptions()
+	require.NoError(t, err)
+	assert.Len(t, opts, 1)
+	require.NotNil(t, host.connectionPool)
+	assert.NoError(t, host.closeConnectionPool(context.Background()))
+}
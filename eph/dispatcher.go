@@ -0,0 +1,120 @@
+package eph
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+type (
+	// dispatcher runs the handlers of events on a fixed set of workers shared by the partition receivers of a host,
+	// rather than on a goroutine of their own per event. A handler dispatched while every worker is busy runs on the
+	// dispatching goroutine instead, which bounds the goroutines of the host and throttles receivers to the pace of
+	// their handlers.
+	dispatcher struct {
+		workers   int
+		jobs      chan func()
+		quit      chan struct{}
+		startOnce sync.Once
+		stopOnce  sync.Once
+	}
+)
+
+// WithHandlerWorkers configures the number of workers which run the handlers registered on the host when more than
+// one is registered. A receiver runs the first handler of each event itself and hands the others to the workers,
+// running them itself as well when no worker is free. The default is GOMAXPROCS. A single registered handler always
+// runs on the receiver.
+func WithHandlerWorkers(workers int) EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		if workers < 1 {
+			return fmt.Errorf("handler workers must be at least 1, got %d", workers)
+		}
+		host.handlerWorkers = workers
+		return nil
+	}
+}
+
+func newDispatcher(workers int) *dispatcher {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &dispatcher{
+		workers: workers,
+		jobs:    make(chan func()),
+		quit:    make(chan struct{}),
+	}
+}
+
+// dispatch runs job on an idle worker, or on the calling goroutine if there is none
+func (d *dispatcher) dispatch(job func()) {
+	d.startOnce.Do(d.start)
+	select {
+	case d.jobs <- job:
+	default:
+		job()
+	}
+}
+
+func (d *dispatcher) start() {
+	for i := 0; i < d.workers; i++ {
+		go d.work()
+	}
+}
+
+func (d *dispatcher) work() {
+	for {
+		select {
+		case job := <-d.jobs:
+			job()
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+// stop stops the workers once they finish their jobs. Jobs dispatched afterwards run on the dispatching goroutine.
+func (d *dispatcher) stop() {
+	if d == nil {
+		return
+	}
+	d.stopOnce.Do(func() { close(d.quit) })
+}
+
+// handlerDispatcher returns the dispatcher of the host, creating it on first use
+func (h *EventProcessorHost) handlerDispatcher() *dispatcher {
+	h.dispatcherMu.Lock()
+	defer h.dispatcherMu.Unlock()
+	if h.dispatcher == nil {
+		h.dispatcher = newDispatcher(h.handlerWorkers)
+	}
+	return h.dispatcher
+}
+
+// stopDispatcher stops the handler workers of the host once its receivers are stopped
+func (h *EventProcessorHost) stopDispatcher() {
+	h.dispatcherMu.Lock()
+	defer h.dispatcherMu.Unlock()
+	h.dispatcher.stop()
+}
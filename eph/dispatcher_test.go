@@ -0,0 +1,82 @@
+package eph
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+func TestWithHandlerWorkers(t *testing.T) {
+	host := new(EventProcessorHost)
+	assert.Error(t, WithHandlerWorkers(0)(host))
+	assert.NoError(t, WithHandlerWorkers(2)(host))
+	assert.Equal(t, 2, host.handlerWorkers)
+}
+
+func TestDispatcher_RunsOnCallerWhenWorkersAreBusy(t *testing.T) {
+	d := newDispatcher(1)
+	defer d.stop()
+
+	// occupy the only worker; the send returns once the worker has taken the job
+	d.startOnce.Do(d.start)
+	release := make(chan struct{})
+	defer close(release)
+	d.jobs <- func() { <-release }
+
+	ran := false
+	d.dispatch(func() { ran = true })
+	assert.True(t, ran, "a job dispatched while every worker is busy runs on the caller")
+}
+
+func TestDispatcher_RunsOnIdleWorker(t *testing.T) {
+	d := newDispatcher(1)
+	defer d.stop()
+
+	done := make(chan struct{})
+	d.dispatch(func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("job did not run")
+	}
+}
+
+func TestDispatcher_RunsOnCallerAfterStop(t *testing.T) {
+	d := newDispatcher(2)
+	d.stop()
+	d.stop()
+
+	ran := false
+	d.dispatch(func() { ran = true })
+	assert.True(t, ran)
+}
+
+func TestCompositeHandlers(t *testing.T) {
+	host := &EventProcessorHost{handlers: make(map[string]eventhub.Handler)}
+	defer host.stopDispatcher()
+
+	var calls int32
+	failure := errors.New("failed")
+	host.handlers["ok"] = func(ctx context.Context, event *eventhub.Event) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}
+	handler := host.compositeHandlers()
+	require.NoError(t, handler(context.Background(), eventhub.NewEventFromString("one")))
+	assert.Nil(t, host.dispatcher, "a single handler runs without the dispatcher")
+
+	host.handlers["failing"] = func(ctx context.Context, event *eventhub.Event) error {
+		atomic.AddInt32(&calls, 1)
+		return failure
+	}
+	host.handlers["other"] = host.handlers["ok"]
+	assert.Equal(t, failure, handler(context.Background(), eventhub.NewEventFromString("two")))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,124 @@
+package eph
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+type (
+	// hostDump is the diagnostic snapshot of an EventProcessorHost written by Dump
+	hostDump struct {
+		Time            time.Time        `json:"time"`
+		Host            string           `json:"host"`
+		Namespace       string           `json:"namespace"`
+		Hub             string           `json:"hub"`
+		ConsumerGroup   string           `json:"consumerGroup"`
+		Partitions      []string         `json:"partitions"`
+		OwnedPartitions []string         `json:"ownedPartitions"`
+		Handlers        int              `json:"handlers"`
+		Leases          []leaseDump      `json:"leases"`
+		LeaseError      string           `json:"leaseError,omitempty"`
+		Checkpoints     []checkpointDump `json:"checkpoints"`
+		Client          json.RawMessage  `json:"client,omitempty"`
+	}
+
+	leaseDump struct {
+		PartitionID string `json:"partitionId"`
+		Owner       string `json:"owner"`
+		Epoch       int64  `json:"epoch"`
+		Expired     bool   `json:"expired"`
+	}
+
+	checkpointDump struct {
+		PartitionID string `json:"partitionId"`
+		persist.Checkpoint
+	}
+)
+
+// Dump returns a JSON snapshot of the host's state for support tickets and postmortems: the partitions it owns, the
+// leases of every partition as read from the lease store, the checkpoint of each owned partition, and the dump of its
+// Event Hub client, with its connections, links and pending events. Failing to read the leases is recorded in the
+// snapshot rather than returned.
+func (h *EventProcessorHost) Dump(ctx context.Context) ([]byte, error) {
+	span, ctx := startConsumerSpanFromContext(ctx, "eph.EventProcessorHost.Dump")
+	defer span.End()
+
+	d := hostDump{
+		Time:            time.Now().UTC(),
+		Host:            h.name,
+		Namespace:       h.namespace,
+		Hub:             h.hubName,
+		ConsumerGroup:   h.consumerGroupName(),
+		Partitions:      h.GetPartitionIDs(),
+		OwnedPartitions: h.PartitionIDsBeingProcessed(),
+		Leases:          []leaseDump{},
+		Checkpoints:     []checkpointDump{},
+	}
+	sort.Strings(d.OwnedPartitions)
+
+	h.handlersMu.Lock()
+	d.Handlers = len(h.handlers)
+	h.handlersMu.Unlock()
+
+	if h.leaser != nil {
+		leases, err := h.leaser.GetLeases(ctx)
+		if err != nil {
+			d.LeaseError = err.Error()
+		}
+		for _, lease := range leases {
+			d.Leases = append(d.Leases, leaseDump{
+				PartitionID: lease.GetPartitionID(),
+				Owner:       lease.GetOwner(),
+				Epoch:       lease.GetEpoch(),
+				Expired:     lease.IsExpired(ctx),
+			})
+		}
+		sort.Slice(d.Leases, func(i, j int) bool { return d.Leases[i].PartitionID < d.Leases[j].PartitionID })
+	}
+
+	if h.checkpointer != nil {
+		for _, partitionID := range d.OwnedPartitions {
+			if checkpoint, ok := h.checkpointer.GetCheckpoint(ctx, partitionID); ok {
+				d.Checkpoints = append(d.Checkpoints, checkpointDump{PartitionID: partitionID, Checkpoint: checkpoint})
+			}
+		}
+	}
+
+	if dumper, ok := h.client.(interface {
+		Dump(ctx context.Context) ([]byte, error)
+	}); ok {
+		client, err := dumper.Dump(ctx)
+		if err != nil {
+			return nil, err
+		}
+		d.Client = client
+	}
+
+	return json.MarshalIndent(d, "", "  ")
+}
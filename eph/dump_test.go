@@ -0,0 +1,46 @@
+package eph
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+func TestEventProcessorHost_Dump(t *testing.T) {
+	ctx := context.Background()
+	host := &EventProcessorHost{namespace: "ns", hubName: "hub", name: "host-1", partitionIDs: []string{"0", "1"}}
+	leaserCheckpointer := newMemoryLeaserCheckpointer(DefaultLeaseDuration, new(sharedStore))
+	leaserCheckpointer.SetEventHostProcessor(host)
+	host.leaser = leaserCheckpointer
+	host.checkpointer = leaserCheckpointer
+	require.NoError(t, leaserCheckpointer.EnsureStore(ctx))
+	for _, partitionID := range host.partitionIDs {
+		_, err := leaserCheckpointer.EnsureLease(ctx, partitionID)
+		require.NoError(t, err)
+	}
+	_, ok, err := leaserCheckpointer.AcquireLease(ctx, "1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, leaserCheckpointer.UpdateCheckpoint(ctx, "1", persist.NewCheckpoint("2048", 21, time.Now())))
+
+	raw, err := host.Dump(ctx)
+	require.NoError(t, err)
+
+	var d hostDump
+	require.NoError(t, json.Unmarshal(raw, &d))
+	assert.Equal(t, "host-1", d.Host)
+	assert.Equal(t, "$Default", d.ConsumerGroup)
+	assert.Equal(t, []string{"0", "1"}, d.Partitions)
+	assert.Empty(t, d.OwnedPartitions, "the host hasn't been started")
+	require.Len(t, d.Leases, 2)
+	assert.True(t, d.Leases[0].Expired)
+	assert.False(t, d.Leases[1].Expired)
+	assert.Empty(t, d.LeaseError)
+	assert.Nil(t, d.Client)
+}
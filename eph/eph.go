@@ -38,6 +38,7 @@ import (
 	"github.com/Azure/azure-amqp-common-go/v3/sas"
 	"github.com/Azure/azure-amqp-common-go/v3/uuid"
 	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/metrics"
 	"github.com/Azure/azure-event-hubs-go/v3/persist"
 
 	"github.com/Azure/go-autorest/autorest/azure"
@@ -65,7 +66,7 @@ type (
 		name                string
 		consumerGroup       string
 		tokenProvider       auth.TokenProvider
-		client              *eventhub.Hub
+		client              Client
 		leaser              Leaser
 		checkpointer        Checkpointer
 		scheduler           *scheduler
@@ -73,14 +74,55 @@ type (
 		hostMu              sync.Mutex
 		handlersMu          sync.Mutex
 		partitionIDs        []string
+		partitionsMu        sync.RWMutex
+		partitionWatch      time.Duration
+		stopPartitionWatch  context.CancelFunc
 		noBanner            bool
 		webSocketConnection bool
 		env                 *azure.Environment
+		cgProvisioner       eventhub.ConsumerGroupProvisioner
+		metricsRegistry     *metrics.Registry
+		metrics             *hostMetrics
+		logger              eventhub.Logger
+		lagInterval         time.Duration
+		stopLagReporting    context.CancelFunc
+		errorStream         eventhub.ErrorStream
+		auditHook           AuditHook
+		codecs              []eventhub.Codec
+		eventPooling        bool
+		prefetchMin         uint32
+		prefetchMax         uint32
+		connectionCount     int
+		connectionPool      *eventhub.ConnectionPool
+		handlerWorkers      int
+		keyedWorkers        int
+		dispatcher          *dispatcher
+		dispatcherMu        sync.Mutex
+		retryClassifier     eventhub.RetryClassifier
+		sink                Sink
+		configMu            sync.Mutex
+		sinkMaxEvents       int
+		sinkMaxDelay        time.Duration
+		prefetchCount       uint32
+		leaseScanInterval   time.Duration
 	}
 
 	// EventProcessorHostOption provides configuration options for an EventProcessorHost
 	EventProcessorHostOption func(host *EventProcessorHost) error
 
+	// Client is the Event Hub client an EventProcessorHost receives with. It is implemented by *eventhub.Hub, and by
+	// the in-memory hub of the eventhubtest package for tests.
+	Client interface {
+		eventhub.PartitionedReceiver
+		eventhub.Manager
+		Close(ctx context.Context) error
+	}
+
+	// partitionWatcher is implemented by clients which can report partition changes, such as *eventhub.Hub
+	partitionWatcher interface {
+		WatchPartitions(ctx context.Context, interval time.Duration) (<-chan eventhub.PartitionChange, error)
+	}
+
 	// Receiver provides the ability to handle Event Hub events
 	Receiver interface {
 		Receive(ctx context.Context, handler eventhub.Handler) (close func() error, err error)
@@ -88,6 +130,7 @@ type (
 
 	checkpointPersister struct {
 		checkpointer Checkpointer
+		host         *EventProcessorHost
 	}
 
 	// HandlerID is a UUID in string format that identifies a registered handler
@@ -110,6 +153,30 @@ func WithConsumerGroup(consumerGroup string) EventProcessorHostOption {
 	}
 }
 
+// WithConsumerGroupAutoCreate will configure an EventProcessorHost to ensure its consumer group exists at startup,
+// creating it through the provisioner if it is missing
+func WithConsumerGroupAutoCreate(provisioner eventhub.ConsumerGroupProvisioner) EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		if provisioner == nil {
+			return errors.New("consumer group provisioner must not be nil")
+		}
+		host.cgProvisioner = provisioner
+		return nil
+	}
+}
+
+// WithPartitionWatch will configure an EventProcessorHost to poll the Event Hub for partition changes every interval
+// and start balancing partitions which are added while the host is running
+func WithPartitionWatch(interval time.Duration) EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		if interval <= 0 {
+			return errors.New("partition watch interval must be positive")
+		}
+		host.partitionWatch = interval
+		return nil
+	}
+}
+
 // WithEnvironment will configure an EventProcessorHost to use the specified Azure Environment
 func WithEnvironment(env azure.Environment) EventProcessorHostOption {
 	return func(host *EventProcessorHost) error {
@@ -126,6 +193,41 @@ func WithWebSocketConnection() EventProcessorHostOption {
 	}
 }
 
+// WithCodec registers codec on the Event Hub client of the EventProcessorHost, so Event.Decode decodes received events
+// of its content type with it. See eventhub.HubWithCodec.
+func WithCodec(codec eventhub.Codec) EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		if codec == nil {
+			return errors.New("codec must not be nil")
+		}
+		host.codecs = append(host.codecs, codec)
+		return nil
+	}
+}
+
+// WithEventPooling configures the host to reuse the events it hands to handlers, see eventhub.ReceiveWithEventPooling.
+// Handlers which keep events after returning must retain them with Event.Retain and release them with Event.Release.
+// An event retained by several handlers goes back to the pool once each of them has released it.
+func WithEventPooling() EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		host.eventPooling = true
+		return nil
+	}
+}
+
+// WithAdaptivePrefetch configures the receivers of the host to adjust their prefetch count between min and max from
+// the handler time of events, see eventhub.ReceiveWithAdaptivePrefetch
+func WithAdaptivePrefetch(min, max uint32) EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		if min < 1 || max < min {
+			return fmt.Errorf("adaptive prefetch needs 1 <= min <= max, got min %d and max %d", min, max)
+		}
+		host.prefetchMin = min
+		host.prefetchMax = max
+		return nil
+	}
+}
+
 // NewFromConnectionString builds a new Event Processor Host from an Event Hub connection string which can be found in
 // the Azure portal
 func NewFromConnectionString(ctx context.Context, connStr string, leaser Leaser, checkpointer Checkpointer, opts ...EventProcessorHostOption) (*EventProcessorHost, error) {
@@ -168,7 +270,7 @@ func NewFromConnectionString(ctx context.Context, connStr string, leaser Leaser,
 		}
 	}
 
-	persister := checkpointPersister{checkpointer: checkpointer}
+	persister := checkpointPersister{checkpointer: checkpointer, host: host}
 	hubOpts := []eventhub.HubOption{eventhub.HubWithOffsetPersistence(persister)}
 	if host.env != nil {
 		hubOpts = append(hubOpts, eventhub.HubWithEnvironment(*host.env))
@@ -178,6 +280,32 @@ func NewFromConnectionString(ctx context.Context, connStr string, leaser Leaser,
 		hubOpts = append(hubOpts, eventhub.HubWithWebSocketConnection())
 	}
 
+	if host.cgProvisioner != nil {
+		hubOpts = append(hubOpts, eventhub.HubWithConsumerGroupAutoCreate(host.cgProvisioner))
+	}
+
+	for _, codec := range host.codecs {
+		hubOpts = append(hubOpts, eventhub.HubWithCodec(codec))
+	}
+	if host.retryClassifier != nil {
+		hubOpts = append(hubOpts, eventhub.HubWithRetryClassifier(host.retryClassifier))
+	}
+	hubOpts = append(hubOpts, host.metricsHubOptions()...)
+	hubOpts = append(hubOpts, host.loggerHubOptions()...)
+	hubOpts = append(hubOpts, host.errorHubOptions()...)
+
+	connOpts, err := host.connectionHubOptions()
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	hubOpts = append(hubOpts, connOpts...)
+
+	if err := host.ensureConsumerGroup(ctx); err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+
 	client, err := eventhub.NewHubFromConnectionString(connStr, hubOpts...)
 	if err != nil {
 		tab.For(ctx).Error(err)
@@ -196,6 +324,22 @@ func NewFromConnectionString(ctx context.Context, connStr string, leaser Leaser,
 	return host, nil
 }
 
+// NewFromIoTHubConnectionString builds a new Event Processor Host for the built-in endpoint of an IoT Hub, where the
+// hub delivers device-to-cloud messages, from a connection string of a shared access policy of the IoT Hub. The Event
+// Hubs compatible endpoint is discovered with eventhub.ResolveIoTHubConnectionString over AMQP; where only web
+// sockets are allowed, resolve it with eventhub.HubWithWebSocketConnection and use NewFromConnectionString instead.
+func NewFromIoTHubConnectionString(ctx context.Context, connStr string, leaser Leaser, checkpointer Checkpointer, opts ...EventProcessorHostOption) (*EventProcessorHost, error) {
+	span, ctx := startConsumerSpanFromContext(ctx, "eph.NewFromIoTHubConnectionString")
+	defer span.End()
+
+	ehConnStr, err := eventhub.ResolveIoTHubConnectionString(ctx, connStr)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	return NewFromConnectionString(ctx, ehConnStr, leaser, checkpointer, opts...)
+}
+
 // New constructs a new instance of an EventHostProcessor
 func New(ctx context.Context, namespace, hubName string, tokenProvider auth.TokenProvider, leaser Leaser, checkpointer Checkpointer, opts ...EventProcessorHostOption) (*EventProcessorHost, error) {
 	span, ctx := startConsumerSpanFromContext(ctx, "eph.New")
@@ -224,7 +368,7 @@ func New(ctx context.Context, namespace, hubName string, tokenProvider auth.Toke
 		}
 	}
 
-	persister := checkpointPersister{checkpointer: checkpointer}
+	persister := checkpointPersister{checkpointer: checkpointer, host: host}
 	hubOpts := []eventhub.HubOption{eventhub.HubWithOffsetPersistence(persister)}
 	if host.env != nil {
 		hubOpts = append(hubOpts, eventhub.HubWithEnvironment(*host.env))
@@ -234,6 +378,32 @@ func New(ctx context.Context, namespace, hubName string, tokenProvider auth.Toke
 		hubOpts = append(hubOpts, eventhub.HubWithWebSocketConnection())
 	}
 
+	if host.cgProvisioner != nil {
+		hubOpts = append(hubOpts, eventhub.HubWithConsumerGroupAutoCreate(host.cgProvisioner))
+	}
+
+	for _, codec := range host.codecs {
+		hubOpts = append(hubOpts, eventhub.HubWithCodec(codec))
+	}
+	if host.retryClassifier != nil {
+		hubOpts = append(hubOpts, eventhub.HubWithRetryClassifier(host.retryClassifier))
+	}
+	hubOpts = append(hubOpts, host.metricsHubOptions()...)
+	hubOpts = append(hubOpts, host.loggerHubOptions()...)
+	hubOpts = append(hubOpts, host.errorHubOptions()...)
+
+	connOpts, err := host.connectionHubOptions()
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	hubOpts = append(hubOpts, connOpts...)
+
+	if err := host.ensureConsumerGroup(ctx); err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+
 	client, err := eventhub.NewHub(namespace, hubName, tokenProvider, hubOpts...)
 	if err != nil {
 		return nil, err
@@ -249,6 +419,67 @@ func New(ctx context.Context, namespace, hubName string, tokenProvider auth.Toke
 	return host, nil
 }
 
+// NewWithClient builds a new Event Processor Host which receives with client rather than with an Event Hub client of
+// its own, such as the in-memory hub of the eventhubtest package for tests of handlers and of balancing. The options
+// which configure the Event Hub client of a host, such as WithCodec, WithWebSocketConnection and WithConnectionCount,
+// don't apply to client. Clients other than *eventhub.Hub are asked to start receiving after the checkpoint of a
+// partition, and the host records a checkpoint after each event handled without error.
+func NewWithClient(ctx context.Context, client Client, leaser Leaser, checkpointer Checkpointer, opts ...EventProcessorHostOption) (*EventProcessorHost, error) {
+	span, ctx := startConsumerSpanFromContext(ctx, "eph.NewWithClient")
+	defer span.End()
+
+	if client == nil {
+		return nil, errors.New("client must not be nil")
+	}
+
+	hostName, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	host := &EventProcessorHost{
+		name:         hostName.String(),
+		handlers:     make(map[string]eventhub.Handler),
+		leaser:       leaser,
+		checkpointer: checkpointer,
+	}
+
+	for _, opt := range opts {
+		if err := opt(host); err != nil {
+			return nil, err
+		}
+	}
+
+	runtimeInfo, err := client.GetRuntimeInformation(ctx)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	host.hubName = runtimeInfo.Path
+
+	if err := host.ensureConsumerGroup(ctx); err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+
+	host.client = client
+	host.partitionIDs = runtimeInfo.PartitionIDs
+	return host, nil
+}
+
+// ensureConsumerGroup verifies the configured consumer group exists when consumer group auto-creation is enabled so
+// a missing consumer group is created, or reported, before any partition receivers are started
+func (h *EventProcessorHost) ensureConsumerGroup(ctx context.Context) error {
+	if h.cgProvisioner == nil || h.consumerGroup == "" || h.consumerGroup == eventhub.DefaultConsumerGroup {
+		return nil
+	}
+
+	if err := h.cgProvisioner.EnsureConsumerGroup(ctx, h.hubName, h.consumerGroup); err != nil {
+		return fmt.Errorf("failed to ensure consumer group %q exists: %w", h.consumerGroup, err)
+	}
+	return nil
+}
+
 // RegisteredHandlerIDs will return the registered event handler IDs
 func (h *EventProcessorHost) RegisteredHandlerIDs() []HandlerID {
 	h.handlersMu.Lock()
@@ -358,37 +589,59 @@ func (h *EventProcessorHost) GetName() string {
 
 // GetPartitionIDs fetches the partition IDs for the Event Hub
 func (h *EventProcessorHost) GetPartitionIDs() []string {
-	return h.partitionIDs
+	h.partitionsMu.RLock()
+	defer h.partitionsMu.RUnlock()
+
+	return append([]string(nil), h.partitionIDs...)
 }
 
 // PartitionIDsBeingProcessed returns the partition IDs currently receiving messages
 func (h *EventProcessorHost) PartitionIDsBeingProcessed() []string {
+	if h.scheduler == nil {
+		// the host hasn't been started
+		return nil
+	}
 	return h.scheduler.getPartitionIDsBeingProcessed()
 }
 
-// Close stops the EventHostProcessor from processing messages
+// Close stops the EventHostProcessor from processing messages. Waiting for its receivers, stores and connections to
+// close is bounded by ctx. If any of them fails to close cleanly, Close still closes the others and returns an
+// *eventhub.CloseError describing each failure.
 func (h *EventProcessorHost) Close(ctx context.Context) error {
 	if !h.noBanner {
 		fmt.Println("shutting down...")
 	}
+
+	h.hostMu.Lock()
+	if h.stopPartitionWatch != nil {
+		h.stopPartitionWatch()
+		h.stopPartitionWatch = nil
+	}
+	if h.stopLagReporting != nil {
+		h.stopLagReporting()
+		h.stopLagReporting = nil
+	}
+	h.hostMu.Unlock()
+
+	var errs closeErrors
 	if h.scheduler != nil {
-		if err := h.scheduler.Stop(ctx); err != nil {
-			if h.client != nil {
-				_ = h.client.Close(ctx)
-			}
-			return err
-		}
+		errs.add("scheduler", h.scheduler.Stop(ctx))
 	}
+	h.stopDispatcher()
 
 	if h.leaser != nil {
-		_ = h.leaser.Close()
+		errs.add("leaser", h.leaser.Close(ctx))
 	}
 
 	if h.checkpointer != nil {
-		_ = h.checkpointer.Close()
+		errs.add("checkpointer", h.checkpointer.Close(ctx))
 	}
 
-	return h.client.Close(ctx)
+	if h.client != nil {
+		errs.add("event hub client", h.client.Close(ctx))
+	}
+	errs.add("connection pool", h.closeConnectionPool(ctx))
+	return errs.err()
 }
 
 func (h *EventProcessorHost) setup(ctx context.Context) error {
@@ -410,13 +663,60 @@ func (h *EventProcessorHost) setup(ctx context.Context) error {
 
 		scheduler := newScheduler(h)
 
-		for _, partitionID := range h.partitionIDs {
+		for _, partitionID := range h.GetPartitionIDs() {
 			h.leaser.EnsureLease(ctx, partitionID)
 			h.checkpointer.EnsureCheckpoint(ctx, partitionID)
 		}
 
 		h.scheduler = scheduler
+
+		if h.partitionWatch > 0 {
+			if err := h.startPartitionWatch(ctx); err != nil {
+				return err
+			}
+		}
+
+		if h.lagInterval > 0 {
+			h.startLagReporting(ctx)
+		}
+	}
+	return nil
+}
+
+// startPartitionWatch subscribes to partition changes of the Event Hub. New partitions get a lease and checkpoint and
+// are picked up by the scheduler on its next scan.
+func (h *EventProcessorHost) startPartitionWatch(ctx context.Context) error {
+	watcher, ok := h.client.(partitionWatcher)
+	if !ok {
+		return fmt.Errorf("the client of the host, a %T, can't watch partitions", h.client)
 	}
+
+	watchCtx, cancel := context.WithCancel(tab.NewContext(context.Background(), tab.FromContext(ctx)))
+	changes, err := watcher.WatchPartitions(watchCtx, h.partitionWatch)
+	if err != nil {
+		cancel()
+		return err
+	}
+	h.stopPartitionWatch = cancel
+
+	go func() {
+		for change := range changes {
+			span, ctx := startConsumerSpanFromContext(watchCtx, "eph.EventProcessorHost.partitionsChanged")
+			for _, partitionID := range change.Added() {
+				if _, err := h.leaser.EnsureLease(ctx, partitionID); err != nil {
+					tab.For(ctx).Error(err)
+				}
+				if _, err := h.checkpointer.EnsureCheckpoint(ctx, partitionID); err != nil {
+					tab.For(ctx).Error(err)
+				}
+			}
+
+			h.partitionsMu.Lock()
+			h.partitionIDs = change.Current.PartitionIDs
+			h.partitionsMu.Unlock()
+			span.End()
+		}
+	}()
 	return nil
 }
 
@@ -425,24 +725,56 @@ func (h *EventProcessorHost) compositeHandlers() eventhub.Handler {
 		span, ctx := startConsumerSpanFromContext(ctx, "eph.EventProcessorHost.compositeHandlers")
 		defer span.End()
 
+		// the handlers run without the lock, so receivers and keyed workers handle events concurrently
 		h.handlersMu.Lock()
-		defer h.handlersMu.Unlock()
+		handlers := make([]eventhub.Handler, 0, len(h.handlers))
+		for _, handler := range h.handlers {
+			handlers = append(handlers, handler)
+		}
+		h.handlersMu.Unlock()
+
+		if len(handlers) == 1 {
+			err := handlers[0](ctx, event)
+			if err != nil {
+				tab.For(ctx).Error(err)
+			}
+			return err
+		}
+
+		dispatcher := h.handlerDispatcher()
 
 		// we accept that this will contain any of the possible len(h.handlers) errors
 		// as it will be used to later decide of delivery is considered a failure
 		// and NOT further inspected
 		var lastError error
+		var errMu sync.Mutex
 
 		wg := &sync.WaitGroup{}
-		for _, handler := range h.handlers {
-			wg.Add(1)
-			go func(boundHandler eventhub.Handler) {
+		run := func(handler eventhub.Handler) func() {
+			return func() {
 				defer wg.Done() // consider if panics should be cought here, too. Currently would crash process
-				if err := boundHandler(ctx, event); err != nil {
+				if err := handler(ctx, event); err != nil {
+					errMu.Lock()
 					lastError = err
+					errMu.Unlock()
 					tab.For(ctx).Error(err)
 				}
-			}(handler)
+			}
+		}
+
+		// the receiver runs the first handler itself once the others are dispatched
+		var first eventhub.Handler
+		for _, handler := range handlers {
+			if first == nil {
+				first = handler
+				continue
+			}
+			wg.Add(1)
+			dispatcher.dispatch(run(handler))
+		}
+		if first != nil {
+			wg.Add(1)
+			run(first)()
 		}
 		wg.Wait()
 		return lastError
@@ -452,7 +784,7 @@ func (h *EventProcessorHost) compositeHandlers() eventhub.Handler {
 func (c checkpointPersister) Write(namespace, name, consumerGroup, partitionID string, checkpoint persist.Checkpoint) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	return c.checkpointer.UpdateCheckpoint(ctx, partitionID, checkpoint)
+	return c.host.updateCheckpoint(ctx, c.checkpointer, partitionID, checkpoint)
 }
 
 func (c checkpointPersister) Read(namespace, name, consumerGroup, partitionID string) (persist.Checkpoint, error) {
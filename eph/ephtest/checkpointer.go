@@ -0,0 +1,136 @@
+package ephtest
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+
+	"github.com/Azure/azure-event-hubs-go/v3/eph"
+	"github.com/Azure/azure-event-hubs-go/v3/eventhubtest"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+type (
+	// Checkpointer is a fake eph.Checkpointer which keeps its checkpoints in a Store. Like the storage checkpointer,
+	// it only updates the checkpoints of partitions the host holds the lease of, so it is used with a Leaser of the
+	// same store. Each operation fails on demand through the embedded Script, which names operations after the methods
+	// of eph.Checkpointer.
+	Checkpointer struct {
+		eventhubtest.Script
+		store *Store
+		host  *eph.EventProcessorHost
+	}
+)
+
+var _ eph.Checkpointer = (*Checkpointer)(nil)
+
+// NewCheckpointer creates a new Checkpointer of store
+func NewCheckpointer(store *Store) *Checkpointer {
+	return &Checkpointer{store: store}
+}
+
+// SetEventHostProcessor sets the host the checkpointer stores checkpoints for
+func (c *Checkpointer) SetEventHostProcessor(host *eph.EventProcessorHost) {
+	c.host = host
+}
+
+// StoreExists tells if the store has been created
+func (c *Checkpointer) StoreExists(_ context.Context) (bool, error) {
+	if err := c.Call("StoreExists"); err != nil {
+		return false, err
+	}
+	return c.store.exists(), nil
+}
+
+// EnsureStore creates the store if it doesn't exist
+func (c *Checkpointer) EnsureStore(_ context.Context) error {
+	if err := c.Call("EnsureStore"); err != nil {
+		return err
+	}
+	c.store.ensure()
+	return nil
+}
+
+// DeleteStore deletes all leases and checkpoints of the store
+func (c *Checkpointer) DeleteStore(_ context.Context) error {
+	if err := c.Call("DeleteStore"); err != nil {
+		return err
+	}
+	c.store.delete()
+	return nil
+}
+
+// GetCheckpoint returns the checkpoint of partitionID. As the method can't return an error, a failure scripted for
+// "GetCheckpoint" returns no checkpoint.
+func (c *Checkpointer) GetCheckpoint(_ context.Context, partitionID string) (persist.Checkpoint, bool) {
+	if err := c.Call("GetCheckpoint"); err != nil {
+		return persist.Checkpoint{}, false
+	}
+	return c.store.Checkpoint(partitionID)
+}
+
+// EnsureCheckpoint returns the checkpoint of partitionID, creating one at the start of the stream if it doesn't exist
+func (c *Checkpointer) EnsureCheckpoint(_ context.Context, partitionID string) (persist.Checkpoint, error) {
+	if err := c.Call("EnsureCheckpoint"); err != nil {
+		return persist.Checkpoint{}, err
+	}
+
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	checkpoint, ok := c.store.checkpoints[partitionID]
+	if !ok {
+		checkpoint = persist.NewCheckpointFromStartOfStream()
+		c.store.checkpoints[partitionID] = checkpoint
+	}
+	return checkpoint, nil
+}
+
+// UpdateCheckpoint stores checkpoint for partitionID if the host holds the lease of the partition
+func (c *Checkpointer) UpdateCheckpoint(_ context.Context, partitionID string, checkpoint persist.Checkpoint) error {
+	if err := c.Call("UpdateCheckpoint"); err != nil {
+		return err
+	}
+	return c.store.putCheckpoint(c.owner(), partitionID, checkpoint)
+}
+
+// DeleteCheckpoint moves the checkpoint of partitionID back to the start of the stream if the host holds the lease
+// of the partition
+func (c *Checkpointer) DeleteCheckpoint(_ context.Context, partitionID string) error {
+	if err := c.Call("DeleteCheckpoint"); err != nil {
+		return err
+	}
+	return c.store.putCheckpoint(c.owner(), partitionID, persist.NewCheckpointFromStartOfStream())
+}
+
+// Close closes the checkpointer
+func (c *Checkpointer) Close(_ context.Context) error {
+	return c.Call("Close")
+}
+
+func (c *Checkpointer) owner() string {
+	if c.host == nil {
+		return ""
+	}
+	return c.host.GetName()
+}
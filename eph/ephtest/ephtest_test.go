@@ -0,0 +1,227 @@
+package ephtest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/eph"
+	"github.com/Azure/azure-event-hubs-go/v3/eventhubtest"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+func newTestHost(t *testing.T, broker *eventhubtest.Broker, store *Store) (*eph.EventProcessorHost, *Leaser, *Checkpointer) {
+	hub, err := broker.Hub("hub")
+	require.NoError(t, err)
+	leaser, checkpointer := NewLeaser(store), NewCheckpointer(store)
+	host, err := eph.NewWithClient(context.Background(), hub, leaser, checkpointer, eph.WithNoBanner())
+	require.NoError(t, err)
+	return host, leaser, checkpointer
+}
+
+func TestStore_RunsHost(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	broker, err := eventhubtest.NewBroker(eventhubtest.BrokerWithPartitionCount(2))
+	require.NoError(t, err)
+	sender, err := broker.Hub("hub")
+	require.NoError(t, err)
+	const count = 10
+	for i := 0; i < count; i++ {
+		require.NoError(t, sender.Send(ctx, eventhub.NewEventFromString(strconv.Itoa(i))))
+	}
+
+	store, err := NewStore()
+	require.NoError(t, err)
+	host, leaser, checkpointer := newTestHost(t, broker, store)
+	var mu sync.Mutex
+	received := 0
+	all := make(chan struct{})
+	_, err = host.RegisterHandler(ctx, func(context.Context, *eventhub.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if received++; received == count {
+			close(all)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, host.StartNonBlocking(ctx))
+	defer func() { _ = host.Close(ctx) }()
+
+	select {
+	case <-all:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the events of the hub")
+	}
+	assert.Equal(t, 2, leaser.Calls("AcquireLease"))
+
+	for _, partitionID := range host.GetPartitionIDs() {
+		lease, ok := store.Lease(partitionID)
+		require.True(t, ok)
+		assert.Equal(t, host.GetName(), lease.Owner)
+
+		events, err := broker.Events("hub", partitionID)
+		require.NoError(t, err)
+		last := events[len(events)-1].GetCheckpoint()
+		require.Eventually(t, func() bool {
+			checkpoint, ok := checkpointer.GetCheckpoint(ctx, partitionID)
+			return ok && checkpoint.Offset == last.Offset
+		}, 5*time.Second, 10*time.Millisecond, "partition %s should be checkpointed after its last event", partitionID)
+	}
+}
+
+func TestScript_FailsHostStart(t *testing.T) {
+	broker, err := eventhubtest.NewBroker()
+	require.NoError(t, err)
+	store, err := NewStore()
+	require.NoError(t, err)
+	host, leaser, _ := newTestHost(t, broker, store)
+	_, err = host.RegisterHandler(context.Background(), func(context.Context, *eventhub.Event) error { return nil })
+	require.NoError(t, err)
+
+	unavailable := errors.New("storage unavailable")
+	leaser.FailNext("EnsureStore", unavailable)
+	assert.Equal(t, unavailable, host.StartNonBlocking(context.Background()))
+	assert.Equal(t, 1, leaser.Calls("EnsureStore"))
+}
+
+func TestLeaser_CoordinatesHosts(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store, err := NewStore(StoreWithLeaseDuration(time.Minute), StoreWithClock(func() time.Time { return now }))
+	require.NoError(t, err)
+	broker, err := eventhubtest.NewBroker()
+	require.NoError(t, err)
+	first, firstLeaser, firstCheckpointer := newTestHost(t, broker, store)
+	second, secondLeaser, secondCheckpointer := newTestHost(t, broker, store)
+	firstLeaser.SetEventHostProcessor(first)
+	firstCheckpointer.SetEventHostProcessor(first)
+	secondLeaser.SetEventHostProcessor(second)
+	secondCheckpointer.SetEventHostProcessor(second)
+
+	_, err = firstLeaser.EnsureLease(ctx, "0")
+	require.NoError(t, err)
+	lease, ok, err := firstLeaser.AcquireLease(ctx, "0")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, first.GetName(), lease.GetOwner())
+	assert.Equal(t, int64(1), lease.GetEpoch())
+	assert.False(t, lease.IsExpired(ctx))
+
+	_, ok, err = secondLeaser.RenewLease(ctx, "0")
+	require.NoError(t, err)
+	assert.False(t, ok, "only the owner renews a lease")
+	checkpoint := persist.Checkpoint{Offset: "10", SequenceNumber: 10}
+	require.NoError(t, firstCheckpointer.UpdateCheckpoint(ctx, "0", checkpoint))
+	err = secondCheckpointer.UpdateCheckpoint(ctx, "0", checkpoint)
+	assert.True(t, errors.Is(err, eph.ErrCheckpointConflict), "only the owner checkpoints a partition")
+
+	stolen, ok, err := secondLeaser.AcquireLease(ctx, "0")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, int64(2), stolen.GetEpoch())
+	assert.True(t, lease.IsExpired(ctx), "a stolen lease is lost to its previous owner")
+	_, ok, err = firstLeaser.RenewLease(ctx, "0")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	assert.True(t, stolen.IsExpired(ctx), "leases expire unless renewed")
+	now = now.Add(-2 * time.Minute)
+	store.ExpireLease("0")
+	assert.True(t, stolen.IsExpired(ctx))
+
+	leases, err := firstLeaser.GetLeases(ctx)
+	require.NoError(t, err)
+	require.Len(t, leases, 1)
+	saved, ok := store.Checkpoint("0")
+	require.True(t, ok)
+	assert.Equal(t, checkpoint, saved)
+
+	secondLeaser.FailAlways("ReleaseLease", errors.New("storage unavailable"))
+	_, err = secondLeaser.ReleaseLease(ctx, "0")
+	assert.Error(t, err)
+	secondLeaser.FailAlways("ReleaseLease", nil)
+	_, err = secondLeaser.ReleaseLease(ctx, "0")
+	assert.NoError(t, err)
+}
+
+func TestStore_ResetsCheckpoints(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	broker, err := eventhubtest.NewBroker(eventhubtest.BrokerWithPartitionCount(2))
+	require.NoError(t, err)
+	sender, err := broker.Hub("hub")
+	require.NoError(t, err)
+	const count = 10
+	for i := 0; i < count; i++ {
+		require.NoError(t, sender.Send(ctx, eventhub.NewEventFromString(strconv.Itoa(i))))
+	}
+
+	store, err := NewStore()
+	require.NoError(t, err)
+	run := func() {
+		host, _, _ := newTestHost(t, broker, store)
+		var mu sync.Mutex
+		received := 0
+		all := make(chan struct{})
+		_, err := host.RegisterHandler(ctx, func(context.Context, *eventhub.Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if received++; received == count {
+				close(all)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.NoError(t, host.StartNonBlocking(ctx))
+		select {
+		case <-all:
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for the events of the hub")
+		}
+
+		for _, partitionID := range host.GetPartitionIDs() {
+			events, err := broker.Events("hub", partitionID)
+			require.NoError(t, err)
+			last := events[len(events)-1].GetCheckpoint()
+			require.Eventually(t, func() bool {
+				checkpoint, ok := store.Checkpoint(partitionID)
+				return ok && checkpoint.Offset == last.Offset
+			}, 5*time.Second, 10*time.Millisecond)
+		}
+
+		_, err = eventhub.ResetCheckpoints(ctx, sender, store, eventhub.ResetToEarliest())
+		assert.Error(t, err, "checkpoints of partitions leased by a host should not be reset")
+		require.NoError(t, host.Close(ctx))
+	}
+	run()
+
+	resets, err := eventhub.ResetCheckpoints(ctx, sender, store, eventhub.ResetToEarliest(), eventhub.ResetWithDryRun())
+	require.NoError(t, err)
+	require.Len(t, resets, 2)
+	var delta int64
+	for _, reset := range resets {
+		assert.True(t, reset.HadCheckpoint)
+		assert.True(t, reset.DeltaKnown)
+		assert.Equal(t, persist.NewCheckpointFromStartOfStream(), reset.After)
+		delta += reset.Delta
+		checkpoint, _ := store.Checkpoint(reset.PartitionID)
+		assert.Equal(t, reset.Before, checkpoint, "a dry run should not write checkpoints")
+	}
+	assert.Equal(t, int64(-count), delta, "every event should be received again")
+
+	_, err = eventhub.ResetCheckpoints(ctx, sender, store, eventhub.ResetToEarliest())
+	require.NoError(t, err)
+	run()
+}
@@ -0,0 +1,205 @@
+package ephtest
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-event-hubs-go/v3/eph"
+	"github.com/Azure/azure-event-hubs-go/v3/eventhubtest"
+)
+
+type (
+	// Leaser is a fake eph.Leaser which keeps its leases in a Store. Hosts sharing a store balance their partitions
+	// the way they would with a storage account, and each operation fails on demand through the embedded Script,
+	// which names operations after the methods of eph.Leaser.
+	Leaser struct {
+		eventhubtest.Script
+		store *Store
+		host  *eph.EventProcessorHost
+	}
+)
+
+var _ eph.Leaser = (*Leaser)(nil)
+
+// NewLeaser creates a new Leaser of store
+func NewLeaser(store *Store) *Leaser {
+	return &Leaser{store: store}
+}
+
+// SetEventHostProcessor sets the host the leaser acquires leases for
+func (l *Leaser) SetEventHostProcessor(host *eph.EventProcessorHost) {
+	l.host = host
+}
+
+// StoreExists tells if the store has been created
+func (l *Leaser) StoreExists(_ context.Context) (bool, error) {
+	if err := l.Call("StoreExists"); err != nil {
+		return false, err
+	}
+	return l.store.exists(), nil
+}
+
+// EnsureStore creates the store if it doesn't exist
+func (l *Leaser) EnsureStore(_ context.Context) error {
+	if err := l.Call("EnsureStore"); err != nil {
+		return err
+	}
+	l.store.ensure()
+	return nil
+}
+
+// DeleteStore deletes all leases and checkpoints of the store
+func (l *Leaser) DeleteStore(_ context.Context) error {
+	if err := l.Call("DeleteStore"); err != nil {
+		return err
+	}
+	l.store.delete()
+	return nil
+}
+
+// GetLeases returns the leases of all partitions
+func (l *Leaser) GetLeases(_ context.Context) ([]eph.LeaseMarker, error) {
+	if err := l.Call("GetLeases"); err != nil {
+		return nil, err
+	}
+
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	var leases []eph.LeaseMarker
+	for _, partitionID := range l.store.partitionIDs() {
+		leases = append(leases, l.store.lease(partitionID))
+	}
+	return leases, nil
+}
+
+// EnsureLease creates the lease of partitionID if it doesn't exist
+func (l *Leaser) EnsureLease(_ context.Context, partitionID string) (eph.LeaseMarker, error) {
+	if err := l.Call("EnsureLease"); err != nil {
+		return nil, err
+	}
+
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	if _, ok := l.store.leases[partitionID]; !ok {
+		l.store.leases[partitionID] = &storedLease{lease: eph.Lease{PartitionID: partitionID}}
+	}
+	return l.store.lease(partitionID), nil
+}
+
+// DeleteLease deletes the lease of partitionID
+func (l *Leaser) DeleteLease(_ context.Context, partitionID string) error {
+	if err := l.Call("DeleteLease"); err != nil {
+		return err
+	}
+
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	delete(l.store.leases, partitionID)
+	return nil
+}
+
+// AcquireLease takes the lease of partitionID for the host, also from another owner, and increments its epoch
+func (l *Leaser) AcquireLease(_ context.Context, partitionID string) (eph.LeaseMarker, bool, error) {
+	if err := l.Call("AcquireLease"); err != nil {
+		return nil, false, err
+	}
+
+	if l.host == nil {
+		return nil, false, errors.New("leaser has no event processor host to acquire leases for")
+	}
+
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	stored, ok := l.store.leases[partitionID]
+	if !ok {
+		return nil, false, fmt.Errorf("lease of partition %q doesn't exist", partitionID)
+	}
+	stored.lease.Owner = l.owner()
+	stored.lease.Epoch++
+	stored.expiration = l.store.now().Add(l.store.leaseDuration)
+	return l.store.lease(partitionID), true, nil
+}
+
+// RenewLease extends the lease of partitionID if the host still holds it
+func (l *Leaser) RenewLease(_ context.Context, partitionID string) (eph.LeaseMarker, bool, error) {
+	if err := l.Call("RenewLease"); err != nil {
+		return nil, false, err
+	}
+	return l.renew(partitionID)
+}
+
+// ReleaseLease gives up the lease of partitionID if the host holds it
+func (l *Leaser) ReleaseLease(_ context.Context, partitionID string) (bool, error) {
+	if err := l.Call("ReleaseLease"); err != nil {
+		return false, err
+	}
+
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	stored, ok := l.store.leases[partitionID]
+	if !ok || stored.lease.Owner != l.owner() {
+		return false, nil
+	}
+	stored.lease.Owner = ""
+	stored.expiration = time.Time{}
+	return true, nil
+}
+
+// UpdateLease extends the lease of partitionID if the host still holds it
+func (l *Leaser) UpdateLease(_ context.Context, partitionID string) (eph.LeaseMarker, bool, error) {
+	if err := l.Call("UpdateLease"); err != nil {
+		return nil, false, err
+	}
+	return l.renew(partitionID)
+}
+
+// Close closes the leaser
+func (l *Leaser) Close(_ context.Context) error {
+	return l.Call("Close")
+}
+
+func (l *Leaser) renew(partitionID string) (eph.LeaseMarker, bool, error) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	if !l.store.isHeld(partitionID, l.owner()) {
+		return nil, false, nil
+	}
+	l.store.leases[partitionID].expiration = l.store.now().Add(l.store.leaseDuration)
+	return l.store.lease(partitionID), true, nil
+}
+
+func (l *Leaser) owner() string {
+	if l.host == nil {
+		return ""
+	}
+	return l.host.GetName()
+}
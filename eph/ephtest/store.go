@@ -0,0 +1,228 @@
+// Package ephtest provides fakes of the Leaser and Checkpointer of the eph package, so event processor host handlers
+// and the code around them can be unit tested without a storage account.
+//
+// A Store keeps the leases and checkpoints of any number of hosts in memory. Each host gets a Leaser and a
+// Checkpointer of the store, whose operations fail on demand through their embedded eventhubtest.Script:
+//
+//	store, err := ephtest.NewStore()
+//	leaser, checkpointer := ephtest.NewLeaser(store), ephtest.NewCheckpointer(store)
+//	checkpointer.FailNext("UpdateCheckpoint", errors.New("storage unavailable"))
+//
+//	host, err := eph.NewWithClient(ctx, hub, leaser, checkpointer)
+//
+// Combined with a hub of an eventhubtest.Broker, whole hosts run in a unit test.
+package ephtest
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/eph"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+type (
+	// Store holds the leases and checkpoints of the Leasers and Checkpointers created for it, like the storage account
+	// of the storage leaser and checkpointer. It is safe for concurrent use.
+	Store struct {
+		leaseDuration time.Duration
+		now           func() time.Time
+
+		mu          sync.Mutex
+		created     bool
+		leases      map[string]*storedLease
+		checkpoints map[string]persist.Checkpoint
+	}
+
+	// StoreOption provides configuration options for a Store
+	StoreOption func(s *Store) error
+
+	storedLease struct {
+		lease      eph.Lease
+		expiration time.Time
+	}
+
+	// Lease is a lease of a Store
+	Lease struct {
+		eph.Lease
+		store *Store
+	}
+)
+
+var _ eventhub.CheckpointStore = (*Store)(nil)
+
+// NewStore creates a new empty Store whose leases last eph.DefaultLeaseDuration
+func NewStore(opts ...StoreOption) (*Store, error) {
+	s := &Store{
+		leaseDuration: eph.DefaultLeaseDuration,
+		now:           time.Now,
+		leases:        make(map[string]*storedLease),
+		checkpoints:   make(map[string]persist.Checkpoint),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// StoreWithLeaseDuration configures how long leases last unless renewed
+func StoreWithLeaseDuration(d time.Duration) StoreOption {
+	return func(s *Store) error {
+		if d <= 0 {
+			return errors.New("lease duration must be positive")
+		}
+		s.leaseDuration = d
+		return nil
+	}
+}
+
+// StoreWithClock configures the clock leases expire by, so tests can expire them without waiting
+func StoreWithClock(now func() time.Time) StoreOption {
+	return func(s *Store) error {
+		if now == nil {
+			return errors.New("clock must not be nil")
+		}
+		s.now = now
+		return nil
+	}
+}
+
+// Lease returns the lease of partitionID as it is stored
+func (s *Store) Lease(partitionID string) (eph.Lease, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.leases[partitionID]
+	if !ok {
+		return eph.Lease{}, false
+	}
+	return l.lease, true
+}
+
+// Checkpoint returns the checkpoint stored for partitionID
+func (s *Store) Checkpoint(partitionID string) (persist.Checkpoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoint, ok := s.checkpoints[partitionID]
+	return checkpoint, ok
+}
+
+// ReadCheckpoint returns the checkpoint stored for partitionID, so a Store can be reset with eventhub.ResetCheckpoints
+func (s *Store) ReadCheckpoint(_ context.Context, partitionID string) (persist.Checkpoint, bool, error) {
+	checkpoint, ok := s.Checkpoint(partitionID)
+	return checkpoint, ok, nil
+}
+
+// WriteCheckpoint stores checkpoint for partitionID unless a host holds the lease of the partition, like the
+// WriteCheckpoint of the storage checkpointer
+func (s *Store) WriteCheckpoint(_ context.Context, partitionID string, checkpoint persist.Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l, ok := s.leases[partitionID]; ok && s.isHeld(partitionID, l.lease.Owner) {
+		return fmt.Errorf("the lease of partition %q is held by %q", partitionID, l.lease.Owner)
+	}
+	s.checkpoints[partitionID] = checkpoint
+	return nil
+}
+
+// ExpireLease expires the lease of partitionID right away, as if its owner had stopped renewing it
+func (s *Store) ExpireLease(partitionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l, ok := s.leases[partitionID]; ok {
+		l.expiration = time.Time{}
+	}
+}
+
+func (s *Store) exists() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.created
+}
+
+func (s *Store) ensure() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.created = true
+}
+
+func (s *Store) delete() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.created = false
+	s.leases = make(map[string]*storedLease)
+	s.checkpoints = make(map[string]persist.Checkpoint)
+}
+
+// putCheckpoint stores checkpoint for partitionID if owner holds the lease of the partition
+func (s *Store) putCheckpoint(owner, partitionID string, checkpoint persist.Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isHeld(partitionID, owner) {
+		return fmt.Errorf("lease of partition %q isn't held by %q: %w", partitionID, owner, eph.ErrCheckpointConflict)
+	}
+	s.checkpoints[partitionID] = checkpoint
+	return nil
+}
+
+// IsExpired indicates the lease isn't held by its owner in the store anymore
+func (l *Lease) IsExpired(_ context.Context) bool {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	return !l.store.isHeld(l.PartitionID, l.Owner)
+}
+
+// isHeld tells if owner holds the lease of partitionID; the caller must hold s.mu
+func (s *Store) isHeld(partitionID, owner string) bool {
+	l, ok := s.leases[partitionID]
+	return ok && owner != "" && l.lease.Owner == owner && s.now().Before(l.expiration)
+}
+
+// lease returns a copy of the stored lease of partitionID; the caller must hold s.mu
+func (s *Store) lease(partitionID string) *Lease {
+	return &Lease{Lease: s.leases[partitionID].lease, store: s}
+}
+
+// partitionIDs returns the partitions with a lease in order; the caller must hold s.mu
+func (s *Store) partitionIDs() []string {
+	ids := make([]string, 0, len(s.leases))
+	for id := range s.leases {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,85 @@
+package eph
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+var (
+	// ErrLeaseLost is returned when the host no longer holds the lease of a partition, because the lease expired or
+	// another host took it over. The host stops receiving from the partition.
+	ErrLeaseLost = errors.New("lease of the partition was lost")
+
+	// ErrCheckpointConflict is returned by Checkpointers when a checkpoint is stored for a partition whose lease the
+	// host doesn't hold, so another host may be processing the partition and checkpointing it
+	ErrCheckpointConflict = errors.New("checkpoint conflicts with the owner of the partition lease")
+)
+
+// closeErrors collects the errors of closing the parts of a host into an eventhub.CloseError
+type closeErrors []error
+
+// add records that part failed to close with err, unless err is nil. The errors of a part which reports a
+// CloseError itself are recorded one by one.
+func (c *closeErrors) add(part string, err error) {
+	if err == nil {
+		return
+	}
+	if closeErr, ok := err.(*eventhub.CloseError); ok {
+		for _, e := range closeErr.Errors {
+			*c = append(*c, fmt.Errorf("%s: %w", part, e))
+		}
+		return
+	}
+	*c = append(*c, fmt.Errorf("%s: %w", part, err))
+}
+
+// err returns a CloseError of the errors recorded, or nil if there are none
+func (c closeErrors) err() error {
+	if len(c) == 0 {
+		return nil
+	}
+	return &eventhub.CloseError{Errors: c}
+}
+
+// SubscribeErrors returns a channel of the non-fatal errors the host handles internally: failures to list, acquire,
+// steal or renew partition leases and to start receivers, along with the errors of the host's Event Hub client. Up to
+// buffer events are queued for the subscriber; later events are dropped until it catches up. Call the returned
+// function to unsubscribe and close the channel.
+func (h *EventProcessorHost) SubscribeErrors(buffer int) (<-chan eventhub.ErrorEvent, func()) {
+	return h.errorStream.Subscribe(buffer)
+}
+
+// errorHubOptions returns the options which forward the errors of the host's Event Hub client to the host's
+// subscribers
+func (h *EventProcessorHost) errorHubOptions() []eventhub.HubOption {
+	return []eventhub.HubOption{eventhub.HubWithErrorListener(h.errorStream.Publish)}
+}
+
+// reportError publishes a non-fatal error concerning partitionID, if any, to the host's subscribers
+func (h *EventProcessorHost) reportError(typ eventhub.ErrorEventType, partitionID string, err error) {
+	h.errorStream.Publish(eventhub.ErrorEvent{Type: typ, Entity: partitionID, Err: err})
+}
@@ -0,0 +1,67 @@
+package eph
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/eventhubtest"
+)
+
+func TestEventProcessorHost_SubscribeErrors(t *testing.T) {
+	host := &EventProcessorHost{}
+	events, unsubscribe := host.SubscribeErrors(10)
+	defer unsubscribe()
+
+	host.reportError(eventhub.ErrorEventLeaseRenew, "3", errors.New("lease lost"))
+	event := <-events
+	assert.Equal(t, eventhub.ErrorEventLeaseRenew, event.Type)
+	assert.Equal(t, "3", event.Entity)
+
+	// errors of the host's Event Hub client are forwarded to the host's subscribers
+	opts := host.errorHubOptions()
+	require.Len(t, opts, 1)
+	assert.NoError(t, opts[0](new(eventhub.Hub)))
+}
+
+type failingCloseLeaser struct {
+	*memoryLeaserCheckpointer
+	err error
+}
+
+func (l *failingCloseLeaser) Close(context.Context) error {
+	return l.err
+}
+
+func TestEventProcessorHost_CloseReportsEachFailure(t *testing.T) {
+	ctx := context.Background()
+	broker, err := eventhubtest.NewBroker(eventhubtest.BrokerWithPartitionCount(1))
+	require.NoError(t, err)
+	client, err := broker.Hub("hub")
+	require.NoError(t, err)
+
+	unavailable := errors.New("storage unavailable")
+	leaser := &failingCloseLeaser{
+		memoryLeaserCheckpointer: newMemoryLeaserCheckpointer(DefaultLeaseDuration, new(sharedStore)),
+		err:                      unavailable,
+	}
+	host, err := NewWithClient(ctx, client, leaser, leaser, WithNoBanner())
+	require.NoError(t, err)
+
+	err = host.Close(ctx)
+	var closeErr *eventhub.CloseError
+	require.True(t, errors.As(err, &closeErr))
+	require.Len(t, closeErr.Errors, 2, "the leaser and the checkpointer fail, the client closes")
+	assert.True(t, errors.Is(err, unavailable))
+	assert.Contains(t, closeErr.Errors[0].Error(), "leaser: storage unavailable")
+	assert.Contains(t, closeErr.Errors[1].Error(), "checkpointer: storage unavailable")
+
+	var errs closeErrors
+	errs.add("client", &eventhub.CloseError{Errors: []error{unavailable, unavailable}})
+	assert.Len(t, errs, 2, "close errors of parts are flattened")
+	assert.NoError(t, closeErrors(nil).err())
+}
@@ -0,0 +1,120 @@
+package eph
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+type (
+	// PartitionLag describes how far the checkpoint of a partition trails the last event enqueued on it
+	PartitionLag struct {
+		PartitionID string
+		// Events is the number of events enqueued after the checkpoint
+		Events int64
+		// Time is the difference between the enqueue time of the last event and the enqueue time of the checkpoint. It
+		// is zero if the checkpoint does not record an enqueue time or there are no events after the checkpoint.
+		Time time.Duration
+		// HasCheckpoint is false if no checkpoint has been recorded for the partition, in which case the lag is
+		// measured from the beginning of the partition
+		HasCheckpoint      bool
+		Checkpoint         persist.Checkpoint
+		LastSequenceNumber int64
+		LastEnqueuedTime   time.Time
+	}
+)
+
+// ComputeLag compares the checkpoint of each partition with its runtime information and returns the lag keyed by
+// partition ID
+func ComputeLag(ctx context.Context, manager eventhub.Manager, checkpointer Checkpointer) (map[string]PartitionLag, error) {
+	span, ctx := startConsumerSpanFromContext(ctx, "eph.ComputeLag")
+	defer span.End()
+
+	hubInfo, err := manager.GetRuntimeInformation(ctx)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+
+	lags := make(map[string]PartitionLag, len(hubInfo.PartitionIDs))
+	for _, partitionID := range hubInfo.PartitionIDs {
+		info, err := manager.GetPartitionInformation(ctx, partitionID)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch runtime information for partition %q: %w", partitionID, err)
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+
+		checkpoint, ok := checkpointer.GetCheckpoint(ctx, partitionID)
+		lags[partitionID] = newPartitionLag(info, checkpoint, ok)
+	}
+	return lags, nil
+}
+
+// PartitionLag returns the lag of every partition of the Event Hub based on the checkpoints of the EventProcessorHost
+func (h *EventProcessorHost) PartitionLag(ctx context.Context) (map[string]PartitionLag, error) {
+	lags, err := ComputeLag(ctx, h.client, h.checkpointer)
+	if err != nil {
+		return nil, err
+	}
+
+	h.metrics.setLag(h, lags)
+	return lags, nil
+}
+
+func newPartitionLag(info *eventhub.HubPartitionRuntimeInformation, checkpoint persist.Checkpoint, hasCheckpoint bool) PartitionLag {
+	lag := PartitionLag{
+		PartitionID:        info.PartitionID,
+		HasCheckpoint:      hasCheckpoint,
+		Checkpoint:         checkpoint,
+		LastSequenceNumber: info.LastSequenceNumber,
+		LastEnqueuedTime:   info.LastEnqueuedTimeUtc,
+	}
+
+	switch {
+	case checkpoint.Offset == persist.EndOfStream:
+		// processing starts from the latest event, so nothing is outstanding
+		return lag
+	case !hasCheckpoint || checkpoint.Offset == persist.StartOfStream:
+		lag.Events = info.LastSequenceNumber - info.BeginningSequenceNumber + 1
+	default:
+		lag.Events = info.LastSequenceNumber - checkpoint.SequenceNumber
+	}
+
+	if lag.Events <= 0 {
+		lag.Events = 0
+		return lag
+	}
+
+	if !checkpoint.EnqueueTime.IsZero() && info.LastEnqueuedTimeUtc.After(checkpoint.EnqueueTime) {
+		lag.Time = info.LastEnqueuedTimeUtc.Sub(checkpoint.EnqueueTime)
+	}
+	return lag
+}
@@ -0,0 +1,72 @@
+package eph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+type (
+	fakeManager struct {
+		partitions map[string]*eventhub.HubPartitionRuntimeInformation
+	}
+
+	fakeCheckpointer struct {
+		Checkpointer
+		checkpoints map[string]persist.Checkpoint
+	}
+)
+
+func (m fakeManager) GetRuntimeInformation(context.Context) (*eventhub.HubRuntimeInformation, error) {
+	info := &eventhub.HubRuntimeInformation{PartitionCount: len(m.partitions)}
+	for id := range m.partitions {
+		info.PartitionIDs = append(info.PartitionIDs, id)
+	}
+	return info, nil
+}
+
+func (m fakeManager) GetPartitionInformation(_ context.Context, partitionID string) (*eventhub.HubPartitionRuntimeInformation, error) {
+	return m.partitions[partitionID], nil
+}
+
+func (c fakeCheckpointer) GetCheckpoint(_ context.Context, partitionID string) (persist.Checkpoint, bool) {
+	checkpoint, ok := c.checkpoints[partitionID]
+	if !ok {
+		return persist.NewCheckpointFromStartOfStream(), false
+	}
+	return checkpoint, true
+}
+
+func TestComputeLag(t *testing.T) {
+	now := time.Now()
+	manager := fakeManager{partitions: map[string]*eventhub.HubPartitionRuntimeInformation{
+		"0": {PartitionID: "0", BeginningSequenceNumber: 10, LastSequenceNumber: 109, LastEnqueuedTimeUtc: now},
+		"1": {PartitionID: "1", BeginningSequenceNumber: 0, LastSequenceNumber: 50, LastEnqueuedTimeUtc: now},
+		"2": {PartitionID: "2", BeginningSequenceNumber: 0, LastSequenceNumber: 20, LastEnqueuedTimeUtc: now},
+	}}
+	checkpointer := fakeCheckpointer{checkpoints: map[string]persist.Checkpoint{
+		"1": persist.NewCheckpoint("1024", 40, now.Add(-time.Minute)),
+		"2": persist.NewCheckpoint("2048", 20, now),
+	}}
+
+	lags, err := ComputeLag(context.Background(), manager, checkpointer)
+	require.NoError(t, err)
+	require.Len(t, lags, 3)
+
+	assert.False(t, lags["0"].HasCheckpoint)
+	assert.EqualValues(t, 100, lags["0"].Events, "without a checkpoint every retained event is outstanding")
+	assert.Zero(t, lags["0"].Time)
+
+	assert.True(t, lags["1"].HasCheckpoint)
+	assert.EqualValues(t, 10, lags["1"].Events)
+	assert.Equal(t, time.Minute, lags["1"].Time)
+
+	assert.Zero(t, lags["2"].Events, "caught up partitions have no lag")
+	assert.Zero(t, lags["2"].Time)
+}
@@ -25,7 +25,6 @@ package eph
 import (
 	"context"
 	"encoding/json"
-	"io"
 	"sync/atomic"
 )
 
@@ -44,7 +43,6 @@ type (
 
 	// Leaser provides the functionality needed to persist and coordinate leases for partitions
 	Leaser interface {
-		io.Closer
 		StoreProvisioner
 		EventProcessHostSetter
 		GetLeases(ctx context.Context) ([]LeaseMarker, error)
@@ -54,6 +52,8 @@ type (
 		RenewLease(ctx context.Context, partitionID string) (LeaseMarker, bool, error)
 		ReleaseLease(ctx context.Context, partitionID string) (bool, error)
 		UpdateLease(ctx context.Context, partitionID string) (LeaseMarker, bool, error)
+		// Close releases the resources of the leaser, waiting for them no longer than ctx allows
+		Close(ctx context.Context) error
 	}
 
 	// Lease represents the information needed to coordinate partitions
@@ -24,7 +24,6 @@ package eph
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"math/rand"
 	"time"
@@ -32,14 +31,17 @@ import (
 	"github.com/devigned/tab"
 
 	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
 )
 
 type (
 	leasedReceiver struct {
-		handle    *eventhub.ListenerHandle
-		processor *EventProcessorHost
-		lease     LeaseMarker
-		done      func()
+		handle     *eventhub.ListenerHandle
+		processor  *EventProcessorHost
+		lease      LeaseMarker
+		done       func()
+		keyed      *keyedProcessor
+		sinkWriter *sinkWriter
 	}
 )
 
@@ -58,19 +60,61 @@ func (lr *leasedReceiver) Run(ctx context.Context) error {
 	epoch := lr.lease.GetEpoch()
 	lr.dlog(ctx, "running...")
 
-	go func() {
-		ctx, done := context.WithCancel(context.Background())
+	if !lr.processor.scheduler.renewManually {
+		// done is set before the renewals start so Close can't miss it
+		renewCtx, done := context.WithCancel(context.Background())
 		lr.done = done
-		lr.periodicallyRenewLease(ctx)
-	}()
+		go lr.periodicallyRenewLease(renewCtx)
+	}
 
 	opts := []eventhub.ReceiveOption{eventhub.ReceiveWithEpoch(epoch)}
 	if lr.processor.consumerGroup != "" {
 		opts = append(opts, eventhub.ReceiveWithConsumerGroup(lr.processor.consumerGroup))
 	}
+	if lr.processor.eventPooling {
+		opts = append(opts, eventhub.ReceiveWithEventPooling())
+	}
+	if prefetch, ok := lr.processor.receivePrefetch(); ok {
+		opts = append(opts, prefetch)
+	}
+	if lr.processor.prefetchMax > 0 {
+		opts = append(opts, eventhub.ReceiveWithAdaptivePrefetch(lr.processor.prefetchMin, lr.processor.prefetchMax))
+	}
+
+	_, isHub := lr.processor.client.(*eventhub.Hub)
+	// the Hub runs the handler and retries its errors itself, unless the handler runs on the keyed workers or before
+	// the sink
+	handler := lr.retrying(lr.processor.compositeHandlers(), isHub && lr.processor.keyedWorkers == 0 && lr.processor.sink == nil)
+	if lr.processor.sink != nil {
+		if err := lr.recoverSink(ctx); err != nil {
+			return err
+		}
+	}
+	if !isHub {
+		// a Hub reads and writes checkpoints through the offset persister of the host, other clients can't
+		checkpoint, err := lr.processor.checkpointer.EnsureCheckpoint(ctx, partitionID)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, receiveAfter(checkpoint))
+	}
+	switch {
+	case lr.processor.sink != nil:
+		var sinkOpts []eventhub.ReceiveOption
+		handler, sinkOpts = lr.writeToSink(handler, isHub, epoch)
+		opts = append(opts, sinkOpts...)
+	case lr.processor.keyedWorkers > 0:
+		var keyedOpts []eventhub.ReceiveOption
+		handler, keyedOpts = lr.processKeyed(handler, isHub)
+		opts = append(opts, keyedOpts...)
+	case !isHub:
+		handler = lr.checkpointing(handler)
+	}
 
-	handle, err := lr.processor.client.Receive(ctx, partitionID, lr.processor.compositeHandlers(), opts...)
+	handle, err := lr.processor.client.Receive(ctx, partitionID, handler, opts...)
 	if err != nil {
+		lr.keyed.stop()
+		lr.sinkWriter.stop(ctx)
 		return err
 	}
 	lr.handle = handle
@@ -78,6 +122,30 @@ func (lr *leasedReceiver) Run(ctx context.Context) error {
 	return nil
 }
 
+// checkpointing records the checkpoint of each event handler handles without error
+func (lr *leasedReceiver) checkpointing(handler eventhub.Handler) eventhub.Handler {
+	return func(ctx context.Context, event *eventhub.Event) error {
+		if err := handler(ctx, event); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+		return lr.processor.updateCheckpoint(ctx, lr.processor.checkpointer, lr.lease.GetPartitionID(), event.GetCheckpoint())
+	}
+}
+
+// receiveAfter returns the receive option which starts receiving after checkpoint
+func receiveAfter(checkpoint persist.Checkpoint) eventhub.ReceiveOption {
+	if checkpoint.Offset == "" && !checkpoint.EnqueueTime.IsZero() {
+		return eventhub.ReceiveFromTimestamp(checkpoint.EnqueueTime)
+	}
+	if checkpoint.Offset == "" {
+		return eventhub.ReceiveWithStartingOffset(persist.StartOfStream)
+	}
+	return eventhub.ReceiveWithStartingOffset(checkpoint.Offset)
+}
+
 func (lr *leasedReceiver) Close(ctx context.Context) error {
 	span, ctx := lr.startConsumerSpanFromContext(ctx, "eph.leasedReceiver.Close")
 	defer span.End()
@@ -85,6 +153,8 @@ func (lr *leasedReceiver) Close(ctx context.Context) error {
 	if lr.done != nil {
 		lr.done()
 	}
+	defer lr.keyed.stop()
+	defer lr.sinkWriter.stop(ctx)
 
 	if lr.handle != nil {
 		return lr.handle.Close(ctx)
@@ -100,7 +170,7 @@ func (lr *leasedReceiver) listenForClose() {
 		defer cancel()
 		span, ctx := lr.startConsumerSpanFromContext(ctx, "eph.leasedReceiver.listenForClose")
 		defer span.End()
-		err := lr.processor.scheduler.stopReceiver(ctx, lr.lease)
+		err := lr.processor.scheduler.stopReceiver(ctx, lr)
 		if err != nil {
 			tab.For(ctx).Error(err)
 		}
@@ -118,26 +188,33 @@ func (lr *leasedReceiver) periodicallyRenewLease(ctx context.Context) {
 		default:
 			skew := time.Duration(rand.Intn(1000)-500) * time.Millisecond
 			time.Sleep(DefaultLeaseRenewalInterval + skew)
-			err := lr.tryRenew(ctx)
-			if err != nil {
-				tab.For(ctx).Error(err)
-				_ = lr.processor.scheduler.stopReceiver(ctx, lr.lease)
-			}
+			lr.renew(ctx)
 		}
 	}
 }
 
+// renew renews the lease of the receiver, and stops the receiver if the lease can't be renewed
+func (lr *leasedReceiver) renew(ctx context.Context) {
+	if err := lr.tryRenew(ctx); err != nil {
+		tab.For(ctx).Error(err)
+		lr.processor.log(ctx, eventhub.LogLevelWarn, "failed to renew lease; stopping receiver", "partitionID", lr.lease.GetPartitionID(), "epoch", lr.lease.GetEpoch(), "error", err)
+		lr.processor.reportError(eventhub.ErrorEventLeaseRenew, lr.lease.GetPartitionID(), err)
+		_ = lr.processor.scheduler.stopReceiver(ctx, lr)
+	}
+}
+
 func (lr *leasedReceiver) tryRenew(ctx context.Context) error {
 	span, ctx := lr.startConsumerSpanFromContext(ctx, "eph.leasedReceiver.tryRenew")
 	defer span.End()
+	ctx, requestID := eventhub.StartRequest(ctx, span)
 
 	lease, ok, err := lr.processor.leaser.RenewLease(ctx, lr.lease.GetPartitionID())
 	if err != nil {
 		tab.For(ctx).Error(err)
-		return err
+		return eventhub.NewRequestError(requestID, err)
 	}
 	if !ok {
-		err = errors.New("can't renew lease")
+		err = ErrLeaseLost
 		tab.For(ctx).Error(err)
 		return err
 	}
@@ -151,6 +228,7 @@ func (lr *leasedReceiver) dlog(ctx context.Context, msg string) {
 	partitionID := lr.lease.GetPartitionID()
 	epoch := lr.lease.GetEpoch()
 	tab.For(ctx).Debug(fmt.Sprintf("eph %q, partition %q, epoch %d: "+msg, name, partitionID, epoch))
+	lr.processor.log(ctx, eventhub.LogLevelDebug, msg, "partitionID", partitionID, "epoch", epoch)
 }
 
 func (lr *leasedReceiver) startConsumerSpanFromContext(ctx context.Context, operationName string) (tab.Spanner, context.Context) {
@@ -0,0 +1,58 @@
+package eph
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+// WithLogger will configure an EventProcessorHost to send lease and checkpoint failures, ownership changes and
+// diagnostic messages to logger. The Event Hub client of the host logs to the same logger.
+func WithLogger(logger eventhub.Logger) EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		if logger == nil {
+			return errors.New("logger must not be nil")
+		}
+		host.logger = logger
+		return nil
+	}
+}
+
+// loggerHubOptions returns the options which send the logs of the host's Event Hub client to the host's logger
+func (h *EventProcessorHost) loggerHubOptions() []eventhub.HubOption {
+	if h.logger == nil {
+		return nil
+	}
+	return []eventhub.HubOption{eventhub.HubWithLogger(h.logger)}
+}
+
+// log writes an entry labeled with the host's name, if the host has a logger
+func (h *EventProcessorHost) log(ctx context.Context, level eventhub.LogLevel, msg string, keysAndValues ...interface{}) {
+	if h.logger == nil {
+		return
+	}
+	h.logger.Log(ctx, level, msg, append([]interface{}{"host", h.name, "hub", h.hubName}, keysAndValues...)...)
+}
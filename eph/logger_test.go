@@ -0,0 +1,30 @@
+package eph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+func TestWithLogger(t *testing.T) {
+	var logged [][]interface{}
+	logger := eventhub.LoggerFunc(func(_ context.Context, _ eventhub.LogLevel, _ string, keysAndValues ...interface{}) {
+		logged = append(logged, keysAndValues)
+	})
+
+	host := &EventProcessorHost{name: "host-1", hubName: "hub"}
+	host.log(context.Background(), eventhub.LogLevelWarn, "dropped without a logger")
+	assert.Empty(t, host.loggerHubOptions())
+
+	assert.Error(t, WithLogger(nil)(host))
+	require.NoError(t, WithLogger(logger)(host))
+	assert.Len(t, host.loggerHubOptions(), 1)
+
+	host.log(context.Background(), eventhub.LogLevelWarn, "failed to renew lease", "partitionID", "0")
+	require.Len(t, logged, 1)
+	assert.Equal(t, []interface{}{"host", "host-1", "hub", "hub", "partitionID", "0"}, logged[0])
+}
@@ -25,7 +25,9 @@ package eph
 import (
 	"context"
 	"errors"
+	"hash/fnv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Azure/azure-amqp-common-go/v3/uuid"
@@ -34,13 +36,22 @@ import (
 	"github.com/Azure/azure-event-hubs-go/v3/persist"
 )
 
+// leaseShards is the number of shards the leases of the in-memory leaser and its store are spread over, so that hosts
+// with many partitions don't serialize every lease operation of a scheduler scan on a single lock
+const leaseShards = 16
+
 type (
 	memoryLeaserCheckpointer struct {
 		store         *sharedStore
 		processor     *EventProcessorHost
 		leaseDuration time.Duration
-		memMu         sync.Mutex
-		leases        map[string]*memoryLease
+		shards        [leaseShards]memoryLeaseShard
+	}
+
+	// memoryLeaseShard holds the leases owned by a leaser for the partitions which hash to the shard
+	memoryLeaseShard struct {
+		mu     sync.Mutex
+		leases map[string]*memoryLease
 	}
 
 	memoryLease struct {
@@ -51,9 +62,16 @@ type (
 		leaser         *memoryLeaserCheckpointer
 	}
 
+	// sharedStore is the store shared by the in-memory leasers of hosts in the same process. Operations on the lease
+	// of a partition lock the shard of the partition only.
 	sharedStore struct {
-		leases  map[string]*storeLease
-		storeMu sync.Mutex
+		created int32
+		shards  [leaseShards]storeShard
+	}
+
+	storeShard struct {
+		mu     sync.Mutex
+		leases map[string]*storeLease
 	}
 
 	storeLease struct {
@@ -69,46 +87,60 @@ func newMemoryLease(partitionID string) *memoryLease {
 	return lease
 }
 
-func (s *sharedStore) exists() bool {
-	s.storeMu.Lock()
-	defer s.storeMu.Unlock()
+// leaseShard returns the shard of the lease of a partition
+func leaseShard(partitionID string) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(partitionID))
+	return int(hash.Sum32() % leaseShards)
+}
 
-	return s.leases != nil
+// shard locks and returns the shard of partitionID
+func (s *sharedStore) shard(partitionID string) *storeShard {
+	shard := &s.shards[leaseShard(partitionID)]
+	shard.mu.Lock()
+	return shard
 }
 
-func (s *sharedStore) ensure() bool {
-	s.storeMu.Lock()
-	defer s.storeMu.Unlock()
+func (s *sharedStore) exists() bool {
+	return atomic.LoadInt32(&s.created) == 1
+}
 
-	if s.leases == nil {
-		s.leases = make(map[string]*storeLease)
+func (s *sharedStore) ensure() bool {
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		if shard.leases == nil {
+			shard.leases = make(map[string]*storeLease)
+		}
+		shard.mu.Unlock()
 	}
+	atomic.StoreInt32(&s.created, 1)
 	return true
 }
 
 func (s *sharedStore) getLease(partitionID string) memoryLease {
-	s.storeMu.Lock()
-	defer s.storeMu.Unlock()
+	shard := s.shard(partitionID)
+	defer shard.mu.Unlock()
 
-	return *s.leases[partitionID].ml
+	return *shard.leases[partitionID].ml
 }
 
 func (s *sharedStore) deleteLease(partitionID string) {
-	s.storeMu.Lock()
-	defer s.storeMu.Unlock()
+	shard := s.shard(partitionID)
+	defer shard.mu.Unlock()
 
-	delete(s.leases, partitionID)
+	delete(shard.leases, partitionID)
 }
 
 func (s *sharedStore) createOrGetLease(partitionID string) memoryLease {
-	s.storeMu.Lock()
-	defer s.storeMu.Unlock()
+	shard := s.shard(partitionID)
+	defer shard.mu.Unlock()
 
-	if _, ok := s.leases[partitionID]; !ok {
-		s.leases[partitionID] = new(storeLease)
+	if _, ok := shard.leases[partitionID]; !ok {
+		shard.leases[partitionID] = new(storeLease)
 	}
 
-	l := s.leases[partitionID]
+	l := shard.leases[partitionID]
 	if l.ml != nil {
 		return *l.ml
 	}
@@ -117,10 +149,10 @@ func (s *sharedStore) createOrGetLease(partitionID string) memoryLease {
 }
 
 func (s *sharedStore) changeLease(partitionID, newToken, oldToken string, duration time.Duration) bool {
-	s.storeMu.Lock()
-	defer s.storeMu.Unlock()
+	shard := s.shard(partitionID)
+	defer shard.mu.Unlock()
 
-	if l, ok := s.leases[partitionID]; ok && l.token == oldToken {
+	if l, ok := shard.leases[partitionID]; ok && l.token == oldToken {
 		l.token = newToken
 		l.expiration = time.Now().Add(duration)
 		return true
@@ -129,10 +161,10 @@ func (s *sharedStore) changeLease(partitionID, newToken, oldToken string, durati
 }
 
 func (s *sharedStore) releaseLease(partitionID, token string) bool {
-	s.storeMu.Lock()
-	defer s.storeMu.Unlock()
+	shard := s.shard(partitionID)
+	defer shard.mu.Unlock()
 
-	if l, ok := s.leases[partitionID]; ok && l.token == token {
+	if l, ok := shard.leases[partitionID]; ok && l.token == token {
 		l.token = ""
 		l.expiration = time.Now().Add(-1 * time.Second)
 		return true
@@ -141,10 +173,10 @@ func (s *sharedStore) releaseLease(partitionID, token string) bool {
 }
 
 func (s *sharedStore) renewLease(partitionID, token string, duration time.Duration) bool {
-	s.storeMu.Lock()
-	defer s.storeMu.Unlock()
+	shard := s.shard(partitionID)
+	defer shard.mu.Unlock()
 
-	if l, ok := s.leases[partitionID]; ok && l.token == token {
+	if l, ok := shard.leases[partitionID]; ok && l.token == token {
 		l.expiration = time.Now().Add(duration)
 		return true
 	}
@@ -152,10 +184,10 @@ func (s *sharedStore) renewLease(partitionID, token string, duration time.Durati
 }
 
 func (s *sharedStore) acquireLease(partitionID, newToken string, duration time.Duration) bool {
-	s.storeMu.Lock()
-	defer s.storeMu.Unlock()
+	shard := s.shard(partitionID)
+	defer shard.mu.Unlock()
 
-	if l, ok := s.leases[partitionID]; ok && (time.Now().After(l.expiration) || l.token == "") {
+	if l, ok := shard.leases[partitionID]; ok && (time.Now().After(l.expiration) || l.token == "") {
 		l.token = newToken
 		l.expiration = time.Now().Add(duration)
 		return true
@@ -164,10 +196,10 @@ func (s *sharedStore) acquireLease(partitionID, newToken string, duration time.D
 }
 
 func (s *sharedStore) storeLease(partitionID, token string, ml memoryLease) bool {
-	s.storeMu.Lock()
-	defer s.storeMu.Unlock()
+	shard := s.shard(partitionID)
+	defer shard.mu.Unlock()
 
-	if l, ok := s.leases[partitionID]; ok && l.token == token {
+	if l, ok := shard.leases[partitionID]; ok && l.token == token {
 		l.ml = &ml
 		return true
 	}
@@ -175,10 +207,10 @@ func (s *sharedStore) storeLease(partitionID, token string, ml memoryLease) bool
 }
 
 func (s *sharedStore) isLeased(partitionID string) bool {
-	s.storeMu.Lock()
-	defer s.storeMu.Unlock()
+	shard := s.shard(partitionID)
+	defer shard.mu.Unlock()
 
-	if l, ok := s.leases[partitionID]; ok {
+	if l, ok := shard.leases[partitionID]; ok {
 		if time.Now().After(l.expiration) || l.token == "" {
 			return false
 		}
@@ -204,11 +236,20 @@ func (l *memoryLease) expireAfter(d time.Duration) {
 func newMemoryLeaserCheckpointer(leaseDuration time.Duration, store *sharedStore) *memoryLeaserCheckpointer {
 	return &memoryLeaserCheckpointer{
 		leaseDuration: leaseDuration,
-		leases:        make(map[string]*memoryLease),
 		store:         store,
 	}
 }
 
+// shard locks and returns the shard of the leases owned by the leaser for partitionID, creating it on first use
+func (ml *memoryLeaserCheckpointer) shard(partitionID string) *memoryLeaseShard {
+	shard := &ml.shards[leaseShard(partitionID)]
+	shard.mu.Lock()
+	if shard.leases == nil {
+		shard.leases = make(map[string]*memoryLease)
+	}
+	return shard
+}
+
 func (ml *memoryLeaserCheckpointer) SetEventHostProcessor(eph *EventProcessorHost) {
 	ml.processor = eph
 }
@@ -236,9 +277,6 @@ func (ml *memoryLeaserCheckpointer) DeleteStore(ctx context.Context) error {
 }
 
 func (ml *memoryLeaserCheckpointer) GetLeases(ctx context.Context) ([]LeaseMarker, error) {
-	ml.memMu.Lock()
-	defer ml.memMu.Unlock()
-
 	span, _ := startConsumerSpanFromContext(ctx, "eph.memoryLeaserCheckpointer.GetLeases")
 	defer span.End()
 
@@ -253,11 +291,12 @@ func (ml *memoryLeaserCheckpointer) GetLeases(ctx context.Context) ([]LeaseMarke
 }
 
 func (ml *memoryLeaserCheckpointer) EnsureLease(ctx context.Context, partitionID string) (LeaseMarker, error) {
-	ml.memMu.Lock()
-	defer ml.memMu.Unlock()
+	shard := ml.shard(partitionID)
+	defer shard.mu.Unlock()
 
 	span, _ := startConsumerSpanFromContext(ctx, "eph.memoryLeaserCheckpointer.EnsureLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	l := ml.store.createOrGetLease(partitionID)
 	l.leaser = ml
@@ -265,22 +304,24 @@ func (ml *memoryLeaserCheckpointer) EnsureLease(ctx context.Context, partitionID
 }
 
 func (ml *memoryLeaserCheckpointer) DeleteLease(ctx context.Context, partitionID string) error {
-	ml.memMu.Lock()
-	defer ml.memMu.Unlock()
+	shard := ml.shard(partitionID)
+	defer shard.mu.Unlock()
 
 	span, _ := startConsumerSpanFromContext(ctx, "eph.memoryLeaserCheckpointer.DeleteLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	ml.store.deleteLease(partitionID)
 	return nil
 }
 
 func (ml *memoryLeaserCheckpointer) AcquireLease(ctx context.Context, partitionID string) (LeaseMarker, bool, error) {
-	ml.memMu.Lock()
-	defer ml.memMu.Unlock()
+	shard := ml.shard(partitionID)
+	defer shard.mu.Unlock()
 
 	span, ctx := startConsumerSpanFromContext(ctx, "eph.memoryLeaserCheckpointer.AcquireLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	lease := ml.store.getLease(partitionID)
 	lease.leaser = ml
@@ -308,36 +349,38 @@ func (ml *memoryLeaserCheckpointer) AcquireLease(ctx context.Context, partitionI
 	if !ml.store.storeLease(partitionID, newToken, lease) {
 		return nil, false, errors.New("failed to store lease after acquiring or changing")
 	}
-	ml.leases[partitionID] = &lease
+	shard.leases[partitionID] = &lease
 	return &lease, true, nil
 }
 
 func (ml *memoryLeaserCheckpointer) RenewLease(ctx context.Context, partitionID string) (LeaseMarker, bool, error) {
-	ml.memMu.Lock()
-	defer ml.memMu.Unlock()
+	shard := ml.shard(partitionID)
+	defer shard.mu.Unlock()
 
 	span, _ := startConsumerSpanFromContext(ctx, "eph.memoryLeaserCheckpointer.RenewLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
-	lease, ok := ml.leases[partitionID]
+	lease, ok := shard.leases[partitionID]
 	if !ok {
 		return nil, false, errors.New("lease was not found")
 	}
 
 	if !ml.store.renewLease(partitionID, lease.Token, ml.leaseDuration) {
-		return nil, false, errors.New("unable to renew lease")
+		return nil, false, ErrLeaseLost
 	}
 	return lease, true, nil
 }
 
 func (ml *memoryLeaserCheckpointer) ReleaseLease(ctx context.Context, partitionID string) (bool, error) {
-	ml.memMu.Lock()
-	defer ml.memMu.Unlock()
+	shard := ml.shard(partitionID)
+	defer shard.mu.Unlock()
 
 	span, _ := startConsumerSpanFromContext(ctx, "eph.memoryLeaserCheckpointer.ReleaseLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
-	lease, ok := ml.leases[partitionID]
+	lease, ok := shard.leases[partitionID]
 	if !ok {
 		return false, errors.New("lease was not found")
 	}
@@ -345,21 +388,25 @@ func (ml *memoryLeaserCheckpointer) ReleaseLease(ctx context.Context, partitionI
 	if !ml.store.releaseLease(partitionID, lease.Token) {
 		return false, errors.New("could not release the lease")
 	}
-	delete(ml.leases, partitionID)
+	delete(shard.leases, partitionID)
 	return true, nil
 }
 
 func (ml *memoryLeaserCheckpointer) UpdateLease(ctx context.Context, partitionID string) (LeaseMarker, bool, error) {
+	shard := ml.shard(partitionID)
+	defer shard.mu.Unlock()
+
 	span, _ := startConsumerSpanFromContext(ctx, "eph.memoryLeaserCheckpointer.UpdateLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
-	lease, ok := ml.leases[partitionID]
+	lease, ok := shard.leases[partitionID]
 	if !ok {
 		return nil, false, errors.New("lease was not found")
 	}
 
 	if !ml.store.renewLease(partitionID, lease.Token, ml.leaseDuration) {
-		return nil, false, errors.New("unable to renew lease")
+		return nil, false, ErrLeaseLost
 	}
 
 	if !ml.store.storeLease(partitionID, lease.Token, *lease) {
@@ -370,27 +417,29 @@ func (ml *memoryLeaserCheckpointer) UpdateLease(ctx context.Context, partitionID
 }
 
 func (ml *memoryLeaserCheckpointer) GetCheckpoint(ctx context.Context, partitionID string) (persist.Checkpoint, bool) {
-	ml.memMu.Lock()
-	defer ml.memMu.Unlock()
+	shard := ml.shard(partitionID)
+	defer shard.mu.Unlock()
 
 	span, _ := startConsumerSpanFromContext(ctx, "eph.memoryCheckpointer.GetCheckpoint")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
-	lease, ok := ml.leases[partitionID]
-	if ok {
+	lease, ok := shard.leases[partitionID]
+	if ok && lease.Checkpoint != nil {
 		return *lease.Checkpoint, ok
 	}
 	return persist.NewCheckpointFromStartOfStream(), ok
 }
 
 func (ml *memoryLeaserCheckpointer) EnsureCheckpoint(ctx context.Context, partitionID string) (persist.Checkpoint, error) {
-	ml.memMu.Lock()
-	defer ml.memMu.Unlock()
+	shard := ml.shard(partitionID)
+	defer shard.mu.Unlock()
 
 	span, _ := startConsumerSpanFromContext(ctx, "eph.memoryCheckpointer.EnsureCheckpoint")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
-	lease, ok := ml.leases[partitionID]
+	lease, ok := shard.leases[partitionID]
 	if ok {
 		if lease.Checkpoint == nil {
 			checkpoint := persist.NewCheckpointFromStartOfStream()
@@ -402,45 +451,47 @@ func (ml *memoryLeaserCheckpointer) EnsureCheckpoint(ctx context.Context, partit
 }
 
 func (ml *memoryLeaserCheckpointer) UpdateCheckpoint(ctx context.Context, partitionID string, checkpoint persist.Checkpoint) error {
-	ml.memMu.Lock()
-	defer ml.memMu.Unlock()
+	shard := ml.shard(partitionID)
+	defer shard.mu.Unlock()
 
 	span, _ := startConsumerSpanFromContext(ctx, "eph.memoryCheckpointer.UpdateCheckpoint")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
-	lease, ok := ml.leases[partitionID]
+	lease, ok := shard.leases[partitionID]
 	if !ok {
-		return errors.New("lease for partition isn't owned by this EventProcessorHost")
+		return ErrCheckpointConflict
 	}
 
 	lease.Checkpoint = &checkpoint
 	if !ml.store.storeLease(partitionID, lease.Token, *lease) {
-		return errors.New("could not store lease on update of checkpoint")
+		return ErrCheckpointConflict
 	}
 	return nil
 }
 
 func (ml *memoryLeaserCheckpointer) DeleteCheckpoint(ctx context.Context, partitionID string) error {
-	ml.memMu.Lock()
-	defer ml.memMu.Unlock()
+	shard := ml.shard(partitionID)
+	defer shard.mu.Unlock()
 
 	span, _ := startConsumerSpanFromContext(ctx, "eph.memoryCheckpointer.DeleteCheckpoint")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
-	lease, ok := ml.leases[partitionID]
+	lease, ok := shard.leases[partitionID]
 	if !ok {
-		return errors.New("lease for partition isn't owned by this EventProcessorHost")
+		return ErrCheckpointConflict
 	}
 
 	checkpoint := persist.NewCheckpointFromStartOfStream()
 	lease.Checkpoint = &checkpoint
 	if !ml.store.storeLease(partitionID, lease.Token, *lease) {
-		return errors.New("failed to store deleted checkpoint")
+		return ErrCheckpointConflict
 	}
-	ml.leases[partitionID] = lease
+	shard.leases[partitionID] = lease
 	return nil
 }
 
-func (ml *memoryLeaserCheckpointer) Close() error {
+func (ml *memoryLeaserCheckpointer) Close(_ context.Context) error {
 	return nil
 }
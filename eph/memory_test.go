@@ -0,0 +1,137 @@
+package eph
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+func newTestMemoryLeaser(t testing.TB, store *sharedStore, name string, partitionIDs []string) *memoryLeaserCheckpointer {
+	host := &EventProcessorHost{name: name, partitionIDs: partitionIDs}
+	leaser := newMemoryLeaserCheckpointer(DefaultLeaseDuration, store)
+	leaser.SetEventHostProcessor(host)
+	require.NoError(t, leaser.EnsureStore(context.Background()))
+	return leaser
+}
+
+func testPartitionIDs(count int) []string {
+	partitionIDs := make([]string, count)
+	for i := range partitionIDs {
+		partitionIDs[i] = strconv.Itoa(i)
+	}
+	return partitionIDs
+}
+
+func TestLeaseShard_SpreadsPartitions(t *testing.T) {
+	seen := make(map[int]int)
+	for _, partitionID := range testPartitionIDs(256) {
+		shard := leaseShard(partitionID)
+		require.True(t, shard >= 0 && shard < leaseShards)
+		assert.Equal(t, shard, leaseShard(partitionID))
+		seen[shard]++
+	}
+	assert.Len(t, seen, leaseShards)
+}
+
+func TestMemoryLeaser_ConcurrentHostsOwnEachPartitionOnce(t *testing.T) {
+	ctx := context.Background()
+	partitionIDs := testPartitionIDs(128)
+	store := new(sharedStore)
+	leasers := []*memoryLeaserCheckpointer{
+		newTestMemoryLeaser(t, store, "host-1", partitionIDs),
+		newTestMemoryLeaser(t, store, "host-2", partitionIDs),
+	}
+	assert.True(t, store.exists())
+
+	for _, partitionID := range partitionIDs {
+		_, err := leasers[0].EnsureLease(ctx, partitionID)
+		require.NoError(t, err)
+	}
+
+	var acquired int32
+	wg := new(sync.WaitGroup)
+	for _, leaser := range leasers {
+		for _, partitionID := range partitionIDs {
+			wg.Add(1)
+			go func(leaser *memoryLeaserCheckpointer, partitionID string) {
+				defer wg.Done()
+				if store.isLeased(partitionID) {
+					return
+				}
+				if _, ok, err := leaser.AcquireLease(ctx, partitionID); err == nil && ok {
+					atomic.AddInt32(&acquired, 1)
+				}
+			}(leaser, partitionID)
+		}
+	}
+	wg.Wait()
+	assert.True(t, int(acquired) >= len(partitionIDs))
+
+	leases, err := leasers[0].GetLeases(ctx)
+	require.NoError(t, err)
+	require.Len(t, leases, len(partitionIDs))
+	for _, lease := range leases {
+		assert.False(t, lease.IsExpired(ctx), lease.GetPartitionID())
+		owner := lease.GetOwner()
+		assert.Contains(t, []string{"host-1", "host-2"}, owner)
+
+		// only the owner of the lease in the store can renew it
+		for _, leaser := range leasers {
+			_, ok, _ := leaser.RenewLease(ctx, lease.GetPartitionID())
+			assert.Equal(t, leaser.processor.GetName() == owner, ok, lease.GetPartitionID())
+		}
+	}
+}
+
+func BenchmarkMemoryLeaser_RenewLease(b *testing.B) {
+	ctx := context.Background()
+	partitionIDs := testPartitionIDs(256)
+	leaser := newTestMemoryLeaser(b, new(sharedStore), "host", partitionIDs)
+	for _, partitionID := range partitionIDs {
+		_, err := leaser.EnsureLease(ctx, partitionID)
+		require.NoError(b, err)
+		_, _, err = leaser.AcquireLease(ctx, partitionID)
+		require.NoError(b, err)
+	}
+
+	var next uint32
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			partitionID := partitionIDs[atomic.AddUint32(&next, 1)%uint32(len(partitionIDs))]
+			if _, _, err := leaser.RenewLease(ctx, partitionID); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func TestMemoryLeaser_SentinelErrors(t *testing.T) {
+	ctx := context.Background()
+	store := new(sharedStore)
+	first := newTestMemoryLeaser(t, store, "host-1", []string{"0"})
+	second := newTestMemoryLeaser(t, store, "host-2", []string{"0"})
+	_, err := first.EnsureLease(ctx, "0")
+	require.NoError(t, err)
+	_, ok, err := first.AcquireLease(ctx, "0")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	assert.True(t, errors.Is(second.UpdateCheckpoint(ctx, "0", persist.NewCheckpointFromStartOfStream()), ErrCheckpointConflict))
+
+	_, ok, err = second.AcquireLease(ctx, "0")
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, _, err = first.RenewLease(ctx, "0")
+	assert.True(t, errors.Is(err, ErrLeaseLost), "a stolen lease is lost")
+	assert.True(t, errors.Is(first.UpdateCheckpoint(ctx, "0", persist.NewCheckpointFromStartOfStream()), ErrCheckpointConflict))
+}
@@ -0,0 +1,146 @@
+package eph
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/metrics"
+)
+
+type (
+	// hostMetrics records partition ownership and consumer lag of an EventProcessorHost. A nil *hostMetrics records
+	// nothing.
+	hostMetrics struct {
+		owned      *metrics.GaugeVec
+		lagEvents  *metrics.GaugeVec
+		lagSeconds *metrics.GaugeVec
+	}
+)
+
+// WithMetrics will configure an EventProcessorHost to record the number of partitions it owns and, whenever
+// PartitionLag is called, the lag of each partition in registry. The Event Hub client of the host records its
+// receive and recovery metrics in the same registry.
+func WithMetrics(registry *metrics.Registry) EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		if registry == nil {
+			return errors.New("metrics registry must not be nil")
+		}
+
+		host.metricsRegistry = registry
+		if host.metrics == nil {
+			host.metrics = new(hostMetrics)
+		}
+		host.metrics.owned = registry.Gauge("eventhub_eph_owned_partitions", "Partitions whose lease is held by the host.", "namespace", "hub", "consumer_group", "host")
+		host.metrics.registerLag(registry)
+		return nil
+	}
+}
+
+// WithLagReporting will configure an EventProcessorHost to compute the lag of every partition of the Event Hub
+// behind its checkpoint every interval while the host runs, publishing it as the eventhub_consumer_lag_events and
+// eventhub_consumer_lag_seconds gauges in registry. Each report makes a management request per partition, so the
+// interval should be no shorter than a few seconds.
+func WithLagReporting(registry *metrics.Registry, interval time.Duration) EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		if registry == nil {
+			return errors.New("metrics registry must not be nil")
+		}
+		if interval <= 0 {
+			return fmt.Errorf("lag reporting interval must be positive, got %v", interval)
+		}
+
+		host.lagInterval = interval
+		if host.metrics == nil {
+			host.metrics = new(hostMetrics)
+		}
+		host.metrics.registerLag(registry)
+		return nil
+	}
+}
+
+func (m *hostMetrics) registerLag(registry *metrics.Registry) {
+	m.lagEvents = registry.Gauge("eventhub_consumer_lag_events", "Events enqueued after the checkpoint of the partition.", "namespace", "hub", "consumer_group", "partition")
+	m.lagSeconds = registry.Gauge("eventhub_consumer_lag_seconds", "Enqueue time of the last event of the partition less the enqueue time of its checkpoint.", "namespace", "hub", "consumer_group", "partition")
+}
+
+// startLagReporting publishes the lag of every partition each lagInterval until stopLagReporting is called
+func (h *EventProcessorHost) startLagReporting(ctx context.Context) {
+	reportCtx, cancel := context.WithCancel(tab.NewContext(context.Background(), tab.FromContext(ctx)))
+	h.stopLagReporting = cancel
+
+	go func() {
+		ticker := time.NewTicker(h.lagInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-reportCtx.Done():
+				return
+			case <-ticker.C:
+				if _, err := h.PartitionLag(reportCtx); err != nil && reportCtx.Err() == nil {
+					tab.For(reportCtx).Error(err)
+					h.log(reportCtx, eventhub.LogLevelWarn, "failed to compute partition lag", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// metricsHubOptions returns the options which record the metrics of the host's Event Hub client
+func (h *EventProcessorHost) metricsHubOptions() []eventhub.HubOption {
+	if h.metricsRegistry == nil {
+		return nil
+	}
+	return []eventhub.HubOption{eventhub.HubWithMetrics(h.metricsRegistry)}
+}
+
+func (h *EventProcessorHost) consumerGroupName() string {
+	if h.consumerGroup == "" {
+		return eventhub.DefaultConsumerGroup
+	}
+	return h.consumerGroup
+}
+
+func (m *hostMetrics) setOwned(h *EventProcessorHost, owned int) {
+	if m == nil || m.owned == nil {
+		return
+	}
+	m.owned.With(h.namespace, h.hubName, h.consumerGroupName(), h.name).Set(float64(owned))
+}
+
+func (m *hostMetrics) setLag(h *EventProcessorHost, lags map[string]PartitionLag) {
+	if m == nil || m.lagEvents == nil {
+		return
+	}
+
+	for partitionID, lag := range lags {
+		m.lagEvents.With(h.namespace, h.hubName, h.consumerGroupName(), partitionID).Set(float64(lag.Events))
+		m.lagSeconds.With(h.namespace, h.hubName, h.consumerGroupName(), partitionID).Set(lag.Time.Seconds())
+	}
+}
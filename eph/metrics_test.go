@@ -0,0 +1,49 @@
+package eph
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/metrics"
+)
+
+func TestWithMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	host := &EventProcessorHost{namespace: "ns", hubName: "hub", name: "host-1"}
+	assert.Error(t, WithMetrics(nil)(host))
+	require.NoError(t, WithMetrics(registry)(host))
+	assert.Len(t, host.metricsHubOptions(), 1)
+
+	host.metrics.setOwned(host, 3)
+	host.metrics.setLag(host, map[string]PartitionLag{
+		"0": {PartitionID: "0", Events: 42, Time: 90 * time.Second},
+	})
+
+	var sb strings.Builder
+	require.NoError(t, registry.WriteText(&sb))
+	assert.Contains(t, sb.String(), `eventhub_eph_owned_partitions{namespace="ns",hub="hub",consumer_group="$Default",host="host-1"} 3`+"\n")
+	assert.Contains(t, sb.String(), `eventhub_consumer_lag_events{namespace="ns",hub="hub",consumer_group="$Default",partition="0"} 42`+"\n")
+	assert.Contains(t, sb.String(), `eventhub_consumer_lag_seconds{namespace="ns",hub="hub",consumer_group="$Default",partition="0"} 90`+"\n")
+}
+
+func TestWithLagReporting(t *testing.T) {
+	registry := metrics.NewRegistry()
+	host := &EventProcessorHost{namespace: "ns", hubName: "hub", name: "host-1"}
+	assert.Error(t, WithLagReporting(nil, time.Second)(host))
+	assert.Error(t, WithLagReporting(registry, 0)(host))
+	require.NoError(t, WithLagReporting(registry, time.Minute)(host))
+	assert.Equal(t, time.Minute, host.lagInterval)
+
+	// ownership isn't recorded without WithMetrics, but lag is
+	host.metrics.setOwned(host, 3)
+	host.metrics.setLag(host, map[string]PartitionLag{"0": {PartitionID: "0", Events: 7}})
+
+	var sb strings.Builder
+	require.NoError(t, registry.WriteText(&sb))
+	assert.NotContains(t, sb.String(), "eventhub_eph_owned_partitions{")
+	assert.Contains(t, sb.String(), `eventhub_consumer_lag_events{namespace="ns",hub="hub",consumer_group="$Default",partition="0"} 7`+"\n")
+}
@@ -0,0 +1,206 @@
+package eph
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+const keyedQueueLength = 64
+
+type (
+	// keyedProcessor runs the handler of the events of a partition on a set of workers, each event on the worker its
+	// partition key hashes to, so events with the same key are handled one after the other in the order they were
+	// received. Events without a key are spread over the workers in turn. The checkpoint of the partition advances as
+	// the events are handled, through a checkpoint manager.
+	keyedProcessor struct {
+		handler eventhub.Handler
+		manager *eventhub.CheckpointManager
+		onError func(ctx context.Context, err error)
+		queues  []chan keyedJob
+		cursor  uint32
+		quit    chan struct{}
+		stopped sync.Once
+	}
+
+	keyedJob struct {
+		ctx   context.Context
+		event *eventhub.Event
+	}
+)
+
+// WithKeyOrderedProcessing handles the events of each partition on workers goroutines rather than one at a time, to
+// parallelize handlers bound by CPU. Events with the same partition key go to the same worker, so they are handled in
+// the order they were received; events without a key may be handled in any order. As events complete out of order,
+// the checkpoint of a partition only advances through the events which have all been handled, so none is skipped
+// after a restart. As without key ordered processing, an event whose handler fails is reported and then completed, so
+// it doesn't hold the checkpoint back.
+func WithKeyOrderedProcessing(workers int) EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		if workers < 1 {
+			return fmt.Errorf("key ordered processing requires at least 1 worker, got %d", workers)
+		}
+		host.keyedWorkers = workers
+		return nil
+	}
+}
+
+func newKeyedProcessor(workers int, handler eventhub.Handler, manager *eventhub.CheckpointManager, onError func(ctx context.Context, err error)) *keyedProcessor {
+	p := &keyedProcessor{
+		handler: handler,
+		manager: manager,
+		onError: onError,
+		queues:  make([]chan keyedJob, workers),
+		quit:    make(chan struct{}),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan keyedJob, keyedQueueLength)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+// handle queues the event on the worker of its partition key. It blocks while the queue of the worker is full, which
+// throttles the receiver to the pace of the workers.
+func (p *keyedProcessor) handle(ctx context.Context, event *eventhub.Event) error {
+	select {
+	case <-p.quit:
+		return errors.New("receiver is closing")
+	default:
+	}
+
+	// pooled events outlive the receiver's handler call
+	event.Retain()
+	queue := p.queues[p.worker(event)]
+	select {
+	case queue <- keyedJob{ctx: ctx, event: event}:
+		// the processor may have stopped, and dropped the queue, while the job was being queued
+		select {
+		case <-p.quit:
+			drop(queue)
+		default:
+		}
+		return nil
+	case <-p.quit:
+		event.Release()
+		return errors.New("receiver is closing")
+	}
+}
+
+// worker returns the index of the worker for the event
+func (p *keyedProcessor) worker(event *eventhub.Event) int {
+	if key, ok := event.GetPartitionKey(); ok {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(key))
+		return int(hash.Sum32() % uint32(len(p.queues)))
+	}
+	return int(atomic.AddUint32(&p.cursor, 1) % uint32(len(p.queues)))
+}
+
+func (p *keyedProcessor) work(queue chan keyedJob) {
+	for {
+		select {
+		case <-p.quit:
+			return
+		case job := <-queue:
+			p.run(job)
+		}
+	}
+}
+
+// run handles the event of job and completes it, reporting the error of the handler, if any, first
+func (p *keyedProcessor) run(job keyedJob) {
+	defer job.event.Release()
+
+	if err := p.handler(job.ctx, job.event); err != nil {
+		p.onError(job.ctx, err)
+	}
+	// events dropped as the receiver recovered are completed through their redelivery
+	if err := p.manager.Complete(job.event); err != nil && !errors.Is(err, eventhub.ErrEventNotInFlight) {
+		p.onError(job.ctx, fmt.Errorf("failed to checkpoint: %w", err))
+	}
+}
+
+// stop makes the workers quit once they finish the events they are handling. Queued events are dropped and released;
+// the checkpoint doesn't cover them, so they are received again by the next owner of the partition.
+func (p *keyedProcessor) stop() {
+	if p == nil {
+		return
+	}
+	p.stopped.Do(func() {
+		close(p.quit)
+		for _, queue := range p.queues {
+			drop(queue)
+		}
+	})
+}
+
+// drop releases the events of the jobs queued on queue
+func drop(queue chan keyedJob) {
+	for {
+		select {
+		case job := <-queue:
+			job.event.Release()
+		default:
+			return
+		}
+	}
+}
+
+// processKeyed returns the handler queuing events on the keyed workers of the receiver and the receive option, if any,
+// which makes a Hub leave checkpointing to the manager of the workers. Events received by clients other than a Hub are
+// tracked by the handler.
+func (lr *leasedReceiver) processKeyed(handler eventhub.Handler, isHub bool) (eventhub.Handler, []eventhub.ReceiveOption) {
+	partitionID := lr.lease.GetPartitionID()
+	manager := eventhub.NewCheckpointManager()
+	lr.keyed = newKeyedProcessor(lr.processor.keyedWorkers, handler, manager, func(ctx context.Context, err error) {
+		tab.For(ctx).Error(err)
+		lr.processor.log(ctx, eventhub.LogLevelWarn, "keyed handler failed", "partitionID", partitionID, "error", err)
+		lr.processor.reportError(eventhub.ErrorEventHandler, partitionID, err)
+	})
+
+	if isHub {
+		return lr.keyed.handle, []eventhub.ReceiveOption{eventhub.ReceiveWithCheckpointManager(manager)}
+	}
+
+	store := func(checkpoint persist.Checkpoint) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		return lr.processor.updateCheckpoint(ctx, lr.processor.checkpointer, partitionID, checkpoint)
+	}
+	return func(ctx context.Context, event *eventhub.Event) error {
+		manager.Track(partitionID, event, store)
+		return lr.keyed.handle(ctx, event)
+	}, nil
+}
@@ -0,0 +1,256 @@
+package eph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/eventhubtest"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+func TestWithKeyOrderedProcessing(t *testing.T) {
+	host := new(EventProcessorHost)
+	assert.Error(t, WithKeyOrderedProcessing(0)(host))
+	require.NoError(t, WithKeyOrderedProcessing(8)(host))
+	assert.Equal(t, 8, host.keyedWorkers)
+}
+
+func TestKeyOrderedProcessing_KeepsKeyOrderAndCheckpointsContiguously(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	broker, err := eventhubtest.NewBroker(eventhubtest.BrokerWithPartitionCount(1))
+	require.NoError(t, err)
+	sender, err := broker.Hub("hub")
+	require.NoError(t, err)
+	const perKey = 10
+	keys := []string{"a", "b", "c", "d"}
+	for i := 0; i < perKey; i++ {
+		for _, key := range keys {
+			event := eventhub.NewEventFromString(fmt.Sprintf("%s-%d", key, i))
+			event.PartitionKey = &key
+			require.NoError(t, sender.Send(ctx, event))
+		}
+	}
+
+	client, err := broker.Hub("hub")
+	require.NoError(t, err)
+	leaserCheckpointer := newMemoryLeaserCheckpointer(DefaultLeaseDuration, new(sharedStore))
+	host, err := NewWithClient(ctx, client, leaserCheckpointer, leaserCheckpointer, WithNoBanner(), WithKeyOrderedProcessing(4))
+	require.NoError(t, err)
+
+	var (
+		mu         sync.Mutex
+		seen       = make(map[string][]int)
+		handled    int
+		running    int32
+		maxRunning int32
+		all        = make(chan struct{})
+	)
+	_, err = host.RegisterHandler(ctx, func(ctx context.Context, event *eventhub.Event) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+
+		parts := strings.Split(string(event.Data), "-")
+		i, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		seen[parts[0]] = append(seen[parts[0]], i)
+		handled++
+		if handled == perKey*len(keys) {
+			close(all)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, host.StartNonBlocking(ctx))
+	defer func() { _ = host.Close(context.Background()) }()
+
+	select {
+	case <-all:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the events of the hub")
+	}
+
+	mu.Lock()
+	for _, key := range keys {
+		require.Len(t, seen[key], perKey)
+		for i, n := range seen[key] {
+			assert.Equal(t, i, n, "events of key %s are handled in order", key)
+		}
+	}
+	mu.Unlock()
+	assert.Greater(t, atomic.LoadInt32(&maxRunning), int32(1), "events of different keys are handled concurrently")
+
+	events, err := broker.Events("hub", "0")
+	require.NoError(t, err)
+	last := events[len(events)-1].GetCheckpoint()
+	require.Eventually(t, func() bool {
+		checkpoint, ok := leaserCheckpointer.GetCheckpoint(ctx, "0")
+		return ok && checkpoint.Offset == last.Offset
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestKeyedProcessor_FailedEventsDoNotHoldTheCheckpointBack(t *testing.T) {
+	manager := eventhub.NewCheckpointManager()
+	var mu sync.Mutex
+	var written []int64
+	store := func(checkpoint persist.Checkpoint) error {
+		mu.Lock()
+		defer mu.Unlock()
+		written = append(written, checkpoint.SequenceNumber)
+		return nil
+	}
+
+	failing := errors.New("handler failed")
+	var errs []error
+	var handled int32
+	p := newKeyedProcessor(1, func(_ context.Context, event *eventhub.Event) error {
+		atomic.AddInt32(&handled, 1)
+		if seq, _ := event.GetSequenceNumber(); seq == 2 {
+			return failing
+		}
+		return nil
+	}, manager, func(_ context.Context, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	})
+	defer p.stop()
+
+	for seq := int64(1); seq <= 3; seq++ {
+		seq := seq
+		event := &eventhub.Event{SystemProperties: &eventhub.SystemProperties{SequenceNumber: &seq}}
+		manager.Track("0", event, store)
+		require.NoError(t, p.handle(context.Background(), event))
+	}
+	require.Eventually(t, func() bool {
+		checkpoint, ok := manager.Checkpoint("0")
+		return ok && checkpoint.SequenceNumber == 3
+	}, time.Second, time.Millisecond, "the checkpoint should advance past the failed event as the following events complete")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.EqualValues(t, 3, atomic.LoadInt32(&handled))
+	assert.Equal(t, []error{failing}, errs, "the failure should be reported")
+	require.NotEmpty(t, written)
+	assert.EqualValues(t, 3, written[len(written)-1])
+	assert.Zero(t, manager.Pending("0"))
+
+	p.stop()
+	assert.Error(t, p.handle(context.Background(), eventhub.NewEventFromString("late")))
+}
+
+func TestKeyedProcessor_StopReleasesQueuedEvents(t *testing.T) {
+	unblock := make(chan struct{})
+	started := make(chan struct{}, 1)
+	p := newKeyedProcessor(1, func(context.Context, *eventhub.Event) error {
+		started <- struct{}{}
+		<-unblock
+		return nil
+	}, eventhub.NewCheckpointManager(), func(context.Context, error) {})
+	defer close(unblock)
+
+	var pooled []*eventhub.Event
+	handle := eventhub.PoolEvents(func(ctx context.Context, event *eventhub.Event) error {
+		pooled = append(pooled, event)
+		return p.handle(ctx, event)
+	})
+	for _, data := range []string{"handling", "queued 1", "queued 2"} {
+		require.NoError(t, handle(context.Background(), eventhub.NewEventFromString(data)))
+		if data == "handling" {
+			<-started
+		}
+	}
+
+	p.stop()
+	assert.Equal(t, "handling", string(pooled[0].Data), "the event being handled should be kept until its handler returns")
+	assert.Nil(t, pooled[1].Data, "queued events should go back to the pool")
+	assert.Nil(t, pooled[2].Data, "queued events should go back to the pool")
+}
+
+func TestKeyOrderedProcessing_EventsPooledAndRetainedByTheHandler(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	broker, err := eventhubtest.NewBroker(eventhubtest.BrokerWithPartitionCount(1))
+	require.NoError(t, err)
+	sender, err := broker.Hub("hub")
+	require.NoError(t, err)
+	const count = 200
+	for i := 0; i < count; i++ {
+		key := strconv.Itoa(i % 4)
+		event := eventhub.NewEventFromString(fmt.Sprintf("event-%d", i))
+		event.PartitionKey = &key
+		require.NoError(t, sender.Send(ctx, event))
+	}
+
+	client, err := broker.Hub("hub")
+	require.NoError(t, err)
+	leaserCheckpointer := newMemoryLeaserCheckpointer(DefaultLeaseDuration, new(sharedStore))
+	host, err := NewWithClient(ctx, client, leaserCheckpointer, leaserCheckpointer, WithNoBanner(),
+		WithEventPooling(), WithKeyOrderedProcessing(4))
+	require.NoError(t, err)
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		mismatched []string
+	)
+	wg.Add(count)
+	_, err = host.RegisterHandler(ctx, func(_ context.Context, event *eventhub.Event) error {
+		// the handler keeps the event after returning, alongside the keyed processor holding it
+		event.Retain()
+		data := string(event.Data)
+		go func() {
+			defer wg.Done()
+			defer event.Release()
+			time.Sleep(time.Millisecond)
+			if later := string(event.Data); later != data {
+				mu.Lock()
+				mismatched = append(mismatched, fmt.Sprintf("%q became %q", data, later))
+				mu.Unlock()
+			}
+		}()
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, host.StartNonBlocking(ctx))
+	defer func() { _ = host.Close(context.Background()) }()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the events of the hub")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Empty(t, mismatched, "retained events should not be recycled while the handler holds them")
+}
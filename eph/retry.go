@@ -0,0 +1,62 @@
+package eph
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+// backoff between the attempts of a handler which failed with an error the retry classifier deems retryable, when the
+// host rather than its Event Hub client runs the handler
+const (
+	handlerRetryMin         = 500 * time.Millisecond
+	handlerRetryMax         = 30 * time.Second
+	handlerRetryMaxAttempts = 10
+)
+
+// WithRetryClassifier configures the host to let classifier override which failures are retried by its Event Hub
+// client, see eventhub.HubWithRetryClassifier, and which errors of its handlers are retried. A handler which fails
+// with an error the classifier deems retryable is called again with the event, up to 10 times with a backoff of up to
+// 30 seconds, before the error is reported; handler errors are not retryable by default. A Hub passed to NewWithClient
+// retries handler errors only if it was built with eventhub.HubWithRetryClassifier.
+func WithRetryClassifier(classifier eventhub.RetryClassifier) EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		if classifier == nil {
+			return errors.New("retry classifier must not be nil")
+		}
+		host.retryClassifier = classifier
+		return nil
+	}
+}
+
+// retrying wraps handler to retry the errors the retry classifier of the host deems retryable, unless the host
+// doesn't have one or its Event Hub client runs handler and retries them itself
+func (lr *leasedReceiver) retrying(handler eventhub.Handler, clientRetries bool) eventhub.Handler {
+	if lr.processor.retryClassifier == nil || clientRetries {
+		return handler
+	}
+	return eventhub.RetryHandler(handler, lr.processor.retryClassifier, handlerRetryMin, handlerRetryMax, handlerRetryMaxAttempts)
+}
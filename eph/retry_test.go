@@ -0,0 +1,75 @@
+package eph
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/eventhubtest"
+)
+
+func TestWithRetryClassifier(t *testing.T) {
+	host := new(EventProcessorHost)
+	assert.Error(t, WithRetryClassifier(nil)(host))
+	require.NoError(t, WithRetryClassifier(func(error, bool) bool { return true })(host))
+	assert.NotNil(t, host.retryClassifier)
+}
+
+func TestRetryClassifier_RetriesHandlerErrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	broker, err := eventhubtest.NewBroker(eventhubtest.BrokerWithPartitionCount(1))
+	require.NoError(t, err)
+	sender, err := broker.Hub("hub")
+	require.NoError(t, err)
+	require.NoError(t, sender.Send(ctx, eventhub.NewEventFromString("transient")))
+	require.NoError(t, sender.Send(ctx, eventhub.NewEventFromString("fatal")))
+
+	transient := errors.New("downstream unavailable")
+	fatal := errors.New("malformed")
+	client, err := broker.Hub("hub")
+	require.NoError(t, err)
+	leaserCheckpointer := newMemoryLeaserCheckpointer(DefaultLeaseDuration, new(sharedStore))
+	host, err := NewWithClient(ctx, client, leaserCheckpointer, leaserCheckpointer, WithNoBanner(),
+		WithRetryClassifier(func(err error, retryable bool) bool {
+			return retryable || errors.Is(err, transient)
+		}))
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	_, err = host.RegisterHandler(ctx, func(_ context.Context, event *eventhub.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		data := string(event.Data)
+		calls[data]++
+		switch {
+		case data == "transient" && calls[data] == 1:
+			return transient
+		case data == "fatal":
+			return fatal
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, host.StartNonBlocking(ctx))
+	defer func() { _ = host.Close(context.Background()) }()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls["fatal"] > 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, calls["transient"], "a handler error classified as retryable should be retried")
+	assert.Equal(t, 1, calls["fatal"], "handler errors are not retried by default")
+}
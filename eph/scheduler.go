@@ -30,6 +30,8 @@ import (
 	"time"
 
 	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
 )
 
 var (
@@ -51,11 +53,16 @@ const (
 
 type (
 	scheduler struct {
-		processor            *EventProcessorHost
-		receivers            map[string]*leasedReceiver
-		done                 func()
-		leaseRenewalInterval time.Duration
-		receiverMu           sync.Mutex
+		processor  *EventProcessorHost
+		receivers  map[string]*leasedReceiver
+		done       func()
+		receiverMu sync.Mutex
+		// rescan cuts the wait for the next scan short, when the scan interval is changed
+		rescan chan struct{}
+		// random picks the order leases are acquired in and the lease to steal; tests seed it to replay a run
+		random *rand.Rand
+		// renewManually keeps receivers from renewing their leases on their own, for tests which renew them in steps
+		renewManually bool
 	}
 
 	ownerCount struct {
@@ -66,9 +73,10 @@ type (
 
 func newScheduler(eventHostProcessor *EventProcessorHost) *scheduler {
 	return &scheduler{
-		processor:            eventHostProcessor,
-		receivers:            make(map[string]*leasedReceiver),
-		leaseRenewalInterval: DefaultLeaseRenewalInterval,
+		processor: eventHostProcessor,
+		receivers: make(map[string]*leasedReceiver),
+		rescan:    make(chan struct{}, 1),
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
 
@@ -85,12 +93,29 @@ func (s *scheduler) Run(ctx context.Context) {
 			return
 		default:
 			s.scan(ctx)
-			skew := time.Duration(rand.Intn(1000)-500) * time.Millisecond
-			time.Sleep(s.leaseRenewalInterval + skew)
+			// the interval may be changed with SetConfig, and the skew is a tenth of it
+			interval := s.processor.scanInterval()
+			skew := time.Duration(s.random.Int63n(int64(interval)/10+1)) - interval/20
+			timer := time.NewTimer(interval + skew)
+			select {
+			case <-timer.C:
+			case <-s.rescan:
+				timer.Stop()
+			case <-ctx.Done():
+				timer.Stop()
+			}
 		}
 	}
 }
 
+// scanNow makes the scheduler scan without waiting for the rest of its interval
+func (s *scheduler) scanNow() {
+	select {
+	case s.rescan <- struct{}{}:
+	default:
+	}
+}
+
 func (s *scheduler) scan(ctx context.Context) {
 	span, ctx := s.startConsumerSpanFromContext(ctx, "eph.scheduler.scan")
 	defer span.End()
@@ -103,11 +128,13 @@ func (s *scheduler) scan(ctx context.Context) {
 	cancel()
 	if err != nil {
 		tab.For(ctx).Error(err)
+		s.processor.log(ctx, eventhub.LogLevelWarn, "failed to list leases", "error", err)
+		s.processor.reportError(eventhub.ErrorEventLease, "", err)
 		return
 	}
 
 	randomLeases := make([]LeaseMarker, len(allLeases))
-	perm := rand.Perm(len(allLeases))
+	perm := s.random.Perm(len(allLeases))
 	for i, v := range perm {
 		randomLeases[v] = allLeases[i]
 	}
@@ -118,14 +145,18 @@ func (s *scheduler) scan(ctx context.Context) {
 	s.dlog(ctx, fmt.Sprintf("acquired: %v, not acquired: %v", acquired, notAcquired))
 	if err != nil {
 		tab.For(ctx).Error(err)
+		s.processor.log(ctx, eventhub.LogLevelWarn, "failed to acquire expired leases", "error", err)
+		s.processor.reportError(eventhub.ErrorEventLease, "", err)
 		return
 	}
 
 	// start receiving message from newly acquired partitions
 	for _, lease := range acquired {
 		if err := s.startReceiver(ctx, lease); err != nil {
-			_, _ = s.processor.leaser.ReleaseLease(ctx, lease.GetPartitionID())
+			_, _ = s.processor.releaseLease(ctx, lease)
 			tab.For(ctx).Error(err)
+			s.processor.log(ctx, eventhub.LogLevelError, "failed to start receiver; releasing lease", "partitionID", lease.GetPartitionID(), "error", err)
+			s.processor.reportError(eventhub.ErrorEventReceiverStart, lease.GetPartitionID(), err)
 			return
 		}
 	}
@@ -155,18 +186,22 @@ func (s *scheduler) scan(ctx context.Context) {
 	if candidate, ok := s.leaseToSteal(ctx, leasesOwnedByOthers, countOwnedByMe); ok {
 		s.dlog(ctx, fmt.Sprintf("attempting to steal: %v", candidate))
 		acquireCtx, cancel := context.WithTimeout(ctx, timeout)
-		stolen, ok, err := s.processor.leaser.AcquireLease(acquireCtx, candidate.GetPartitionID())
+		stolen, ok, err := s.processor.acquireLease(acquireCtx, AuditLeaseSteal, candidate)
 		cancel()
 		switch {
 		case err != nil:
 			tab.For(ctx).Error(err)
+			s.processor.log(ctx, eventhub.LogLevelWarn, "failed to steal lease", "partitionID", candidate.GetPartitionID(), "error", err)
+			s.processor.reportError(eventhub.ErrorEventLease, candidate.GetPartitionID(), err)
 		case !ok:
 			s.dlog(ctx, fmt.Sprintf("failed to steal: %v", candidate))
 		default:
 			s.dlog(ctx, fmt.Sprintf("stole: %v", stolen))
 			if err := s.startReceiver(ctx, stolen); err != nil {
-				_, _ = s.processor.leaser.ReleaseLease(acquireCtx, candidate.GetPartitionID())
+				_, _ = s.processor.releaseLease(acquireCtx, stolen)
 				tab.For(ctx).Error(err)
+				s.processor.log(ctx, eventhub.LogLevelError, "failed to start receiver; releasing lease", "partitionID", candidate.GetPartitionID(), "error", err)
+				s.processor.reportError(eventhub.ErrorEventReceiverStart, candidate.GetPartitionID(), err)
 				return
 			}
 		}
@@ -184,16 +219,15 @@ func (s *scheduler) Stop(ctx context.Context) error {
 		s.done()
 	}
 
-	// close all receivers even if errors occur reporting only the last error, but logging all
-	var lastErr error
-	for _, lr := range s.receivers {
-		if err := lr.Close(ctx); err != nil {
-			lastErr = err
-		}
-		_, _ = s.processor.leaser.ReleaseLease(ctx, lr.lease.GetPartitionID())
+	// close all receivers even if errors occur, reporting each of them
+	var errs closeErrors
+	for partitionID, lr := range s.receivers {
+		errs.add("receiver of partition "+partitionID, lr.Close(ctx))
+		_, _ = s.processor.releaseLease(ctx, lr.lease)
 	}
+	s.processor.metrics.setOwned(s.processor, 0)
 
-	return lastErr
+	return errs.err()
 }
 
 func (s *scheduler) getPartitionIDsBeingProcessed() []string {
@@ -235,26 +269,31 @@ func (s *scheduler) startReceiver(ctx context.Context, lease LeaseMarker) error
 		return err
 	}
 	s.receivers[lease.GetPartitionID()] = lr
+	s.processor.metrics.setOwned(s.processor, len(s.receivers))
 	return nil
 }
 
-func (s *scheduler) stopReceiver(ctx context.Context, lease LeaseMarker) error {
+// stopReceiver stops lr and releases its lease, unless lr has already been replaced by another receiver of its
+// partition
+func (s *scheduler) stopReceiver(ctx context.Context, lr *leasedReceiver) error {
 	s.receiverMu.Lock()
 	defer s.receiverMu.Unlock()
 
 	span, ctx := s.startConsumerSpanFromContext(ctx, "eph.scheduler.stopReceiver")
 	defer span.End()
 
+	lease := lr.lease
 	span.AddAttributes(
 		tab.StringAttribute(partitionIDTag, lease.GetPartitionID()),
 		tab.Int64Attribute(epochTag, lease.GetEpoch()),
 	)
 	s.dlog(ctx, fmt.Sprintf("stopping receiver for partitionID %q", lease.GetPartitionID()))
-	if receiver, ok := s.receivers[lease.GetPartitionID()]; ok {
+	if receiver, ok := s.receivers[lease.GetPartitionID()]; ok && receiver == lr {
 		// try to release the lease if possible
-		_, _ = s.processor.leaser.ReleaseLease(ctx, lease.GetPartitionID())
+		_, _ = s.processor.releaseLease(ctx, lease)
 		err := receiver.Close(ctx)
 		delete(s.receivers, lease.GetPartitionID())
+		s.processor.metrics.setOwned(s.processor, len(s.receivers))
 		if err != nil {
 			tab.For(ctx).Error(err)
 			return err
@@ -271,7 +310,7 @@ func (s *scheduler) acquireExpiredLeases(ctx context.Context, leases []LeaseMark
 		if lease.IsExpired(ctx) && len(acquired) < greed {
 			// if lease has no owner or is expired and we haven't been too greedy
 			acquireCtx, cancel := context.WithTimeout(ctx, timeout)
-			if acquiredLease, ok, err := s.processor.leaser.AcquireLease(acquireCtx, lease.GetPartitionID()); ok {
+			if acquiredLease, ok, err := s.processor.acquireLease(acquireCtx, AuditLeaseAcquire, lease); ok {
 				cancel()
 				acquired = append(acquired, acquiredLease)
 			} else {
@@ -292,6 +331,7 @@ func (s *scheduler) acquireExpiredLeases(ctx context.Context, leases []LeaseMark
 func (s *scheduler) dlog(ctx context.Context, msg string) {
 	name := s.processor.name
 	tab.For(ctx).Debug(fmt.Sprintf("eph %q: "+msg, name))
+	s.processor.log(ctx, eventhub.LogLevelDebug, msg)
 }
 
 func (s *scheduler) leaseToSteal(ctx context.Context, candidates []LeaseMarker, myLeaseCount int) (LeaseMarker, bool) {
@@ -304,7 +344,7 @@ func (s *scheduler) leaseToSteal(ctx context.Context, candidates []LeaseMarker,
 		tab.For(ctx).Debug(fmt.Sprintf("i am %v, the biggest owner is %v and leases by owner: %v", s.processor.GetName(), biggestOwner.Owner, leasesByOwner))
 		if leasesByOwner[biggestOwner.Owner] != nil &&
 			(len(biggestOwner.Leases)-myLeaseCount) >= 2 && len(leasesByOwner[biggestOwner.Owner]) >= 1 {
-			selection := rand.Intn(len(leasesByOwner[biggestOwner.Owner]))
+			selection := s.random.Intn(len(leasesByOwner[biggestOwner.Owner]))
 			return leasesByOwner[biggestOwner.Owner][selection], true
 		}
 	}
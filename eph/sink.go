@@ -0,0 +1,338 @@
+package eph
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+const sinkTimeout = 30 * time.Second
+
+// errSinkClosed is returned for the events received by a receiver whose sink writer was stopped
+var errSinkClosed = errors.New("sink writer is closed")
+
+type (
+	// Sink is a transactional store the events of a host are delivered to effectively once. The events of a partition
+	// are written in transactions, and each transaction is committed together with the checkpoint of its last event,
+	// which the sink records in a journal in the same transaction. The checkpoint of the partition is written to the
+	// checkpointer of the host only once the transaction is committed; when a host acquires the partition, the journal
+	// is read back and the checkpointer rolled forward to it if the host stopped between the two, so the events of a
+	// committed transaction are never received again, and those of a transaction which wasn't are.
+	//
+	// A sink is shared by the receivers of every partition the host owns, and must be safe for concurrent use.
+	Sink interface {
+		// Begin starts a transaction for the events of a partition. epoch is the epoch of the lease of the partition;
+		// a sink which records the epoch of the last transaction committed for a partition in its journal can reject
+		// the commits of hosts which have since lost the lease.
+		Begin(ctx context.Context, partitionID string, epoch int64) (SinkTransaction, error)
+		// LastCommitted returns the checkpoint recorded in the journal by the last transaction committed for a
+		// partition, if any
+		LastCommitted(ctx context.Context, partitionID string) (persist.Checkpoint, bool, error)
+	}
+
+	// SinkTransaction is a transaction of a Sink
+	SinkTransaction interface {
+		// Write adds an event to the transaction
+		Write(ctx context.Context, event *eventhub.Event) error
+		// CommitWithCheckpoint commits the events written in the transaction and records checkpoint in the journal
+		// of the sink, atomically
+		CommitWithCheckpoint(ctx context.Context, checkpoint persist.Checkpoint) error
+		// Abort discards the events written in the transaction
+		Abort(ctx context.Context) error
+	}
+
+	// sinkWriter writes the events of a partition to a sink, committing a transaction once it holds the max events of
+	// the host or the max delay after it began, and completes the events with a checkpoint manager once their transaction is committed.
+	// A failure fails the writer for good: the events after it can't be written without the ones before, so the
+	// receiver is stopped and the partition received again from its checkpoint.
+	sinkWriter struct {
+		sink        Sink
+		partitionID string
+		epoch       int64
+		limits      func() (maxEvents int, maxDelay time.Duration)
+		handler     eventhub.Handler
+		manager     *eventhub.CheckpointManager
+		onError     func(ctx context.Context, err error, fatal bool)
+
+		mu     sync.Mutex
+		tx     SinkTransaction
+		events []*eventhub.Event
+		timer  *time.Timer
+		err    error
+	}
+)
+
+// WithSink configures the host to deliver the events it receives to sink effectively once, in transactions of up to
+// maxEvents events committed at the latest maxDelay after their first event was received, or only once full if
+// maxDelay is 0. The registered handlers, if any, are called with each event before it is written. An error of a
+// handler, after the retries configured with WithRetryClassifier, or of the sink aborts the open transaction and stops
+// the receiver of the partition, so its events are received again from the last committed transaction. Events are
+// written in the order they are received, so WithKeyOrderedProcessing doesn't apply.
+func WithSink(sink Sink, maxEvents int, maxDelay time.Duration) EventProcessorHostOption {
+	return func(host *EventProcessorHost) error {
+		if sink == nil {
+			return errors.New("sink must not be nil")
+		}
+		if maxEvents < 1 {
+			return fmt.Errorf("sink transactions must hold at least 1 event, got %d", maxEvents)
+		}
+		if maxDelay < 0 {
+			return fmt.Errorf("sink transaction delay must not be negative, got %v", maxDelay)
+		}
+		host.sink = sink
+		host.sinkMaxEvents = maxEvents
+		host.sinkMaxDelay = maxDelay
+		return nil
+	}
+}
+
+// recoverSink rolls the checkpoint of the partition of the receiver forward to the last transaction committed to the
+// sink of the host, in case the host which committed it stopped before writing the checkpoint
+func (lr *leasedReceiver) recoverSink(ctx context.Context) error {
+	span, ctx := lr.startConsumerSpanFromContext(ctx, "eph.leasedReceiver.recoverSink")
+	defer span.End()
+
+	partitionID := lr.lease.GetPartitionID()
+	committed, ok, err := lr.processor.sink.LastCommitted(ctx, partitionID)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return fmt.Errorf("failed to read the last checkpoint committed to the sink: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+	if current, ok := lr.processor.checkpointer.GetCheckpoint(ctx, partitionID); ok && !checkpointBefore(current, committed) {
+		return nil
+	}
+
+	lr.processor.log(ctx, eventhub.LogLevelInfo, "rolling the checkpoint forward to the last sink transaction", "partitionID", partitionID, "sequenceNumber", committed.SequenceNumber)
+	return lr.processor.updateCheckpoint(ctx, lr.processor.checkpointer, partitionID, committed)
+}
+
+// checkpointBefore reports whether checkpoint a is before checkpoint b in their partition
+func checkpointBefore(a, b persist.Checkpoint) bool {
+	if a.Offset == "" || a.Offset == persist.StartOfStream {
+		return b.Offset != "" && b.Offset != persist.StartOfStream
+	}
+	return a.SequenceNumber < b.SequenceNumber
+}
+
+// writeToSink returns the handler writing events to the sink of the host after handler, and the receive option, if
+// any, which makes a Hub leave checkpointing to the writer. Events received by clients other than a Hub are tracked
+// by the handler.
+func (lr *leasedReceiver) writeToSink(handler eventhub.Handler, isHub bool, epoch int64) (eventhub.Handler, []eventhub.ReceiveOption) {
+	partitionID := lr.lease.GetPartitionID()
+	manager := eventhub.NewCheckpointManager()
+	lr.sinkWriter = &sinkWriter{
+		sink:        lr.processor.sink,
+		partitionID: partitionID,
+		epoch:       epoch,
+		limits:      lr.processor.sinkLimits,
+		handler:     handler,
+		manager:     manager,
+		onError: func(ctx context.Context, err error, fatal bool) {
+			tab.For(ctx).Error(err)
+			lr.processor.reportError(eventhub.ErrorEventSink, partitionID, err)
+			if !fatal {
+				lr.processor.log(ctx, eventhub.LogLevelWarn, "failed to checkpoint a committed sink transaction", "partitionID", partitionID, "error", err)
+				return
+			}
+			lr.processor.log(ctx, eventhub.LogLevelWarn, "sink transaction failed; stopping receiver", "partitionID", partitionID, "error", err)
+			// the receiver is stopped from its own goroutine, as closing it waits for the handler which failed
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), sinkTimeout)
+				defer cancel()
+				_ = lr.processor.scheduler.stopReceiver(ctx, lr)
+			}()
+		},
+	}
+
+	if isHub {
+		return lr.sinkWriter.handle, []eventhub.ReceiveOption{eventhub.ReceiveWithCheckpointManager(manager)}
+	}
+
+	store := func(checkpoint persist.Checkpoint) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		return lr.processor.updateCheckpoint(ctx, lr.processor.checkpointer, partitionID, checkpoint)
+	}
+	return func(ctx context.Context, event *eventhub.Event) error {
+		manager.Track(partitionID, event, store)
+		return lr.sinkWriter.handle(ctx, event)
+	}, nil
+}
+
+// handle calls the handler of w with event, then writes it to the open transaction, beginning one if needed, and
+// commits the transaction once it is full
+func (w *sinkWriter) handle(ctx context.Context, event *eventhub.Event) error {
+	w.mu.Lock()
+	failed := w.err
+	w.mu.Unlock()
+	if failed != nil {
+		return failed
+	}
+
+	if err := w.handler(ctx, event); err != nil {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.fail(ctx, err)
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+
+	maxEvents, maxDelay := w.limits()
+	if w.tx == nil {
+		tx, err := w.sink.Begin(ctx, w.partitionID, w.epoch)
+		if err != nil {
+			w.fail(ctx, fmt.Errorf("failed to begin sink transaction: %w", err))
+			return w.err
+		}
+		w.tx = tx
+		if maxDelay > 0 {
+			w.timer = time.AfterFunc(maxDelay, func() { w.commitDue(tx) })
+		}
+	}
+
+	if err := w.tx.Write(ctx, event); err != nil {
+		w.fail(ctx, fmt.Errorf("failed to write event to sink transaction: %w", err))
+		return w.err
+	}
+	// pooled events outlive the receiver's handler call until their transaction is committed
+	event.Retain()
+	w.events = append(w.events, event)
+
+	if len(w.events) >= maxEvents {
+		return w.commit(ctx)
+	}
+	return nil
+}
+
+// commitDue commits tx once its delay has passed, unless it was committed or aborted since
+func (w *sinkWriter) commitDue(tx SinkTransaction) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.tx != tx || w.err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sinkTimeout)
+	defer cancel()
+	_ = w.commit(ctx)
+}
+
+// commit commits the open transaction with the checkpoint of its last event, then completes its events, which writes
+// the checkpoint of the partition. The events are completed last to first, so the checkpoint is only written once, as
+// the first completes. w.mu must be held.
+func (w *sinkWriter) commit(ctx context.Context) error {
+	span, ctx := startConsumerSpanFromContext(ctx, "eph.sinkWriter.commit")
+	defer span.End()
+	span.AddAttributes(
+		tab.StringAttribute(partitionIDTag, w.partitionID),
+		tab.Int64Attribute("eph.sink_events", int64(len(w.events))))
+
+	tx, events := w.tx, w.events
+	w.tx, w.events = nil, nil
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+
+	checkpoint := events[len(events)-1].GetCheckpoint()
+	if err := tx.CommitWithCheckpoint(ctx, checkpoint); err != nil {
+		// whether the transaction was committed is unknown; the next owner of the partition finds out from the journal
+		for _, event := range events {
+			event.Release()
+		}
+		w.fail(ctx, fmt.Errorf("failed to commit sink transaction: %w", err))
+		return w.err
+	}
+
+	var checkpointErr error
+	for i := len(events) - 1; i >= 0; i-- {
+		if err := w.manager.Complete(events[i]); err != nil && !errors.Is(err, eventhub.ErrEventNotInFlight) && checkpointErr == nil {
+			checkpointErr = err
+		}
+		events[i].Release()
+	}
+	if checkpointErr != nil {
+		// the transaction is committed, so the checkpoint is rolled forward from the journal if it isn't written later
+		w.onError(ctx, fmt.Errorf("failed to checkpoint sink transaction: %w", checkpointErr), false)
+	}
+	return nil
+}
+
+// fail records err as the error of w, aborts the open transaction and reports the failure. w.mu must be held.
+func (w *sinkWriter) fail(ctx context.Context, err error) {
+	if w.err != nil {
+		return
+	}
+	w.err = err
+	w.abort(ctx)
+	w.onError(ctx, err, true)
+}
+
+// abort aborts the open transaction, if any, and releases its events without completing them. w.mu must be held.
+func (w *sinkWriter) abort(ctx context.Context) {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if w.tx == nil {
+		return
+	}
+	if err := w.tx.Abort(ctx); err != nil {
+		tab.For(ctx).Error(err)
+	}
+	for _, event := range w.events {
+		event.Release()
+	}
+	w.tx, w.events = nil, nil
+}
+
+// stop aborts the open transaction as the receiver closes; its events are received again by the next owner of the
+// partition
+func (w *sinkWriter) stop(ctx context.Context) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = errSinkClosed
+	}
+	w.abort(ctx)
+}
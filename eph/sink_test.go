@@ -0,0 +1,252 @@
+package eph
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/eventhubtest"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+type (
+	// memorySink commits the data of events with the checkpoint of the transaction in its journal, rejecting the
+	// commits of transactions begun with an epoch older than the last committed
+	memorySink struct {
+		mu        sync.Mutex
+		committed map[string][]string
+		journal   map[string]persist.Checkpoint
+		epochs    map[string]int64
+		aborts    int
+		// failWrite fails the write of the event with the given data, once
+		failWrite string
+	}
+
+	memorySinkTransaction struct {
+		sink        *memorySink
+		partitionID string
+		epoch       int64
+		data        []string
+	}
+)
+
+func newMemorySink() *memorySink {
+	return &memorySink{
+		committed: make(map[string][]string),
+		journal:   make(map[string]persist.Checkpoint),
+		epochs:    make(map[string]int64),
+	}
+}
+
+func (s *memorySink) Begin(_ context.Context, partitionID string, epoch int64) (SinkTransaction, error) {
+	return &memorySinkTransaction{sink: s, partitionID: partitionID, epoch: epoch}, nil
+}
+
+func (s *memorySink) LastCommitted(_ context.Context, partitionID string) (persist.Checkpoint, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checkpoint, ok := s.journal[partitionID]
+	return checkpoint, ok, nil
+}
+
+func (s *memorySink) data(partitionID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.committed[partitionID]...)
+}
+
+func (tx *memorySinkTransaction) Write(_ context.Context, event *eventhub.Event) error {
+	tx.sink.mu.Lock()
+	defer tx.sink.mu.Unlock()
+	if string(event.Data) == tx.sink.failWrite {
+		tx.sink.failWrite = ""
+		return errors.New("write failed")
+	}
+	tx.data = append(tx.data, string(event.Data))
+	return nil
+}
+
+func (tx *memorySinkTransaction) CommitWithCheckpoint(_ context.Context, checkpoint persist.Checkpoint) error {
+	tx.sink.mu.Lock()
+	defer tx.sink.mu.Unlock()
+	if tx.epoch < tx.sink.epochs[tx.partitionID] {
+		return errors.New("lease lost")
+	}
+	tx.sink.epochs[tx.partitionID] = tx.epoch
+	tx.sink.committed[tx.partitionID] = append(tx.sink.committed[tx.partitionID], tx.data...)
+	tx.sink.journal[tx.partitionID] = checkpoint
+	return nil
+}
+
+func (tx *memorySinkTransaction) Abort(context.Context) error {
+	tx.sink.mu.Lock()
+	defer tx.sink.mu.Unlock()
+	tx.sink.aborts++
+	return nil
+}
+
+func TestWithSink(t *testing.T) {
+	host := new(EventProcessorHost)
+	sink := newMemorySink()
+	assert.Error(t, WithSink(nil, 1, 0)(host))
+	assert.Error(t, WithSink(sink, 0, 0)(host))
+	assert.Error(t, WithSink(sink, 1, -time.Second)(host))
+	require.NoError(t, WithSink(sink, 10, time.Second)(host))
+	assert.Equal(t, sink, host.sink)
+	assert.Equal(t, 10, host.sinkMaxEvents)
+	assert.Equal(t, time.Second, host.sinkMaxDelay)
+}
+
+func TestCheckpointBefore(t *testing.T) {
+	start := persist.NewCheckpointFromStartOfStream()
+	first := persist.NewCheckpoint("0", 0, time.Time{})
+	later := persist.NewCheckpoint("100", 4, time.Time{})
+	assert.True(t, checkpointBefore(start, first))
+	assert.True(t, checkpointBefore(persist.Checkpoint{}, later))
+	assert.True(t, checkpointBefore(first, later))
+	assert.False(t, checkpointBefore(later, first))
+	assert.False(t, checkpointBefore(later, later))
+	assert.False(t, checkpointBefore(start, start))
+}
+
+// sinkHarness is a hub with a single partition, and the checkpoint store of the hosts delivering it to a sink
+type sinkHarness struct {
+	t      *testing.T
+	broker *eventhubtest.Broker
+	sender *eventhubtest.Hub
+	store  *sharedStore
+	sink   *memorySink
+	sent   int
+}
+
+func newSinkHarness(t *testing.T) *sinkHarness {
+	broker, err := eventhubtest.NewBroker(eventhubtest.BrokerWithPartitionCount(1))
+	require.NoError(t, err)
+	sender, err := broker.Hub("hub")
+	require.NoError(t, err)
+	return &sinkHarness{t: t, broker: broker, sender: sender, store: new(sharedStore), sink: newMemorySink()}
+}
+
+func (h *sinkHarness) send(ctx context.Context, count int) {
+	for i := 0; i < count; i++ {
+		require.NoError(h.t, h.sender.Send(ctx, eventhub.NewEventFromString(strconv.Itoa(h.sent))))
+		h.sent++
+	}
+}
+
+func (h *sinkHarness) start(ctx context.Context, maxEvents int, maxDelay time.Duration) (*EventProcessorHost, *memoryLeaserCheckpointer) {
+	host, leaserCheckpointer := h.newHost(ctx, maxEvents, maxDelay)
+	require.NoError(h.t, host.StartNonBlocking(ctx))
+	return host, leaserCheckpointer
+}
+
+func (h *sinkHarness) newHost(ctx context.Context, maxEvents int, maxDelay time.Duration) (*EventProcessorHost, *memoryLeaserCheckpointer) {
+	client, err := h.broker.Hub("hub")
+	require.NoError(h.t, err)
+	leaserCheckpointer := newMemoryLeaserCheckpointer(DefaultLeaseDuration, h.store)
+	host, err := NewWithClient(ctx, client, leaserCheckpointer, leaserCheckpointer, WithNoBanner(), WithSink(h.sink, maxEvents, maxDelay))
+	require.NoError(h.t, err)
+	return host, leaserCheckpointer
+}
+
+// checkpointOf returns the checkpoint of the i-th event sent
+func (h *sinkHarness) checkpointOf(i int) persist.Checkpoint {
+	events, err := h.broker.Events("hub", "0")
+	require.NoError(h.t, err)
+	return events[i].GetCheckpoint()
+}
+
+func (h *sinkHarness) expect(data ...string) {
+	require.Eventually(h.t, func() bool {
+		return assert.ObjectsAreEqual(data, h.sink.data("0"))
+	}, 5*time.Second, 10*time.Millisecond, "sink holds %v", h.sink.data("0"))
+}
+
+func TestSink_CommitsTransactionsWithTheirCheckpoint(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	h := newSinkHarness(t)
+	h.send(ctx, 5)
+
+	host, checkpointer := h.start(ctx, 2, 0)
+	h.expect("0", "1", "2", "3")
+	checkpoint, ok := checkpointer.GetCheckpoint(ctx, "0")
+	require.True(t, ok)
+	assert.Equal(t, h.checkpointOf(3), checkpoint, "the checkpoint should cover the committed transactions only")
+	assert.Equal(t, h.checkpointOf(3), h.sink.journal["0"])
+
+	// the open transaction is aborted as the host closes, and its event received again by the next
+	require.NoError(t, host.Close(ctx))
+	assert.Equal(t, 1, h.sink.aborts)
+
+	host, checkpointer = h.start(ctx, 2, 0)
+	defer func() { _ = host.Close(context.Background()) }()
+	h.send(ctx, 1)
+	h.expect("0", "1", "2", "3", "4", "5")
+	require.Eventually(t, func() bool {
+		checkpoint, ok := checkpointer.GetCheckpoint(ctx, "0")
+		return ok && checkpoint == h.checkpointOf(5)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSink_CommitsAfterMaxDelay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	h := newSinkHarness(t)
+	h.send(ctx, 3)
+
+	host, checkpointer := h.start(ctx, 100, 20*time.Millisecond)
+	defer func() { _ = host.Close(context.Background()) }()
+	h.expect("0", "1", "2")
+	require.Eventually(t, func() bool {
+		checkpoint, ok := checkpointer.GetCheckpoint(ctx, "0")
+		return ok && checkpoint == h.checkpointOf(2)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSink_RollsTheCheckpointForwardToTheJournal(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	h := newSinkHarness(t)
+	h.send(ctx, 5)
+
+	// a host committed the first 3 events, then stopped before writing the checkpoint
+	h.sink.committed["0"] = []string{"0", "1", "2"}
+	h.sink.journal["0"] = h.checkpointOf(2)
+
+	host, _ := h.start(ctx, 2, 0)
+	defer func() { _ = host.Close(context.Background()) }()
+	h.expect("0", "1", "2", "3", "4")
+}
+
+func TestSink_FailedWritesStopTheReceiver(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	h := newSinkHarness(t)
+	h.sink.failWrite = "2"
+	h.send(ctx, 6)
+
+	// the host scans for leases in steps, so the receiver stopped by the failure is only started again below
+	host, _ := h.newHost(ctx, 2, 0)
+	defer func() { _ = host.Close(context.Background()) }()
+	require.NoError(t, host.setup(ctx))
+	host.scheduler.scan(ctx)
+	h.expect("0", "1")
+	require.Eventually(t, func() bool {
+		return len(host.scheduler.getPartitionIDsBeingProcessed()) == 0
+	}, 5*time.Second, 10*time.Millisecond, "the receiver should be stopped")
+	assert.Equal(t, 1, h.sink.aborts)
+
+	// the partition is acquired again, and received from the last committed transaction
+	require.Eventually(t, func() bool {
+		host.scheduler.scan(ctx)
+		return assert.ObjectsAreEqual([]string{"0", "1", "2", "3", "4", "5"}, h.sink.data("0"))
+	}, 10*time.Second, 50*time.Millisecond, "sink holds %v", h.sink.data("0"))
+}
@@ -0,0 +1,163 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Error event types
+const (
+	// ErrorEventSendRetry reports a send attempt which failed and will be retried
+	ErrorEventSendRetry ErrorEventType = "send-retry"
+	// ErrorEventLinkDetached reports a receiver link which failed and will be recovered
+	ErrorEventLinkDetached ErrorEventType = "link-detached"
+	// ErrorEventDecode reports a received message which could not be decoded into an event
+	ErrorEventDecode ErrorEventType = "decode"
+	// ErrorEventHandler reports an event whose handler returned an error, releasing it for redelivery
+	ErrorEventHandler ErrorEventType = "handler"
+	// ErrorEventSlowHandler reports a handler which took longer than the configured threshold; the error is a
+	// SlowHandlerError
+	ErrorEventSlowHandler ErrorEventType = "slow-handler"
+	// ErrorEventSequenceGap reports events of a partition which were never delivered to the handler; the error is a
+	// SequenceGapError
+	ErrorEventSequenceGap ErrorEventType = "sequence-gap"
+	// ErrorEventKeepAlive reports a connection which failed its keep-alive probe and will be recycled
+	ErrorEventKeepAlive ErrorEventType = "keep-alive"
+	// ErrorEventLeaseRenew reports a partition lease which could not be renewed, stopping its receiver
+	ErrorEventLeaseRenew ErrorEventType = "lease-renew"
+	// ErrorEventLease reports a failure to list, acquire or steal partition leases, retried on the next scan
+	ErrorEventLease ErrorEventType = "lease"
+	// ErrorEventReceiverStart reports a receiver which could not be started for an acquired lease
+	ErrorEventReceiverStart ErrorEventType = "receiver-start"
+	// ErrorEventSink reports a sink transaction which failed, stopping its receiver so the partition is received again
+	// from its checkpoint
+	ErrorEventSink ErrorEventType = "sink"
+)
+
+type (
+	// ErrorEventType identifies where a non-fatal error occurred
+	ErrorEventType string
+
+	// ErrorEvent describes an error which was handled internally, usually by retrying, rather than returned to the
+	// caller
+	ErrorEvent struct {
+		Type ErrorEventType
+		// Time is when the error occurred
+		Time time.Time
+		// Entity is the address of the entity, or the ID of the partition, the error concerns, if any
+		Entity string
+		Err    error
+	}
+
+	// ErrorListener is called with each non-fatal error of a Hub. It is called synchronously, so it must not block.
+	ErrorListener func(event ErrorEvent)
+
+	// ErrorStream fans error events out to subscribers. Each subscriber has its own buffered channel; events are dropped
+	// for subscribers whose buffer is full, so a slow subscriber never stalls the client. The zero value is ready to use.
+	ErrorStream struct {
+		mu          sync.Mutex
+		subscribers map[chan ErrorEvent]struct{}
+		listeners   []ErrorListener
+	}
+)
+
+// HubWithErrorListener configures the Hub to report non-fatal errors, such as retried sends and recovered links, to
+// the listener, in addition to subscribers of Hub.SubscribeErrors
+func HubWithErrorListener(listener ErrorListener) HubOption {
+	return func(h *Hub) error {
+		if listener == nil {
+			return errors.New("error listener must not be nil")
+		}
+		h.errorStream.listen(listener)
+		return nil
+	}
+}
+
+// SubscribeErrors returns a channel of the non-fatal errors the Hub handles internally, such as retried sends,
+// detached links, undecodable messages and failed keep-alive probes, which are otherwise only visible as degraded
+// throughput. Up to buffer events are queued for the subscriber; later events are dropped until it catches up. Call
+// the returned function to unsubscribe and close the channel.
+func (h *Hub) SubscribeErrors(buffer int) (<-chan ErrorEvent, func()) {
+	return h.errorStream.Subscribe(buffer)
+}
+
+// reportError publishes a non-fatal error of the given type to the Hub's error listeners and subscribers
+func (h *Hub) reportError(typ ErrorEventType, entity string, err error) {
+	h.errorStream.Publish(ErrorEvent{Type: typ, Entity: entity, Err: err})
+}
+
+// Subscribe returns a channel receiving every event published after the call, queueing up to buffer events. Call the
+// returned function to unsubscribe and close the channel.
+func (s *ErrorStream) Subscribe(buffer int) (<-chan ErrorEvent, func()) {
+	if buffer < 0 {
+		buffer = 0
+	}
+
+	ch := make(chan ErrorEvent, buffer)
+	s.mu.Lock()
+	if s.subscribers == nil {
+		s.subscribers = make(map[chan ErrorEvent]struct{})
+	}
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subscribers, ch)
+			s.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish delivers event to the listeners and subscribers of the stream without blocking, stamping it with the
+// current time if it has none
+func (s *ErrorStream) Publish(event ErrorEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, listener := range s.listeners {
+		listener(event)
+	}
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// the subscriber isn't keeping up
+		}
+	}
+}
+
+func (s *ErrorStream) listen(listener ErrorListener) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, listener)
+	s.mu.Unlock()
+}
@@ -0,0 +1,48 @@
+package eventhub
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorStream(t *testing.T) {
+	var stream ErrorStream
+	stream.Publish(ErrorEvent{Type: ErrorEventDecode}) // no subscribers
+
+	events, unsubscribe := stream.Subscribe(1)
+	failed := errors.New("failed")
+	stream.Publish(ErrorEvent{Type: ErrorEventSendRetry, Entity: "hub", Err: failed})
+	stream.Publish(ErrorEvent{Type: ErrorEventSendRetry, Entity: "hub", Err: failed}) // dropped; the buffer is full
+
+	event := <-events
+	assert.Equal(t, ErrorEventSendRetry, event.Type)
+	assert.Equal(t, "hub", event.Entity)
+	assert.Equal(t, failed, event.Err)
+	assert.False(t, event.Time.IsZero())
+	assert.Len(t, events, 0)
+
+	unsubscribe()
+	unsubscribe()
+	_, open := <-events
+	assert.False(t, open)
+	assert.NotPanics(t, func() { stream.Publish(ErrorEvent{Type: ErrorEventDecode}) })
+}
+
+func TestHub_SubscribeErrors(t *testing.T) {
+	h := new(Hub)
+	assert.Error(t, HubWithErrorListener(nil)(h))
+
+	var heard []ErrorEvent
+	require.NoError(t, HubWithErrorListener(func(event ErrorEvent) { heard = append(heard, event) })(h))
+	events, unsubscribe := h.SubscribeErrors(10)
+	defer unsubscribe()
+
+	h.reportError(ErrorEventKeepAlive, "hub/ConsumerGroups/$Default/Partitions/0", errors.New("probe failed"))
+	require.Len(t, heard, 1)
+	event := <-events
+	assert.Equal(t, heard[0], event)
+	assert.Equal(t, ErrorEventKeepAlive, event.Type)
+}
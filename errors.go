@@ -1,11 +1,341 @@
 package eventhub
 
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Azure/go-amqp"
+)
+
+// AMQP error conditions which the service uses to fail operations
+const (
+	conditionDetachForced          amqp.ErrorCondition = "amqp:link:detach-forced"
+	conditionLinkStolen            amqp.ErrorCondition = "amqp:link:stolen"
+	conditionResourceLimitExceeded amqp.ErrorCondition = "amqp:resource-limit-exceeded"
+	conditionUnauthorizedAccess    amqp.ErrorCondition = "amqp:unauthorized-access"
+	conditionNotFound              amqp.ErrorCondition = "amqp:not-found"
+	conditionInternalError         amqp.ErrorCondition = "amqp:internal-error"
+	conditionMessageSizeExceeded   amqp.ErrorCondition = "amqp:link:message-size-exceeded"
+	conditionArgumentOutOfRange    amqp.ErrorCondition = "com.microsoft:argument-out-of-range"
+)
+
 type (
 	// ErrNoMessages is returned when an operation returned no messages. It is not indicative that there will not be
 	// more messages in the future.
 	ErrNoMessages struct{}
+
+	// ErrNotFound is returned when the service reports that the requested entity does not exist
+	ErrNotFound struct {
+		Description string
+		cause       error
+	}
+
+	// ErrPartitionNotFound is returned when an operation targets a partition the Event Hub doesn't have. It matches
+	// ErrNotFound{} as well.
+	ErrPartitionNotFound struct {
+		PartitionID string
+		Description string
+		cause       error
+	}
+
+	// ErrUnauthorized is returned when the service rejects the credentials used for an operation
+	ErrUnauthorized struct {
+		Description string
+		cause       error
+	}
+
+	// ErrServerBusy is returned when the service is too busy to process an operation. The operation may succeed if it
+	// is retried later.
+	ErrServerBusy struct {
+		Description string
+		cause       error
+	}
+
+	// ErrLinkDetached is returned when the service detached the link an operation was using, for example because the
+	// entity was updated or a partition moved. Unless Stolen is set, the operation may succeed on a new link.
+	ErrLinkDetached struct {
+		Description string
+		// Stolen is set when a receiver with a higher epoch took over the partition
+		Stolen bool
+		cause  error
+	}
+
+	// ErrResourceLimitExceeded is returned when an operation exceeds a quota of the namespace, such as its throughput
+	// units or the number of receivers per consumer group. The operation may succeed later, once usage has dropped.
+	ErrResourceLimitExceeded struct {
+		Description string
+		cause       error
+	}
+
+	// retryableError is implemented by errors which know whether the failed operation may be retried
+	retryableError interface {
+		error
+		Retryable() bool
+	}
+
+	// AMQPError is returned when the service fails an operation with an AMQP error condition which does not have a
+	// more specific error type
+	AMQPError struct {
+		Condition   string
+		Description string
+		Info        map[string]interface{}
+		cause       error
+	}
+
+	// ManagementError is returned when the management node responds with a status code which does not have a more
+	// specific error type
+	ManagementError struct {
+		Code        int
+		Description string
+	}
+
+	// ErrManagementCircuitOpen is returned without contacting the management node while the management circuit breaker
+	// is open after repeated failures
+	ErrManagementCircuitOpen struct {
+		RetryAfter time.Duration
+	}
 )
 
 func (e ErrNoMessages) Error() string {
 	return "no messages available"
 }
+
+func (e ErrNotFound) Error() string {
+	return "entity not found: " + e.Description
+}
+
+// Is reports whether target is an ErrNotFound, so errors.Is(err, ErrNotFound{}) matches regardless of description
+func (e ErrNotFound) Is(target error) bool {
+	_, ok := target.(ErrNotFound)
+	return ok
+}
+
+// Unwrap returns the underlying AMQP error, if any
+func (e ErrNotFound) Unwrap() error {
+	return e.cause
+}
+
+// Retryable indicates whether the operation may succeed if it is retried
+func (e ErrNotFound) Retryable() bool {
+	return false
+}
+
+func (e ErrPartitionNotFound) Error() string {
+	return fmt.Sprintf("partition %q not found: %s", e.PartitionID, e.Description)
+}
+
+// Is reports whether target is an ErrPartitionNotFound or an ErrNotFound, so errors.Is(err, ErrPartitionNotFound{})
+// matches regardless of partition and description
+func (e ErrPartitionNotFound) Is(target error) bool {
+	switch target.(type) {
+	case ErrPartitionNotFound, ErrNotFound:
+		return true
+	default:
+		return false
+	}
+}
+
+// Unwrap returns the underlying error, if any
+func (e ErrPartitionNotFound) Unwrap() error {
+	return e.cause
+}
+
+// Retryable indicates whether the operation may succeed if it is retried
+func (e ErrPartitionNotFound) Retryable() bool {
+	return false
+}
+
+func (e ErrUnauthorized) Error() string {
+	return "unauthorized: " + e.Description
+}
+
+// Is reports whether target is an ErrUnauthorized, so errors.Is(err, ErrUnauthorized{}) matches regardless of
+// description
+func (e ErrUnauthorized) Is(target error) bool {
+	_, ok := target.(ErrUnauthorized)
+	return ok
+}
+
+// Unwrap returns the underlying AMQP error, if any
+func (e ErrUnauthorized) Unwrap() error {
+	return e.cause
+}
+
+// Retryable indicates whether the operation may succeed if it is retried
+func (e ErrUnauthorized) Retryable() bool {
+	return false
+}
+
+func (e ErrServerBusy) Error() string {
+	return "server busy: " + e.Description
+}
+
+// Is reports whether target is an ErrServerBusy, so errors.Is(err, ErrServerBusy{}) matches regardless of description
+func (e ErrServerBusy) Is(target error) bool {
+	_, ok := target.(ErrServerBusy)
+	return ok
+}
+
+// Unwrap returns the underlying AMQP error, if any
+func (e ErrServerBusy) Unwrap() error {
+	return e.cause
+}
+
+// Retryable indicates whether the operation may succeed if it is retried
+func (e ErrServerBusy) Retryable() bool {
+	return true
+}
+
+func (e ErrLinkDetached) Error() string {
+	if e.Stolen {
+		return "link stolen by a receiver with a higher epoch: " + e.Description
+	}
+	return "link detached by the service: " + e.Description
+}
+
+// Is reports whether target is an ErrLinkDetached, so errors.Is(err, ErrLinkDetached{}) matches regardless of
+// description
+func (e ErrLinkDetached) Is(target error) bool {
+	_, ok := target.(ErrLinkDetached)
+	return ok
+}
+
+// Unwrap returns the underlying AMQP error
+func (e ErrLinkDetached) Unwrap() error {
+	return e.cause
+}
+
+// Retryable indicates whether the operation may succeed if it is retried
+func (e ErrLinkDetached) Retryable() bool {
+	return !e.Stolen
+}
+
+func (e ErrResourceLimitExceeded) Error() string {
+	return "resource limit exceeded: " + e.Description
+}
+
+// Is reports whether target is an ErrResourceLimitExceeded, so errors.Is(err, ErrResourceLimitExceeded{}) matches
+// regardless of description
+func (e ErrResourceLimitExceeded) Is(target error) bool {
+	_, ok := target.(ErrResourceLimitExceeded)
+	return ok
+}
+
+// Unwrap returns the underlying AMQP error
+func (e ErrResourceLimitExceeded) Unwrap() error {
+	return e.cause
+}
+
+// Retryable indicates whether the operation may succeed if it is retried
+func (e ErrResourceLimitExceeded) Retryable() bool {
+	return true
+}
+
+func (e AMQPError) Error() string {
+	return fmt.Sprintf("amqp error %s: %s", e.Condition, e.Description)
+}
+
+// Unwrap returns the underlying AMQP error
+func (e AMQPError) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether the condition of the error means target, so errors.Is(err, ErrMessageTooLarge) matches messages
+// the service rejected for their size
+func (e AMQPError) Is(target error) bool {
+	return target == ErrMessageTooLarge && amqp.ErrorCondition(e.Condition) == conditionMessageSizeExceeded
+}
+
+// Retryable indicates whether the operation may succeed if it is retried
+func (e AMQPError) Retryable() bool {
+	switch amqp.ErrorCondition(e.Condition) {
+	case errorTimeout, conditionInternalError:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e ManagementError) Error() string {
+	return fmt.Sprintf("management request failed with status code %d and description: %s", e.Code, e.Description)
+}
+
+// Retryable indicates whether the request may succeed if it is retried
+func (e ManagementError) Retryable() bool {
+	return e.Code >= 500 || e.Code == http.StatusRequestTimeout
+}
+
+func (e ErrManagementCircuitOpen) Error() string {
+	return fmt.Sprintf("management circuit breaker is open after repeated failures; retry after %v", e.RetryAfter)
+}
+
+// IsRetryable reports whether an error returned by the package indicates that the failed operation may succeed if it is
+// retried. Errors which don't classify themselves are not retryable.
+func IsRetryable(err error) bool {
+	var r retryableError
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+	return false
+}
+
+// fromAMQPError maps an error condition reported by the service to the package's typed errors. The original error
+// stays reachable through errors.As. Errors which don't carry an AMQP condition are returned unchanged.
+func fromAMQPError(err error) error {
+	remote := remoteAMQPError(err)
+	if remote == nil {
+		return err
+	}
+
+	switch remote.Condition {
+	case errorServerBusy:
+		return ErrServerBusy{Description: remote.Description, cause: err}
+	case conditionUnauthorizedAccess:
+		return ErrUnauthorized{Description: remote.Description, cause: err}
+	case conditionNotFound:
+		return ErrNotFound{Description: remote.Description, cause: err}
+	case conditionResourceLimitExceeded:
+		return ErrResourceLimitExceeded{Description: remote.Description, cause: err}
+	case conditionDetachForced:
+		return ErrLinkDetached{Description: remote.Description, cause: err}
+	case conditionLinkStolen:
+		return ErrLinkDetached{Description: remote.Description, Stolen: true, cause: err}
+	default:
+		return AMQPError{
+			Condition:   string(remote.Condition),
+			Description: remote.Description,
+			Info:        remote.Info,
+			cause:       err,
+		}
+	}
+}
+
+// partitionNotFound returns an ErrPartitionNotFound if err reports that partitionID doesn't exist, and err otherwise.
+// The service rejects links to a partition beyond the partition count as out of range.
+func partitionNotFound(partitionID string, err error) error {
+	var notFound ErrNotFound
+	if errors.As(err, &notFound) {
+		return ErrPartitionNotFound{PartitionID: partitionID, Description: notFound.Description, cause: err}
+	}
+	if remote := remoteAMQPError(err); remote != nil && remote.Condition == conditionArgumentOutOfRange {
+		return ErrPartitionNotFound{PartitionID: partitionID, Description: remote.Description, cause: err}
+	}
+	return err
+}
+
+// remoteAMQPError returns the error condition the service reported, whether directly or as the reason for detaching a
+// link, or nil if err does not carry one
+func remoteAMQPError(err error) *amqp.Error {
+	var detach *amqp.DetachError
+	if errors.As(err, &detach) {
+		return detach.RemoteError
+	}
+
+	var remote *amqp.Error
+	if errors.As(err, &remote) {
+		return remote
+	}
+	return nil
+}
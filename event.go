@@ -25,6 +25,7 @@ package eventhub
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -39,6 +40,7 @@ const (
 	partitionKeyAnnotationName string = "x-opt-partition-key"
 	sequenceNumberName         string = "x-opt-sequence-number"
 	enqueueTimeName            string = "x-opt-enqueued-time"
+	publisherAnnotationName    string = "x-opt-publisher"
 )
 
 type (
@@ -50,12 +52,37 @@ type (
 
 		ID string
 
+		// ContentType is the MIME type of Data. It is sent as the content-type property of the AMQP message and set on
+		// received events from the same property.
+		ContentType string
+
 		message          *amqp.Message
+		codecs           *codecRegistry
 		SystemProperties *SystemProperties
 
-		// RawAMQPMessage is a subset of fields from the underlying AMQP message.
-		// NOTE: These fields are only used when receiving events and are not sent.
+		// pooled is set on events of receivers with event pooling; retained counts the holders which retained the event
+		// and haven't released it yet
+		pooled   bool
+		retained int32
+
+		// RawAMQPMessage holds the sections of the underlying AMQP message which have no dedicated field on Event. They
+		// are set on received events and sent with events, so bridging and diagnostic tools can pass messages on
+		// without losing information. Annotations with numeric keys, which the protocol reserves, are not kept.
 		RawAMQPMessage struct {
+			// Header carries the delivery details of the message
+			Header *MessageHeader
+
+			// DeliveryAnnotations are annotations for the infrastructure of a single hop
+			DeliveryAnnotations map[string]interface{}
+
+			// MessageAnnotations are annotations for the infrastructure along the whole path of the message. The
+			// annotations of the service are also available as SystemProperties; those are sent in preference to the
+			// ones here.
+			MessageAnnotations map[string]interface{}
+
+			// Footer holds details of the message computed from its other sections, such as hashes or signatures
+			Footer map[string]interface{}
+
 			// Properties are standard properties for an AMQP message.
 			Properties struct {
 				// The identity of the user responsible for producing the message.
@@ -85,10 +112,45 @@ type (
 
 				// A common field for summary information about the message content and purpose.
 				Subject string
+
+				// The address of the node the message is destined for.
+				To string
+
+				// The address of the node to send replies to.
+				ReplyTo string
+
+				// The time after which the message is considered expired.
+				AbsoluteExpiryTime time.Time
+
+				// The time the message was created.
+				CreationTime time.Time
+
+				// The group the message belongs to.
+				GroupID string
+
+				// The position of the message within its group.
+				GroupSequence uint32
+
+				// The group replies to the message should be sent to.
+				ReplyToGroupID string
 			}
 		}
 	}
 
+	// MessageHeader is the header section of an AMQP message
+	MessageHeader struct {
+		// Durable asks intermediaries to store the message durably
+		Durable bool
+		// Priority is the relative priority of the message
+		Priority uint8
+		// TTL is how long the message is live for
+		TTL time.Duration
+		// FirstAcquirer reports whether the message has not been acquired by a receiver before
+		FirstAcquirer bool
+		// DeliveryCount is the number of unsuccessful prior attempts to deliver the message
+		DeliveryCount uint32
+	}
+
 	// SystemProperties are used to store properties that are set by the system.
 	SystemProperties struct {
 		SequenceNumber *int64     `mapstructure:"x-opt-sequence-number"` // unique sequence number of the message
@@ -128,11 +190,27 @@ func NewEvent(data []byte) *Event {
 	}
 }
 
-// GetCheckpoint returns the checkpoint information on the Event
+// GetCheckpoint returns the checkpoint information on the Event. Events which weren't received from a Hub get theirs
+// from their system properties.
 func (e *Event) GetCheckpoint() persist.Checkpoint {
 	var offset string
 	var enqueueTime time.Time
 	var sequenceNumber int64
+	if e.message == nil {
+		if sp := e.SystemProperties; sp != nil {
+			if sp.Offset != nil {
+				offset = strconv.FormatInt(*sp.Offset, 10)
+			}
+			if sp.EnqueuedTime != nil {
+				enqueueTime = *sp.EnqueuedTime
+			}
+			if sp.SequenceNumber != nil {
+				sequenceNumber = *sp.SequenceNumber
+			}
+		}
+		return persist.NewCheckpoint(offset, sequenceNumber, enqueueTime)
+	}
+
 	if val, ok := e.message.Annotations[offsetAnnotationName]; ok {
 		offset = fmt.Sprintf("%v", val)
 	}
@@ -173,22 +251,168 @@ func (e *Event) Get(key string) (interface{}, bool) {
 	return nil, false
 }
 
+// GetEnqueuedTime returns the time the event was enqueued in its partition, if known
+func (e *Event) GetEnqueuedTime() (time.Time, bool) {
+	if e.SystemProperties != nil && e.SystemProperties.EnqueuedTime != nil {
+		return *e.SystemProperties.EnqueuedTime, true
+	}
+
+	switch val := e.annotation(enqueueTimeName).(type) {
+	case time.Time:
+		return val, true
+	case int64:
+		return time.Unix(0, val*int64(time.Millisecond)), true
+	}
+	return time.Time{}, false
+}
+
+// GetSequenceNumber returns the sequence number of the event in its partition, if known
+func (e *Event) GetSequenceNumber() (int64, bool) {
+	if e.SystemProperties != nil && e.SystemProperties.SequenceNumber != nil {
+		return *e.SystemProperties.SequenceNumber, true
+	}
+
+	val, ok := e.annotation(sequenceNumberName).(int64)
+	return val, ok
+}
+
+// GetOffset returns the offset of the event in its partition, if known
+func (e *Event) GetOffset() (int64, bool) {
+	if e.SystemProperties != nil && e.SystemProperties.Offset != nil {
+		return *e.SystemProperties.Offset, true
+	}
+
+	switch val := e.annotation(offsetAnnotationName).(type) {
+	case int64:
+		return val, true
+	case string:
+		offset, err := strconv.ParseInt(val, 10, 64)
+		return offset, err == nil
+	}
+	return 0, false
+}
+
+// GetPartitionKey returns the partition key the event was sent with, if any
+func (e *Event) GetPartitionKey() (string, bool) {
+	if e.SystemProperties != nil && e.SystemProperties.PartitionKey != nil {
+		return *e.SystemProperties.PartitionKey, true
+	}
+	if val, ok := e.annotation(partitionKeyAnnotationName).(string); ok {
+		return val, true
+	}
+	if e.PartitionKey != nil {
+		return *e.PartitionKey, true
+	}
+	return "", false
+}
+
+// GetPublisher returns the name of the publisher the event was sent by, for events sent to a publisher endpoint of the
+// Event Hub
+func (e *Event) GetPublisher() (string, bool) {
+	val, ok := e.annotation(publisherAnnotationName).(string)
+	return val, ok
+}
+
+// annotation returns the message annotation with the given key, if any
+func (e *Event) annotation(key string) interface{} {
+	if e.SystemProperties != nil {
+		if val, ok := e.SystemProperties.Annotations[key]; ok {
+			return val
+		}
+	}
+	if e.message != nil {
+		return e.message.Annotations[key]
+	}
+	return nil
+}
+
+// MarshalBinary encodes the event as an AMQP message, with its system properties as message annotations, as the
+// service delivers it. Events can be stored this way and restored with UnmarshalBinary without losing the types of
+// their properties and annotations.
+func (e *Event) MarshalBinary() ([]byte, error) {
+	msg := amqp.NewMessage(e.Data)
+	msg.Properties = new(amqp.MessageProperties)
+	if len(e.Properties) > 0 {
+		msg.ApplicationProperties = make(map[string]interface{}, len(e.Properties))
+	}
+	if err := e.fillMsg(msg); err != nil {
+		return nil, err
+	}
+	return msg.MarshalBinary()
+}
+
+// UnmarshalBinary decodes an event encoded by MarshalBinary, or any AMQP message, into e as if it had been received
+func (e *Event) UnmarshalBinary(data []byte) error {
+	msg := new(amqp.Message)
+	if err := msg.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	var body []byte
+	if len(msg.Data) > 0 {
+		body = msg.Data[0]
+	}
+	*e = Event{}
+	return populateEvent(e, body, msg)
+}
+
 func (e *Event) toMsg() (*amqp.Message, error) {
 	msg := e.message
 	if msg == nil {
 		msg = amqp.NewMessage(e.Data)
 	}
 
-	msg.Properties = &amqp.MessageProperties{
-		MessageID: e.ID,
+	msg.Properties = new(amqp.MessageProperties)
+	if len(e.Properties) > 0 {
+		msg.ApplicationProperties = make(map[string]interface{}, len(e.Properties))
 	}
+	if err := e.fillMsg(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
 
-	if len(e.Properties) > 0 {
-		msg.ApplicationProperties = make(map[string]interface{})
-		for key, value := range e.Properties {
-			msg.ApplicationProperties[key] = value
+// fillMsg sets the sections of msg from the event. msg must have properties, which are overwritten, and application
+// properties to add to if the event has any.
+func (e *Event) fillMsg(msg *amqp.Message) error {
+	raw := &e.RawAMQPMessage
+	*msg.Properties = amqp.MessageProperties{
+		MessageID:          e.ID,
+		UserID:             raw.Properties.UserID,
+		To:                 raw.Properties.To,
+		Subject:            raw.Properties.Subject,
+		ReplyTo:            raw.Properties.ReplyTo,
+		CorrelationID:      raw.Properties.CorrelationID,
+		ContentType:        e.ContentType,
+		ContentEncoding:    raw.Properties.ContentEncoding,
+		AbsoluteExpiryTime: raw.Properties.AbsoluteExpiryTime,
+		CreationTime:       raw.Properties.CreationTime,
+		GroupID:            raw.Properties.GroupID,
+		GroupSequence:      raw.Properties.GroupSequence,
+		ReplyToGroupID:     raw.Properties.ReplyToGroupID,
+	}
+	if msg.Properties.ContentType == "" {
+		msg.Properties.ContentType = raw.Properties.ContentType
+	}
+
+	if raw.Header != nil {
+		msg.Header = &amqp.MessageHeader{
+			Durable:       raw.Header.Durable,
+			Priority:      raw.Header.Priority,
+			TTL:           raw.Header.TTL,
+			FirstAcquirer: raw.Header.FirstAcquirer,
+			DeliveryCount: raw.Header.DeliveryCount,
 		}
 	}
+	msg.DeliveryAnnotations = addMapToAnnotations(msg.DeliveryAnnotations, raw.DeliveryAnnotations)
+	msg.Footer = addMapToAnnotations(msg.Footer, raw.Footer)
+
+	// the raw message annotations go first, so the system properties and partition key below take precedence
+	msg.Annotations = addMapToAnnotations(msg.Annotations, raw.MessageAnnotations)
+
+	for key, value := range e.Properties {
+		msg.ApplicationProperties[key] = value
+	}
 
 	if e.SystemProperties != nil {
 		// Set the raw annotations first (they may be nil) and add the explicit
@@ -197,7 +421,7 @@ func (e *Event) toMsg() (*amqp.Message, error) {
 
 		sysPropMap, err := encodeStructureToMap(e.SystemProperties)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		msg.Annotations = addMapToAnnotations(msg.Annotations, sysPropMap)
 	}
@@ -209,8 +433,7 @@ func (e *Event) toMsg() (*amqp.Message, error) {
 
 		msg.Annotations[partitionKeyAnnotationName] = e.PartitionKey
 	}
-
-	return msg, nil
+	return nil
 }
 
 func eventFromMsg(msg *amqp.Message) (*Event, error) {
@@ -218,22 +441,48 @@ func eventFromMsg(msg *amqp.Message) (*Event, error) {
 }
 
 func newEvent(data []byte, msg *amqp.Message) (*Event, error) {
-	event := &Event{
-		Data:    data,
-		message: msg,
-	}
+	event := new(Event)
+	err := populateEvent(event, data, msg)
+	return event, err
+}
+
+// populateEvent sets the fields of event from msg. Maps and system properties left on event by reset are reused.
+func populateEvent(event *Event, data []byte, msg *amqp.Message) error {
+	event.Data = data
+	event.message = msg
 
 	if msg.Properties != nil {
 		if id, ok := msg.Properties.MessageID.(string); ok {
 			event.ID = id
 		}
+		event.ContentType = msg.Properties.ContentType
 
 		event.RawAMQPMessage.Properties.UserID = msg.Properties.UserID
 		event.RawAMQPMessage.Properties.Subject = msg.Properties.Subject
 		event.RawAMQPMessage.Properties.CorrelationID = msg.Properties.CorrelationID
 		event.RawAMQPMessage.Properties.ContentEncoding = msg.Properties.ContentEncoding
 		event.RawAMQPMessage.Properties.ContentType = msg.Properties.ContentType
+		event.RawAMQPMessage.Properties.To = msg.Properties.To
+		event.RawAMQPMessage.Properties.ReplyTo = msg.Properties.ReplyTo
+		event.RawAMQPMessage.Properties.AbsoluteExpiryTime = msg.Properties.AbsoluteExpiryTime
+		event.RawAMQPMessage.Properties.CreationTime = msg.Properties.CreationTime
+		event.RawAMQPMessage.Properties.GroupID = msg.Properties.GroupID
+		event.RawAMQPMessage.Properties.GroupSequence = msg.Properties.GroupSequence
+		event.RawAMQPMessage.Properties.ReplyToGroupID = msg.Properties.ReplyToGroupID
+	}
+
+	if msg.Header != nil {
+		event.RawAMQPMessage.Header = &MessageHeader{
+			Durable:       msg.Header.Durable,
+			Priority:      msg.Header.Priority,
+			TTL:           msg.Header.TTL,
+			FirstAcquirer: msg.Header.FirstAcquirer,
+			DeliveryCount: msg.Header.DeliveryCount,
+		}
 	}
+	event.RawAMQPMessage.DeliveryAnnotations = stringKeyed(msg.DeliveryAnnotations)
+	event.RawAMQPMessage.MessageAnnotations = stringKeyedInto(event.RawAMQPMessage.MessageAnnotations, msg.Annotations)
+	event.RawAMQPMessage.Footer = stringKeyed(msg.Footer)
 
 	if msg.Annotations != nil {
 		if val, ok := msg.Annotations[partitionKeyAnnotationName]; ok {
@@ -244,7 +493,7 @@ func newEvent(data []byte, msg *amqp.Message) (*Event, error) {
 
 		if err := mapstructure.WeakDecode(msg.Annotations, &event.SystemProperties); err != nil {
 			fmt.Println("error decoding...", err)
-			return event, err
+			return err
 		}
 
 		// If we didn't populate any system properties, set up the struct so we
@@ -262,19 +511,23 @@ func newEvent(data []byte, msg *amqp.Message) (*Event, error) {
 		// This approach is also consistent with the behavior of .NET:
 		//
 		//	https://docs.microsoft.com/en-us/dotnet/api/azure.messaging.eventhubs.eventdata.systemproperties?view=azure-dotnet#Azure_Messaging_EventHubs_EventData_SystemProperties
-		event.SystemProperties.Annotations = make(map[string]interface{})
+		if event.SystemProperties.Annotations == nil {
+			event.SystemProperties.Annotations = make(map[string]interface{})
+		}
 		for key, val := range msg.Annotations {
 			if s, ok := key.(string); ok {
 				event.SystemProperties.Annotations[s] = val
 			}
 		}
+	} else {
+		event.SystemProperties = nil
 	}
 
 	if msg != nil {
 		event.Properties = msg.ApplicationProperties
 	}
 
-	return event, nil
+	return nil
 }
 
 func encodeStructureToMap(structPointer interface{}) (map[string]interface{}, error) {
@@ -344,6 +597,28 @@ func parseMapStructureTag(tag reflect.StructTag) (*mapStructureTag, error) {
 	return mapTag, nil
 }
 
+// stringKeyed returns the annotations with string keys, or nil if there are none
+func stringKeyed(a amqp.Annotations) map[string]interface{} {
+	return stringKeyedInto(nil, a)
+}
+
+// stringKeyedInto adds the string-keyed annotations of a to m, allocating m if needed, and returns m or nil if it is
+// empty
+func stringKeyedInto(m map[string]interface{}, a amqp.Annotations) map[string]interface{} {
+	for key, val := range a {
+		if s, ok := key.(string); ok {
+			if m == nil {
+				m = make(map[string]interface{})
+			}
+			m[s] = val
+		}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
 func addMapToAnnotations(a amqp.Annotations, m map[string]interface{}) amqp.Annotations {
 	if a == nil && len(m) > 0 {
 		a = make(amqp.Annotations)
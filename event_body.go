@@ -0,0 +1,74 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"bytes"
+	"io"
+)
+
+// BodyReader returns a reader of the body of the event, for decoders which read from an io.Reader, such as
+// json.NewDecoder, so that large bodies are decoded from where they are rather than from a copy. The body of a received
+// AMQP message may be split into several data sections, of which Data holds the first only; the reader reads all of
+// them in order, without joining them into one slice. For other events the reader reads Data.
+//
+// The reader reads the memory of the event, so for events of receivers with event pooling it must not be used after
+// the handler returns unless the event is retained.
+func (e *Event) BodyReader() io.Reader {
+	sections := e.bodySections()
+	if len(sections) == 1 {
+		return bytes.NewReader(sections[0])
+	}
+
+	readers := make([]io.Reader, len(sections))
+	for i, section := range sections {
+		readers[i] = bytes.NewReader(section)
+	}
+	return io.MultiReader(readers...)
+}
+
+// BodySize returns the size in bytes of the body BodyReader reads
+func (e *Event) BodySize() int {
+	size := 0
+	for _, section := range e.bodySections() {
+		size += len(section)
+	}
+	return size
+}
+
+// bodySections returns the data sections of the message the event was received as, or Data alone for events which
+// weren't received or whose Data was replaced since
+func (e *Event) bodySections() [][]byte {
+	if e.message == nil || len(e.message.Data) < 2 || !sameBytes(e.Data, e.message.Data[0]) {
+		return [][]byte{e.Data}
+	}
+	return e.message.Data
+}
+
+// sameBytes reports whether a and b are the same slice, not only equal ones
+func sameBytes(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return len(a) == 0 || &a[0] == &b[0]
+}
@@ -0,0 +1,48 @@
+package eventhub
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvent_BodyReader(t *testing.T) {
+	t.Run("Data", func(t *testing.T) {
+		event := NewEventFromString("hello")
+		body, err := ioutil.ReadAll(event.BodyReader())
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(body))
+		assert.Equal(t, 5, event.BodySize())
+	})
+
+	t.Run("SeveralSections", func(t *testing.T) {
+		event, err := eventFromMsg(&amqp.Message{Data: [][]byte{[]byte("hel"), []byte("lo "), []byte("world")}})
+		require.NoError(t, err)
+		assert.Equal(t, "hel", string(event.Data))
+
+		body, err := ioutil.ReadAll(event.BodyReader())
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", string(body))
+		assert.Equal(t, 11, event.BodySize())
+	})
+
+	t.Run("ReplacedData", func(t *testing.T) {
+		event, err := eventFromMsg(&amqp.Message{Data: [][]byte{[]byte("hel"), []byte("lo")}})
+		require.NoError(t, err)
+		event.Data = []byte("bye")
+
+		body, err := ioutil.ReadAll(event.BodyReader())
+		require.NoError(t, err)
+		assert.Equal(t, "bye", string(body))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		body, err := ioutil.ReadAll(NewEvent(nil).BodyReader())
+		require.NoError(t, err)
+		assert.Empty(t, body)
+		assert.Equal(t, 0, NewEvent(nil).BodySize())
+	})
+}
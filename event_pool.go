@@ -0,0 +1,174 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Azure/go-amqp"
+)
+
+var eventPool = sync.Pool{
+	New: func() interface{} {
+		return new(Event)
+	},
+}
+
+// ReceiveWithEventPooling configures the receiver to reuse the Events it hands to the handler, cutting the garbage
+// produced per event for consumers of very high volumes. An Event, with its SystemProperties and annotation maps, goes
+// back to the pool as soon as the handler returns, so the handler must not keep references to them unless it calls
+// Event.Retain, and then Event.Release once done with the event.
+//
+// The body of an event is not pooled: Data is the buffer the event was decoded into and stays valid after the Event is
+// released, as do the Properties and PartitionKey of the event.
+func ReceiveWithEventPooling() ReceiveOption {
+	return func(receiver *receiver) error {
+		receiver.pooled = true
+		return nil
+	}
+}
+
+// Retain keeps a pooled event from going back to the pool when the handler returns, for handlers which pass events on
+// to other goroutines. An event may be retained by several holders, each of which must release it with Release; the
+// event goes back to the pool once all of them have. Retain has no effect on events which aren't pooled.
+func (e *Event) Retain() {
+	if e.pooled {
+		atomic.AddInt32(&e.retained, 1)
+	}
+}
+
+// Release releases an event retained with Retain, returning it to the pool if no other holder retains it. The holder
+// must not use the event afterwards. Release has no effect on events which aren't pooled or retained, which the
+// receiver releases itself.
+func (e *Event) Release() {
+	if !e.pooled {
+		return
+	}
+	for {
+		retained := atomic.LoadInt32(&e.retained)
+		if retained <= 0 {
+			return
+		}
+		if atomic.CompareAndSwapInt32(&e.retained, retained, retained-1) {
+			if retained == 1 {
+				e.recycle()
+			}
+			return
+		}
+	}
+}
+
+// PoolEvents returns a handler which hands handler a pooled copy of each event and returns the copy to the pool once
+// handler returns, unless it is retained, as receivers configured with ReceiveWithEventPooling do. It lets
+// implementations of PartitionedReceiver other than Hub, such as test doubles, honor ReceiveSettings.EventPooling.
+func PoolEvents(handler Handler) Handler {
+	return func(ctx context.Context, event *Event) error {
+		pooled := pooledCopy(event)
+		defer func() {
+			if atomic.LoadInt32(&pooled.retained) == 0 {
+				pooled.recycle()
+			}
+		}()
+		return handler(ctx, pooled)
+	}
+}
+
+// pooledCopy returns a pooled event with the content of event, copying the maps recycle clears
+func pooledCopy(event *Event) *Event {
+	pooled := eventPool.Get().(*Event)
+	systemProperties := pooled.SystemProperties
+	annotations := pooled.RawAMQPMessage.MessageAnnotations
+
+	*pooled = *event
+	pooled.pooled = true
+	pooled.retained = 0
+	if event.SystemProperties != nil {
+		if systemProperties == nil {
+			systemProperties = new(SystemProperties)
+		}
+		raw := systemProperties.Annotations
+		*systemProperties = *event.SystemProperties
+		systemProperties.Annotations = copyAnnotations(raw, event.SystemProperties.Annotations)
+		pooled.SystemProperties = systemProperties
+	}
+	pooled.RawAMQPMessage.MessageAnnotations = copyAnnotations(annotations, event.RawAMQPMessage.MessageAnnotations)
+	return pooled
+}
+
+// copyAnnotations copies from into the emptied map into, allocating it if needed; it returns nil if from is
+func copyAnnotations(into, from map[string]interface{}) map[string]interface{} {
+	if from == nil {
+		return nil
+	}
+	if into == nil {
+		into = make(map[string]interface{}, len(from))
+	}
+	for key, value := range from {
+		into[key] = value
+	}
+	return into
+}
+
+// eventFromMsg returns the event for msg, from the pool if the receiver pools events
+func (r *receiver) eventFromMsg(msg *amqp.Message) (*Event, error) {
+	if !r.pooled {
+		return eventFromMsg(msg)
+	}
+
+	event := eventPool.Get().(*Event)
+	event.pooled = true
+	err := populateEvent(event, msg.Data[0], msg)
+	return event, err
+}
+
+// releaseEvent returns an event the handler is done with to the pool, unless it is retained
+func (r *receiver) releaseEvent(event *Event) {
+	if event == nil || !event.pooled || atomic.LoadInt32(&event.retained) > 0 {
+		return
+	}
+	event.recycle()
+}
+
+// recycle clears the event, keeping its system properties and annotation maps for reuse, and puts it in the pool
+func (e *Event) recycle() {
+	systemProperties := e.SystemProperties
+	annotations := e.RawAMQPMessage.MessageAnnotations
+
+	*e = Event{}
+	if systemProperties != nil {
+		raw := systemProperties.Annotations
+		for key := range raw {
+			delete(raw, key)
+		}
+		*systemProperties = SystemProperties{Annotations: raw}
+		e.SystemProperties = systemProperties
+	}
+	for key := range annotations {
+		delete(annotations, key)
+	}
+	e.RawAMQPMessage.MessageAnnotations = annotations
+
+	eventPool.Put(e)
+}
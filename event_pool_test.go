@@ -0,0 +1,128 @@
+package eventhub
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pooledTestMessage(data string) *amqp.Message {
+	msg := amqp.NewMessage([]byte(data))
+	msg.Annotations = amqp.Annotations{
+		"x-opt-sequence-number": int64(42),
+		"x-opt-partition-key":   "pk",
+	}
+	msg.ApplicationProperties = map[string]interface{}{"kind": "telemetry"}
+	return msg
+}
+
+func TestReceiver_PooledEvents(t *testing.T) {
+	r := new(receiver)
+	require.NoError(t, ReceiveWithEventPooling()(r))
+
+	event, err := r.eventFromMsg(pooledTestMessage("one"))
+	require.NoError(t, err)
+	assert.True(t, event.pooled)
+	assert.Equal(t, "one", string(event.Data))
+	assert.Equal(t, int64(42), *event.SystemProperties.SequenceNumber)
+	assert.Equal(t, "pk", *event.PartitionKey)
+
+	data := event.Data
+	systemProperties := event.SystemProperties
+	annotations := event.SystemProperties.Annotations
+	r.releaseEvent(event)
+
+	assert.Equal(t, "one", string(data), "bodies are not reused")
+	assert.Nil(t, event.Data)
+	assert.Nil(t, event.PartitionKey)
+	assert.Nil(t, event.Properties)
+	assert.Same(t, systemProperties, event.SystemProperties, "system properties are kept for reuse")
+	assert.Nil(t, systemProperties.SequenceNumber)
+	assert.Empty(t, annotations)
+
+	require.NoError(t, populateEvent(event, []byte("two"), amqp.NewMessage([]byte("two"))))
+	assert.Nil(t, event.SystemProperties, "events without annotations have no system properties")
+}
+
+func TestEvent_RetainAndRelease(t *testing.T) {
+	r := &receiver{pooled: true}
+	event, err := r.eventFromMsg(pooledTestMessage("one"))
+	require.NoError(t, err)
+
+	event.Retain()
+	r.releaseEvent(event)
+	assert.Equal(t, "one", string(event.Data), "retained events are not released with the handler")
+	assert.Equal(t, int64(42), *event.SystemProperties.SequenceNumber)
+
+	event.Release()
+	assert.Nil(t, event.Data)
+	assert.Equal(t, int32(0), event.retained)
+}
+
+func TestEvent_RetainAndReleaseWithoutPooling(t *testing.T) {
+	r := new(receiver)
+	event, err := r.eventFromMsg(pooledTestMessage("one"))
+	require.NoError(t, err)
+	assert.False(t, event.pooled)
+
+	event.Retain()
+	event.Release()
+	r.releaseEvent(event)
+	assert.Equal(t, "one", string(event.Data))
+	assert.Equal(t, int64(42), *event.SystemProperties.SequenceNumber)
+}
+
+func TestEvent_RetainCountsHolders(t *testing.T) {
+	r := &receiver{pooled: true}
+	event, err := r.eventFromMsg(pooledTestMessage("one"))
+	require.NoError(t, err)
+
+	event.Retain()
+	event.Retain()
+	r.releaseEvent(event)
+	event.Release()
+	assert.Equal(t, "one", string(event.Data), "the event should not be recycled while another holder retains it")
+	assert.Equal(t, int64(42), *event.SystemProperties.SequenceNumber)
+	assert.Equal(t, int32(1), event.retained)
+
+	event.Release()
+	assert.Nil(t, event.Data)
+	assert.Equal(t, int32(0), event.retained)
+
+	event.Release()
+	assert.Equal(t, int32(0), event.retained, "releasing more than retained should have no effect")
+}
+
+func TestPoolEvents(t *testing.T) {
+	source, err := eventFromMsg(pooledTestMessage("one"))
+	require.NoError(t, err)
+
+	var handled, retained *Event
+	handler := PoolEvents(func(_ context.Context, event *Event) error {
+		assert.True(t, event.pooled)
+		assert.NotSame(t, source, event)
+		assert.Equal(t, "one", string(event.Data))
+		assert.Equal(t, int64(42), *event.SystemProperties.SequenceNumber)
+		if handled == nil {
+			handled = event
+			return nil
+		}
+		event.Retain()
+		retained = event
+		return nil
+	})
+
+	require.NoError(t, handler(context.Background(), source))
+	assert.Nil(t, handled.Data, "the copy should go back to the pool when the handler returns")
+	assert.Equal(t, "one", string(source.Data), "the source event should be left as is")
+	assert.Equal(t, int64(42), *source.SystemProperties.SequenceNumber)
+	assert.Equal(t, "pk", source.SystemProperties.Annotations["x-opt-partition-key"])
+
+	require.NoError(t, handler(context.Background(), source))
+	assert.Equal(t, "one", string(retained.Data), "retained copies should be kept")
+	retained.Release()
+	assert.Nil(t, retained.Data)
+}
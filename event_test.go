@@ -2,6 +2,7 @@ package eventhub
 
 import (
 	"testing"
+	"time"
 
 	"github.com/Azure/go-amqp"
 	"github.com/stretchr/testify/require"
@@ -65,6 +66,7 @@ func TestMessageConversion(t *testing.T) {
 	require.EqualValues(t, "subject", event.RawAMQPMessage.Properties.Subject)
 	require.EqualValues(t, "utf-75", event.RawAMQPMessage.Properties.ContentEncoding)
 	require.EqualValues(t, "application/octet-stream", event.RawAMQPMessage.Properties.ContentType)
+	require.EqualValues(t, "application/octet-stream", event.ContentType)
 
 	// AMQPMessage.ApplicationProperties -> Event.Properties
 	require.EqualValues(t, "applicationProperty1Value", event.Properties["applicationProperty1"])
@@ -73,3 +75,152 @@ func TestMessageConversion(t *testing.T) {
 	require.EqualValues(t, "annotation1Value", event.SystemProperties.Annotations["annotation1"])
 	require.EqualValues(t, "dt-subject-value", event.SystemProperties.Annotations["dt-subject"])
 }
+
+func TestEventContentType(t *testing.T) {
+	event := NewEventFromString("hello world")
+	event.ContentType = "avro/binary+0123456789abcdef"
+
+	msg, err := event.toMsg()
+	require.NoError(t, err)
+	require.Equal(t, "avro/binary+0123456789abcdef", msg.Properties.ContentType)
+
+	received, err := eventFromMsg(msg)
+	require.NoError(t, err)
+	require.Equal(t, event.ContentType, received.ContentType)
+}
+
+func TestEventAMQPFidelity(t *testing.T) {
+	event := NewEventFromString("hello world")
+	event.ID = "id"
+	raw := &event.RawAMQPMessage
+	raw.Header = &MessageHeader{Durable: true, Priority: 7, TTL: time.Minute, DeliveryCount: 2}
+	raw.DeliveryAnnotations = map[string]interface{}{"x-hop": "one"}
+	raw.MessageAnnotations = map[string]interface{}{"x-route": "north", partitionKeyAnnotationName: "overridden"}
+	raw.Footer = map[string]interface{}{"hash": []byte{1, 2, 3}}
+	raw.Properties.To = "to"
+	raw.Properties.ReplyTo = "reply-to"
+	raw.Properties.Subject = "subject"
+	raw.Properties.CorrelationID = "correlation"
+	raw.Properties.ContentType = "text/plain"
+	raw.Properties.CreationTime = time.Date(2021, 6, 3, 16, 48, 47, 0, time.UTC)
+	raw.Properties.GroupID = "group"
+	raw.Properties.GroupSequence = 3
+	key := "key"
+	event.PartitionKey = &key
+
+	msg, err := event.toMsg()
+	require.NoError(t, err)
+	bin, err := msg.MarshalBinary()
+	require.NoError(t, err)
+	decoded := new(amqp.Message)
+	require.NoError(t, decoded.UnmarshalBinary(bin))
+
+	received, err := eventFromMsg(decoded)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(received.Data))
+	require.Equal(t, "id", received.ID)
+	require.Equal(t, "text/plain", received.ContentType, "the raw content type is sent unless the event has one")
+
+	got := received.RawAMQPMessage
+	require.Equal(t, raw.Header, got.Header)
+	require.Equal(t, raw.DeliveryAnnotations, got.DeliveryAnnotations)
+	require.Equal(t, raw.Footer, got.Footer)
+	require.Equal(t, "north", got.MessageAnnotations["x-route"])
+	require.Equal(t, "key", got.MessageAnnotations[partitionKeyAnnotationName], "the partition key takes precedence")
+	require.Equal(t, raw.Properties.To, got.Properties.To)
+	require.Equal(t, raw.Properties.ReplyTo, got.Properties.ReplyTo)
+	require.Equal(t, raw.Properties.Subject, got.Properties.Subject)
+	require.Equal(t, raw.Properties.CorrelationID, got.Properties.CorrelationID)
+	require.Equal(t, raw.Properties.CreationTime, got.Properties.CreationTime.UTC())
+	require.Equal(t, raw.Properties.GroupID, got.Properties.GroupID)
+	require.Equal(t, raw.Properties.GroupSequence, got.Properties.GroupSequence)
+}
+
+func TestEventSystemPropertyAccessors(t *testing.T) {
+	event := NewEventFromString("hello world")
+	_, ok := event.GetEnqueuedTime()
+	require.False(t, ok)
+	_, ok = event.GetSequenceNumber()
+	require.False(t, ok)
+	_, ok = event.GetOffset()
+	require.False(t, ok)
+	_, ok = event.GetPartitionKey()
+	require.False(t, ok)
+	_, ok = event.GetPublisher()
+	require.False(t, ok)
+
+	enqueued := time.Date(2021, 6, 3, 16, 48, 47, 0, time.UTC)
+	received, err := eventFromMsg(&amqp.Message{
+		Annotations: amqp.Annotations{
+			enqueueTimeName:            enqueued,
+			sequenceNumberName:         int64(42),
+			offsetAnnotationName:       "4096",
+			partitionKeyAnnotationName: "key",
+			publisherAnnotationName:    "device-1",
+		},
+		Data: [][]byte{[]byte("hello world")},
+	})
+	require.NoError(t, err)
+
+	enqueuedTime, ok := received.GetEnqueuedTime()
+	require.True(t, ok)
+	require.Equal(t, enqueued, enqueuedTime)
+	sequenceNumber, _ := received.GetSequenceNumber()
+	require.Equal(t, int64(42), sequenceNumber)
+	offset, _ := received.GetOffset()
+	require.Equal(t, int64(4096), offset)
+	partitionKey, _ := received.GetPartitionKey()
+	require.Equal(t, "key", partitionKey)
+	publisher, _ := received.GetPublisher()
+	require.Equal(t, "device-1", publisher)
+
+	annotated := &Event{SystemProperties: &SystemProperties{Annotations: map[string]interface{}{
+		enqueueTimeName:      int64(1000),
+		sequenceNumberName:   int64(7),
+		offsetAnnotationName: int64(8),
+	}}}
+	enqueuedTime, _ = annotated.GetEnqueuedTime()
+	require.Equal(t, time.Unix(1, 0), enqueuedTime)
+	sequenceNumber, _ = annotated.GetSequenceNumber()
+	require.Equal(t, int64(7), sequenceNumber)
+	offset, _ = annotated.GetOffset()
+	require.Equal(t, int64(8), offset)
+}
+
+func TestEvent_MarshalBinaryRoundTrips(t *testing.T) {
+	enqueued := time.Date(2021, 6, 3, 16, 48, 47, 0, time.UTC)
+	received, err := eventFromMsg(&amqp.Message{
+		Properties: &amqp.MessageProperties{MessageID: "id-1", ContentType: "text/plain"},
+		Annotations: amqp.Annotations{
+			enqueueTimeName:            enqueued,
+			sequenceNumberName:         int64(42),
+			offsetAnnotationName:       "4096",
+			partitionKeyAnnotationName: "key",
+			"x-custom":                 int32(7),
+		},
+		ApplicationProperties: map[string]interface{}{"count": int64(3), "ratio": 0.5},
+		Data:                  [][]byte{[]byte("hello world")},
+	})
+	require.NoError(t, err)
+
+	data, err := received.MarshalBinary()
+	require.NoError(t, err)
+	event := new(Event)
+	require.NoError(t, event.UnmarshalBinary(data))
+
+	require.Equal(t, "hello world", string(event.Data))
+	require.Equal(t, "id-1", event.ID)
+	require.Equal(t, "text/plain", event.ContentType)
+	require.Equal(t, int64(3), event.Properties["count"])
+	require.Equal(t, 0.5, event.Properties["ratio"])
+	require.Equal(t, int32(7), event.SystemProperties.Annotations["x-custom"])
+	partitionKey, _ := event.GetPartitionKey()
+	require.Equal(t, "key", partitionKey)
+	enqueuedTime, _ := event.GetEnqueuedTime()
+	require.True(t, enqueued.Equal(enqueuedTime))
+	checkpoint := event.GetCheckpoint()
+	require.Equal(t, "4096", checkpoint.Offset)
+	require.Equal(t, int64(42), checkpoint.SequenceNumber)
+
+	require.Error(t, new(Event).UnmarshalBinary([]byte("garbage")))
+}
@@ -0,0 +1,294 @@
+// Package eventhubtest provides an in-memory stand-in for Event Hubs, so applications and event processor hosts can
+// be tested without Azure.
+//
+// A Broker holds the hubs of a namespace in memory, with partitions, offsets, sequence numbers and consumer groups.
+// Its Hubs are clients of a hub which implement the Sender, PartitionedReceiver and Manager interfaces of the eventhub
+// package and eph.Client, so code written against those interfaces runs unchanged against a broker:
+//
+//	broker, err := eventhubtest.NewBroker(eventhubtest.BrokerWithPartitionCount(2))
+//	hub, err := broker.Hub("telemetry")
+//	err = hub.Send(ctx, eventhub.NewEventFromString("hello"))
+//
+//	host, err := eph.NewWithClient(ctx, hub, leaserCheckpointer, leaserCheckpointer)
+//
+// The broker follows the service where tests are likely to depend on it: events with a partition key always land in
+// the same partition, offsets and sequence numbers grow per partition, receivers start after an offset or a time,
+// consumer groups must exist, receivers with an epoch take partitions over from receivers with a lower one and at most
+// five receivers read a partition per consumer group.
+//
+// Where the behavior of the service itself matters, a Recorder records the traffic of a live run against a hub, and a
+// Player replays the recording offline, without credentials and deterministically:
+//
+//	recorder := eventhubtest.NewRecorder(hub)
+//	// run the application against recorder, then
+//	err = recorder.Recording().Save(file)
+//
+//	recording, err := eventhubtest.LoadRecording(file)
+//	player, err := eventhubtest.NewPlayer(recording)
+//	// run the application against player
+package eventhubtest
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+const (
+	defaultPartitionCount = 4
+	// maxReceiversPerPartition is the number of receivers the service allows per partition and consumer group
+	maxReceiversPerPartition = 5
+)
+
+type (
+	// Broker is an in-memory Event Hubs namespace. Hubs are created as they are first used.
+	Broker struct {
+		partitionCount int
+		consumerGroups map[string]bool
+		now            func() time.Time
+
+		mu   sync.Mutex
+		hubs map[string]*hubState
+	}
+
+	// BrokerOption configures a Broker
+	BrokerOption func(b *Broker) error
+
+	hubState struct {
+		name       string
+		createdAt  time.Time
+		partitions []*partition
+		mu         sync.Mutex
+		next       int
+	}
+
+	partition struct {
+		id string
+
+		mu         sync.Mutex
+		events     []*eventhub.Event
+		nextOffset int64
+		// arrived is closed, and replaced, when events are appended
+		arrived   chan struct{}
+		listeners map[string][]*listener
+	}
+)
+
+// NewBroker creates a new in-memory namespace
+func NewBroker(opts ...BrokerOption) (*Broker, error) {
+	b := &Broker{
+		partitionCount: defaultPartitionCount,
+		consumerGroups: map[string]bool{eventhub.DefaultConsumerGroup: true},
+		now:            time.Now,
+		hubs:           make(map[string]*hubState),
+	}
+	for _, opt := range opts {
+		if err := opt(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// BrokerWithPartitionCount configures the number of partitions of the hubs of the broker, 4 by default
+func BrokerWithPartitionCount(count int) BrokerOption {
+	return func(b *Broker) error {
+		if count < 1 {
+			return fmt.Errorf("partition count must be at least 1, got %d", count)
+		}
+		b.partitionCount = count
+		return nil
+	}
+}
+
+// BrokerWithConsumerGroups creates consumer groups other than $Default on the hubs of the broker. Receiving with a
+// consumer group which doesn't exist fails, as it does with the service.
+func BrokerWithConsumerGroups(names ...string) BrokerOption {
+	return func(b *Broker) error {
+		for _, name := range names {
+			if name == "" {
+				return errors.New("consumer group names must not be empty")
+			}
+			b.consumerGroups[name] = true
+		}
+		return nil
+	}
+}
+
+// BrokerWithClock configures the clock events are enqueued by, time.Now by default
+func BrokerWithClock(now func() time.Time) BrokerOption {
+	return func(b *Broker) error {
+		if now == nil {
+			return errors.New("clock must not be nil")
+		}
+		b.now = now
+		return nil
+	}
+}
+
+// Hub creates a new client of the hub with the given name
+func (b *Broker) Hub(name string, opts ...HubOption) (*Hub, error) {
+	h := &Hub{
+		broker:          b,
+		state:           b.hub(name),
+		offsetPersister: persist.NewMemoryPersister(),
+	}
+	for _, opt := range opts {
+		if err := opt(h); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Events returns the events of a partition of a hub, in the order they were enqueued, with their system properties
+func (b *Broker) Events(hubName, partitionID string) ([]*eventhub.Event, error) {
+	p, err := b.hub(hubName).partition(partitionID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	events := make([]*eventhub.Event, len(p.events))
+	for i, event := range p.events {
+		events[i] = copyEvent(event)
+	}
+	return events, nil
+}
+
+func (b *Broker) hub(name string) *hubState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if h, ok := b.hubs[name]; ok {
+		return h
+	}
+
+	h := &hubState{
+		name:       name,
+		createdAt:  b.now(),
+		partitions: make([]*partition, b.partitionCount),
+	}
+	for i := range h.partitions {
+		h.partitions[i] = &partition{
+			id:        strconv.Itoa(i),
+			arrived:   make(chan struct{}),
+			listeners: make(map[string][]*listener),
+		}
+	}
+	b.hubs[name] = h
+	return h
+}
+
+func (h *hubState) partition(partitionID string) (*partition, error) {
+	for _, p := range h.partitions {
+		if p.id == partitionID {
+			return p, nil
+		}
+	}
+	return nil, eventhub.ErrPartitionNotFound{
+		PartitionID: partitionID,
+		Description: fmt.Sprintf("partition %q of event hub %q does not exist", partitionID, h.name),
+	}
+}
+
+func (h *hubState) partitionIDs() []string {
+	ids := make([]string, len(h.partitions))
+	for i, p := range h.partitions {
+		ids[i] = p.id
+	}
+	return ids
+}
+
+// partitionFor picks the partition of events with the given partition key, in turn for events without one
+func (h *hubState) partitionFor(partitionKey *string) *partition {
+	if partitionKey != nil {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(*partitionKey))
+		return h.partitions[hash.Sum32()%uint32(len(h.partitions))]
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p := h.partitions[h.next%len(h.partitions)]
+	h.next++
+	return p
+}
+
+// append enqueues events, setting their system properties
+func (p *partition) append(now time.Time, events ...*eventhub.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, event := range events {
+		stored := copyEvent(event)
+		sequenceNumber := int64(len(p.events))
+		offset := p.nextOffset
+		enqueued := now
+		stored.SystemProperties = &eventhub.SystemProperties{
+			SequenceNumber: &sequenceNumber,
+			Offset:         &offset,
+			EnqueuedTime:   &enqueued,
+			PartitionKey:   stored.PartitionKey,
+		}
+		p.events = append(p.events, stored)
+		p.nextOffset += int64(len(event.Data))
+	}
+
+	close(p.arrived)
+	p.arrived = make(chan struct{})
+}
+
+// copyEvent copies event, so neither senders nor handlers see what the other does to theirs
+func copyEvent(event *eventhub.Event) *eventhub.Event {
+	c := &eventhub.Event{
+		Data:           append([]byte(nil), event.Data...),
+		ID:             event.ID,
+		ContentType:    event.ContentType,
+		RawAMQPMessage: event.RawAMQPMessage,
+	}
+	if event.PartitionKey != nil {
+		key := *event.PartitionKey
+		c.PartitionKey = &key
+	}
+	if event.Properties != nil {
+		c.Properties = make(map[string]interface{}, len(event.Properties))
+		for k, v := range event.Properties {
+			c.Properties[k] = v
+		}
+	}
+	if sp := event.SystemProperties; sp != nil {
+		copied := *sp
+		c.SystemProperties = &copied
+	}
+	return c
+}
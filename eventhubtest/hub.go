@@ -0,0 +1,473 @@
+package eventhubtest
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+type (
+	// Hub is a client of a hub of a Broker. It is safe for concurrent use.
+	Hub struct {
+		broker            *Broker
+		state             *hubState
+		senderPartitionID *string
+		offsetPersister   persist.CheckpointPersister
+
+		mu        sync.Mutex
+		closed    bool
+		listeners []*listener
+	}
+
+	// HubOption configures a Hub
+	HubOption func(h *Hub) error
+
+	// listener delivers the events of a partition to a handler
+	listener struct {
+		partition     *partition
+		consumerGroup string
+		epoch         *int64
+		ctx           context.Context
+		cancel        context.CancelFunc
+		done          chan struct{}
+
+		mu  sync.Mutex
+		err error
+	}
+)
+
+// maxMessageSize is the largest event or batch the broker accepts, as the service does on standard namespaces
+var maxMessageSize = int(eventhub.DefaultMaxMessageSizeInBytes)
+
+// HubWithPartitionedSender configures the Hub to send to a specific partition, like eventhub.HubWithPartitionedSender
+func HubWithPartitionedSender(partitionID string) HubOption {
+	return func(h *Hub) error {
+		if _, err := h.state.partition(partitionID); err != nil {
+			return err
+		}
+		h.senderPartitionID = &partitionID
+		return nil
+	}
+}
+
+// HubWithOffsetPersistence configures where the Hub records the checkpoint of the last event handled by each
+// receiver, and where receivers started without a starting position resume from, like
+// eventhub.HubWithOffsetPersistence. The default keeps checkpoints in memory.
+func HubWithOffsetPersistence(persister persist.CheckpointPersister) HubOption {
+	return func(h *Hub) error {
+		if persister == nil {
+			return errors.New("persister must not be nil")
+		}
+		h.offsetPersister = persister
+		return nil
+	}
+}
+
+// Send enqueues event on the partition of its partition key, or on the partitions in turn when it has none
+func (h *Hub) Send(ctx context.Context, event *eventhub.Event, _ ...eventhub.SendOption) error {
+	if err := h.checkSend(ctx); err != nil {
+		return err
+	}
+	if len(event.Data) > maxMessageSize {
+		return eventhub.ErrMessageTooLarge
+	}
+
+	p, err := h.partitionFor(event.PartitionKey)
+	if err != nil {
+		return err
+	}
+	p.append(h.broker.now(), event)
+	return nil
+}
+
+// SendBatch enqueues the events of iterator, which must be an *eventhub.EventBatchIterator. The events of a partition
+// key land on the partition of the key; the events without a key land together on one partition.
+func (h *Hub) SendBatch(ctx context.Context, iterator eventhub.BatchIterator, _ ...eventhub.BatchOption) error {
+	if err := h.checkSend(ctx); err != nil {
+		return err
+	}
+
+	ebi, ok := iterator.(*eventhub.EventBatchIterator)
+	if !ok {
+		return fmt.Errorf("eventhubtest: batches must be sent with an *eventhub.EventBatchIterator, got a %T", iterator)
+	}
+
+	for key, events := range ebi.PartitionEventsMap {
+		events = events[ebi.Cursors[key]:]
+		if len(events) == 0 {
+			continue
+		}
+
+		size := 0
+		for _, event := range events {
+			size += len(event.Data)
+		}
+		if size > maxMessageSize {
+			return eventhub.ErrMessageTooLarge
+		}
+
+		p, err := h.partitionFor(events[0].PartitionKey)
+		if err != nil {
+			return err
+		}
+		p.append(h.broker.now(), events...)
+		ebi.Cursors[key] = len(ebi.PartitionEventsMap[key])
+	}
+	return nil
+}
+
+// Receive delivers the events of a partition to handler, from the starting position of opts or, without one, after
+// the last event a receiver of the Hub handled, until the returned handle is closed. Events already enqueued are
+// delivered first, then events as they are enqueued.
+func (h *Hub) Receive(ctx context.Context, partitionID string, handler eventhub.Handler, opts ...eventhub.ReceiveOption) (*eventhub.ListenerHandle, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	settings, err := eventhub.ResolveReceiveOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !h.broker.consumerGroups[settings.ConsumerGroup] {
+		return nil, eventhub.ErrNotFound{
+			Description: fmt.Sprintf("consumer group %q of event hub %q does not exist", settings.ConsumerGroup, h.state.name),
+		}
+	}
+
+	p, err := h.state.partition(partitionID)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := settings.StartingCheckpoint
+	if checkpoint == (persist.Checkpoint{}) {
+		if checkpoint, err = h.offsetPersister.Read("", h.state.name, settings.ConsumerGroup, partitionID); err != nil {
+			return nil, err
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, eventhub.ErrHubClosed
+	}
+
+	l, start, err := p.attach(settings.ConsumerGroup, settings.Epoch, checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	h.listeners = append(h.listeners, l)
+
+	if settings.EventPooling {
+		handler = eventhub.PoolEvents(handler)
+	}
+	go h.deliver(l, start, handler)
+	return eventhub.NewListenerHandle(l.ctx, l.close, l.error), nil
+}
+
+// Peek returns up to maxEvents events of a partition, from the starting position of opts or from the start of the
+// partition, without attaching a receiver or reading the offset persister of the Hub. Like the service, it refuses
+// epochs and consumer groups receiving with an epoch.
+func (h *Hub) Peek(ctx context.Context, partitionID string, maxEvents int, opts ...eventhub.ReceiveOption) ([]*eventhub.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if maxEvents <= 0 {
+		return nil, fmt.Errorf("the number of events to peek must be positive, got %d", maxEvents)
+	}
+
+	settings, err := eventhub.ResolveReceiveOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if settings.Epoch != nil {
+		return nil, errors.New("peeking with an epoch would disconnect the receivers of the consumer group")
+	}
+	if !h.broker.consumerGroups[settings.ConsumerGroup] {
+		return nil, eventhub.ErrNotFound{
+			Description: fmt.Sprintf("consumer group %q of event hub %q does not exist", settings.ConsumerGroup, h.state.name),
+		}
+	}
+
+	p, err := h.state.partition(partitionID)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := settings.StartingCheckpoint
+	if checkpoint == (persist.Checkpoint{}) {
+		checkpoint = persist.NewCheckpointFromStartOfStream()
+	}
+
+	h.mu.Lock()
+	closed := h.closed
+	h.mu.Unlock()
+	if closed {
+		return nil, eventhub.ErrHubClosed
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, other := range p.listeners[settings.ConsumerGroup] {
+		if other.epoch != nil {
+			return nil, fmt.Errorf("receiver without an epoch can't connect to partition %s while a receiver with epoch %d is connected", p.id, *other.epoch)
+		}
+	}
+
+	start := startIndex(p.events, checkpoint)
+	end := start + maxEvents
+	if end > len(p.events) {
+		end = len(p.events)
+	}
+	events := make([]*eventhub.Event, 0, end-start)
+	for _, event := range p.events[start:end] {
+		events = append(events, copyEvent(event))
+	}
+	return events, nil
+}
+
+// GetRuntimeInformation returns the runtime information of the hub
+func (h *Hub) GetRuntimeInformation(ctx context.Context) (*eventhub.HubRuntimeInformation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &eventhub.HubRuntimeInformation{
+		Path:           h.state.name,
+		CreatedAt:      h.state.createdAt,
+		PartitionCount: len(h.state.partitions),
+		PartitionIDs:   h.state.partitionIDs(),
+	}, nil
+}
+
+// GetPartitionInformation returns the runtime information of a partition of the hub
+func (h *Hub) GetPartitionInformation(ctx context.Context, partitionID string) (*eventhub.HubPartitionRuntimeInformation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p, err := h.state.partition(partitionID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	info := &eventhub.HubPartitionRuntimeInformation{
+		HubPath:            h.state.name,
+		PartitionID:        partitionID,
+		LastSequenceNumber: -1,
+		LastEnqueuedOffset: persist.StartOfStream,
+	}
+	if n := len(p.events); n > 0 {
+		last := p.events[n-1].SystemProperties
+		info.LastSequenceNumber = *last.SequenceNumber
+		info.LastEnqueuedOffset = strconv.FormatInt(*last.Offset, 10)
+		info.LastEnqueuedTimeUtc = *last.EnqueuedTime
+	}
+	return info, nil
+}
+
+// Close closes the receivers of the Hub. Sends and receives after Close fail.
+func (h *Hub) Close(ctx context.Context) error {
+	h.mu.Lock()
+	h.closed = true
+	listeners := h.listeners
+	h.listeners = nil
+	h.mu.Unlock()
+
+	for _, l := range listeners {
+		if err := l.close(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Hub) checkSend(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return eventhub.ErrHubClosed
+	}
+	return nil
+}
+
+func (h *Hub) partitionFor(partitionKey *string) (*partition, error) {
+	if h.senderPartitionID != nil {
+		return h.state.partition(*h.senderPartitionID)
+	}
+	return h.state.partitionFor(partitionKey), nil
+}
+
+// deliver hands the events of the partition of l to handler, from index start on, until l is closed
+func (h *Hub) deliver(l *listener, start int, handler eventhub.Handler) {
+	defer close(l.done)
+	defer l.partition.detach(l)
+
+	p := l.partition
+	for next := start; ; next++ {
+		p.mu.Lock()
+		for next >= len(p.events) {
+			arrived := p.arrived
+			p.mu.Unlock()
+			select {
+			case <-l.ctx.Done():
+				return
+			case <-arrived:
+			}
+			p.mu.Lock()
+		}
+		event := copyEvent(p.events[next])
+		p.mu.Unlock()
+
+		if l.ctx.Err() != nil {
+			return
+		}
+		if err := handler(l.ctx, event); err != nil {
+			// like the service, the broker doesn't redeliver events a handler failed
+			continue
+		}
+		_ = h.offsetPersister.Write("", h.state.name, l.consumerGroup, p.id, event.GetCheckpoint())
+	}
+}
+
+// attach adds a listener for consumerGroup, enforcing the epoch and receiver limits of the service, and returns it
+// with the index of the first event after checkpoint
+func (p *partition) attach(consumerGroup string, epoch *int64, checkpoint persist.Checkpoint) (*listener, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current := p.listeners[consumerGroup]
+	var stolen []*listener
+	for _, other := range current {
+		switch {
+		case epoch == nil && other.epoch != nil:
+			return nil, 0, fmt.Errorf("receiver without an epoch can't connect to partition %s while a receiver with epoch %d is connected", p.id, *other.epoch)
+		case epoch != nil && other.epoch != nil && *other.epoch > *epoch:
+			return nil, 0, fmt.Errorf("receiver with epoch %d can't connect to partition %s while a receiver with epoch %d is connected", *epoch, p.id, *other.epoch)
+		case epoch != nil && (other.epoch == nil || *other.epoch < *epoch):
+			stolen = append(stolen, other)
+		}
+	}
+	if len(current)-len(stolen) >= maxReceiversPerPartition {
+		return nil, 0, fmt.Errorf("at most %d receivers can read partition %s with consumer group %q", maxReceiversPerPartition, p.id, consumerGroup)
+	}
+
+	for _, other := range stolen {
+		other.fail(fmt.Errorf("receiver was disconnected from partition %s by a receiver with epoch %d", p.id, *epoch))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &listener{
+		partition:     p,
+		consumerGroup: consumerGroup,
+		epoch:         epoch,
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	p.listeners[consumerGroup] = append(p.listeners[consumerGroup], l)
+	return l, startIndex(p.events, checkpoint), nil
+}
+
+// detach removes a listener
+func (p *partition) detach(l *listener) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	listeners := p.listeners[l.consumerGroup]
+	for i, other := range listeners {
+		if other == l {
+			p.listeners[l.consumerGroup] = append(listeners[:i:i], listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// startIndex returns the index of the first of events after checkpoint
+func startIndex(events []*eventhub.Event, checkpoint persist.Checkpoint) int {
+	switch checkpoint.Offset {
+	case persist.StartOfStream:
+		return 0
+	case persist.EndOfStream:
+		return len(events)
+	case "":
+		if checkpoint.EnqueueTime.IsZero() {
+			return 0
+		}
+		for i, event := range events {
+			if enqueued, ok := event.GetEnqueuedTime(); ok && enqueued.After(checkpoint.EnqueueTime) {
+				return i
+			}
+		}
+		return len(events)
+	}
+
+	offset, err := strconv.ParseInt(checkpoint.Offset, 10, 64)
+	if err != nil {
+		return 0
+	}
+	for i, event := range events {
+		if o, ok := event.GetOffset(); ok && o > offset {
+			return i
+		}
+	}
+	return len(events)
+}
+
+// close stops the listener and waits for its handler to return
+func (l *listener) close(ctx context.Context) error {
+	l.cancel()
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fail stops the listener with err, without waiting for its handler
+func (l *listener) fail(err error) {
+	l.mu.Lock()
+	l.err = err
+	l.mu.Unlock()
+	l.cancel()
+}
+
+func (l *listener) error() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
@@ -0,0 +1,307 @@
+package eventhubtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+func newTestHub(t *testing.T, opts ...BrokerOption) (*Broker, *Hub) {
+	broker, err := NewBroker(opts...)
+	require.NoError(t, err)
+	hub, err := broker.Hub("hub")
+	require.NoError(t, err)
+	return broker, hub
+}
+
+// receive collects the data of the events handler is called with
+func receive(t *testing.T, hub *Hub, partitionID string, opts ...eventhub.ReceiveOption) (*eventhub.ListenerHandle, <-chan string) {
+	received := make(chan string, 100)
+	handle, err := hub.Receive(context.Background(), partitionID, func(ctx context.Context, event *eventhub.Event) error {
+		received <- string(event.Data)
+		return nil
+	}, opts...)
+	require.NoError(t, err)
+	return handle, received
+}
+
+func expect(t *testing.T, received <-chan string, want ...string) {
+	for _, w := range want {
+		select {
+		case got := <-received:
+			assert.Equal(t, w, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", w)
+		}
+	}
+	select {
+	case got := <-received:
+		t.Fatalf("unexpected event %q", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestHub_SendAssignsSystemProperties(t *testing.T) {
+	broker, hub := newTestHub(t, BrokerWithPartitionCount(2))
+	ctx := context.Background()
+
+	key := "device-1"
+	for i := 0; i < 3; i++ {
+		event := eventhub.NewEventFromString(fmt.Sprintf("event-%d", i))
+		event.PartitionKey = &key
+		require.NoError(t, hub.Send(ctx, event))
+	}
+
+	var keyed []*eventhub.Event
+	for _, partitionID := range []string{"0", "1"} {
+		events, err := broker.Events("hub", partitionID)
+		require.NoError(t, err)
+		keyed = append(keyed, events...)
+	}
+	require.Len(t, keyed, 3, "events of a key land on one partition")
+	for i, event := range keyed {
+		assert.Equal(t, fmt.Sprintf("event-%d", i), string(event.Data))
+		assert.Equal(t, int64(i), *event.SystemProperties.SequenceNumber)
+		assert.Equal(t, int64(i*len("event-0")), *event.SystemProperties.Offset)
+		assert.Equal(t, key, *event.SystemProperties.PartitionKey)
+	}
+
+	_, err := broker.Events("hub", "2")
+	assert.Error(t, err)
+	assert.Equal(t, eventhub.ErrMessageIsTooBig, hub.Send(ctx, eventhub.NewEvent(make([]byte, maxMessageSize+1))))
+}
+
+func TestHub_SendRoundRobinsEventsWithoutKey(t *testing.T) {
+	broker, hub := newTestHub(t, BrokerWithPartitionCount(3))
+	for i := 0; i < 6; i++ {
+		require.NoError(t, hub.Send(context.Background(), eventhub.NewEventFromString("event")))
+	}
+
+	for _, partitionID := range []string{"0", "1", "2"} {
+		events, err := broker.Events("hub", partitionID)
+		require.NoError(t, err)
+		assert.Len(t, events, 2)
+	}
+}
+
+func TestHub_SendBatch(t *testing.T) {
+	broker, err := NewBroker(BrokerWithPartitionCount(2))
+	require.NoError(t, err)
+	hub, err := broker.Hub("hub", HubWithPartitionedSender("1"))
+	require.NoError(t, err)
+
+	events := []*eventhub.Event{
+		eventhub.NewEventFromString("a"),
+		eventhub.NewEventFromString("b"),
+		eventhub.NewEventFromString("c"),
+	}
+	require.NoError(t, hub.SendBatch(context.Background(), eventhub.NewEventBatchIterator(events...)))
+
+	stored, err := broker.Events("hub", "1")
+	require.NoError(t, err)
+	require.Len(t, stored, 3)
+	assert.Equal(t, "c", string(stored[2].Data))
+
+	_, err = broker.Hub("hub", HubWithPartitionedSender("7"))
+	assert.True(t, errors.Is(err, eventhub.ErrPartitionNotFound{}))
+}
+
+func TestHub_ReceiveStartingPositions(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	broker, hub := newTestHub(t, BrokerWithPartitionCount(1), BrokerWithClock(func() time.Time { return now }))
+	defer func() { _ = hub.Close(context.Background()) }()
+	ctx := context.Background()
+	for _, data := range []string{"a", "b", "c"} {
+		require.NoError(t, hub.Send(ctx, eventhub.NewEventFromString(data)))
+		now = now.Add(time.Minute)
+	}
+
+	_, fromStart := receive(t, hub, "0", eventhub.ReceiveWithStartingOffset(persist.StartOfStream))
+	expect(t, fromStart, "a", "b", "c")
+
+	_, afterOffset := receive(t, hub, "0", eventhub.ReceiveWithStartingOffset("0"))
+	expect(t, afterOffset, "b", "c")
+
+	_, fromTime := receive(t, hub, "0", eventhub.ReceiveFromTimestamp(time.Date(2021, 6, 1, 0, 1, 30, 0, time.UTC)))
+	expect(t, fromTime, "c")
+
+	_, latest := receive(t, hub, "0", eventhub.ReceiveWithLatestOffset())
+	expect(t, latest)
+
+	require.NoError(t, hub.Send(ctx, eventhub.NewEventFromString("d")))
+	expect(t, fromStart, "d")
+	expect(t, latest, "d")
+
+	events, err := broker.Events("hub", "0")
+	require.NoError(t, err)
+	assert.Len(t, events, 4)
+}
+
+func TestHub_ReceiveResumesFromPersister(t *testing.T) {
+	broker, err := NewBroker(BrokerWithPartitionCount(1))
+	require.NoError(t, err)
+	persister := persist.NewMemoryPersister()
+	hub, err := broker.Hub("hub", HubWithOffsetPersistence(persister))
+	require.NoError(t, err)
+	ctx := context.Background()
+	defer func() { _ = hub.Close(ctx) }()
+
+	require.NoError(t, hub.Send(ctx, eventhub.NewEventFromString("a")))
+	require.NoError(t, hub.Send(ctx, eventhub.NewEventFromString("b")))
+
+	handle, received := receive(t, hub, "0")
+	expect(t, received, "a", "b")
+	require.NoError(t, handle.Close(ctx))
+
+	checkpoint, err := persister.Read("", "hub", eventhub.DefaultConsumerGroup, "0")
+	require.NoError(t, err)
+	assert.Equal(t, "1", checkpoint.Offset)
+	assert.Equal(t, int64(1), checkpoint.SequenceNumber)
+
+	require.NoError(t, hub.Send(ctx, eventhub.NewEventFromString("c")))
+	_, resumed := receive(t, hub, "0")
+	expect(t, resumed, "c")
+}
+
+func TestHub_Peek(t *testing.T) {
+	broker, err := NewBroker(BrokerWithPartitionCount(1))
+	require.NoError(t, err)
+	persister := persist.NewMemoryPersister()
+	hub, err := broker.Hub("hub", HubWithOffsetPersistence(persister))
+	require.NoError(t, err)
+	ctx := context.Background()
+	defer func() { _ = hub.Close(ctx) }()
+	for _, data := range []string{"a", "b", "c"} {
+		require.NoError(t, hub.Send(ctx, eventhub.NewEventFromString(data)))
+	}
+	handle, received := receive(t, hub, "0")
+	expect(t, received, "a", "b", "c")
+
+	events, err := hub.Peek(ctx, "0", 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "a", string(events[0].Data), "peeks should start at the start of the partition")
+	assert.Equal(t, "b", string(events[1].Data))
+	events[0].Data[0] = 'z'
+
+	events, err = hub.Peek(ctx, "0", 10, eventhub.ReceiveWithStartingOffset("0"))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "b", string(events[0].Data))
+
+	require.NoError(t, hub.Send(ctx, eventhub.NewEventFromString("d")))
+	expect(t, received, "d")
+	assert.NoError(t, handle.Err(), "peeks should not disconnect the receivers of the partition")
+	checkpoint, err := persister.Read("", "hub", eventhub.DefaultConsumerGroup, "0")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), checkpoint.SequenceNumber, "peeks should not write checkpoints")
+
+	_, err = hub.Peek(ctx, "0", 0)
+	assert.Error(t, err)
+	_, err = hub.Peek(ctx, "0", 1, eventhub.ReceiveWithEpoch(1))
+	assert.Error(t, err)
+	_, err = hub.Peek(ctx, "0", 1, eventhub.ReceiveWithConsumerGroup("missing"))
+	assert.Error(t, err)
+	receive(t, hub, "0", eventhub.ReceiveWithEpoch(1))
+	_, err = hub.Peek(ctx, "0", 1)
+	assert.Error(t, err, "peeks are refused while an epoch receiver is connected")
+}
+
+func TestHub_ReceiveConsumerGroups(t *testing.T) {
+	_, hub := newTestHub(t, BrokerWithConsumerGroups("analytics"))
+	defer func() { _ = hub.Close(context.Background()) }()
+
+	_, err := hub.Receive(context.Background(), "0", func(context.Context, *eventhub.Event) error { return nil },
+		eventhub.ReceiveWithConsumerGroup("missing"))
+	assert.Error(t, err)
+
+	receive(t, hub, "0", eventhub.ReceiveWithConsumerGroup("analytics"))
+
+	_, err = hub.Receive(context.Background(), "9", func(context.Context, *eventhub.Event) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestHub_ReceiveEpochs(t *testing.T) {
+	_, hub := newTestHub(t)
+	defer func() { _ = hub.Close(context.Background()) }()
+
+	first, _ := receive(t, hub, "0", eventhub.ReceiveWithEpoch(1))
+
+	_, err := hub.Receive(context.Background(), "0", func(context.Context, *eventhub.Event) error { return nil })
+	assert.Error(t, err, "receivers without an epoch are refused while an epoch receiver is connected")
+
+	second, _ := receive(t, hub, "0", eventhub.ReceiveWithEpoch(2))
+	select {
+	case <-first.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the receiver with the lower epoch should have been disconnected")
+	}
+	assert.Error(t, first.Err())
+	assert.NoError(t, second.Err())
+
+	_, err = hub.Receive(context.Background(), "0", func(context.Context, *eventhub.Event) error { return nil }, eventhub.ReceiveWithEpoch(1))
+	assert.Error(t, err, "receivers with a lower epoch are refused")
+}
+
+func TestHub_ReceiverLimit(t *testing.T) {
+	_, hub := newTestHub(t)
+	defer func() { _ = hub.Close(context.Background()) }()
+	for i := 0; i < maxReceiversPerPartition; i++ {
+		receive(t, hub, "0")
+	}
+
+	_, err := hub.Receive(context.Background(), "0", func(context.Context, *eventhub.Event) error { return nil })
+	assert.Error(t, err)
+
+	receive(t, hub, "1")
+}
+
+func TestHub_GetInformation(t *testing.T) {
+	_, hub := newTestHub(t, BrokerWithPartitionCount(2))
+	ctx := context.Background()
+
+	info, err := hub.GetRuntimeInformation(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "hub", info.Path)
+	assert.Equal(t, []string{"0", "1"}, info.PartitionIDs)
+
+	partition, err := hub.GetPartitionInformation(ctx, "0")
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), partition.LastSequenceNumber)
+
+	require.NoError(t, hub.Send(ctx, eventhub.NewEventFromString("a")))
+	require.NoError(t, hub.Send(ctx, eventhub.NewEventFromString("b")))
+	require.NoError(t, hub.Send(ctx, eventhub.NewEventFromString("c")))
+	partition, err = hub.GetPartitionInformation(ctx, "0")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), partition.LastSequenceNumber)
+	assert.Equal(t, "1", partition.LastEnqueuedOffset)
+}
+
+func TestHub_Close(t *testing.T) {
+	broker, hub := newTestHub(t)
+	handle, _ := receive(t, hub, "0")
+
+	require.NoError(t, hub.Close(context.Background()))
+	select {
+	case <-handle.Done():
+	default:
+		t.Fatal("Close should close the receivers of the hub")
+	}
+
+	assert.Error(t, hub.Send(context.Background(), eventhub.NewEventFromString("a")))
+	_, err := hub.Receive(context.Background(), "0", func(context.Context, *eventhub.Event) error { return nil })
+	assert.Error(t, err)
+
+	other, err := broker.Hub("hub")
+	require.NoError(t, err)
+	assert.NoError(t, other.Send(context.Background(), eventhub.NewEventFromString("a")), "other clients of the hub stay open")
+}
@@ -0,0 +1,525 @@
+package eventhubtest
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/Azure/go-amqp"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+type (
+	// Client is a client of a hub, such as *eventhub.Hub, whose traffic a Recorder records
+	Client interface {
+		eventhub.Sender
+		eventhub.PartitionedReceiver
+		eventhub.Manager
+		Close(ctx context.Context) error
+	}
+
+	// Recording is the traffic between a client and a hub, as recorded by a Recorder and replayed by a Player. It is
+	// stored as JSON, with events in the AMQP encoding of eventhub.Event.MarshalBinary, so their properties and
+	// annotations keep their types.
+	Recording struct {
+		// RuntimeInformation is the last runtime information of the hub the client got, if it got any
+		RuntimeInformation *eventhub.HubRuntimeInformation `json:"runtimeInformation,omitempty"`
+		// Partitions holds the last runtime information the client got of each partition
+		Partitions map[string]*eventhub.HubPartitionRuntimeInformation `json:"partitions,omitempty"`
+		// Streams holds the events received from each partition with each consumer group
+		Streams []*RecordedStream `json:"streams,omitempty"`
+		// Sends holds the outcome of each send, in order
+		Sends []RecordedSend `json:"sends,omitempty"`
+	}
+
+	// RecordedStream holds the events received from a partition with a consumer group
+	RecordedStream struct {
+		ConsumerGroup string `json:"consumerGroup"`
+		PartitionID   string `json:"partitionID"`
+		// Events are the encoded events, each received once, in the order of their sequence numbers
+		Events [][]byte `json:"events"`
+		// Err is the error the last receiver of the stream stopped with, if one did
+		Err *RecordedError `json:"error,omitempty"`
+	}
+
+	// RecordedSend is the outcome of a send
+	RecordedSend struct {
+		Batch bool           `json:"batch,omitempty"`
+		Err   *RecordedError `json:"error,omitempty"`
+	}
+
+	// RecordedError is an error of the hub. Errors of AMQP links keep their condition and description, and are
+	// replayed as link detaches.
+	RecordedError struct {
+		Condition   string `json:"condition,omitempty"`
+		Description string `json:"description,omitempty"`
+		Message     string `json:"message"`
+	}
+
+	// Recorder is a Client which records the traffic of the client it wraps, for a Player to replay it. It is safe
+	// for concurrent use.
+	Recorder struct {
+		client Client
+
+		mu        sync.Mutex
+		recording Recording
+		// last holds the sequence number of the last event of each stream, so events received twice are recorded once
+		last map[*RecordedStream]int64
+	}
+
+	// Player is a Client which replays a Recording: receivers get the recorded events of their partition and consumer
+	// group, and stop with the recorded error if there is one, sends have the recorded outcomes in order, and the
+	// runtime information is the recorded one. Playback needs neither a network nor credentials, and is
+	// deterministic. It is safe for concurrent use.
+	Player struct {
+		recording *Recording
+		streams   map[streamKey][]*eventhub.Event
+		errs      map[streamKey]*RecordedError
+
+		mu        sync.Mutex
+		closed    bool
+		sends     int
+		sent      []*eventhub.Event
+		listeners []*listener
+	}
+
+	streamKey struct {
+		consumerGroup string
+		partitionID   string
+	}
+)
+
+// NewRecorder creates a new Recorder of the traffic of client
+func NewRecorder(client Client) *Recorder {
+	return &Recorder{
+		client: client,
+		last:   make(map[*RecordedStream]int64),
+	}
+}
+
+// Recording returns what was recorded so far
+func (r *Recorder) Recording() *Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	recording := &Recording{
+		RuntimeInformation: r.recording.RuntimeInformation,
+		Sends:              append([]RecordedSend(nil), r.recording.Sends...),
+	}
+	if r.recording.Partitions != nil {
+		recording.Partitions = make(map[string]*eventhub.HubPartitionRuntimeInformation, len(r.recording.Partitions))
+		for id, info := range r.recording.Partitions {
+			recording.Partitions[id] = info
+		}
+	}
+	for _, stream := range r.recording.Streams {
+		copied := *stream
+		copied.Events = append([][]byte(nil), stream.Events...)
+		recording.Streams = append(recording.Streams, &copied)
+	}
+	return recording
+}
+
+// Send sends event with the client and records the outcome
+func (r *Recorder) Send(ctx context.Context, event *eventhub.Event, opts ...eventhub.SendOption) error {
+	err := r.client.Send(ctx, event, opts...)
+	r.recordSend(false, err)
+	return err
+}
+
+// SendBatch sends the events of iterator with the client and records the outcome
+func (r *Recorder) SendBatch(ctx context.Context, iterator eventhub.BatchIterator, opts ...eventhub.BatchOption) error {
+	err := r.client.SendBatch(ctx, iterator, opts...)
+	r.recordSend(true, err)
+	return err
+}
+
+// Receive receives with the client, recording the events handler is called with and the error the receiver stops
+// with
+func (r *Recorder) Receive(ctx context.Context, partitionID string, handler eventhub.Handler, opts ...eventhub.ReceiveOption) (*eventhub.ListenerHandle, error) {
+	settings, err := eventhub.ResolveReceiveOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	stream := r.stream(settings.ConsumerGroup, partitionID)
+
+	handle, err := r.client.Receive(ctx, partitionID, func(ctx context.Context, event *eventhub.Event) error {
+		if err := r.recordEvent(stream, event); err != nil {
+			return err
+		}
+		return handler(ctx, event)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-handle.Done()
+		if err := handle.Err(); err != nil && !errors.Is(err, context.Canceled) {
+			r.mu.Lock()
+			stream.Err = recordError(err)
+			r.mu.Unlock()
+		}
+	}()
+	return handle, nil
+}
+
+// GetRuntimeInformation gets the runtime information of the hub with the client and records it
+func (r *Recorder) GetRuntimeInformation(ctx context.Context) (*eventhub.HubRuntimeInformation, error) {
+	info, err := r.client.GetRuntimeInformation(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recorded := *info
+	r.recording.RuntimeInformation = &recorded
+	return info, nil
+}
+
+// GetPartitionInformation gets the runtime information of a partition with the client and records it
+func (r *Recorder) GetPartitionInformation(ctx context.Context, partitionID string) (*eventhub.HubPartitionRuntimeInformation, error) {
+	info, err := r.client.GetPartitionInformation(ctx, partitionID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.recording.Partitions == nil {
+		r.recording.Partitions = make(map[string]*eventhub.HubPartitionRuntimeInformation)
+	}
+	recorded := *info
+	r.recording.Partitions[partitionID] = &recorded
+	return info, nil
+}
+
+// Close closes the client
+func (r *Recorder) Close(ctx context.Context) error {
+	return r.client.Close(ctx)
+}
+
+func (r *Recorder) stream(consumerGroup, partitionID string) *RecordedStream {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stream := range r.recording.Streams {
+		if stream.ConsumerGroup == consumerGroup && stream.PartitionID == partitionID {
+			return stream
+		}
+	}
+	stream := &RecordedStream{ConsumerGroup: consumerGroup, PartitionID: partitionID}
+	r.recording.Streams = append(r.recording.Streams, stream)
+	return stream
+}
+
+func (r *Recorder) recordEvent(stream *RecordedStream, event *eventhub.Event) error {
+	data, err := event.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if sequenceNumber, ok := event.GetSequenceNumber(); ok {
+		if last, seen := r.last[stream]; seen && sequenceNumber <= last {
+			return nil
+		}
+		r.last[stream] = sequenceNumber
+	}
+	stream.Events = append(stream.Events, data)
+	return nil
+}
+
+func (r *Recorder) recordSend(batch bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recording.Sends = append(r.recording.Sends, RecordedSend{Batch: batch, Err: recordError(err)})
+}
+
+// recordError records err, keeping the condition and description of AMQP errors
+func recordError(err error) *RecordedError {
+	if err == nil {
+		return nil
+	}
+
+	recorded := &RecordedError{Message: err.Error()}
+	var detach *amqp.DetachError
+	var remote *amqp.Error
+	switch {
+	case errors.As(err, &detach) && detach.RemoteError != nil:
+		remote = detach.RemoteError
+	case errors.As(err, &remote):
+	}
+	if remote != nil {
+		recorded.Condition = string(remote.Condition)
+		recorded.Description = remote.Description
+	}
+	return recorded
+}
+
+// error returns the error to replay
+func (e *RecordedError) error() error {
+	if e == nil {
+		return nil
+	}
+	if e.Condition != "" {
+		return &amqp.DetachError{RemoteError: &amqp.Error{
+			Condition:   amqp.ErrorCondition(e.Condition),
+			Description: e.Description,
+		}}
+	}
+	return errors.New(e.Message)
+}
+
+// Save writes the recording to w as JSON
+func (rec *Recording) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rec)
+}
+
+// LoadRecording reads a recording written by Recording.Save
+func LoadRecording(r io.Reader) (*Recording, error) {
+	rec := new(Recording)
+	if err := json.NewDecoder(r).Decode(rec); err != nil {
+		return nil, fmt.Errorf("eventhubtest: failed to read recording: %v", err)
+	}
+	return rec, nil
+}
+
+// NewPlayer creates a new Player of recording
+func NewPlayer(recording *Recording) (*Player, error) {
+	if recording == nil {
+		return nil, errors.New("recording must not be nil")
+	}
+
+	p := &Player{
+		recording: recording,
+		streams:   make(map[streamKey][]*eventhub.Event),
+		errs:      make(map[streamKey]*RecordedError),
+	}
+	for _, stream := range recording.Streams {
+		key := streamKey{consumerGroup: stream.ConsumerGroup, partitionID: stream.PartitionID}
+		for i, data := range stream.Events {
+			event := new(eventhub.Event)
+			if err := event.UnmarshalBinary(data); err != nil {
+				return nil, fmt.Errorf("eventhubtest: event %d of partition %s with consumer group %q is invalid: %v", i, stream.PartitionID, stream.ConsumerGroup, err)
+			}
+			p.streams[key] = append(p.streams[key], event)
+		}
+		p.errs[key] = stream.Err
+	}
+	return p, nil
+}
+
+// Send returns the next recorded outcome of a send, or nil after the recorded ones. The event is kept for Sent.
+func (p *Player) Send(ctx context.Context, event *eventhub.Event, _ ...eventhub.SendOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return eventhub.ErrHubClosed
+	}
+
+	err := p.nextSend()
+	if err == nil {
+		p.sent = append(p.sent, copyEvent(event))
+	}
+	return err
+}
+
+// SendBatch returns the next recorded outcome of a send, or nil after the recorded ones. The events of iterator, if
+// it's an *eventhub.EventBatchIterator, are kept for Sent.
+func (p *Player) SendBatch(ctx context.Context, iterator eventhub.BatchIterator, _ ...eventhub.BatchOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return eventhub.ErrHubClosed
+	}
+
+	if err := p.nextSend(); err != nil {
+		return err
+	}
+	if ebi, ok := iterator.(*eventhub.EventBatchIterator); ok {
+		for key, events := range ebi.PartitionEventsMap {
+			for _, event := range events[ebi.Cursors[key]:] {
+				p.sent = append(p.sent, copyEvent(event))
+			}
+			ebi.Cursors[key] = len(events)
+		}
+	}
+	return nil
+}
+
+// Sent returns the events sent successfully during playback
+func (p *Player) Sent() []*eventhub.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*eventhub.Event(nil), p.sent...)
+}
+
+// Receive replays the recorded events of a partition and consumer group to handler, from the starting position of
+// opts, then stops with the recorded error of the stream, if there is one, or waits for the returned handle to be
+// closed
+func (p *Player) Receive(ctx context.Context, partitionID string, handler eventhub.Handler, opts ...eventhub.ReceiveOption) (*eventhub.ListenerHandle, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	settings, err := eventhub.ResolveReceiveOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !p.hasPartition(partitionID) {
+		return nil, eventhub.ErrPartitionNotFound{PartitionID: partitionID, Description: "the partition is not in the recording"}
+	}
+
+	key := streamKey{consumerGroup: settings.ConsumerGroup, partitionID: partitionID}
+	events := p.streams[key]
+	events = events[startIndex(events, settings.StartingCheckpoint):]
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, eventhub.ErrHubClosed
+	}
+
+	listenerCtx, cancel := context.WithCancel(context.Background())
+	l := &listener{
+		consumerGroup: settings.ConsumerGroup,
+		ctx:           listenerCtx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	p.listeners = append(p.listeners, l)
+
+	go p.replay(l, events, p.errs[key].error(), handler)
+	return eventhub.NewListenerHandle(l.ctx, l.close, l.error), nil
+}
+
+// GetRuntimeInformation returns the recorded runtime information of the hub
+func (p *Player) GetRuntimeInformation(ctx context.Context) (*eventhub.HubRuntimeInformation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if p.recording.RuntimeInformation == nil {
+		return nil, errors.New("the runtime information of the hub is not in the recording")
+	}
+
+	info := *p.recording.RuntimeInformation
+	return &info, nil
+}
+
+// GetPartitionInformation returns the recorded runtime information of a partition
+func (p *Player) GetPartitionInformation(ctx context.Context, partitionID string) (*eventhub.HubPartitionRuntimeInformation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	recorded, ok := p.recording.Partitions[partitionID]
+	if !ok {
+		return nil, fmt.Errorf("the runtime information of partition %q is not in the recording", partitionID)
+	}
+	info := *recorded
+	return &info, nil
+}
+
+// Close closes the receivers of the Player. Sends and receives after Close fail.
+func (p *Player) Close(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	listeners := p.listeners
+	p.listeners = nil
+	p.mu.Unlock()
+
+	for _, l := range listeners {
+		if err := l.close(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextSend returns the error of the next recorded send. p.mu must be held.
+func (p *Player) nextSend() error {
+	if p.sends >= len(p.recording.Sends) {
+		return nil
+	}
+	recorded := p.recording.Sends[p.sends]
+	p.sends++
+	return recorded.Err.error()
+}
+
+// hasPartition reports whether partitionID is a partition of the recorded hub, or of a recorded stream when the
+// runtime information of the hub wasn't recorded
+func (p *Player) hasPartition(partitionID string) bool {
+	if info := p.recording.RuntimeInformation; info != nil {
+		for _, id := range info.PartitionIDs {
+			if id == partitionID {
+				return true
+			}
+		}
+		return false
+	}
+
+	for key := range p.streams {
+		if key.partitionID == partitionID {
+			return true
+		}
+	}
+	return false
+}
+
+// replay hands events to handler, then stops l with err, if it isn't nil, or waits for l to be closed
+func (p *Player) replay(l *listener, events []*eventhub.Event, err error, handler eventhub.Handler) {
+	defer close(l.done)
+
+	for _, event := range events {
+		if l.ctx.Err() != nil {
+			return
+		}
+		// like the service, a player doesn't redeliver events a handler failed
+		_ = handler(l.ctx, copyEvent(event))
+	}
+
+	if err != nil {
+		l.fail(err)
+		return
+	}
+	<-l.ctx.Done()
+}
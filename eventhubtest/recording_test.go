@@ -0,0 +1,180 @@
+package eventhubtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+// recordSession records a run which reads the events of partition 0 of a broker hub until a receiver with a higher
+// epoch takes the partition over
+func recordSession(t *testing.T) *Recording {
+	ctx := context.Background()
+	broker, err := NewBroker(BrokerWithPartitionCount(2))
+	require.NoError(t, err)
+	hub, err := broker.Hub("hub", HubWithPartitionedSender("0"))
+	require.NoError(t, err)
+	recorder := NewRecorder(hub)
+	defer func() { _ = recorder.Close(ctx) }()
+
+	_, err = recorder.GetRuntimeInformation(ctx)
+	require.NoError(t, err)
+	for _, data := range []string{"a", "b", "c"} {
+		event := eventhub.NewEventFromString(data)
+		event.Properties = map[string]interface{}{"count": int64(len(data))}
+		require.NoError(t, recorder.Send(ctx, event))
+	}
+	assert.Error(t, recorder.Send(ctx, eventhub.NewEvent(make([]byte, maxMessageSize+1))))
+	_, err = recorder.GetPartitionInformation(ctx, "0")
+	require.NoError(t, err)
+
+	received := make(chan string, 3)
+	handle, err := recorder.Receive(ctx, "0", func(_ context.Context, event *eventhub.Event) error {
+		received <- string(event.Data)
+		return nil
+	}, eventhub.ReceiveWithEpoch(1))
+	require.NoError(t, err)
+	expect(t, received, "a", "b", "c")
+
+	other, err := broker.Hub("hub")
+	require.NoError(t, err)
+	defer func() { _ = other.Close(ctx) }()
+	_, err = other.Receive(ctx, "0", func(context.Context, *eventhub.Event) error { return nil }, eventhub.ReceiveWithEpoch(2))
+	require.NoError(t, err)
+	<-handle.Done()
+
+	require.Eventually(t, func() bool {
+		return recorder.Recording().Streams[0].Err != nil
+	}, 5*time.Second, time.Millisecond, "the error the receiver stopped with should be recorded")
+	return recorder.Recording()
+}
+
+func TestRecorder_Records(t *testing.T) {
+	recording := recordSession(t)
+
+	require.NotNil(t, recording.RuntimeInformation)
+	assert.Equal(t, []string{"0", "1"}, recording.RuntimeInformation.PartitionIDs)
+	assert.Equal(t, int64(2), recording.Partitions["0"].LastSequenceNumber)
+
+	require.Len(t, recording.Sends, 4)
+	assert.Nil(t, recording.Sends[0].Err)
+	require.NotNil(t, recording.Sends[3].Err)
+	assert.Equal(t, eventhub.ErrMessageIsTooBig.Error(), recording.Sends[3].Err.Message)
+
+	require.Len(t, recording.Streams, 1)
+	stream := recording.Streams[0]
+	assert.Equal(t, eventhub.DefaultConsumerGroup, stream.ConsumerGroup)
+	assert.Equal(t, "0", stream.PartitionID)
+	assert.Len(t, stream.Events, 3)
+	assert.Contains(t, stream.Err.Message, "epoch 2")
+}
+
+func TestPlayer_ReplaysRecording(t *testing.T) {
+	var saved bytes.Buffer
+	require.NoError(t, recordSession(t).Save(&saved))
+	recording, err := LoadRecording(&saved)
+	require.NoError(t, err)
+
+	player, err := NewPlayer(recording)
+	require.NoError(t, err)
+	ctx := context.Background()
+	defer func() { _ = player.Close(ctx) }()
+
+	info, err := player.GetRuntimeInformation(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "hub", info.Path)
+	partition, err := player.GetPartitionInformation(ctx, "0")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), partition.LastSequenceNumber)
+	_, err = player.GetPartitionInformation(ctx, "1")
+	assert.Error(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, player.Send(ctx, eventhub.NewEventFromString("x")))
+	}
+	assert.Error(t, player.Send(ctx, eventhub.NewEventFromString("x")), "sends replay their recorded outcomes")
+	require.NoError(t, player.Send(ctx, eventhub.NewEventFromString("y")), "sends after the recorded ones succeed")
+	assert.Len(t, player.Sent(), 4)
+
+	var events []*eventhub.Event
+	handle, err := player.Receive(ctx, "0", func(_ context.Context, event *eventhub.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	require.NoError(t, err)
+	select {
+	case <-handle.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the receiver should stop with the recorded error")
+	}
+	assert.Contains(t, handle.Err().Error(), "epoch 2")
+
+	require.Len(t, events, 3)
+	for i, data := range []string{"a", "b", "c"} {
+		assert.Equal(t, data, string(events[i].Data))
+		assert.Equal(t, int64(1), events[i].Properties["count"], "properties keep their types")
+		sequenceNumber, ok := events[i].GetSequenceNumber()
+		require.True(t, ok)
+		assert.Equal(t, int64(i), sequenceNumber)
+	}
+	assert.Equal(t, "1", events[1].GetCheckpoint().Offset)
+
+	var resumed []string
+	handle, err = player.Receive(ctx, "0", func(_ context.Context, event *eventhub.Event) error {
+		resumed = append(resumed, string(event.Data))
+		return nil
+	}, eventhub.ReceiveWithStartingOffset(events[0].GetCheckpoint().Offset))
+	require.NoError(t, err)
+	<-handle.Done()
+	assert.Equal(t, []string{"b", "c"}, resumed)
+
+	_, err = player.Receive(ctx, "7", func(context.Context, *eventhub.Event) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestPlayer_WaitsAfterStreamWithoutError(t *testing.T) {
+	player, err := NewPlayer(&Recording{
+		RuntimeInformation: &eventhub.HubRuntimeInformation{Path: "hub", PartitionIDs: []string{"0"}},
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	handle, err := player.Receive(ctx, "0", func(context.Context, *eventhub.Event) error { return nil },
+		eventhub.ReceiveWithStartingOffset(persist.StartOfStream))
+	require.NoError(t, err)
+	select {
+	case <-handle.Done():
+		t.Fatal("a receiver of a stream recorded without an error should wait to be closed")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	require.NoError(t, player.Close(ctx))
+	<-handle.Done()
+	assert.Error(t, player.Send(ctx, eventhub.NewEventFromString("x")))
+
+	_, err = NewPlayer(&Recording{Streams: []*RecordedStream{{PartitionID: "0", Events: [][]byte{[]byte("garbage")}}}})
+	assert.Error(t, err)
+}
+
+func TestRecordedError_KeepsAMQPCondition(t *testing.T) {
+	detach := &amqp.DetachError{RemoteError: &amqp.Error{Condition: "amqp:link:stolen", Description: "new receiver"}}
+	recorded := recordError(detach)
+	assert.Equal(t, "amqp:link:stolen", recorded.Condition)
+	assert.Equal(t, "new receiver", recorded.Description)
+
+	var replayed *amqp.DetachError
+	require.True(t, errors.As(recorded.error(), &replayed))
+	assert.Equal(t, amqp.ErrorCondition("amqp:link:stolen"), replayed.RemoteError.Condition)
+
+	assert.Nil(t, recordError(nil))
+	assert.Equal(t, "boom", recordError(errors.New("boom")).error().Error())
+}
@@ -0,0 +1,102 @@
+package eventhubtest
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"sync"
+)
+
+type (
+	// Script scripts the failures of the operations of a fake, such as a TokenProvider or the leasers and
+	// checkpointers of the ephtest package, and counts the calls of each operation. Operations are named after the
+	// methods of the fake, like "GetToken" or "RenewLease". The zero value fails nothing and is ready to use. A Script
+	// is safe for concurrent use.
+	Script struct {
+		mu     sync.Mutex
+		next   map[string][]error
+		always map[string]error
+		calls  map[string]int
+	}
+)
+
+// FailNext fails the next calls of operation with errs, one call per error in order. Calls after them succeed again.
+func (s *Script) FailNext(operation string, errs ...error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next == nil {
+		s.next = make(map[string][]error)
+	}
+	s.next[operation] = append(s.next[operation], errs...)
+}
+
+// FailAlways fails every call of operation with err, once the failures scripted with FailNext are used up. A nil err
+// stops failing the operation.
+func (s *Script) FailAlways(operation string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.always == nil {
+		s.always = make(map[string]error)
+	}
+	if err == nil {
+		delete(s.always, operation)
+		return
+	}
+	s.always[operation] = err
+}
+
+// Reset removes all scripted failures and call counts
+func (s *Script) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.next = nil
+	s.always = nil
+	s.calls = nil
+}
+
+// Calls returns the number of times operation has been called, failed calls included
+func (s *Script) Calls(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[operation]
+}
+
+// Call counts a call of operation and returns the failure scripted for it, or nil if the call should succeed. Fakes
+// call it first thing in each of their operations.
+func (s *Script) Call(operation string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.calls == nil {
+		s.calls = make(map[string]int)
+	}
+	s.calls[operation]++
+
+	if errs := s.next[operation]; len(errs) > 0 {
+		s.next[operation] = errs[1:]
+		return errs[0]
+	}
+	return s.always[operation]
+}
@@ -0,0 +1,48 @@
+package eventhubtest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-amqp-common-go/v3/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScript(t *testing.T) {
+	var script Script
+	assert.NoError(t, script.Call("Send"))
+
+	first, second, always := errors.New("first"), errors.New("second"), errors.New("always")
+	script.FailNext("Send", first, second)
+	script.FailAlways("Send", always)
+	assert.Equal(t, first, script.Call("Send"))
+	assert.Equal(t, second, script.Call("Send"))
+	assert.Equal(t, always, script.Call("Send"))
+	assert.NoError(t, script.Call("Receive"), "failures are scripted per operation")
+	assert.Equal(t, 4, script.Calls("Send"))
+
+	script.FailAlways("Send", nil)
+	assert.NoError(t, script.Call("Send"))
+
+	script.FailNext("Send", first)
+	script.Reset()
+	assert.NoError(t, script.Call("Send"))
+	assert.Equal(t, 1, script.Calls("Send"))
+}
+
+func TestTokenProvider(t *testing.T) {
+	tp := NewTokenProvider()
+	token, err := tp.GetToken("amqps://ns.servicebus.windows.net/hub")
+	require.NoError(t, err)
+	assert.Equal(t, auth.CBSTokenTypeSAS, token.TokenType)
+	assert.True(t, strings.HasPrefix(token.Token, "SharedAccessSignature "))
+	assert.NotEmpty(t, token.Expiry)
+
+	tp.FailNext("GetToken", errors.New("unauthorized"))
+	_, err = tp.GetToken("amqps://ns.servicebus.windows.net/hub")
+	assert.EqualError(t, err, "unauthorized")
+	assert.Equal(t, []string{"amqps://ns.servicebus.windows.net/hub"}, tp.Audiences())
+	assert.Equal(t, 2, tp.Calls("GetToken"))
+}
@@ -0,0 +1,77 @@
+package eventhubtest
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-amqp-common-go/v3/auth"
+)
+
+type (
+	// TokenProvider is a fake auth.TokenProvider which hands out SAS tokens that no service accepts, and records the
+	// audiences it's asked for. Its embedded Script fails "GetToken" on demand, to test how an application copes with
+	// an identity provider which is down or refuses credentials.
+	TokenProvider struct {
+		Script
+		// Expiry is how long the tokens handed out are valid, one hour if zero
+		Expiry time.Duration
+
+		mu        sync.Mutex
+		audiences []string
+	}
+)
+
+var _ auth.TokenProvider = (*TokenProvider)(nil)
+
+// NewTokenProvider creates a new fake TokenProvider without scripted failures
+func NewTokenProvider() *TokenProvider {
+	return new(TokenProvider)
+}
+
+// GetToken returns a fake token for audience, or the failure scripted for "GetToken"
+func (tp *TokenProvider) GetToken(audience string) (*auth.Token, error) {
+	if err := tp.Call("GetToken"); err != nil {
+		return nil, err
+	}
+
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.audiences = append(tp.audiences, audience)
+
+	expiry := tp.Expiry
+	if expiry == 0 {
+		expiry = time.Hour
+	}
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	return auth.NewToken(auth.CBSTokenTypeSAS, "SharedAccessSignature sr="+audience+"&sig=fake&se="+expires, expires), nil
+}
+
+// Audiences returns the audiences tokens have been handed out for, in order
+func (tp *TokenProvider) Audiences() []string {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	return append([]string(nil), tp.audiences...)
+}
@@ -0,0 +1,449 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3/internal/buffer"
+)
+
+const (
+	// ExportFormatAMQP writes each event as its AMQP message, encoded as on the wire and prefixed by its length as a
+	// 4 byte big endian integer. It preserves every section of the message and the types of application properties.
+	ExportFormatAMQP ExportFormat = "amqp"
+	// ExportFormatJSONL writes each event as a line of JSON, which is easy to inspect and process with other tools.
+	// Application properties are kept as JSON values, so numbers come back as float64 and times as strings.
+	ExportFormatJSONL ExportFormat = "jsonl"
+
+	defaultExportIdleTimeout = 10 * time.Second
+	maxExportedMessageSize   = 64 * 1024 * 1024
+)
+
+type (
+	// ExportFormat is the file format of exported events
+	ExportFormat string
+
+	// ExportOption provides structure for configuring the export of a partition
+	ExportOption func(o *exportOptions) error
+
+	exportOptions struct {
+		format       ExportFormat
+		receiveOpts  []ReceiveOption
+		untilOffset  *int64
+		untilTime    *time.Time
+		idleTimeout  time.Duration
+		lastSequence int64
+	}
+
+	// ExportReader reads the events of an export
+	ExportReader struct {
+		format ExportFormat
+		reader *bufio.Reader
+	}
+
+	// exportedEvent is the JSON representation of an event in an export
+	exportedEvent struct {
+		ID             string                 `json:"id,omitempty"`
+		Data           []byte                 `json:"data"`
+		ContentType    string                 `json:"contentType,omitempty"`
+		PartitionKey   *string                `json:"partitionKey,omitempty"`
+		Properties     map[string]interface{} `json:"properties,omitempty"`
+		SequenceNumber *int64                 `json:"sequenceNumber,omitempty"`
+		Offset         *int64                 `json:"offset,omitempty"`
+		EnqueuedTime   *time.Time             `json:"enqueuedTime,omitempty"`
+	}
+)
+
+// ExportWithFormat configures the format events are written in, ExportFormatAMQP by default
+func ExportWithFormat(format ExportFormat) ExportOption {
+	return func(o *exportOptions) error {
+		if format != ExportFormatAMQP && format != ExportFormatJSONL {
+			return fmt.Errorf("unknown export format %q", format)
+		}
+		o.format = format
+		return nil
+	}
+}
+
+// ExportWithReceiveOptions configures the receiver the events are exported from, such as its consumer group and the
+// offset or time the export starts at with ReceiveWithStartingOffset or ReceiveFromTimestamp
+func ExportWithReceiveOptions(opts ...ReceiveOption) ExportOption {
+	return func(o *exportOptions) error {
+		o.receiveOpts = append(o.receiveOpts, opts...)
+		return nil
+	}
+}
+
+// ExportUntilOffset ends the export with the event at offset, including it
+func ExportUntilOffset(offset int64) ExportOption {
+	return func(o *exportOptions) error {
+		o.untilOffset = &offset
+		return nil
+	}
+}
+
+// ExportUntilTime ends the export with the last event enqueued at or before t
+func ExportUntilTime(t time.Time) ExportOption {
+	return func(o *exportOptions) error {
+		o.untilTime = &t
+		return nil
+	}
+}
+
+// ExportWithIdleTimeout ends the export if no event arrives for timeout, which happens when the export starts after
+// the last event of the partition. The default is 10 seconds.
+func ExportWithIdleTimeout(timeout time.Duration) ExportOption {
+	return func(o *exportOptions) error {
+		if timeout <= 0 {
+			return errors.New("idle timeout must be positive")
+		}
+		o.idleTimeout = timeout
+		return nil
+	}
+}
+
+// ExportPartition writes the events of a partition to w, returning the number of events written. The export starts
+// where a receiver created with the receive options of ExportWithReceiveOptions starts and ends with the last event
+// enqueued when the export began, or earlier with ExportUntilOffset or ExportUntilTime. Use an ExportReader to read
+// the export, or ReplayEvents to send its events to a hub.
+func (h *Hub) ExportPartition(ctx context.Context, partitionID string, w io.Writer, opts ...ExportOption) (int, error) {
+	span, ctx := h.startSpanFromContext(ctx, "eh.Hub.ExportPartition")
+	defer span.End()
+
+	options := &exportOptions{format: ExportFormatAMQP, idleTimeout: defaultExportIdleTimeout}
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return 0, err
+		}
+	}
+
+	info, err := h.GetPartitionInformation(ctx, partitionID)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return 0, err
+	}
+	if info.LastSequenceNumber < info.BeginningSequenceNumber || info.LastSequenceNumber < 0 {
+		return 0, nil
+	}
+	options.lastSequence = info.LastSequenceNumber
+
+	var (
+		mu       sync.Mutex
+		count    int
+		finished bool
+		writeErr error
+		done     = make(chan struct{})
+		activity = make(chan struct{}, 1)
+	)
+	finish := func(err error) {
+		finished = true
+		writeErr = err
+		close(done)
+	}
+
+	handler := func(_ context.Context, event *Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if finished {
+			return nil
+		}
+
+		select {
+		case activity <- struct{}{}:
+		default:
+		}
+
+		if options.after(event) {
+			finish(nil)
+			return nil
+		}
+
+		if err := writeExported(w, options.format, event); err != nil {
+			finish(err)
+			return err
+		}
+		count++
+
+		if options.last(event) {
+			finish(nil)
+		}
+		return nil
+	}
+
+	handle, err := h.Receive(ctx, partitionID, handler, options.receiveOpts...)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return 0, err
+	}
+
+	idle := time.NewTimer(options.idleTimeout)
+	defer idle.Stop()
+
+wait:
+	for {
+		select {
+		case <-done:
+			break wait
+		case <-activity:
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(options.idleTimeout)
+		case <-idle.C:
+			break wait
+		case <-handle.Done():
+			mu.Lock()
+			if writeErr == nil {
+				writeErr = handle.Err()
+			}
+			mu.Unlock()
+			break wait
+		case <-ctx.Done():
+			mu.Lock()
+			writeErr = ctx.Err()
+			mu.Unlock()
+			break wait
+		}
+	}
+
+	mu.Lock()
+	finished = true
+	exported, err := count, writeErr
+	mu.Unlock()
+
+	if closeErr := handle.Close(context.Background()); closeErr != nil {
+		tab.For(ctx).Debug(fmt.Sprintf("failed to close export receiver: %v", closeErr))
+	}
+	if err != nil {
+		tab.For(ctx).Error(err)
+	}
+	return exported, err
+}
+
+// after reports whether event is past the end of the export
+func (o *exportOptions) after(event *Event) bool {
+	if offset, ok := event.GetOffset(); ok && o.untilOffset != nil && offset > *o.untilOffset {
+		return true
+	}
+	if enqueued, ok := event.GetEnqueuedTime(); ok && o.untilTime != nil && enqueued.After(*o.untilTime) {
+		return true
+	}
+	return false
+}
+
+// last reports whether event ends the export
+func (o *exportOptions) last(event *Event) bool {
+	if sequence, ok := event.GetSequenceNumber(); ok && sequence >= o.lastSequence {
+		return true
+	}
+	offset, ok := event.GetOffset()
+	return ok && o.untilOffset != nil && offset >= *o.untilOffset
+}
+
+func writeExported(w io.Writer, format ExportFormat, event *Event) error {
+	buf := buffer.Get()
+	defer buffer.Put(buf)
+
+	if format == ExportFormatJSONL {
+		exported := exportedEvent{
+			ID:           event.ID,
+			Data:         event.Data,
+			ContentType:  event.ContentType,
+			PartitionKey: event.PartitionKey,
+			Properties:   event.Properties,
+		}
+		if sequence, ok := event.GetSequenceNumber(); ok {
+			exported.SequenceNumber = &sequence
+		}
+		if offset, ok := event.GetOffset(); ok {
+			exported.Offset = &offset
+		}
+		if enqueued, ok := event.GetEnqueuedTime(); ok {
+			exported.EnqueuedTime = &enqueued
+		}
+
+		// Encode ends the line with a newline
+		if err := json.NewEncoder(buf).Encode(exported); err != nil {
+			return err
+		}
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+
+	msg := event.message
+	if msg == nil {
+		var err error
+		if msg, err = event.toMsg(); err != nil {
+			return err
+		}
+	}
+	bin, err := msg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(bin)))
+	buf.Write(size[:])
+	buf.Write(bin)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// NewExportReader creates a new ExportReader of the events written by ExportPartition in format to r
+func NewExportReader(r io.Reader, format ExportFormat) (*ExportReader, error) {
+	if format != ExportFormatAMQP && format != ExportFormatJSONL {
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+	return &ExportReader{format: format, reader: bufio.NewReader(r)}, nil
+}
+
+// Next returns the next event of the export, or io.EOF after the last one. Events read from ExportFormatAMQP exports
+// are as they were received, with all the sections of their messages.
+func (er *ExportReader) Next() (*Event, error) {
+	if er.format == ExportFormatJSONL {
+		return er.nextJSON()
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(er.reader, size[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("export is truncated")
+		}
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(size[:])
+	if length > maxExportedMessageSize {
+		return nil, fmt.Errorf("exported message of %d bytes exceeds the limit of %d bytes", length, maxExportedMessageSize)
+	}
+	// the decoded message copies what it keeps of the record, so the record is read into a pooled buffer
+	buf := buffer.Get()
+	defer buffer.Put(buf)
+	bin := buffer.Sized(buf, int(length))
+	if _, err := io.ReadFull(er.reader, bin); err != nil {
+		return nil, errors.New("export is truncated")
+	}
+
+	msg := new(amqp.Message)
+	if err := msg.UnmarshalBinary(bin); err != nil {
+		return nil, err
+	}
+	if len(msg.Data) == 0 {
+		msg.Data = [][]byte{nil}
+	}
+	return eventFromMsg(msg)
+}
+
+func (er *ExportReader) nextJSON() (*Event, error) {
+	for {
+		line, err := er.reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var exported exportedEvent
+		if err := json.Unmarshal(line, &exported); err != nil {
+			return nil, err
+		}
+
+		event := &Event{
+			ID:           exported.ID,
+			Data:         exported.Data,
+			ContentType:  exported.ContentType,
+			PartitionKey: exported.PartitionKey,
+			Properties:   exported.Properties,
+			SystemProperties: &SystemProperties{
+				SequenceNumber: exported.SequenceNumber,
+				Offset:         exported.Offset,
+				EnqueuedTime:   exported.EnqueuedTime,
+				PartitionKey:   exported.PartitionKey,
+			},
+		}
+		return event, nil
+	}
+}
+
+// ReplayEvents sends the events of an export to the Event Hub, returning the number of events sent. Events keep their
+// data, application properties, partition key, content type, ID and the other sections of their AMQP messages, but
+// not their system properties, which the Event Hub assigns anew.
+func (h *Hub) ReplayEvents(ctx context.Context, r *ExportReader, opts ...SendOption) (int, error) {
+	span, ctx := h.startSpanFromContext(ctx, "eh.Hub.ReplayEvents")
+	defer span.End()
+
+	sent := 0
+	for {
+		event, err := r.Next()
+		if err == io.EOF {
+			return sent, nil
+		}
+		if err != nil {
+			tab.For(ctx).Error(err)
+			return sent, err
+		}
+
+		if err := h.Send(ctx, replayedEvent(event), opts...); err != nil {
+			tab.For(ctx).Error(err)
+			return sent, err
+		}
+		sent++
+	}
+}
+
+// replayedEvent returns a copy of event for sending, without the annotations the service assigns
+func replayedEvent(event *Event) *Event {
+	replayed := &Event{
+		ID:           event.ID,
+		Data:         event.Data,
+		ContentType:  event.ContentType,
+		PartitionKey: event.PartitionKey,
+		Properties:   event.Properties,
+	}
+	replayed.RawAMQPMessage = event.RawAMQPMessage
+
+	annotations := make(map[string]interface{})
+	for key, val := range event.RawAMQPMessage.MessageAnnotations {
+		if !strings.HasPrefix(key, "x-opt-") {
+			annotations[key] = val
+		}
+	}
+	replayed.RawAMQPMessage.MessageAnnotations = annotations
+	return replayed
+}
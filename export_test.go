@@ -0,0 +1,165 @@
+package eventhub
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportTestEvent(t *testing.T, sequence int64, offset string, enqueued time.Time) *Event {
+	event, err := eventFromMsg(&amqp.Message{
+		Properties: &amqp.MessageProperties{MessageID: "id", ContentType: "text/plain", Subject: "subject"},
+		Annotations: amqp.Annotations{
+			sequenceNumberName:         sequence,
+			offsetAnnotationName:       offset,
+			enqueueTimeName:            enqueued,
+			partitionKeyAnnotationName: "key",
+			"x-route":                  "north",
+		},
+		ApplicationProperties: map[string]interface{}{"count": int32(3)},
+		Data:                  [][]byte{[]byte("hello")},
+	})
+	require.NoError(t, err)
+	return event
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	enqueued := time.Date(2021, 6, 3, 16, 48, 47, 0, time.UTC)
+
+	for _, format := range []ExportFormat{ExportFormatAMQP, ExportFormatJSONL} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeExported(&buf, format, exportTestEvent(t, 1, "100", enqueued)))
+			require.NoError(t, writeExported(&buf, format, exportTestEvent(t, 2, "200", enqueued)))
+
+			reader, err := NewExportReader(&buf, format)
+			require.NoError(t, err)
+
+			event, err := reader.Next()
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(event.Data))
+			assert.Equal(t, "id", event.ID)
+			assert.Equal(t, "text/plain", event.ContentType)
+			assert.Equal(t, "key", *event.PartitionKey)
+			sequence, _ := event.GetSequenceNumber()
+			assert.Equal(t, int64(1), sequence)
+			offset, _ := event.GetOffset()
+			assert.Equal(t, int64(100), offset)
+			enqueuedTime, _ := event.GetEnqueuedTime()
+			assert.True(t, enqueued.Equal(enqueuedTime))
+			if format == ExportFormatAMQP {
+				assert.Equal(t, int32(3), event.Properties["count"])
+				assert.Equal(t, "subject", event.RawAMQPMessage.Properties.Subject)
+			} else {
+				assert.Equal(t, float64(3), event.Properties["count"])
+			}
+
+			event, err = reader.Next()
+			require.NoError(t, err)
+			sequence, _ = event.GetSequenceNumber()
+			assert.Equal(t, int64(2), sequence)
+
+			_, err = reader.Next()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestExportReader_Errors(t *testing.T) {
+	_, err := NewExportReader(&bytes.Buffer{}, "csv")
+	assert.Error(t, err)
+
+	reader, err := NewExportReader(bytes.NewReader([]byte{0, 0, 0, 10, 1}), ExportFormatAMQP)
+	require.NoError(t, err)
+	_, err = reader.Next()
+	assert.EqualError(t, err, "export is truncated")
+
+	reader, err = NewExportReader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff}), ExportFormatAMQP)
+	require.NoError(t, err)
+	_, err = reader.Next()
+	assert.Error(t, err)
+
+	reader, err = NewExportReader(bytes.NewReader([]byte("{not json}\n")), ExportFormatJSONL)
+	require.NoError(t, err)
+	_, err = reader.Next()
+	assert.Error(t, err)
+}
+
+func TestExportOptions_Range(t *testing.T) {
+	enqueued := time.Date(2021, 6, 3, 16, 48, 47, 0, time.UTC)
+	options := &exportOptions{lastSequence: 10}
+	require.NoError(t, ExportUntilOffset(200)(options))
+
+	assert.False(t, options.after(exportTestEvent(t, 1, "100", enqueued)))
+	assert.False(t, options.last(exportTestEvent(t, 1, "100", enqueued)))
+	assert.True(t, options.last(exportTestEvent(t, 2, "200", enqueued)), "the export includes the event at the offset")
+	assert.True(t, options.after(exportTestEvent(t, 3, "300", enqueued)))
+	assert.True(t, options.last(exportTestEvent(t, 10, "150", enqueued)), "the export ends with the last event enqueued")
+
+	options = &exportOptions{lastSequence: 10}
+	require.NoError(t, ExportUntilTime(enqueued)(options))
+	assert.False(t, options.after(exportTestEvent(t, 1, "100", enqueued)))
+	assert.True(t, options.after(exportTestEvent(t, 2, "200", enqueued.Add(time.Second))))
+
+	assert.Error(t, ExportWithFormat("csv")(options))
+	assert.Error(t, ExportWithIdleTimeout(0)(options))
+}
+
+func TestReplayedEvent(t *testing.T) {
+	event := exportTestEvent(t, 1, "100", time.Now())
+	replayed := replayedEvent(event)
+
+	assert.Nil(t, replayed.SystemProperties)
+	assert.Equal(t, map[string]interface{}{"x-route": "north"}, replayed.RawAMQPMessage.MessageAnnotations)
+	assert.Equal(t, "subject", replayed.RawAMQPMessage.Properties.Subject)
+
+	msg, err := replayed.toMsg()
+	require.NoError(t, err)
+	assert.Equal(t, "key", *msg.Annotations[partitionKeyAnnotationName].(*string))
+	assert.NotContains(t, msg.Annotations, sequenceNumberName)
+}
+
+func BenchmarkExport(b *testing.B) {
+	event, err := eventFromMsg(&amqp.Message{
+		Properties:            &amqp.MessageProperties{MessageID: "id"},
+		Annotations:           amqp.Annotations{sequenceNumberName: int64(1), offsetAnnotationName: "100"},
+		ApplicationProperties: map[string]interface{}{"count": int32(3)},
+		Data:                  [][]byte{make([]byte, 1024)},
+	})
+	require.NoError(b, err)
+
+	for _, format := range []ExportFormat{ExportFormatAMQP, ExportFormatJSONL} {
+		b.Run(string(format)+"/Write", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := writeExported(ioutil.Discard, format, event); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(string(format)+"/Read", func(b *testing.B) {
+			var buf bytes.Buffer
+			require.NoError(b, writeExported(&buf, format, event))
+			exported := buf.Bytes()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				reader, err := NewExportReader(bytes.NewReader(exported), format)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := reader.Next(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/devigned/tab"
+)
+
+type (
+	// FailoverReceiver receives the partitions of an Event Hub replicated in two namespaces, typically in different
+	// regions. Each partition is received from the primary; once the primary is unavailable, because its receiver can't
+	// be started or gives up recovering, the partition is received from the secondary. The namespaces number their events
+	// independently, so the position on the secondary is picked by a FailoverPosition, from the time of the last event
+	// handled by default. Partitions don't switch back to the primary on their own.
+	FailoverReceiver struct {
+		primary   PartitionedReceiver
+		secondary PartitionedReceiver
+		position  FailoverPosition
+		listener  func(point FailoverPoint)
+	}
+
+	// FailoverReceiverOption provides structure for configuring a new FailoverReceiver
+	FailoverReceiverOption func(f *FailoverReceiver) error
+
+	// FailoverPoint describes a partition switching from the primary to the secondary namespace
+	FailoverPoint struct {
+		PartitionID string
+		// LastEvent is the last event of the partition handled from the primary, or nil if none was
+		LastEvent *Event
+		// Started is when the partition started to be received
+		Started time.Time
+		// Err is why the primary was given up
+		Err error
+	}
+
+	// FailoverPosition returns the option positioning the receiver of a partition on the secondary namespace. Offsets
+	// and sequence numbers of the primary don't identify events on the secondary, so the position is derived from time
+	// or the end of the partition.
+	FailoverPosition func(point FailoverPoint) ReceiveOption
+
+	// failoverPartition is the receipt of a partition by a FailoverReceiver
+	failoverPartition struct {
+		receiver    *FailoverReceiver
+		partitionID string
+		handler     Handler
+		opts        []ReceiveOption
+		started     time.Time
+		ctx         context.Context
+		cancel      context.CancelFunc
+
+		mu        sync.Mutex
+		current   *ListenerHandle
+		onPrimary bool
+		lastEvent *Event
+		closed    bool
+		err       error
+	}
+)
+
+// NewFailoverReceiver creates a FailoverReceiver which receives from primary and falls back to secondary, typically
+// Hubs for the same Event Hub in namespaces of different regions
+func NewFailoverReceiver(primary, secondary PartitionedReceiver, opts ...FailoverReceiverOption) (*FailoverReceiver, error) {
+	if primary == nil || secondary == nil {
+		return nil, errors.New("failover receiver requires a primary and a secondary")
+	}
+
+	f := &FailoverReceiver{
+		primary:   primary,
+		secondary: secondary,
+		position:  FailoverFromTimestamp(0),
+	}
+	for _, opt := range opts {
+		if err := opt(f); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// FailoverReceiverWithPosition configures where receivers start on the secondary namespace
+func FailoverReceiverWithPosition(position FailoverPosition) FailoverReceiverOption {
+	return func(f *FailoverReceiver) error {
+		if position == nil {
+			return errors.New("failover position must not be nil")
+		}
+		f.position = position
+		return nil
+	}
+}
+
+// FailoverReceiverWithListener configures the FailoverReceiver to report each partition switching to the secondary
+// namespace to the listener. It is called synchronously, so it must not block.
+func FailoverReceiverWithListener(listener func(point FailoverPoint)) FailoverReceiverOption {
+	return func(f *FailoverReceiver) error {
+		if listener == nil {
+			return errors.New("failover listener must not be nil")
+		}
+		f.listener = listener
+		return nil
+	}
+}
+
+// FailoverFromTimestamp starts receivers on the secondary namespace with the events enqueued after the last event
+// handled from the primary, less rewind, or after the partition started to be received if no event was handled.
+// Replication between namespaces lags and enqueues events at different times, so a rewind of a few minutes trades
+// events handled twice for fewer events skipped.
+func FailoverFromTimestamp(rewind time.Duration) FailoverPosition {
+	return func(point FailoverPoint) ReceiveOption {
+		from := point.Started
+		if point.LastEvent != nil {
+			if enqueued, ok := point.LastEvent.GetEnqueuedTime(); ok {
+				from = enqueued
+			}
+		}
+		return ReceiveFromTimestamp(from.Add(-rewind))
+	}
+}
+
+// FailoverFromEnd starts receivers on the secondary namespace with the events enqueued after the switch, skipping
+// any the primary didn't deliver
+func FailoverFromEnd() FailoverPosition {
+	return func(FailoverPoint) ReceiveOption {
+		return ReceiveWithLatestOffset()
+	}
+}
+
+// Receive receives the partition from the primary, or from the secondary if the primary is or becomes unavailable.
+// The options apply to both; on the secondary, the position of the FailoverReceiver takes the place of any starting
+// position among them. The handle is done once the partition is no longer received from either namespace.
+func (f *FailoverReceiver) Receive(ctx context.Context, partitionID string, handler Handler, opts ...ReceiveOption) (*ListenerHandle, error) {
+	ctx, span := tab.StartSpan(ctx, "eh.FailoverReceiver.Receive")
+	defer span.End()
+	ApplyComponentInfo(span)
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
+
+	listenerCtx, cancel := context.WithCancel(context.Background())
+	p := &failoverPartition{
+		receiver:    f,
+		partitionID: partitionID,
+		handler:     handler,
+		opts:        opts,
+		started:     time.Now(),
+		ctx:         listenerCtx,
+		cancel:      cancel,
+		onPrimary:   true,
+	}
+
+	handle, err := f.primary.Receive(ctx, partitionID, p.handle, opts...)
+	if err != nil {
+		if ctx.Err() != nil {
+			cancel()
+			return nil, err
+		}
+		tab.For(ctx).Error(err)
+		handle, err = p.failover(ctx, err)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	p.mu.Lock()
+	p.current = handle
+	p.mu.Unlock()
+	go p.watch()
+	return NewListenerHandle(listenerCtx, p.close, p.lastErr), nil
+}
+
+// handle hands an event to the handler, remembering the last event of the primary the handler accepted
+func (p *failoverPartition) handle(ctx context.Context, event *Event) error {
+	if err := p.handler(ctx, event); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	if p.onPrimary {
+		p.lastEvent = event
+	}
+	p.mu.Unlock()
+	return nil
+}
+
+// failover starts receiving the partition from the secondary after the primary failed with cause
+func (p *failoverPartition) failover(ctx context.Context, cause error) (*ListenerHandle, error) {
+	p.mu.Lock()
+	p.onPrimary = false
+	point := FailoverPoint{PartitionID: p.partitionID, LastEvent: p.lastEvent, Started: p.started, Err: cause}
+	p.mu.Unlock()
+
+	opts := append(append([]ReceiveOption{}, p.opts...), p.receiver.position(point))
+	handle, err := p.receiver.secondary.Receive(ctx, p.partitionID, p.handler, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive partition %s from the primary (%v) and the secondary: %w", p.partitionID, cause, err)
+	}
+
+	if p.receiver.listener != nil {
+		p.receiver.listener(point)
+	}
+	return handle, nil
+}
+
+// watch switches to the secondary when the receiver of the primary stops on its own with an error, and ends the
+// receipt when the receiver of the secondary stops or either is closed
+func (p *failoverPartition) watch() {
+	for {
+		p.mu.Lock()
+		handle, onPrimary := p.current, p.onPrimary
+		p.mu.Unlock()
+
+		select {
+		case <-handle.Done():
+		case <-p.ctx.Done():
+			return
+		}
+
+		err := handle.Err()
+		p.mu.Lock()
+		closed := p.closed
+		p.mu.Unlock()
+		if closed || !onPrimary || err == nil || errors.Is(err, context.Canceled) {
+			p.finish(err)
+			return
+		}
+
+		next, failoverErr := p.failover(p.ctx, err)
+		if failoverErr != nil {
+			p.finish(failoverErr)
+			return
+		}
+
+		p.mu.Lock()
+		p.current = next
+		closed = p.closed
+		p.mu.Unlock()
+		if closed {
+			// closed while switching; the receiver of the secondary must not outlive the handle
+			_ = next.Close(context.Background())
+			p.finish(nil)
+			return
+		}
+	}
+}
+
+// finish ends the receipt, recording err unless the receiver was closed
+func (p *failoverPartition) finish(err error) {
+	p.mu.Lock()
+	if err != nil && !errors.Is(err, context.Canceled) {
+		p.err = err
+	}
+	p.mu.Unlock()
+	p.cancel()
+}
+
+// close stops receiving the partition from whichever namespace it is received from
+func (p *failoverPartition) close(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	handle := p.current
+	p.mu.Unlock()
+
+	var err error
+	if handle != nil {
+		err = handle.Close(ctx)
+	}
+	p.cancel()
+	return err
+}
+
+func (p *failoverPartition) lastErr() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
@@ -0,0 +1,183 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+type (
+	fakeRegion struct {
+		mu        sync.Mutex
+		err       error
+		receivers []*fakeRegionReceiver
+	}
+
+	fakeRegionReceiver struct {
+		settings *ReceiveSettings
+		handler  Handler
+		cancel   context.CancelFunc
+		err      error
+	}
+)
+
+func (f *fakeRegion) Receive(_ context.Context, _ string, handler Handler, opts ...ReceiveOption) (*ListenerHandle, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	settings, err := ResolveReceiveOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &fakeRegionReceiver{settings: settings, handler: handler, cancel: cancel}
+	f.receivers = append(f.receivers, r)
+	return NewListenerHandle(ctx, func(context.Context) error {
+		cancel()
+		return nil
+	}, func() error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return r.err
+	}), nil
+}
+
+func (f *fakeRegion) receiver(t *testing.T) *fakeRegionReceiver {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	require.Len(t, f.receivers, 1)
+	return f.receivers[0]
+}
+
+// fail stops the receiver as a Hub's receiver does once it gives up recovering
+func (f *fakeRegion) fail(t *testing.T, err error) {
+	r := f.receiver(t)
+	f.mu.Lock()
+	r.err = err
+	f.mu.Unlock()
+	r.cancel()
+}
+
+func eventEnqueuedAt(t time.Time) *Event {
+	return &Event{SystemProperties: &SystemProperties{EnqueuedTime: &t}}
+}
+
+func TestFailoverReceiver_SwitchesWhenThePrimaryGivesUp(t *testing.T) {
+	primary, secondary := new(fakeRegion), new(fakeRegion)
+	points := make(chan FailoverPoint, 1)
+	f, err := NewFailoverReceiver(primary, secondary,
+		FailoverReceiverWithPosition(FailoverFromTimestamp(time.Minute)),
+		FailoverReceiverWithListener(func(point FailoverPoint) { points <- point }))
+	require.NoError(t, err)
+
+	var handled []*Event
+	handle, err := f.Receive(context.Background(), "3", func(_ context.Context, event *Event) error {
+		handled = append(handled, event)
+		return nil
+	}, ReceiveWithConsumerGroup("orders"))
+	require.NoError(t, err)
+
+	enqueued := time.Date(2021, 5, 4, 12, 0, 0, 0, time.UTC)
+	last := eventEnqueuedAt(enqueued)
+	require.NoError(t, primary.receiver(t).handler(context.Background(), last))
+
+	unavailable := errors.New("namespace unavailable")
+	primary.fail(t, unavailable)
+
+	point := <-points
+	assert.Equal(t, "3", point.PartitionID)
+	assert.Equal(t, last, point.LastEvent)
+	assert.Equal(t, unavailable, point.Err)
+
+	settings := secondary.receiver(t).settings
+	assert.Equal(t, "orders", settings.ConsumerGroup, "options apply to the secondary as well")
+	assert.Equal(t, enqueued.Add(-time.Minute), settings.StartingCheckpoint.EnqueueTime)
+	assert.Empty(t, settings.StartingCheckpoint.Offset, "offsets of the primary don't carry over")
+
+	select {
+	case <-handle.Done():
+		t.Fatal("the handle is done only once the secondary stops")
+	default:
+	}
+
+	require.NoError(t, handle.Close(context.Background()))
+	<-handle.Done()
+	assert.Equal(t, context.Canceled, handle.Err(), "like the handles of a Hub, closed handles report cancellation")
+	assert.Len(t, handled, 1)
+}
+
+func TestFailoverReceiver_StartsOnTheSecondaryWhenThePrimaryIsDown(t *testing.T) {
+	primary, secondary := &fakeRegion{err: errors.New("no such host")}, new(fakeRegion)
+	f, err := NewFailoverReceiver(primary, secondary, FailoverReceiverWithPosition(FailoverFromEnd()))
+	require.NoError(t, err)
+
+	handle, err := f.Receive(context.Background(), "0", func(context.Context, *Event) error { return nil },
+		ReceiveWithStartingOffset("1024"))
+	require.NoError(t, err)
+	defer func() { _ = handle.Close(context.Background()) }()
+
+	assert.Equal(t, persist.EndOfStream, secondary.receiver(t).settings.StartingCheckpoint.Offset)
+
+	secondary.err = errors.New("also down")
+	primary.err = errors.New("still down")
+	_, err = f.Receive(context.Background(), "1", func(context.Context, *Event) error { return nil })
+	assert.True(t, errors.Is(err, secondary.err))
+}
+
+func TestFailoverReceiver_EndsWithTheSecondary(t *testing.T) {
+	primary, secondary := new(fakeRegion), new(fakeRegion)
+	f, err := NewFailoverReceiver(primary, secondary)
+	require.NoError(t, err)
+
+	started := time.Now()
+	handle, err := f.Receive(context.Background(), "0", func(context.Context, *Event) error { return nil })
+	require.NoError(t, err)
+
+	primary.fail(t, errors.New("primary gone"))
+	require.Eventually(t, func() bool {
+		secondary.mu.Lock()
+		defer secondary.mu.Unlock()
+		return len(secondary.receivers) == 1
+	}, time.Second, time.Millisecond)
+	assert.False(t, secondary.receiver(t).settings.StartingCheckpoint.EnqueueTime.Before(started),
+		"without events handled, the secondary starts from when the partition started to be received")
+
+	lost := errors.New("secondary gone")
+	secondary.fail(t, lost)
+	<-handle.Done()
+	assert.Equal(t, lost, handle.Err())
+}
+
+func TestFailoverReceiver_ClosingThePrimaryDoesNotSwitch(t *testing.T) {
+	primary, secondary := new(fakeRegion), new(fakeRegion)
+	f, err := NewFailoverReceiver(primary, secondary)
+	require.NoError(t, err)
+
+	handle, err := f.Receive(context.Background(), "0", func(context.Context, *Event) error { return nil })
+	require.NoError(t, err)
+
+	// a Hub closing its receivers cancels them
+	primary.receiver(t).cancel()
+	<-handle.Done()
+	assert.Equal(t, context.Canceled, handle.Err())
+	assert.Empty(t, secondary.receivers)
+}
+
+func TestNewFailoverReceiver(t *testing.T) {
+	_, err := NewFailoverReceiver(nil, new(fakeRegion))
+	assert.Error(t, err)
+	_, err = NewFailoverReceiver(new(fakeRegion), new(fakeRegion), FailoverReceiverWithPosition(nil))
+	assert.Error(t, err)
+	_, err = NewFailoverReceiver(new(fakeRegion), new(fakeRegion), FailoverReceiverWithListener(nil))
+	assert.Error(t, err)
+}
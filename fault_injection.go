@@ -0,0 +1,278 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp"
+)
+
+type (
+	// FaultInjector injects transport faults into the connections and links of a Hub, so tests can verify how an
+	// application recovers from them. Faults are armed with its methods at any time, also while the Hub is in use,
+	// and fire on the connections and links of every Hub it is configured on with HubWithFaultInjection. It is safe
+	// for concurrent use.
+	FaultInjector struct {
+		mu            sync.Mutex
+		dropAfter     int
+		deliveryDelay time.Duration
+		detaches      []*amqp.Error
+		// detachArmed is closed when a detach is forced, to interrupt receivers waiting for events
+		detachArmed chan struct{}
+	}
+
+	// faultConn counts the AMQP frames of a connection for a FaultInjector
+	faultConn struct {
+		net.Conn
+		in  *frameTracer
+		out *frameTracer
+	}
+
+	// faultTransport opens the sessions of its base transport with links a FaultInjector can fail
+	faultTransport struct {
+		base   transport
+		faults *FaultInjector
+	}
+
+	faultSession struct {
+		amqpSession
+		faults *FaultInjector
+	}
+
+	faultSender struct {
+		amqpSender
+		faults *FaultInjector
+	}
+
+	faultReceiver struct {
+		amqpReceiver
+		faults *FaultInjector
+	}
+)
+
+// NewFaultInjector creates a new FaultInjector without any faults armed
+func NewFaultInjector() *FaultInjector {
+	return new(FaultInjector)
+}
+
+// HubWithFaultInjection configures the Hub to suffer the faults armed on faults. Faults are meant for tests; a Hub
+// configured with a FaultInjector dials its connections itself, like a Hub configured with HubWithAMQPDialer, so the frames
+// of its connections can be counted.
+func HubWithFaultInjection(faults *FaultInjector) HubOption {
+	return func(h *Hub) error {
+		if faults == nil {
+			return errors.New("fault injector must not be nil")
+		}
+		h.namespace.faults = faults
+		return nil
+	}
+}
+
+// DropConnectionAfter closes the connection which carries the frames-th AMQP frame sent or received from now on, on
+// any connection of the Hub, as a network failure would. Heartbeats count as frames, protocol headers don't. A count
+// below 1 disarms the fault.
+func (f *FaultInjector) DropConnectionAfter(frames int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if frames < 0 {
+		frames = 0
+	}
+	f.dropAfter = frames
+}
+
+// DelayDeliveries holds back each event delivered to a receiver of the Hub by d, as a congested network would. A
+// delay of 0 disarms the fault.
+func (f *FaultInjector) DelayDeliveries(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deliveryDelay = d
+}
+
+// ForceDetach fails the next send or receive on any link of the Hub as if the service had detached the link with the
+// given condition, such as amqp.ErrorDetachForced or "amqp:link:stolen", and description. Receivers waiting for
+// events fail right away. Each call fails one operation.
+func (f *FaultInjector) ForceDetach(condition amqp.ErrorCondition, description string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.detaches = append(f.detaches, &amqp.Error{Condition: condition, Description: description})
+	if f.detachArmed != nil {
+		close(f.detachArmed)
+		f.detachArmed = nil
+	}
+}
+
+// Reset disarms all faults
+func (f *FaultInjector) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.dropAfter = 0
+	f.deliveryDelay = 0
+	f.detaches = nil
+}
+
+// countFrame counts a frame of conn, and closes conn if it's the one the armed drop is after
+func (f *FaultInjector) countFrame(conn net.Conn) {
+	f.mu.Lock()
+	if f.dropAfter == 0 {
+		f.mu.Unlock()
+		return
+	}
+	f.dropAfter--
+	drop := f.dropAfter == 0
+	f.mu.Unlock()
+
+	if drop {
+		_ = conn.Close()
+	}
+}
+
+func (f *FaultInjector) delay() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.deliveryDelay
+}
+
+// detach returns the error of the next forced detach, or nil and a channel closed when one is forced
+func (f *FaultInjector) detach() (<-chan struct{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.detaches) == 0 {
+		if f.detachArmed == nil {
+			f.detachArmed = make(chan struct{})
+		}
+		return f.detachArmed, nil
+	}
+	remote := f.detaches[0]
+	f.detaches = f.detaches[1:]
+	return nil, &amqp.DetachError{RemoteError: remote}
+}
+
+// injectFaults wraps conn so the namespace's fault injector, if one is configured, can count its frames and drop it
+func (ns *namespace) injectFaults(conn net.Conn) net.Conn {
+	if ns.faults == nil {
+		return conn
+	}
+
+	c := &faultConn{Conn: conn}
+	count := func(summary string) {
+		if !strings.Contains(summary, " protocol header ") {
+			ns.faults.countFrame(conn)
+		}
+	}
+	c.in = &frameTracer{direction: "<-", trace: count}
+	c.out = &frameTracer{direction: "->", trace: count}
+	return c
+}
+
+func (c *faultConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.in.feed(b[:n])
+	}
+	return n, err
+}
+
+func (c *faultConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.out.feed(b[:n])
+	}
+	return n, err
+}
+
+func (t faultTransport) newSession(conn *amqp.Client) (amqpSession, error) {
+	s, err := t.base.newSession(conn)
+	if err != nil {
+		return nil, err
+	}
+	return &faultSession{amqpSession: s, faults: t.faults}, nil
+}
+
+func (s *faultSession) NewSender(opts ...amqp.LinkOption) (amqpSender, error) {
+	sender, err := s.amqpSession.NewSender(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &faultSender{amqpSender: sender, faults: s.faults}, nil
+}
+
+func (s *faultSession) NewReceiver(opts ...amqp.LinkOption) (amqpReceiver, error) {
+	receiver, err := s.amqpSession.NewReceiver(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &faultReceiver{amqpReceiver: receiver, faults: s.faults}, nil
+}
+
+func (s *faultSender) Send(ctx context.Context, msg *amqp.Message) error {
+	if _, err := s.faults.detach(); err != nil {
+		return err
+	}
+	return s.amqpSender.Send(ctx, msg)
+}
+
+func (r *faultReceiver) Receive(ctx context.Context) (*amqp.Message, error) {
+	if d := r.faults.delay(); d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+
+	for {
+		armed, err := r.faults.detach()
+		if err != nil {
+			return nil, err
+		}
+
+		// receive until a detach is forced; canceling a receive leaves its events buffered on the link
+		receiveCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-armed:
+				cancel()
+			case <-receiveCtx.Done():
+			}
+		}()
+		msg, err := r.amqpReceiver.Receive(receiveCtx)
+		interrupted := receiveCtx.Err() != nil && ctx.Err() == nil
+		cancel()
+		if err != nil && interrupted {
+			continue
+		}
+		return msg, err
+	}
+}
@@ -0,0 +1,133 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFaultReceiver(t *testing.T, faults *FaultInjector) (*memoryReceiver, amqpReceiver) {
+	memory := new(memoryTransport)
+	ns := &namespace{transport: memory, faults: faults}
+	s, err := ns.amqpTransport().newSession(nil)
+	require.NoError(t, err)
+	link, err := s.NewReceiver()
+	require.NoError(t, err)
+	return memory.sessions[0].receivers[0], link
+}
+
+func TestHubWithFaultInjection(t *testing.T) {
+	h := &Hub{namespace: new(namespace)}
+	assert.Error(t, HubWithFaultInjection(nil)(h))
+
+	faults := NewFaultInjector()
+	require.NoError(t, HubWithFaultInjection(faults)(h))
+	assert.Same(t, faults, h.namespace.faults)
+	assert.Equal(t, faultTransport{base: goAMQPTransport{}, faults: faults}, h.namespace.amqpTransport())
+}
+
+func TestFaultInjector_DropConnectionAfter(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	faults := NewFaultInjector()
+	ns := &namespace{faults: faults}
+	conn := ns.injectFaults(client)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			if _, err := server.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	_, err := conn.Write([]byte("AMQP\x00\x01\x00\x00"))
+	require.NoError(t, err)
+	_, err = conn.Write(amqpFrame(0, nil))
+	require.NoError(t, err, "frames pass while no drop is armed")
+
+	faults.DropConnectionAfter(2)
+	_, err = conn.Write([]byte("AMQP\x00\x01\x00\x00"))
+	require.NoError(t, err, "protocol headers aren't frames")
+	_, err = conn.Write(amqpFrame(0, nil))
+	require.NoError(t, err)
+	_, err = conn.Write(amqpFrame(0, nil))
+	require.NoError(t, err, "the frame the drop is after is carried")
+
+	_, err = conn.Write(amqpFrame(0, nil))
+	assert.Error(t, err, "the connection is closed after the frame")
+}
+
+func TestFaultInjector_ForceDetachInterruptsReceive(t *testing.T) {
+	faults := NewFaultInjector()
+	memory, link := newFaultReceiver(t, faults)
+
+	received := make(chan error, 1)
+	go func() {
+		_, err := link.Receive(context.Background())
+		received <- err
+	}()
+
+	faults.ForceDetach(amqp.ErrorDetachForced, "entity updated")
+	select {
+	case err := <-received:
+		var detach *amqp.DetachError
+		require.True(t, errors.As(err, &detach))
+		assert.Equal(t, amqp.ErrorDetachForced, detach.RemoteError.Condition)
+		assert.Equal(t, "entity updated", detach.RemoteError.Description)
+	case <-time.After(5 * time.Second):
+		t.Fatal("a forced detach should fail a waiting receive")
+	}
+
+	memory.messages <- &amqp.Message{Data: [][]byte{[]byte("hello")}}
+	msg, err := link.Receive(context.Background())
+	require.NoError(t, err, "each forced detach fails one operation")
+	assert.Equal(t, []byte("hello"), msg.GetData())
+}
+
+func TestFaultInjector_ForceDetachSend(t *testing.T) {
+	faults := NewFaultInjector()
+	base := new(testAmqpSender)
+	sender := &faultSender{amqpSender: base, faults: faults}
+
+	faults.ForceDetach("amqp:link:stolen", "")
+	err := sender.Send(context.Background(), amqp.NewMessage(nil))
+	var detach *amqp.DetachError
+	require.True(t, errors.As(err, &detach))
+	assert.Equal(t, amqp.ErrorCondition("amqp:link:stolen"), detach.RemoteError.Condition)
+	assert.Equal(t, 0, base.sendCount)
+
+	require.NoError(t, sender.Send(context.Background(), amqp.NewMessage(nil)))
+	assert.Equal(t, 1, base.sendCount)
+}
+
+func TestFaultInjector_DelayDeliveries(t *testing.T) {
+	faults := NewFaultInjector()
+	memory, link := newFaultReceiver(t, faults)
+
+	faults.DelayDeliveries(50 * time.Millisecond)
+	memory.messages <- &amqp.Message{Data: [][]byte{[]byte("hello")}}
+	start := time.Now()
+	_, err := link.Receive(context.Background())
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = link.Receive(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	faults.Reset()
+	memory.messages <- &amqp.Message{Data: [][]byte{[]byte("hello")}}
+	start = time.Now()
+	_, err = link.Receive(context.Background())
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+}
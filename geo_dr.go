@@ -0,0 +1,326 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/devigned/tab"
+)
+
+const (
+	// geoDRMinCheckInterval bounds how often failing links trigger a look up of the alias, however many recover at once
+	geoDRMinCheckInterval = time.Second
+	geoDRLookupTimeout    = 10 * time.Second
+)
+
+type (
+	// FailoverEvent reports that the Geo-DR alias the Hub connects to now points to another namespace. Geo-DR replicates
+	// the configuration of a namespace, not its events: the offsets and sequence numbers checkpointed against the
+	// previous primary don't refer to the same events on the new one, and may be beyond the end of its partitions.
+	// Receivers resume from the last event they handled, so applications should decide whether to reset their
+	// checkpoints, for example to the start or the end of each partition, before processing further.
+	FailoverEvent struct {
+		// Alias is the host of the alias the Hub connects to
+		Alias string
+		// PreviousHost is the host the alias pointed to before the failover
+		PreviousHost string
+		// Host is the host the alias points to now
+		Host string
+		// Cause is the redirect the service sent, or nil when the failover was seen in DNS
+		Cause error
+	}
+
+	// FailoverListener is called once per failover of the alias, after the connections of the Hub have been closed so
+	// its links are rebuilt against the new primary. It is called synchronously, so it must not block.
+	FailoverListener func(event FailoverEvent)
+
+	// geoDR follows the namespace a Geo-DR alias resolves to. A failover is seen when DNS resolves the alias to another
+	// host, or when the service redirects a connection or link to another host.
+	geoDR struct {
+		alias      string
+		interval   time.Duration
+		listener   FailoverListener
+		resolve    func(ctx context.Context, host string) (string, error)
+		onFailover func(event FailoverEvent)
+
+		mu sync.Mutex
+		// resolved is the host the CNAME record of the alias last pointed to
+		resolved string
+		// redirectHost and redirect are the host the service last redirected to and the address to dial it at
+		redirectHost string
+		redirect     string
+		lastCheck    time.Time
+		cancel       context.CancelFunc
+	}
+)
+
+// HubWithGeoDRFailover follows failovers of the Geo-DR alias the Hub connects to. The CNAME record of the alias, which
+// points to its primary namespace, is looked up in DNS every interval and whenever a link fails, and redirects sent by the service are followed. On a failover, the connections
+// of the Hub are closed so its senders and receivers rebuild their links against the new primary, and the listener is
+// told that checkpointed offsets may no longer be valid. Watching starts with the first connection and stops when the
+// Hub is closed.
+func HubWithGeoDRFailover(interval time.Duration, listener FailoverListener) HubOption {
+	return func(h *Hub) error {
+		if interval <= 0 {
+			return fmt.Errorf("Geo-DR check interval must be positive, got %v", interval)
+		}
+		if listener == nil {
+			return errors.New("failover listener must not be nil")
+		}
+
+		h.namespace.geoDR = &geoDR{
+			alias:      strings.TrimPrefix(h.namespace.host, "amqps://"),
+			interval:   interval,
+			listener:   listener,
+			resolve:    newAliasResolver().resolve,
+			onFailover: h.failover,
+		}
+		return nil
+	}
+}
+
+// start looks up the alias every interval until stopped, unless it is already doing so
+func (g *geoDR) start() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g.cancel = cancel
+	go func() {
+		ticker := time.NewTicker(g.interval)
+		defer ticker.Stop()
+		for {
+			// the first look up records the host the alias points to while connecting
+			g.check(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// stop ends looking up the alias; a later connection starts it again
+func (g *geoDR) stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cancel != nil {
+		g.cancel()
+		g.cancel = nil
+	}
+}
+
+// check looks up the alias and reports a failover if it points to another host than it did before
+func (g *geoDR) check(ctx context.Context) {
+	g.mu.Lock()
+	g.lastCheck = time.Now()
+	g.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, geoDRLookupTimeout)
+	defer cancel()
+	host, err := g.resolve(ctx, g.alias)
+	if err != nil {
+		// a failed look up says nothing about the alias; the next one may succeed
+		tab.For(ctx).Debug(fmt.Sprintf("failed to look up Geo-DR alias %s: %v", g.alias, err))
+		return
+	}
+
+	g.resolvedTo(strings.TrimSuffix(host, "."))
+}
+
+// checkAfter looks up the alias on behalf of a link which failed with cause, unless the service redirected the link,
+// which is followed instead. Look ups are skipped if one was made less than geoDRMinCheckInterval ago.
+func (g *geoDR) checkAfter(ctx context.Context, cause error) {
+	if remote := remoteAMQPError(cause); remote != nil && isRedirect(remote.Condition) {
+		if host, address := redirectTarget(remote); host != "" {
+			g.redirectedTo(host, address, cause)
+			return
+		}
+	}
+
+	g.mu.Lock()
+	recent := time.Since(g.lastCheck) < geoDRMinCheckInterval
+	g.mu.Unlock()
+	if !recent {
+		g.check(ctx)
+	}
+}
+
+// resolvedTo records that DNS resolves the alias to host, and reports a failover if it resolved to another host before.
+// The first look up only learns where the alias points.
+func (g *geoDR) resolvedTo(host string) {
+	g.mu.Lock()
+	previous := g.resolved
+	if previous == host {
+		g.mu.Unlock()
+		return
+	}
+	g.resolved = host
+	if previous == "" {
+		g.mu.Unlock()
+		return
+	}
+	// the alias pointing somewhere new supersedes an earlier redirect
+	g.redirectHost, g.redirect = "", ""
+	g.mu.Unlock()
+
+	g.onFailover(FailoverEvent{Alias: g.alias, PreviousHost: previous, Host: host})
+}
+
+// redirectedTo records that the service redirected a connection or link to host, to be dialed at address, and reports
+// a failover unless it redirected there before
+func (g *geoDR) redirectedTo(host, address string, cause error) {
+	g.mu.Lock()
+	g.redirect = address
+	previous := g.redirectHost
+	if previous == host {
+		g.mu.Unlock()
+		return
+	}
+	g.redirectHost = host
+	if previous == "" {
+		previous = g.resolved
+	}
+	g.mu.Unlock()
+
+	g.onFailover(FailoverEvent{Alias: g.alias, PreviousHost: previous, Host: host, Cause: cause})
+}
+
+// redirectedAddress returns the address connections must be dialed at after a redirect, or an empty string
+func (g *geoDR) redirectedAddress() string {
+	if g == nil {
+		return ""
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.redirect
+}
+
+// isRedirect is true for the conditions the service redirects connections and links to another host with
+func isRedirect(condition amqp.ErrorCondition) bool {
+	return condition == amqp.ErrorConnectionRedirect || condition == amqp.ErrorLinkRedirect
+}
+
+// redirectTarget returns the host a redirect points to and the address to dial it at, from the hostname, network-host
+// and port of the redirect's info
+func redirectTarget(remote *amqp.Error) (string, string) {
+	host, _ := remote.Info["hostname"].(string)
+	networkHost, _ := remote.Info["network-host"].(string)
+	if networkHost == "" {
+		networkHost = host
+	}
+	if host == "" {
+		host = networkHost
+	}
+	if networkHost == "" {
+		return "", ""
+	}
+
+	port := amqpsPort
+	if p, ok := remote.Info["port"]; ok && p != nil {
+		port = fmt.Sprint(p)
+	}
+	return host, net.JoinHostPort(networkHost, port)
+}
+
+// detectFailover checks whether the link failing with cause is a sign the Geo-DR alias failed over, if the Hub follows
+// failovers
+func (h *Hub) detectFailover(ctx context.Context, cause error) {
+	if h.namespace == nil || h.namespace.geoDR == nil || cause == nil {
+		return
+	}
+	h.namespace.geoDR.checkAfter(ctx, cause)
+}
+
+// failover closes the connections of the Hub, so each sender and receiver recovers its link against the new primary,
+// and reports the failover
+func (h *Hub) failover(event FailoverEvent) {
+	ctx := context.Background()
+	h.log(ctx, LogLevelWarn, "Geo-DR alias failed over; rebuilding links", "alias", event.Alias, "previousHost", event.PreviousHost, "host", event.Host)
+	h.namespace.notifyConnection(ConnectionEvent{Type: ConnectionEventFailover, Host: event.Host, Err: event.Cause})
+
+	if h.namespace.pool != nil {
+		h.namespace.pool.evict(h.namespace.host)
+	} else {
+		for _, conn := range h.connections() {
+			_ = h.namespace.failConnection(conn)
+		}
+	}
+
+	h.mgmtMu.Lock()
+	h.mgmtConn = nil
+	if h.mgmtClient != nil {
+		// runtime information cached from the previous primary describes its partitions, not the new one's
+		h.mgmtClient.Invalidate()
+	}
+	h.mgmtMu.Unlock()
+
+	h.namespace.geoDR.listener(event)
+}
+
+// connections returns the connections the senders, receivers and management client of the Hub use
+func (h *Hub) connections() []*amqp.Client {
+	var conns []*amqp.Client
+	add := func(conn *amqp.Client) {
+		if conn != nil {
+			conns = append(conns, conn)
+		}
+	}
+
+	h.receiverMu.Lock()
+	for _, r := range h.receivers {
+		add(r.currentConnection())
+	}
+	h.receiverMu.Unlock()
+
+	h.senderMu.RLock()
+	senders := []*sender{h.sender}
+	senders = append(senders, h.senderStripes...)
+	for _, s := range h.partitionSenders {
+		senders = append(senders, s)
+	}
+	h.senderMu.RUnlock()
+	for _, s := range senders {
+		if s != nil {
+			add(s.currentConnection())
+		}
+	}
+
+	h.mgmtMu.Lock()
+	add(h.mgmtConn)
+	h.mgmtMu.Unlock()
+	return conns
+}
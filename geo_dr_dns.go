@@ -0,0 +1,230 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// resolvConfPath lists the name servers of the host on Unix systems
+	resolvConfPath = "/etc/resolv.conf"
+	// dnsUDPSize is the largest DNS response read over UDP; larger ones are truncated and asked again over TCP
+	dnsUDPSize = 512
+)
+
+type (
+	// aliasResolver looks up the CNAME record of a Geo-DR alias. Unlike net.Resolver.LookupCNAME, which follows the
+	// whole chain of CNAME records to the cluster hosting the namespace, it returns the first hop only: the namespace
+	// the alias points to. The cluster a namespace is hosted on changes with the maintenance of the service, which
+	// must not be mistaken for a failover.
+	aliasResolver struct {
+		// servers are the addresses of the name servers asked in turn, until one answers
+		servers []string
+		dialer  net.Dialer
+	}
+)
+
+// newAliasResolver returns a resolver asking the name servers of the host, or the local one if none are configured
+func newAliasResolver() *aliasResolver {
+	servers := readNameServers(resolvConfPath)
+	if len(servers) == 0 {
+		servers = []string{"127.0.0.1:53", "[::1]:53"}
+	}
+	return &aliasResolver{servers: servers}
+}
+
+// readNameServers returns the addresses of the name servers listed in the resolv.conf file at path, if any
+func readNameServers(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		// addresses with a zone, such as fe80::1%eth0, keep it for dialing
+		if ip := net.ParseIP(strings.SplitN(fields[1], "%", 2)[0]); ip != nil {
+			servers = append(servers, net.JoinHostPort(fields[1], "53"))
+		}
+	}
+	return servers
+}
+
+// resolve returns the host the CNAME record of host points to, or host itself if it has no CNAME record
+func (r *aliasResolver) resolve(ctx context.Context, host string) (string, error) {
+	name, err := dnsmessage.NewName(fqdn(host))
+	if err != nil {
+		return "", err
+	}
+
+	var lastErr error
+	for _, server := range r.servers {
+		target, err := r.ask(ctx, server, name)
+		if err == nil {
+			return target, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return "", &net.DNSError{Err: lastErr.Error(), Name: host}
+}
+
+// ask queries server for the CNAME record of name, over UDP and then over TCP if the response was truncated
+func (r *aliasResolver) ask(ctx context.Context, server string, name dnsmessage.Name) (string, error) {
+	id := uint16(rand.Uint32())
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return "", err
+	}
+
+	response, err := r.exchange(ctx, "udp", server, query)
+	if err != nil {
+		return "", err
+	}
+	var header dnsmessage.Header
+	if header, err = parseHeader(response); err == nil && header.Truncated {
+		if response, err = r.exchange(ctx, "tcp", server, query); err != nil {
+			return "", err
+		}
+	}
+	return cnameTarget(response, id, name)
+}
+
+// exchange sends query to server over network and returns the response
+func (r *aliasResolver) exchange(ctx context.Context, network, server string, query []byte) ([]byte, error) {
+	conn, err := r.dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		response := make([]byte, dnsUDPSize)
+		n, err := conn.Read(response)
+		if err != nil {
+			return nil, err
+		}
+		return response[:n], nil
+	}
+
+	// over TCP, messages are prefixed with their length
+	framed := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(framed, uint16(len(query)))
+	copy(framed[2:], query)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func parseHeader(response []byte) (dnsmessage.Header, error) {
+	var parser dnsmessage.Parser
+	return parser.Start(response)
+}
+
+// cnameTarget returns the target of the CNAME record of name in the response to query id, or name itself if the
+// response has none. Records for other names, such as the rest of the chain some servers add, are ignored.
+func cnameTarget(response []byte, id uint16, name dnsmessage.Name) (string, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return "", err
+	}
+	if header.ID != id || !header.Response {
+		return "", errors.New("unexpected DNS response")
+	}
+	if header.RCode != dnsmessage.RCodeSuccess {
+		return "", fmt.Errorf("DNS query failed: %v", header.RCode)
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return "", err
+	}
+
+	for {
+		answer, err := parser.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			return name.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if answer.Type != dnsmessage.TypeCNAME || !strings.EqualFold(answer.Name.String(), name.String()) {
+			if err := parser.SkipAnswer(); err != nil {
+				return "", err
+			}
+			continue
+		}
+		cname, err := parser.CNAMEResource()
+		if err != nil {
+			return "", err
+		}
+		return cname.CNAME.String(), nil
+	}
+}
+
+// fqdn returns host as a fully qualified domain name, ending with a dot
+func fqdn(host string) string {
+	if strings.HasSuffix(host, ".") {
+		return host
+	}
+	return host + "."
+}
@@ -0,0 +1,138 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeNameServer answers CNAME queries over UDP with a chain of CNAME records, as some recursive servers do
+type fakeNameServer struct {
+	conn  net.PacketConn
+	mu    sync.Mutex
+	chain map[string]string
+}
+
+func newFakeNameServer(t *testing.T, chain map[string]string) *fakeNameServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeNameServer{conn: conn, chain: chain}
+	go s.serve()
+	return s
+}
+
+func (s *fakeNameServer) set(name, target string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chain[name] = target
+}
+
+func (s *fakeNameServer) serve() {
+	buf := make([]byte, dnsUDPSize)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+			continue
+		}
+		response := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+			Questions: query.Questions,
+		}
+		s.mu.Lock()
+		for name := query.Questions[0].Name.String(); s.chain[name] != ""; name = s.chain[name] {
+			response.Answers = append(response.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(s.chain[name])},
+			})
+		}
+		s.mu.Unlock()
+		if len(response.Answers) == 0 {
+			response.RCode = dnsmessage.RCodeNameError
+		}
+		packed, err := response.Pack()
+		if err != nil {
+			continue
+		}
+		_, _ = s.conn.WriteTo(packed, addr)
+	}
+}
+
+func TestAliasResolver_ResolvesTheFirstHop(t *testing.T) {
+	server := newFakeNameServer(t, map[string]string{
+		"alias.servicebus.windows.net.":   "primary.servicebus.windows.net.",
+		"primary.servicebus.windows.net.": "ns-sb2-prod-1.cloudapp.net.",
+	})
+	defer server.conn.Close()
+	r := &aliasResolver{servers: []string{server.conn.LocalAddr().String()}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	host, err := r.resolve(ctx, "alias.servicebus.windows.net")
+	require.NoError(t, err)
+	assert.Equal(t, "primary.servicebus.windows.net.", host)
+
+	host, err = r.resolve(ctx, "ns-sb2-prod-1.cloudapp.net")
+	require.Error(t, err, "names which don't exist should fail to resolve")
+	assert.Empty(t, host)
+	var dnsErr *net.DNSError
+	assert.True(t, errors.As(err, &dnsErr))
+}
+
+func TestGeoDR_ClusterChangesAreNotFailovers(t *testing.T) {
+	server := newFakeNameServer(t, map[string]string{
+		"alias.servicebus.windows.net.":   "primary.servicebus.windows.net.",
+		"primary.servicebus.windows.net.": "ns-sb2-prod-1.cloudapp.net.",
+	})
+	defer server.conn.Close()
+
+	var events []FailoverEvent
+	h, closed := newGeoDRHub(t, nil, func(event FailoverEvent) {
+		events = append(events, event)
+	})
+	g := h.namespace.geoDR
+	g.resolve = (&aliasResolver{servers: []string{server.conn.LocalAddr().String()}}).resolve
+
+	ctx := context.Background()
+	g.check(ctx)
+	server.set("primary.servicebus.windows.net.", "ns-sb2-prod-2.cloudapp.net.")
+	g.check(ctx)
+	assert.Empty(t, events, "moving the namespace to another cluster is not a failover")
+	assert.Empty(t, closed)
+
+	server.set("alias.servicebus.windows.net.", "secondary.servicebus.windows.net.")
+	g.check(ctx)
+	require.Len(t, events, 1)
+	assert.Equal(t, "primary.servicebus.windows.net", events[0].PreviousHost)
+	assert.Equal(t, "secondary.servicebus.windows.net", events[0].Host)
+}
+
+func TestReadNameServers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resolv")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "resolv.conf")
+	require.NoError(t, ioutil.WriteFile(path, []byte(strings.Join([]string{
+		"# generated",
+		"search example.com",
+		"nameserver 10.0.0.2",
+		"nameserver fe80::1%eth0",
+		"nameserver not-an-address",
+	}, "\n")), 0600))
+	assert.Equal(t, []string{"10.0.0.2:53", "[fe80::1%eth0]:53"}, readNameServers(path))
+	assert.Nil(t, readNameServers(path+".missing"))
+}
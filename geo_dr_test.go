@@ -0,0 +1,154 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAlias struct {
+	mu      sync.Mutex
+	host    string
+	lookups int
+}
+
+func (a *fakeAlias) resolve(_ context.Context, host string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lookups++
+	if a.host == "" {
+		return "", &net.DNSError{Err: "no such host", Name: host}
+	}
+	return a.host + ".", nil
+}
+
+func (a *fakeAlias) pointTo(host string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.host = host
+}
+
+func newGeoDRHub(t *testing.T, alias *fakeAlias, listener FailoverListener) (*Hub, map[*amqp.Client]bool) {
+	pool, closed := newTestConnectionPool(t, 1)
+	h, err := NewHub("alias", "hub", nil, HubWithConnectionPool(pool), HubWithGeoDRFailover(time.Hour, listener))
+	require.NoError(t, err)
+	h.namespace.geoDR.resolve = alias.resolve
+	return h, closed
+}
+
+func TestHubWithGeoDRFailover(t *testing.T) {
+	h, err := NewHub("alias", "hub", nil)
+	require.NoError(t, err)
+	assert.Error(t, HubWithGeoDRFailover(0, func(FailoverEvent) {})(h))
+	assert.Error(t, HubWithGeoDRFailover(time.Minute, nil)(h))
+	require.NoError(t, HubWithGeoDRFailover(time.Minute, func(FailoverEvent) {})(h))
+	assert.Equal(t, "alias.servicebus.windows.net", h.namespace.geoDR.alias)
+}
+
+func TestGeoDR_FailoverSeenInDNS(t *testing.T) {
+	alias := &fakeAlias{host: "primary.cloudapp.net"}
+	var events []FailoverEvent
+	h, closed := newGeoDRHub(t, alias, func(event FailoverEvent) {
+		events = append(events, event)
+	})
+	g := h.namespace.geoDR
+
+	conn, err := h.namespace.pool.acquire("receiver", h.namespace.poolKey(), func() (*amqp.Client, error) { return new(amqp.Client), nil })
+	require.NoError(t, err)
+
+	g.check(context.Background())
+	assert.Empty(t, events, "the first look up only learns where the alias points")
+
+	alias.pointTo("")
+	g.check(context.Background())
+	assert.Empty(t, events, "a failed look up is not a failover")
+
+	alias.pointTo("secondary.cloudapp.net")
+	g.check(context.Background())
+	g.check(context.Background())
+	require.Len(t, events, 1)
+	assert.Equal(t, FailoverEvent{
+		Alias:        "alias.servicebus.windows.net",
+		PreviousHost: "primary.cloudapp.net",
+		Host:         "secondary.cloudapp.net",
+	}, events[0])
+	assert.True(t, closed[conn], "connections to the previous primary are closed")
+
+	next, err := h.namespace.pool.acquire("receiver", h.namespace.poolKey(), func() (*amqp.Client, error) { return new(amqp.Client), nil })
+	require.NoError(t, err)
+	assert.True(t, next != conn, "new links dial a new connection")
+}
+
+func TestGeoDR_FollowsRedirects(t *testing.T) {
+	alias := &fakeAlias{host: "primary.cloudapp.net"}
+	var events []FailoverEvent
+	h, _ := newGeoDRHub(t, alias, func(event FailoverEvent) {
+		events = append(events, event)
+	})
+	g := h.namespace.geoDR
+	g.check(context.Background())
+	assert.False(t, h.namespace.hasCustomEndpoint())
+
+	redirect := &amqp.DetachError{RemoteError: &amqp.Error{
+		Condition: amqp.ErrorLinkRedirect,
+		Info: map[string]interface{}{
+			"hostname":     "secondary.servicebus.windows.net",
+			"network-host": "10.1.2.3",
+			"port":         uint32(5671),
+		},
+	}}
+	h.detectFailover(context.Background(), redirect)
+	h.detectFailover(context.Background(), redirect)
+	require.Len(t, events, 1, "links redirected to the same host report one failover")
+	assert.Equal(t, "primary.cloudapp.net", events[0].PreviousHost)
+	assert.Equal(t, "secondary.servicebus.windows.net", events[0].Host)
+	assert.True(t, errors.Is(events[0].Cause, redirect))
+
+	assert.True(t, h.namespace.hasCustomEndpoint())
+	assert.Equal(t, "10.1.2.3:5671", h.namespace.endpointAddress("alias.servicebus.windows.net", amqpsPort))
+	assert.Contains(t, h.namespace.poolKey(), "redirect=10.1.2.3:5671")
+}
+
+func TestGeoDR_LinkFailuresLookUpTheAlias(t *testing.T) {
+	alias := &fakeAlias{host: "primary.cloudapp.net"}
+	var events []FailoverEvent
+	h, _ := newGeoDRHub(t, alias, func(event FailoverEvent) {
+		events = append(events, event)
+	})
+
+	h.detectFailover(context.Background(), amqp.ErrConnClosed)
+	assert.Equal(t, 1, alias.lookups)
+	h.detectFailover(context.Background(), amqp.ErrConnClosed)
+	assert.Equal(t, 1, alias.lookups, "look ups are rate limited when many links fail at once")
+
+	alias.pointTo("secondary.cloudapp.net")
+	h.namespace.geoDR.lastCheck = time.Time{}
+	h.detectFailover(context.Background(), amqp.ErrConnClosed)
+	require.Len(t, events, 1)
+	assert.Equal(t, "secondary.cloudapp.net", events[0].Host)
+}
+
+func TestGeoDR_StopsWithHub(t *testing.T) {
+	alias := &fakeAlias{host: "primary.cloudapp.net"}
+	h, _ := newGeoDRHub(t, alias, func(FailoverEvent) {})
+	g := h.namespace.geoDR
+
+	g.start()
+	assert.Eventually(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.resolved == "primary.cloudapp.net"
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, h.Close(context.Background()))
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	assert.Nil(t, g.cancel)
+}
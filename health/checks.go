@@ -0,0 +1,116 @@
+package health
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/eph"
+)
+
+type (
+	// ConnectionTracker follows the connection lifecycle events of a Hub and reports whether any of its senders or
+	// receivers has given up recovering. Hand its Observe method to eventhub.HubWithConnectionListener.
+	ConnectionTracker struct {
+		mu     sync.Mutex
+		failed map[string]error
+	}
+)
+
+// HubReachable checks that the management node of the Event Hub answers a runtime information request
+func HubReachable(hub *eventhub.Hub) Check {
+	return func(ctx context.Context) error {
+		if _, err := hub.GetRuntimeInformation(ctx); err != nil {
+			return fmt.Errorf("event hub unreachable: %w", err)
+		}
+		return nil
+	}
+}
+
+// LeaseStoreReachable checks that the leases of an EventProcessorHost can be read from the lease store
+func LeaseStoreReachable(leaser eph.Leaser) Check {
+	return func(ctx context.Context) error {
+		if _, err := leaser.GetLeases(ctx); err != nil {
+			return fmt.Errorf("lease store unreachable: %w", err)
+		}
+		return nil
+	}
+}
+
+// PartitionsProcessed checks that the EventProcessorHost is pumping events from at least minimum partitions. As
+// partitions are balanced across hosts, minimum should not exceed the number of partitions divided by the number of
+// hosts.
+func PartitionsProcessed(host *eph.EventProcessorHost, minimum int) Check {
+	return func(ctx context.Context) error {
+		if processed := len(host.PartitionIDsBeingProcessed()); processed < minimum {
+			return fmt.Errorf("processing %d partitions; expected at least %d", processed, minimum)
+		}
+		return nil
+	}
+}
+
+// NewConnectionTracker creates a ConnectionTracker with every entity healthy
+func NewConnectionTracker() *ConnectionTracker {
+	return &ConnectionTracker{failed: make(map[string]error)}
+}
+
+// Observe records a connection lifecycle event. An entity fails when it gives up recovering and heals when a link
+// to it is attached again.
+func (t *ConnectionTracker) Observe(event eventhub.ConnectionEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch event.Type {
+	case eventhub.ConnectionEventFailed:
+		err := event.Err
+		if err == nil {
+			err = errors.New("recovery failed")
+		}
+		t.failed[event.Entity] = err
+	case eventhub.ConnectionEventLinkAttached:
+		delete(t.failed, event.Entity)
+	}
+}
+
+// Check fails while any entity has given up recovering
+func (t *ConnectionTracker) Check(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.failed) == 0 {
+		return nil
+	}
+
+	failures := make([]string, 0, len(t.failed))
+	for entity, err := range t.failed {
+		failures = append(failures, fmt.Sprintf("%s: %v", entity, err))
+	}
+	sort.Strings(failures)
+	return fmt.Errorf("links failed: %s", strings.Join(failures, "; "))
+}
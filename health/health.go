@@ -0,0 +1,213 @@
+// Package health aggregates the state of Event Hubs clients into readiness and liveness results for HTTP probes.
+//
+// A Checker runs named checks, concurrently and each bounded by a timeout, and serves their results as JSON:
+//
+//	checker := health.NewChecker()
+//	checker.AddReadiness("hub", health.HubReachable(hub))
+//	checker.AddReadiness("leases", health.LeaseStoreReachable(leaser))
+//	checker.AddLiveness("connections", tracker.Check)
+//	http.Handle("/readyz", checker.ReadyHandler())
+//	http.Handle("/livez", checker.LiveHandler())
+package health
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds how long a single check may run
+const DefaultTimeout = 5 * time.Second
+
+type (
+	// Check reports whether a dependency is healthy, returning the reason when it is not
+	Check func(ctx context.Context) error
+
+	// Checker runs readiness and liveness checks. Readiness checks report whether the application can do useful work,
+	// such as reaching the Event Hub and the lease store; liveness checks report whether it is stuck and should be
+	// restarted. It is safe for concurrent use.
+	Checker struct {
+		timeout time.Duration
+
+		mu        sync.RWMutex
+		readiness map[string]Check
+		liveness  map[string]Check
+	}
+
+	// CheckerOption provides structure for configuring a Checker
+	CheckerOption func(c *Checker)
+
+	// Report is the outcome of running a set of checks
+	Report struct {
+		// Healthy is true when every check passed
+		Healthy bool `json:"healthy"`
+		// Checks holds the result of each check, ordered by name
+		Checks []Result `json:"checks"`
+	}
+
+	// Result is the outcome of a single check
+	Result struct {
+		Name     string        `json:"name"`
+		Healthy  bool          `json:"healthy"`
+		Error    string        `json:"error,omitempty"`
+		Duration time.Duration `json:"duration"`
+	}
+)
+
+// WithTimeout configures how long each check may run before it is reported as failed
+func WithTimeout(timeout time.Duration) CheckerOption {
+	return func(c *Checker) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
+	}
+}
+
+// NewChecker creates a Checker without any checks
+func NewChecker(opts ...CheckerOption) *Checker {
+	c := &Checker{
+		timeout:   DefaultTimeout,
+		readiness: make(map[string]Check),
+		liveness:  make(map[string]Check),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// AddReadiness registers a readiness check under name, replacing any check of the same name
+func (c *Checker) AddReadiness(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readiness[name] = check
+}
+
+// AddLiveness registers a liveness check under name, replacing any check of the same name
+func (c *Checker) AddLiveness(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.liveness[name] = check
+}
+
+// Ready runs the readiness checks
+func (c *Checker) Ready(ctx context.Context) Report {
+	return c.run(ctx, c.snapshot(c.readiness))
+}
+
+// Live runs the liveness checks
+func (c *Checker) Live(ctx context.Context) Report {
+	return c.run(ctx, c.snapshot(c.liveness))
+}
+
+// ReadyHandler serves the readiness report as JSON, with status 200 when ready and 503 otherwise
+func (c *Checker) ReadyHandler() http.Handler {
+	return reportHandler(c.Ready)
+}
+
+// LiveHandler serves the liveness report as JSON, with status 200 when live and 503 otherwise
+func (c *Checker) LiveHandler() http.Handler {
+	return reportHandler(c.Live)
+}
+
+func (c *Checker) snapshot(checks map[string]Check) map[string]Check {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	copied := make(map[string]Check, len(checks))
+	for name, check := range checks {
+		copied[name] = check
+	}
+	return copied
+}
+
+// run executes checks concurrently, each bounded by the checker's timeout
+func (c *Checker) run(ctx context.Context, checks map[string]Check) Report {
+	results := make([]Result, 0, len(checks))
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := c.runOne(ctx, name, check)
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	report := Report{Healthy: true, Checks: results}
+	for _, result := range results {
+		report.Healthy = report.Healthy && result.Healthy
+	}
+	return report
+}
+
+func (c *Checker) runOne(ctx context.Context, name string, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		// a check which ignores its context must not hold up the probe
+		err = ctx.Err()
+	}
+
+	result := Result{Name: name, Healthy: err == nil, Duration: time.Since(start)}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func reportHandler(run func(ctx context.Context) Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := run(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		if report.Healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,80 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+func TestChecker(t *testing.T) {
+	checker := NewChecker(WithTimeout(20 * time.Millisecond))
+	assert.True(t, checker.Ready(context.Background()).Healthy, "no checks is healthy")
+
+	checker.AddReadiness("ok", func(ctx context.Context) error { return nil })
+	checker.AddReadiness("broken", func(ctx context.Context) error { return errors.New("unreachable") })
+	checker.AddReadiness("stuck", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	checker.AddLiveness("ok", func(ctx context.Context) error { return nil })
+
+	report := checker.Ready(context.Background())
+	assert.False(t, report.Healthy)
+	require.Len(t, report.Checks, 3)
+	assert.Equal(t, Result{Name: "broken", Error: "unreachable"}, withoutDuration(report.Checks[0]))
+	assert.Equal(t, Result{Name: "ok", Healthy: true}, withoutDuration(report.Checks[1]))
+	assert.Equal(t, Result{Name: "stuck", Error: context.DeadlineExceeded.Error()}, withoutDuration(report.Checks[2]))
+
+	assert.True(t, checker.Live(context.Background()).Healthy)
+}
+
+func TestChecker_Handlers(t *testing.T) {
+	checker := NewChecker()
+	checker.AddReadiness("broken", func(ctx context.Context) error { return errors.New("unreachable") })
+
+	rec := httptest.NewRecorder()
+	checker.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var report Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.False(t, report.Healthy)
+	assert.Equal(t, "unreachable", report.Checks[0].Error)
+
+	rec = httptest.NewRecorder()
+	checker.LiveHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestConnectionTracker(t *testing.T) {
+	tracker := NewConnectionTracker()
+	assert.NoError(t, tracker.Check(context.Background()))
+
+	tracker.Observe(eventhub.ConnectionEvent{Type: eventhub.ConnectionEventReconnecting, Entity: "hub/Partitions/0"})
+	assert.NoError(t, tracker.Check(context.Background()), "recovering links are still live")
+
+	tracker.Observe(eventhub.ConnectionEvent{Type: eventhub.ConnectionEventFailed, Entity: "hub/Partitions/0", Err: errors.New("gone")})
+	tracker.Observe(eventhub.ConnectionEvent{Type: eventhub.ConnectionEventFailed, Entity: "hub/Partitions/1"})
+	err := tracker.Check(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, "links failed: hub/Partitions/0: gone; hub/Partitions/1: recovery failed", err.Error())
+
+	tracker.Observe(eventhub.ConnectionEvent{Type: eventhub.ConnectionEventLinkAttached, Entity: "hub/Partitions/0"})
+	tracker.Observe(eventhub.ConnectionEvent{Type: eventhub.ConnectionEventLinkAttached, Entity: "hub/Partitions/1"})
+	assert.NoError(t, tracker.Check(context.Background()))
+}
+
+func withoutDuration(result Result) Result {
+	result.Duration = 0
+	return result
+}
@@ -0,0 +1,468 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/devigned/tab"
+)
+
+const (
+	// defaultHotSpotSamples is the number of intervals the period of an analysis is sampled in by default
+	defaultHotSpotSamples = 5
+	// defaultHotSpotThreshold is the factor of the mean rate a partition must exceed to be reported as hot by default
+	defaultHotSpotThreshold = 2
+)
+
+type (
+	// PartitionKeyStats counts the events sent with each partition key, so AnalyzeHotSpots can tell which keys make
+	// partitions hot. A Hub configured with HubWithPartitionKeyStats records its sends; other producers may call
+	// Observe themselves. At most maxKeys keys are tracked; the events of keys seen after that are only counted in
+	// total.
+	PartitionKeyStats struct {
+		maxKeys int
+
+		mu        sync.Mutex
+		keys      map[string]*PartitionKeyCount
+		untracked int64
+	}
+
+	// PartitionKeyCount is the number of events and bytes sent with a partition key
+	PartitionKeyCount struct {
+		Key string
+		// PartitionID is the partition the events of the key were last sent to, or empty if the service assigned it
+		PartitionID string
+		Events      int64
+		Bytes       int64
+	}
+
+	// PartitionRate is the rate events were enqueued on a partition over the period of a hot spot analysis
+	PartitionRate struct {
+		PartitionID string
+		// Events is the number of events enqueued over the period
+		Events          int64
+		EventsPerSecond float64
+		// PeakEventsPerSecond is the rate of the busiest sampling interval
+		PeakEventsPerSecond float64
+	}
+
+	// HotSpotReport describes how evenly events were spread across the partitions of a hub over a period
+	HotSpotReport struct {
+		Start      time.Time
+		End        time.Time
+		Partitions []PartitionRate
+		// MeanEventsPerSecond is the mean rate of the partitions
+		MeanEventsPerSecond float64
+		// Skew is the rate of the busiest partition over the mean rate: 1 when events are spread evenly, the number of
+		// partitions when a single partition receives them all, and 0 when none were enqueued
+		Skew float64
+		// HotPartitions are the IDs of the partitions whose rate exceeds the threshold of the analysis
+		HotPartitions []string
+		// HotKeys are the partition keys sent more than the mean rate of a partition over the period, on a hot
+		// partition if their partition is known, busiest first. Their counts cover the period of the analysis.
+		HotKeys []PartitionKeyCount
+		// UntrackedKeyEvents is the number of events sent over the period with keys beyond the capacity of the key
+		// statistics
+		UntrackedKeyEvents int64
+	}
+
+	// HotSpotOption configures AnalyzeHotSpots
+	HotSpotOption func(a *hotSpotAnalysis) error
+
+	hotSpotAnalysis struct {
+		interval  time.Duration
+		threshold float64
+		keyStats  *PartitionKeyStats
+	}
+
+	// hotSpotSample is the last sequence number of each partition at a point in time
+	hotSpotSample struct {
+		at   time.Time
+		last map[string]int64
+	}
+)
+
+// NewPartitionKeyStats creates statistics tracking the events sent with up to maxKeys partition keys
+func NewPartitionKeyStats(maxKeys int) (*PartitionKeyStats, error) {
+	if maxKeys <= 0 {
+		return nil, fmt.Errorf("partition key statistics must track at least one key, got %d", maxKeys)
+	}
+	return &PartitionKeyStats{maxKeys: maxKeys, keys: make(map[string]*PartitionKeyCount)}, nil
+}
+
+// Observe counts events and bytes sent with partitionKey to partitionID, which is empty if the service assigns the
+// partition
+func (s *PartitionKeyStats) Observe(partitionKey, partitionID string, events, bytes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count, ok := s.keys[partitionKey]
+	if !ok {
+		if len(s.keys) >= s.maxKeys {
+			s.untracked += int64(events)
+			return
+		}
+		count = &PartitionKeyCount{Key: partitionKey}
+		s.keys[partitionKey] = count
+	}
+	if partitionID != "" {
+		count.PartitionID = partitionID
+	}
+	count.Events += int64(events)
+	count.Bytes += int64(bytes)
+}
+
+// Counts returns the counts of the keys tracked, busiest first
+func (s *PartitionKeyStats) Counts() []PartitionKeyCount {
+	counts, _ := s.snapshot()
+	sortKeyCounts(counts)
+	return counts
+}
+
+func (s *PartitionKeyStats) snapshot() ([]PartitionKeyCount, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make([]PartitionKeyCount, 0, len(s.keys))
+	for _, count := range s.keys {
+		counts = append(counts, *count)
+	}
+	return counts, s.untracked
+}
+
+// observe records the sends of evt with a partition key. It does nothing if s is nil.
+func (s *PartitionKeyStats) observe(evt eventer, partitionID string) {
+	if s == nil {
+		return
+	}
+
+	var key *string
+	switch e := evt.(type) {
+	case *Event:
+		key = e.PartitionKey
+	case *EventBatch:
+		key = e.PartitionKey
+	}
+	if key == nil {
+		return
+	}
+	events, bytes := eventerSize(evt)
+	s.Observe(*key, partitionID, events, bytes)
+}
+
+// HubWithPartitionKeyStats records the events the Hub sends with a partition key in stats
+func HubWithPartitionKeyStats(stats *PartitionKeyStats) HubOption {
+	return func(h *Hub) error {
+		if stats == nil {
+			return errors.New("partition key statistics must not be nil")
+		}
+		h.keyStats = stats
+		return nil
+	}
+}
+
+// HotSpotWithSampleInterval sets how often the runtime information of the partitions is sampled during the analysis.
+// Each sample makes a management request per partition. The default samples the period 5 times.
+func HotSpotWithSampleInterval(interval time.Duration) HotSpotOption {
+	return func(a *hotSpotAnalysis) error {
+		if interval <= 0 {
+			return fmt.Errorf("hot spot sample interval must be positive, got %v", interval)
+		}
+		a.interval = interval
+		return nil
+	}
+}
+
+// HotSpotWithThreshold sets the factor of the mean rate a partition must exceed to be reported as hot. It defaults
+// to 2.
+func HotSpotWithThreshold(factor float64) HotSpotOption {
+	return func(a *hotSpotAnalysis) error {
+		if factor <= 1 {
+			return fmt.Errorf("hot spot threshold must be greater than 1, got %v", factor)
+		}
+		a.threshold = factor
+		return nil
+	}
+}
+
+// HotSpotWithKeyStats identifies the partition keys making partitions hot from the events counted in stats over the
+// period of the analysis
+func HotSpotWithKeyStats(stats *PartitionKeyStats) HotSpotOption {
+	return func(a *hotSpotAnalysis) error {
+		if stats == nil {
+			return errors.New("partition key statistics must not be nil")
+		}
+		a.keyStats = stats
+		return nil
+	}
+}
+
+// AnalyzeHotSpots samples the runtime information of each partition of the hub over period, and reports the rate
+// events were enqueued on each, how skewed the rates are and which partitions are hot. Given the statistics of the
+// producers' partition keys, it also reports the keys making partitions hot.
+func AnalyzeHotSpots(ctx context.Context, manager Manager, period time.Duration, opts ...HotSpotOption) (*HotSpotReport, error) {
+	ctx, span := tab.StartSpan(ctx, "eh.AnalyzeHotSpots")
+	ApplyComponentInfo(span)
+	defer span.End()
+
+	if period <= 0 {
+		err := fmt.Errorf("hot spot analysis period must be positive, got %v", period)
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	a := &hotSpotAnalysis{interval: period / defaultHotSpotSamples, threshold: defaultHotSpotThreshold}
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+	}
+	if a.interval <= 0 || a.interval > period {
+		a.interval = period
+	}
+
+	hubInfo, err := manager.GetRuntimeInformation(ctx)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+
+	var keysBefore []PartitionKeyCount
+	var untrackedBefore int64
+	if a.keyStats != nil {
+		keysBefore, untrackedBefore = a.keyStats.snapshot()
+	}
+
+	first, err := sampleHotSpots(ctx, manager, hubInfo.PartitionIDs)
+	if err != nil {
+		return nil, err
+	}
+	samples := []hotSpotSample{first}
+	end := first.at.Add(period)
+	for {
+		wait := a.interval
+		if remaining := time.Until(end); remaining < wait {
+			wait = remaining
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				tab.For(ctx).Error(ctx.Err())
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		sample, err := sampleHotSpots(ctx, manager, hubInfo.PartitionIDs)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+		if !sample.at.Before(end) {
+			break
+		}
+	}
+
+	report := newHotSpotReport(hubInfo.PartitionIDs, samples, a.threshold)
+	if a.keyStats != nil {
+		keysAfter, untrackedAfter := a.keyStats.snapshot()
+		report.HotKeys = hotKeys(report, keysBefore, keysAfter)
+		report.UntrackedKeyEvents = untrackedAfter - untrackedBefore
+	}
+	return report, nil
+}
+
+// AnalyzeHotSpots analyzes the partitions of the Hub with AnalyzeHotSpots, identifying hot keys from the statistics of
+// HubWithPartitionKeyStats unless other statistics are given. The partitions are sampled from the management node even
+// if the Hub was created with HubWithRuntimeInformationCache.
+func (h *Hub) AnalyzeHotSpots(ctx context.Context, period time.Duration, opts ...HotSpotOption) (*HotSpotReport, error) {
+	if h.keyStats != nil {
+		opts = append([]HotSpotOption{HotSpotWithKeyStats(h.keyStats)}, opts...)
+	}
+	return AnalyzeHotSpots(ctx, uncachedManager{Hub: h}, period, opts...)
+}
+
+// uncachedManager fetches the partition runtime information of a Hub from the management node even if the Hub caches
+// it, as samples served from the cache would report partitions as idle
+type uncachedManager struct {
+	*Hub
+}
+
+func (m uncachedManager) GetPartitionInformation(ctx context.Context, partitionID string) (*HubPartitionRuntimeInformation, error) {
+	return m.Hub.getPartitionInformation(ctx, partitionID, false)
+}
+
+// sampleHotSpots fetches the runtime information of the partitions concurrently, so that the sequence numbers of a
+// sample are taken as close together as possible, and times the sample halfway through the requests
+func sampleHotSpots(ctx context.Context, manager Manager, partitionIDs []string) (hotSpotSample, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		sample   = hotSpotSample{last: make(map[string]int64, len(partitionIDs))}
+		sem      = make(chan struct{}, partitionInfoConcurrency)
+	)
+
+	start := time.Now()
+	for _, partitionID := range partitionIDs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(partitionID string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			info, err := manager.GetPartitionInformation(ctx, partitionID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to fetch runtime information for partition %q: %w", partitionID, err)
+					cancel()
+				}
+				return
+			}
+			sample.last[partitionID] = info.LastSequenceNumber
+		}(partitionID)
+	}
+	wg.Wait()
+	end := time.Now()
+
+	if firstErr != nil {
+		tab.For(ctx).Error(firstErr)
+		return sample, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		tab.For(ctx).Error(err)
+		return sample, err
+	}
+
+	sample.at = start.Add(end.Sub(start) / 2)
+	return sample, nil
+}
+
+// newHotSpotReport computes the rates of the partitions between the samples. A sequence number going backwards, as
+// when a partition is recreated, counts as no events for the interval.
+func newHotSpotReport(partitionIDs []string, samples []hotSpotSample, threshold float64) *HotSpotReport {
+	first, last := samples[0], samples[len(samples)-1]
+	report := &HotSpotReport{Start: first.at, End: last.at}
+	elapsed := last.at.Sub(first.at).Seconds()
+
+	var total, busiest float64
+	for _, partitionID := range partitionIDs {
+		rate := PartitionRate{PartitionID: partitionID}
+		for i := 1; i < len(samples); i++ {
+			events := samples[i].last[partitionID] - samples[i-1].last[partitionID]
+			if events <= 0 {
+				continue
+			}
+			rate.Events += events
+			if interval := samples[i].at.Sub(samples[i-1].at).Seconds(); interval > 0 {
+				if perSecond := float64(events) / interval; perSecond > rate.PeakEventsPerSecond {
+					rate.PeakEventsPerSecond = perSecond
+				}
+			}
+		}
+		if elapsed > 0 {
+			rate.EventsPerSecond = float64(rate.Events) / elapsed
+		}
+		total += rate.EventsPerSecond
+		if rate.EventsPerSecond > busiest {
+			busiest = rate.EventsPerSecond
+		}
+		report.Partitions = append(report.Partitions, rate)
+	}
+
+	if len(partitionIDs) == 0 || total == 0 {
+		return report
+	}
+	report.MeanEventsPerSecond = total / float64(len(partitionIDs))
+	report.Skew = busiest / report.MeanEventsPerSecond
+	for _, rate := range report.Partitions {
+		if rate.EventsPerSecond > threshold*report.MeanEventsPerSecond {
+			report.HotPartitions = append(report.HotPartitions, rate.PartitionID)
+		}
+	}
+	return report
+}
+
+// hotKeys returns the keys sent more than the mean rate of a partition between the snapshots of their counts, on a
+// hot partition if it is known
+func hotKeys(report *HotSpotReport, before, after []PartitionKeyCount) []PartitionKeyCount {
+	elapsed := report.End.Sub(report.Start).Seconds()
+	if elapsed <= 0 || report.MeanEventsPerSecond == 0 {
+		return nil
+	}
+
+	hot := make(map[string]bool, len(report.HotPartitions))
+	for _, partitionID := range report.HotPartitions {
+		hot[partitionID] = true
+	}
+	previous := make(map[string]PartitionKeyCount, len(before))
+	for _, count := range before {
+		previous[count.Key] = count
+	}
+
+	var keys []PartitionKeyCount
+	for _, count := range after {
+		count.Events -= previous[count.Key].Events
+		count.Bytes -= previous[count.Key].Bytes
+		if float64(count.Events)/elapsed <= report.MeanEventsPerSecond {
+			continue
+		}
+		if count.PartitionID != "" && !hot[count.PartitionID] {
+			continue
+		}
+		keys = append(keys, count)
+	}
+	sortKeyCounts(keys)
+	return keys
+}
+
+func sortKeyCounts(counts []PartitionKeyCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Events != counts[j].Events {
+			return counts[i].Events > counts[j].Events
+		}
+		return counts[i].Key < counts[j].Key
+	})
+}
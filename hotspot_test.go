@@ -0,0 +1,189 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedManager enqueues a fixed number of events on each partition between two samples of its runtime
+// information, and sends the events of keys along with them
+type scriptedManager struct {
+	mu        sync.Mutex
+	perSample map[string]int64
+	last      map[string]int64
+	samples   map[string]int
+	keys      *PartitionKeyStats
+	err       error
+}
+
+func newScriptedManager(perSample map[string]int64, keys *PartitionKeyStats) *scriptedManager {
+	return &scriptedManager{perSample: perSample, last: make(map[string]int64), samples: make(map[string]int), keys: keys}
+}
+
+func (m *scriptedManager) GetRuntimeInformation(context.Context) (*HubRuntimeInformation, error) {
+	return &HubRuntimeInformation{PartitionIDs: []string{"0", "1", "2", "3"}}, nil
+}
+
+func (m *scriptedManager) GetPartitionInformation(_ context.Context, partitionID string) (*HubPartitionRuntimeInformation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.samples[partitionID]++
+	if m.samples[partitionID] > 1 {
+		m.last[partitionID] += m.perSample[partitionID]
+		if m.keys != nil && partitionID == "0" {
+			m.keys.Observe("hot", "0", 9, 90)
+			m.keys.Observe("cold", "0", 1, 10)
+			m.keys.Observe("unassigned", "", 5, 50)
+		}
+	}
+	return &HubPartitionRuntimeInformation{PartitionID: partitionID, LastSequenceNumber: m.last[partitionID]}, nil
+}
+
+// intervals returns the number of intervals sampled
+func (m *scriptedManager) intervals() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(m.samples["0"] - 1)
+}
+
+func TestPartitionKeyStats(t *testing.T) {
+	_, err := NewPartitionKeyStats(0)
+	assert.Error(t, err)
+
+	stats, err := NewPartitionKeyStats(2)
+	require.NoError(t, err)
+	key := "a"
+	event := NewEventFromString("foo")
+	event.PartitionKey = &key
+	stats.observe(event, "")
+	stats.observe(NewEventFromString("no key"), "")
+	stats.Observe("b", "1", 3, 30)
+	stats.Observe("a", "4", 1, 3)
+	stats.Observe("c", "2", 5, 50)
+	(*PartitionKeyStats)(nil).observe(event, "")
+
+	assert.Equal(t, []PartitionKeyCount{
+		{Key: "b", PartitionID: "1", Events: 3, Bytes: 30},
+		{Key: "a", PartitionID: "4", Events: 2, Bytes: 6},
+	}, stats.Counts())
+	_, untracked := stats.snapshot()
+	assert.Equal(t, int64(5), untracked, "keys beyond the capacity should only be counted in total")
+
+	h := new(Hub)
+	assert.Error(t, HubWithPartitionKeyStats(nil)(h))
+	require.NoError(t, HubWithPartitionKeyStats(stats)(h))
+	assert.Equal(t, stats, h.keyStats)
+}
+
+func TestAnalyzeHotSpots(t *testing.T) {
+	ctx := context.Background()
+	keys, err := NewPartitionKeyStats(10)
+	require.NoError(t, err)
+	keys.Observe("hot", "0", 1000, 10000)
+	manager := newScriptedManager(map[string]int64{"0": 10, "1": 1, "2": 1}, keys)
+
+	report, err := AnalyzeHotSpots(ctx, manager, 50*time.Millisecond,
+		HotSpotWithSampleInterval(10*time.Millisecond),
+		HotSpotWithKeyStats(keys))
+	require.NoError(t, err)
+
+	intervals := manager.intervals()
+	require.True(t, intervals >= 5, "the period should be sampled every interval, got %d intervals", intervals)
+	require.Len(t, report.Partitions, 4)
+	for i, expected := range []int64{10, 1, 1, 0} {
+		assert.Equal(t, expected*intervals, report.Partitions[i].Events)
+	}
+	assert.True(t, report.Partitions[0].PeakEventsPerSecond >= report.Partitions[0].EventsPerSecond)
+	assert.True(t, report.End.Sub(report.Start) >= 50*time.Millisecond)
+	assert.InDelta(t, 10.0/3, report.Skew, 0.001)
+	assert.Equal(t, []string{"0"}, report.HotPartitions)
+	assert.Equal(t, []PartitionKeyCount{
+		{Key: "hot", PartitionID: "0", Events: 9 * intervals, Bytes: 90 * intervals},
+		{Key: "unassigned", Events: 5 * intervals, Bytes: 50 * intervals},
+	}, report.HotKeys, "keys on hot partitions, or whose partition is unknown, sending more than the mean rate are hot")
+}
+
+func TestAnalyzeHotSpots_EvenSpread(t *testing.T) {
+	manager := newScriptedManager(map[string]int64{"0": 5, "1": 5, "2": 5, "3": 5}, nil)
+	report, err := AnalyzeHotSpots(context.Background(), manager, 20*time.Millisecond)
+	require.NoError(t, err)
+	assert.InDelta(t, 1, report.Skew, 0.001)
+	assert.Empty(t, report.HotPartitions)
+	assert.Empty(t, report.HotKeys)
+
+	report, err = AnalyzeHotSpots(context.Background(), newScriptedManager(nil, nil), 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Zero(t, report.Skew, "no skew is reported for a hub without events")
+}
+
+func TestAnalyzeHotSpots_Errors(t *testing.T) {
+	ctx := context.Background()
+	manager := newScriptedManager(nil, nil)
+	_, err := AnalyzeHotSpots(ctx, manager, 0)
+	assert.Error(t, err)
+	_, err = AnalyzeHotSpots(ctx, manager, time.Second, HotSpotWithSampleInterval(0))
+	assert.Error(t, err)
+	_, err = AnalyzeHotSpots(ctx, manager, time.Second, HotSpotWithThreshold(1))
+	assert.Error(t, err)
+	_, err = AnalyzeHotSpots(ctx, manager, time.Second, HotSpotWithKeyStats(nil))
+	assert.Error(t, err)
+
+	cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = AnalyzeHotSpots(cancelled, manager, time.Hour)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	manager.err = errors.New("management node unavailable")
+	_, err = AnalyzeHotSpots(ctx, manager, time.Second)
+	assert.True(t, errors.Is(err, manager.err))
+}
+
+// barrierManager answers the requests for partition runtime information only once all of them are in flight
+type barrierManager struct {
+	partitionIDs []string
+	arrived      sync.WaitGroup
+}
+
+func (m *barrierManager) GetRuntimeInformation(context.Context) (*HubRuntimeInformation, error) {
+	return &HubRuntimeInformation{PartitionIDs: m.partitionIDs}, nil
+}
+
+func (m *barrierManager) GetPartitionInformation(ctx context.Context, partitionID string) (*HubPartitionRuntimeInformation, error) {
+	m.arrived.Done()
+	done := make(chan struct{})
+	go func() {
+		m.arrived.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return &HubPartitionRuntimeInformation{PartitionID: partitionID, LastSequenceNumber: 7}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestSampleHotSpots_FetchesPartitionsConcurrently(t *testing.T) {
+	manager := &barrierManager{partitionIDs: []string{"0", "1", "2", "3"}}
+	manager.arrived.Add(len(manager.partitionIDs))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	before := time.Now()
+	sample, err := sampleHotSpots(ctx, manager, manager.partitionIDs)
+	after := time.Now()
+	require.NoError(t, err, "the partitions should be requested together rather than one after the other")
+
+	assert.Equal(t, map[string]int64{"0": 7, "1": 7, "2": 7, "3": 7}, sample.last)
+	assert.False(t, sample.at.Before(before))
+	assert.False(t, sample.at.After(after))
+}
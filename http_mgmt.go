@@ -111,6 +111,7 @@ func (em *entityManager) Post(ctx context.Context, entityPath string, body []byt
 func (em *entityManager) Execute(ctx context.Context, method string, entityPath string, body io.Reader) (*http.Response, error) {
 	span, ctx := em.startSpanFromContext(ctx, "sb.EntityManger.Execute")
 	defer span.End()
+	ctx, requestID := StartRequest(ctx, span)
 
 	client := &http.Client{
 		Timeout: 60 * time.Second,
@@ -118,16 +119,17 @@ func (em *entityManager) Execute(ctx context.Context, method string, entityPath
 	req, err := http.NewRequest(method, em.Host+strings.TrimPrefix(entityPath, "/"), body)
 	if err != nil {
 		tab.For(ctx).Error(err)
-		return nil, err
+		return nil, NewRequestError(requestID, err)
 	}
 
 	req = addAtomXMLContentType(req)
 	req = addAPIVersion201704(req)
+	req.Header.Set(requestIDKey, requestID)
 	applyRequestInfo(span, req)
 	req, err = em.addAuthorization(req)
 	if err != nil {
 		tab.For(ctx).Error(err)
-		return nil, err
+		return nil, NewRequestError(requestID, err)
 	}
 
 	req = req.WithContext(ctx)
@@ -141,7 +143,7 @@ func (em *entityManager) Execute(ctx context.Context, method string, entityPath
 		applyResponseInfo(span, res)
 	}
 
-	return res, err
+	return res, NewRequestError(requestID, err)
 }
 
 func (em *entityManager) addAuthorization(req *http.Request) (*http.Request, error) {
@@ -0,0 +1,69 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"sync/atomic"
+)
+
+type (
+	// HubConfig holds the settings of a Hub which can be changed while it runs, with SetConfig. Zero values leave a
+	// setting unchanged.
+	HubConfig struct {
+		// PrefetchCount is the number of events receivers fetch ahead of their handler, for receivers which don't set
+		// their own with ReceiveWithPrefetchCount. Receivers created afterwards start with it, and running receivers
+		// use it for the links they attach as they recover, so they keep their position in the partition and, with an
+		// epoch, their ownership of it.
+		PrefetchCount uint32
+	}
+)
+
+// SetConfig changes the settings of the Hub while it runs
+func (h *Hub) SetConfig(config HubConfig) error {
+	if config.PrefetchCount > 0 {
+		atomic.StoreUint32(&h.livePrefetch, config.PrefetchCount)
+	}
+	return nil
+}
+
+// Config returns the settings of the Hub which can be changed while it runs, as they currently are
+func (h *Hub) Config() HubConfig {
+	prefetch := atomic.LoadUint32(&h.livePrefetch)
+	if prefetch == 0 {
+		prefetch = defaultPrefetchCount
+		if h.preset != nil {
+			prefetch = h.preset.prefetchCount
+		}
+	}
+	return HubConfig{PrefetchCount: prefetch}
+}
+
+// refreshPrefetch picks up the prefetch count set with Hub.SetConfig, unless the receiver was configured with its own
+func (r *receiver) refreshPrefetch() {
+	if r.prefetchSet {
+		return
+	}
+	if prefetch := atomic.LoadUint32(&r.hub.livePrefetch); prefetch > 0 {
+		atomic.StoreUint32(&r.prefetchCount, prefetch)
+	}
+}
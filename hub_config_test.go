@@ -0,0 +1,40 @@
+package eventhub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_Config(t *testing.T) {
+	h := new(Hub)
+	assert.Equal(t, HubConfig{PrefetchCount: defaultPrefetchCount}, h.Config())
+
+	require.NoError(t, HubWithPerformanceProfile(ProfileLowLatency)(h))
+	assert.Equal(t, HubConfig{PrefetchCount: 100}, h.Config())
+
+	require.NoError(t, h.SetConfig(HubConfig{PrefetchCount: 50}))
+	assert.Equal(t, HubConfig{PrefetchCount: 50}, h.Config())
+	require.NoError(t, h.SetConfig(HubConfig{}))
+	assert.Equal(t, HubConfig{PrefetchCount: 50}, h.Config(), "zero values should leave the settings unchanged")
+}
+
+func TestReceiver_RefreshPrefetch(t *testing.T) {
+	h := new(Hub)
+	r := &receiver{hub: h, prefetchCount: defaultPrefetchCount}
+	pinned := &receiver{hub: h, prefetchCount: defaultPrefetchCount}
+	require.NoError(t, ReceiveWithPrefetchCount(10)(pinned))
+
+	r.refreshPrefetch()
+	assert.Equal(t, uint32(defaultPrefetchCount), r.linkCredit())
+
+	require.NoError(t, h.SetConfig(HubConfig{PrefetchCount: 50}))
+	// running receivers pick the new prefetch count up as they attach their next link
+	assert.Equal(t, uint32(defaultPrefetchCount), r.linkCredit())
+	r.refreshPrefetch()
+	pinned.refreshPrefetch()
+	assert.Equal(t, uint32(50), r.linkCredit())
+	assert.Equal(t, uint32(50), r.initialCredit())
+	assert.Equal(t, uint32(10), pinned.linkCredit(), "a prefetch count set on the receiver should take precedence")
+}
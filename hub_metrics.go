@@ -0,0 +1,302 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp"
+
+	"github.com/Azure/azure-event-hubs-go/v3/metrics"
+)
+
+// freshnessBuckets are the upper bounds, in seconds, of the enqueue-to-process histogram, which reach further than
+// metrics.DefaultBuckets as a consumer catching up may be minutes or hours behind
+var freshnessBuckets = []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600}
+
+type (
+	// hubMetrics records the send, receive and recovery metrics of a Hub. A nil *hubMetrics records nothing.
+	hubMetrics struct {
+		hub *Hub
+
+		sentEvents     *metrics.CounterVec
+		sentBytes      *metrics.CounterVec
+		sendErrors     *metrics.CounterVec
+		sendRetries    *metrics.CounterVec
+		sendDuration   *metrics.HistogramVec
+		sendAttempts   *metrics.HistogramVec
+		receivedEvents *metrics.CounterVec
+		receivedBytes  *metrics.CounterVec
+		handlerTime    *metrics.HistogramVec
+		freshness      *metrics.HistogramVec
+		slowHandlers   *metrics.CounterVec
+		sequenceGaps   *metrics.CounterVec
+		missingEvents  *metrics.CounterVec
+		recoveries     *metrics.CounterVec
+		recoveryTime   *metrics.HistogramVec
+		connections    *metrics.CounterVec
+		detaches       *metrics.CounterVec
+		tokenRefreshes *metrics.CounterVec
+
+		recoveringMu sync.Mutex
+		recovering   map[string]time.Time
+	}
+
+	// timedSender observes how long each send over the wrapped link takes to be acknowledged by the broker
+	timedSender struct {
+		amqpSender
+		observe func(elapsed time.Duration)
+	}
+)
+
+// HubWithMetrics records metrics of the Hub's sends, receives, retries and link recoveries in registry, labeled with
+// the namespace and name of the Event Hub. A registry may be shared by any number of Hubs; serve it to Prometheus as
+// an http.Handler.
+//
+// Three latency histograms separate where time is spent: eventhub_send_duration_seconds covers a send call from start
+// to finish, including retries and backoff; eventhub_send_attempt_duration_seconds covers each attempt from transfer
+// to broker acknowledgement, i.e. network and broker time; and eventhub_handler_duration_seconds covers the handler
+// processing each received event. eventhub_enqueue_to_process_seconds measures freshness: the time from the service
+// enqueuing an event to its handler starting, which includes any backlog the consumer is working through.
+//
+// Transport health is recorded by eventhub_connections_opened_total, eventhub_link_detaches_total by entity and AMQP
+// error condition, eventhub_cbs_token_refreshes_total by outcome, and eventhub_link_recovery_duration_seconds, the time
+// from a link failing to its recovery.
+func HubWithMetrics(registry *metrics.Registry) HubOption {
+	return func(h *Hub) error {
+		if registry == nil {
+			return errors.New("metrics registry must not be nil")
+		}
+		h.metrics = newHubMetrics(h, registry)
+		if h.namespace != nil {
+			h.namespace.metrics = h.metrics
+		}
+		return nil
+	}
+}
+
+func newHubMetrics(h *Hub, registry *metrics.Registry) *hubMetrics {
+	return &hubMetrics{
+		hub:            h,
+		sentEvents:     registry.Counter("eventhub_sent_events_total", "Events sent successfully.", "namespace", "hub", "partition"),
+		sentBytes:      registry.Counter("eventhub_sent_bytes_total", "Bytes of event data sent successfully.", "namespace", "hub", "partition"),
+		sendErrors:     registry.Counter("eventhub_send_errors_total", "Sends which failed after exhausting their retries.", "namespace", "hub", "partition"),
+		sendRetries:    registry.Counter("eventhub_send_retries_total", "Send attempts which failed and were retried.", "namespace", "hub", "partition"),
+		sendDuration:   registry.Histogram("eventhub_send_duration_seconds", "Time taken by sends, including retries.", nil, "namespace", "hub", "partition"),
+		sendAttempts:   registry.Histogram("eventhub_send_attempt_duration_seconds", "Time from transferring a send attempt to its acknowledgement by the broker.", nil, "namespace", "hub", "partition"),
+		receivedEvents: registry.Counter("eventhub_received_events_total", "Events delivered to handlers.", "namespace", "hub", "consumer_group", "partition"),
+		receivedBytes:  registry.Counter("eventhub_received_bytes_total", "Bytes of event data delivered to handlers.", "namespace", "hub", "consumer_group", "partition"),
+		handlerTime:    registry.Histogram("eventhub_handler_duration_seconds", "Time taken by handlers to process an event.", nil, "namespace", "hub", "consumer_group", "partition"),
+		freshness:      registry.Histogram("eventhub_enqueue_to_process_seconds", "Time from an event being enqueued to its handler starting.", freshnessBuckets, "namespace", "hub", "consumer_group", "partition"),
+		slowHandlers:   registry.Counter("eventhub_slow_handlers_total", "Handler invocations slower than the slow handler threshold.", "namespace", "hub", "consumer_group", "partition"),
+		sequenceGaps:   registry.Counter("eventhub_sequence_gaps_total", "Gaps in the sequence numbers of the events delivered to handlers.", "namespace", "hub", "consumer_group", "partition"),
+		missingEvents:  registry.Counter("eventhub_missing_events_total", "Events skipped by gaps in the sequence numbers delivered to handlers.", "namespace", "hub", "consumer_group", "partition"),
+		recoveries:     registry.Counter("eventhub_link_recoveries_total", "Link recovery attempts by outcome.", "namespace", "hub", "entity", "outcome"),
+		recoveryTime:   registry.Histogram("eventhub_link_recovery_duration_seconds", "Time from a link failing to its recovery.", nil, "namespace", "hub", "entity"),
+		connections:    registry.Counter("eventhub_connections_opened_total", "AMQP connections opened to the namespace.", "namespace", "hub"),
+		detaches:       registry.Counter("eventhub_link_detaches_total", "Links which failed, by the AMQP error condition reported by the service.", "namespace", "hub", "entity", "condition"),
+		tokenRefreshes: registry.Counter("eventhub_cbs_token_refreshes_total", "Claims negotiated with the CBS node, by outcome.", "namespace", "hub", "outcome"),
+		recovering:     make(map[string]time.Time),
+	}
+}
+
+func (m *hubMetrics) namespaceName() string {
+	if m.hub.namespace == nil {
+		return ""
+	}
+	return m.hub.namespace.name
+}
+
+// observeSend records a send of evt to partition which took elapsed and ended with err
+func (m *hubMetrics) observeSend(partition string, evt eventer, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	ns := m.namespaceName()
+	m.sendDuration.With(ns, m.hub.name, partition).Observe(elapsed.Seconds())
+	if err != nil {
+		m.sendErrors.With(ns, m.hub.name, partition).Inc()
+		return
+	}
+
+	events, bytes := eventerSize(evt)
+	m.sentEvents.With(ns, m.hub.name, partition).Add(float64(events))
+	m.sentBytes.With(ns, m.hub.name, partition).Add(float64(bytes))
+}
+
+func (m *hubMetrics) observeRetry(partition string) {
+	if m == nil {
+		return
+	}
+	m.sendRetries.With(m.namespaceName(), m.hub.name, partition).Inc()
+}
+
+func (m *hubMetrics) observeReceive(consumerGroup, partition string, event *Event) {
+	if m == nil || event == nil {
+		return
+	}
+
+	ns := m.namespaceName()
+	m.receivedEvents.With(ns, m.hub.name, consumerGroup, partition).Inc()
+	m.receivedBytes.With(ns, m.hub.name, consumerGroup, partition).Add(float64(len(event.Data)))
+}
+
+// timeSends wraps the links returned by get so the duration of each send attempt over them is recorded
+func (m *hubMetrics) timeSends(partition string, get getAmqpSender) getAmqpSender {
+	if m == nil {
+		return get
+	}
+
+	histogram := m.sendAttempts.With(m.namespaceName(), m.hub.name, partition)
+	return func() amqpSender {
+		return timedSender{
+			amqpSender: get(),
+			observe:    func(elapsed time.Duration) { histogram.Observe(elapsed.Seconds()) },
+		}
+	}
+}
+
+// observeFreshness records how long ago event was enqueued, as its handler starts
+func (m *hubMetrics) observeFreshness(consumerGroup, partition string, event *Event, now time.Time) {
+	if m == nil || event == nil || event.SystemProperties == nil || event.SystemProperties.EnqueuedTime == nil {
+		return
+	}
+
+	age := now.Sub(*event.SystemProperties.EnqueuedTime)
+	if age < 0 {
+		// the clocks of the client and the service disagree
+		age = 0
+	}
+	m.freshness.With(m.namespaceName(), m.hub.name, consumerGroup, partition).Observe(age.Seconds())
+}
+
+func (m *hubMetrics) observeHandler(consumerGroup, partition string, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.handlerTime.With(m.namespaceName(), m.hub.name, consumerGroup, partition).Observe(elapsed.Seconds())
+}
+
+func (m *hubMetrics) observeSlowHandler(consumerGroup, partition string) {
+	if m == nil {
+		return
+	}
+	m.slowHandlers.With(m.namespaceName(), m.hub.name, consumerGroup, partition).Inc()
+}
+
+func (m *hubMetrics) observeSequenceGap(consumerGroup, partition string, missing int64) {
+	if m == nil {
+		return
+	}
+
+	ns := m.namespaceName()
+	m.sequenceGaps.With(ns, m.hub.name, consumerGroup, partition).Inc()
+	m.missingEvents.With(ns, m.hub.name, consumerGroup, partition).Add(float64(missing))
+}
+
+func (m *hubMetrics) observeRecovery(event RecoveryEvent) {
+	if m == nil {
+		return
+	}
+
+	outcome := "attempt"
+	switch {
+	case event.Recovered:
+		outcome = "recovered"
+	case event.GaveUp:
+		outcome = "gave_up"
+	}
+	m.recoveries.With(m.namespaceName(), m.hub.name, event.Entity, outcome).Inc()
+
+	m.recoveringMu.Lock()
+	defer m.recoveringMu.Unlock()
+	started, ok := m.recovering[event.Entity]
+	switch {
+	case event.Recovered:
+		if ok {
+			m.recoveryTime.With(m.namespaceName(), m.hub.name, event.Entity).Observe(time.Since(started).Seconds())
+		}
+		delete(m.recovering, event.Entity)
+	case event.GaveUp:
+		delete(m.recovering, event.Entity)
+	case !ok:
+		m.recovering[event.Entity] = time.Now()
+	}
+}
+
+// observeConnection records connections opened and links which failed
+func (m *hubMetrics) observeConnection(event ConnectionEvent) {
+	if m == nil {
+		return
+	}
+
+	switch event.Type {
+	case ConnectionEventConnected:
+		m.connections.With(m.namespaceName(), m.hub.name).Inc()
+	case ConnectionEventLinkDetached:
+		if event.Err == nil {
+			// closed by the client
+			return
+		}
+		condition := "none"
+		if remote := remoteAMQPError(event.Err); remote != nil {
+			condition = string(remote.Condition)
+		}
+		m.detaches.With(m.namespaceName(), m.hub.name, event.Entity, condition).Inc()
+	}
+}
+
+// observeTokenRefresh records the outcome of negotiating a claim with the CBS node
+func (m *hubMetrics) observeTokenRefresh(err error) {
+	if m == nil {
+		return
+	}
+
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	m.tokenRefreshes.With(m.namespaceName(), m.hub.name, outcome).Inc()
+}
+
+// eventerSize returns the number of events and bytes of event data in evt
+func eventerSize(evt eventer) (int, int) {
+	switch e := evt.(type) {
+	case *EventBatch:
+		return len(e.marshaledMessages), e.size
+	case *Event:
+		return 1, len(e.Data)
+	}
+	return 1, 0
+}
+
+func (s timedSender) Send(ctx context.Context, msg *amqp.Message) error {
+	start := time.Now()
+	err := s.amqpSender.Send(ctx, msg)
+	s.observe(time.Since(start))
+	return err
+}
@@ -0,0 +1,141 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/metrics"
+)
+
+func TestHubMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	h := &Hub{name: "hub", namespace: &namespace{name: "ns"}}
+	assert.Error(t, HubWithMetrics(nil)(h))
+	require.NoError(t, HubWithMetrics(registry)(h))
+
+	batch := NewEventBatch("id", nil)
+	_, err := batch.Add(NewEventFromString("foo"))
+	require.NoError(t, err)
+	_, err = batch.Add(NewEventFromString("barbaz"))
+	require.NoError(t, err)
+
+	h.metrics.observeSend("0", NewEventFromString("hello"), 100*time.Millisecond, nil)
+	h.metrics.observeSend("0", batch, 200*time.Millisecond, nil)
+	h.metrics.observeSend("0", NewEventFromString("hello"), time.Second, errors.New("failed"))
+	h.metrics.observeRetry("0")
+	h.metrics.observeReceive("$Default", "1", NewEventFromString("hello"))
+	h.metrics.observeRecovery(RecoveryEvent{Entity: "hub/Partitions/1", Attempt: 1})
+	h.metrics.observeRecovery(RecoveryEvent{Entity: "hub/Partitions/1", Recovered: true})
+
+	var sb strings.Builder
+	require.NoError(t, registry.WriteText(&sb))
+	text := sb.String()
+	for _, line := range []string{
+		`eventhub_sent_events_total{namespace="ns",hub="hub",partition="0"} 3`,
+		`eventhub_send_errors_total{namespace="ns",hub="hub",partition="0"} 1`,
+		`eventhub_send_retries_total{namespace="ns",hub="hub",partition="0"} 1`,
+		`eventhub_send_duration_seconds_count{namespace="ns",hub="hub",partition="0"} 3`,
+		`eventhub_received_events_total{namespace="ns",hub="hub",consumer_group="$Default",partition="1"} 1`,
+		`eventhub_received_bytes_total{namespace="ns",hub="hub",consumer_group="$Default",partition="1"} 5`,
+		`eventhub_link_recoveries_total{namespace="ns",hub="hub",entity="hub/Partitions/1",outcome="attempt"} 1`,
+		`eventhub_link_recoveries_total{namespace="ns",hub="hub",entity="hub/Partitions/1",outcome="recovered"} 1`,
+	} {
+		assert.Contains(t, text, line+"\n")
+	}
+
+	var disabled *hubMetrics
+	assert.NotPanics(t, func() {
+		disabled.observeSend("0", NewEventFromString("hello"), time.Second, nil)
+		disabled.observeRecovery(RecoveryEvent{})
+	})
+}
+
+func TestHubMetrics_Latency(t *testing.T) {
+	registry := metrics.NewRegistry()
+	h := &Hub{name: "hub", namespace: &namespace{name: "ns"}}
+	require.NoError(t, HubWithMetrics(registry)(h))
+
+	link := &testAmqpSender{sendErrors: []error{errors.New("busy")}}
+	get := h.metrics.timeSends("0", func() amqpSender { return link })
+	assert.Error(t, get().Send(context.Background(), nil))
+	assert.NoError(t, get().Send(context.Background(), nil))
+	assert.Equal(t, "sender-id", get().LinkName())
+	h.metrics.observeHandler("$Default", "1", 300*time.Millisecond)
+
+	var sb strings.Builder
+	require.NoError(t, registry.WriteText(&sb))
+	assert.Contains(t, sb.String(), `eventhub_send_attempt_duration_seconds_count{namespace="ns",hub="hub",partition="0"} 2`+"\n")
+	assert.Contains(t, sb.String(), `eventhub_handler_duration_seconds_bucket{namespace="ns",hub="hub",consumer_group="$Default",partition="1",le="0.25"} 0`+"\n")
+	assert.Contains(t, sb.String(), `eventhub_handler_duration_seconds_bucket{namespace="ns",hub="hub",consumer_group="$Default",partition="1",le="0.5"} 1`+"\n")
+
+	var disabled *hubMetrics
+	assert.Equal(t, link, disabled.timeSends("0", func() amqpSender { return link })())
+	assert.NotPanics(t, func() { disabled.observeHandler("$Default", "1", time.Second) })
+}
+
+func TestHubMetrics_Transport(t *testing.T) {
+	registry := metrics.NewRegistry()
+	h := &Hub{name: "hub", namespace: &namespace{name: "ns"}}
+	require.NoError(t, HubWithMetrics(registry)(h))
+	require.Equal(t, h.metrics, h.namespace.metrics)
+
+	entity := "hub/Partitions/0"
+	h.namespace.notifyConnection(ConnectionEvent{Type: ConnectionEventConnected})
+	h.namespace.notifyConnection(ConnectionEvent{Type: ConnectionEventLinkDetached, Entity: entity})
+	h.namespace.notifyConnection(ConnectionEvent{Type: ConnectionEventLinkDetached, Entity: entity, Err: &amqp.DetachError{RemoteError: &amqp.Error{Condition: conditionDetachForced}}})
+	h.namespace.notifyConnection(ConnectionEvent{Type: ConnectionEventLinkDetached, Entity: entity, Err: errors.New("connection reset")})
+	h.metrics.observeTokenRefresh(nil)
+	h.metrics.observeTokenRefresh(errors.New("unauthorized"))
+
+	h.metrics.observeRecovery(RecoveryEvent{Entity: entity, Attempt: 1})
+	h.metrics.recovering[entity] = time.Now().Add(-3 * time.Second)
+	h.metrics.observeRecovery(RecoveryEvent{Entity: entity, Attempt: 2})
+	h.metrics.observeRecovery(RecoveryEvent{Entity: entity, Attempt: 2, Recovered: true})
+	h.metrics.observeRecovery(RecoveryEvent{Entity: entity, Attempt: 1})
+	h.metrics.observeRecovery(RecoveryEvent{Entity: entity, Attempt: 1, GaveUp: true})
+	assert.Empty(t, h.metrics.recovering)
+
+	var sb strings.Builder
+	require.NoError(t, registry.WriteText(&sb))
+	text := sb.String()
+	for _, line := range []string{
+		`eventhub_connections_opened_total{namespace="ns",hub="hub"} 1`,
+		`eventhub_link_detaches_total{namespace="ns",hub="hub",entity="hub/Partitions/0",condition="amqp:link:detach-forced"} 1`,
+		`eventhub_link_detaches_total{namespace="ns",hub="hub",entity="hub/Partitions/0",condition="none"} 1`,
+		`eventhub_cbs_token_refreshes_total{namespace="ns",hub="hub",outcome="success"} 1`,
+		`eventhub_cbs_token_refreshes_total{namespace="ns",hub="hub",outcome="failure"} 1`,
+		`eventhub_link_recovery_duration_seconds_count{namespace="ns",hub="hub",entity="hub/Partitions/0"} 1`,
+		`eventhub_link_recovery_duration_seconds_bucket{namespace="ns",hub="hub",entity="hub/Partitions/0",le="2.5"} 0`,
+		`eventhub_link_recovery_duration_seconds_bucket{namespace="ns",hub="hub",entity="hub/Partitions/0",le="5"} 1`,
+	} {
+		assert.Contains(t, text, line+"\n")
+	}
+}
+
+func TestHubMetrics_Freshness(t *testing.T) {
+	registry := metrics.NewRegistry()
+	h := &Hub{name: "hub", namespace: &namespace{name: "ns"}}
+	require.NoError(t, HubWithMetrics(registry)(h))
+
+	now := time.Now()
+	enqueued := now.Add(-90 * time.Second)
+	future := now.Add(time.Second)
+	h.metrics.observeFreshness("$Default", "0", &Event{SystemProperties: &SystemProperties{EnqueuedTime: &enqueued}}, now)
+	h.metrics.observeFreshness("$Default", "0", &Event{SystemProperties: &SystemProperties{EnqueuedTime: &future}}, now)
+	h.metrics.observeFreshness("$Default", "0", NewEventFromString("never enqueued"), now)
+
+	var sb strings.Builder
+	require.NoError(t, registry.WriteText(&sb))
+	text := sb.String()
+	assert.Contains(t, text, `eventhub_enqueue_to_process_seconds_bucket{namespace="ns",hub="hub",consumer_group="$Default",partition="0",le="0.01"} 1`+"\n")
+	assert.Contains(t, text, `eventhub_enqueue_to_process_seconds_bucket{namespace="ns",hub="hub",consumer_group="$Default",partition="0",le="60"} 1`+"\n")
+	assert.Contains(t, text, `eventhub_enqueue_to_process_seconds_bucket{namespace="ns",hub="hub",consumer_group="$Default",partition="0",le="300"} 2`+"\n")
+	assert.Contains(t, text, `eventhub_enqueue_to_process_seconds_sum{namespace="ns",hub="hub",consumer_group="$Default",partition="0"} 90`+"\n")
+}
@@ -0,0 +1,150 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"sync"
+)
+
+type (
+	// HubStats is a snapshot of the cumulative counters of a Hub since it was created, along with the links it has open
+	HubStats struct {
+		// EventsSent is the number of events sent successfully, counting each event of a batch
+		EventsSent int64 `json:"eventsSent"`
+		// BytesSent is the number of bytes of event data sent successfully
+		BytesSent int64 `json:"bytesSent"`
+		// SendFailures is the number of sends which failed after exhausting their retries
+		SendFailures int64 `json:"sendFailures"`
+		// SendRetries is the number of send attempts which failed and were retried
+		SendRetries int64 `json:"sendRetries"`
+		// EventsReceived is the number of events delivered to handlers
+		EventsReceived int64 `json:"eventsReceived"`
+		// BytesReceived is the number of bytes of event data delivered to handlers
+		BytesReceived int64 `json:"bytesReceived"`
+		// HandlerFailures is the number of events whose handler returned an error
+		HandlerFailures int64 `json:"handlerFailures"`
+		// SlowHandlers is the number of handler invocations slower than the threshold of HubWithSlowHandlerThreshold
+		SlowHandlers int64 `json:"slowHandlers"`
+		// SequenceGaps is the number of times events of a partition were skipped
+		SequenceGaps int64 `json:"sequenceGaps"`
+		// MissingEvents is the number of events skipped by sequence gaps
+		MissingEvents int64 `json:"missingEvents"`
+		// Reconnects is the number of links recovered after a failure
+		Reconnects int64 `json:"reconnects"`
+		// RecoveryFailures is the number of links whose recovery was given up
+		RecoveryFailures int64 `json:"recoveryFailures"`
+		// ActiveSenders is the number of sender links currently open
+		ActiveSenders int `json:"activeSenders"`
+		// ActiveReceivers is the number of receiver links currently open
+		ActiveReceivers int `json:"activeReceivers"`
+	}
+
+	// hubStats accumulates the counters reported by Hub.Stats
+	hubStats struct {
+		mu     sync.Mutex
+		counts HubStats
+	}
+)
+
+// Stats returns a snapshot of the Hub's cumulative send, receive and recovery counters and of its open links. Unlike
+// HubWithMetrics, statistics are always collected and need no registry, which lets applications export them to
+// whichever monitoring system they use.
+func (h *Hub) Stats() HubStats {
+	h.stats.mu.Lock()
+	stats := h.stats.counts
+	h.stats.mu.Unlock()
+
+	h.senderMu.Lock()
+	if h.sender != nil {
+		stats.ActiveSenders = 1
+	}
+	for _, s := range h.senderStripes {
+		if s != nil {
+			stats.ActiveSenders++
+		}
+	}
+	stats.ActiveSenders += len(h.partitionSenders)
+	h.senderMu.Unlock()
+
+	h.receiverMu.Lock()
+	stats.ActiveReceivers = len(h.receivers)
+	h.receiverMu.Unlock()
+	return stats
+}
+
+func (s *hubStats) observeSend(evt eventer, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.counts.SendFailures++
+		return
+	}
+
+	events, bytes := eventerSize(evt)
+	s.counts.EventsSent += int64(events)
+	s.counts.BytesSent += int64(bytes)
+}
+
+func (s *hubStats) observeRetry() {
+	s.mu.Lock()
+	s.counts.SendRetries++
+	s.mu.Unlock()
+}
+
+func (s *hubStats) observeReceive(event *Event, err error) {
+	if event == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts.EventsReceived++
+	s.counts.BytesReceived += int64(len(event.Data))
+	if err != nil {
+		s.counts.HandlerFailures++
+	}
+}
+
+func (s *hubStats) observeSlowHandler() {
+	s.mu.Lock()
+	s.counts.SlowHandlers++
+	s.mu.Unlock()
+}
+
+func (s *hubStats) observeSequenceGap(missing int64) {
+	s.mu.Lock()
+	s.counts.SequenceGaps++
+	s.counts.MissingEvents += missing
+	s.mu.Unlock()
+}
+
+func (s *hubStats) observeRecovery(event RecoveryEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case event.Recovered:
+		s.counts.Reconnects++
+	case event.GaveUp:
+		s.counts.RecoveryFailures++
+	}
+}
@@ -0,0 +1,44 @@
+package eventhub
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_Stats(t *testing.T) {
+	h := &Hub{name: "hub", receivers: map[string]*receiver{"0": {}, "1": {}}}
+	assert.Equal(t, HubStats{ActiveReceivers: 2}, h.Stats())
+
+	batch := NewEventBatch("id", nil)
+	_, err := batch.Add(NewEventFromString("foo"))
+	require.NoError(t, err)
+	_, err = batch.Add(NewEventFromString("barbaz"))
+	require.NoError(t, err)
+
+	h.stats.observeSend(NewEventFromString("hello"), nil)
+	h.stats.observeSend(batch, nil)
+	h.stats.observeSend(NewEventFromString("hello"), errors.New("failed"))
+	h.stats.observeRetry()
+	h.stats.observeReceive(NewEventFromString("hello"), nil)
+	h.stats.observeReceive(NewEventFromString("hi"), errors.New("handler failed"))
+	h.stats.observeRecovery(RecoveryEvent{Attempt: 1})
+	h.stats.observeRecovery(RecoveryEvent{Recovered: true})
+	h.stats.observeRecovery(RecoveryEvent{GaveUp: true})
+	h.sender = new(sender)
+
+	stats := h.Stats()
+	assert.Equal(t, int64(3), stats.EventsSent)
+	assert.Equal(t, int64(5+batch.size), stats.BytesSent)
+	assert.Equal(t, int64(1), stats.SendFailures)
+	assert.Equal(t, int64(1), stats.SendRetries)
+	assert.Equal(t, int64(2), stats.EventsReceived)
+	assert.Equal(t, int64(7), stats.BytesReceived)
+	assert.Equal(t, int64(1), stats.HandlerFailures)
+	assert.Equal(t, int64(1), stats.Reconnects)
+	assert.Equal(t, int64(1), stats.RecoveryFailures)
+	assert.Equal(t, 1, stats.ActiveSenders)
+	assert.Equal(t, 2, stats.ActiveReceivers)
+}
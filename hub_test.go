@@ -28,6 +28,7 @@ package eventhub
 import (
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -238,6 +239,7 @@ func (suite *eventHubSuite) TestSasToken() {
 		"TestMultiSendAndReceive":            testMultiSendAndReceive,
 		"TestHubRuntimeInformation":          testHubRuntimeInformation,
 		"TestHubPartitionRuntimeInformation": testHubPartitionRuntimeInformation,
+		"TestAllPartitionInformation":        testAllPartitionInformation,
 	}
 
 	for name, testFunc := range tests {
@@ -616,6 +618,7 @@ func (suite *eventHubSuite) TestHubManagement() {
 	tests := map[string]func(context.Context, *testing.T, *Hub, []string, string){
 		"TestHubRuntimeInformation":          testHubRuntimeInformation,
 		"TestHubPartitionRuntimeInformation": testHubPartitionRuntimeInformation,
+		"TestAllPartitionInformation":        testAllPartitionInformation,
 	}
 
 	for name, testFunc := range tests {
@@ -650,6 +653,19 @@ func testHubPartitionRuntimeInformation(ctx context.Context, t *testing.T, clien
 	}
 }
 
+func testAllPartitionInformation(ctx context.Context, t *testing.T, client *Hub, partitionIDs []string, hubName string) {
+	infos, err := client.GetAllPartitionInformation(ctx)
+	if assert.NoError(t, err) {
+		assert.Len(t, infos, len(partitionIDs))
+		for _, partitionID := range partitionIDs {
+			if assert.Contains(t, infos, partitionID) {
+				assert.Equal(t, hubName, infos[partitionID].HubPath)
+				assert.Equal(t, partitionID, infos[partitionID].PartitionID)
+			}
+		}
+	}
+}
+
 func TestEnvironmentalCreation(t *testing.T) {
 	require.NoError(t, os.Setenv("EVENTHUB_NAME", "foo"))
 	_, err := NewHubFromEnvironment()
@@ -773,3 +789,29 @@ func TestIsRecoverableCloseError(t *testing.T) {
 	// if the caller closes a link we shouldn't reopen or create a new one to replace it
 	require.False(t, isRecoverableCloseError(amqp.ErrLinkClosed))
 }
+
+type fakeConsumerGroupProvisioner struct {
+	calls []string
+	err   error
+}
+
+func (p *fakeConsumerGroupProvisioner) EnsureConsumerGroup(_ context.Context, hubName, consumerGroup string) error {
+	p.calls = append(p.calls, hubName+"/"+consumerGroup)
+	return p.err
+}
+
+func TestHub_EnsureConsumerGroup(t *testing.T) {
+	provisioner := new(fakeConsumerGroupProvisioner)
+	h := &Hub{name: "hub"}
+	require.NoError(t, HubWithConsumerGroupAutoCreate(provisioner)(h))
+
+	require.NoError(t, h.ensureConsumerGroup(context.Background(), DefaultConsumerGroup))
+	require.NoError(t, h.ensureConsumerGroup(context.Background(), "cg"))
+	require.NoError(t, h.ensureConsumerGroup(context.Background(), "cg"))
+	assert.Equal(t, []string{"hub/cg"}, provisioner.calls, "default group is skipped and ensured groups are cached")
+
+	provisioner.err = errors.New("forbidden")
+	err := h.ensureConsumerGroup(context.Background(), "other")
+	assert.True(t, errors.Is(err, provisioner.err))
+	require.Error(t, h.ensureConsumerGroup(context.Background(), "other"), "failures are not cached")
+}
@@ -0,0 +1,64 @@
+// Package buffer pools the byte buffers the client encodes and decodes messages, leases and checkpoints with, so that
+// steady streams of them don't allocate a buffer each.
+package buffer
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"bytes"
+	"sync"
+)
+
+const (
+	// maxPooledSize is the largest buffer returned to the pool. It is the largest message Event Hubs accepts, so
+	// buffers grown for unusually large payloads, such as big exports, are left to the garbage collector rather than
+	// held on to.
+	maxPooledSize = 1024 * 1024
+)
+
+var pool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// Get returns an empty buffer from the pool
+func Get() *bytes.Buffer {
+	return pool.Get().(*bytes.Buffer)
+}
+
+// Put resets buf and returns it to the pool. The bytes of buf must not be used after Put.
+func Put(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > maxPooledSize {
+		return
+	}
+	buf.Reset()
+	pool.Put(buf)
+}
+
+// Sized returns a slice of n bytes backed by buf, which is emptied first, for reading n bytes into
+func Sized(buf *bytes.Buffer, n int) []byte {
+	buf.Reset()
+	buf.Grow(n)
+	return buf.Bytes()[:n]
+}
@@ -0,0 +1,33 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPut(t *testing.T) {
+	buf := Get()
+	buf.WriteString("hello")
+	Put(buf)
+
+	assert.Equal(t, 0, Get().Len(), "pooled buffers must be empty")
+	Put(nil)
+}
+
+func TestPut_DropsLargeBuffers(t *testing.T) {
+	buf := Get()
+	buf.Write(make([]byte, 2*maxPooledSize))
+	Put(buf)
+	assert.Equal(t, 2*maxPooledSize, buf.Len(), "large buffers are left to the garbage collector as they are")
+}
+
+func TestSized(t *testing.T) {
+	buf := Get()
+	defer Put(buf)
+	buf.WriteString("left over")
+
+	b := Sized(buf, 16)
+	assert.Len(t, b, 16)
+	assert.True(t, buf.Cap() >= 16)
+}
@@ -0,0 +1,240 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-amqp-common-go/v3/auth"
+	"github.com/Azure/go-amqp"
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+const (
+	// iotHubEventsAddress is the address of device-to-cloud messages on an IoT Hub, which redirects to the Event Hubs
+	// compatible endpoint of the hub
+	iotHubEventsAddress = "messages/events/"
+	iotHubTokenDuration = time.Hour
+)
+
+type (
+	// iotHubConnection is the information of an IoT Hub connection string
+	iotHubConnection struct {
+		hostName string
+		keyName  string
+		key      string
+	}
+
+	// iotHubTokenProvider provides SAS tokens signed the way IoT Hub expects, with the decoded shared access key
+	iotHubTokenProvider struct {
+		keyName string
+		key     []byte
+	}
+)
+
+// NewHubFromIoTHubConnectionString creates a new Event Hub client for the built-in endpoint of an IoT Hub, where the
+// hub delivers device-to-cloud messages, from a connection string of a shared access policy of the IoT Hub with the
+// service connect permission, formatted like the following:
+//
+//   HostName=myhub.azure-devices.net;SharedAccessKeyName=service;SharedAccessKey=superSecret1234=
+//
+// The Event Hubs compatible endpoint is discovered by connecting to the IoT Hub, see ResolveIoTHubConnectionString.
+func NewHubFromIoTHubConnectionString(ctx context.Context, connStr string, opts ...HubOption) (*Hub, error) {
+	ehConnStr, err := ResolveIoTHubConnectionString(ctx, connStr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewHubFromConnectionString(ehConnStr, opts...)
+}
+
+// ResolveIoTHubConnectionString returns the Event Hubs connection string of the built-in endpoint of the IoT Hub of
+// an IoT Hub connection string. IoT Hub answers a receiver of device-to-cloud messages with a redirect to the Event Hub
+// behind it, which names the namespace and hub of the endpoint; the connection string authenticates to them with the
+// shared access policy of the IoT Hub. The options configure the connection to the IoT Hub, such as
+// HubWithWebSocketConnection or HubWithProxy where outbound AMQP is blocked.
+//
+// The result can be used wherever an Event Hubs connection string can, for instance to build an EventProcessorHost.
+func ResolveIoTHubConnectionString(ctx context.Context, connStr string, opts ...HubOption) (string, error) {
+	parsed, err := parseIoTHubConnectionString(connStr)
+	if err != nil {
+		return "", err
+	}
+
+	provider, err := newIoTHubTokenProvider(parsed.keyName, parsed.key)
+	if err != nil {
+		return "", err
+	}
+
+	ns, err := newNamespace()
+	if err != nil {
+		return "", err
+	}
+	ns.name = strings.Split(parsed.hostName, ".")[0]
+	ns.host = "amqps://" + parsed.hostName
+	ns.tokenProvider = provider
+
+	h := &Hub{
+		name:               iotHubEventsAddress,
+		namespace:          ns,
+		offsetPersister:    persist.NewMemoryPersister(),
+		userAgent:          rootUserAgent,
+		receivers:          make(map[string]*receiver),
+		senderRetryOptions: newSenderRetryOptions(),
+		mgmtRetryOptions:   newManagementRetryOptions(),
+		recoveryOptions:    newRecoveryOptions(),
+		throttle:           newServerBusyThrottle(defaultThrottleBaseDelay, defaultThrottleMaxDelay),
+	}
+	for _, opt := range opts {
+		if err := opt(h); err != nil {
+			return "", err
+		}
+	}
+
+	host, entityPath, err := h.resolveIoTHubEndpoint(ctx, parsed.hostName)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Endpoint=sb://%s/;SharedAccessKeyName=%s;SharedAccessKey=%s;EntityPath=%s", host, parsed.keyName, parsed.key, entityPath), nil
+}
+
+// resolveIoTHubEndpoint attaches a receiver to the device-to-cloud messages of the IoT Hub and returns the host and
+// entity path of the redirect the hub answers with
+func (h *Hub) resolveIoTHubEndpoint(ctx context.Context, hostName string) (string, string, error) {
+	span, ctx := h.startSpanFromContext(ctx, "eh.Hub.resolveIoTHubEndpoint")
+	defer span.End()
+
+	client, err := h.namespace.newConnection()
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return "", "", err
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := h.namespace.negotiateClaimForAudience(ctx, client, hostName, iotHubEventsAddress); err != nil {
+		tab.For(ctx).Error(err)
+		return "", "", err
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return "", "", err
+	}
+
+	receiver, err := session.NewReceiver(amqp.LinkSourceAddress(iotHubEventsAddress))
+	if err == nil {
+		_ = receiver.Close(ctx)
+		err = fmt.Errorf("%s did not redirect to an Event Hubs compatible endpoint", hostName)
+		tab.For(ctx).Error(err)
+		return "", "", err
+	}
+
+	var amqpErr *amqp.Error
+	if !errors.As(err, &amqpErr) || amqpErr.Condition != amqp.ErrorLinkRedirect {
+		tab.For(ctx).Error(err)
+		return "", "", err
+	}
+	return iotHubRedirectEndpoint(amqpErr)
+}
+
+// iotHubRedirectEndpoint returns the host and entity path of a link redirect, whose address is like
+// amqps://namespace.servicebus.windows.net:5671/iothub-ehub-myhub-1234-abcdef/
+func iotHubRedirectEndpoint(redirect *amqp.Error) (string, string, error) {
+	address, _ := redirect.Info["address"].(string)
+	u, err := url.Parse(address)
+	if err != nil || u.Hostname() == "" {
+		return "", "", fmt.Errorf("redirect has an invalid address %q", address)
+	}
+
+	entityPath := strings.Trim(u.Path, "/")
+	if entityPath == "" {
+		return "", "", fmt.Errorf("redirect address %q has no entity path", address)
+	}
+
+	host := u.Hostname()
+	if hostName, ok := redirect.Info["hostname"].(string); ok && hostName != "" {
+		host = hostName
+	}
+	return host, entityPath, nil
+}
+
+// parseIoTHubConnectionString parses a connection string of a shared access policy of an IoT Hub
+func parseIoTHubConnectionString(connStr string) (*iotHubConnection, error) {
+	parsed := new(iotHubConnection)
+	for _, part := range strings.Split(connStr, ";") {
+		keyValue := strings.SplitN(part, "=", 2)
+		if len(keyValue) != 2 {
+			continue
+		}
+
+		value := strings.TrimSpace(keyValue[1])
+		switch strings.ToLower(strings.TrimSpace(keyValue[0])) {
+		case "hostname":
+			parsed.hostName = value
+		case "sharedaccesskeyname":
+			parsed.keyName = value
+		case "sharedaccesskey":
+			parsed.key = value
+		case "deviceid", "moduleid":
+			return nil, errors.New("device and module connection strings can't read device-to-cloud messages; use a connection string of a shared access policy of the IoT Hub")
+		}
+	}
+
+	if parsed.hostName == "" || parsed.keyName == "" || parsed.key == "" {
+		return nil, errors.New("IoT Hub connection string must have a HostName, SharedAccessKeyName and SharedAccessKey")
+	}
+	return parsed, nil
+}
+
+func newIoTHubTokenProvider(keyName, key string) (*iotHubTokenProvider, error) {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("IoT Hub shared access key isn't base64 encoded: %v", err)
+	}
+	return &iotHubTokenProvider{keyName: keyName, key: decoded}, nil
+}
+
+// GetToken returns a SAS token for the audience, which is the host name of the IoT Hub
+func (p *iotHubTokenProvider) GetToken(audience string) (*auth.Token, error) {
+	expiry := strconv.FormatInt(time.Now().Add(iotHubTokenDuration).Unix(), 10)
+	return auth.NewToken(auth.CBSTokenTypeSAS, p.sign(audience, expiry), expiry), nil
+}
+
+func (p *iotHubTokenProvider) sign(audience, expiry string) string {
+	resource := url.QueryEscape(audience)
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(resource + "\n" + expiry))
+	signature := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return fmt.Sprintf("SharedAccessSignature sr=%s&sig=%s&se=%s&skn=%s", resource, signature, expiry, p.keyName)
+}
@@ -0,0 +1,56 @@
+package eventhub
+
+import (
+	"testing"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIoTHubConnectionString(t *testing.T) {
+	parsed, err := parseIoTHubConnectionString("HostName=myhub.azure-devices.net;SharedAccessKeyName=service;SharedAccessKey=c2VjcmV0LWtleS1ieXRlcw==")
+	require.NoError(t, err)
+	assert.Equal(t, "myhub.azure-devices.net", parsed.hostName)
+	assert.Equal(t, "service", parsed.keyName)
+	assert.Equal(t, "c2VjcmV0LWtleS1ieXRlcw==", parsed.key)
+
+	_, err = parseIoTHubConnectionString("HostName=myhub.azure-devices.net;DeviceId=device1;SharedAccessKey=c2VjcmV0LWtleS1ieXRlcw==")
+	assert.Error(t, err)
+
+	_, err = parseIoTHubConnectionString("HostName=myhub.azure-devices.net;SharedAccessKeyName=service")
+	assert.Error(t, err)
+}
+
+func TestIoTHubTokenProvider(t *testing.T) {
+	provider, err := newIoTHubTokenProvider("service", "c2VjcmV0LWtleS1ieXRlcw==")
+	require.NoError(t, err)
+	assert.Equal(t,
+		"SharedAccessSignature sr=myhub.azure-devices.net&sig=skWMTQpX%2BzC%2FGpgQ9BkAVneZS7dJEgymEwAc7uWlz94%3D&se=1700000000&skn=service",
+		provider.sign("myhub.azure-devices.net", "1700000000"))
+
+	token, err := provider.GetToken("myhub.azure-devices.net")
+	require.NoError(t, err)
+	assert.Contains(t, token.Token, "sr=myhub.azure-devices.net&")
+
+	_, err = newIoTHubTokenProvider("service", "not base64!")
+	assert.Error(t, err)
+}
+
+func TestIoTHubRedirectEndpoint(t *testing.T) {
+	host, entityPath, err := iotHubRedirectEndpoint(&amqp.Error{
+		Condition: amqp.ErrorLinkRedirect,
+		Info: map[string]interface{}{
+			"hostname":     "ihsuprodbyres063dednamespace.servicebus.windows.net",
+			"network-host": "ihsuprodbyres063dednamespace.servicebus.windows.net",
+			"port":         int32(5671),
+			"address":      "amqps://ihsuprodbyres063dednamespace.servicebus.windows.net:5671/iothub-ehub-myhub-1234-abcdef/",
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ihsuprodbyres063dednamespace.servicebus.windows.net", host)
+	assert.Equal(t, "iothub-ehub-myhub-1234-abcdef", entityPath)
+
+	_, _, err = iotHubRedirectEndpoint(&amqp.Error{Condition: amqp.ErrorLinkRedirect})
+	assert.Error(t, err)
+}
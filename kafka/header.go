@@ -0,0 +1,252 @@
+package kafka
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Azure/go-amqp"
+)
+
+// AMQP type constructors of the primitive values application properties can hold
+const (
+	typeNull       byte = 0x40
+	typeTrue       byte = 0x41
+	typeFalse      byte = 0x42
+	typeBool       byte = 0x56
+	typeUbyte      byte = 0x50
+	typeByte       byte = 0x51
+	typeSmallUint  byte = 0x52
+	typeSmallUlong byte = 0x53
+	typeSmallInt   byte = 0x54
+	typeSmallLong  byte = 0x55
+	typeUint0      byte = 0x43
+	typeUlong0     byte = 0x44
+	typeUshort     byte = 0x60
+	typeShort      byte = 0x61
+	typeUint       byte = 0x70
+	typeInt        byte = 0x71
+	typeFloat      byte = 0x72
+	typeUlong      byte = 0x80
+	typeLong       byte = 0x81
+	typeDouble     byte = 0x82
+	typeTimestamp  byte = 0x83
+	typeUUID       byte = 0x98
+	typeVbin8      byte = 0xa0
+	typeStr8       byte = 0xa1
+	typeSym8       byte = 0xa3
+	typeVbin32     byte = 0xb0
+	typeStr32      byte = 0xb1
+	typeSym32      byte = 0xb3
+)
+
+var errTruncated = errors.New("kafka: AMQP encoded header value is truncated")
+
+// EncodeHeaderValue encodes v in the AMQP type system, as the Event Hubs Kafka endpoint encodes application properties
+// of events sent over AMQP into the header values of Kafka records. Nil, booleans, integers, floats, strings, byte
+// slices, times and amqp.UUID values are supported.
+func EncodeHeaderValue(v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case nil:
+		return []byte{typeNull}, nil
+	case bool:
+		if value {
+			return []byte{typeTrue}, nil
+		}
+		return []byte{typeFalse}, nil
+	case uint8:
+		return []byte{typeUbyte, value}, nil
+	case int8:
+		return []byte{typeByte, byte(value)}, nil
+	case uint16:
+		return appendUint(typeUshort, uint64(value), 2), nil
+	case int16:
+		return appendUint(typeShort, uint64(uint16(value)), 2), nil
+	case uint32:
+		return appendUint(typeUint, uint64(value), 4), nil
+	case int32:
+		return appendUint(typeInt, uint64(uint32(value)), 4), nil
+	case uint64:
+		return appendUint(typeUlong, value, 8), nil
+	case uint:
+		return appendUint(typeUlong, uint64(value), 8), nil
+	case int64:
+		return appendUint(typeLong, uint64(value), 8), nil
+	case int:
+		return appendUint(typeLong, uint64(int64(value)), 8), nil
+	case float32:
+		return appendUint(typeFloat, uint64(math.Float32bits(value)), 4), nil
+	case float64:
+		return appendUint(typeDouble, math.Float64bits(value), 8), nil
+	case time.Time:
+		return appendUint(typeTimestamp, uint64(value.UnixNano()/int64(time.Millisecond)), 8), nil
+	case amqp.UUID:
+		return append([]byte{typeUUID}, value[:]...), nil
+	case string:
+		return appendVariable(typeStr8, typeStr32, []byte(value)), nil
+	case []byte:
+		return appendVariable(typeVbin8, typeVbin32, value), nil
+	default:
+		return nil, fmt.Errorf("kafka: %T values can't be encoded as AMQP header values", v)
+	}
+}
+
+// DecodeHeaderValue decodes a header value encoded in the AMQP type system, such as the header values the Event Hubs
+// Kafka endpoint delivers for the application properties of events sent over AMQP. Symbols are decoded as strings.
+func DecodeHeaderValue(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, errTruncated
+	}
+
+	typ, data := data[0], data[1:]
+	size := fixedSize(typ)
+	if size >= 0 {
+		if len(data) != size {
+			return nil, fmt.Errorf("kafka: AMQP value of type 0x%02x must have %d bytes, not %d", typ, size, len(data))
+		}
+		return decodeFixed(typ, data)
+	}
+
+	var length int
+	switch typ {
+	case typeVbin8, typeStr8, typeSym8:
+		if len(data) < 1 {
+			return nil, errTruncated
+		}
+		length, data = int(data[0]), data[1:]
+	case typeVbin32, typeStr32, typeSym32:
+		if len(data) < 4 {
+			return nil, errTruncated
+		}
+		length, data = int(binary.BigEndian.Uint32(data)), data[4:]
+	default:
+		return nil, fmt.Errorf("kafka: unsupported AMQP type 0x%02x", typ)
+	}
+	if len(data) != length {
+		return nil, fmt.Errorf("kafka: AMQP value of type 0x%02x has %d bytes, not the %d its length gives", typ, len(data), length)
+	}
+
+	switch typ {
+	case typeVbin8, typeVbin32:
+		return append([]byte(nil), data...), nil
+	default:
+		if !utf8.Valid(data) {
+			return nil, errors.New("kafka: AMQP string is not valid UTF-8")
+		}
+		return string(data), nil
+	}
+}
+
+// fixedSize returns the size of the values of a fixed width type following its constructor, or -1 for variable width
+// and unknown types
+func fixedSize(typ byte) int {
+	switch typ {
+	case typeNull, typeTrue, typeFalse, typeUint0, typeUlong0:
+		return 0
+	case typeBool, typeUbyte, typeByte, typeSmallUint, typeSmallUlong, typeSmallInt, typeSmallLong:
+		return 1
+	case typeUshort, typeShort:
+		return 2
+	case typeUint, typeInt, typeFloat:
+		return 4
+	case typeUlong, typeLong, typeDouble, typeTimestamp:
+		return 8
+	case typeUUID:
+		return 16
+	default:
+		return -1
+	}
+}
+
+func decodeFixed(typ byte, data []byte) (interface{}, error) {
+	switch typ {
+	case typeNull:
+		return nil, nil
+	case typeTrue:
+		return true, nil
+	case typeFalse:
+		return false, nil
+	case typeBool:
+		return data[0] != 0, nil
+	case typeUbyte:
+		return data[0], nil
+	case typeByte:
+		return int8(data[0]), nil
+	case typeUint0:
+		return uint32(0), nil
+	case typeSmallUint:
+		return uint32(data[0]), nil
+	case typeUlong0:
+		return uint64(0), nil
+	case typeSmallUlong:
+		return uint64(data[0]), nil
+	case typeSmallInt:
+		return int32(int8(data[0])), nil
+	case typeSmallLong:
+		return int64(int8(data[0])), nil
+	case typeUshort:
+		return binary.BigEndian.Uint16(data), nil
+	case typeShort:
+		return int16(binary.BigEndian.Uint16(data)), nil
+	case typeUint:
+		return binary.BigEndian.Uint32(data), nil
+	case typeInt:
+		return int32(binary.BigEndian.Uint32(data)), nil
+	case typeFloat:
+		return math.Float32frombits(binary.BigEndian.Uint32(data)), nil
+	case typeUlong:
+		return binary.BigEndian.Uint64(data), nil
+	case typeLong:
+		return int64(binary.BigEndian.Uint64(data)), nil
+	case typeDouble:
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case typeTimestamp:
+		ms := int64(binary.BigEndian.Uint64(data))
+		return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond)).UTC(), nil
+	default:
+		var uuid amqp.UUID
+		copy(uuid[:], data)
+		return uuid, nil
+	}
+}
+
+func appendUint(typ byte, value uint64, size int) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, value)
+	return append([]byte{typ}, buf[8-size:]...)
+}
+
+func appendVariable(short, long byte, data []byte) []byte {
+	if len(data) <= math.MaxUint8 {
+		return append([]byte{short, byte(len(data))}, data...)
+	}
+	buf := make([]byte, 5, 5+len(data))
+	buf[0] = long
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(data)))
+	return append(buf, data...)
+}
@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeHeaderValue(t *testing.T) {
+	cases := map[string]struct {
+		value   interface{}
+		encoded []byte
+	}{
+		"nil":       {nil, []byte{0x40}},
+		"true":      {true, []byte{0x41}},
+		"int32":     {int32(-2), []byte{0x71, 0xff, 0xff, 0xff, 0xfe}},
+		"int":       {1, []byte{0x81, 0, 0, 0, 0, 0, 0, 0, 1}},
+		"uint16":    {uint16(258), []byte{0x60, 1, 2}},
+		"double":    {1.5, []byte{0x82, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		"string":    {"hi", []byte{0xa1, 2, 'h', 'i'}},
+		"binary":    {[]byte{1, 2}, []byte{0xa0, 2, 1, 2}},
+		"timestamp": {time.Unix(1, 0), []byte{0x83, 0, 0, 0, 0, 0, 0, 0x03, 0xe8}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			encoded, err := EncodeHeaderValue(c.value)
+			require.NoError(t, err)
+			assert.Equal(t, c.encoded, encoded)
+		})
+	}
+
+	_, err := EncodeHeaderValue(struct{}{})
+	assert.Error(t, err)
+}
+
+func TestHeaderValueRoundTrip(t *testing.T) {
+	values := []interface{}{
+		nil, true, false, uint8(7), int8(-7), uint16(math.MaxUint16), int16(math.MinInt16), uint32(math.MaxUint32),
+		int32(math.MinInt32), uint64(math.MaxUint64), int64(math.MinInt64), float32(1.25), math.Pi, "hello",
+		strings.Repeat("long ", 100), []byte{0, 1}, time.Date(2021, 6, 3, 16, 48, 47, 123e6, time.UTC),
+		amqp.UUID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+	}
+
+	for _, value := range values {
+		encoded, err := EncodeHeaderValue(value)
+		require.NoError(t, err)
+		decoded, err := DecodeHeaderValue(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, value, decoded)
+	}
+}
+
+func TestDecodeHeaderValue(t *testing.T) {
+	decoded, err := DecodeHeaderValue([]byte{0x54, 0xff})
+	require.NoError(t, err)
+	assert.Equal(t, int32(-1), decoded, "small ints are sign extended")
+
+	decoded, err = DecodeHeaderValue([]byte{0x44})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), decoded)
+
+	decoded, err = DecodeHeaderValue([]byte{0xa3, 3, 'f', 'o', 'o'})
+	require.NoError(t, err)
+	assert.Equal(t, "foo", decoded, "symbols are decoded as strings")
+
+	for _, invalid := range [][]byte{nil, {0x71, 1}, {0xa1, 3, 'a'}, {0xb1, 0, 0}, {0xa1, 1, 0xff}, {0x00}} {
+		_, err := DecodeHeaderValue(invalid)
+		assert.Error(t, err, "%x", invalid)
+	}
+}
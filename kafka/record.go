@@ -0,0 +1,143 @@
+// Package kafka converts between events and Kafka records following the mapping of the Event Hubs Kafka endpoint, so
+// pipelines bridging AMQP and Kafka clients of the same hub keep the metadata of events:
+//
+//   - the body of an event is the value of a record
+//   - the partition key of an event is the key of a record
+//   - the application properties of an event are the headers of a record, with values encoded in the AMQP type system
+//     when they come from AMQP, as the Kafka endpoint delivers them to Kafka consumers
+//   - the enqueued time and sequence number of an event are the timestamp and offset of a record
+package kafka
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+type (
+	// Header is a header of a Kafka record
+	Header struct {
+		Key   string
+		Value []byte
+	}
+
+	// Record holds the fields of a Kafka record which have a counterpart on events
+	Record struct {
+		Key       []byte
+		Value     []byte
+		Headers   []Header
+		Timestamp time.Time
+		// Offset is the offset of the record in its partition. The Kafka endpoint uses sequence numbers as offsets.
+		Offset int64
+	}
+
+	// EventOption provides structure for configuring the conversion of records to events
+	EventOption func(o *eventOptions) error
+
+	eventOptions struct {
+		amqpHeaderValues bool
+	}
+)
+
+// EventOptionWithAMQPHeaderValues decodes the values of headers in the AMQP type system, for records which carry the
+// application properties of events sent over AMQP, such as records built by RecordFromEvent or received from the Kafka
+// endpoint for events of AMQP producers. Without it, header values become []byte application properties, as the Kafka
+// endpoint delivers the headers of Kafka producers to AMQP consumers.
+func EventOptionWithAMQPHeaderValues() EventOption {
+	return func(o *eventOptions) error {
+		o.amqpHeaderValues = true
+		return nil
+	}
+}
+
+// RecordFromEvent converts event to a Kafka record. Application properties become headers sorted by key, with values
+// encoded by EncodeHeaderValue.
+func RecordFromEvent(event *eventhub.Event) (*Record, error) {
+	record := &Record{Value: event.Data}
+	if event.PartitionKey != nil {
+		record.Key = []byte(*event.PartitionKey)
+	}
+
+	keys := make([]string, 0, len(event.Properties))
+	for key := range event.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, err := EncodeHeaderValue(event.Properties[key])
+		if err != nil {
+			return nil, fmt.Errorf("kafka: property %q: %v", key, err)
+		}
+		record.Headers = append(record.Headers, Header{Key: key, Value: value})
+	}
+
+	if sysProps := event.SystemProperties; sysProps != nil {
+		if sysProps.EnqueuedTime != nil {
+			record.Timestamp = *sysProps.EnqueuedTime
+		}
+		if sysProps.SequenceNumber != nil {
+			record.Offset = *sysProps.SequenceNumber
+		}
+	}
+	return record, nil
+}
+
+// EventFromRecord converts a Kafka record to an event for sending. Headers become application properties, the last
+// header winning for repeated keys. The timestamp and offset of the record aren't carried over, the hub assigns the
+// enqueued time and sequence number of the event.
+func EventFromRecord(record *Record, opts ...EventOption) (*eventhub.Event, error) {
+	options := new(eventOptions)
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+
+	event := eventhub.NewEvent(record.Value)
+	if len(record.Key) > 0 {
+		key := string(record.Key)
+		event.PartitionKey = &key
+	}
+
+	if len(record.Headers) > 0 {
+		event.Properties = make(map[string]interface{}, len(record.Headers))
+	}
+	for _, header := range record.Headers {
+		if !options.amqpHeaderValues {
+			event.Properties[header.Key] = header.Value
+			continue
+		}
+
+		value, err := DecodeHeaderValue(header.Value)
+		if err != nil {
+			return nil, fmt.Errorf("kafka: header %q: %v", header.Key, err)
+		}
+		event.Properties[header.Key] = value
+	}
+	return event, nil
+}
@@ -0,0 +1,69 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+func TestRecordFromEvent(t *testing.T) {
+	key := "device-1"
+	enqueued := time.Date(2021, 6, 3, 16, 48, 47, 0, time.UTC)
+	sequenceNumber := int64(42)
+	event := &eventhub.Event{
+		Data:         []byte("hello"),
+		PartitionKey: &key,
+		Properties:   map[string]interface{}{"kind": "reading", "count": int32(3)},
+		SystemProperties: &eventhub.SystemProperties{
+			EnqueuedTime:   &enqueued,
+			SequenceNumber: &sequenceNumber,
+		},
+	}
+
+	record, err := RecordFromEvent(event)
+	require.NoError(t, err)
+	assert.Equal(t, &Record{
+		Key:   []byte("device-1"),
+		Value: []byte("hello"),
+		Headers: []Header{
+			{Key: "count", Value: []byte{0x71, 0, 0, 0, 3}},
+			{Key: "kind", Value: []byte{0xa1, 7, 'r', 'e', 'a', 'd', 'i', 'n', 'g'}},
+		},
+		Timestamp: enqueued,
+		Offset:    42,
+	}, record)
+
+	roundTripped, err := EventFromRecord(record, EventOptionWithAMQPHeaderValues())
+	require.NoError(t, err)
+	assert.Equal(t, event.Data, roundTripped.Data)
+	assert.Equal(t, event.PartitionKey, roundTripped.PartitionKey)
+	assert.Equal(t, event.Properties, roundTripped.Properties)
+	assert.Nil(t, roundTripped.SystemProperties)
+
+	event.Properties["invalid"] = struct{}{}
+	_, err = RecordFromEvent(event)
+	assert.Error(t, err)
+}
+
+func TestEventFromRecord(t *testing.T) {
+	record := &Record{
+		Value:   []byte("hello"),
+		Headers: []Header{{Key: "trace", Value: []byte("a")}, {Key: "trace", Value: []byte("b")}},
+	}
+
+	event, err := EventFromRecord(record)
+	require.NoError(t, err)
+	assert.Nil(t, event.PartitionKey)
+	assert.Equal(t, map[string]interface{}{"trace": []byte("b")}, event.Properties, "the last header wins")
+
+	_, err = EventFromRecord(record, EventOptionWithAMQPHeaderValues())
+	assert.Error(t, err, "raw header values aren't AMQP encoded")
+
+	event, err = EventFromRecord(&Record{})
+	require.NoError(t, err)
+	assert.Nil(t, event.Properties)
+}
@@ -0,0 +1,174 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/devigned/tab"
+)
+
+// errConnectionUnresponsive is returned by probes which the service didn't answer over the connection probed
+var errConnectionUnresponsive = errors.New("connection did not answer the keep-alive probe")
+
+type (
+	keepAliveOptions struct {
+		// interval is how long a receiver may go without traffic before its connection is probed
+		interval time.Duration
+		// timeout bounds how long a probe waits for the service to answer
+		timeout time.Duration
+	}
+)
+
+// HubWithKeepAlive enables dead-peer detection for receivers. When a receiver has gone interval without receiving an
+// event, a lightweight request is sent to the management node over the receiver's connection. If the service does not
+// answer within timeout, the connection is assumed to be half-open, for example after a NAT or load balancer dropped
+// it silently, and is recycled so the receiver recovers on a new connection instead of waiting indefinitely.
+func HubWithKeepAlive(interval, timeout time.Duration) HubOption {
+	return func(h *Hub) error {
+		if interval <= 0 {
+			return fmt.Errorf("keep-alive interval must be positive, got %v", interval)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("keep-alive timeout must be positive, got %v", timeout)
+		}
+		h.keepAlive = &keepAliveOptions{interval: interval, timeout: timeout}
+		return nil
+	}
+}
+
+// monitorConnection probes the receiver's connection whenever it has been idle for the keep-alive interval, and
+// recycles it if the service doesn't answer. Probes which fail for other reasons, such as the token provider failing,
+// say nothing of the connection, so they are retried at the next interval.
+func (r *receiver) monitorConnection(ctx context.Context, opts *keepAliveOptions) {
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if time.Since(r.lastActivityTime()) < opts.interval {
+			continue
+		}
+
+		conn := r.currentConnection()
+		if conn == nil {
+			continue
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, opts.timeout)
+		err := r.hub.probeConnection(probeCtx, conn)
+		cancel()
+		if err == nil || ctx.Err() != nil {
+			r.markActivity()
+			continue
+		}
+		if !errors.Is(err, errConnectionUnresponsive) {
+			r.hub.log(ctx, LogLevelWarn, "keep-alive probe could not be sent; retrying at the next interval", "entity", r.getAddress(), "error", err)
+			continue
+		}
+
+		span, spanCtx := r.startConsumerSpanFromContext(ctx, "eh.receiver.monitorConnection")
+		tab.For(spanCtx).Error(fmt.Errorf("connection failed keep-alive probe and will be recycled: %w", err))
+		r.hub.log(spanCtx, LogLevelWarn, "connection failed keep-alive probe; recycling connection", "entity", r.getAddress(), "error", err)
+		r.hub.reportError(ErrorEventKeepAlive, r.getAddress(), err)
+		// closing the connection, for every link using it, fails the pending receive, which starts the usual link
+		// recovery
+		_ = r.hub.namespace.failConnection(conn)
+		span.End()
+		r.markActivity()
+	}
+}
+
+// markActivity records that the receiver's connection has shown signs of life
+func (r *receiver) markActivity() {
+	atomic.StoreInt64(&r.lastActivity, time.Now().UnixNano())
+}
+
+func (r *receiver) lastActivityTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&r.lastActivity))
+}
+
+func (r *receiver) currentConnection() *amqp.Client {
+	r.connMu.RLock()
+	defer r.connMu.RUnlock()
+	return r.connection
+}
+
+// probeConnection sends a single Event Hub read request over conn and waits for any answer. Unlike other management
+// requests, the probe bypasses the runtime information cache, retries and the circuit breaker, as only the liveness of
+// this connection is of interest. The error is errConnectionUnresponsive if the probe timed out or the connection
+// failed under it.
+func (h *Hub) probeConnection(ctx context.Context, conn *amqp.Client) error {
+	if conn == nil {
+		return errors.New("no connection to probe")
+	}
+
+	c := newClient(h.namespace, h.name)
+	msg := &amqp.Message{
+		ApplicationProperties: map[string]interface{}{
+			operationKey:  readOperationKey,
+			entityTypeKey: eventHubEntityType,
+			entityNameKey: h.name,
+		},
+	}
+	msg, err := c.addSecurityToken(msg)
+	if err != nil {
+		return err
+	}
+
+	links := h.namespace.rpcLinks()
+	link, err := links.get(conn, address)
+	if err != nil {
+		return probeError(ctx, err)
+	}
+
+	// any response, even an error status, proves the peer is alive
+	if _, err := link.RPC(ctx, msg); err != nil {
+		_ = links.discard(conn, address, link)
+		return probeError(ctx, err)
+	}
+	return nil
+}
+
+// probeError wraps err in errConnectionUnresponsive if the probe timed out or err is a failure of the connection
+// rather than, for example, the management node rejecting the link
+func probeError(ctx context.Context, err error) error {
+	var netErr net.Error
+	if ctx.Err() != nil || errors.Is(err, amqp.ErrConnClosed) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %v", errConnectionUnresponsive, err)
+	}
+	return err
+}
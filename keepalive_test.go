@@ -0,0 +1,81 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-amqp-common-go/v3/auth"
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingTokenProvider struct {
+	requests int32
+}
+
+func (tp *failingTokenProvider) GetToken(string) (*auth.Token, error) {
+	atomic.AddInt32(&tp.requests, 1)
+	return nil, errors.New("token provider unavailable")
+}
+
+func TestHubWithKeepAlive(t *testing.T) {
+	h := new(Hub)
+	assert.Error(t, HubWithKeepAlive(0, time.Second)(h))
+	assert.Error(t, HubWithKeepAlive(time.Second, 0)(h))
+	require.NoError(t, HubWithKeepAlive(time.Minute, 10*time.Second)(h))
+	assert.Equal(t, time.Minute, h.keepAlive.interval)
+	assert.Equal(t, 10*time.Second, h.keepAlive.timeout)
+}
+
+func TestReceiver_MonitorConnectionSkipsActiveReceivers(t *testing.T) {
+	r := &receiver{hub: new(Hub)}
+	r.markActivity()
+	assert.WithinDuration(t, time.Now(), r.lastActivityTime(), time.Second)
+
+	// with no connection and recent activity, the monitor must neither probe nor panic before it's cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r.monitorConnection(ctx, &keepAliveOptions{interval: 10 * time.Millisecond, timeout: time.Millisecond})
+}
+
+func TestHub_ProbeConnectionRequiresConnection(t *testing.T) {
+	h := new(Hub)
+	assert.Error(t, h.probeConnection(context.Background(), nil))
+}
+
+func TestReceiver_MonitorConnectionKeepsConnectionWhenTheProbeIsNotSent(t *testing.T) {
+	pool, closed := newTestConnectionPool(t, 1)
+	tp := new(failingTokenProvider)
+	hub := &Hub{name: "hub", namespace: &namespace{name: "ns", pool: pool, tokenProvider: tp}}
+	r := &receiver{hub: hub, consumerGroup: DefaultConsumerGroup, partitionID: "0"}
+	conn, err := pool.acquire(r, "ns", func() (*amqp.Client, error) { return new(amqp.Client), nil })
+	require.NoError(t, err)
+	r.connection = conn
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r.monitorConnection(ctx, &keepAliveOptions{interval: 5 * time.Millisecond, timeout: 10 * time.Millisecond})
+
+	assert.Greater(t, atomic.LoadInt32(&tp.requests), int32(1), "the probe should be retried at each interval")
+	assert.Empty(t, closed, "a probe which could not be authorized says nothing of the connection")
+}
+
+func TestProbeError(t *testing.T) {
+	ctx := context.Background()
+	expired, cancel := context.WithTimeout(ctx, 0)
+	defer cancel()
+
+	assert.True(t, errors.Is(probeError(expired, context.DeadlineExceeded), errConnectionUnresponsive), "probes which time out should fail the connection")
+	assert.True(t, errors.Is(probeError(ctx, amqp.ErrConnClosed), errConnectionUnresponsive))
+	assert.True(t, errors.Is(probeError(ctx, io.EOF), errConnectionUnresponsive))
+	assert.True(t, errors.Is(probeError(ctx, &net.OpError{Op: "read", Err: errors.New("connection reset")}), errConnectionUnresponsive))
+
+	rejected := &amqp.Error{Condition: amqp.ErrorUnauthorizedAccess, Description: "link attach rejected"}
+	assert.False(t, errors.Is(probeError(ctx, rejected), errConnectionUnresponsive), "a rejected link should not fail the connection")
+}
@@ -0,0 +1,178 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3/metrics"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+type (
+	// lagReporter periodically publishes how far the receivers of a Hub trail the partitions they receive from
+	lagReporter struct {
+		interval time.Duration
+		gauge    *metrics.GaugeVec
+
+		mu       sync.Mutex
+		cancel   context.CancelFunc
+		reported map[[2]string]bool
+	}
+)
+
+// HubWithLagReporting publishes, every interval, the number of events enqueued on each partition the Hub receives
+// from after the last event delivered to the handler, as the eventhub_consumer_delivered_lag_events gauge in
+// registry. Reporting starts with the first Receive and stops when the Hub is closed. Each report makes a management
+// request per partition, so the interval should be no shorter than a few seconds.
+func HubWithLagReporting(registry *metrics.Registry, interval time.Duration) HubOption {
+	return func(h *Hub) error {
+		if registry == nil {
+			return errors.New("metrics registry must not be nil")
+		}
+		if interval <= 0 {
+			return fmt.Errorf("lag reporting interval must be positive, got %v", interval)
+		}
+
+		h.lagReporter = &lagReporter{
+			interval: interval,
+			gauge:    registry.Gauge("eventhub_consumer_delivered_lag_events", "Events enqueued after the last event delivered to the handler.", "namespace", "hub", "consumer_group", "partition"),
+			reported: make(map[[2]string]bool),
+		}
+		return nil
+	}
+}
+
+// startLagReporter starts reporting the lag of the Hub's receivers, unless reporting is disabled or already running
+func (h *Hub) startLagReporter() {
+	lr := h.lagReporter
+	if lr == nil {
+		return
+	}
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if lr.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lr.cancel = cancel
+	go func() {
+		ticker := time.NewTicker(lr.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.reportLag(ctx)
+			}
+		}
+	}()
+}
+
+// stopLagReporter stops reporting; a later Receive starts it again
+func (h *Hub) stopLagReporter() {
+	lr := h.lagReporter
+	if lr == nil {
+		return
+	}
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if lr.cancel != nil {
+		lr.cancel()
+		lr.cancel = nil
+	}
+}
+
+// reportLag publishes the lag of each partition the Hub receives from, removing the gauges of partitions it no longer
+// receives from
+func (h *Hub) reportLag(ctx context.Context) {
+	span, ctx := h.startSpanFromContext(ctx, "eh.Hub.reportLag")
+	defer span.End()
+
+	h.receiverMu.Lock()
+	current := make(map[[2]string]bool, len(h.receivers))
+	for _, r := range h.receivers {
+		current[[2]string{r.consumerGroup, r.partitionID}] = true
+	}
+	h.receiverMu.Unlock()
+
+	lr := h.lagReporter
+	ns := ""
+	if h.namespace != nil {
+		ns = h.namespace.name
+	}
+
+	for key := range current {
+		consumerGroup, partitionID := key[0], key[1]
+		info, err := h.GetPartitionInformation(ctx, partitionID)
+		if err != nil {
+			tab.For(ctx).Error(err)
+			h.log(ctx, LogLevelWarn, "failed to fetch partition information for lag reporting", "partitionID", partitionID, "error", err)
+			continue
+		}
+
+		checkpoint, err := h.offsetPersister.Read(ns, h.name, consumerGroup, partitionID)
+		if err != nil {
+			// nothing has been delivered yet
+			checkpoint = persist.NewCheckpointFromStartOfStream()
+		}
+		lr.gauge.With(ns, h.name, consumerGroup, partitionID).Set(float64(deliveredLag(info, checkpoint)))
+	}
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for key := range lr.reported {
+		if !current[key] {
+			lr.gauge.Delete(ns, h.name, key[0], key[1])
+		}
+	}
+	lr.reported = current
+}
+
+// deliveredLag is the number of events enqueued on the partition after the checkpoint
+func deliveredLag(info *HubPartitionRuntimeInformation, checkpoint persist.Checkpoint) int64 {
+	var lag int64
+	switch checkpoint.Offset {
+	case persist.EndOfStream:
+		return 0
+	case persist.StartOfStream:
+		lag = info.LastSequenceNumber - info.BeginningSequenceNumber + 1
+	default:
+		lag = info.LastSequenceNumber - checkpoint.SequenceNumber
+	}
+
+	if lag < 0 {
+		return 0
+	}
+	return lag
+}
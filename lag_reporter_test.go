@@ -0,0 +1,38 @@
+package eventhub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/metrics"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+func TestDeliveredLag(t *testing.T) {
+	info := &HubPartitionRuntimeInformation{BeginningSequenceNumber: 10, LastSequenceNumber: 49}
+
+	assert.Equal(t, int64(40), deliveredLag(info, persist.NewCheckpointFromStartOfStream()))
+	assert.Equal(t, int64(0), deliveredLag(info, persist.NewCheckpointFromEndOfStream()))
+	assert.Equal(t, int64(9), deliveredLag(info, persist.NewCheckpoint("1234", 40, time.Now())))
+	assert.Equal(t, int64(0), deliveredLag(info, persist.NewCheckpoint("1234", 60, time.Now())), "lag is never negative")
+}
+
+func TestHubWithLagReporting(t *testing.T) {
+	h := new(Hub)
+	assert.Error(t, HubWithLagReporting(nil, time.Second)(h))
+	assert.Error(t, HubWithLagReporting(metrics.NewRegistry(), 0)(h))
+	require.NoError(t, HubWithLagReporting(metrics.NewRegistry(), time.Minute)(h))
+	require.NotNil(t, h.lagReporter)
+
+	h.startLagReporter()
+	h.startLagReporter()
+	assert.NotNil(t, h.lagReporter.cancel)
+	h.stopLagReporter()
+	assert.Nil(t, h.lagReporter.cancel)
+
+	// reporting is off by default
+	new(Hub).startLagReporter()
+}
@@ -0,0 +1,127 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Azure/go-amqp"
+)
+
+// Connection lifecycle event types
+const (
+	// ConnectionEventConnected reports that a connection to the namespace was established
+	ConnectionEventConnected ConnectionEventType = "connected"
+	// ConnectionEventAuthenticated reports that a claim for an entity was accepted by the service
+	ConnectionEventAuthenticated ConnectionEventType = "authenticated"
+	// ConnectionEventLinkAttached reports that a sender or receiver link was attached
+	ConnectionEventLinkAttached ConnectionEventType = "link-attached"
+	// ConnectionEventLinkDetached reports that a sender or receiver link was closed or failed
+	ConnectionEventLinkDetached ConnectionEventType = "link-detached"
+	// ConnectionEventReconnecting reports an attempt to recover a failed sender or receiver
+	ConnectionEventReconnecting ConnectionEventType = "reconnecting"
+	// ConnectionEventFailed reports that a sender or receiver gave up recovering and is no longer usable
+	ConnectionEventFailed ConnectionEventType = "failed"
+	// ConnectionEventFailover reports that the Geo-DR alias of the namespace failed over to the host of the event
+	ConnectionEventFailover ConnectionEventType = "failover"
+)
+
+type (
+	// ConnectionEventType identifies a transition in the life of a connection or link
+	ConnectionEventType string
+
+	// ConnectionEvent describes a change in the health of the Hub's transport
+	ConnectionEvent struct {
+		Type ConnectionEventType
+		// Host is the namespace host the connection is to
+		Host string
+		// Entity is the address of the entity the claim or link is for; empty for connection events
+		Entity string
+		// Attempt is the 1-based recovery attempt of reconnecting events
+		Attempt int
+		// Err is the cause of detached, reconnecting and failed events, if known
+		Err error
+	}
+
+	// ConnectionListener is called as connections and links of a Hub change state. It is called synchronously, so it
+	// must not block.
+	ConnectionListener func(event ConnectionEvent)
+)
+
+// HubWithConnectionListener configures the Hub to report connection and link lifecycle events to the listener, so
+// applications can log and alert on transport health
+func HubWithConnectionListener(listener ConnectionListener) HubOption {
+	return func(h *Hub) error {
+		if listener == nil {
+			return errors.New("connection listener must not be nil")
+		}
+		h.namespace.connectionListener = listener
+		return nil
+	}
+}
+
+// notifyConnection records a lifecycle event in the metrics and reports it to the configured listener, if any
+func (ns *namespace) notifyConnection(event ConnectionEvent) {
+	ns.metrics.observeConnection(event)
+	if ns.connectionListener == nil {
+		return
+	}
+
+	if event.Host == "" {
+		event.Host = strings.TrimPrefix(ns.host, "amqps://")
+	}
+	ns.connectionListener(event)
+}
+
+// connect dials a new connection to the namespace and reports it
+func (ns *namespace) connect() (*amqp.Client, error) {
+	client, err := ns.newConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	ns.notifyConnection(ConnectionEvent{Type: ConnectionEventConnected})
+	if ns.geoDR != nil {
+		ns.geoDR.start()
+	}
+	return client, nil
+}
+
+// connectionEventFromRecovery translates recovery progress into a lifecycle event. Successful recoveries are reported
+// by the link attach itself and giving up because the sender or receiver closed is not a failure, so neither produces
+// an event.
+func connectionEventFromRecovery(event RecoveryEvent) (ConnectionEvent, bool) {
+	switch {
+	case event.Recovered:
+		return ConnectionEvent{}, false
+	case event.GaveUp:
+		if errors.Is(event.Err, context.Canceled) {
+			return ConnectionEvent{}, false
+		}
+		return ConnectionEvent{Type: ConnectionEventFailed, Entity: event.Entity, Attempt: event.Attempt, Err: event.Err}, true
+	default:
+		return ConnectionEvent{Type: ConnectionEventReconnecting, Entity: event.Entity, Attempt: event.Attempt, Err: event.Err}, true
+	}
+}
@@ -0,0 +1,53 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_ConnectionListener(t *testing.T) {
+	var events []ConnectionEvent
+	h := &Hub{namespace: &namespace{host: "amqps://ns.servicebus.windows.net"}, recoveryOptions: newRecoveryOptions()}
+	assert.Error(t, HubWithConnectionListener(nil)(h))
+	require.NoError(t, HubWithConnectionListener(func(event ConnectionEvent) {
+		events = append(events, event)
+	})(h))
+
+	boom := errors.New("boom")
+	h.notifyRecovery(RecoveryEvent{Entity: "hub/ConsumerGroups/$Default/Partitions/0", Attempt: 1, Err: boom})
+	h.notifyRecovery(RecoveryEvent{Entity: "hub/ConsumerGroups/$Default/Partitions/0", Attempt: 1, Recovered: true})
+	h.notifyRecovery(RecoveryEvent{Entity: "hub/ConsumerGroups/$Default/Partitions/0", Attempt: 2, Err: context.Canceled, GaveUp: true})
+	h.notifyRecovery(RecoveryEvent{Entity: "hub/ConsumerGroups/$Default/Partitions/0", Attempt: 10, Err: boom, GaveUp: true})
+
+	require.Len(t, events, 2)
+	assert.Equal(t, ConnectionEventReconnecting, events[0].Type)
+	assert.Equal(t, "ns.servicebus.windows.net", events[0].Host)
+	assert.Equal(t, boom, events[0].Err)
+	assert.Equal(t, ConnectionEventFailed, events[1].Type)
+	assert.Equal(t, 10, events[1].Attempt)
+}
+
+func TestReceiver_CloseReportsLinkDetached(t *testing.T) {
+	var events []ConnectionEvent
+	ns := &namespace{
+		transport:          new(memoryTransport),
+		connectionListener: func(event ConnectionEvent) { events = append(events, event) },
+	}
+
+	s, err := ns.amqpTransport().newSession(nil)
+	require.NoError(t, err)
+	link, err := s.NewReceiver()
+	require.NoError(t, err)
+	sess, err := newSession(s)
+	require.NoError(t, err)
+
+	r := &receiver{hub: &Hub{name: "hub", namespace: ns}, session: sess, receiver: link, consumerGroup: DefaultConsumerGroup, partitionID: "0"}
+	require.NoError(t, r.Close(context.Background()))
+	require.Len(t, events, 1)
+	assert.Equal(t, ConnectionEventLinkDetached, events[0].Type)
+	assert.Equal(t, r.getAddress(), events[0].Entity)
+}
@@ -0,0 +1,186 @@
+package loadtest
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+type (
+	// ConsumerOption configures Consume
+	ConsumerOption func(c *consumer) error
+
+	consumer struct {
+		events         int64
+		duration       time.Duration
+		receiveOptions []eventhub.ReceiveOption
+	}
+
+	// subscribeFunc starts receiving the events of a partition, returning what stops it
+	subscribeFunc func(ctx context.Context, partitionID string, handler eventhub.Handler, opts ...eventhub.ReceiveOption) (closer, error)
+
+	closer interface {
+		Close(ctx context.Context) error
+	}
+)
+
+// ConsumeWithEvents stops the run once count events were received
+func ConsumeWithEvents(count int) ConsumerOption {
+	return func(c *consumer) error {
+		if count < 1 {
+			return fmt.Errorf("event count must be at least 1, got %d", count)
+		}
+		c.events = int64(count)
+		return nil
+	}
+}
+
+// ConsumeWithDuration stops the run after d
+func ConsumeWithDuration(d time.Duration) ConsumerOption {
+	return func(c *consumer) error {
+		if d <= 0 {
+			return fmt.Errorf("duration must be positive, got %v", d)
+		}
+		c.duration = d
+		return nil
+	}
+}
+
+// ConsumeWithReceiveOptions passes opts to the receivers of the partitions. Receivers start at the latest offset
+// unless opts say otherwise.
+func ConsumeWithReceiveOptions(opts ...eventhub.ReceiveOption) ConsumerOption {
+	return func(c *consumer) error {
+		c.receiveOptions = append(c.receiveOptions, opts...)
+		return nil
+	}
+}
+
+// Consume receives the events of partitionIDs from receiver until the configured number of events were received, the
+// configured duration elapsed or ctx is done, whichever comes first; at least one of them must bound the run. Start
+// Consume before Produce to measure the latency of the events Produce sends.
+func Consume(ctx context.Context, receiver eventhub.PartitionedReceiver, partitionIDs []string, opts ...ConsumerOption) (*Result, error) {
+	subscribe := func(ctx context.Context, partitionID string, handler eventhub.Handler, opts ...eventhub.ReceiveOption) (closer, error) {
+		handle, err := receiver.Receive(ctx, partitionID, handler, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return handle, nil
+	}
+	return consume(ctx, subscribe, partitionIDs, opts...)
+}
+
+func consume(ctx context.Context, subscribe subscribeFunc, partitionIDs []string, opts ...ConsumerOption) (*Result, error) {
+	c := new(consumer)
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(partitionIDs) == 0 {
+		return nil, errors.New("at least one partition is required")
+	}
+	if _, ok := ctx.Deadline(); !ok && c.events == 0 && c.duration == 0 {
+		return nil, errors.New("a number of events, a duration or a context deadline is required to bound the run")
+	}
+
+	// the run is timed from before its deadline is set, so it never reports less than its duration
+	start := time.Now()
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if c.duration > 0 {
+		runCtx, cancel = context.WithTimeout(runCtx, c.duration)
+		defer cancel()
+	}
+
+	var (
+		events     int64
+		bytes      int64
+		mu         sync.Mutex
+		partitions = make(map[string]int64, len(partitionIDs))
+		latency    = newLatencyRecorder()
+		doneOnce   sync.Once
+	)
+
+	handler := func(partitionID string) eventhub.Handler {
+		return func(_ context.Context, event *eventhub.Event) error {
+			if sentAt, ok := event.Properties[SentAtProperty].(int64); ok {
+				latency.record(time.Since(time.Unix(0, sentAt)))
+			}
+			atomic.AddInt64(&bytes, int64(len(event.Data)))
+
+			mu.Lock()
+			partitions[partitionID]++
+			mu.Unlock()
+
+			if n := atomic.AddInt64(&events, 1); c.events > 0 && n >= c.events {
+				doneOnce.Do(cancel)
+			}
+			return nil
+		}
+	}
+
+	receiveOptions := append([]eventhub.ReceiveOption{eventhub.ReceiveWithLatestOffset()}, c.receiveOptions...)
+	var listeners []closer
+	closeAll := func() {
+		closeCtx, closeCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer closeCancel()
+		for _, l := range listeners {
+			_ = l.Close(closeCtx)
+		}
+	}
+
+	for _, partitionID := range partitionIDs {
+		l, err := subscribe(runCtx, partitionID, handler(partitionID), receiveOptions...)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+
+	<-runCtx.Done()
+	elapsed := time.Since(start)
+	closeAll()
+
+	result := &Result{
+		Events:     atomic.LoadInt64(&events),
+		Bytes:      atomic.LoadInt64(&bytes),
+		Elapsed:    elapsed,
+		Latency:    latency.summary(),
+		Partitions: make(map[string]int64, len(partitions)),
+	}
+	mu.Lock()
+	for partitionID, count := range partitions {
+		result.Partitions[partitionID] = count
+	}
+	mu.Unlock()
+	return result, nil
+}
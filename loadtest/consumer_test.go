@@ -0,0 +1,103 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+type fakeListener struct {
+	stop   chan struct{}
+	once   sync.Once
+	closed bool
+}
+
+func (l *fakeListener) Close(context.Context) error {
+	l.once.Do(func() { close(l.stop) })
+	l.closed = true
+	return nil
+}
+
+// fakeSubscriptions delivers events to each partition until its listener is closed
+type fakeSubscriptions struct {
+	mu        sync.Mutex
+	listeners []*fakeListener
+	fail      string
+}
+
+func (f *fakeSubscriptions) subscribe(_ context.Context, partitionID string, handler eventhub.Handler, _ ...eventhub.ReceiveOption) (closer, error) {
+	if partitionID == f.fail {
+		return nil, errors.New("partition not found")
+	}
+
+	l := &fakeListener{stop: make(chan struct{})}
+	f.mu.Lock()
+	f.listeners = append(f.listeners, l)
+	f.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-l.stop:
+				return
+			default:
+			}
+			event := eventhub.NewEventFromString("hello")
+			event.Set(SentAtProperty, time.Now().Add(-time.Millisecond).UnixNano())
+			_ = handler(context.Background(), event)
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+	return l, nil
+}
+
+func TestConsume_Events(t *testing.T) {
+	subs := new(fakeSubscriptions)
+	result, err := consume(context.Background(), subs.subscribe, []string{"0", "1"}, ConsumeWithEvents(50))
+	require.NoError(t, err)
+
+	assert.True(t, result.Events >= 50)
+	assert.Equal(t, 5*result.Events, result.Bytes)
+	assert.Equal(t, result.Events, result.Latency.Count)
+	assert.True(t, result.Latency.Min >= time.Millisecond)
+	assert.Len(t, result.Partitions, 2)
+	for _, l := range subs.listeners {
+		assert.True(t, l.closed)
+	}
+}
+
+func TestConsume_Duration(t *testing.T) {
+	subs := new(fakeSubscriptions)
+	result, err := consume(context.Background(), subs.subscribe, []string{"0"}, ConsumeWithDuration(20*time.Millisecond))
+	require.NoError(t, err)
+
+	assert.True(t, result.Elapsed >= 20*time.Millisecond)
+	assert.True(t, result.Events > 0)
+}
+
+func TestConsume_ReceiveFailureClosesListeners(t *testing.T) {
+	subs := &fakeSubscriptions{fail: "1"}
+	_, err := consume(context.Background(), subs.subscribe, []string{"0", "1"}, ConsumeWithEvents(1))
+	assert.Error(t, err)
+	require.Len(t, subs.listeners, 1)
+	assert.True(t, subs.listeners[0].closed)
+}
+
+func TestConsume_Validation(t *testing.T) {
+	subs := new(fakeSubscriptions)
+	_, err := consume(context.Background(), subs.subscribe, []string{"0"})
+	assert.Error(t, err, "an unbounded run is refused")
+	_, err = consume(context.Background(), subs.subscribe, nil, ConsumeWithEvents(1))
+	assert.Error(t, err)
+	_, err = consume(context.Background(), subs.subscribe, []string{"0"}, ConsumeWithEvents(0))
+	assert.Error(t, err)
+	_, err = consume(context.Background(), subs.subscribe, []string{"0"}, ConsumeWithDuration(0))
+	assert.Error(t, err)
+}
@@ -0,0 +1,313 @@
+// Package loadtest generates load against an Event Hub and measures it, to quantify performance regressions of the
+// client and to size deployments.
+//
+// Produce sends events of a given size at a given rate, one at a time or in batches, from several goroutines at once,
+// spread over partition keys. Consume receives from a set of partitions. Both report a Result with the number of events
+// and bytes, the rates and a latency summary: the duration of sends for producers, and the time from send to receipt
+// for consumers of events sent by Produce.
+//
+//	hub, err := eventhub.NewHubFromEnvironment(eventhub.HubWithSenderLinks(4))
+//	result, err := loadtest.Produce(ctx, hub,
+//		loadtest.ProduceWithEventSize(2048),
+//		loadtest.ProduceWithRate(5000),
+//		loadtest.ProduceWithDuration(time.Minute),
+//		loadtest.ProduceWithConcurrency(8),
+//		loadtest.ProduceWithPartitionKeys(32))
+//	fmt.Println(result)
+package loadtest
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+const (
+	// SentAtProperty is the application property holding the time Produce sent an event, in nanoseconds since the Unix
+	// epoch, which Consume measures the latency of events with
+	SentAtProperty = "loadtest-sent-at"
+
+	defaultEventSize = 1024
+)
+
+type (
+	// ProducerOption configures Produce
+	ProducerOption func(p *producer) error
+
+	producer struct {
+		eventSize     int
+		rate          float64
+		events        int64
+		duration      time.Duration
+		concurrency   int
+		batchSize     int
+		partitionKeys int
+		sendOptions   []eventhub.SendOption
+
+		body    []byte
+		claimed int64
+		pace    *pacer
+	}
+
+	// pacer spaces events evenly at a rate, across the goroutines sharing it
+	pacer struct {
+		start    time.Time
+		interval time.Duration
+		next     int64
+	}
+)
+
+// ProduceWithEventSize configures the size of the body of each event, 1024 bytes by default
+func ProduceWithEventSize(size int) ProducerOption {
+	return func(p *producer) error {
+		if size < 0 {
+			return fmt.Errorf("event size must not be negative, got %d", size)
+		}
+		p.eventSize = size
+		return nil
+	}
+}
+
+// ProduceWithRate caps the number of events sent per second, across all goroutines. By default events are sent as
+// fast as the hub accepts them.
+func ProduceWithRate(eventsPerSecond float64) ProducerOption {
+	return func(p *producer) error {
+		if eventsPerSecond <= 0 {
+			return fmt.Errorf("rate must be positive, got %v", eventsPerSecond)
+		}
+		p.rate = eventsPerSecond
+		return nil
+	}
+}
+
+// ProduceWithEvents stops the run once count events were sent
+func ProduceWithEvents(count int) ProducerOption {
+	return func(p *producer) error {
+		if count < 1 {
+			return fmt.Errorf("event count must be at least 1, got %d", count)
+		}
+		p.events = int64(count)
+		return nil
+	}
+}
+
+// ProduceWithDuration stops the run after d
+func ProduceWithDuration(d time.Duration) ProducerOption {
+	return func(p *producer) error {
+		if d <= 0 {
+			return fmt.Errorf("duration must be positive, got %v", d)
+		}
+		p.duration = d
+		return nil
+	}
+}
+
+// ProduceWithConcurrency configures the number of goroutines sending, 1 by default
+func ProduceWithConcurrency(concurrency int) ProducerOption {
+	return func(p *producer) error {
+		if concurrency < 1 {
+			return fmt.Errorf("concurrency must be at least 1, got %d", concurrency)
+		}
+		p.concurrency = concurrency
+		return nil
+	}
+}
+
+// ProduceWithBatchSize sends events in batches of size with SendBatch rather than one at a time with Send
+func ProduceWithBatchSize(size int) ProducerOption {
+	return func(p *producer) error {
+		if size < 1 {
+			return fmt.Errorf("batch size must be at least 1, got %d", size)
+		}
+		p.batchSize = size
+		return nil
+	}
+}
+
+// ProduceWithPartitionKeys spreads the events over count partition keys, and so over the partitions of the hub. By
+// default events have no partition key, and the service spreads them over the partitions.
+func ProduceWithPartitionKeys(count int) ProducerOption {
+	return func(p *producer) error {
+		if count < 1 {
+			return fmt.Errorf("partition keys must be at least 1, got %d", count)
+		}
+		p.partitionKeys = count
+		return nil
+	}
+}
+
+// ProduceWithSendOptions passes opts to the sends of single events
+func ProduceWithSendOptions(opts ...eventhub.SendOption) ProducerOption {
+	return func(p *producer) error {
+		p.sendOptions = append(p.sendOptions, opts...)
+		return nil
+	}
+}
+
+// Produce sends events to sender until the configured number of events were sent, the configured duration elapsed or
+// ctx is done, whichever comes first; at least one of them must bound the run. Failed sends are counted in the
+// result and don't stop the run.
+func Produce(ctx context.Context, sender eventhub.Sender, opts ...ProducerOption) (*Result, error) {
+	p := &producer{
+		eventSize:   defaultEventSize,
+		concurrency: 1,
+		batchSize:   1,
+	}
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := ctx.Deadline(); !ok && p.events == 0 && p.duration == 0 {
+		return nil, errors.New("a number of events, a duration or a context deadline is required to bound the run")
+	}
+
+	// the run is timed from before its deadline is set, so it never reports less than its duration
+	start := time.Now()
+	runCtx := ctx
+	if p.duration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, p.duration)
+		defer cancel()
+	}
+
+	p.body = make([]byte, p.eventSize)
+	for i := range p.body {
+		p.body[i] = byte('a' + i%26)
+	}
+
+	if p.rate > 0 {
+		p.pace = &pacer{start: start, interval: time.Duration(float64(time.Second) / p.rate)}
+	}
+
+	result := new(Result)
+	latency := newLatencyRecorder()
+	var errOnce sync.Once
+	wg := new(sync.WaitGroup)
+	for i := 0; i < p.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				first, count := p.claim()
+				if count == 0 {
+					return
+				}
+				if p.pace != nil {
+					if err := p.pace.wait(runCtx, count); err != nil {
+						return
+					}
+				}
+				if runCtx.Err() != nil {
+					return
+				}
+
+				sendStart := time.Now()
+				err := p.send(runCtx, sender, first, count)
+				if err != nil {
+					if runCtx.Err() != nil && ctx.Err() == nil {
+						// the run ended during the send
+						return
+					}
+					atomic.AddInt64(&result.Errors, 1)
+					errOnce.Do(func() { result.Err = err })
+					continue
+				}
+				latency.record(time.Since(sendStart))
+				atomic.AddInt64(&result.Events, int64(count))
+				atomic.AddInt64(&result.Bytes, int64(count*p.eventSize))
+			}
+		}()
+	}
+	wg.Wait()
+
+	result.Elapsed = time.Since(start)
+	result.Latency = latency.summary()
+	return result, nil
+}
+
+// claim reserves the next events to send, returning the sequence number of the first one and how many there are
+func (p *producer) claim() (int64, int) {
+	count := int64(p.batchSize)
+	last := atomic.AddInt64(&p.claimed, count)
+	first := last - count
+	if p.events > 0 {
+		if first >= p.events {
+			return 0, 0
+		}
+		if last > p.events {
+			count = p.events - first
+		}
+	}
+	return first, int(count)
+}
+
+func (p *producer) send(ctx context.Context, sender eventhub.Sender, first int64, count int) error {
+	if count == 1 && p.batchSize == 1 {
+		return sender.Send(ctx, p.event(first), p.sendOptions...)
+	}
+
+	events := make([]*eventhub.Event, count)
+	for i := range events {
+		events[i] = p.event(first + int64(i))
+	}
+	return sender.SendBatch(ctx, eventhub.NewEventBatchIterator(events...))
+}
+
+// event builds the event with sequence number n of the run
+func (p *producer) event(n int64) *eventhub.Event {
+	event := eventhub.NewEvent(p.body)
+	event.Set(SentAtProperty, time.Now().UnixNano())
+	if p.partitionKeys > 0 {
+		key := strconv.FormatInt(n%int64(p.partitionKeys), 10)
+		event.PartitionKey = &key
+	}
+	return event
+}
+
+// wait blocks until the next count events are due
+func (p *pacer) wait(ctx context.Context, count int) error {
+	n := atomic.AddInt64(&p.next, int64(count)) - int64(count)
+	delay := time.Until(p.start.Add(time.Duration(n) * p.interval))
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+type fakeSender struct {
+	mu      sync.Mutex
+	events  []*eventhub.Event
+	batches int
+	fail    func(n int) error
+	delay   time.Duration
+}
+
+func (s *fakeSender) Send(ctx context.Context, event *eventhub.Event, _ ...eventhub.SendOption) error {
+	return s.add(ctx, event)
+}
+
+func (s *fakeSender) SendBatch(ctx context.Context, iterator eventhub.BatchIterator, _ ...eventhub.BatchOption) error {
+	ebi := iterator.(*eventhub.EventBatchIterator)
+	var events []*eventhub.Event
+	for _, partitionEvents := range ebi.PartitionEventsMap {
+		events = append(events, partitionEvents...)
+	}
+
+	s.mu.Lock()
+	s.batches++
+	s.mu.Unlock()
+	return s.add(ctx, events...)
+}
+
+func (s *fakeSender) add(ctx context.Context, events ...*eventhub.Event) error {
+	if s.delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.delay):
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail != nil {
+		if err := s.fail(len(s.events)); err != nil {
+			return err
+		}
+	}
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func TestProduce_Events(t *testing.T) {
+	sender := new(fakeSender)
+	result, err := Produce(context.Background(), sender,
+		ProduceWithEvents(100),
+		ProduceWithEventSize(64),
+		ProduceWithConcurrency(4),
+		ProduceWithPartitionKeys(3))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(100), result.Events)
+	assert.Equal(t, int64(6400), result.Bytes)
+	assert.Equal(t, int64(0), result.Errors)
+	assert.Equal(t, int64(100), result.Latency.Count)
+	require.Len(t, sender.events, 100)
+
+	keys := make(map[string]bool)
+	for _, event := range sender.events {
+		assert.Len(t, event.Data, 64)
+		assert.IsType(t, int64(0), event.Properties[SentAtProperty])
+		require.NotNil(t, event.PartitionKey)
+		keys[*event.PartitionKey] = true
+	}
+	assert.Len(t, keys, 3)
+}
+
+func TestProduce_Batches(t *testing.T) {
+	sender := new(fakeSender)
+	result, err := Produce(context.Background(), sender, ProduceWithEvents(25), ProduceWithBatchSize(10))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(25), result.Events)
+	assert.Equal(t, 3, sender.batches)
+	assert.Len(t, sender.events, 25)
+}
+
+func TestProduce_Rate(t *testing.T) {
+	sender := new(fakeSender)
+	start := time.Now()
+	result, err := Produce(context.Background(), sender, ProduceWithEvents(11), ProduceWithRate(100), ProduceWithConcurrency(2))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(11), result.Events)
+	assert.True(t, time.Since(start) >= 100*time.Millisecond, "11 events at 100/s take at least 100ms")
+}
+
+func TestProduce_Duration(t *testing.T) {
+	sender := &fakeSender{delay: time.Millisecond}
+	result, err := Produce(context.Background(), sender, ProduceWithDuration(50*time.Millisecond))
+	require.NoError(t, err)
+
+	assert.True(t, result.Events > 0)
+	assert.Equal(t, int64(0), result.Errors, "sends cut short by the end of the run are not failures")
+	assert.True(t, result.Elapsed >= 50*time.Millisecond)
+}
+
+func TestProduce_Failures(t *testing.T) {
+	failure := errors.New("server busy")
+	sender := &fakeSender{fail: func(n int) error {
+		if n == 5 {
+			return failure
+		}
+		return nil
+	}}
+	result, err := Produce(context.Background(), sender, ProduceWithEvents(20))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(5), result.Events)
+	assert.Equal(t, int64(15), result.Errors)
+	assert.Equal(t, failure, result.Err)
+}
+
+func TestProduce_Validation(t *testing.T) {
+	_, err := Produce(context.Background(), new(fakeSender))
+	assert.Error(t, err, "an unbounded run is refused")
+
+	for _, opt := range []ProducerOption{
+		ProduceWithEventSize(-1),
+		ProduceWithRate(0),
+		ProduceWithEvents(0),
+		ProduceWithDuration(0),
+		ProduceWithConcurrency(0),
+		ProduceWithBatchSize(0),
+		ProduceWithPartitionKeys(0),
+	} {
+		_, err := Produce(context.Background(), new(fakeSender), ProduceWithEvents(1), opt)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,173 @@
+package loadtest
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencySamples is the number of latencies a run keeps to estimate percentiles from
+const latencySamples = 10000
+
+type (
+	// Result is the outcome of a producer or consumer run
+	Result struct {
+		// Events is the number of events sent or received
+		Events int64
+		// Bytes is the size of the bodies of the events sent or received
+		Bytes int64
+		// Errors is the number of sends which failed
+		Errors int64
+		// Err is the error of the first send which failed
+		Err error
+		// Elapsed is the duration of the run
+		Elapsed time.Duration
+		// Latency summarizes the duration of sends for producers, and the time from send to receipt for consumers
+		Latency LatencySummary
+		// Partitions is the number of events received from each partition, for consumers
+		Partitions map[string]int64
+	}
+
+	// LatencySummary summarizes the latencies of a run. Percentiles are estimated from a uniform sample of the
+	// latencies for long runs.
+	LatencySummary struct {
+		Count int64
+		Min   time.Duration
+		Mean  time.Duration
+		P50   time.Duration
+		P90   time.Duration
+		P99   time.Duration
+		Max   time.Duration
+	}
+
+	// latencyRecorder keeps the count, sum and bounds of latencies, and a reservoir sample of them for percentiles
+	latencyRecorder struct {
+		mu      sync.Mutex
+		count   int64
+		sum     time.Duration
+		min     time.Duration
+		max     time.Duration
+		samples []time.Duration
+		rand    *rand.Rand
+	}
+)
+
+// EventsPerSecond returns the rate of events over the run
+func (r *Result) EventsPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Events) / r.Elapsed.Seconds()
+}
+
+// BytesPerSecond returns the rate of body bytes over the run
+func (r *Result) BytesPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Elapsed.Seconds()
+}
+
+// String reports the result on a single line
+func (r *Result) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "events=%d bytes=%d errors=%d elapsed=%v rate=%.1f/s throughput=%.2fMiB/s",
+		r.Events, r.Bytes, r.Errors, r.Elapsed.Round(time.Millisecond), r.EventsPerSecond(), r.BytesPerSecond()/(1<<20))
+	if r.Latency.Count > 0 {
+		fmt.Fprintf(&sb, " latency min=%v mean=%v p50=%v p90=%v p99=%v max=%v",
+			r.Latency.Min, r.Latency.Mean, r.Latency.P50, r.Latency.P90, r.Latency.P99, r.Latency.Max)
+	}
+	if len(r.Partitions) > 0 {
+		ids := make([]string, 0, len(r.Partitions))
+		for id := range r.Partitions {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		sb.WriteString(" partitions=")
+		for i, id := range ids {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			fmt.Fprintf(&sb, "%s:%d", id, r.Partitions[id])
+		}
+	}
+	return sb.String()
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (l *latencyRecorder) record(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.count++
+	l.sum += d
+	if l.count == 1 || d < l.min {
+		l.min = d
+	}
+	if d > l.max {
+		l.max = d
+	}
+
+	if len(l.samples) < latencySamples {
+		l.samples = append(l.samples, d)
+		return
+	}
+	if i := l.rand.Int63n(l.count); i < latencySamples {
+		l.samples[i] = d
+	}
+}
+
+func (l *latencyRecorder) summary() LatencySummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.count == 0 {
+		return LatencySummary{}
+	}
+
+	sorted := append([]time.Duration(nil), l.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+
+	return LatencySummary{
+		Count: l.count,
+		Min:   l.min,
+		Mean:  l.sum / time.Duration(l.count),
+		P50:   percentile(.5),
+		P90:   percentile(.9),
+		P99:   percentile(.99),
+		Max:   l.max,
+	}
+}
@@ -0,0 +1,50 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyRecorder(t *testing.T) {
+	l := newLatencyRecorder()
+	assert.Equal(t, LatencySummary{}, l.summary())
+
+	for i := 1; i <= 100; i++ {
+		l.record(time.Duration(i) * time.Millisecond)
+	}
+	s := l.summary()
+	assert.Equal(t, int64(100), s.Count)
+	assert.Equal(t, time.Millisecond, s.Min)
+	assert.Equal(t, 100*time.Millisecond, s.Max)
+	assert.Equal(t, 50500*time.Microsecond, s.Mean)
+	assert.Equal(t, 50*time.Millisecond, s.P50)
+	assert.Equal(t, 90*time.Millisecond, s.P90)
+	assert.Equal(t, 99*time.Millisecond, s.P99)
+}
+
+func TestLatencyRecorder_SamplesLongRuns(t *testing.T) {
+	l := newLatencyRecorder()
+	for i := 0; i < 3*latencySamples; i++ {
+		l.record(time.Duration(i%100) * time.Millisecond)
+	}
+	s := l.summary()
+	assert.Equal(t, int64(3*latencySamples), s.Count)
+	assert.Len(t, l.samples, latencySamples)
+	assert.InDelta(t, float64(50*time.Millisecond), float64(s.P50), float64(5*time.Millisecond))
+}
+
+func TestResult_String(t *testing.T) {
+	r := &Result{
+		Events:     2000,
+		Bytes:      2 << 20,
+		Elapsed:    2 * time.Second,
+		Partitions: map[string]int64{"1": 1200, "0": 800},
+		Latency:    LatencySummary{Count: 1, Min: time.Millisecond, Mean: time.Millisecond, P50: time.Millisecond, P90: time.Millisecond, P99: time.Millisecond, Max: time.Millisecond},
+	}
+	assert.Equal(t, 1000.0, r.EventsPerSecond())
+	assert.Equal(t, float64(1<<20), r.BytesPerSecond())
+	assert.Equal(t, "events=2000 bytes=2097152 errors=0 elapsed=2s rate=1000.0/s throughput=1.00MiB/s latency min=1ms mean=1ms p50=1ms p90=1ms p99=1ms max=1ms partitions=0:800,1:1200", r.String())
+	assert.Equal(t, 0.0, new(Result).EventsPerSecond())
+}
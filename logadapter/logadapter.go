@@ -0,0 +1,102 @@
+// Package logadapter adapts log/slog, zap and logr loggers to eventhub.Logger, so the warnings and diagnostic
+// messages of the Event Hubs client go to the application's logging pipeline:
+//
+//	hub, err := eventhub.NewHubFromConnectionString(connStr, eventhub.HubWithLogger(logadapter.Slog(slog.Default())))
+package logadapter
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+// Slog returns a Logger which writes to logger
+func Slog(logger *slog.Logger) eventhub.Logger {
+	return eventhub.LoggerFunc(func(ctx context.Context, level eventhub.LogLevel, msg string, keysAndValues ...interface{}) {
+		logger.Log(ctx, slogLevel(level), msg, keysAndValues...)
+	})
+}
+
+// Zap returns a Logger which writes to logger
+func Zap(logger *zap.Logger) eventhub.Logger {
+	sugar := logger.WithOptions(zap.AddCallerSkip(1)).Sugar()
+	return eventhub.LoggerFunc(func(_ context.Context, level eventhub.LogLevel, msg string, keysAndValues ...interface{}) {
+		switch level {
+		case eventhub.LogLevelDebug:
+			sugar.Debugw(msg, keysAndValues...)
+		case eventhub.LogLevelInfo:
+			sugar.Infow(msg, keysAndValues...)
+		case eventhub.LogLevelWarn:
+			sugar.Warnw(msg, keysAndValues...)
+		default:
+			sugar.Errorw(msg, keysAndValues...)
+		}
+	})
+}
+
+// Logr returns a Logger which writes to logger. Debug entries are written at verbosity 1 and info and warning entries
+// at verbosity 0; error entries are written with logger.Error, passing the value of the "error" key as the error.
+func Logr(logger logr.Logger) eventhub.Logger {
+	return eventhub.LoggerFunc(func(_ context.Context, level eventhub.LogLevel, msg string, keysAndValues ...interface{}) {
+		switch level {
+		case eventhub.LogLevelDebug:
+			logger.V(1).Info(msg, keysAndValues...)
+		case eventhub.LogLevelInfo, eventhub.LogLevelWarn:
+			logger.Info(msg, keysAndValues...)
+		default:
+			err, rest := splitError(keysAndValues)
+			logger.Error(err, msg, rest...)
+		}
+	})
+}
+
+func slogLevel(level eventhub.LogLevel) slog.Level {
+	switch level {
+	case eventhub.LogLevelDebug:
+		return slog.LevelDebug
+	case eventhub.LogLevelInfo:
+		return slog.LevelInfo
+	case eventhub.LogLevelWarn:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
+
+// splitError removes the "error" key and its value from keysAndValues, returning the value if it is an error
+func splitError(keysAndValues []interface{}) (error, []interface{}) {
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if key, ok := keysAndValues[i].(string); ok && key == "error" {
+			if err, ok := keysAndValues[i+1].(error); ok {
+				rest := append(append([]interface{}{}, keysAndValues[:i]...), keysAndValues[i+2:]...)
+				return err, rest
+			}
+		}
+	}
+	return nil, keysAndValues
+}
@@ -0,0 +1,53 @@
+package logadapter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Slog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	logger.Log(context.Background(), eventhub.LogLevelDebug, "not written")
+	logger.Log(context.Background(), eventhub.LogLevelWarn, "send failed; retrying", "entity", "hub", "error", errors.New("busy"))
+	assert.NotContains(t, buf.String(), "not written")
+	assert.Contains(t, buf.String(), `level=WARN msg="send failed; retrying" entity=hub error=busy`)
+}
+
+func TestZap(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	logger := Zap(zap.New(core))
+
+	logger.Log(context.Background(), eventhub.LogLevelError, "gave up recovering link", "entity", "hub/Partitions/0")
+	entries := logs.All()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, zap.ErrorLevel, entries[0].Level)
+		assert.Equal(t, "hub/Partitions/0", entries[0].ContextMap()["entity"])
+	}
+}
+
+func TestLogr(t *testing.T) {
+	var lines []string
+	logger := Logr(funcr.New(func(prefix, args string) {
+		lines = append(lines, args)
+	}, funcr.Options{Verbosity: 0}))
+
+	logger.Log(context.Background(), eventhub.LogLevelDebug, "not written")
+	logger.Log(context.Background(), eventhub.LogLevelError, "failed to renew lease", "partitionID", "0", "error", errors.New("lost"))
+	if assert.Len(t, lines, 1) {
+		assert.Contains(t, lines[0], `"msg"="failed to renew lease"`)
+		assert.Contains(t, lines[0], `"error"="lost"`)
+		assert.Contains(t, lines[0], `"partitionID"="0"`)
+	}
+}
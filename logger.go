@@ -0,0 +1,93 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+)
+
+// Levels of log entries, from the least to the most severe
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+type (
+	// LogLevel is the severity of a log entry
+	LogLevel int
+
+	// Logger receives the warnings and diagnostic messages of the client, such as retried sends, link detaches and
+	// recoveries, and lease failures of the event processor host. KeysAndValues alternate between string keys and
+	// values, the convention of zap's SugaredLogger, logr and slog; error values are logged under the "error" key.
+	//
+	// Logger is called synchronously from the sending and receiving goroutines, so it must not block. Adapters for
+	// log/slog, zap and logr are available in the logadapter module.
+	Logger interface {
+		Log(ctx context.Context, level LogLevel, msg string, keysAndValues ...interface{})
+	}
+
+	// LoggerFunc is a function which implements Logger
+	LoggerFunc func(ctx context.Context, level LogLevel, msg string, keysAndValues ...interface{})
+)
+
+// Log calls f
+func (f LoggerFunc) Log(ctx context.Context, level LogLevel, msg string, keysAndValues ...interface{}) {
+	f(ctx, level, msg, keysAndValues...)
+}
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "debug"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelWarn:
+		return "warn"
+	case LogLevelError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// HubWithLogger sends the warnings and diagnostic messages of the Hub to logger. Without a logger they are only
+// recorded on trace spans.
+func HubWithLogger(logger Logger) HubOption {
+	return func(h *Hub) error {
+		if logger == nil {
+			return errors.New("logger must not be nil")
+		}
+		h.logger = logger
+		return nil
+	}
+}
+
+// log writes an entry about the Hub, labeled with the Event Hub's name, if the Hub has a logger
+func (h *Hub) log(ctx context.Context, level LogLevel, msg string, keysAndValues ...interface{}) {
+	if h == nil || h.logger == nil {
+		return
+	}
+	h.logger.Log(ctx, level, msg, append([]interface{}{"hub", h.name}, keysAndValues...)...)
+}
@@ -0,0 +1,42 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type logEntry struct {
+	level         LogLevel
+	msg           string
+	keysAndValues []interface{}
+}
+
+func TestHubWithLogger(t *testing.T) {
+	var entries []logEntry
+	logger := LoggerFunc(func(_ context.Context, level LogLevel, msg string, keysAndValues ...interface{}) {
+		entries = append(entries, logEntry{level: level, msg: msg, keysAndValues: keysAndValues})
+	})
+
+	h := &Hub{name: "hub"}
+	assert.Error(t, HubWithLogger(nil)(h))
+	require.NoError(t, HubWithLogger(logger)(h))
+
+	detached := errors.New("detached")
+	h.notifyRecovery(RecoveryEvent{Entity: "hub/Partitions/0", Attempt: 1, Err: detached})
+	h.notifyRecovery(RecoveryEvent{Entity: "hub/Partitions/0", Attempt: 1, Recovered: true})
+	h.notifyRecovery(RecoveryEvent{Entity: "hub/Partitions/0", Attempt: 3, Err: detached, GaveUp: true})
+
+	require.Len(t, entries, 3)
+	assert.Equal(t, LogLevelWarn, entries[0].level)
+	assert.Equal(t, LogLevelInfo, entries[1].level)
+	assert.Equal(t, LogLevelError, entries[2].level)
+	assert.Equal(t, []interface{}{"hub", "hub", "entity", "hub/Partitions/0", "attempt", 3, "error", detached}, entries[2].keysAndValues)
+
+	var quiet *Hub
+	assert.NotPanics(t, func() { quiet.log(context.Background(), LogLevelError, "dropped") })
+	assert.Equal(t, "warn", LogLevelWarn.String())
+}
@@ -0,0 +1,96 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// managementCircuitBreaker stops management requests from reaching the management node after repeated failures so
+	// callers, such as EPH scheduling loops, fail fast rather than waiting on a node which is not responding. After the
+	// cooldown a single trial request is let through; its outcome closes the breaker or opens it for another cooldown.
+	managementCircuitBreaker struct {
+		threshold int
+		cooldown  time.Duration
+		now       func() time.Time
+
+		mu       sync.Mutex
+		failures int
+		openedAt time.Time
+		open     bool
+		trial    bool
+	}
+)
+
+func newManagementCircuitBreaker(threshold int, cooldown time.Duration) *managementCircuitBreaker {
+	return &managementCircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// allow returns an error if requests must not be sent to the management node
+func (b *managementCircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return nil
+	}
+
+	if elapsed := b.now().Sub(b.openedAt); elapsed < b.cooldown || b.trial {
+		retryAfter := b.cooldown - elapsed
+		if retryAfter < 0 {
+			retryAfter = 0
+		}
+		return ErrManagementCircuitOpen{RetryAfter: retryAfter}
+	}
+
+	// half-open: let a single trial request through
+	b.trial = true
+	return nil
+}
+
+// record updates the breaker with the outcome of a request. Only failures which indicate the management node is
+// unhealthy count towards opening the breaker.
+func (b *managementCircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil || !isRetryableManagementError(err) {
+		b.failures = 0
+		b.open = false
+		b.trial = false
+		return
+	}
+
+	b.failures++
+	if b.trial || b.failures >= b.threshold {
+		b.open = true
+		b.trial = false
+		b.openedAt = b.now()
+	}
+}
@@ -0,0 +1,44 @@
+package eventhub
+
+import (
+	"testing"
+	"time"
+
+	common "github.com/Azure/azure-amqp-common-go/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagementCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	b := newManagementCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	unavailable := common.Retryable("link detached")
+
+	require.NoError(t, b.allow())
+	b.record(unavailable)
+	require.NoError(t, b.allow(), "a single failure should not open the breaker")
+
+	b.record(ErrNotFound{})
+	b.record(unavailable)
+	require.NoError(t, b.allow(), "non-transient errors reset the failure count")
+
+	b.record(unavailable)
+	err := b.allow()
+	require.Error(t, err)
+	assert.Equal(t, ErrManagementCircuitOpen{RetryAfter: time.Minute}, err)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, b.allow(), "a trial request is allowed after the cooldown")
+	assert.Error(t, b.allow(), "only one trial request is allowed at a time")
+
+	b.record(unavailable)
+	assert.Error(t, b.allow(), "a failed trial reopens the breaker")
+
+	now = now.Add(time.Minute)
+	require.NoError(t, b.allow())
+	b.record(nil)
+	assert.NoError(t, b.allow(), "a successful trial closes the breaker")
+	assert.NoError(t, b.allow())
+}
@@ -0,0 +1,117 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"sync"
+
+	"github.com/Azure/azure-amqp-common-go/v3/uuid"
+	"github.com/Azure/go-amqp"
+)
+
+type (
+	// marshalScratch is an AMQP message with the sections it's built from, reused to marshal the events added to
+	// batches. Only the encoding of those messages is kept, so building a message of their own for each is waste.
+	marshalScratch struct {
+		msg         amqp.Message
+		properties  amqp.MessageProperties
+		data        [1][]byte
+		appProps    map[string]interface{}
+		annotations amqp.Annotations
+	}
+)
+
+var marshalScratchPool = sync.Pool{
+	New: func() interface{} {
+		return &marshalScratch{
+			appProps:    make(map[string]interface{}),
+			annotations: make(amqp.Annotations),
+		}
+	},
+}
+
+// marshalForBatch encodes the AMQP message of event, giving it a message ID if it has none. The messages of received
+// events are reused as they are by toMsg; those of other events are built on a pooled scratch message.
+func marshalForBatch(event *Event) ([]byte, error) {
+	if event.message != nil {
+		msg, err := event.toMsg()
+		if err != nil {
+			return nil, err
+		}
+		return marshalWithMessageID(msg)
+	}
+
+	scratch := marshalScratchPool.Get().(*marshalScratch)
+	defer scratch.release()
+
+	msg := scratch.messageFor(event)
+	if err := event.fillMsg(msg); err != nil {
+		return nil, err
+	}
+
+	// empty sections are encoded as present but empty, unlike absent ones
+	if len(msg.Annotations) == 0 {
+		msg.Annotations = nil
+	}
+	if len(msg.ApplicationProperties) == 0 {
+		msg.ApplicationProperties = nil
+	}
+	return marshalWithMessageID(msg)
+}
+
+func marshalWithMessageID(msg *amqp.Message) ([]byte, error) {
+	if msg.Properties.MessageID == nil || msg.Properties.MessageID == "" {
+		uid, err := uuid.NewV4()
+		if err != nil {
+			return nil, err
+		}
+		msg.Properties.MessageID = uid.String()
+	}
+	return msg.MarshalBinary()
+}
+
+// messageFor prepares the scratch message to be filled with the sections of event
+func (s *marshalScratch) messageFor(event *Event) *amqp.Message {
+	s.data[0] = event.Data
+	s.msg = amqp.Message{
+		Data:                  s.data[:],
+		Properties:            &s.properties,
+		ApplicationProperties: s.appProps,
+		Annotations:           s.annotations,
+	}
+	return &s.msg
+}
+
+// release drops the references the scratch message holds to the event it was filled with and returns it to the pool
+func (s *marshalScratch) release() {
+	s.data[0] = nil
+	s.msg = amqp.Message{}
+	s.properties = amqp.MessageProperties{}
+	for key := range s.appProps {
+		delete(s.appProps, key)
+	}
+	for key := range s.annotations {
+		delete(s.annotations, key)
+	}
+	marshalScratchPool.Put(s)
+}
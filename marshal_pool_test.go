@@ -0,0 +1,42 @@
+package eventhub
+
+import (
+	"testing"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalForBatch_MatchesToMsg(t *testing.T) {
+	partitionKey := "pk"
+	keyed := NewEventFromString("keyed")
+	keyed.ID = "keyed-id"
+	keyed.PartitionKey = &partitionKey
+	keyed.Properties = map[string]interface{}{"count": int64(3)}
+
+	plain := NewEventFromString("plain")
+	plain.ID = "plain-id"
+
+	for _, event := range []*Event{keyed, plain, keyed} {
+		bin, err := marshalForBatch(event)
+		require.NoError(t, err)
+
+		msg, err := event.toMsg()
+		require.NoError(t, err)
+		expected, err := msg.MarshalBinary()
+		require.NoError(t, err)
+		assert.Equal(t, expected, bin, event.ID)
+	}
+}
+
+func TestMarshalForBatch_MessageID(t *testing.T) {
+	bin, err := marshalForBatch(NewEventFromString("no id"))
+	require.NoError(t, err)
+
+	msg := new(amqp.Message)
+	require.NoError(t, msg.UnmarshalBinary(bin))
+	assert.NotEmpty(t, msg.Properties.MessageID)
+	assert.Nil(t, msg.Annotations)
+	assert.Nil(t, msg.ApplicationProperties)
+}
@@ -0,0 +1,264 @@
+// Package metrics provides counters, gauges and histograms for the Event Hubs client and exposes them in the
+// Prometheus text exposition format, without depending on a Prometheus client library.
+//
+// A Registry is handed to the client with eventhub.HubWithMetrics or eph.WithMetrics, and served to Prometheus as an
+// http.Handler:
+//
+//	registry := metrics.NewRegistry()
+//	hub, err := eventhub.NewHubFromConnectionString(connStr, eventhub.HubWithMetrics(registry))
+//	http.Handle("/metrics", registry)
+package metrics
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets of latency histograms
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+type (
+	// Registry holds metric families and writes them in the Prometheus text format. It is safe for concurrent use.
+	Registry struct {
+		mu       sync.Mutex
+		families map[string]*family
+	}
+
+	family struct {
+		name    string
+		help    string
+		kind    string
+		labels  []string
+		buckets []float64
+
+		mu     sync.Mutex
+		series map[string]*series
+	}
+
+	series struct {
+		labelValues []string
+		value       float64
+		counts      []uint64
+		count       uint64
+	}
+
+	// CounterVec is a family of counters partitioned by label values
+	CounterVec struct {
+		family *family
+	}
+
+	// GaugeVec is a family of gauges partitioned by label values
+	GaugeVec struct {
+		family *family
+	}
+
+	// HistogramVec is a family of histograms partitioned by label values
+	HistogramVec struct {
+		family *family
+	}
+
+	// Counter is a value which only goes up
+	Counter struct {
+		family *family
+		series *series
+	}
+
+	// Gauge is a value which goes up and down
+	Gauge struct {
+		family *family
+		series *series
+	}
+
+	// Histogram counts observations in buckets
+	Histogram struct {
+		family *family
+		series *series
+	}
+)
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Counter returns the counter family name, registering it if needed. Registering a name again with the same labels
+// returns the same family; registering it with another type or labels panics.
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{family: r.register(name, help, kindCounter, labels, nil)}
+}
+
+// Gauge returns the gauge family name, registering it if needed
+func (r *Registry) Gauge(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{family: r.register(name, help, kindGauge, labels, nil)}
+}
+
+// Histogram returns the histogram family name with the given bucket upper bounds, registering it if needed. Nil
+// buckets use DefaultBuckets.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &HistogramVec{family: r.register(name, help, kindHistogram, labels, sorted)}
+}
+
+func (r *Registry) register(name, help, kind string, labels []string, buckets []float64) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		if f.kind != kind || strings.Join(f.labels, ",") != strings.Join(labels, ",") {
+			panic(fmt.Sprintf("metrics: %s is already registered as a %s with labels %v", name, f.kind, f.labels))
+		}
+		return f
+	}
+
+	f := &family{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  append([]string(nil), labels...),
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+// with returns the series for the label values, creating it if needed. The caller holds f.mu.
+func (f *family) with(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, got %d values", f.name, f.labels, len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family) lockedWith(labelValues []string) *series {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.with(labelValues)
+}
+
+func (f *family) delete(labelValues []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.series, strings.Join(labelValues, "\xff"))
+}
+
+// With returns the counter for the label values, which must be given in the order the labels were registered
+func (v *CounterVec) With(labelValues ...string) Counter {
+	return Counter{family: v.family, series: v.family.lockedWith(labelValues)}
+}
+
+// With returns the gauge for the label values
+func (v *GaugeVec) With(labelValues ...string) Gauge {
+	return Gauge{family: v.family, series: v.family.lockedWith(labelValues)}
+}
+
+// Delete removes the gauge for the label values, such as the gauge of a partition which is no longer owned
+func (v *GaugeVec) Delete(labelValues ...string) {
+	v.family.delete(labelValues)
+}
+
+// With returns the histogram for the label values
+func (v *HistogramVec) With(labelValues ...string) Histogram {
+	return Histogram{family: v.family, series: v.family.lockedWith(labelValues)}
+}
+
+// Inc adds 1 to the counter
+func (c Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds delta, which must not be negative, to the counter
+func (c Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+
+	c.family.mu.Lock()
+	c.series.value += delta
+	c.family.mu.Unlock()
+}
+
+// Set sets the gauge to value
+func (g Gauge) Set(value float64) {
+	g.family.mu.Lock()
+	g.series.value = value
+	g.family.mu.Unlock()
+}
+
+// Add adds delta to the gauge
+func (g Gauge) Add(delta float64) {
+	g.family.mu.Lock()
+	g.series.value += delta
+	g.family.mu.Unlock()
+}
+
+// Observe records value in the histogram
+func (h Histogram) Observe(value float64) {
+	h.family.mu.Lock()
+	defer h.family.mu.Unlock()
+
+	for i, bound := range h.family.buckets {
+		if value <= bound {
+			h.series.counts[i]++
+		}
+	}
+	h.series.count++
+	h.series.value += value
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return fmt.Sprintf("%v", f)
+}
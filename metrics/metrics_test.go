@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	sent := r.Counter("eventhub_sent_events_total", "Events sent.", "hub", "partition")
+	sent.With("hub", "0").Add(2)
+	sent.With("hub", "0").Inc()
+	sent.With(`a"b`, "1").Inc()
+
+	owned := r.Gauge("eventhub_eph_owned_partitions", "Partitions owned.")
+	owned.With().Set(4)
+	owned.With().Add(-1)
+
+	latency := r.Histogram("eventhub_send_duration_seconds", "Send latency.", []float64{1, 0.1}, "hub")
+	latency.With("hub").Observe(0.05)
+	latency.With("hub").Observe(0.5)
+	latency.With("hub").Observe(5)
+
+	r.Gauge("eventhub_unused", "Never set.", "hub")
+
+	var sb strings.Builder
+	require.NoError(t, r.WriteText(&sb))
+	assert.Equal(t, `# HELP eventhub_eph_owned_partitions Partitions owned.
+# TYPE eventhub_eph_owned_partitions gauge
+eventhub_eph_owned_partitions 3
+# HELP eventhub_send_duration_seconds Send latency.
+# TYPE eventhub_send_duration_seconds histogram
+eventhub_send_duration_seconds_bucket{hub="hub",le="0.1"} 1
+eventhub_send_duration_seconds_bucket{hub="hub",le="1"} 2
+eventhub_send_duration_seconds_bucket{hub="hub",le="+Inf"} 3
+eventhub_send_duration_seconds_sum{hub="hub"} 5.55
+eventhub_send_duration_seconds_count{hub="hub"} 3
+# HELP eventhub_sent_events_total Events sent.
+# TYPE eventhub_sent_events_total counter
+eventhub_sent_events_total{hub="a\"b",partition="1"} 1
+eventhub_sent_events_total{hub="hub",partition="0"} 3
+`, sb.String())
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+	first := r.Counter("eventhub_retries_total", "Retries.", "hub")
+	first.With("hub").Inc()
+	again := r.Counter("eventhub_retries_total", "Retries.", "hub")
+	again.With("hub").Inc()
+	assert.Equal(t, float64(2), first.With("hub").series.value, "registering again shares the family")
+
+	assert.Panics(t, func() { r.Gauge("eventhub_retries_total", "Retries.", "hub") })
+	assert.Panics(t, func() { r.Counter("eventhub_retries_total", "Retries.", "namespace") })
+	assert.Panics(t, func() { first.With("hub", "extra") })
+
+	gauge := r.Gauge("eventhub_lag", "Lag.", "partition")
+	gauge.With("0").Set(1)
+	gauge.Delete("0")
+	assert.Empty(t, gauge.family.series)
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("eventhub_sent_events_total", "Events sent.").With().Inc()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, contentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "eventhub_sent_events_total 1\n")
+}
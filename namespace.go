@@ -24,24 +24,62 @@ package eventhub
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Azure/azure-amqp-common-go/v3/auth"
-	"github.com/Azure/azure-amqp-common-go/v3/cbs"
 	"github.com/Azure/azure-amqp-common-go/v3/conn"
 	"github.com/Azure/azure-amqp-common-go/v3/sas"
 	"github.com/Azure/go-amqp"
 	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/devigned/tab"
 	"golang.org/x/net/websocket"
 )
 
+const (
+	amqpsPort = "5671"
+	httpsPort = "443"
+
+	cbsAddress           = "$cbs"
+	cbsOperationPutToken = "put-token"
+	cbsTokenTypeKey      = "type"
+	cbsAudienceKey       = "name"
+	cbsExpirationKey     = "expiration"
+)
+
 type (
 	namespace struct {
 		name          string
 		tokenProvider auth.TokenProvider
 		host          string
 		useWebSocket  bool
+		proxy         proxyFunc
+		tlsConfig     *tls.Config
+		pool          *ConnectionPool
+		connOptions   []amqp.ConnOption
+		dialer        DialFunc
+		transport     transport
+		faults        *FaultInjector
+		sessionMux    *sessionMultiplexer
+		metrics       *hubMetrics
+		geoDR         *geoDR
+
+		connectionListener ConnectionListener
+		frameTracer        FrameTracer
+		connectTimeout     time.Duration
+		endpointHost       string
+		endpointPort       string
+		tlsDisabled        bool
+
+		rpcLinksOnce sync.Once
+		rpcLinkCache *rpcLinkCache
 	}
 
 	// namespaceOption provides structure for configuring a new Event Hub namespace
@@ -100,27 +138,227 @@ func (ns *namespace) newConnection() (*amqp.Client, error) {
 		amqp.ConnProperty("framework", runtime.Version()),
 		amqp.ConnProperty("user-agent", rootUserAgent),
 	}
+	defaultConnOptions = append(defaultConnOptions, ns.connOptions...)
+
+	trimmedHost := strings.TrimPrefix(ns.host, "amqps://")
+	proxyURL, err := ns.resolveProxy(trimmedHost)
+	if err != nil {
+		return nil, err
+	}
 
 	if ns.useWebSocket {
-		trimmedHost := strings.TrimPrefix(ns.host, "amqps://")
-		wssConn, err := websocket.Dial("wss://"+trimmedHost+"/$servicebus/websocket", "amqp", "http://localhost/")
+		wssConn, err := ns.dialWebSocket(trimmedHost, proxyURL)
 		if err != nil {
 			return nil, err
 		}
 
 		wssConn.PayloadType = websocket.BinaryFrame
-		return amqp.New(wssConn, append(defaultConnOptions, amqp.ConnServerHostname(trimmedHost))...)
+		return amqp.New(ns.instrumentConn(wssConn), append(defaultConnOptions, amqp.ConnServerHostname(trimmedHost))...)
+	}
+
+	if proxyURL != nil {
+		tlsConn, err := ns.dialTLSThroughProxy(proxyURL, trimmedHost, amqpsPort)
+		if err != nil {
+			return nil, err
+		}
+		return amqp.New(ns.instrumentConn(tlsConn), append(defaultConnOptions, amqp.ConnServerHostname(trimmedHost))...)
+	}
+
+	// frames can only be traced and counted below TLS, so tracing and fault injection take over dialing from go-amqp,
+	// as do custom endpoints
+	if ns.dialer != nil || ns.frameTracer != nil || ns.faults != nil || ns.hasCustomEndpoint() {
+		tlsConn, err := ns.dialTLS(trimmedHost, amqpsPort)
+		if err != nil {
+			return nil, err
+		}
+		return amqp.New(ns.instrumentConn(tlsConn), append(defaultConnOptions, amqp.ConnServerHostname(trimmedHost))...)
+	}
+
+	if ns.tlsConfig != nil {
+		defaultConnOptions = append(defaultConnOptions, amqp.ConnTLSConfig(ns.newTLSConfig(trimmedHost)))
 	}
 
 	return amqp.Dial(host, defaultConnOptions...)
 }
 
+func (ns *namespace) dialWebSocket(host string, proxyURL *url.URL) (*websocket.Conn, error) {
+	scheme := "wss://"
+	if ns.tlsDisabled {
+		scheme = "ws://"
+	}
+	location := scheme + host + "/$servicebus/websocket"
+	config, err := websocket.NewConfig(location, "http://localhost/")
+	if err != nil {
+		return nil, err
+	}
+	config.Protocol = []string{"amqp"}
+
+	if proxyURL == nil && ns.dialer == nil && !ns.hasCustomEndpoint() {
+		config.TlsConfig = ns.newTLSConfig(host)
+		return websocket.DialConfig(config)
+	}
+
+	var tlsConn net.Conn
+	if proxyURL != nil {
+		tlsConn, err = ns.dialTLSThroughProxy(proxyURL, host, httpsPort)
+	} else {
+		tlsConn, err = ns.dialTLS(host, httpsPort)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	wssConn, err := websocket.NewClient(config, tlsConn)
+	if err != nil {
+		_ = tlsConn.Close()
+		return nil, err
+	}
+	return wssConn, nil
+}
+
+// dialTLS opens a connection to host, or the custom endpoint standing in for it, with the custom dialer, if any, and
+// secures it with TLS unless TLS is disabled
+func (ns *namespace) dialTLS(host, port string) (net.Conn, error) {
+	dial := ns.dialer
+	if dial == nil {
+		dial = defaultDial
+	}
+
+	ctx, cancel := withDefaultTimeout(context.Background(), ns.connectTimeout)
+	defer cancel()
+
+	conn, err := dial(ctx, "tcp", ns.endpointAddress(host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	// the connect timeout covers the TLS handshake as well as dialing
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+	return ns.secureConn(conn, host)
+}
+
+// dialTLSThroughProxy tunnels a connection to host through the proxy and secures it with TLS
+func (ns *namespace) dialTLSThroughProxy(proxyURL *url.URL, host, port string) (net.Conn, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), ns.connectTimeout)
+	defer cancel()
+
+	conn, err := dialProxy(ctx, ns.dialer, proxyURL, ns.endpointAddress(host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	// the connect timeout covers the TLS handshake as well as the tunnel
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+	return ns.secureConn(conn, host)
+}
+
+// secureConn performs a TLS handshake with host over conn, closing conn if the handshake fails. Conn is returned as
+// is when TLS is disabled.
+func (ns *namespace) secureConn(conn net.Conn, host string) (net.Conn, error) {
+	if ns.tlsDisabled {
+		return conn, nil
+	}
+
+	tlsConn := tls.Client(conn, ns.newTLSConfig(host))
+	if err := tlsConn.Handshake(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// newTLSConfig returns a copy of the configured TLS settings for a connection to host. The server name defaults to host
+// unless the configuration overrides it.
+func (ns *namespace) newTLSConfig(host string) *tls.Config {
+	config := new(tls.Config)
+	if ns.tlsConfig != nil {
+		config = ns.tlsConfig.Clone()
+	}
+
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	return config
+}
+
+// negotiateClaim makes sure a claim covering entityPath is in force on the connection. Links to the partitions and
+// consumer groups of a hub share the claim of the hub, which is negotiated once per connection and renewed ahead of
+// its expiry.
 func (ns *namespace) negotiateClaim(ctx context.Context, conn *amqp.Client, entityPath string) error {
+	return ns.negotiateClaimForAudience(ctx, conn, ns.getEntityAudience(claimEntityPath(entityPath)), entityPath)
+}
+
+func (ns *namespace) negotiateClaimForAudience(ctx context.Context, conn *amqp.Client, audience, entityPath string) error {
+	return ns.rpcLinks().claims.ensure(ctx, conn, audience, func(ctx context.Context) (time.Time, error) {
+		return ns.putToken(ctx, conn, audience, entityPath)
+	})
+}
+
+// putToken negotiates a claim for audience with the CBS node of the connection, returning its expiry, or the zero time
+// if the token doesn't say
+func (ns *namespace) putToken(ctx context.Context, conn *amqp.Client, audience, entityPath string) (expiry time.Time, err error) {
 	span, ctx := ns.startSpanFromContext(ctx, "eh.namespace.negotiateClaim")
 	defer span.End()
+	defer func() { ns.metrics.observeTokenRefresh(err) }()
+
+	token, err := ns.tokenProvider.GetToken(audience)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return time.Time{}, err
+	}
+
+	tab.For(ctx).Debug(fmt.Sprintf("negotiating claim for audience %s with token type %s and expiry of %s", audience, token.TokenType, token.Expiry))
+	msg := &amqp.Message{
+		Value: token.Token,
+		ApplicationProperties: map[string]interface{}{
+			operationKey:     cbsOperationPutToken,
+			cbsTokenTypeKey:  string(token.TokenType),
+			cbsAudienceKey:   audience,
+			cbsExpirationKey: token.Expiry,
+		},
+	}
+
+	// the $cbs link is shared by every entity on the connection, so refreshing a token doesn't attach a new link
+	links := ns.rpcLinks()
+	link, err := links.get(conn, cbsAddress)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return time.Time{}, err
+	}
 
-	audience := ns.getEntityAudience(entityPath)
-	return cbs.NegotiateClaim(ctx, audience, conn, ns.tokenProvider)
+	res, err := link.RetryableRPC(ctx, 3, 1*time.Second, msg)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		if closeErr := links.discardFailed(conn, cbsAddress, link, err); closeErr != nil {
+			tab.For(ctx).Debug(fmt.Sprintf("failed to close cbs link: %v", closeErr))
+		}
+		return time.Time{}, fromAMQPError(err)
+	}
+
+	tab.For(ctx).Debug(fmt.Sprintf("negotiated with response code %d and message: %s", res.Code, res.Description))
+	ns.notifyConnection(ConnectionEvent{Type: ConnectionEventAuthenticated, Entity: entityPath})
+	return tokenExpiry(token.Expiry), nil
+}
+
+// tokenExpiry parses the expiry of a token, in seconds since the epoch
+func tokenExpiry(expiry string) time.Time {
+	seconds, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
 }
 
 func (ns *namespace) getAmqpsHostURI() string {
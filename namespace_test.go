@@ -0,0 +1,83 @@
+package eventhub
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespace_NewTLSConfig(t *testing.T) {
+	ns := &namespace{}
+	config := ns.newTLSConfig("ns.servicebus.windows.net")
+	assert.Equal(t, "ns.servicebus.windows.net", config.ServerName)
+
+	h := &Hub{namespace: ns}
+	custom := &tls.Config{MinVersion: tls.VersionTLS12}
+	require.NoError(t, HubWithTLSConfig(custom)(h))
+
+	config = ns.newTLSConfig("ns.servicebus.windows.net")
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	assert.Equal(t, "ns.servicebus.windows.net", config.ServerName)
+	assert.Empty(t, custom.ServerName, "the supplied config must not be modified")
+
+	ns.tlsConfig.ServerName = "private.endpoint"
+	assert.Equal(t, "private.endpoint", ns.newTLSConfig("ns.servicebus.windows.net").ServerName)
+
+	assert.Error(t, HubWithTLSConfig(nil)(h))
+}
+
+func TestConnectionTuningOptions(t *testing.T) {
+	h := &Hub{namespace: &namespace{}}
+	require.NoError(t, HubWithConnectionIdleTimeout(5*time.Minute)(h))
+	require.NoError(t, HubWithConnectTimeout(30*time.Second)(h))
+	require.NoError(t, HubWithMaxFrameSize(64*1024)(h))
+	require.NoError(t, HubWithMaxSessions(16)(h))
+	require.NoError(t, HubWithContainerID("ingest-worker-1")(h))
+	assert.Len(t, h.namespace.connOptions, 5)
+
+	assert.Error(t, HubWithConnectionIdleTimeout(-time.Second)(h))
+	assert.Error(t, HubWithConnectTimeout(-time.Second)(h))
+	assert.Error(t, HubWithMaxFrameSize(511)(h))
+	assert.Error(t, HubWithMaxSessions(0)(h))
+	assert.Error(t, HubWithMaxSessions(65537)(h))
+	assert.Error(t, HubWithContainerID("")(h))
+}
+
+func TestNamespace_NewConnectionUsesDialer(t *testing.T) {
+	var dialed []string
+	refused := errors.New("refused")
+	ns := &namespace{host: "amqps://ns.servicebus.windows.net"}
+	h := &Hub{namespace: ns}
+	assert.Error(t, HubWithAMQPDialer(nil)(h))
+	require.NoError(t, HubWithAMQPDialer(func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, network+"://"+address)
+		return nil, refused
+	})(h))
+
+	_, err := ns.newConnection()
+	assert.Equal(t, refused, err)
+
+	ns.useWebSocket = true
+	_, err = ns.newConnection()
+	assert.Equal(t, refused, err)
+
+	ns.proxy = func(*http.Request) (*url.URL, error) {
+		return url.Parse("http://proxy.local:3128")
+	}
+	_, err = ns.newConnection()
+	assert.Equal(t, refused, err)
+
+	assert.Equal(t, []string{
+		"tcp://ns.servicebus.windows.net:5671",
+		"tcp://ns.servicebus.windows.net:443",
+		"tcp://proxy.local:3128",
+	}, dialed)
+}
@@ -0,0 +1,111 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+
+	"github.com/devigned/tab"
+)
+
+type (
+	// OpenOption provides a way to configure Open
+	OpenOption func(opts *openOptions) error
+
+	openOptions struct {
+		management bool
+	}
+)
+
+// OpenWithManagementLink configures Open to also set up the link runtime information is read over, for applications
+// which read it early, such as to list partitions before receiving from them
+func OpenWithManagementLink() OpenOption {
+	return func(opts *openOptions) error {
+		opts.management = true
+		return nil
+	}
+}
+
+// Open sets the Hub up for sending ahead of its first send: it dials the connection, negotiates the claims and attaches
+// the sender links, so that the first Send after process start doesn't wait seconds for all that. Every link configured
+// with HubWithSenderLinks is attached, and with a partitioner configured with HubWithPartitioner, the partition IDs are
+// read and the sender of every partition is attached as well.
+//
+// Links attached by Open are the ones later sends use, and are recovered the same way. Calling Open is optional; links
+// it doesn't attach are attached on first use, and opening an open Hub only attaches the links which aren't yet.
+func (h *Hub) Open(ctx context.Context, opts ...OpenOption) error {
+	span, ctx := h.startSpanFromContext(ctx, "eh.Hub.Open")
+	defer span.End()
+
+	options := new(openOptions)
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			tab.For(ctx).Error(err)
+			return err
+		}
+	}
+
+	if err := h.openSenders(ctx); err != nil {
+		tab.For(ctx).Error(err)
+		return err
+	}
+
+	if options.management {
+		if _, err := h.GetRuntimeInformation(ctx); err != nil {
+			tab.For(ctx).Error(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// openSenders attaches the sender links of the Hub which aren't attached yet
+func (h *Hub) openSenders(ctx context.Context) error {
+	links := h.senderLinks
+	if links < 1 {
+		links = 1
+	}
+	for index := 0; index < links; index++ {
+		if _, err := h.senderStripe(ctx, index); err != nil {
+			return err
+		}
+	}
+
+	if h.partitioner == nil || h.senderPartitionID != nil {
+		return nil
+	}
+
+	h.senderMu.Lock()
+	defer h.senderMu.Unlock()
+
+	partitionIDs, err := h.partitionIDsLocked(ctx)
+	if err != nil {
+		return err
+	}
+	for _, partitionID := range partitionIDs {
+		if _, err := h.partitionSenderLocked(ctx, partitionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package eventhub
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHub_OpenAttachesOnlyMissingLinks(t *testing.T) {
+	// every link is attached already, so opening must not dial
+	h := &Hub{
+		sender:        new(sender),
+		senderLinks:   3,
+		senderStripes: []*sender{new(sender), new(sender)},
+		partitioner:   KafkaPartitioner{},
+		partitionIDs:  []string{"0", "1"},
+		partitionSenders: map[string]*sender{
+			"0": new(sender),
+			"1": new(sender),
+		},
+	}
+	assert.NoError(t, h.Open(context.Background()))
+	assert.Len(t, h.partitionSenders, 2)
+}
+
+func TestHub_OpenBoundToPartitionSkipsPartitionSenders(t *testing.T) {
+	partitionID := "0"
+	h := &Hub{
+		sender:            new(sender),
+		senderPartitionID: &partitionID,
+		partitioner:       KafkaPartitioner{},
+	}
+	assert.NoError(t, h.Open(context.Background()))
+	assert.Nil(t, h.partitionIDs)
+}
@@ -0,0 +1,370 @@
+// Package opentelemetry exports the traces of the Event Hubs client to OpenTelemetry.
+//
+// The client records spans through github.com/devigned/tab. Registering the tracer of this package routes those spans,
+// including Send, SendBatch, message delivery, management requests and the lease and checkpoint operations of the
+// event processor host, to an OpenTelemetry TracerProvider:
+//
+//	opentelemetry.Register(tracerProvider)
+//
+// Span attributes set by the client, such as eh.namespace, eh.hub, eh.partition and eh.sequence_number, become
+// OpenTelemetry attributes. Trace context is carried in event properties with the configured propagator, so handler
+// spans are parented to the spans which sent their events.
+//
+// At high volume, WithSampleRate records only a fraction of traces, and WithRedactor keeps secrets such as shared
+// access keys out of span attributes, events and errors:
+//
+//	err := opentelemetry.Register(provider,
+//		opentelemetry.WithSampleRate(0.01),
+//		opentelemetry.WithRedactor(opentelemetry.RedactSecrets),
+//		opentelemetry.WithRedactor(opentelemetry.RedactKeys("app.body")))
+package opentelemetry
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	crand "crypto/rand"
+	"fmt"
+	"math/rand"
+	"regexp"
+
+	"github.com/devigned/tab"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/Azure/azure-event-hubs-go"
+
+	// RedactedValue replaces the values removed by RedactKeys
+	RedactedValue = "[REDACTED]"
+
+	// messageKey and errorKey are the keys redactors see for span event messages and error messages
+	messageKey = "message"
+	errorKey   = "error"
+)
+
+// secretPattern matches the shared access keys of connection strings and the signatures of shared access tokens
+var secretPattern = regexp.MustCompile(`(?i)(SharedAccessKey=|SharedAccessSignature=|sig=)[^;&\s"]+`)
+
+type (
+	// Tracer implements tab.Tracer with an OpenTelemetry TracerProvider
+	Tracer struct {
+		tracer     trace.Tracer
+		propagator propagation.TextMapPropagator
+		sampleRate float64
+		random     func() float64
+		redactors  []Redactor
+	}
+
+	// TracerOption configures a Tracer
+	TracerOption func(t *Tracer) error
+
+	// Redactor rewrites a value before it is recorded. It is called with the key and value of string attributes, with
+	// "message" and the message of span events, and with "error" and the message of recorded errors.
+	Redactor func(key, value string) string
+
+	// span adapts an OpenTelemetry span to tab.Spanner
+	span struct {
+		ctx    context.Context
+		span   trace.Span
+		tracer *Tracer
+	}
+
+	// logger records the logs of a span as span events
+	logger struct {
+		span   trace.Span
+		tracer *Tracer
+	}
+
+	// redactedError reports the redacted message of an error while keeping it for errors.Is and errors.As
+	redactedError struct {
+		msg string
+		err error
+	}
+
+	// carrier adapts the properties of an event to an OpenTelemetry TextMapCarrier
+	carrier struct {
+		tab.Carrier
+	}
+)
+
+// NewTracer creates a Tracer which records spans with provider. Trace context is propagated with the W3C traceparent
+// and tracestate headers unless WithPropagator says otherwise.
+func NewTracer(provider trace.TracerProvider, opts ...TracerOption) (*Tracer, error) {
+	if provider == nil {
+		return nil, fmt.Errorf("tracer provider must not be nil")
+	}
+
+	t := &Tracer{
+		tracer:     provider.Tracer(instrumentationName),
+		propagator: propagation.TraceContext{},
+		sampleRate: 1,
+		random:     rand.Float64,
+	}
+	for _, opt := range opts {
+		if err := opt(t); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Register creates a Tracer with provider and registers it as the tracer of the Event Hubs client
+func Register(provider trace.TracerProvider, opts ...TracerOption) error {
+	t, err := NewTracer(provider, opts...)
+	if err != nil {
+		return err
+	}
+
+	tab.Register(t)
+	return nil
+}
+
+// WithPropagator configures how trace context is injected into and extracted from event properties
+func WithPropagator(propagator propagation.TextMapPropagator) TracerOption {
+	return func(t *Tracer) error {
+		if propagator == nil {
+			return fmt.Errorf("propagator must not be nil")
+		}
+		t.propagator = propagator
+		return nil
+	}
+}
+
+// WithSampleRate records only the given fraction, between 0 and 1, of the traces started by the client. The decision
+// is made when a trace starts, so a trace is recorded in full or not at all, and it is propagated in the trace context
+// of sent events, so handler spans follow the decision of the producer. Without this option, every span is handed to
+// the TracerProvider, whose sampler decides.
+func WithSampleRate(rate float64) TracerOption {
+	return func(t *Tracer) error {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("sample rate must be between 0 and 1, got %v", rate)
+		}
+		t.sampleRate = rate
+		return nil
+	}
+}
+
+// WithRedactor rewrites attribute values, span event messages and error messages with redactor before they are
+// recorded. Redactors run in the order they are configured.
+func WithRedactor(redactor Redactor) TracerOption {
+	return func(t *Tracer) error {
+		if redactor == nil {
+			return fmt.Errorf("redactor must not be nil")
+		}
+		t.redactors = append(t.redactors, redactor)
+		return nil
+	}
+}
+
+// RedactKeys returns a Redactor which replaces the values of the attributes with the given keys by RedactedValue,
+// such as attributes which carry event bodies
+func RedactKeys(keys ...string) Redactor {
+	redacted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		redacted[key] = true
+	}
+
+	return func(key, value string) string {
+		if redacted[key] {
+			return RedactedValue
+		}
+		return value
+	}
+}
+
+// RedactSecrets is a Redactor which replaces the shared access keys of connection strings and the signatures of
+// shared access tokens by RedactedValue, wherever they appear
+func RedactSecrets(key, value string) string {
+	return secretPattern.ReplaceAllString(value, "${1}"+RedactedValue)
+}
+
+// StartSpan starts a span which is a child of the span in ctx, if any
+func (t *Tracer) StartSpan(ctx context.Context, operationName string, opts ...interface{}) (context.Context, tab.Spanner) {
+	if t.sampleRate < 1 {
+		parent := trace.SpanContextFromContext(ctx)
+		switch {
+		case !parent.IsValid() && t.random() >= t.sampleRate:
+			// the trace isn't sampled; carry the decision to child spans and downstream consumers
+			ctx = trace.ContextWithSpanContext(ctx, newUnsampledSpanContext())
+			return ctx, &span{ctx: ctx, span: trace.SpanFromContext(ctx), tracer: t}
+		case parent.IsValid() && !parent.IsSampled():
+			return ctx, &span{ctx: ctx, span: trace.SpanFromContext(ctx), tracer: t}
+		}
+	}
+
+	ctx, s := t.tracer.Start(ctx, operationName)
+	return ctx, &span{ctx: ctx, span: s, tracer: t}
+}
+
+// StartSpanWithRemoteParent starts a span which is a child of the trace context carried by carrier, or of the span in
+// ctx if carrier carries none
+func (t *Tracer) StartSpanWithRemoteParent(ctx context.Context, operationName string, c tab.Carrier, opts ...interface{}) (context.Context, tab.Spanner) {
+	if c != nil {
+		ctx = t.propagator.Extract(ctx, carrier{c})
+	}
+	return t.StartSpan(ctx, operationName, opts...)
+}
+
+// FromContext returns the span in ctx; without one, the span records nothing
+func (t *Tracer) FromContext(ctx context.Context) tab.Spanner {
+	return &span{ctx: ctx, span: trace.SpanFromContext(ctx), tracer: t}
+}
+
+// NewContext returns a copy of parent carrying span
+func (t *Tracer) NewContext(parent context.Context, s tab.Spanner) context.Context {
+	if otelSpan, ok := s.InternalSpan().(trace.Span); ok {
+		return trace.ContextWithSpan(parent, otelSpan)
+	}
+	return parent
+}
+
+func (s *span) AddAttributes(attributes ...tab.Attribute) {
+	s.span.SetAttributes(s.tracer.toKeyValues(attributes)...)
+}
+
+func (s *span) End() {
+	s.span.End()
+}
+
+func (s *span) Logger() tab.Logger {
+	return &logger{span: s.span, tracer: s.tracer}
+}
+
+// Inject writes the trace context of the span into the properties of an event
+func (s *span) Inject(c tab.Carrier) error {
+	s.tracer.propagator.Inject(trace.ContextWithSpan(s.ctx, s.span), carrier{c})
+	return nil
+}
+
+func (s *span) InternalSpan() interface{} {
+	return s.span
+}
+
+func (l *logger) Info(msg string, attributes ...tab.Attribute) {
+	l.log("info", msg, attributes)
+}
+
+// Error records err on the span and marks the span as failed
+func (l *logger) Error(err error, attributes ...tab.Attribute) {
+	if msg := l.tracer.redact(errorKey, err.Error()); msg != err.Error() {
+		err = &redactedError{msg: msg, err: err}
+	}
+	l.span.RecordError(err, trace.WithAttributes(l.tracer.toKeyValues(attributes)...))
+	l.span.SetStatus(codes.Error, err.Error())
+}
+
+func (l *logger) Fatal(msg string, attributes ...tab.Attribute) {
+	l.log("fatal", msg, attributes)
+	l.span.SetStatus(codes.Error, l.tracer.redact(messageKey, msg))
+}
+
+func (l *logger) Debug(msg string, attributes ...tab.Attribute) {
+	l.log("debug", msg, attributes)
+}
+
+func (l *logger) log(level, msg string, attributes []tab.Attribute) {
+	kvs := append(l.tracer.toKeyValues(attributes), attribute.String("level", level))
+	l.span.AddEvent(l.tracer.redact(messageKey, msg), trace.WithAttributes(kvs...))
+}
+
+func (c carrier) Get(key string) string {
+	if value, ok := c.GetKeyValues()[key]; ok {
+		if str, ok := value.(string); ok {
+			return str
+		}
+	}
+	return ""
+}
+
+func (c carrier) Set(key, value string) {
+	c.Carrier.Set(key, value)
+}
+
+func (c carrier) Keys() []string {
+	kvs := c.GetKeyValues()
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// newUnsampledSpanContext returns the context of a new trace which is not recorded
+func newUnsampledSpanContext() trace.SpanContext {
+	var traceID trace.TraceID
+	var spanID trace.SpanID
+	_, _ = crand.Read(traceID[:])
+	_, _ = crand.Read(spanID[:])
+	return trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+}
+
+// redact applies the configured redactors to value
+func (t *Tracer) redact(key, value string) string {
+	for _, redactor := range t.redactors {
+		value = redactor(key, value)
+	}
+	return value
+}
+
+// toKeyValues converts tab attributes, which hold strings, booleans and integers, to OpenTelemetry attributes. An
+// attribute changed by the redactors is recorded as a string, whatever its type.
+func (t *Tracer) toKeyValues(attributes []tab.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attributes))
+	for _, a := range attributes {
+		if len(t.redactors) > 0 {
+			value := fmt.Sprint(a.Value)
+			if redacted := t.redact(a.Key, value); redacted != value {
+				kvs = append(kvs, attribute.String(a.Key, redacted))
+				continue
+			}
+		}
+
+		switch v := a.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.Key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(a.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(a.Key, v))
+		case float64:
+			kvs = append(kvs, attribute.Float64(a.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(a.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,141 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/devigned/tab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type properties map[string]interface{}
+
+func (p properties) Set(key string, value interface{}) {
+	p[key] = value
+}
+
+func (p properties) GetKeyValues() map[string]interface{} {
+	return p
+}
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer, err := NewTracer(provider)
+	require.NoError(t, err)
+
+	ctx, send := tracer.StartSpan(context.Background(), "eh.Hub.Send")
+	send.AddAttributes(
+		tab.StringAttribute("eh.hub", "hub"),
+		tab.Int64Attribute("eh.sequence_number", 42),
+		tab.BoolAttribute("eh.batch", false),
+	)
+	event := properties{}
+	require.NoError(t, tab.FromContext(ctx).Inject(event))
+	require.NoError(t, send.Inject(event))
+	send.End()
+	assert.Contains(t, event, "traceparent")
+
+	_, handle := tracer.StartSpanWithRemoteParent(context.Background(), "eh.Receiver.handleMessage", event)
+	handle.Logger().Error(errors.New("handler failed"))
+	handle.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "eh.Hub.Send", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.Int64("eh.sequence_number", 42))
+	assert.Contains(t, spans[0].Attributes(), attribute.String("eh.hub", "hub"))
+
+	assert.Equal(t, spans[0].SpanContext().TraceID(), spans[1].SpanContext().TraceID())
+	assert.Equal(t, spans[0].SpanContext().SpanID(), spans[1].Parent().SpanID())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	require.Len(t, spans[1].Events(), 1)
+}
+
+func TestNewTracerRequiresProvider(t *testing.T) {
+	_, err := NewTracer(nil)
+	assert.Error(t, err)
+
+	_, err = NewTracer(sdktrace.NewTracerProvider(), WithPropagator(nil))
+	assert.Error(t, err)
+}
+
+func TestTracer_Sampling(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	_, err := NewTracer(provider, WithSampleRate(1.5))
+	assert.Error(t, err)
+
+	tracer, err := NewTracer(provider, WithSampleRate(0.5))
+	require.NoError(t, err)
+
+	tracer.random = func() float64 { return 0.7 }
+	ctx, send := tracer.StartSpan(context.Background(), "eh.Hub.Send")
+	_, child := tracer.StartSpan(ctx, "eh.sender.trySend")
+	event := properties{}
+	require.NoError(t, send.Inject(event))
+	child.End()
+	send.End()
+	assert.Empty(t, recorder.Ended(), "unsampled traces record nothing")
+	assert.Contains(t, event["traceparent"], "-00", "the decision travels with the event")
+
+	// consumers follow the decision of the producer, whatever they draw
+	tracer.random = func() float64 { return 0.1 }
+	_, handle := tracer.StartSpanWithRemoteParent(context.Background(), "eh.Receiver.handleMessage", event)
+	handle.End()
+	assert.Empty(t, recorder.Ended())
+
+	ctx, send = tracer.StartSpan(context.Background(), "eh.Hub.Send")
+	_, child = tracer.StartSpan(ctx, "eh.sender.trySend")
+	child.End()
+	send.End()
+	assert.Len(t, recorder.Ended(), 2)
+}
+
+func TestTracer_Redaction(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	_, err := NewTracer(provider, WithRedactor(nil))
+	assert.Error(t, err)
+
+	tracer, err := NewTracer(provider, WithRedactor(RedactSecrets), WithRedactor(RedactKeys("app.body", "app.size")))
+	require.NoError(t, err)
+
+	connStr := "Endpoint=sb://ns.servicebus.windows.net/;SharedAccessKeyName=send;SharedAccessKey=c2VjcmV0;EntityPath=hub"
+	failed := errors.New("dial failed for " + connStr)
+	_, s := tracer.StartSpan(context.Background(), "eh.Hub.Send")
+	s.AddAttributes(
+		tab.StringAttribute("app.body", "hello"),
+		tab.Int64Attribute("app.size", 5),
+		tab.Int64Attribute("eh.sequence_number", 42),
+		tab.StringAttribute("app.token", "SharedAccessSignature sr=ns&sig=c2lnbmF0dXJl&se=1&skn=send"),
+	)
+	s.Logger().Debug("connecting with " + connStr)
+	s.Logger().Error(failed)
+	s.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	attrs := spans[0].Attributes()
+	assert.Contains(t, attrs, attribute.String("app.body", RedactedValue))
+	assert.Contains(t, attrs, attribute.String("app.size", RedactedValue))
+	assert.Contains(t, attrs, attribute.Int64("eh.sequence_number", 42))
+	assert.Contains(t, attrs, attribute.String("app.token", "SharedAccessSignature sr=ns&sig=[REDACTED]&se=1&skn=send"))
+
+	redacted := "Endpoint=sb://ns.servicebus.windows.net/;SharedAccessKeyName=send;SharedAccessKey=[REDACTED];EntityPath=hub"
+	events := spans[0].Events()
+	require.Len(t, events, 2)
+	assert.Equal(t, "connecting with "+redacted, events[0].Name)
+	assert.Equal(t, "dial failed for "+redacted, spans[0].Status().Description)
+	for _, event := range events {
+		for _, attr := range event.Attributes {
+			assert.NotContains(t, attr.Value.Emit(), "c2VjcmV0")
+		}
+	}
+}
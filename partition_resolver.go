@@ -0,0 +1,132 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// PartitionIndexForKey returns the index, in the ordered list of partition IDs, of the partition the service assigns to
+// events sent with the given partition key. The hash matches the one used by the service and the other Event Hubs
+// SDKs: Jenkins' lookup3 over the UTF-8 bytes of the key, folded to 16 bits.
+func PartitionIndexForKey(partitionKey string, partitionCount int) int {
+	if partitionCount <= 0 {
+		return 0
+	}
+
+	hash1, hash2 := lookup3([]byte(partitionKey), 0, 0)
+	index := int(int16(hash1^hash2)) % partitionCount
+	if index < 0 {
+		index = -index
+	}
+	return index
+}
+
+// ResolvePartition returns the ID of the partition the service assigns to events sent with the given partition key.
+// This is useful for locating hot partitions or for sending directly to the partition a key maps to.
+func (h *Hub) ResolvePartition(ctx context.Context, partitionKey string) (string, error) {
+	span, ctx := h.startSpanFromContext(ctx, "eh.Hub.ResolvePartition")
+	defer span.End()
+
+	info, err := h.GetRuntimeInformation(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if len(info.PartitionIDs) == 0 {
+		return "", fmt.Errorf("event hub %q reported no partitions", h.name)
+	}
+
+	return info.PartitionIDs[PartitionIndexForKey(partitionKey, len(info.PartitionIDs))], nil
+}
+
+// lookup3 is Bob Jenkins' hashlittle2, returning the primary and secondary hash of data
+func lookup3(data []byte, seed1, seed2 uint32) (uint32, uint32) {
+	a := 0xdeadbeef + uint32(len(data)) + seed1
+	b, c := a, a
+	c += seed2
+
+	for len(data) > 12 {
+		a += binary.LittleEndian.Uint32(data)
+		b += binary.LittleEndian.Uint32(data[4:])
+		c += binary.LittleEndian.Uint32(data[8:])
+
+		a -= c
+		a ^= bits.RotateLeft32(c, 4)
+		c += b
+		b -= a
+		b ^= bits.RotateLeft32(a, 6)
+		a += c
+		c -= b
+		c ^= bits.RotateLeft32(b, 8)
+		b += a
+		a -= c
+		a ^= bits.RotateLeft32(c, 16)
+		c += b
+		b -= a
+		b ^= bits.RotateLeft32(a, 19)
+		a += c
+		c -= b
+		c ^= bits.RotateLeft32(b, 4)
+		b += a
+
+		data = data[12:]
+	}
+
+	if len(data) == 0 {
+		return c, b
+	}
+
+	// the tail is zero padded to a full block
+	var tail [12]byte
+	copy(tail[:], data)
+	switch {
+	case len(data) > 8:
+		c += binary.LittleEndian.Uint32(tail[8:])
+		fallthrough
+	case len(data) > 4:
+		b += binary.LittleEndian.Uint32(tail[4:])
+		fallthrough
+	default:
+		a += binary.LittleEndian.Uint32(tail[:])
+	}
+
+	c ^= b
+	c -= bits.RotateLeft32(b, 14)
+	a ^= c
+	a -= bits.RotateLeft32(c, 11)
+	b ^= a
+	b -= bits.RotateLeft32(a, 25)
+	c ^= b
+	c -= bits.RotateLeft32(b, 16)
+	a ^= c
+	a -= bits.RotateLeft32(c, 4)
+	b ^= a
+	b -= bits.RotateLeft32(a, 14)
+	c ^= b
+	c -= bits.RotateLeft32(b, 24)
+	return c, b
+}
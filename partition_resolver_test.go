@@ -0,0 +1,31 @@
+package eventhub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup3(t *testing.T) {
+	// reference values from Bob Jenkins' lookup3.c driver
+	c, b := lookup3(nil, 0, 0)
+	assert.Equal(t, uint32(0xdeadbeef), c)
+	assert.Equal(t, uint32(0xdeadbeef), b)
+
+	c, _ = lookup3([]byte("Four score and seven years ago"), 0, 0)
+	assert.Equal(t, uint32(0x17770551), c)
+
+	c, _ = lookup3([]byte("Four score and seven years ago"), 1, 0)
+	assert.Equal(t, uint32(0xcd628161), c)
+}
+
+func TestPartitionIndexForKey(t *testing.T) {
+	for _, key := range []string{"", "a", "partition-key", "Four score and seven years ago"} {
+		index := PartitionIndexForKey(key, 32)
+		assert.True(t, index >= 0 && index < 32, key)
+		assert.Equal(t, index, PartitionIndexForKey(key, 32), "resolution must be deterministic")
+	}
+
+	assert.Equal(t, 0, PartitionIndexForKey("any", 1))
+	assert.Equal(t, 0, PartitionIndexForKey("any", 0))
+}
@@ -0,0 +1,135 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/devigned/tab"
+)
+
+type (
+	// PartitionChange is emitted by WatchPartitions when the partition count or partition IDs of the Event Hub change
+	PartitionChange struct {
+		Previous *HubRuntimeInformation
+		Current  *HubRuntimeInformation
+	}
+)
+
+// Added returns the partition IDs present in Current but not in Previous
+func (pc PartitionChange) Added() []string {
+	return partitionIDsDifference(pc.Current, pc.Previous)
+}
+
+// Removed returns the partition IDs present in Previous but not in Current
+func (pc PartitionChange) Removed() []string {
+	return partitionIDsDifference(pc.Previous, pc.Current)
+}
+
+// WatchPartitions polls the runtime information of the Event Hub every interval and emits a PartitionChange on the
+// returned channel whenever the partition count or partition IDs differ from the previous poll. The initial runtime
+// information is fetched before WatchPartitions returns, so an error is returned if it cannot be fetched. Failures on
+// later polls are traced and the poll is retried on the next interval.
+//
+// The channel is closed when the context is done. Consumers must keep receiving from the channel, as polling waits for
+// each change to be delivered.
+func (h *Hub) WatchPartitions(ctx context.Context, interval time.Duration) (<-chan PartitionChange, error) {
+	span, ctx := h.startSpanFromContext(ctx, "eh.Hub.WatchPartitions")
+	defer span.End()
+
+	if interval <= 0 {
+		return nil, errors.New("partition watch interval must be positive")
+	}
+
+	current, err := h.GetRuntimeInformation(ctx)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+
+	changes := make(chan PartitionChange)
+	go func() {
+		defer close(changes)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := h.GetRuntimeInformation(ctx)
+			if err != nil {
+				tab.For(ctx).Error(err)
+				continue
+			}
+
+			if !partitionsChanged(current, info) {
+				continue
+			}
+
+			select {
+			case changes <- PartitionChange{Previous: current, Current: info}:
+				current = info
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+func partitionsChanged(previous, current *HubRuntimeInformation) bool {
+	if previous.PartitionCount != current.PartitionCount || len(previous.PartitionIDs) != len(current.PartitionIDs) {
+		return true
+	}
+	return len(partitionIDsDifference(current, previous)) > 0
+}
+
+// partitionIDsDifference returns the partition IDs of a which are not in b
+func partitionIDsDifference(a, b *HubRuntimeInformation) []string {
+	if a == nil {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	if b != nil {
+		for _, id := range b.PartitionIDs {
+			known[id] = true
+		}
+	}
+
+	var diff []string
+	for _, id := range a.PartitionIDs {
+		if !known[id] {
+			diff = append(diff, id)
+		}
+	}
+	return diff
+}
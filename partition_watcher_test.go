@@ -0,0 +1,23 @@
+package eventhub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionChange(t *testing.T) {
+	previous := &HubRuntimeInformation{PartitionCount: 2, PartitionIDs: []string{"0", "1"}}
+	assert.False(t, partitionsChanged(previous, &HubRuntimeInformation{PartitionCount: 2, PartitionIDs: []string{"1", "0"}}))
+
+	current := &HubRuntimeInformation{PartitionCount: 4, PartitionIDs: []string{"0", "1", "2", "3"}}
+	assert.True(t, partitionsChanged(previous, current))
+
+	change := PartitionChange{Previous: previous, Current: current}
+	assert.Equal(t, []string{"2", "3"}, change.Added())
+	assert.Empty(t, change.Removed())
+
+	renamed := &HubRuntimeInformation{PartitionCount: 2, PartitionIDs: []string{"0", "2"}}
+	assert.True(t, partitionsChanged(previous, renamed))
+	assert.Equal(t, []string{"1"}, PartitionChange{Previous: previous, Current: renamed}.Removed())
+}
@@ -0,0 +1,169 @@
+package eventhub
+
+//LICENSE
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type (
+	// Partitioner picks, on the client, the partition of events sent with a partition key. By default the service
+	// picks it; a Partitioner is for producers which must keep the key to partition mapping of another system.
+	Partitioner interface {
+		// PartitionIndex returns the index, in the ordered list of partition IDs, of the partition for the key
+		PartitionIndex(partitionKey string, partitionCount int) int
+	}
+
+	// KafkaPartitioner is the Partitioner of the default partitioner of Kafka producers: the positive murmur2 hash of
+	// the key modulo the partition count. Producers migrating from Kafka keep sending the events of a key to the
+	// partition with the same number as before, given the hub has as many partitions as the topic had.
+	KafkaPartitioner struct{}
+)
+
+// HubWithPartitioner configures the Hub to pick the partition of events and batches sent with a partition key with
+// partitioner and send them to that partition, rather than leaving the choice to the service. The events keep their
+// partition key. Events without a partition key are sent as before.
+//
+// The partition IDs of the hub are read on the first send with a key and kept for the life of the Hub.
+func HubWithPartitioner(partitioner Partitioner) HubOption {
+	return func(h *Hub) error {
+		if partitioner == nil {
+			return errors.New("partitioner must not be nil")
+		}
+		h.partitioner = partitioner
+		return nil
+	}
+}
+
+// PartitionIndex returns the index of the partition a Kafka producer would pick for the key
+func (KafkaPartitioner) PartitionIndex(partitionKey string, partitionCount int) int {
+	if partitionCount <= 0 {
+		return 0
+	}
+	return int(murmur2([]byte(partitionKey))&0x7fffffff) % partitionCount
+}
+
+// senderForKey returns the sender for an event or batch with the given partition key: the sender of the partition the
+// partitioner picks when the Hub has one and isn't bound to a partition already, and the sender of one of the links of
+// the Hub otherwise
+func (h *Hub) senderForKey(ctx context.Context, partitionKey *string) (*sender, error) {
+	if h.partitioner == nil || partitionKey == nil || h.senderPartitionID != nil {
+		return h.stripedSender(ctx, partitionKey)
+	}
+
+	h.senderMu.RLock()
+	var s *sender
+	if h.partitionIDs != nil && !h.senderClosed {
+		s = h.partitionSenders[h.partitionIDs[h.partitioner.PartitionIndex(*partitionKey, len(h.partitionIDs))]]
+	}
+	h.senderMu.RUnlock()
+	if s != nil {
+		return s, nil
+	}
+
+	span, ctx := h.startSpanFromContext(ctx, "eh.Hub.senderForKey")
+	defer span.End()
+
+	h.senderMu.Lock()
+	defer h.senderMu.Unlock()
+
+	partitionIDs, err := h.partitionIDsLocked(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return h.partitionSenderLocked(ctx, partitionIDs[h.partitioner.PartitionIndex(*partitionKey, len(partitionIDs))])
+}
+
+// partitionIDsLocked returns the partition IDs of the hub, reading them on first use. h.senderMu must be held.
+func (h *Hub) partitionIDsLocked(ctx context.Context) ([]string, error) {
+	if h.partitionIDs == nil {
+		info, err := h.GetRuntimeInformation(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(info.PartitionIDs) == 0 {
+			return nil, fmt.Errorf("event hub %q reported no partitions", h.name)
+		}
+		h.partitionIDs = info.PartitionIDs
+	}
+	return h.partitionIDs, nil
+}
+
+// partitionSenderLocked returns the sender of a partition, attaching it on first use. h.senderMu must be held.
+func (h *Hub) partitionSenderLocked(ctx context.Context, partitionID string) (*sender, error) {
+	if h.senderClosed {
+		return nil, ErrHubClosed
+	}
+	if s, ok := h.partitionSenders[partitionID]; ok {
+		return s, nil
+	}
+
+	s := &sender{
+		hub:          h,
+		partitionID:  &partitionID,
+		retryOptions: h.senderRetryOptions,
+		cond:         sync.NewCond(&sync.Mutex{}),
+	}
+	if err := s.newSessionAndLink(ctx); err != nil {
+		return nil, err
+	}
+
+	if h.partitionSenders == nil {
+		h.partitionSenders = make(map[string]*sender)
+	}
+	h.partitionSenders[partitionID] = s
+	return s, nil
+}
+
+// closePartitionSenders closes the senders opened for the partitions picked by the partitioner, recording their
+// failures in errs
+func (h *Hub) closePartitionSenders(ctx context.Context, errs *closeErrors) {
+	h.senderMu.Lock()
+	defer h.senderMu.Unlock()
+
+	for partitionID, s := range h.partitionSenders {
+		errs.add(fmt.Sprintf("sender of partition %s", partitionID), s.Close(ctx))
+		delete(h.partitionSenders, partitionID)
+	}
+}
+
+// murmur2 is the 32 bit MurmurHash2 of Kafka's Utils.murmur2, with its seed
+func murmur2(data []byte) uint32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+
+	length := len(data)
+	h := uint32(seed) ^ uint32(length)
+	for ; len(data) >= 4; data = data[4:] {
+		k := binary.LittleEndian.Uint32(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	switch len(data) {
+	case 3:
+		h ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[0])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
@@ -0,0 +1,42 @@
+package eventhub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMurmur2(t *testing.T) {
+	// reference values from the tests of Kafka's Utils.murmur2
+	cases := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for key, expected := range cases {
+		assert.Equal(t, expected, int32(murmur2([]byte(key))), key)
+	}
+}
+
+func TestKafkaPartitioner(t *testing.T) {
+	partitioner := KafkaPartitioner{}
+	// the positive hash of "foobar" is 1357151166
+	assert.Equal(t, 1357151166%32, partitioner.PartitionIndex("foobar", 32))
+	assert.Equal(t, 0, partitioner.PartitionIndex("foobar", 1))
+	assert.Equal(t, 0, partitioner.PartitionIndex("foobar", 0))
+
+	for _, key := range []string{"", "a", "21", "a-little-bit-longer-string"} {
+		index := partitioner.PartitionIndex(key, 12)
+		assert.True(t, index >= 0 && index < 12, key)
+	}
+}
+
+func TestHubWithPartitioner(t *testing.T) {
+	h := new(Hub)
+	assert.Error(t, HubWithPartitioner(nil)(h))
+	assert.NoError(t, HubWithPartitioner(KafkaPartitioner{})(h))
+	assert.Equal(t, KafkaPartitioner{}, h.partitioner)
+}
@@ -0,0 +1,161 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+// defaultPeekIdleTimeout is how long Peek waits for the next event before returning the events it read
+const defaultPeekIdleTimeout = 10 * time.Second
+
+// Peek reads up to maxEvents events of a partition, from the position of the receive options given or from the start
+// of the partition, for tools inspecting the events of a hub. Unlike Receive, Peek neither reads nor writes the offset
+// persister of the Hub, and doesn't replace the receiver of the Hub on the same partition and consumer group, so it can
+// browse a partition while it is being consumed. It returns once it has read maxEvents events or the last event
+// enqueued on the partition when it was called, or once no event arrived for 10 seconds.
+//
+// Peek opens a receiver without an epoch, which the service refuses on consumer groups receiving with an epoch, as
+// Event Processor Hosts do; browse their partitions with another consumer group. ReceiveWithEpoch is rejected, as it
+// would disconnect the receivers of the consumer group.
+func (h *Hub) Peek(ctx context.Context, partitionID string, maxEvents int, opts ...ReceiveOption) ([]*Event, error) {
+	span, ctx := h.startSpanFromContext(ctx, "eh.Hub.Peek")
+	defer span.End()
+
+	if maxEvents <= 0 {
+		err := fmt.Errorf("the number of events to peek must be positive, got %d", maxEvents)
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+
+	r := &receiver{
+		hub:           h,
+		consumerGroup: DefaultConsumerGroup,
+		partitionID:   partitionID,
+		peeking:       true,
+	}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+	}
+	if r.epoch != nil {
+		err := errors.New("peeking with an epoch would disconnect the receivers of the consumer group")
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	if r.checkpoint == (persist.Checkpoint{}) {
+		r.checkpoint = persist.NewCheckpointFromStartOfStream()
+	}
+
+	info, err := h.GetPartitionInformation(ctx, partitionID)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+	if !peekable(r.checkpoint, info) {
+		return nil, nil
+	}
+
+	// the link is only granted credit for the events to read
+	r.prefetchCount = uint32(maxEvents)
+	if r.prefetchCount > defaultPrefetchCount {
+		r.prefetchCount = defaultPrefetchCount
+	}
+	r.prefetchSet = true
+	if err := r.newSessionAndLink(ctx); err != nil {
+		tab.For(ctx).Error(err)
+		return nil, partitionNotFound(r.partitionID, err)
+	}
+	defer func() {
+		if err := r.Close(context.Background()); err != nil {
+			tab.For(ctx).Debug(fmt.Sprintf("failed to close peek receiver: %v", err))
+		}
+	}()
+	return r.peek(ctx, info.LastSequenceNumber, maxEvents, defaultPeekIdleTimeout)
+}
+
+// peek reads up to maxEvents events from the link of r, stopping at the event with the sequence number last
+func (r *receiver) peek(ctx context.Context, last int64, maxEvents int, idleTimeout time.Duration) ([]*Event, error) {
+	events := make([]*Event, 0, maxEvents)
+	for len(events) < maxEvents {
+		receiveCtx, cancel := context.WithTimeout(ctx, idleTimeout)
+		msg, err := r.listenForMessage(receiveCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				// no event arrived for the idle timeout
+				break
+			}
+			tab.For(ctx).Error(err)
+			return events, err
+		}
+		r.completeInFlight(ctx, msg)
+		if err := r.receiver.AcceptMessage(ctx, msg); err != nil {
+			tab.For(ctx).Debug(fmt.Sprintf("failed to accept peeked event: %v", err))
+		}
+
+		event, err := eventFromMsg(msg)
+		if err != nil {
+			tab.For(ctx).Error(err)
+			return events, err
+		}
+		events = append(events, event)
+		if props := event.SystemProperties; props != nil && props.SequenceNumber != nil && *props.SequenceNumber >= last {
+			break
+		}
+	}
+	return events, nil
+}
+
+// peekable reports whether events were enqueued on the partition of info after checkpoint
+func peekable(checkpoint persist.Checkpoint, info *HubPartitionRuntimeInformation) bool {
+	if info.LastSequenceNumber < info.BeginningSequenceNumber || info.LastSequenceNumber < 0 {
+		return false
+	}
+
+	switch checkpoint.Offset {
+	case persist.StartOfStream:
+		return true
+	case persist.EndOfStream:
+		return false
+	case "":
+		return checkpoint.EnqueueTime.Before(info.LastEnqueuedTimeUtc)
+	}
+
+	offset, err := strconv.ParseInt(checkpoint.Offset, 10, 64)
+	if err != nil {
+		return true
+	}
+	last, err := strconv.ParseInt(info.LastEnqueuedOffset, 10, 64)
+	return err != nil || offset < last
+}
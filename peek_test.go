@@ -0,0 +1,92 @@
+package eventhub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+func TestReceiver_Peek(t *testing.T) {
+	ns := &namespace{transport: new(memoryTransport)}
+	s, err := ns.amqpTransport().newSession(nil)
+	require.NoError(t, err)
+	link, err := s.NewReceiver()
+	require.NoError(t, err)
+	sess, err := newSession(s)
+	require.NoError(t, err)
+
+	persister := persist.NewMemoryPersister()
+	r := &receiver{
+		hub:           &Hub{name: "hub", namespace: ns, offsetPersister: persister},
+		session:       sess,
+		receiver:      link,
+		consumerGroup: DefaultConsumerGroup,
+		partitionID:   "0",
+		peeking:       true,
+	}
+	go func() {
+		for seq := int64(1); seq <= 3; seq++ {
+			link.(*memoryReceiver).messages <- &amqp.Message{
+				Data:        [][]byte{[]byte("event")},
+				Annotations: amqp.Annotations{sequenceNumberName: seq},
+			}
+		}
+	}()
+
+	ctx := context.Background()
+	events, err := r.peek(ctx, 2, 10, time.Second)
+	require.NoError(t, err)
+	require.Len(t, events, 2, "peeks should stop at the last event enqueued when they started")
+	assert.Equal(t, int64(2), *events[1].SystemProperties.SequenceNumber)
+
+	events, err = r.peek(ctx, 10, 1, time.Second)
+	require.NoError(t, err)
+	require.Len(t, events, 1, "peeks should stop at the number of events asked for")
+
+	events, err = r.peek(ctx, 10, 10, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Empty(t, events, "peeks should return once no event arrives for the idle timeout")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = r.peek(cancelled, 10, 10, time.Second)
+	assert.Error(t, err)
+
+	checkpoint := persist.NewCheckpoint("100", 3, time.Now())
+	require.NoError(t, r.storeLastReceivedCheckpoint(checkpoint))
+	read, err := r.getLastReceivedCheckpoint()
+	require.NoError(t, err)
+	assert.Equal(t, checkpoint, read)
+	read, err = persister.Read("", "hub", DefaultConsumerGroup, "0")
+	require.NoError(t, err)
+	assert.Equal(t, persist.NewCheckpointFromStartOfStream(), read, "peeking receivers should not write the offset persister of the hub")
+}
+
+func TestHub_PeekRejectsInvalidArguments(t *testing.T) {
+	h := &Hub{name: "hub", namespace: &namespace{transport: new(memoryTransport)}}
+	_, err := h.Peek(context.Background(), "0", 0)
+	assert.Error(t, err)
+	_, err = h.Peek(context.Background(), "0", 1, ReceiveWithEpoch(1))
+	assert.Error(t, err)
+}
+
+func TestPeekable(t *testing.T) {
+	lastEnqueued := time.Now()
+	info := &HubPartitionRuntimeInformation{BeginningSequenceNumber: 10, LastSequenceNumber: 49, LastEnqueuedOffset: "4900", LastEnqueuedTimeUtc: lastEnqueued}
+
+	assert.True(t, peekable(persist.NewCheckpointFromStartOfStream(), info))
+	assert.False(t, peekable(persist.NewCheckpointFromEndOfStream(), info))
+	assert.True(t, peekable(persist.NewCheckpoint("1200", 0, time.Time{}), info))
+	assert.False(t, peekable(persist.NewCheckpoint("4900", 0, time.Time{}), info), "nothing was enqueued after the last event")
+	assert.True(t, peekable(persist.NewCheckpoint("", 0, lastEnqueued.Add(-time.Minute)), info))
+	assert.False(t, peekable(persist.NewCheckpoint("", 0, lastEnqueued), info))
+
+	empty := &HubPartitionRuntimeInformation{LastSequenceNumber: -1, LastEnqueuedOffset: persist.StartOfStream}
+	assert.False(t, peekable(persist.NewCheckpointFromStartOfStream(), empty), "nothing can be peeked on an empty partition")
+}
@@ -0,0 +1,122 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"fmt"
+)
+
+// Performance profiles of a Hub, see HubWithPerformanceProfile
+const (
+	// ProfileLowLatency tunes the Hub to get each event across quickly: sends spread over a couple of links so they
+	// don't queue behind each other, SendBatches fills small batches and sends more of them at once, and receivers
+	// keep a small prefetch buffer, so events are handed to the handler soon after they are enqueued and a consumer
+	// taking over a partition doesn't wait for a large buffer to drain.
+	ProfileLowLatency PerformanceProfile = iota + 1
+	// ProfileHighThroughput tunes the Hub to move as many events as it can: sends spread over more links, SendBatches
+	// fills batches to the maximum message size, and receivers keep a large prefetch buffer, so fast handlers never
+	// wait for the next event.
+	ProfileHighThroughput
+)
+
+type (
+	// PerformanceProfile is a set of tuned defaults for the sender links, batch sizes, send concurrency and prefetch of a
+	// Hub
+	PerformanceProfile int
+
+	// performancePreset holds the defaults a profile sets. Zero values leave the default of the Hub in place.
+	performancePreset struct {
+		senderLinks      int
+		prefetchCount    uint32
+		batchMaxSize     MaxMessageSizeInBytes
+		batchConcurrency int
+	}
+)
+
+var performancePresets = map[PerformanceProfile]*performancePreset{
+	ProfileLowLatency: {
+		senderLinks:      2,
+		prefetchCount:    100,
+		batchMaxSize:     64 * 1024,
+		batchConcurrency: 8,
+	},
+	ProfileHighThroughput: {
+		senderLinks:      4,
+		prefetchCount:    3000,
+		batchMaxSize:     DefaultMaxMessageSizeInBytes,
+		batchConcurrency: 8,
+	},
+}
+
+// HubWithPerformanceProfile selects tuned defaults for the Hub rather than tuning each of its knobs by hand. A profile
+// only sets defaults: options which set a knob explicitly take precedence, whichever order they are passed in, as do
+// ReceiveWithPrefetchCount on a receiver and the options of SendBatches on a call.
+//
+//	hub, err := eventhub.NewHubFromEnvironment(eventhub.HubWithPerformanceProfile(eventhub.ProfileHighThroughput))
+func HubWithPerformanceProfile(profile PerformanceProfile) HubOption {
+	return func(h *Hub) error {
+		preset, ok := performancePresets[profile]
+		if !ok {
+			return fmt.Errorf("unknown performance profile %d", profile)
+		}
+		h.preset = preset
+		return nil
+	}
+}
+
+func (p PerformanceProfile) String() string {
+	switch p {
+	case ProfileLowLatency:
+		return "low latency"
+	case ProfileHighThroughput:
+		return "high throughput"
+	}
+	return "unknown"
+}
+
+// applyToHub sets the knobs of the Hub the options left unset
+func (p *performancePreset) applyToHub(h *Hub) {
+	if p == nil {
+		return
+	}
+	if h.senderLinks == 0 {
+		h.senderLinks = p.senderLinks
+	}
+}
+
+// applyToReceiver sets the defaults of a receiver, before its options are applied
+func (p *performancePreset) applyToReceiver(r *receiver) {
+	if p == nil {
+		return
+	}
+	r.prefetchCount = p.prefetchCount
+}
+
+// applyToSendBatches sets the defaults of SendBatches, before its options are applied
+func (p *performancePreset) applyToSendBatches(opts *sendBatchesOptions) {
+	if p == nil {
+		return
+	}
+	opts.maxSize = p.batchMaxSize
+	opts.concurrency = p.batchConcurrency
+}
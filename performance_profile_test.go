@@ -0,0 +1,56 @@
+package eventhub
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-amqp-common-go/v3/aad"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubWithPerformanceProfile(t *testing.T) {
+	h := new(Hub)
+	assert.Error(t, HubWithPerformanceProfile(PerformanceProfile(0))(h))
+	assert.Error(t, HubWithPerformanceProfile(PerformanceProfile(42))(h))
+	assert.Nil(t, h.preset)
+
+	for _, profile := range []PerformanceProfile{ProfileLowLatency, ProfileHighThroughput} {
+		h, err := NewHub("test", "test", &aad.TokenProvider{}, HubWithPerformanceProfile(profile))
+		require.NoError(t, err, profile.String())
+		assert.Equal(t, performancePresets[profile].senderLinks, h.senderLinks, profile.String())
+
+		r := &receiver{prefetchCount: defaultPrefetchCount}
+		h.preset.applyToReceiver(r)
+		assert.Equal(t, performancePresets[profile].prefetchCount, r.prefetchCount, profile.String())
+
+		opts := &sendBatchesOptions{concurrency: defaultSendBatchesConcurrency, maxSize: DefaultMaxMessageSizeInBytes}
+		h.preset.applyToSendBatches(opts)
+		assert.Equal(t, performancePresets[profile].batchConcurrency, opts.concurrency, profile.String())
+		assert.Equal(t, performancePresets[profile].batchMaxSize, opts.maxSize, profile.String())
+	}
+}
+
+func TestHubWithPerformanceProfile_ExplicitOptionsWin(t *testing.T) {
+	h, err := NewHub("test", "test", &aad.TokenProvider{}, HubWithSenderLinks(8), HubWithPerformanceProfile(ProfileHighThroughput))
+	require.NoError(t, err)
+	assert.Equal(t, 8, h.senderLinks)
+
+	h, err = NewHub("test", "test", &aad.TokenProvider{}, HubWithPerformanceProfile(ProfileLowLatency), HubWithSenderLinks(1))
+	require.NoError(t, err)
+	assert.Equal(t, 1, h.senderLinks)
+
+	r := &receiver{prefetchCount: defaultPrefetchCount}
+	h.preset.applyToReceiver(r)
+	require.NoError(t, ReceiveWithPrefetchCount(500)(r))
+	assert.Equal(t, uint32(500), r.prefetchCount)
+}
+
+func TestHub_WithoutPerformanceProfile(t *testing.T) {
+	h, err := NewHub("test", "test", &aad.TokenProvider{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, h.senderLinks)
+
+	r := &receiver{prefetchCount: defaultPrefetchCount}
+	h.preset.applyToReceiver(r)
+	assert.Equal(t, uint32(defaultPrefetchCount), r.prefetchCount)
+}
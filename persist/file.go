@@ -23,13 +23,14 @@ package persist
 //	SOFTWARE
 
 import (
-	"bytes"
 	"encoding/json"
 	"io"
 	"os"
 	"path"
 	"strings"
 	"sync"
+
+	"github.com/Azure/azure-event-hubs-go/v3/internal/buffer"
 )
 
 type (
@@ -83,7 +84,10 @@ func (fp *FilePersister) Read(namespace, name, consumerGroup, partitionID string
 		return NewCheckpointFromStartOfStream(), err
 	}
 
-	buf := bytes.NewBuffer(nil)
+	defer func() { _ = f.Close() }()
+
+	buf := buffer.Get()
+	defer buffer.Put(buf)
 	_, err = io.Copy(buf, f)
 	if err != nil {
 		return NewCheckpointFromStartOfStream(), err
@@ -0,0 +1,174 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+type (
+	// proxyFunc returns the proxy to use to reach the given request URL, or nil to connect directly
+	proxyFunc func(req *http.Request) (*url.URL, error)
+
+	// bufferedConn is a net.Conn whose reads are served from a bufio.Reader which may already hold bytes read from the
+	// connection
+	bufferedConn struct {
+		net.Conn
+		reader *bufio.Reader
+	}
+)
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// dialProxy opens a connection to addr (host:port) through the proxy. HTTP and HTTPS proxies are asked to tunnel the
+// connection with CONNECT; SOCKS5 proxies are supported with the socks5 scheme. Credentials are taken from the user
+// info of the proxy URL. The connection to the proxy itself is opened with dial. Dialing the proxy and asking it for
+// the tunnel are bounded by ctx.
+func dialProxy(ctx context.Context, dial DialFunc, proxyURL *url.URL, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = defaultDial
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https":
+		return dialHTTPProxy(ctx, dial, proxyURL, addr)
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+		}
+
+		dialer, err := proxy.SOCKS5("tcp", proxyHostPort(proxyURL), auth, dialFuncDialer(dial))
+		if err != nil {
+			return nil, err
+		}
+		if contextDialer, ok := dialer.(proxy.ContextDialer); ok {
+			return contextDialer.DialContext(ctx, "tcp", addr)
+		}
+		return dialer.Dial("tcp", addr)
+	default:
+		return nil, fmt.Errorf("proxy scheme %q is not supported; use http, https or socks5", proxyURL.Scheme)
+	}
+}
+
+func dialHTTPProxy(ctx context.Context, dial DialFunc, proxyURL *url.URL, addr string) (net.Conn, error) {
+	conn, err := dial(ctx, "tcp", proxyHostPort(proxyURL))
+	if err != nil {
+		return nil, err
+	}
+
+	// the deadline of ctx covers the TLS handshake with the proxy and the CONNECT exchange as well as dialing
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", proxyURL.Host, addr, res.Status)
+	}
+
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// proxyHostPort returns the host:port of the proxy, filling in the default port of the scheme if it is missing
+func proxyHostPort(proxyURL *url.URL) string {
+	if proxyURL.Port() != "" {
+		return proxyURL.Host
+	}
+
+	port := "80"
+	switch proxyURL.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// resolveProxy returns the proxy the namespace should use to reach host, or nil if the connection should be direct
+func (ns *namespace) resolveProxy(host string) (*url.URL, error) {
+	if ns.proxy == nil {
+		return nil, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://"+host, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ns.proxy(req)
+}
@@ -0,0 +1,120 @@
+package eventhub
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyHostPort(t *testing.T) {
+	for raw, expected := range map[string]string{
+		"http://proxy":         "proxy:80",
+		"https://proxy":        "proxy:443",
+		"socks5://proxy":       "proxy:1080",
+		"http://proxy:3128":    "proxy:3128",
+		"http://user:p@proxy/": "proxy:80",
+	} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		assert.Equal(t, expected, proxyHostPort(u), raw)
+	}
+}
+
+func TestDialHTTPProxy(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	requests := make(chan *http.Request, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		requests <- req
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\nhello"))
+	}()
+
+	proxyURL, err := url.Parse("http://user:secret@" + listener.Addr().String())
+	require.NoError(t, err)
+
+	conn, err := dialProxy(context.Background(), nil, proxyURL, "ns.servicebus.windows.net:5671")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	req := <-requests
+	assert.Equal(t, http.MethodConnect, req.Method)
+	assert.Equal(t, "ns.servicebus.windows.net:5671", req.Host)
+	assert.Equal(t, "Basic dXNlcjpzZWNyZXQ=", req.Header.Get("Proxy-Authorization"))
+
+	// bytes sent by the proxy after the CONNECT response belong to the tunneled connection
+	buf := make([]byte, 5)
+	_, err = conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+}
+
+func TestDialHTTPProxy_Refused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = http.ReadRequest(bufio.NewReader(conn))
+		_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n"))
+	}()
+
+	proxyURL, err := url.Parse("http://" + listener.Addr().String())
+	require.NoError(t, err)
+
+	_, err = dialProxy(context.Background(), nil, proxyURL, "ns.servicebus.windows.net:5671")
+	assert.Error(t, err)
+
+	_, err = dialProxy(context.Background(), nil, &url.URL{Scheme: "ftp", Host: "proxy"}, "ns.servicebus.windows.net:5671")
+	assert.Error(t, err)
+}
+
+func TestDialHTTPProxy_Unresponsive(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// read the CONNECT request, but never answer it
+		_, _ = http.ReadRequest(bufio.NewReader(conn))
+		time.Sleep(5 * time.Second)
+	}()
+
+	proxyURL, err := url.Parse("http://" + listener.Addr().String())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = dialProxy(ctx, nil, proxyURL, "ns.servicebus.windows.net:5671")
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 2*time.Second, "an unresponsive proxy should not outlast the deadline of the dial")
+}
@@ -0,0 +1,66 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+type (
+	// ReceiveSettings are the settings of a receiver which ReceiveOptions configure, for implementations of
+	// PartitionedReceiver other than Hub, such as test doubles, which can't apply the options themselves
+	ReceiveSettings struct {
+		// ConsumerGroup is the consumer group to receive with, DefaultConsumerGroup unless configured
+		ConsumerGroup string
+		// StartingCheckpoint is the position to start receiving after; the zero value when none was configured, in
+		// which case a Hub resumes from its offset persister
+		StartingCheckpoint persist.Checkpoint
+		// Epoch is the epoch of the receiver, if it has one
+		Epoch *int64
+		// PrefetchCount is the number of events to fetch ahead of the handler
+		PrefetchCount uint32
+		// EventPooling is set if the handler is to be given pooled events, see ReceiveWithEventPooling and PoolEvents
+		EventPooling bool
+	}
+)
+
+// ResolveReceiveOptions applies opts to the defaults of a receiver and returns the settings they result in
+func ResolveReceiveOptions(opts ...ReceiveOption) (*ReceiveSettings, error) {
+	r := &receiver{
+		consumerGroup: DefaultConsumerGroup,
+		prefetchCount: defaultPrefetchCount,
+	}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ReceiveSettings{
+		ConsumerGroup:      r.consumerGroup,
+		StartingCheckpoint: r.checkpoint,
+		Epoch:              r.epoch,
+		PrefetchCount:      r.prefetchCount,
+		EventPooling:       r.pooled,
+	}, nil
+}
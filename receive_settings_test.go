@@ -0,0 +1,78 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+func TestResolveReceiveOptions(t *testing.T) {
+	settings, err := ResolveReceiveOptions()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultConsumerGroup, settings.ConsumerGroup)
+	assert.Equal(t, persist.Checkpoint{}, settings.StartingCheckpoint)
+	assert.Nil(t, settings.Epoch)
+	assert.Equal(t, uint32(defaultPrefetchCount), settings.PrefetchCount)
+	assert.False(t, settings.EventPooling)
+
+	settings, err = ResolveReceiveOptions(
+		ReceiveWithConsumerGroup("analytics"),
+		ReceiveWithStartingOffset("42"),
+		ReceiveWithEpoch(3),
+		ReceiveWithPrefetchCount(10),
+		ReceiveWithEventPooling())
+	require.NoError(t, err)
+	assert.Equal(t, "analytics", settings.ConsumerGroup)
+	assert.Equal(t, "42", settings.StartingCheckpoint.Offset)
+	require.NotNil(t, settings.Epoch)
+	assert.Equal(t, int64(3), *settings.Epoch)
+	assert.Equal(t, uint32(10), settings.PrefetchCount)
+	assert.True(t, settings.EventPooling)
+
+	_, err = ResolveReceiveOptions(ReceiveWithAdaptivePrefetch(10, 1))
+	assert.Error(t, err)
+}
+
+func TestNewListenerHandle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var failure error
+	closed := false
+	handle := NewListenerHandle(ctx, func(context.Context) error {
+		closed = true
+		cancel()
+		return nil
+	}, func() error { return failure })
+
+	assert.NoError(t, handle.Err())
+	assert.Equal(t, errDrainNotEnabled, handle.Pause(ctx))
+	assert.Nil(t, handle.InFlight())
+
+	failure = errors.New("disconnected")
+	assert.Equal(t, failure, handle.Err())
+
+	require.NoError(t, handle.Close(context.Background()))
+	assert.True(t, closed)
+	<-handle.Done()
+}
+
+func TestEvent_GetCheckpointFromSystemProperties(t *testing.T) {
+	offset, sequenceNumber := int64(128), int64(7)
+	enqueued := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	event := NewEventFromString("data")
+	event.SystemProperties = &SystemProperties{
+		Offset:         &offset,
+		SequenceNumber: &sequenceNumber,
+		EnqueuedTime:   &enqueued,
+	}
+
+	checkpoint := event.GetCheckpoint()
+	assert.Equal(t, "128", checkpoint.Offset)
+	assert.Equal(t, sequenceNumber, checkpoint.SequenceNumber)
+	assert.Equal(t, enqueued, checkpoint.EnqueueTime)
+}
@@ -25,9 +25,10 @@ package eventhub
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	common "github.com/Azure/azure-amqp-common-go/v3"
 	"github.com/Azure/go-amqp"
 	"github.com/devigned/tab"
 
@@ -51,17 +52,48 @@ const (
 // receiver provides session and link handling for a receiving entity path
 type (
 	receiver struct {
-		hub           *Hub
+		// lastActivity is the time, in Unix nanoseconds, the connection last showed signs of life
+		lastActivity int64
+		hub          *Hub
+		// connMu guards the connection and the link, which recovery replaces while events are handled
+		connMu        sync.RWMutex
 		connection    *amqp.Client
 		session       *session
-		receiver      *amqp.Receiver
+		receiver      amqpReceiver
 		consumerGroup string
 		partitionID   string
 		prefetchCount uint32
+		prefetchSet   bool
 		done          func()
 		epoch         *int64
 		lastError     error
 		checkpoint    persist.Checkpoint
+		// lastSequence is the sequence number of the last event delivered to the handler, if any
+		lastSequence *int64
+
+		// manualCredit is set by ReceiveWithDrain; paused is 1 while no credit is granted
+		manualCredit bool
+		paused       int32
+		// closing is 1 once Close drains the link, so the listener stops rather than recovers when the link closes
+		closing int32
+		// listening is closed once the listener stops receiving from the link
+		listening     chan struct{}
+		stopListening sync.Once
+		// inFlight holds the events received from the link and not yet completed by the handler, if manualCredit is
+		// set; inFlightIdle is closed once the last of them completes
+		inFlightMu   sync.Mutex
+		inFlight     map[*amqp.Message]amqpReceiver
+		inFlightIdle chan struct{}
+
+		// pooled is set by ReceiveWithEventPooling
+		pooled bool
+		// adaptive is set by ReceiveWithAdaptivePrefetch
+		adaptive *adaptivePrefetch
+		// checkpoints is set by ReceiveWithCheckpointManager
+		checkpoints *CheckpointManager
+		// peeking is set for the receivers of Peek, which keep their position to themselves rather than in the offset
+		// persister of the Hub
+		peeking bool
 	}
 
 	// ReceiveOption provides a structure for configuring receivers
@@ -71,6 +103,9 @@ type (
 	ListenerHandle struct {
 		r   *receiver
 		ctx context.Context
+		// close and err back the handles of listeners other than the receivers of a Hub, see NewListenerHandle
+		close func(ctx context.Context) error
+		err   func() error
 	}
 )
 
@@ -106,10 +141,12 @@ func ReceiveFromTimestamp(t time.Time) ReceiveOption {
 	}
 }
 
-// ReceiveWithPrefetchCount configures the receiver to attempt to fetch as many messages as the prefetch amount
+// ReceiveWithPrefetchCount configures the receiver to attempt to fetch as many messages as the prefetch amount. It
+// takes precedence over the prefetch count set with Hub.SetConfig.
 func ReceiveWithPrefetchCount(prefetch uint32) ReceiveOption {
 	return func(receiver *receiver) error {
 		receiver.prefetchCount = prefetch
+		receiver.prefetchSet = true
 		return nil
 	}
 }
@@ -142,6 +179,8 @@ func (h *Hub) newReceiver(ctx context.Context, partitionID string, opts ...Recei
 		prefetchCount: defaultPrefetchCount,
 		partitionID:   partitionID,
 	}
+	h.preset.applyToReceiver(receiver)
+	receiver.refreshPrefetch()
 
 	// apply options after fetching the persisted checkpoint in case the options
 	// specify a custom checkpoint to start from. This allows the custom
@@ -152,6 +191,11 @@ func (h *Hub) newReceiver(ctx context.Context, partitionID string, opts ...Recei
 		}
 	}
 
+	if err := h.ensureConsumerGroup(ctx, receiver.consumerGroup); err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+
 	// update checkpoint if no checkpoint is specified and if old checkpoint is successfully read from e.g. file or memory
 	if receiver.checkpoint == (persist.Checkpoint{}) {
 		oldCheckpoint, err := receiver.getLastReceivedCheckpoint()
@@ -166,7 +210,10 @@ func (h *Hub) newReceiver(ctx context.Context, partitionID string, opts ...Recei
 	}
 
 	tab.For(ctx).Debug("creating a new receiver")
-	return receiver, receiver.newSessionAndLink(ctx)
+	if err := receiver.newSessionAndLink(ctx); err != nil {
+		return receiver, partitionNotFound(partitionID, err)
+	}
+	return receiver, nil
 }
 
 // Close will close the AMQP session and link of the receiver
@@ -174,18 +221,36 @@ func (r *receiver) Close(ctx context.Context) error {
 	span, _ := r.startConsumerSpanFromContext(ctx, "eh.receiver.Close")
 	defer span.End()
 
-	if r.done != nil {
+	// hand events the service already transferred to the handler before the link goes away
+	draining := r.manualCredit && r.listening != nil
+	if draining {
+		if err := r.pause(ctx); err != nil {
+			tab.For(ctx).Debug("failed to drain receiver before closing: " + err.Error())
+		}
+		atomic.StoreInt32(&r.closing, 1)
+	} else if r.done != nil {
 		r.done()
 	}
 
-	err := r.receiver.Close(ctx)
+	err := r.currentLink().Close(ctx)
+	r.hub.namespace.notifyConnection(ConnectionEvent{Type: ConnectionEventLinkDetached, Entity: r.getAddress()})
+	if draining {
+		if drainErr := r.awaitDrained(ctx); drainErr != nil {
+			tab.For(ctx).Debug("failed to hand drained events to the handler before closing: " + drainErr.Error())
+		}
+		r.done()
+	}
+	if r.checkpoints != nil {
+		// events still in flight are received again by the next receiver of the partition
+		r.checkpoints.Reset(r.partitionID)
+	}
 	if err != nil {
 		tab.For(ctx).Error(err)
 		if sessionErr := r.session.Close(ctx); sessionErr != nil {
 			tab.For(ctx).Error(sessionErr)
 		}
 
-		if connErr := r.connection.Close(); connErr != nil {
+		if connErr := r.hub.namespace.releaseConnection(r, r.connection); connErr != nil {
 			tab.For(ctx).Error(connErr)
 		}
 
@@ -195,14 +260,14 @@ func (r *receiver) Close(ctx context.Context) error {
 	if sessionErr := r.session.Close(ctx); sessionErr != nil {
 		tab.For(ctx).Error(sessionErr)
 
-		if connErr := r.connection.Close(); connErr != nil {
+		if connErr := r.hub.namespace.releaseConnection(r, r.connection); connErr != nil {
 			tab.For(ctx).Error(connErr)
 		}
 
 		return sessionErr
 	}
 
-	return r.connection.Close()
+	return r.hub.namespace.releaseConnection(r, r.connection)
 }
 
 // Recover will attempt to close the current session and link, then rebuild them
@@ -210,7 +275,22 @@ func (r *receiver) Recover(ctx context.Context) error {
 	span, ctx := r.startConsumerSpanFromContext(ctx, "eh.receiver.Recover")
 	defer span.End()
 
-	_ = r.connection.Close() // we expect the receiver is in an error state
+	if r.checkpoints != nil {
+		// the events after the last checkpoint are received again
+		r.checkpoints.Reset(r.partitionID)
+	}
+
+	// we expect the link, session or connection is in an error state, ignore errors
+	closeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if link := r.currentLink(); link != nil {
+		_ = link.Close(closeCtx)
+	}
+	if r.session != nil {
+		// releases the share of a session shared with other links
+		_ = r.session.Close(closeCtx)
+	}
+	_ = r.hub.namespace.discardConnection(r, r.connection)
 	return r.newSessionAndLink(ctx)
 }
 
@@ -218,6 +298,7 @@ func (r *receiver) Recover(ctx context.Context) error {
 func (r *receiver) Listen(handler Handler) *ListenerHandle {
 	ctx, done := context.WithCancel(context.Background())
 	r.done = done
+	r.listening = make(chan struct{})
 
 	span, ctx := r.startConsumerSpanFromContext(ctx, "eh.receiver.Listen")
 	defer span.End()
@@ -225,6 +306,10 @@ func (r *receiver) Listen(handler Handler) *ListenerHandle {
 	messages := make(chan *amqp.Message)
 	go r.listenForMessages(ctx, messages)
 	go r.handleMessages(ctx, messages, handler)
+	if r.hub.keepAlive != nil {
+		r.markActivity()
+		go r.monitorConnection(ctx, r.hub.keepAlive)
+	}
 
 	return &ListenerHandle{
 		r:   r,
@@ -247,36 +332,74 @@ func (r *receiver) handleMessages(ctx context.Context, messages chan *amqp.Messa
 
 func (r *receiver) handleMessage(ctx context.Context, msg *amqp.Message, handler Handler) {
 	const optName = "eh.Receiver.handleMessage"
+	defer r.completeInFlight(ctx, msg)
 
-	event, err := eventFromMsg(msg)
+	event, err := r.eventFromMsg(msg)
 	if err != nil {
 		tab.For(ctx).Error(err)
-		r.lastError = err
-		r.done()
+		r.hub.reportError(ErrorEventDecode, r.getAddress(), err)
+		if r.hub.retryClassifier.Retry(err, false) {
+			r.hub.log(ctx, LogLevelWarn, "skipping event which failed to decode", "entity", r.getAddress(), "messageID", messageID(msg), "error", err)
+		} else {
+			r.lastError = err
+			r.done()
+		}
+		// a partially decoded event is never handed to the handler
+		r.releaseEvent(event)
+		return
 	}
+	defer r.releaseEvent(event)
+	event.codecs = &r.hub.codecs
 
-	ctx, span := tab.StartSpanWithRemoteParent(ctx, optName, event)
+	ctx, span := tab.StartSpanWithRemoteParent(ctx, optName, remoteTraceContext(event))
 	defer span.End()
 
+	span.AddAttributes(
+		tab.StringAttribute("span.kind", "consumer"),
+		tab.StringAttribute("eh.partition", r.partitionID),
+		tab.StringAttribute("eh.consumer_group", r.consumerGroup),
+	)
+	applyHubInfo(span, r.hub)
 	id := messageID(msg)
 	if str, ok := id.(string); ok {
 		span.AddAttributes(tab.StringAttribute("eh.message_id", str))
 	}
+	if event != nil && event.SystemProperties != nil && event.SystemProperties.SequenceNumber != nil {
+		span.AddAttributes(tab.Int64Attribute("eh.sequence_number", *event.SystemProperties.SequenceNumber))
+	}
 
+	r.hub.metrics.observeReceive(r.consumerGroup, r.partitionID, event)
+	r.checkSequence(ctx, event)
+	handlerStart := time.Now()
+	r.hub.metrics.observeFreshness(r.consumerGroup, r.partitionID, event, handlerStart)
+	if r.checkpoints != nil {
+		r.checkpoints.Track(r.partitionID, event, r.storeLastReceivedCheckpoint)
+	}
 	err = handler(ctx, event)
+	handlerTime := time.Since(handlerStart)
+	r.observeHandlerTime(handlerTime)
+	r.hub.metrics.observeHandler(r.consumerGroup, r.partitionID, handlerTime)
+	r.checkHandlerDuration(ctx, event, handlerTime)
+	r.hub.stats.observeReceive(event, err)
 	if err != nil {
-		err = r.receiver.ModifyMessage(ctx, msg, true, false, nil)
+		r.hub.log(ctx, LogLevelWarn, "handler failed; releasing event for redelivery", "entity", r.getAddress(), "messageID", id, "error", err)
+		r.hub.reportError(ErrorEventHandler, r.getAddress(), err)
+		err = r.currentLink().ModifyMessage(ctx, msg, true, false, nil)
 		if err != nil {
 			tab.For(ctx).Error(err)
 		}
 		tab.For(ctx).Error(fmt.Errorf("message modified(true, false, nil): id: %v", id))
 		return
 	}
-	err = r.receiver.AcceptMessage(ctx, msg)
+	err = r.currentLink().AcceptMessage(ctx, msg)
 	if err != nil {
 		tab.For(ctx).Error(err)
 	}
 
+	if r.checkpoints != nil {
+		// the checkpoint advances as the manager completes events
+		return
+	}
 	err = r.storeLastReceivedCheckpoint(event.GetCheckpoint())
 	if err != nil {
 		tab.For(ctx).Error(err)
@@ -286,6 +409,7 @@ func (r *receiver) handleMessage(ctx context.Context, msg *amqp.Message, handler
 func (r *receiver) listenForMessages(ctx context.Context, msgChan chan *amqp.Message) {
 	span, ctx := r.startConsumerSpanFromContext(ctx, "eh.receiver.listenForMessages")
 	defer span.End()
+	defer r.stopListening.Do(func() { close(r.listening) })
 
 	for {
 		msg, err := r.listenForMessage(ctx)
@@ -306,34 +430,35 @@ func (r *receiver) listenForMessages(ctx context.Context, msgChan chan *amqp.Mes
 			tab.For(ctx).Debug("context done")
 			return
 		default:
-			if amqpErr, ok := err.(*amqp.DetachError); ok && amqpErr.RemoteError != nil && amqpErr.RemoteError.Condition == "amqp:link:stolen" {
-				tab.For(ctx).Debug("link has been stolen by a higher epoch")
-				_ = r.Close(ctx)
+			if atomic.LoadInt32(&r.closing) == 1 {
+				// Close closed the drained link, and every event it buffered was handed over
 				return
 			}
 
-			_, retryErr := common.Retry(10, 10*time.Second, func() (interface{}, error) {
-				sp, ctx := r.startConsumerSpanFromContext(ctx, "eh.receiver.listenForMessages.tryRecover")
-				defer sp.End()
-
-				tab.For(ctx).Debug("recovering connection")
-				err := r.Recover(ctx)
-				if err == nil {
-					tab.For(ctx).Debug("recovered connection")
-					return nil, nil
+			amqpErr, ok := err.(*amqp.DetachError)
+			stolen := ok && amqpErr.RemoteError != nil && amqpErr.RemoteError.Condition == "amqp:link:stolen"
+			if !r.hub.retryClassifier.Retry(fromAMQPError(err), !stolen) {
+				if stolen {
+					tab.For(ctx).Debug("link has been stolen by a higher epoch")
+					r.hub.log(ctx, LogLevelWarn, "receiver link stolen by a receiver with a higher epoch", "entity", r.getAddress())
+				} else {
+					r.hub.log(ctx, LogLevelError, "receiver link failed with an error classified as not retryable", "entity", r.getAddress(), "error", err)
+					r.lastError = fromAMQPError(err)
 				}
+				r.stopListening.Do(func() { close(r.listening) })
+				_ = r.Close(ctx)
+				return
+			}
 
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				default:
-					return nil, common.Retryable(err.Error())
-				}
-			})
+			r.hub.namespace.notifyConnection(ConnectionEvent{Type: ConnectionEventLinkDetached, Entity: r.getAddress(), Err: err})
+			r.hub.log(ctx, LogLevelWarn, "receiver link detached", "entity", r.getAddress(), "error", err)
+			r.hub.reportError(ErrorEventLinkDetached, r.getAddress(), err)
+			retryErr := r.hub.recoverLink(ctx, r.getAddress(), err, r.Recover)
 
 			if retryErr != nil {
 				tab.For(ctx).Debug("retried, but error was unrecoverable")
-				r.lastError = retryErr
+				r.lastError = fromAMQPError(retryErr)
+				r.stopListening.Do(func() { close(r.listening) })
 				_ = r.Close(ctx)
 				return
 			}
@@ -350,6 +475,8 @@ func (r *receiver) listenForMessage(ctx context.Context) (*amqp.Message, error)
 		tab.For(ctx).Debug(err.Error())
 		return nil, err
 	}
+	r.markActivity()
+	r.trackInFlight(msg)
 
 	id := messageID(msg)
 	if str, ok := id.(string); ok {
@@ -363,11 +490,14 @@ func (r *receiver) newSessionAndLink(ctx context.Context) error {
 	span, ctx := r.startConsumerSpanFromContext(ctx, "eh.receiver.newSessionAndLink")
 	defer span.End()
 
-	connection, err := r.hub.namespace.newConnection()
+	r.refreshPrefetch()
+	connection, err := r.hub.namespace.acquireConnection(r)
 	if err != nil {
 		return err
 	}
+	r.connMu.Lock()
 	r.connection = connection
+	r.connMu.Unlock()
 
 	address := r.getAddress()
 	err = r.hub.namespace.negotiateClaim(ctx, connection, address)
@@ -376,7 +506,7 @@ func (r *receiver) newSessionAndLink(ctx context.Context) error {
 		return err
 	}
 
-	amqpSession, err := connection.NewSession()
+	sess, err := r.hub.namespace.amqpTransport().newSession(connection)
 	if err != nil {
 		tab.For(ctx).Error(err)
 		return err
@@ -391,7 +521,7 @@ func (r *receiver) newSessionAndLink(ctx context.Context) error {
 
 	offsetExpression := getOffsetExpression(checkpoint)
 
-	r.session, err = newSession(amqpSession)
+	r.session, err = newSession(sess)
 	if err != nil {
 		tab.For(ctx).Error(err)
 		return err
@@ -399,30 +529,58 @@ func (r *receiver) newSessionAndLink(ctx context.Context) error {
 
 	opts := []amqp.LinkOption{
 		amqp.LinkSourceAddress(address),
-		amqp.LinkCredit(r.prefetchCount),
+		amqp.LinkCredit(r.linkCredit()),
 		amqp.LinkReceiverSettle(amqp.ModeFirst),
 		amqp.LinkSelectorFilter(offsetExpression),
 	}
 
+	if r.manualCredit {
+		opts = append(opts, amqp.LinkWithManualCredits())
+	}
+
 	if r.epoch != nil {
 		opts = append(opts, amqp.LinkPropertyInt64(epochKey, *r.epoch))
 	}
 
-	amqpReceiver, err := amqpSession.NewReceiver(opts...)
+	amqpReceiver, err := sess.NewReceiver(opts...)
 	if err != nil {
 		tab.For(ctx).Error(err)
 		return err
 	}
 
+	if r.manualCredit && atomic.LoadInt32(&r.paused) == 0 {
+		if err := amqpReceiver.IssueCredit(r.initialCredit()); err != nil {
+			tab.For(ctx).Error(err)
+			return err
+		}
+	}
+
+	r.connMu.Lock()
 	r.receiver = amqpReceiver
+	r.connMu.Unlock()
+	r.hub.namespace.notifyConnection(ConnectionEvent{Type: ConnectionEventLinkAttached, Entity: address})
 	return nil
 }
 
+// currentLink returns the link of the receiver, which recovery may replace concurrently
+func (r *receiver) currentLink() amqpReceiver {
+	r.connMu.RLock()
+	defer r.connMu.RUnlock()
+	return r.receiver
+}
+
 func (r *receiver) getLastReceivedCheckpoint() (persist.Checkpoint, error) {
+	if r.peeking {
+		return r.checkpoint, nil
+	}
 	return r.offsetPersister().Read(r.namespaceName(), r.hubName(), r.consumerGroup, r.partitionID)
 }
 
 func (r *receiver) storeLastReceivedCheckpoint(checkpoint persist.Checkpoint) error {
+	if r.peeking {
+		r.checkpoint = checkpoint
+		return nil
+	}
 	return r.offsetPersister().Write(r.namespaceName(), r.hubName(), r.consumerGroup, r.partitionID, checkpoint)
 }
 
@@ -463,6 +621,9 @@ func messageID(msg *amqp.Message) interface{} {
 
 // Close will close the listener
 func (lc *ListenerHandle) Close(ctx context.Context) error {
+	if lc.r == nil {
+		return lc.close(ctx)
+	}
 	return lc.r.Close(ctx)
 }
 
@@ -473,12 +634,33 @@ func (lc *ListenerHandle) Done() <-chan struct{} {
 
 // Err will return the last error encountered
 func (lc *ListenerHandle) Err() error {
+	if lc.r == nil {
+		if lc.err != nil {
+			if err := lc.err(); err != nil {
+				return err
+			}
+		}
+		return lc.ctx.Err()
+	}
+
 	if lc.r.lastError != nil {
 		return lc.r.lastError
 	}
 	return lc.ctx.Err()
 }
 
+// NewListenerHandle creates the ListenerHandle of a listener other than the receivers of a Hub, for implementations of
+// PartitionedReceiver such as test doubles. The handle is done when ctx is, closing it calls closeFunc, and Err returns
+// the error errFunc returns, if errFunc is not nil and the error isn't, or the error of ctx. Handles created this way
+// don't support Pause and Resume.
+func NewListenerHandle(ctx context.Context, closeFunc func(ctx context.Context) error, errFunc func() error) *ListenerHandle {
+	return &ListenerHandle{
+		ctx:   ctx,
+		close: closeFunc,
+		err:   errFunc,
+	}
+}
+
 // getOffsetExpression calculates a selector expression based on the Offset or EnqueueTime of a Checkpoint.
 func getOffsetExpression(checkpoint persist.Checkpoint) string {
 	if checkpoint.Offset == "" {
@@ -0,0 +1,172 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/devigned/tab"
+	"github.com/jpillora/backoff"
+)
+
+type (
+	// RecoveryEvent reports progress rebuilding the connection, authorization and link of a sender or receiver after
+	// a failure. Receivers resume from the last event they handled.
+	RecoveryEvent struct {
+		// Entity is the address of the link being recovered
+		Entity string
+		// Attempt is the 1-based number of the recovery attempt
+		Attempt int
+		// Err is the error which triggered recovery, or which failed the previous attempt
+		Err error
+		// Delay is the time waited before the attempt
+		Delay time.Duration
+		// Recovered is set once the link has been rebuilt
+		Recovered bool
+		// GaveUp is set when no further attempts will be made
+		GaveUp bool
+	}
+
+	// RecoveryListener is called as recovery progresses. It is called synchronously from the recovering sender or
+	// receiver, so it must not block.
+	RecoveryListener func(event RecoveryEvent)
+
+	recoveryOptions struct {
+		backoff *backoff.Backoff
+
+		// maxAttempts bounds the number of recovery attempts of a receiver; < 0 retries until the receiver is closed
+		maxAttempts int
+
+		listener RecoveryListener
+	}
+)
+
+func newRecoveryOptions() *recoveryOptions {
+	return &recoveryOptions{
+		backoff: &backoff.Backoff{
+			Min:    500 * time.Millisecond,
+			Max:    30 * time.Second,
+			Factor: 2,
+			Jitter: true,
+		},
+		maxAttempts: 10,
+	}
+}
+
+// HubWithRecoveryBackoff configures the exponential backoff, with jitter, between attempts to recover a receiver
+// after its connection or link fails, and the number of attempts made before the receiver gives up and closes. A
+// negative maxAttempts retries until the receiver is closed.
+func HubWithRecoveryBackoff(min, max time.Duration, maxAttempts int) HubOption {
+	return func(h *Hub) error {
+		if min <= 0 || max < min {
+			return fmt.Errorf("recovery backoff requires 0 < min <= max, got min %v and max %v", min, max)
+		}
+
+		if maxAttempts == 0 {
+			return fmt.Errorf("recovery requires at least one attempt")
+		}
+
+		h.recoveryOptions.backoff.Min = min
+		h.recoveryOptions.backoff.Max = max
+		h.recoveryOptions.maxAttempts = maxAttempts
+		return nil
+	}
+}
+
+// HubWithRecoveryListener configures the Hub to report the progress of sender and receiver recovery to the listener
+func HubWithRecoveryListener(listener RecoveryListener) HubOption {
+	return func(h *Hub) error {
+		h.recoveryOptions.listener = listener
+		return nil
+	}
+}
+
+// notifyRecovery reports a recovery event to the configured listener, if any
+func (h *Hub) notifyRecovery(event RecoveryEvent) {
+	h.metrics.observeRecovery(event)
+	h.stats.observeRecovery(event)
+
+	if h.recoveryOptions != nil && h.recoveryOptions.listener != nil {
+		h.recoveryOptions.listener(event)
+	}
+
+	if connEvent, ok := connectionEventFromRecovery(event); ok && h.namespace != nil {
+		h.namespace.notifyConnection(connEvent)
+	}
+
+	switch {
+	case event.Recovered:
+		h.log(context.Background(), LogLevelInfo, "link recovered", "entity", event.Entity, "attempt", event.Attempt)
+	case event.GaveUp:
+		h.log(context.Background(), LogLevelError, "gave up recovering link", "entity", event.Entity, "attempt", event.Attempt, "error", event.Err)
+	default:
+		h.log(context.Background(), LogLevelWarn, "recovering link", "entity", event.Entity, "attempt", event.Attempt, "delay", event.Delay, "error", event.Err)
+	}
+}
+
+// recoverLink calls recover with backoff until it succeeds, the attempts are exhausted or the context is done
+func (h *Hub) recoverLink(ctx context.Context, entity string, cause error, recover func(ctx context.Context) error) error {
+	opts := h.recoveryOptions
+	if opts == nil {
+		opts = newRecoveryOptions()
+	}
+
+	// create a per call copy as Duration() modifies its state
+	backoff := opts.backoff.Copy()
+	lastErr := cause
+	for attempt := 1; attempt <= opts.maxAttempts || opts.maxAttempts < 0; attempt++ {
+		delay := backoff.Duration()
+		if busy, ok := h.throttle.observe(lastErr); ok && busy > delay {
+			// the service is throttling; back off as long as it asks, for every receiver of the Hub
+			delay = busy
+		}
+		h.notifyRecovery(RecoveryEvent{Entity: entity, Attempt: attempt, Err: lastErr, Delay: delay})
+		tab.For(ctx).Debug(fmt.Sprintf("recovering %s, attempt %d after %v", entity, attempt, delay))
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			h.notifyRecovery(RecoveryEvent{Entity: entity, Attempt: attempt, Err: ctx.Err(), GaveUp: true})
+			return ctx.Err()
+		}
+
+		h.detectFailover(ctx, lastErr)
+		err := recover(ctx)
+		if err == nil {
+			h.notifyRecovery(RecoveryEvent{Entity: entity, Attempt: attempt, Recovered: true})
+			return nil
+		}
+
+		tab.For(ctx).Error(err)
+		lastErr = err
+		if !h.retryClassifier.Retry(err, true) {
+			h.notifyRecovery(RecoveryEvent{Entity: entity, Attempt: attempt, Err: err, GaveUp: true})
+			return fmt.Errorf("failed to recover %s: %v", entity, err)
+		}
+	}
+
+	h.notifyRecovery(RecoveryEvent{Entity: entity, Attempt: opts.maxAttempts, Err: lastErr, GaveUp: true})
+	return fmt.Errorf("failed to recover %s after %d attempts: %v", entity, opts.maxAttempts, lastErr)
+}
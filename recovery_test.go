@@ -0,0 +1,94 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRecoveryHub(t *testing.T, maxAttempts int) (*Hub, *[]RecoveryEvent) {
+	h := &Hub{recoveryOptions: newRecoveryOptions()}
+	require.NoError(t, HubWithRecoveryBackoff(time.Millisecond, time.Millisecond, maxAttempts)(h))
+
+	var events []RecoveryEvent
+	require.NoError(t, HubWithRecoveryListener(func(event RecoveryEvent) {
+		events = append(events, event)
+	})(h))
+	return h, &events
+}
+
+func TestHub_RecoverLink(t *testing.T) {
+	t.Run("RecoversAfterFailures", func(t *testing.T) {
+		h, events := newTestRecoveryHub(t, 5)
+		cause := errors.New("link detached")
+
+		calls := 0
+		err := h.recoverLink(context.Background(), "hub/Partitions/0", cause, func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return errors.New("dial failed")
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls)
+
+		require.Len(t, *events, 4)
+		assert.Equal(t, cause, (*events)[0].Err)
+		assert.Equal(t, 3, (*events)[2].Attempt)
+		last := (*events)[3]
+		assert.True(t, last.Recovered)
+		assert.Equal(t, "hub/Partitions/0", last.Entity)
+	})
+
+	t.Run("GivesUp", func(t *testing.T) {
+		h, events := newTestRecoveryHub(t, 2)
+		err := h.recoverLink(context.Background(), "entity", errors.New("cause"), func(context.Context) error {
+			return errors.New("dial failed")
+		})
+		require.Error(t, err)
+		last := (*events)[len(*events)-1]
+		assert.True(t, last.GaveUp)
+		assert.EqualError(t, last.Err, "dial failed")
+	})
+
+	t.Run("StopsOnErrorsClassifiedAsNotRetryable", func(t *testing.T) {
+		h, events := newTestRecoveryHub(t, 5)
+		unauthorized := errors.New("unauthorized")
+		require.NoError(t, HubWithRetryClassifier(func(err error, retryable bool) bool {
+			return retryable && err != unauthorized
+		})(h))
+
+		calls := 0
+		err := h.recoverLink(context.Background(), "entity", errors.New("cause"), func(context.Context) error {
+			calls++
+			return unauthorized
+		})
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+		last := (*events)[len(*events)-1]
+		assert.True(t, last.GaveUp)
+		assert.Equal(t, unauthorized, last.Err)
+	})
+
+	t.Run("StopsWhenContextIsDone", func(t *testing.T) {
+		h, _ := newTestRecoveryHub(t, -1)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := h.recoverLink(ctx, "entity", errors.New("cause"), func(context.Context) error {
+			return errors.New("dial failed")
+		})
+		assert.Equal(t, context.Canceled, err)
+	})
+
+	t.Run("InvalidOptions", func(t *testing.T) {
+		h := &Hub{recoveryOptions: newRecoveryOptions()}
+		assert.Error(t, HubWithRecoveryBackoff(0, time.Second, 1)(h))
+		assert.Error(t, HubWithRecoveryBackoff(time.Second, time.Millisecond, 1)(h))
+		assert.Error(t, HubWithRecoveryBackoff(time.Millisecond, time.Second, 0)(h))
+	})
+}
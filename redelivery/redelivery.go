@@ -0,0 +1,282 @@
+// Package redelivery approximates the redelivery of a queue on the log of an Event Hub. A Redeliverer wraps a handler;
+// an event the handler fails is sent again to the hub, to be received after the events already in its partition,
+// with its delivery count incremented and the time before which it should not be handled, which grows exponentially
+// with each delivery. Events which fail their last delivery are handed to a dead-letter sink:
+//
+//	hub, err := eventhub.NewHubFromConnectionString(connStr)
+//	deadLetters, err := eventhub.NewHubFromConnectionString(deadLetterConnStr)
+//	redeliverer, err := redelivery.NewRedeliverer(hub,
+//		redelivery.RedelivererWithMaxDeliveries(5),
+//		redelivery.RedelivererWithDeadLetterSink(redelivery.SenderDeadLetterSink(deadLetters)))
+//	handle, err := hub.Receive(ctx, partitionID, redeliverer.Handler(handler))
+//
+// A redelivered event is a new event of the hub: it gets a new sequence number and offset, and the partition moves on
+// past the original, so the events of a partition key are no longer handled in order once one is redelivered. Events
+// without a partition key may be redelivered to another partition. An event received before it is due holds up the
+// handling of its partition until it is.
+package redelivery
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/replication"
+)
+
+// Application properties of redelivered and dead-lettered events
+const (
+	// DeliveryCountProperty holds the number of times an event has been delivered, counting the original delivery.
+	// It is absent from events which haven't been redelivered.
+	DeliveryCountProperty = "eh-delivery-count"
+	// NotBeforeProperty holds the time, in milliseconds since the Unix epoch, before which a redelivered event should
+	// not be handled
+	NotBeforeProperty = "eh-not-before"
+	// DeadLetterReasonProperty holds the error a dead-lettered event failed its last delivery with
+	DeadLetterReasonProperty = "eh-dead-letter-reason"
+)
+
+const (
+	defaultMaxDeliveries = 5
+	defaultMinDelay      = time.Second
+	defaultMaxDelay      = 5 * time.Minute
+)
+
+type (
+	// Redeliverer sends the events a handler fails back to their hub, and the events which fail their last delivery
+	// to a dead-letter sink
+	Redeliverer struct {
+		sender        eventhub.Sender
+		maxDeliveries int
+		minDelay      time.Duration
+		maxDelay      time.Duration
+		deadLetters   DeadLetterSink
+		now           func() time.Time
+	}
+
+	// RedelivererOption provides structure for configuring a new Redeliverer
+	RedelivererOption func(r *Redeliverer) error
+
+	// DeadLetterSink receives the events which failed their last delivery, along with the error of the handler
+	DeadLetterSink interface {
+		DeadLetter(ctx context.Context, event *eventhub.Event, cause error) error
+	}
+
+	// DeadLetterFunc is a function which implements DeadLetterSink
+	DeadLetterFunc func(ctx context.Context, event *eventhub.Event, cause error) error
+
+	senderDeadLetterSink struct {
+		sender eventhub.Sender
+	}
+)
+
+// NewRedeliverer creates a new Redeliverer which sends the events to redeliver with sender, which should send to the
+// hub the events are received from. By default, events are delivered up to 5 times, with delays doubling from a
+// second up to 5 minutes.
+func NewRedeliverer(sender eventhub.Sender, opts ...RedelivererOption) (*Redeliverer, error) {
+	if sender == nil {
+		return nil, errors.New("redelivery: a sender is required")
+	}
+
+	r := &Redeliverer{
+		sender:        sender,
+		maxDeliveries: defaultMaxDeliveries,
+		minDelay:      defaultMinDelay,
+		maxDelay:      defaultMaxDelay,
+		now:           time.Now,
+	}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// RedelivererWithMaxDeliveries configures the number of times an event is delivered, counting the original delivery,
+// before it is dead-lettered
+func RedelivererWithMaxDeliveries(deliveries int) RedelivererOption {
+	return func(r *Redeliverer) error {
+		if deliveries < 1 {
+			return fmt.Errorf("redelivery: max deliveries must be at least 1, got %d", deliveries)
+		}
+		r.maxDeliveries = deliveries
+		return nil
+	}
+}
+
+// RedelivererWithBackoff configures the delay before the first redelivery of an event, doubled for each later one up
+// to max
+func RedelivererWithBackoff(min, max time.Duration) RedelivererOption {
+	return func(r *Redeliverer) error {
+		if min < 0 || max < min {
+			return fmt.Errorf("redelivery: backoff requires 0 <= min <= max, got min %v and max %v", min, max)
+		}
+		r.minDelay = min
+		r.maxDelay = max
+		return nil
+	}
+}
+
+// RedelivererWithDeadLetterSink configures the sink of the events which fail their last delivery. Without one, the
+// handler's error for such an event is returned to the receiver.
+func RedelivererWithDeadLetterSink(sink DeadLetterSink) RedelivererOption {
+	return func(r *Redeliverer) error {
+		if sink == nil {
+			return errors.New("redelivery: dead-letter sink must not be nil")
+		}
+		r.deadLetters = sink
+		return nil
+	}
+}
+
+// SenderDeadLetterSink returns a DeadLetterSink which sends dead-lettered events with sender, typically to a hub set
+// aside for them, with the error of their last delivery in DeadLetterReasonProperty
+func SenderDeadLetterSink(sender eventhub.Sender) DeadLetterSink {
+	return senderDeadLetterSink{sender: sender}
+}
+
+// DeadLetter calls f
+func (f DeadLetterFunc) DeadLetter(ctx context.Context, event *eventhub.Event, cause error) error {
+	return f(ctx, event, cause)
+}
+
+func (s senderDeadLetterSink) DeadLetter(ctx context.Context, event *eventhub.Event, cause error) error {
+	event.Properties[DeadLetterReasonProperty] = cause.Error()
+	return s.sender.Send(ctx, event)
+}
+
+// DeliveryCount returns the number of times event has been delivered, counting the original delivery
+func DeliveryCount(event *eventhub.Event) int {
+	if count, ok := int64Property(event, DeliveryCountProperty); ok && count > 0 {
+		return int(count)
+	}
+	return 1
+}
+
+// NotBefore returns the time before which a redelivered event should not be handled
+func NotBefore(event *eventhub.Event) (time.Time, bool) {
+	ms, ok := int64Property(event, NotBeforeProperty)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), true
+}
+
+// Handler returns a Handler which calls handler with the events it receives once they are due. An event handler fails
+// is sent back to the hub, or handed to the dead-letter sink once it has been delivered as many times as allowed; the
+// Handler returns nil once it has been, so the receiver moves on, and an error if it couldn't be.
+func (r *Redeliverer) Handler(handler eventhub.Handler) eventhub.Handler {
+	return func(ctx context.Context, event *eventhub.Event) error {
+		if notBefore, ok := NotBefore(event); ok {
+			if wait := notBefore.Sub(r.now()); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				}
+			}
+		}
+
+		err := handler(ctx, event)
+		if err == nil {
+			return nil
+		}
+		return r.redeliver(ctx, event, err)
+	}
+}
+
+// redeliver sends event, which its handler failed with cause, back to the hub, or to the dead-letter sink if it has
+// been delivered as many times as allowed
+func (r *Redeliverer) redeliver(ctx context.Context, event *eventhub.Event, cause error) error {
+	ctx, span := tab.StartSpan(ctx, "eh.redelivery.Redeliverer.redeliver")
+	defer span.End()
+
+	count := DeliveryCount(event)
+	span.AddAttributes(tab.Int64Attribute("eh.delivery_count", int64(count)))
+
+	again := replication.Republished(event)
+	if again.Properties == nil {
+		again.Properties = make(map[string]interface{})
+	}
+
+	if count >= r.maxDeliveries {
+		if r.deadLetters == nil {
+			return cause
+		}
+		again.Properties[DeliveryCountProperty] = int64(count)
+		delete(again.Properties, NotBeforeProperty)
+		if err := r.deadLetters.DeadLetter(ctx, again, cause); err != nil {
+			tab.For(ctx).Error(err)
+			return fmt.Errorf("redelivery: failed to dead-letter event after %d deliveries: %w", count, err)
+		}
+		return nil
+	}
+
+	notBefore := r.now().Add(r.delay(count))
+	again.Properties[DeliveryCountProperty] = int64(count + 1)
+	again.Properties[NotBeforeProperty] = notBefore.UnixNano() / int64(time.Millisecond)
+	if err := r.sender.Send(ctx, again); err != nil {
+		tab.For(ctx).Error(err)
+		return fmt.Errorf("redelivery: failed to redeliver event: %w", err)
+	}
+	return nil
+}
+
+// delay returns the delay before redelivering an event which failed its delivery of the given count
+func (r *Redeliverer) delay(count int) time.Duration {
+	delay := r.minDelay
+	for i := 1; i < count && delay < r.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > r.maxDelay {
+		delay = r.maxDelay
+	}
+	return delay
+}
+
+// int64Property returns the application property key of event as an int64, whichever integer type it was decoded as
+func int64Property(event *eventhub.Event, key string) (int64, bool) {
+	switch v := event.Properties[key].(type) {
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,174 @@
+package redelivery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+type fakeSender struct {
+	sent []*eventhub.Event
+	err  error
+}
+
+func (s *fakeSender) Send(_ context.Context, event *eventhub.Event, _ ...eventhub.SendOption) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, event)
+	return nil
+}
+
+func (s *fakeSender) SendBatch(_ context.Context, _ eventhub.BatchIterator, _ ...eventhub.BatchOption) error {
+	return errors.New("not implemented")
+}
+
+func receivedEvent(data string) *eventhub.Event {
+	partitionKey := "pk"
+	return &eventhub.Event{
+		Data:         []byte(data),
+		PartitionKey: &partitionKey,
+		Properties:   map[string]interface{}{"app": "value"},
+	}
+}
+
+func TestNewRedeliverer(t *testing.T) {
+	_, err := NewRedeliverer(nil)
+	assert.Error(t, err)
+
+	sender := new(fakeSender)
+	for _, opt := range []RedelivererOption{
+		RedelivererWithMaxDeliveries(0),
+		RedelivererWithBackoff(time.Second, time.Millisecond),
+		RedelivererWithDeadLetterSink(nil),
+	} {
+		_, err := NewRedeliverer(sender, opt)
+		assert.Error(t, err)
+	}
+}
+
+func TestRedeliverer_RedeliversWithGrowingDelays(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	sender := new(fakeSender)
+	var deadLettered []*eventhub.Event
+	var causes []error
+	r, err := NewRedeliverer(sender,
+		RedelivererWithMaxDeliveries(3),
+		RedelivererWithBackoff(time.Second, 90*time.Second),
+		RedelivererWithDeadLetterSink(DeadLetterFunc(func(_ context.Context, event *eventhub.Event, cause error) error {
+			deadLettered = append(deadLettered, event)
+			causes = append(causes, cause)
+			return nil
+		})))
+	require.NoError(t, err)
+	r.now = func() time.Time { return now }
+
+	failure := errors.New("handler failed")
+	calls := 0
+	handler := r.Handler(func(context.Context, *eventhub.Event) error {
+		calls++
+		return failure
+	})
+
+	event := receivedEvent("order")
+	assert.Equal(t, 1, DeliveryCount(event))
+	require.NoError(t, handler(context.Background(), event), "a redelivered event is handled as far as the receiver is concerned")
+	require.Len(t, sender.sent, 1)
+	again := sender.sent[0]
+	assert.Equal(t, "order", string(again.Data))
+	assert.Equal(t, "pk", *again.PartitionKey)
+	assert.Equal(t, "value", again.Properties["app"])
+	assert.Equal(t, 2, DeliveryCount(again))
+	notBefore, ok := NotBefore(again)
+	require.True(t, ok)
+	assert.Equal(t, now.Add(time.Second), notBefore)
+	assert.Nil(t, event.Properties[DeliveryCountProperty], "the received event should be left as it was")
+
+	// the redelivered event is due by the time it is received
+	now = now.Add(time.Minute)
+	require.NoError(t, handler(context.Background(), again))
+	require.Len(t, sender.sent, 2)
+	assert.Equal(t, 3, DeliveryCount(sender.sent[1]))
+	notBefore, _ = NotBefore(sender.sent[1])
+	assert.Equal(t, now.Add(2*time.Second), notBefore)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, handler(context.Background(), sender.sent[1]))
+	assert.Len(t, sender.sent, 2, "an event which failed its last delivery is not redelivered")
+	require.Len(t, deadLettered, 1)
+	assert.Equal(t, 3, DeliveryCount(deadLettered[0]))
+	assert.Equal(t, failure, causes[0])
+	assert.Equal(t, 3, calls)
+}
+
+func TestRedeliverer_Delay(t *testing.T) {
+	r, err := NewRedeliverer(new(fakeSender), RedelivererWithBackoff(time.Second, 5*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, r.delay(1))
+	assert.Equal(t, 2*time.Second, r.delay(2))
+	assert.Equal(t, 4*time.Second, r.delay(3))
+	assert.Equal(t, 5*time.Second, r.delay(4))
+	assert.Equal(t, 5*time.Second, r.delay(100))
+}
+
+func TestRedeliverer_WaitsUntilDue(t *testing.T) {
+	r, err := NewRedeliverer(new(fakeSender))
+	require.NoError(t, err)
+	handled := false
+	handler := r.Handler(func(context.Context, *eventhub.Event) error {
+		handled = true
+		return nil
+	})
+
+	event := receivedEvent("early")
+	event.Properties[DeliveryCountProperty] = int64(2)
+	event.Properties[NotBeforeProperty] = time.Now().Add(20*time.Millisecond).UnixNano() / int64(time.Millisecond)
+	start := time.Now()
+	require.NoError(t, handler(context.Background(), event))
+	assert.True(t, handled)
+	assert.True(t, time.Since(start) >= 10*time.Millisecond, "the handler should wait until the event is due")
+
+	handled = false
+	event.Properties[NotBeforeProperty] = time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, handler(ctx, event))
+	assert.False(t, handled)
+}
+
+func TestRedeliverer_Failures(t *testing.T) {
+	failure := errors.New("handler failed")
+	failing := func(context.Context, *eventhub.Event) error { return failure }
+
+	sender := &fakeSender{err: errors.New("hub unavailable")}
+	r, err := NewRedeliverer(sender)
+	require.NoError(t, err)
+	err = r.Handler(failing)(context.Background(), receivedEvent("x"))
+	assert.True(t, errors.Is(err, sender.err), "an event which couldn't be redelivered should fail the handler")
+
+	r, err = NewRedeliverer(new(fakeSender), RedelivererWithMaxDeliveries(1))
+	require.NoError(t, err)
+	assert.Equal(t, failure, r.Handler(failing)(context.Background(), receivedEvent("x")),
+		"without a dead-letter sink the handler's error is returned")
+}
+
+func TestSenderDeadLetterSink(t *testing.T) {
+	deadLetters := new(fakeSender)
+	r, err := NewRedeliverer(new(fakeSender), RedelivererWithMaxDeliveries(1),
+		RedelivererWithDeadLetterSink(SenderDeadLetterSink(deadLetters)))
+	require.NoError(t, err)
+
+	err = r.Handler(func(context.Context, *eventhub.Event) error {
+		return errors.New("malformed order")
+	})(context.Background(), receivedEvent("order"))
+	require.NoError(t, err)
+	require.Len(t, deadLetters.sent, 1)
+	assert.Equal(t, "malformed order", deadLetters.sent[0].Properties[DeadLetterReasonProperty])
+	assert.Equal(t, "order", string(deadLetters.sent[0].Data))
+}
@@ -0,0 +1,245 @@
+// Package replication republishes the events of one Event Hub to another, for migrating a hub to another region or
+// namespace and for fanning its events out to hubs of other consumers.
+//
+// A Bridge receives the events of the source hub through an EventProcessorHost and sends each one to the destination
+// hub with its body, partition key, content type and application properties:
+//
+//	source, err := eph.NewFromConnectionString(ctx, sourceConnStr, leaserCheckpointer, leaserCheckpointer,
+//		eph.WithConsumerGroup("replication"))
+//	destination, err := eventhub.NewHubFromConnectionString(destinationConnStr)
+//	bridge, err := replication.NewBridge(source, destination, replication.BridgeWithFilter(isTelemetry))
+//	err = bridge.Start(ctx)
+//
+// The host checkpoints an event only once the bridge has sent it, so after a restart the bridge resumes with the first
+// event it hadn't sent yet. Events may be sent more than once around failures, never lost. Give the host a consumer
+// group and a checkpoint store of its own so the progress of the bridge is independent of other consumers of the hub.
+package replication
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/eph"
+)
+
+type (
+	// Bridge republishes the events of a source hub to a destination hub
+	Bridge struct {
+		source      processor
+		destination eventhub.Sender
+		filters     []Filter
+		transforms  []Transform
+		sendOpts    []eventhub.SendOption
+
+		mu        sync.Mutex
+		handlerID eph.HandlerID
+		started   bool
+	}
+
+	// BridgeOption provides structure for configuring a new Bridge
+	BridgeOption func(b *Bridge) error
+
+	// Filter reports whether an event of the source hub should be republished
+	Filter func(event *eventhub.Event) bool
+
+	// Transform returns the event to republish in place of an event of the source hub, or nil to skip the event. The
+	// event passed in is the copy the bridge sends, so it may be modified and returned.
+	Transform func(ctx context.Context, event *eventhub.Event) (*eventhub.Event, error)
+
+	// processor is the part of eph.EventProcessorHost the bridge uses
+	processor interface {
+		RegisterHandler(ctx context.Context, handler eventhub.Handler) (eph.HandlerID, error)
+		UnregisterHandler(ctx context.Context, id eph.HandlerID)
+		StartNonBlocking(ctx context.Context) error
+		Close(ctx context.Context) error
+	}
+)
+
+// NewBridge creates a new Bridge which receives events with source and sends them with destination
+func NewBridge(source *eph.EventProcessorHost, destination eventhub.Sender, opts ...BridgeOption) (*Bridge, error) {
+	if source == nil {
+		return nil, errors.New("replication: a source host is required")
+	}
+	return newBridge(source, destination, opts...)
+}
+
+func newBridge(source processor, destination eventhub.Sender, opts ...BridgeOption) (*Bridge, error) {
+	if destination == nil {
+		return nil, errors.New("replication: a destination is required")
+	}
+
+	b := &Bridge{
+		source:      source,
+		destination: destination,
+	}
+	for _, opt := range opts {
+		if err := opt(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// BridgeWithFilter configures the bridge to republish only the events filter accepts. Filters run before transforms,
+// and an event must be accepted by every filter to be republished.
+func BridgeWithFilter(filter Filter) BridgeOption {
+	return func(b *Bridge) error {
+		if filter == nil {
+			return errors.New("replication: filter must not be nil")
+		}
+		b.filters = append(b.filters, filter)
+		return nil
+	}
+}
+
+// BridgeWithTransform configures the bridge to republish the events transform returns. Transforms run in the order
+// they are configured, each on the result of the previous one. An error of a transform fails the event, which the host
+// delivers again.
+func BridgeWithTransform(transform Transform) BridgeOption {
+	return func(b *Bridge) error {
+		if transform == nil {
+			return errors.New("replication: transform must not be nil")
+		}
+		b.transforms = append(b.transforms, transform)
+		return nil
+	}
+}
+
+// BridgeWithSendOptions configures the options of the sends to the destination hub
+func BridgeWithSendOptions(opts ...eventhub.SendOption) BridgeOption {
+	return func(b *Bridge) error {
+		b.sendOpts = append(b.sendOpts, opts...)
+		return nil
+	}
+}
+
+// Start registers the bridge with the source host and starts the host. It returns once the host has started.
+func (b *Bridge) Start(ctx context.Context) error {
+	ctx, span := tab.StartSpan(ctx, "eh.replication.Bridge.Start")
+	defer span.End()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.started {
+		return errors.New("replication: bridge is already started")
+	}
+
+	id, err := b.source.RegisterHandler(ctx, b.forward)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return err
+	}
+
+	if err := b.source.StartNonBlocking(ctx); err != nil {
+		tab.For(ctx).Error(err)
+		b.source.UnregisterHandler(ctx, id)
+		return err
+	}
+
+	b.handlerID = id
+	b.started = true
+	return nil
+}
+
+// Close stops the bridge and closes the source host. The destination is left open for its owner to close.
+func (b *Bridge) Close(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.started {
+		return nil
+	}
+	b.started = false
+	return b.source.Close(ctx)
+}
+
+// forward is the handler of the bridge. Errors are returned to the host, which releases the event for redelivery and
+// leaves the checkpoint of its partition where it was.
+func (b *Bridge) forward(ctx context.Context, event *eventhub.Event) error {
+	ctx, span := tab.StartSpan(ctx, "eh.replication.Bridge.forward")
+	defer span.End()
+
+	for _, filter := range b.filters {
+		if !filter(event) {
+			return nil
+		}
+	}
+
+	republished := Republished(event)
+	for _, transform := range b.transforms {
+		var err error
+		republished, err = transform(ctx, republished)
+		if err != nil {
+			tab.For(ctx).Error(err)
+			return err
+		}
+		if republished == nil {
+			return nil
+		}
+	}
+
+	if err := b.destination.Send(ctx, republished, b.sendOpts...); err != nil {
+		tab.For(ctx).Error(err)
+		return err
+	}
+	return nil
+}
+
+// Republished returns a copy of a received event for sending to another hub, with its ID, body, partition key, content
+// type, application properties and AMQP sections, but without the annotations the source hub assigned, such as its
+// sequence number and offset, which the destination hub assigns anew
+func Republished(event *eventhub.Event) *eventhub.Event {
+	republished := &eventhub.Event{
+		ID:           event.ID,
+		Data:         event.Data,
+		ContentType:  event.ContentType,
+		PartitionKey: event.PartitionKey,
+	}
+	if len(event.Properties) > 0 {
+		republished.Properties = make(map[string]interface{}, len(event.Properties))
+		for key, val := range event.Properties {
+			republished.Properties[key] = val
+		}
+	}
+
+	republished.RawAMQPMessage = event.RawAMQPMessage
+	republished.RawAMQPMessage.MessageAnnotations = nil
+	for key, val := range event.RawAMQPMessage.MessageAnnotations {
+		if strings.HasPrefix(key, "x-opt-") {
+			continue
+		}
+		if republished.RawAMQPMessage.MessageAnnotations == nil {
+			republished.RawAMQPMessage.MessageAnnotations = make(map[string]interface{})
+		}
+		republished.RawAMQPMessage.MessageAnnotations[key] = val
+	}
+	return republished
+}
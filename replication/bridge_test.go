@@ -0,0 +1,151 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/eph"
+)
+
+type (
+	fakeProcessor struct {
+		handler eventhub.Handler
+		started bool
+		closed  bool
+	}
+
+	fakeSender struct {
+		sent []*eventhub.Event
+		err  error
+	}
+)
+
+func (p *fakeProcessor) RegisterHandler(_ context.Context, handler eventhub.Handler) (eph.HandlerID, error) {
+	p.handler = handler
+	return "id", nil
+}
+
+func (p *fakeProcessor) UnregisterHandler(_ context.Context, _ eph.HandlerID) {
+	p.handler = nil
+}
+
+func (p *fakeProcessor) StartNonBlocking(_ context.Context) error {
+	p.started = true
+	return nil
+}
+
+func (p *fakeProcessor) Close(_ context.Context) error {
+	p.closed = true
+	return nil
+}
+
+func (s *fakeSender) Send(_ context.Context, event *eventhub.Event, _ ...eventhub.SendOption) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, event)
+	return nil
+}
+
+func (s *fakeSender) SendBatch(_ context.Context, _ eventhub.BatchIterator, _ ...eventhub.BatchOption) error {
+	return errors.New("not implemented")
+}
+
+func receivedEvent(data string) *eventhub.Event {
+	partitionKey := "pk"
+	event := eventhub.NewEventFromString(data)
+	event.ID = "id-" + data
+	event.PartitionKey = &partitionKey
+	event.Properties = map[string]interface{}{"kind": "telemetry"}
+	event.RawAMQPMessage.MessageAnnotations = map[string]interface{}{
+		"x-opt-sequence-number": int64(7),
+		"x-opt-partition-key":   "pk",
+		"custom":                "kept",
+	}
+	return event
+}
+
+func TestBridge_Forward(t *testing.T) {
+	source := new(fakeProcessor)
+	destination := new(fakeSender)
+	bridge, err := newBridge(source, destination)
+	require.NoError(t, err)
+
+	require.NoError(t, bridge.Start(context.Background()))
+	assert.True(t, source.started)
+	assert.Error(t, bridge.Start(context.Background()))
+
+	require.NoError(t, source.handler(context.Background(), receivedEvent("one")))
+	require.Len(t, destination.sent, 1)
+
+	sent := destination.sent[0]
+	assert.Equal(t, "one", string(sent.Data))
+	assert.Equal(t, "id-one", sent.ID)
+	require.NotNil(t, sent.PartitionKey)
+	assert.Equal(t, "pk", *sent.PartitionKey)
+	assert.Equal(t, map[string]interface{}{"kind": "telemetry"}, sent.Properties)
+	assert.Equal(t, map[string]interface{}{"custom": "kept"}, sent.RawAMQPMessage.MessageAnnotations)
+
+	require.NoError(t, bridge.Close(context.Background()))
+	assert.True(t, source.closed)
+}
+
+func TestBridge_FilterAndTransform(t *testing.T) {
+	source := new(fakeProcessor)
+	destination := new(fakeSender)
+	bridge, err := newBridge(source, destination,
+		BridgeWithFilter(func(event *eventhub.Event) bool {
+			return string(event.Data) != "skipped"
+		}),
+		BridgeWithTransform(func(_ context.Context, event *eventhub.Event) (*eventhub.Event, error) {
+			if string(event.Data) == "dropped" {
+				return nil, nil
+			}
+			event.Properties["replicated"] = true
+			return event, nil
+		}))
+	require.NoError(t, err)
+	require.NoError(t, bridge.Start(context.Background()))
+
+	original := receivedEvent("one")
+	for _, event := range []*eventhub.Event{original, receivedEvent("skipped"), receivedEvent("dropped")} {
+		require.NoError(t, source.handler(context.Background(), event))
+	}
+
+	require.Len(t, destination.sent, 1)
+	assert.Equal(t, true, destination.sent[0].Properties["replicated"])
+	assert.NotContains(t, original.Properties, "replicated")
+}
+
+func TestBridge_FailuresAreReturnedToTheHost(t *testing.T) {
+	source := new(fakeProcessor)
+	destination := &fakeSender{err: errors.New("send failed")}
+	bridge, err := newBridge(source, destination)
+	require.NoError(t, err)
+	require.NoError(t, bridge.Start(context.Background()))
+	assert.EqualError(t, source.handler(context.Background(), receivedEvent("one")), "send failed")
+
+	transformErr := errors.New("transform failed")
+	bridge, err = newBridge(source, new(fakeSender), BridgeWithTransform(func(context.Context, *eventhub.Event) (*eventhub.Event, error) {
+		return nil, transformErr
+	}))
+	require.NoError(t, err)
+	require.NoError(t, bridge.Start(context.Background()))
+	assert.Equal(t, transformErr, source.handler(context.Background(), receivedEvent("one")))
+}
+
+func TestNewBridge_Validation(t *testing.T) {
+	_, err := NewBridge(nil, new(fakeSender))
+	assert.Error(t, err)
+
+	_, err = newBridge(new(fakeProcessor), nil)
+	assert.Error(t, err)
+
+	_, err = newBridge(new(fakeProcessor), new(fakeSender), BridgeWithFilter(nil))
+	assert.Error(t, err)
+}
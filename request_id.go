@@ -0,0 +1,119 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-amqp-common-go/v3/uuid"
+	"github.com/devigned/tab"
+)
+
+const (
+	// requestIDKey names the client request ID in the application properties of management requests and in the
+	// headers of HTTP requests
+	requestIDKey = "x-ms-client-request-id"
+	// requestIDAttribute names the client request ID in trace spans
+	requestIDAttribute = "eh.request_id"
+)
+
+type (
+	requestIDContextKey struct{}
+
+	// RequestError is returned when an operation fails, with the client request ID the operation was stamped with so
+	// the failure can be correlated with the service's logs in a support request. The error the operation failed with
+	// is matched by errors.Is and errors.As. Errors raised before a request is made, such as ErrHubClosed or failing to
+	// connect, are returned as they are.
+	RequestError struct {
+		RequestID string
+		Err       error
+	}
+)
+
+// WithRequestID returns a copy of ctx carrying id as the client request ID of the operations started with it. Sends,
+// management requests and lease operations started with a context which doesn't carry a request ID generate one.
+//
+// Operations started within another, such as the management requests of an EventProcessorHost's lease operation,
+// share its request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDFromContext returns the client request ID carried by ctx
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDContextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// RequestIDFromError returns the client request ID of the operation which failed with err
+func RequestIDFromError(err error) (string, bool) {
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.RequestID, true
+	}
+	return "", false
+}
+
+// StartRequest stamps ctx with a client request ID, generating one unless ctx already carries one, and records it on
+// span
+func StartRequest(ctx context.Context, span tab.Spanner) (context.Context, string) {
+	id, ok := RequestIDFromContext(ctx)
+	if !ok {
+		id = newRequestID()
+		ctx = WithRequestID(ctx, id)
+	}
+	span.AddAttributes(tab.StringAttribute(requestIDAttribute, id))
+	return ctx, id
+}
+
+// NewRequestError attaches requestID to err. It returns nil if err is nil, and err if it already carries a request ID.
+func NewRequestError(requestID string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := RequestIDFromError(err); ok {
+		return err
+	}
+	return &RequestError{RequestID: requestID, Err: err}
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%v (client request ID: %s)", e.Err, e.RequestID)
+}
+
+// Unwrap returns the error the operation failed with
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func newRequestID() string {
+	id, err := uuid.NewV4()
+	if err != nil {
+		// the system's random source failing leaves the time as the most distinctive ID at hand
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return id.String()
+}
@@ -0,0 +1,87 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-amqp-common-go/v3/auth"
+	"github.com/Azure/go-amqp"
+	"github.com/devigned/tab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartRequest(t *testing.T) {
+	ctx, span := tab.StartSpan(context.Background(), "test")
+	defer span.End()
+
+	_, ok := RequestIDFromContext(ctx)
+	assert.False(t, ok)
+
+	generated, first := StartRequest(ctx, span)
+	require.NotEmpty(t, first)
+	id, ok := RequestIDFromContext(generated)
+	assert.True(t, ok)
+	assert.Equal(t, first, id)
+
+	_, second := StartRequest(ctx, span)
+	assert.NotEqual(t, first, second, "each operation should get a request ID of its own")
+
+	_, kept := StartRequest(generated, span)
+	assert.Equal(t, first, kept, "operations within another should share its request ID")
+
+	_, chosen := StartRequest(WithRequestID(ctx, "support-case"), span)
+	assert.Equal(t, "support-case", chosen)
+}
+
+func TestNewRequestError(t *testing.T) {
+	assert.NoError(t, NewRequestError("id", nil))
+
+	busy := ErrServerBusy{Description: "busy"}
+	err := NewRequestError("id", busy)
+	assert.EqualError(t, err, busy.Error()+" (client request ID: id)")
+	assert.True(t, errors.Is(err, ErrServerBusy{}))
+	assert.True(t, IsRetryable(err))
+	id, ok := RequestIDFromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, "id", id)
+
+	assert.Equal(t, err, NewRequestError("outer", err), "an error should keep the request ID of the operation which failed")
+	_, ok = RequestIDFromError(busy)
+	assert.False(t, ok)
+}
+
+func TestManagementClient_RPCStampsRequestID(t *testing.T) {
+	c := newTestManagementClient(&fakeTokenProvider{tokenType: auth.CBSTokenTypeSAS})
+	c.breaker = newManagementCircuitBreaker(1, time.Minute)
+	c.breaker.record(errors.New("unavailable"))
+
+	msg := &amqp.Message{ApplicationProperties: map[string]interface{}{operationKey: readOperationKey}}
+	_, err := c.rpc(WithRequestID(context.Background(), "support-case"), nil, msg)
+	require.Error(t, err)
+	assert.Equal(t, "support-case", msg.ApplicationProperties[requestIDKey])
+}
+
+func TestEntityManager_ExecuteSendsRequestID(t *testing.T) {
+	var sent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = r.Header.Get(requestIDKey)
+	}))
+	em := newEntityManager(server.URL+"/", &fakeTokenProvider{tokenType: auth.CBSTokenTypeSAS})
+
+	res, err := em.Get(WithRequestID(context.Background(), "support-case"), "hub")
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	assert.Equal(t, "support-case", sent)
+
+	server.Close()
+	_, err = em.Get(context.Background(), "hub")
+	require.Error(t, err)
+	id, ok := RequestIDFromError(err)
+	assert.True(t, ok)
+	assert.NotEmpty(t, id)
+}
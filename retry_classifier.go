@@ -0,0 +1,103 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jpillora/backoff"
+)
+
+type (
+	// RetryClassifier decides whether an operation which failed with err is retried, given whether the package would
+	// retry it. Returning retryable keeps the package's decision. Errors are classified as the package returns them,
+	// so errors.As matches the package's error types.
+	RetryClassifier func(err error, retryable bool) bool
+)
+
+// HubWithRetryClassifier configures the Hub to let classifier override which failures are retried:
+//
+// A failed send is retried, on its link or on a rebuilt one, while the classifier agrees.
+//
+// A receiver whose link fails rebuilds it while the classifier agrees, and closes otherwise.
+//
+// An event which can't be decoded stops its receiver, unless the classifier deems the error retryable, in which case
+// the event is skipped.
+//
+// A handler which fails is called again with the event, backing off as configured by HubWithRecoveryBackoff, while
+// the classifier deems its error retryable. Handler errors are not retryable by default.
+func HubWithRetryClassifier(classifier RetryClassifier) HubOption {
+	return func(h *Hub) error {
+		if classifier == nil {
+			return errors.New("retry classifier must not be nil")
+		}
+		h.retryClassifier = classifier
+		return nil
+	}
+}
+
+// Retry reports whether to retry an operation which failed with err, given whether the package would. A nil
+// classifier keeps the package's decision.
+func (c RetryClassifier) Retry(err error, retryable bool) bool {
+	if c == nil {
+		return retryable
+	}
+	return c(err, retryable)
+}
+
+// RetryHandler returns a Handler which calls handler again with the event, after an exponential backoff with jitter
+// between min and max, while it fails with an error classifier deems retryable. It gives up once ctx is done or
+// handler was called maxAttempts times, returning the last error; a negative maxAttempts retries until ctx is done.
+// Handler errors are not retryable by default.
+func RetryHandler(handler Handler, classifier RetryClassifier, min, max time.Duration, maxAttempts int) Handler {
+	return func(ctx context.Context, event *Event) error {
+		// each event backs off from the minimum
+		b := &backoff.Backoff{Min: min, Max: max, Factor: 2, Jitter: true}
+		for attempt := 1; ; attempt++ {
+			err := handler(ctx, event)
+			if err == nil || (maxAttempts >= 0 && attempt >= maxAttempts) || !classifier.Retry(err, false) {
+				return err
+			}
+
+			select {
+			case <-time.After(b.Duration()):
+			case <-ctx.Done():
+				return err
+			}
+		}
+	}
+}
+
+// retryingHandler wraps handler to retry the errors the retry classifier of the Hub deems retryable, if it has one
+func (h *Hub) retryingHandler(handler Handler) Handler {
+	if h.retryClassifier == nil {
+		return handler
+	}
+	opts := h.recoveryOptions
+	if opts == nil {
+		opts = newRecoveryOptions()
+	}
+	return RetryHandler(handler, h.retryClassifier, opts.backoff.Min, opts.backoff.Max, opts.maxAttempts)
+}
@@ -0,0 +1,138 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryClassifier_Retry(t *testing.T) {
+	var none RetryClassifier
+	assert.True(t, none.Retry(errors.New("err"), true))
+	assert.False(t, none.Retry(errors.New("err"), false))
+
+	inverted := RetryClassifier(func(_ error, retryable bool) bool { return !retryable })
+	assert.False(t, inverted.Retry(errors.New("err"), true))
+
+	assert.Error(t, HubWithRetryClassifier(nil)(&Hub{}))
+}
+
+func TestRetryHandler(t *testing.T) {
+	transient := errors.New("transient")
+	classifier := RetryClassifier(func(err error, retryable bool) bool {
+		return retryable || errors.Is(err, transient)
+	})
+
+	failing := func(failures int, err error) (Handler, *int) {
+		calls := 0
+		return func(context.Context, *Event) error {
+			calls++
+			if calls <= failures {
+				return err
+			}
+			return nil
+		}, &calls
+	}
+
+	t.Run("RetriesRetryableErrors", func(t *testing.T) {
+		handler, calls := failing(2, transient)
+		err := RetryHandler(handler, classifier, time.Millisecond, time.Millisecond, 5)(context.Background(), NewEventFromString("x"))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, *calls)
+	})
+
+	t.Run("DoesNotRetryByDefault", func(t *testing.T) {
+		handler, calls := failing(1, transient)
+		err := RetryHandler(handler, nil, time.Millisecond, time.Millisecond, 5)(context.Background(), NewEventFromString("x"))
+		assert.Equal(t, transient, err)
+		assert.Equal(t, 1, *calls)
+
+		fatal := errors.New("fatal")
+		handler, calls = failing(1, fatal)
+		err = RetryHandler(handler, classifier, time.Millisecond, time.Millisecond, 5)(context.Background(), NewEventFromString("x"))
+		assert.Equal(t, fatal, err)
+		assert.Equal(t, 1, *calls)
+	})
+
+	t.Run("GivesUpAfterMaxAttempts", func(t *testing.T) {
+		handler, calls := failing(10, transient)
+		err := RetryHandler(handler, classifier, time.Millisecond, time.Millisecond, 3)(context.Background(), NewEventFromString("x"))
+		assert.Equal(t, transient, err)
+		assert.Equal(t, 3, *calls)
+	})
+
+	t.Run("StopsWhenContextIsDone", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		handler, calls := failing(10, transient)
+		err := RetryHandler(handler, classifier, time.Hour, time.Hour, -1)(ctx, NewEventFromString("x"))
+		assert.Equal(t, transient, err)
+		assert.Equal(t, 1, *calls)
+	})
+}
+
+func TestHub_RetryingHandler(t *testing.T) {
+	h := &Hub{recoveryOptions: newRecoveryOptions()}
+	calls := 0
+	handler := func(context.Context, *Event) error {
+		calls++
+		return errors.New("fails")
+	}
+	require.Error(t, h.retryingHandler(handler)(context.Background(), NewEventFromString("x")))
+	assert.Equal(t, 1, calls, "handlers are not retried without a classifier")
+
+	require.NoError(t, HubWithRecoveryBackoff(time.Millisecond, time.Millisecond, 2)(h))
+	require.NoError(t, HubWithRetryClassifier(func(error, bool) bool { return true })(h))
+	calls = 0
+	require.Error(t, h.retryingHandler(handler)(context.Background(), NewEventFromString("x")))
+	assert.Equal(t, 2, calls, "handlers should be retried as often as links are recovered")
+}
+
+func TestReceiver_RetryClassifierSkipsUndecodableEvents(t *testing.T) {
+	ns := &namespace{transport: new(memoryTransport)}
+	s, err := ns.amqpTransport().newSession(nil)
+	require.NoError(t, err)
+	link, err := s.NewReceiver()
+	require.NoError(t, err)
+
+	stopped := false
+	r := &receiver{
+		hub:           &Hub{name: "hub", namespace: ns},
+		receiver:      link,
+		consumerGroup: DefaultConsumerGroup,
+		partitionID:   "0",
+		done:          func() { stopped = true },
+	}
+	var handled int
+	handler := func(context.Context, *Event) error {
+		handled++
+		return nil
+	}
+	undecodable := func() *amqp.Message {
+		return &amqp.Message{
+			Data:        [][]byte{[]byte("event")},
+			Annotations: amqp.Annotations{sequenceNumberName: "not a number"},
+		}
+	}
+
+	var classified error
+	r.hub.retryClassifier = func(err error, retryable bool) bool {
+		classified = err
+		assert.False(t, retryable, "decode errors stop the receiver by default")
+		return true
+	}
+	r.handleMessage(context.Background(), undecodable(), handler)
+	assert.Error(t, classified)
+	assert.False(t, stopped)
+	assert.Equal(t, 0, handled, "an event which failed to decode should be skipped")
+
+	r.hub.retryClassifier = nil
+	r.handleMessage(context.Background(), undecodable(), handler)
+	assert.True(t, stopped)
+	assert.Error(t, r.lastError)
+}
@@ -0,0 +1,145 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-amqp-common-go/v3/rpc"
+	"github.com/Azure/go-amqp"
+)
+
+type (
+	// rpcLinkCache shares request / response links between the users of a connection, so management and CBS requests
+	// don't attach a new link for every operation. A cached link is safe for concurrent requests as responses are
+	// correlated to their requests by message ID, and each request waits on its own context. The claims negotiated
+	// over the CBS links are kept alongside, as they live as long as the same connections.
+	rpcLinkCache struct {
+		newLink   func(conn *amqp.Client, address string) (*rpc.Link, error)
+		closeLink func(ctx context.Context, link *rpc.Link) error
+		claims    *claimCache
+
+		mu    sync.Mutex
+		links map[rpcLinkKey]*rpc.Link
+	}
+
+	rpcLinkKey struct {
+		conn    *amqp.Client
+		address string
+	}
+)
+
+func newRPCLinkCache() *rpcLinkCache {
+	return &rpcLinkCache{
+		newLink: func(conn *amqp.Client, address string) (*rpc.Link, error) {
+			return rpc.NewLink(conn, address)
+		},
+		closeLink: func(ctx context.Context, link *rpc.Link) error {
+			return link.Close(ctx)
+		},
+		claims: newClaimCache(),
+		links:  make(map[rpcLinkKey]*rpc.Link),
+	}
+}
+
+// get returns the cached link to address on the connection, attaching a new one if there is none
+func (c *rpcLinkCache) get(conn *amqp.Client, address string) (*rpc.Link, error) {
+	if conn == nil {
+		return nil, errors.New("a connection is required to build an RPC link")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := rpcLinkKey{conn: conn, address: address}
+	if link, ok := c.links[key]; ok {
+		return link, nil
+	}
+
+	link, err := c.newLink(conn, address)
+	if err != nil {
+		return nil, err
+	}
+
+	c.links[key] = link
+	return link, nil
+}
+
+// discard closes and forgets the link if it is still the cached link to address on the connection. Requests in flight
+// on the link fail and the next request attaches a new link.
+func (c *rpcLinkCache) discard(conn *amqp.Client, address string, link *rpc.Link) error {
+	c.mu.Lock()
+	key := rpcLinkKey{conn: conn, address: address}
+	if c.links[key] != link {
+		c.mu.Unlock()
+		return nil
+	}
+	delete(c.links, key)
+	c.mu.Unlock()
+
+	// the request which failed may have been cancelled, so closing gets its own deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.closeLink(ctx, link)
+}
+
+// discardFailed discards the link after a request on it failed with err, unless only that request gave up. Requests
+// are correlated by message ID, so many may be outstanding on a link at once; one request running out of time says
+// nothing about the link and must not fail the others.
+func (c *rpcLinkCache) discardFailed(conn *amqp.Client, address string, link *rpc.Link, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return c.discard(conn, address, link)
+}
+
+// forget drops every link and claim of a connection which is closing. The links are not closed individually as they go
+// away with the connection.
+func (c *rpcLinkCache) forget(conn *amqp.Client) {
+	c.claims.forget(conn)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.links {
+		if key.conn == conn {
+			delete(c.links, key)
+		}
+	}
+}
+
+// rpcLinks returns the RPC link cache for the namespace's connections. Namespaces sharing a connection pool share the
+// pool's cache, as they share its connections.
+func (ns *namespace) rpcLinks() *rpcLinkCache {
+	if ns.pool != nil {
+		return ns.pool.rpcLinks
+	}
+
+	ns.rpcLinksOnce.Do(func() {
+		ns.rpcLinkCache = newRPCLinkCache()
+	})
+	return ns.rpcLinkCache
+}
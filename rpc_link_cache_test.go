@@ -0,0 +1,104 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Azure/azure-amqp-common-go/v3/rpc"
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPCLinkCache(t *testing.T) {
+	var attached, closed int
+	cache := newRPCLinkCache()
+	cache.newLink = func(conn *amqp.Client, address string) (*rpc.Link, error) {
+		attached++
+		return new(rpc.Link), nil
+	}
+	cache.closeLink = func(ctx context.Context, link *rpc.Link) error {
+		closed++
+		return nil
+	}
+
+	connA, connB := new(amqp.Client), new(amqp.Client)
+	mgmtA, err := cache.get(connA, address)
+	require.NoError(t, err)
+	again, err := cache.get(connA, address)
+	require.NoError(t, err)
+	assert.Same(t, mgmtA, again, "a link is reused for the same connection and address")
+
+	cbsA, err := cache.get(connA, cbsAddress)
+	require.NoError(t, err)
+	assert.NotSame(t, mgmtA, cbsA)
+	mgmtB, err := cache.get(connB, address)
+	require.NoError(t, err)
+	assert.NotSame(t, mgmtA, mgmtB)
+	assert.Equal(t, 3, attached)
+
+	require.NoError(t, cache.discard(connA, address, mgmtB), "a link which isn't cached for the key is left alone")
+	assert.Equal(t, 0, closed)
+	require.NoError(t, cache.discard(connA, address, mgmtA))
+	assert.Equal(t, 1, closed)
+	replacement, err := cache.get(connA, address)
+	require.NoError(t, err)
+	assert.NotSame(t, mgmtA, replacement)
+
+	cache.forget(connA)
+	_, err = cache.get(connA, cbsAddress)
+	require.NoError(t, err)
+	assert.Equal(t, 5, attached)
+	again, err = cache.get(connB, address)
+	require.NoError(t, err)
+	assert.Same(t, mgmtB, again, "forgetting a connection keeps the links of other connections")
+
+	_, err = cache.get(nil, address)
+	assert.Error(t, err)
+
+	cache.newLink = func(*amqp.Client, string) (*rpc.Link, error) {
+		return nil, errors.New("attach failed")
+	}
+	_, err = cache.get(new(amqp.Client), address)
+	assert.Error(t, err)
+}
+
+func TestNamespace_RPCLinksSharedThroughPool(t *testing.T) {
+	pool, err := NewConnectionPool(1)
+	require.NoError(t, err)
+
+	a, b := &namespace{pool: pool}, &namespace{pool: pool}
+	assert.Same(t, a.rpcLinks(), b.rpcLinks())
+
+	unpooled := &namespace{}
+	assert.Same(t, unpooled.rpcLinks(), unpooled.rpcLinks())
+	assert.NotSame(t, a.rpcLinks(), unpooled.rpcLinks())
+}
+
+func TestRPCLinkCache_DiscardFailedKeepsLinkOnRequestTimeout(t *testing.T) {
+	var closed int
+	cache := newRPCLinkCache()
+	cache.newLink = func(*amqp.Client, string) (*rpc.Link, error) {
+		return new(rpc.Link), nil
+	}
+	cache.closeLink = func(context.Context, *rpc.Link) error {
+		closed++
+		return nil
+	}
+
+	conn := new(amqp.Client)
+	link, err := cache.get(conn, address)
+	require.NoError(t, err)
+
+	// a request giving up must not fail the other requests outstanding on the shared link
+	require.NoError(t, cache.discardFailed(conn, address, link, context.DeadlineExceeded))
+	require.NoError(t, cache.discardFailed(conn, address, link, context.Canceled))
+	same, err := cache.get(conn, address)
+	require.NoError(t, err)
+	assert.Same(t, link, same)
+	assert.Equal(t, 0, closed)
+
+	require.NoError(t, cache.discardFailed(conn, address, link, amqp.ErrLinkClosed))
+	assert.Equal(t, 1, closed)
+}
@@ -0,0 +1,113 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// runtimeInfoCache holds hub and partition runtime information for a fixed time to live so callers which poll the
+	// management node do not issue redundant requests
+	runtimeInfoCache struct {
+		ttl time.Duration
+		now func() time.Time
+
+		mu         sync.Mutex
+		hub        *HubRuntimeInformation
+		hubExpiry  time.Time
+		partitions map[string]cachedPartitionInfo
+	}
+
+	cachedPartitionInfo struct {
+		info   *HubPartitionRuntimeInformation
+		expiry time.Time
+	}
+)
+
+func newRuntimeInfoCache(ttl time.Duration) *runtimeInfoCache {
+	return &runtimeInfoCache{
+		ttl:        ttl,
+		now:        time.Now,
+		partitions: make(map[string]cachedPartitionInfo),
+	}
+}
+
+// getHub returns a copy of the cached hub runtime information if it has not expired
+func (c *runtimeInfoCache) getHub() (*HubRuntimeInformation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.hub == nil || !c.now().Before(c.hubExpiry) {
+		return nil, false
+	}
+	return copyHubRuntimeInformation(c.hub), true
+}
+
+func (c *runtimeInfoCache) setHub(info *HubRuntimeInformation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hub = copyHubRuntimeInformation(info)
+	c.hubExpiry = c.now().Add(c.ttl)
+}
+
+// getPartition returns a copy of the cached partition runtime information if it has not expired
+func (c *runtimeInfoCache) getPartition(partitionID string) (*HubPartitionRuntimeInformation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.partitions[partitionID]
+	if !ok || !c.now().Before(cached.expiry) {
+		return nil, false
+	}
+	info := *cached.info
+	return &info, true
+}
+
+func (c *runtimeInfoCache) setPartition(info *HubPartitionRuntimeInformation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cp := *info
+	c.partitions[info.PartitionID] = cachedPartitionInfo{
+		info:   &cp,
+		expiry: c.now().Add(c.ttl),
+	}
+}
+
+// invalidate drops all cached runtime information
+func (c *runtimeInfoCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hub = nil
+	c.partitions = make(map[string]cachedPartitionInfo)
+}
+
+func copyHubRuntimeInformation(info *HubRuntimeInformation) *HubRuntimeInformation {
+	cp := *info
+	cp.PartitionIDs = append([]string(nil), info.PartitionIDs...)
+	return &cp
+}
@@ -0,0 +1,57 @@
+package eventhub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeInfoCache(t *testing.T) {
+	now := time.Now()
+	cache := newRuntimeInfoCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	_, ok := cache.getHub()
+	assert.False(t, ok)
+
+	cache.setHub(&HubRuntimeInformation{Path: "hub", PartitionIDs: []string{"0", "1"}})
+	cache.setPartition(&HubPartitionRuntimeInformation{PartitionID: "0", LastSequenceNumber: 42})
+
+	hub, ok := cache.getHub()
+	require.True(t, ok)
+	assert.Equal(t, []string{"0", "1"}, hub.PartitionIDs)
+
+	// callers receive copies which do not alter the cache
+	hub.PartitionIDs[0] = "changed"
+	hub, _ = cache.getHub()
+	assert.Equal(t, "0", hub.PartitionIDs[0])
+
+	partition, ok := cache.getPartition("0")
+	require.True(t, ok)
+	assert.EqualValues(t, 42, partition.LastSequenceNumber)
+	_, ok = cache.getPartition("1")
+	assert.False(t, ok)
+
+	now = now.Add(time.Minute)
+	_, ok = cache.getHub()
+	assert.False(t, ok, "hub information should expire after the ttl")
+	_, ok = cache.getPartition("0")
+	assert.False(t, ok, "partition information should expire after the ttl")
+
+	cache.setHub(&HubRuntimeInformation{Path: "hub"})
+	cache.invalidate()
+	_, ok = cache.getHub()
+	assert.False(t, ok, "invalidate should drop cached information")
+}
+
+func TestClient_Uncached(t *testing.T) {
+	c := newClient(nil, "hub")
+	c.cache = newRuntimeInfoCache(time.Minute)
+
+	uncached := c.uncached()
+	assert.Nil(t, uncached.cache)
+	assert.Equal(t, "hub", uncached.hubName)
+	assert.NotNil(t, c.cache, "the cache of the client should be kept")
+}
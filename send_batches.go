@@ -0,0 +1,279 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Azure/azure-amqp-common-go/v3/uuid"
+	"github.com/devigned/tab"
+)
+
+const (
+	defaultSendBatchesConcurrency = 4
+)
+
+type (
+	// SendBatchesResult is the outcome of SendBatches
+	SendBatchesResult struct {
+		// Batches is the number of batches the events were split into
+		Batches int
+		// Sent is the number of events which were sent
+		Sent int
+		// Failed holds the batches which were not sent, with their events
+		Failed []FailedBatch
+	}
+
+	// FailedBatch is a batch SendBatches failed to send
+	FailedBatch struct {
+		Events []*Event
+		Err    error
+	}
+
+	// SendBatchesOption provides a way to configure SendBatches
+	SendBatchesOption func(opts *sendBatchesOptions) error
+
+	sendBatchesOptions struct {
+		concurrency int
+		maxSize     MaxMessageSizeInBytes
+	}
+
+	// pendingBatch is a batch to send with the events in it
+	pendingBatch struct {
+		batch  *EventBatch
+		events []*Event
+	}
+)
+
+// SendBatchesWithConcurrency configures how many batches SendBatches sends at once. The default is 4, or the one of the
+// performance profile of the Hub.
+func SendBatchesWithConcurrency(concurrency int) SendBatchesOption {
+	return func(opts *sendBatchesOptions) error {
+		if concurrency < 1 {
+			return fmt.Errorf("concurrency must be at least 1, got %d", concurrency)
+		}
+		opts.concurrency = concurrency
+		return nil
+	}
+}
+
+// SendBatchesWithMaxSizeInBytes configures the size batches are filled to, DefaultMaxMessageSizeInBytes or the one of
+// the performance profile of the Hub by default
+func SendBatchesWithMaxSizeInBytes(sizeInBytes int) SendBatchesOption {
+	return func(opts *sendBatchesOptions) error {
+		if sizeInBytes <= batchMessageWrapperSize {
+			return fmt.Errorf("batch size must be more than %d bytes, got %d", batchMessageWrapperSize, sizeInBytes)
+		}
+		opts.maxSize = MaxMessageSizeInBytes(sizeInBytes)
+		return nil
+	}
+}
+
+// SendBatches splits events into as few batches as fit the maximum batch size and sends them, several at once. This
+// is meant for large backfills, which would otherwise send batch after batch. Events are batched by partition key;
+// the batches of a partition key are sent one after the other, so the events of a key keep their order, while batches
+// of different keys and of events without a key are sent concurrently.
+//
+// A batch which fails to send doesn't stop the others, except for the later batches of the same partition key, which
+// are not sent so that they don't overtake it. The result lists the events of every batch which was not sent, and the
+// error is non-nil if any was not. An event too big for a batch of its own fails the call before anything is sent, with
+// a nil result.
+func (h *Hub) SendBatches(ctx context.Context, events []*Event, opts ...SendBatchesOption) (*SendBatchesResult, error) {
+	span, ctx := h.startSpanFromContext(ctx, "eh.Hub.SendBatches")
+	defer span.End()
+	ctx, requestID := StartRequest(ctx, span)
+
+	options := &sendBatchesOptions{
+		concurrency: defaultSendBatchesConcurrency,
+		maxSize:     DefaultMaxMessageSizeInBytes,
+	}
+	h.preset.applyToSendBatches(options)
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+	}
+
+	// each event carries the trace context, as the events of a batch are delivered to handlers one by one
+	for _, event := range events {
+		if err := injectTraceContext(span, event); err != nil {
+			tab.For(ctx).Error(err)
+			return nil, err
+		}
+	}
+
+	lanes, err := batchLanes(events, options.maxSize)
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return nil, err
+	}
+
+	result := new(SendBatchesResult)
+	for _, lane := range lanes {
+		result.Batches += len(lane)
+	}
+
+	var mu sync.Mutex
+	work := make(chan []*pendingBatch)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < options.concurrency && i < len(lanes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for lane := range work {
+				sent, failed := h.sendLane(ctx, lane)
+				mu.Lock()
+				result.Sent += sent
+				result.Failed = append(result.Failed, failed...)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, lane := range lanes {
+		work <- lane
+	}
+	close(work)
+	wg.Wait()
+
+	if len(result.Failed) > 0 {
+		err := fmt.Errorf("%d of %d batches failed to send: %v", len(result.Failed), result.Batches, result.Failed[0].Err)
+		tab.For(ctx).Error(err)
+		return result, NewRequestError(requestID, err)
+	}
+	return result, nil
+}
+
+// sendLane sends the batches of a lane in order, giving up on the rest of the lane at the first failure. It returns
+// the number of events sent and the batches which were not.
+func (h *Hub) sendLane(ctx context.Context, lane []*pendingBatch) (int, []FailedBatch) {
+	sent := 0
+	for i, pending := range lane {
+		err := ctx.Err()
+		if err == nil {
+			err = h.sendPendingBatch(ctx, pending)
+		}
+		if err != nil {
+			failed := []FailedBatch{{Events: pending.events, Err: err}}
+			for _, skipped := range lane[i+1:] {
+				failed = append(failed, FailedBatch{
+					Events: skipped.events,
+					Err:    fmt.Errorf("not sent after an earlier batch of the partition key failed: %v", err),
+				})
+			}
+			return sent, failed
+		}
+		sent += len(pending.events)
+	}
+	return sent, nil
+}
+
+func (h *Hub) sendPendingBatch(ctx context.Context, pending *pendingBatch) error {
+	ctx, cancel := withDefaultTimeout(ctx, h.timeouts.Send)
+	defer cancel()
+
+	sender, err := h.senderForKey(ctx, pending.batch.PartitionKey)
+	if err != nil {
+		return err
+	}
+	return sender.trySend(ctx, pending.batch)
+}
+
+// batchLanes groups events into batches of at most maxSize bytes. The batches of each partition key form a lane, in
+// the order of their events; every batch of events without a partition key is a lane of its own.
+func batchLanes(events []*Event, maxSize MaxMessageSizeInBytes) ([][]*pendingBatch, error) {
+	var keys []string
+	byKey := make(map[string][]*Event)
+	for _, event := range events {
+		key := KeyOfNoPartitionKey
+		if event.PartitionKey != nil {
+			key = *event.PartitionKey
+		}
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], event)
+	}
+
+	var lanes [][]*pendingBatch
+	for _, key := range keys {
+		keyed := byKey[key]
+		var partitionKey *string
+		if key != KeyOfNoPartitionKey {
+			partitionKey = keyed[0].PartitionKey
+		}
+
+		batches, err := fillBatches(keyed, partitionKey, maxSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if partitionKey == nil {
+			for _, batch := range batches {
+				lanes = append(lanes, []*pendingBatch{batch})
+			}
+			continue
+		}
+		lanes = append(lanes, batches)
+	}
+	return lanes, nil
+}
+
+// fillBatches adds events to batches in order, starting a new batch whenever the current one is full
+func fillBatches(events []*Event, partitionKey *string, maxSize MaxMessageSizeInBytes) ([]*pendingBatch, error) {
+	var batches []*pendingBatch
+	var current *pendingBatch
+	for _, event := range events {
+		if current != nil {
+			ok, err := current.batch.Add(event)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				current.events = append(current.events, event)
+				continue
+			}
+		}
+
+		id, err := uuid.NewV4()
+		if err != nil {
+			return nil, err
+		}
+		batch := NewEventBatch(id.String(), &BatchOptions{MaxSize: maxSize})
+		batch.PartitionKey = partitionKey
+
+		ok, err := batch.Add(event)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrMessageTooLarge
+		}
+
+		current = &pendingBatch{batch: batch, events: []*Event{event}}
+		batches = append(batches, current)
+	}
+	return batches, nil
+}
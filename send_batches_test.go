@@ -0,0 +1,70 @@
+package eventhub
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendBatchesOptions(t *testing.T) {
+	opts := new(sendBatchesOptions)
+	assert.Error(t, SendBatchesWithConcurrency(0)(opts))
+	assert.NoError(t, SendBatchesWithConcurrency(8)(opts))
+	assert.Equal(t, 8, opts.concurrency)
+
+	assert.Error(t, SendBatchesWithMaxSizeInBytes(batchMessageWrapperSize)(opts))
+	assert.NoError(t, SendBatchesWithMaxSizeInBytes(4096)(opts))
+	assert.Equal(t, MaxMessageSizeInBytes(4096), opts.maxSize)
+}
+
+func TestBatchLanes(t *testing.T) {
+	keyed := func(key string, data string) *Event {
+		event := NewEventFromString(data)
+		event.PartitionKey = &key
+		return event
+	}
+	payload := strings.Repeat("x", 400)
+	events := []*Event{
+		keyed("a", "a1"+payload),
+		NewEventFromString("u1" + payload),
+		keyed("b", "b1"+payload),
+		keyed("a", "a2"+payload),
+		NewEventFromString("u2" + payload),
+		keyed("a", "a3"+payload),
+		NewEventFromString("u3" + payload),
+	}
+
+	// room for two events a batch
+	lanes, err := batchLanes(events, 1200)
+	require.NoError(t, err)
+
+	var got [][][]string
+	for _, lane := range lanes {
+		var batches [][]string
+		for _, pending := range lane {
+			var data []string
+			for _, event := range pending.events {
+				data = append(data, string(event.Data[:2]))
+			}
+			batches = append(batches, data)
+		}
+		got = append(got, batches)
+	}
+	assert.Equal(t, [][][]string{
+		{{"a1", "a2"}, {"a3"}},
+		{{"u1", "u2"}},
+		{{"u3"}},
+		{{"b1"}},
+	}, got)
+
+	assert.Equal(t, "a", *lanes[0][0].batch.PartitionKey)
+	assert.Nil(t, lanes[1][0].batch.PartitionKey)
+	assert.Equal(t, "b", *lanes[3][0].batch.PartitionKey)
+}
+
+func TestBatchLanesEventTooBig(t *testing.T) {
+	_, err := batchLanes([]*Event{NewEventFromString(strings.Repeat("x", 2048))}, 1024)
+	assert.Equal(t, ErrMessageIsTooBig, err)
+}
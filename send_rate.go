@@ -0,0 +1,291 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ingress quota of a throughput unit
+const (
+	throughputUnitBytesPerSecond  = 1024 * 1024
+	throughputUnitEventsPerSecond = 1000
+)
+
+const (
+	// sendRateInterval is the period over which the throughput of a Hub is observed before its limit is raised, and
+	// the least time between two decreases
+	sendRateInterval = time.Second
+	// sendRateMinFraction is the lowest limit, as a fraction of the quota
+	sendRateMinFraction = 0.05
+	// sendRateIncrease is the share of the quota the limit is raised by after an interval without throttling
+	sendRateIncrease = 0.05
+	// sendRateDecrease is the factor applied to the observed throughput when the service throttles a send
+	sendRateDecrease = 0.7
+	// sendRateDemand is the share of the limit sends must reach for the limit to be raised
+	sendRateDemand = 0.9
+)
+
+type (
+	// SendRate is the rate a Hub configured with HubWithSendRateShaping limits its sends to
+	SendRate struct {
+		BytesPerSecond  float64
+		EventsPerSecond float64
+		// Throttled is set when the limit was lowered because the service throttled a send, and unset when it was
+		// raised
+		Throttled bool
+	}
+
+	// SendRateListener is called with the send rate limit of a Hub each time it changes. It is called synchronously
+	// from a sending goroutine, so it should return quickly.
+	SendRateListener func(rate SendRate)
+
+	// sendRateShaper limits the sends of a Hub to a share of the ingress quota of the throughput units of its
+	// namespace. The share starts at the whole quota; each time the service throttles a send, it drops below the
+	// throughput observed until then, and each interval in which sends used most of it without being throttled, it
+	// rises by a step, back up to the quota. Sends wait for tokens of two buckets, of bytes and of events, which refill
+	// at the limit and hold an interval's worth of them.
+	sendRateShaper struct {
+		maxBytes  float64
+		maxEvents float64
+		listener  SendRateListener
+		now       func() time.Time
+
+		mu           sync.Mutex
+		fraction     float64
+		bytes        float64
+		events       float64
+		refilled     time.Time
+		windowStart  time.Time
+		windowBytes  float64
+		windowEvents float64
+		throttled    bool
+		lastDecrease time.Time
+		lowered      bool
+	}
+)
+
+// HubWithSendRateShaping configures the Hub to shape its send rate to the ingress quota of throughputUnits throughput
+// units, 1 MB and 1000 events per second each, rather than sending in bursts until the service throttles it. The
+// limit starts at the quota; when the service throttles a send, it drops below the throughput the Hub was sending at,
+// then rises again by steps while sends keep up with it without being throttled. listener, if not nil, is called with
+// each limit applied. Hubs sharing a namespace share its throughput units, so each should be given its own share.
+func HubWithSendRateShaping(throughputUnits float64, listener SendRateListener) HubOption {
+	return func(h *Hub) error {
+		if throughputUnits <= 0 {
+			return fmt.Errorf("send rate shaping requires a positive number of throughput units, got %v", throughputUnits)
+		}
+		h.sendRate = newSendRateShaper(throughputUnits, listener)
+		return nil
+	}
+}
+
+// SendRate returns the rate the sends of the Hub are limited to, if it was configured with HubWithSendRateShaping
+func (h *Hub) SendRate() (SendRate, bool) {
+	if h.sendRate == nil {
+		return SendRate{}, false
+	}
+	h.sendRate.mu.Lock()
+	defer h.sendRate.mu.Unlock()
+	return h.sendRate.rate(), true
+}
+
+func newSendRateShaper(throughputUnits float64, listener SendRateListener) *sendRateShaper {
+	return &sendRateShaper{
+		maxBytes:  throughputUnits * throughputUnitBytesPerSecond,
+		maxEvents: throughputUnits * throughputUnitEventsPerSecond,
+		listener:  listener,
+		now:       time.Now,
+		fraction:  1,
+	}
+}
+
+// wait takes the tokens for sending evt, waiting for the buckets to refill if they don't hold enough. The tokens are
+// returned if ctx is done first, so sends which are given up don't hold back the next ones.
+func (s *sendRateShaper) wait(ctx context.Context, evt eventer) error {
+	if s == nil {
+		return nil
+	}
+
+	events, bytes := eventerSize(evt)
+	s.mu.Lock()
+	s.refill()
+	s.bytes -= float64(bytes)
+	s.events -= float64(events)
+	rate := s.rate()
+	delay := maxDuration(seconds(-s.bytes/rate.BytesPerSecond), seconds(-s.events/rate.EventsPerSecond))
+	s.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		s.bytes += float64(bytes)
+		s.events += float64(events)
+		// refilling caps the buckets, which may have refilled while the send waited
+		s.refill()
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// observeSent records evt as sent, and raises the limit once sends have kept up with it for an interval without
+// being throttled
+func (s *sendRateShaper) observeSent(evt eventer) {
+	if s == nil {
+		return
+	}
+
+	events, bytes := eventerSize(evt)
+	s.mu.Lock()
+	s.windowBytes += float64(bytes)
+	s.windowEvents += float64(events)
+
+	now := s.now()
+	if s.windowStart.IsZero() {
+		s.windowStart = now
+	}
+	elapsed := now.Sub(s.windowStart)
+	if elapsed < sendRateInterval {
+		s.mu.Unlock()
+		return
+	}
+
+	raise := !s.throttled && s.fraction < 1 && s.demand(elapsed) >= sendRateDemand*s.fraction
+	s.windowStart, s.windowBytes, s.windowEvents, s.throttled = now, 0, 0, false
+	if !raise {
+		s.mu.Unlock()
+		return
+	}
+	s.fraction += sendRateIncrease
+	s.lowered = false
+	if s.fraction > 1 {
+		s.fraction = 1
+	}
+	rate := s.rate()
+	s.mu.Unlock()
+	s.notify(rate)
+}
+
+// observeThrottled lowers the limit below the throughput observed since the start of the interval, or below the
+// current limit if that was higher, at most once an interval so a burst of throttled sends counts once
+func (s *sendRateShaper) observeThrottled() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	s.throttled = true
+	if !s.lastDecrease.IsZero() && now.Sub(s.lastDecrease) < sendRateInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastDecrease = now
+
+	observed := s.fraction
+	if !s.windowStart.IsZero() {
+		if elapsed := now.Sub(s.windowStart); elapsed > 0 {
+			if demand := s.demand(elapsed); demand > 0 && demand < observed {
+				observed = demand
+			}
+		}
+	}
+	s.fraction = observed * sendRateDecrease
+	s.lowered = true
+	if s.fraction < sendRateMinFraction {
+		s.fraction = sendRateMinFraction
+	}
+	// the buckets only hold what the new limit allows
+	s.refill()
+	rate := s.rate()
+	s.mu.Unlock()
+	s.notify(rate)
+}
+
+// demand returns the throughput observed over elapsed, as a fraction of the quota. s.mu must be held.
+func (s *sendRateShaper) demand(elapsed time.Duration) float64 {
+	perSecond := float64(time.Second) / float64(elapsed)
+	bytes := s.windowBytes * perSecond / s.maxBytes
+	events := s.windowEvents * perSecond / s.maxEvents
+	if bytes > events {
+		return bytes
+	}
+	return events
+}
+
+// refill adds the tokens accrued since the last refill, up to an interval's worth. s.mu must be held.
+func (s *sendRateShaper) refill() {
+	now := s.now()
+	rate := s.rate()
+	capacity := sendRateInterval.Seconds()
+	if s.refilled.IsZero() {
+		s.bytes, s.events = rate.BytesPerSecond*capacity, rate.EventsPerSecond*capacity
+	} else if elapsed := now.Sub(s.refilled).Seconds(); elapsed > 0 {
+		s.bytes += rate.BytesPerSecond * elapsed
+		s.events += rate.EventsPerSecond * elapsed
+	}
+	s.refilled = now
+
+	if max := rate.BytesPerSecond * capacity; s.bytes > max {
+		s.bytes = max
+	}
+	if max := rate.EventsPerSecond * capacity; s.events > max {
+		s.events = max
+	}
+}
+
+// rate returns the current limit. s.mu must be held.
+func (s *sendRateShaper) rate() SendRate {
+	return SendRate{
+		BytesPerSecond:  s.fraction * s.maxBytes,
+		EventsPerSecond: s.fraction * s.maxEvents,
+		Throttled:       s.lowered,
+	}
+}
+
+func (s *sendRateShaper) notify(rate SendRate) {
+	if s.listener != nil {
+		s.listener(rate)
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,108 @@
+package eventhub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubWithSendRateShaping(t *testing.T) {
+	h := new(Hub)
+	_, ok := h.SendRate()
+	assert.False(t, ok)
+
+	assert.Error(t, HubWithSendRateShaping(0, nil)(h))
+	assert.Error(t, HubWithSendRateShaping(-1, nil)(h))
+	require.NoError(t, HubWithSendRateShaping(2, nil)(h))
+	rate, ok := h.SendRate()
+	require.True(t, ok)
+	assert.Equal(t, SendRate{BytesPerSecond: 2 * 1024 * 1024, EventsPerSecond: 2000}, rate)
+}
+
+func TestSendRateShaper_Wait(t *testing.T) {
+	var nilShaper *sendRateShaper
+	assert.NoError(t, nilShaper.wait(context.Background(), NewEventFromString("foo")))
+	nilShaper.observeSent(NewEventFromString("foo"))
+	nilShaper.observeThrottled()
+
+	// a thousandth of a throughput unit allows a single event a second
+	now := time.Now()
+	shaper := newSendRateShaper(0.001, nil)
+	shaper.now = func() time.Time { return now }
+	assert.NoError(t, shaper.wait(context.Background(), NewEventFromString("foo")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, shaper.wait(ctx, NewEventFromString("foo")))
+
+	now = now.Add(2 * time.Second)
+	assert.NoError(t, shaper.wait(context.Background(), NewEventFromString("foo")), "the buckets should have refilled")
+}
+
+func TestSendRateShaper_CancelledWaitsReturnTheirTokens(t *testing.T) {
+	// a thousandth of a throughput unit allows a single event a second
+	now := time.Now()
+	shaper := newSendRateShaper(0.001, nil)
+	shaper.now = func() time.Time { return now }
+	require.NoError(t, shaper.wait(context.Background(), NewEventFromString("foo")))
+
+	cancelled, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, shaper.wait(cancelled, NewEventFromString("foo")))
+
+	// a second later the bucket holds the event of the second, which the cancelled send must not have spent
+	now = now.Add(time.Second)
+	immediate, cancelImmediate := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelImmediate()
+	assert.NoError(t, shaper.wait(immediate, NewEventFromString("foo")), "the send should not wait for the tokens of the cancelled one")
+}
+
+func TestSendRateShaper_Shaping(t *testing.T) {
+	now := time.Now()
+	var rates []SendRate
+	shaper := newSendRateShaper(1, func(rate SendRate) { rates = append(rates, rate) })
+	shaper.now = func() time.Time { return now }
+	send := func(count int) {
+		for i := 0; i < count; i++ {
+			shaper.observeSent(NewEventFromString("foo"))
+		}
+	}
+
+	// throttled while sending at half the quota, the limit drops below it
+	send(250)
+	now = now.Add(500 * time.Millisecond)
+	shaper.observeThrottled()
+	require.Len(t, rates, 1)
+	assert.True(t, rates[0].Throttled)
+	assert.InDelta(t, 350, rates[0].EventsPerSecond, 0.001)
+
+	now = now.Add(300 * time.Millisecond)
+	shaper.observeThrottled()
+	assert.Len(t, rates, 1, "a burst of throttled sends should lower the limit once")
+
+	// the interval throttled doesn't raise the limit, and the next does once sends keep up with it
+	now = now.Add(700 * time.Millisecond)
+	send(1)
+	assert.Len(t, rates, 1)
+	send(320)
+	now = now.Add(time.Second)
+	send(1)
+	require.Len(t, rates, 2)
+	assert.False(t, rates[1].Throttled)
+	assert.InDelta(t, 400, rates[1].EventsPerSecond, 0.001)
+
+	now = now.Add(time.Second)
+	send(1)
+	assert.Len(t, rates, 2, "sends well below the limit should not raise it")
+
+	for i := 0; i < 20; i++ {
+		now = now.Add(time.Second)
+		shaper.observeThrottled()
+	}
+	rate, _ := (&Hub{sendRate: shaper}).SendRate()
+	assert.InDelta(t, 50, rate.EventsPerSecond, 0.001, "the limit should not drop below its floor")
+	assert.True(t, rate.Throttled)
+}
@@ -48,7 +48,7 @@ type (
 		hub          *Hub
 		connection   *amqp.Client
 		session      *session
-		sender       atomic.Value // holds a *amqp.Sender
+		sender       atomic.Value // holds an amqpSender, in reality an *amqp.Sender
 		partitionID  *string
 		Name         string
 		retryOptions *senderRetryOptions
@@ -112,6 +112,9 @@ func (h *Hub) newSender(ctx context.Context, retryOptions *senderRetryOptions) (
 	}
 	tab.For(ctx).Debug(fmt.Sprintf("creating a new sender for entity path %s", s.getAddress()))
 	err := s.newSessionAndLink(ctx)
+	if err != nil && s.partitionID != nil {
+		err = partitionNotFound(*s.partitionID, err)
+	}
 	return s, err
 }
 
@@ -169,7 +172,7 @@ func (s *sender) recoverWithExpectedLinkID(ctx context.Context, expectedLinkID s
 		// creates a new connection so we'd need to change that.
 		_ = s.amqpSender().Close(closeCtx)
 		_ = s.session.Close(closeCtx)
-		_ = s.connection.Close()
+		_ = s.hub.namespace.discardConnection(s, s.connection)
 		err = s.newSessionAndLink(ctx)
 
 		s.recovering = false
@@ -180,19 +183,27 @@ func (s *sender) recoverWithExpectedLinkID(ctx context.Context, expectedLinkID s
 	return err
 }
 
+// currentConnection returns the connection of the sender, waiting for a recovery in progress to finish
+func (s *sender) currentConnection() *amqp.Client {
+	s.cond.L.Lock()
+	defer s.cond.L.Unlock()
+	return s.connection
+}
+
 // Close will close the AMQP connection, session and link of the sender
 func (s *sender) Close(ctx context.Context) error {
 	span, _ := s.startProducerSpanFromContext(ctx, "eh.sender.Close")
 	defer span.End()
 
 	err := s.amqpSender().Close(ctx)
+	s.hub.namespace.notifyConnection(ConnectionEvent{Type: ConnectionEventLinkDetached, Entity: s.getAddress()})
 	if err != nil {
 		tab.For(ctx).Error(err)
 		if sessionErr := s.session.Close(ctx); sessionErr != nil {
 			tab.For(ctx).Error(sessionErr)
 		}
 
-		if connErr := s.connection.Close(); connErr != nil {
+		if connErr := s.hub.namespace.releaseConnection(s, s.connection); connErr != nil {
 			tab.For(ctx).Error(connErr)
 		}
 
@@ -202,14 +213,14 @@ func (s *sender) Close(ctx context.Context) error {
 	if sessionErr := s.session.Close(ctx); sessionErr != nil {
 		tab.For(ctx).Error(sessionErr)
 
-		if connErr := s.connection.Close(); connErr != nil {
+		if connErr := s.hub.namespace.releaseConnection(s, s.connection); connErr != nil {
 			tab.For(ctx).Error(connErr)
 		}
 
 		return sessionErr
 	}
 
-	return s.connection.Close()
+	return s.hub.namespace.releaseConnection(s, s.connection)
 }
 
 // Send will send a message to the entity path with options
@@ -241,7 +252,7 @@ func (s *sender) trySend(ctx context.Context, evt eventer) error {
 	sp, ctx := s.startProducerSpanFromContext(ctx, "eh.sender.trySend")
 	defer sp.End()
 
-	if err := sp.Inject(evt); err != nil {
+	if err := injectTraceContext(sp, evt); err != nil {
 		tab.For(ctx).Error(err)
 		return err
 	}
@@ -256,12 +267,35 @@ func (s *sender) trySend(ctx context.Context, evt eventer) error {
 		sp.AddAttributes(tab.StringAttribute("he.message_id", str))
 	}
 
+	partition := ""
+	if s.partitionID != nil {
+		partition = *s.partitionID
+	}
+	start := time.Now()
+
 	// create a per goroutine copy as Duration() and Reset() modify its state
 	backoff := s.retryOptions.recoveryBackoff.Copy()
 
+	attempt := 0
 	recvr := func(linkID string, err error, recover bool) {
+		s.hub.metrics.observeRetry(partition)
+		s.hub.stats.observeRetry()
 		duration := backoff.Duration()
+		if isThrottled(err) {
+			s.hub.sendRate.observeThrottled()
+		}
+		if delay, ok := s.hub.throttle.observe(err); ok && delay > duration {
+			// the service is throttling; back off as long as it asks, for every sender of the Hub
+			duration = delay
+		}
+		if recover {
+			attempt++
+			s.hub.namespace.notifyConnection(ConnectionEvent{Type: ConnectionEventLinkDetached, Entity: s.getAddress(), Err: err})
+			s.hub.notifyRecovery(RecoveryEvent{Entity: s.getAddress(), Attempt: attempt, Err: err, Delay: duration})
+		}
 		tab.For(ctx).Debug("amqp error, delaying " + strconv.FormatInt(int64(duration/time.Millisecond), 10) + " millis: " + err.Error())
+		s.hub.log(ctx, LogLevelWarn, "send failed; retrying", "entity", s.getAddress(), "delay", duration, "recover", recover, "error", err)
+		s.hub.reportError(ErrorEventSendRetry, s.getAddress(), err)
 		select {
 		case <-time.After(duration):
 			// ok, continue to recover
@@ -270,23 +304,47 @@ func (s *sender) trySend(ctx context.Context, evt eventer) error {
 			return
 		}
 		if recover {
+			s.hub.detectFailover(ctx, err)
 			err = s.recoverWithExpectedLinkID(ctx, linkID)
 			if err != nil {
 				tab.For(ctx).Debug("failed to recover connection")
 			} else {
 				tab.For(ctx).Debug("recovered connection")
+				s.hub.notifyRecovery(RecoveryEvent{Entity: s.getAddress(), Attempt: attempt, Recovered: true})
+				attempt = 0
 				backoff.Reset()
 			}
 		}
 	}
 
+	if err := s.hub.throttle.wait(ctx); err != nil {
+		s.hub.metrics.observeSend(partition, evt, time.Since(start), err)
+		s.hub.stats.observeSend(evt, err)
+		return err
+	}
+	if err := s.hub.sendRate.wait(ctx, evt); err != nil {
+		s.hub.metrics.observeSend(partition, evt, time.Since(start), err)
+		s.hub.stats.observeSend(evt, err)
+		return err
+	}
+
 	// try as long as the context is not dead
 	// successful send
 	// don't rebuild the connection in this case, just delay and try again
-	return sendMessage(ctx, s.amqpSender, s.retryOptions.maxRetries, msg, recvr)
+	err = sendMessage(ctx, s.hub.metrics.timeSends(partition, s.amqpSender), s.retryOptions.maxRetries, msg, recvr, s.hub.retryClassifier)
+	if err == nil {
+		s.hub.throttle.observe(nil)
+		s.hub.sendRate.observeSent(evt)
+		s.hub.keyStats.observe(evt, partition)
+	} else if isThrottled(err) {
+		s.hub.sendRate.observeThrottled()
+	}
+	s.hub.metrics.observeSend(partition, evt, time.Since(start), err)
+	s.hub.stats.observeSend(evt, err)
+	return err
 }
 
-func sendMessage(ctx context.Context, getAmqpSender getAmqpSender, maxRetries int, msg *amqp.Message, recoverLink func(linkID string, err error, recover bool)) error {
+func sendMessage(ctx context.Context, getAmqpSender getAmqpSender, maxRetries int, msg *amqp.Message, recoverLink func(linkID string, err error, recover bool), classifier RetryClassifier) error {
 	var lastError error
 
 	// maxRetries >= 0 == finite retries
@@ -304,26 +362,29 @@ func sendMessage(ctx context.Context, getAmqpSender getAmqpSender, maxRetries in
 
 			lastError = err
 
-			switch e := err.(type) {
-			case *amqp.Error:
-				if e.Condition == errorServerBusy || e.Condition == errorTimeout {
-					recoverLink(sender.LinkName(), err, false)
-					break
-				}
-				recoverLink(sender.LinkName(), err, true)
-			case *amqp.DetachError, net.Error:
-				recoverLink(sender.LinkName(), err, true)
-			default:
-				if !isRecoverableCloseError(err) {
-					return err
-				}
-
-				recoverLink(sender.LinkName(), err, true)
+			retry, recover := classifySendError(err)
+			if !classifier.Retry(fromAMQPError(err), retry) {
+				return fromAMQPError(err)
 			}
+			recoverLink(sender.LinkName(), err, recover)
 		}
 	}
 
-	return lastError
+	return fromAMQPError(lastError)
+}
+
+// classifySendError reports whether a send which failed with err is retried, and whether the link is rebuilt first. Errors
+// which are not retried unless a retry classifier says so rebuild the link, as they may have left it unusable.
+func classifySendError(err error) (retry bool, recover bool) {
+	switch e := err.(type) {
+	case *amqp.Error:
+		busy := e.Condition == errorServerBusy || e.Condition == errorTimeout
+		return true, !busy
+	case *amqp.DetachError, net.Error:
+		return true, true
+	default:
+		return isRecoverableCloseError(err), true
+	}
 }
 
 func (s *sender) String() string {
@@ -346,7 +407,7 @@ func (s *sender) newSessionAndLink(ctx context.Context) error {
 	span, ctx := s.startProducerSpanFromContext(ctx, "eh.sender.newSessionAndLink")
 	defer span.End()
 
-	connection, err := s.hub.namespace.newConnection()
+	connection, err := s.hub.namespace.acquireConnection(s)
 	if err != nil {
 		tab.For(ctx).Error(err)
 		return err
@@ -359,13 +420,13 @@ func (s *sender) newSessionAndLink(ctx context.Context) error {
 		return err
 	}
 
-	amqpSession, err := connection.NewSession()
+	sess, err := s.hub.namespace.amqpTransport().newSession(connection)
 	if err != nil {
 		tab.For(ctx).Error(err)
 		return err
 	}
 
-	amqpSender, err := amqpSession.NewSender(
+	amqpSender, err := sess.NewSender(
 		amqp.LinkSenderSettle(amqp.ModeMixed),
 		amqp.LinkReceiverSettle(amqp.ModeFirst),
 		amqp.LinkTargetAddress(s.getAddress()),
@@ -376,13 +437,14 @@ func (s *sender) newSessionAndLink(ctx context.Context) error {
 		return err
 	}
 
-	s.session, err = newSession(amqpSession)
+	s.session, err = newSession(sess)
 	if err != nil {
 		tab.For(ctx).Error(err)
 		return err
 	}
 
 	s.sender.Store(amqpSender)
+	s.hub.namespace.notifyConnection(ConnectionEvent{Type: ConnectionEventLinkAttached, Entity: s.getAddress()})
 	return nil
 }
 
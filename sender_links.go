@@ -0,0 +1,129 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// ErrHubClosed is returned by operations of a Hub started after the Hub was closed
+var ErrHubClosed = errors.New("hub is closed")
+
+// HubWithSenderLinks configures the Hub to send over count links rather than one, to go past the throughput of a
+// single link. Each link has a session of its own unless HubWithLinksPerSession says otherwise. Events and batches with
+// a partition key always go over the same link, so events of a key sent one after the other keep their order; events
+// without a partition key are spread over the links in turn and may overtake each other. Links are opened as they are
+// first used. The links are shared by every goroutine sending with the Hub: count sizes the pool of links, it doesn't
+// limit the number of goroutines which may send at once.
+func HubWithSenderLinks(count int) HubOption {
+	return func(h *Hub) error {
+		if count < 1 {
+			return fmt.Errorf("sender links must be at least 1, got %d", count)
+		}
+		h.senderLinks = count
+		return nil
+	}
+}
+
+// stripedSender returns the sender of the link an event or batch with the given partition key goes over. The first
+// link is the sender of the Hub.
+func (h *Hub) stripedSender(ctx context.Context, partitionKey *string) (*sender, error) {
+	if h.senderLinks <= 1 {
+		return h.getSender(ctx)
+	}
+
+	return h.senderStripe(ctx, senderLinkIndex(partitionKey, h.senderLinks, &h.senderLinkCursor))
+}
+
+// senderStripe returns the sender of the link with the given index, attaching it on first use
+func (h *Hub) senderStripe(ctx context.Context, index int) (*sender, error) {
+	if index == 0 {
+		return h.getSender(ctx)
+	}
+
+	h.senderMu.RLock()
+	closed := h.senderClosed
+	var s *sender
+	if h.senderStripes != nil {
+		s = h.senderStripes[index-1]
+	}
+	h.senderMu.RUnlock()
+	if closed {
+		return nil, ErrHubClosed
+	}
+	if s != nil {
+		return s, nil
+	}
+
+	span, ctx := h.startSpanFromContext(ctx, "eh.Hub.stripedSender")
+	defer span.End()
+
+	h.senderMu.Lock()
+	defer h.senderMu.Unlock()
+
+	if h.senderClosed {
+		return nil, ErrHubClosed
+	}
+	if h.senderStripes == nil {
+		h.senderStripes = make([]*sender, h.senderLinks-1)
+	}
+	if s := h.senderStripes[index-1]; s != nil {
+		return s, nil
+	}
+
+	s, err := h.newSender(ctx, h.senderRetryOptions)
+	if err != nil {
+		return nil, err
+	}
+	h.senderStripes[index-1] = s
+	return s, nil
+}
+
+// senderLinkIndex picks one of count links: by the hash of the partition key for keyed sends, and in turn with cursor
+// otherwise
+func senderLinkIndex(partitionKey *string, count int, cursor *uint32) int {
+	if partitionKey != nil {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(*partitionKey))
+		return int(hash.Sum32() % uint32(count))
+	}
+	return int(atomic.AddUint32(cursor, 1) % uint32(count))
+}
+
+// closeSenderStripes closes the senders of the links past the first one, recording their failures in errs
+func (h *Hub) closeSenderStripes(ctx context.Context, errs *closeErrors) {
+	h.senderMu.Lock()
+	defer h.senderMu.Unlock()
+
+	for i, s := range h.senderStripes {
+		if s == nil {
+			continue
+		}
+		errs.add(fmt.Sprintf("sender link %d", i+1), s.Close(ctx))
+		h.senderStripes[i] = nil
+	}
+}
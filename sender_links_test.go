@@ -0,0 +1,79 @@
+package eventhub
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubWithSenderLinks(t *testing.T) {
+	h := new(Hub)
+	assert.Error(t, HubWithSenderLinks(0)(h))
+	assert.NoError(t, HubWithSenderLinks(4)(h))
+	assert.Equal(t, 4, h.senderLinks)
+}
+
+func TestSenderLinkIndex(t *testing.T) {
+	var cursor uint32
+	for _, key := range []string{"", "a", "device-1", "device-2"} {
+		key := key
+		index := senderLinkIndex(&key, 4, &cursor)
+		assert.True(t, index >= 0 && index < 4, key)
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, index, senderLinkIndex(&key, 4, &cursor), "keys must stay on one link")
+		}
+	}
+	assert.Equal(t, uint32(0), cursor, "keyed sends must not move the cursor")
+
+	seen := make(map[int]int)
+	for i := 0; i < 8; i++ {
+		seen[senderLinkIndex(nil, 4, &cursor)]++
+	}
+	assert.Equal(t, map[int]int{0: 2, 1: 2, 2: 2, 3: 2}, seen)
+}
+
+func TestHub_CloseSenderStripesWithoutStripes(t *testing.T) {
+	h := &Hub{senderStripes: make([]*sender, 3)}
+	errs := new(closeErrors)
+	h.closeSenderStripes(context.Background(), errs)
+	assert.NoError(t, errs.err())
+}
+
+func TestHub_ConcurrentSendersShareLinks(t *testing.T) {
+	links := []*sender{new(sender), new(sender), new(sender)}
+	h := &Hub{senderLinks: 3, sender: links[0], senderStripes: links[1:]}
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var key *string
+			if i%2 == 0 {
+				k := "device"
+				key = &k
+			}
+			s, err := h.senderForKey(context.Background(), key)
+			assert.NoError(t, err)
+			assert.Contains(t, links, s)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, links[1:], h.senderStripes, "no links are attached past the pool")
+}
+
+func TestHub_SendersAfterClose(t *testing.T) {
+	h := &Hub{senderLinks: 2, senderStripes: make([]*sender, 1)}
+	require.NoError(t, h.Close(context.Background()))
+
+	_, err := h.getSender(context.Background())
+	assert.Equal(t, ErrHubClosed, err)
+	_, err = h.senderStripe(context.Background(), 1)
+	assert.Equal(t, ErrHubClosed, err)
+	_, err = h.partitionSenderLocked(context.Background(), "0")
+	assert.Equal(t, ErrHubClosed, err)
+	assert.Equal(t, ErrHubClosed, h.Send(context.Background(), NewEventFromString("after close")))
+}
@@ -61,7 +61,7 @@ func TestSenderRetries(t *testing.T) {
 		recoverCalls = nil
 		sender = &testAmqpSender{}
 
-		err := sendMessage(context.TODO(), getAmqpSender, 3, nil, recover)
+		err := sendMessage(context.TODO(), getAmqpSender, 3, nil, recover, nil)
 		assert.NoError(t, err)
 		assert.EqualValues(t, 1, sender.sendCount)
 		assert.Empty(t, recoverCalls)
@@ -78,7 +78,7 @@ func TestSenderRetries(t *testing.T) {
 
 		actualErr := sendMessage(context.TODO(), getAmqpSender,
 			1, // note we're only allowing 1 retry attempt - so we get the first send() and then 1 additional.
-			nil, recover)
+			nil, recover, nil)
 
 		assert.EqualValues(t, amqp.ErrSessionClosed, actualErr)
 		assert.EqualValues(t, 2, sender.sendCount)
@@ -106,7 +106,7 @@ func TestSenderRetries(t *testing.T) {
 			},
 		}
 
-		actualErr := sendMessage(context.TODO(), getAmqpSender, 5, nil, recover)
+		actualErr := sendMessage(context.TODO(), getAmqpSender, 5, nil, recover, nil)
 
 		assert.EqualValues(t, errors.New("Anything not explicitly retryable kills all retries"), actualErr)
 		assert.EqualValues(t, 1, sender.sendCount)
@@ -121,7 +121,7 @@ func TestSenderRetries(t *testing.T) {
 			},
 		}
 
-		actualErr := sendMessage(context.TODO(), getAmqpSender, 5, nil, recover)
+		actualErr := sendMessage(context.TODO(), getAmqpSender, 5, nil, recover, nil)
 
 		assert.EqualValues(t, amqp.ErrLinkClosed, actualErr)
 		assert.EqualValues(t, 1, sender.sendCount)
@@ -144,7 +144,7 @@ func TestSenderRetries(t *testing.T) {
 				}},
 		}
 
-		err := sendMessage(context.TODO(), getAmqpSender, 6, nil, recover)
+		err := sendMessage(context.TODO(), getAmqpSender, 6, nil, recover, nil)
 		assert.NoError(t, err)
 		assert.EqualValues(t, 4, sender.sendCount)
 		assert.EqualValues(t, []recoveryCall{
@@ -181,7 +181,7 @@ func TestSenderRetries(t *testing.T) {
 			},
 		}
 
-		err := sendMessage(context.TODO(), getAmqpSender, 6, nil, recover)
+		err := sendMessage(context.TODO(), getAmqpSender, 6, nil, recover, nil)
 		assert.NoError(t, err)
 		assert.EqualValues(t, 3, sender.sendCount)
 		assert.EqualValues(t, []recoveryCall{
@@ -208,7 +208,7 @@ func TestSenderRetries(t *testing.T) {
 			},
 		}
 
-		err := sendMessage(context.TODO(), getAmqpSender, 6, nil, recover)
+		err := sendMessage(context.TODO(), getAmqpSender, 6, nil, recover, nil)
 		assert.NoError(t, err)
 		assert.EqualValues(t, 4, sender.sendCount)
 		assert.EqualValues(t, []recoveryCall{
@@ -242,7 +242,7 @@ func TestSenderRetries(t *testing.T) {
 			},
 		}
 
-		err := sendMessage(context.TODO(), getAmqpSender, maxRetries, nil, recover)
+		err := sendMessage(context.TODO(), getAmqpSender, maxRetries, nil, recover, nil)
 		assert.NoError(t, err, "Last call succeeds")
 		assert.EqualValues(t, 3+1, sender.sendCount)
 		assert.EqualValues(t, recoverCalls, []recoveryCall{
@@ -261,7 +261,7 @@ func TestSenderRetries(t *testing.T) {
 			},
 		}
 
-		err := sendMessage(context.TODO(), getAmqpSender, maxRetries, nil, recover)
+		err := sendMessage(context.TODO(), getAmqpSender, maxRetries, nil, recover, nil)
 		assert.EqualValues(t, amqp.ErrConnClosed, err)
 		assert.EqualValues(t, maxRetries+1, sender.sendCount)
 		assert.EqualValues(t, recoverCalls, []recoveryCall{
@@ -281,11 +281,43 @@ func TestSenderRetries(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		cancel()
 
-		err := sendMessage(ctx, getAmqpSender, maxRetries, nil, recover)
+		err := sendMessage(ctx, getAmqpSender, maxRetries, nil, recover, nil)
 		assert.EqualValues(t, context.Canceled, err)
 		assert.EqualValues(t, 0, sender.sendCount)
 		assert.Empty(t, recoverCalls)
 	})
+
+	t.Run("ClassifierStopsRetries", func(t *testing.T) {
+		recoverCalls = nil
+		busy := &amqp.Error{Condition: errorServerBusy, Description: "busy"}
+		sender = &testAmqpSender{sendErrors: []error{busy, busy}}
+
+		var classified []error
+		err := sendMessage(context.TODO(), getAmqpSender, 5, nil, recover, func(err error, retryable bool) bool {
+			classified = append(classified, err)
+			assert.True(t, retryable, "the package retries a busy service")
+			return false
+		})
+		assert.True(t, errors.Is(err, ErrServerBusy{}))
+		assert.EqualValues(t, 1, sender.sendCount)
+		assert.Empty(t, recoverCalls)
+		require.Len(t, classified, 1)
+		assert.True(t, errors.Is(classified[0], ErrServerBusy{}), "the classifier should see the package's error types")
+	})
+
+	t.Run("ClassifierRetriesFatalErrors", func(t *testing.T) {
+		recoverCalls = nil
+		fatal := errors.New("not retried by default")
+		sender = &testAmqpSender{sendErrors: []error{fatal}}
+
+		err := sendMessage(context.TODO(), getAmqpSender, 5, nil, recover, func(err error, retryable bool) bool {
+			assert.False(t, retryable)
+			return true
+		})
+		assert.NoError(t, err)
+		assert.EqualValues(t, 2, sender.sendCount)
+		assert.Equal(t, []recoveryCall{{"sender-id", fatal, true}}, recoverCalls, "the link should be rebuilt")
+	})
 }
 
 type FakeLocker struct {
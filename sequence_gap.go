@@ -0,0 +1,84 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devigned/tab"
+)
+
+type (
+	// SequenceGapError reports events of a partition which were never delivered to the handler: the sequence number of
+	// the event delivered did not follow that of the event delivered before it. It is published to the subscribers of
+	// Hub.SubscribeErrors. Gaps come from misconfigured offsets, events expiring from the partition before they were
+	// received, or filtering bugs.
+	SequenceGapError struct {
+		Entity        string
+		ConsumerGroup string
+		PartitionID   string
+		// Expected is the sequence number which should have been delivered next
+		Expected int64
+		// Received is the sequence number which was delivered instead
+		Received int64
+	}
+)
+
+// Missing is the number of events skipped by the gap
+func (e SequenceGapError) Missing() int64 {
+	return e.Received - e.Expected
+}
+
+func (e SequenceGapError) Error() string {
+	return fmt.Sprintf("%d events missing from %s: expected sequence number %d, received %d", e.Missing(), e.Entity, e.Expected, e.Received)
+}
+
+// checkSequence compares the sequence number of event with that of the event delivered before it, reporting a gap if
+// events were skipped. The first event after the receiver starts, where it may have been positioned anywhere, sets
+// the baseline. Sequence numbers going backwards, as when an event is redelivered, reset the baseline without a report.
+func (r *receiver) checkSequence(ctx context.Context, event *Event) {
+	if event == nil || event.SystemProperties == nil || event.SystemProperties.SequenceNumber == nil {
+		return
+	}
+
+	received := *event.SystemProperties.SequenceNumber
+	last := r.lastSequence
+	r.lastSequence = &received
+	if last == nil || received <= *last+1 {
+		return
+	}
+
+	gap := SequenceGapError{
+		Entity:        r.getAddress(),
+		ConsumerGroup: r.consumerGroup,
+		PartitionID:   r.partitionID,
+		Expected:      *last + 1,
+		Received:      received,
+	}
+	tab.For(ctx).Error(gap)
+	r.hub.log(ctx, LogLevelWarn, "events missing from partition", "entity", gap.Entity, "partitionID", gap.PartitionID, "expected", gap.Expected, "received", gap.Received)
+	r.hub.reportError(ErrorEventSequenceGap, gap.Entity, gap)
+	r.hub.stats.observeSequenceGap(gap.Missing())
+	r.hub.metrics.observeSequenceGap(r.consumerGroup, r.partitionID, gap.Missing())
+}
@@ -0,0 +1,37 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiver_CheckSequence(t *testing.T) {
+	h := &Hub{name: "hub"}
+	events, unsubscribe := h.SubscribeErrors(10)
+	defer unsubscribe()
+
+	r := &receiver{hub: h, consumerGroup: DefaultConsumerGroup, partitionID: "2"}
+	for _, sequence := range []int64{10, 11, 12, 15, 16, 3, 4} {
+		sequence := sequence
+		r.checkSequence(context.Background(), &Event{SystemProperties: &SystemProperties{SequenceNumber: &sequence}})
+	}
+	r.checkSequence(context.Background(), NewEventFromString("no system properties"))
+
+	require.Len(t, events, 1, "only the jump from 12 to 15 is a gap")
+	reported := <-events
+	assert.Equal(t, ErrorEventSequenceGap, reported.Type)
+	var gap SequenceGapError
+	require.True(t, errors.As(reported.Err, &gap))
+	assert.Equal(t, int64(13), gap.Expected)
+	assert.Equal(t, int64(15), gap.Received)
+	assert.Equal(t, int64(2), gap.Missing())
+	assert.Equal(t, "2 events missing from hub/ConsumerGroups/$Default/Partitions/2: expected sequence number 13, received 15", gap.Error())
+
+	stats := h.Stats()
+	assert.Equal(t, int64(1), stats.SequenceGaps)
+	assert.Equal(t, int64(2), stats.MissingEvents)
+}
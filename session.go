@@ -24,27 +24,26 @@ package eventhub
 
 import (
 	"github.com/Azure/azure-amqp-common-go/v3/uuid"
-	"github.com/Azure/go-amqp"
 )
 
 type (
 	// session is a wrapper for the AMQP session with some added information to help with Service Bus messaging
 	session struct {
-		*amqp.Session
+		amqpSession
 		SessionID string
 	}
 )
 
 // newSession is a constructor for a Service Bus session which will pre-populate the SessionID with a new UUID
-func newSession(amqpSession *amqp.Session) (*session, error) {
+func newSession(s amqpSession) (*session, error) {
 	sessionID, err := uuid.NewV4()
 	if err != nil {
 		return nil, err
 	}
 
 	return &session{
-		Session:   amqpSession,
-		SessionID: sessionID.String(),
+		amqpSession: s,
+		SessionID:   sessionID.String(),
 	}, nil
 }
 
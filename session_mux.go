@@ -0,0 +1,166 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Azure/go-amqp"
+)
+
+type (
+	// sessionMultiplexer shares AMQP sessions between the links of a namespace. Each sender and receiver opens a single
+	// link on the session it is handed, so a session is handed out until it carries maxLinks links; then a new session
+	// is opened on the connection. A session is closed once the last of its links is done with it.
+	sessionMultiplexer struct {
+		maxLinks int
+
+		mu       sync.Mutex
+		sessions map[*amqp.Client][]*sharedSession
+	}
+
+	sharedSession struct {
+		session amqpSession
+		conn    *amqp.Client
+		links   int
+	}
+
+	// multiplexedTransport opens sessions through its base transport and shares them with the multiplexer
+	multiplexedTransport struct {
+		base transport
+		mux  *sessionMultiplexer
+	}
+
+	// sessionHandle is one link's share of a shared session; closing it releases the share rather than the session
+	sessionHandle struct {
+		shared *sharedSession
+		mux    *sessionMultiplexer
+		once   sync.Once
+	}
+)
+
+// HubWithLinksPerSession configures how many sender and receiver links share an AMQP session. By default each link
+// gets a session of its own; sharing sessions reduces the number of sessions on a connection, at the cost of links
+// contending for the session's transfer window. A new session is opened whenever every open session on the
+// connection carries maxLinks links. Sessions are shared by the links of a Hub only, even when its connections are
+// pooled.
+func HubWithLinksPerSession(maxLinks int) HubOption {
+	return func(h *Hub) error {
+		if maxLinks < 1 {
+			return fmt.Errorf("links per session must be at least 1, got %d", maxLinks)
+		}
+
+		if maxLinks == 1 {
+			h.namespace.sessionMux = nil
+			return nil
+		}
+		h.namespace.sessionMux = newSessionMultiplexer(maxLinks)
+		return nil
+	}
+}
+
+func newSessionMultiplexer(maxLinks int) *sessionMultiplexer {
+	return &sessionMultiplexer{
+		maxLinks: maxLinks,
+		sessions: make(map[*amqp.Client][]*sharedSession),
+	}
+}
+
+func (t multiplexedTransport) newSession(conn *amqp.Client) (amqpSession, error) {
+	return t.mux.acquire(t.base, conn)
+}
+
+// acquire returns a share of a session on conn with room for another link, opening a session if there is none
+func (m *sessionMultiplexer) acquire(base transport, conn *amqp.Client) (amqpSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, shared := range m.sessions[conn] {
+		if shared.links < m.maxLinks {
+			shared.links++
+			return &sessionHandle{shared: shared, mux: m}, nil
+		}
+	}
+
+	s, err := base.newSession(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	shared := &sharedSession{session: s, conn: conn, links: 1}
+	m.sessions[conn] = append(m.sessions[conn], shared)
+	return &sessionHandle{shared: shared, mux: m}, nil
+}
+
+// forget stops handing out the sessions of conn, which was closed or discarded. Links still using them release their
+// shares as usual.
+func (m *sessionMultiplexer) forget(conn *amqp.Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, conn)
+}
+
+// release gives up a share of the session, closing the session when it was the last
+func (m *sessionMultiplexer) release(ctx context.Context, shared *sharedSession) error {
+	m.mu.Lock()
+	shared.links--
+	if shared.links > 0 {
+		m.mu.Unlock()
+		return nil
+	}
+
+	sessions := m.sessions[shared.conn]
+	for i, s := range sessions {
+		if s == shared {
+			sessions = append(sessions[:i], sessions[i+1:]...)
+			break
+		}
+	}
+	if len(sessions) == 0 {
+		delete(m.sessions, shared.conn)
+	} else {
+		m.sessions[shared.conn] = sessions
+	}
+	m.mu.Unlock()
+
+	return shared.session.Close(ctx)
+}
+
+func (h *sessionHandle) NewSender(opts ...amqp.LinkOption) (amqpSender, error) {
+	return h.shared.session.NewSender(opts...)
+}
+
+func (h *sessionHandle) NewReceiver(opts ...amqp.LinkOption) (amqpReceiver, error) {
+	return h.shared.session.NewReceiver(opts...)
+}
+
+// Close releases the handle's share of the session; closing a handle more than once has no further effect
+func (h *sessionHandle) Close(ctx context.Context) error {
+	var err error
+	h.once.Do(func() {
+		err = h.mux.release(ctx, h.shared)
+	})
+	return err
+}
@@ -0,0 +1,92 @@
+package eventhub
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubWithLinksPerSession(t *testing.T) {
+	h := &Hub{namespace: &namespace{}}
+	assert.Error(t, HubWithLinksPerSession(0)(h))
+
+	require.NoError(t, HubWithLinksPerSession(1)(h))
+	assert.Nil(t, h.namespace.sessionMux, "a session per link needs no multiplexing")
+
+	require.NoError(t, HubWithLinksPerSession(4)(h))
+	assert.Equal(t, 4, h.namespace.sessionMux.maxLinks)
+}
+
+func TestSessionMultiplexer(t *testing.T) {
+	memory := new(memoryTransport)
+	ns := &namespace{transport: memory, sessionMux: newSessionMultiplexer(2)}
+	ctx := context.Background()
+
+	var handles []amqpSession
+	for i := 0; i < 3; i++ {
+		s, err := ns.amqpTransport().newSession(nil)
+		require.NoError(t, err)
+		_, err = s.NewReceiver()
+		require.NoError(t, err)
+		handles = append(handles, s)
+	}
+
+	require.Len(t, memory.sessions, 2, "a new session is opened once the first carries two links")
+	assert.Len(t, memory.sessions[0].receivers, 2)
+	assert.Len(t, memory.sessions[1].receivers, 1)
+
+	require.NoError(t, handles[0].Close(ctx))
+	require.NoError(t, handles[0].Close(ctx))
+	assert.False(t, memory.sessions[0].closed, "the session stays open while a link uses it")
+
+	s, err := ns.amqpTransport().newSession(nil)
+	require.NoError(t, err)
+	assert.Len(t, memory.sessions, 2, "the released share is reused")
+
+	require.NoError(t, handles[1].Close(ctx))
+	require.NoError(t, s.Close(ctx))
+	assert.True(t, memory.sessions[0].closed)
+
+	require.NoError(t, handles[2].Close(ctx))
+	assert.True(t, memory.sessions[1].closed)
+	assert.Empty(t, ns.sessionMux.sessions)
+}
+
+func TestReceiver_RecoverReleasesSharedSession(t *testing.T) {
+	memory := new(memoryTransport)
+	ns := &namespace{transport: memory, sessionMux: newSessionMultiplexer(2)}
+	s, err := ns.amqpTransport().newSession(nil)
+	require.NoError(t, err)
+	link, err := s.NewReceiver()
+	require.NoError(t, err)
+	sess, err := newSession(s)
+	require.NoError(t, err)
+
+	// dialing a new connection fails, as the namespace has no host
+	r := &receiver{hub: &Hub{name: "hub", namespace: ns}, session: sess, receiver: link, consumerGroup: DefaultConsumerGroup, partitionID: "0"}
+	assert.Error(t, r.Recover(context.Background()))
+	assert.True(t, link.(*memoryReceiver).closed, "recovery should close the failed link")
+	assert.True(t, memory.sessions[0].closed, "recovery should release the share of the shared session")
+	assert.Empty(t, ns.sessionMux.sessions)
+}
+
+func TestSessionMultiplexer_Forget(t *testing.T) {
+	memory := new(memoryTransport)
+	mux := newSessionMultiplexer(2)
+	conn := new(amqp.Client)
+	dead, err := mux.acquire(memory, conn)
+	require.NoError(t, err)
+
+	mux.forget(conn)
+	assert.Empty(t, mux.sessions, "the sessions of a discarded connection should not be kept")
+	_, err = mux.acquire(memory, conn)
+	require.NoError(t, err)
+	assert.Len(t, memory.sessions, 2, "the sessions of a discarded connection should not be handed out")
+
+	require.NoError(t, dead.Close(context.Background()))
+	assert.True(t, memory.sessions[0].closed)
+	assert.Len(t, mux.sessions[conn], 1)
+}
@@ -0,0 +1,88 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/devigned/tab"
+)
+
+type (
+	// SlowHandlerError reports a handler invocation which took longer than the threshold configured with
+	// HubWithSlowHandlerThreshold. It is published to the subscribers of Hub.SubscribeErrors; the event was still
+	// processed.
+	SlowHandlerError struct {
+		Entity         string
+		ConsumerGroup  string
+		PartitionID    string
+		SequenceNumber int64
+		Duration       time.Duration
+		Threshold      time.Duration
+	}
+)
+
+// HubWithSlowHandlerThreshold reports every handler invocation which takes longer than threshold: it is logged at
+// warn level and published as a SlowHandlerError to the subscribers of Hub.SubscribeErrors, with the partition and
+// sequence number of the event and the time taken, and counted in Stats and, with HubWithMetrics, in
+// eventhub_slow_handlers_total.
+func HubWithSlowHandlerThreshold(threshold time.Duration) HubOption {
+	return func(h *Hub) error {
+		if threshold <= 0 {
+			return fmt.Errorf("slow handler threshold must be positive, got %v", threshold)
+		}
+		h.slowHandlerThreshold = threshold
+		return nil
+	}
+}
+
+func (e SlowHandlerError) Error() string {
+	return fmt.Sprintf("handler for event %d of %s took %v, over the threshold of %v", e.SequenceNumber, e.Entity, e.Duration, e.Threshold)
+}
+
+// checkHandlerDuration reports the handler invocation for event if it took longer than the slow handler threshold
+func (r *receiver) checkHandlerDuration(ctx context.Context, event *Event, elapsed time.Duration) {
+	threshold := r.hub.slowHandlerThreshold
+	if threshold <= 0 || elapsed <= threshold {
+		return
+	}
+
+	slow := SlowHandlerError{
+		Entity:        r.getAddress(),
+		ConsumerGroup: r.consumerGroup,
+		PartitionID:   r.partitionID,
+		Duration:      elapsed,
+		Threshold:     threshold,
+	}
+	if event != nil && event.SystemProperties != nil && event.SystemProperties.SequenceNumber != nil {
+		slow.SequenceNumber = *event.SystemProperties.SequenceNumber
+	}
+
+	tab.For(ctx).Info(slow.Error())
+	r.hub.log(ctx, LogLevelWarn, "slow handler", "entity", slow.Entity, "partitionID", slow.PartitionID, "sequenceNumber", slow.SequenceNumber, "duration", elapsed, "threshold", threshold)
+	r.hub.reportError(ErrorEventSlowHandler, slow.Entity, slow)
+	r.hub.stats.observeSlowHandler()
+	r.hub.metrics.observeSlowHandler(r.consumerGroup, r.partitionID)
+}
@@ -0,0 +1,54 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3/metrics"
+)
+
+func TestHubWithSlowHandlerThreshold(t *testing.T) {
+	registry := metrics.NewRegistry()
+	h := &Hub{name: "hub", namespace: &namespace{name: "ns"}}
+	assert.Error(t, HubWithSlowHandlerThreshold(0)(h))
+	require.NoError(t, HubWithSlowHandlerThreshold(time.Second)(h))
+	require.NoError(t, HubWithMetrics(registry)(h))
+	events, unsubscribe := h.SubscribeErrors(10)
+	defer unsubscribe()
+
+	r := &receiver{hub: h, consumerGroup: DefaultConsumerGroup, partitionID: "3"}
+	sequence := int64(99)
+	event := &Event{SystemProperties: &SystemProperties{SequenceNumber: &sequence}}
+	r.checkHandlerDuration(context.Background(), event, 500*time.Millisecond)
+	r.checkHandlerDuration(context.Background(), event, 2*time.Second)
+
+	require.Len(t, events, 1)
+	reported := <-events
+	assert.Equal(t, ErrorEventSlowHandler, reported.Type)
+	var slow SlowHandlerError
+	require.True(t, errors.As(reported.Err, &slow))
+	assert.Equal(t, SlowHandlerError{
+		Entity:         "hub/ConsumerGroups/$Default/Partitions/3",
+		ConsumerGroup:  DefaultConsumerGroup,
+		PartitionID:    "3",
+		SequenceNumber: 99,
+		Duration:       2 * time.Second,
+		Threshold:      time.Second,
+	}, slow)
+	assert.Equal(t, int64(1), h.Stats().SlowHandlers)
+
+	var sb strings.Builder
+	require.NoError(t, registry.WriteText(&sb))
+	assert.Contains(t, sb.String(), `eventhub_slow_handlers_total{namespace="ns",hub="hub",consumer_group="$Default",partition="3"} 1`+"\n")
+
+	// detection is off by default
+	r.hub = new(Hub)
+	r.checkHandlerDuration(context.Background(), event, time.Hour)
+	assert.Zero(t, r.hub.Stats().SlowHandlers)
+}
@@ -0,0 +1,115 @@
+package storage
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-amqp-common-go/v3/uuid"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+// checkpointWriteLeaseDuration is the shortest lease Azure Storage grants, held while a checkpoint is written outside
+// of an Event Processor Host
+const checkpointWriteLeaseDuration = 15 * time.Second
+
+var _ eventhub.CheckpointStore = (*LeaserCheckpointer)(nil)
+
+// ReadCheckpoint reads the checkpoint of the partition from its blob, whether or not a host holds its lease. It lets
+// the LeaserCheckpointer be used as an eventhub.CheckpointStore, for instance to reset the checkpoints of a consumer
+// group with eventhub.ResetCheckpoints, without running an Event Processor Host.
+func (sl *LeaserCheckpointer) ReadCheckpoint(ctx context.Context, partitionID string) (persist.Checkpoint, bool, error) {
+	span, ctx := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.ReadCheckpoint")
+	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
+
+	lease, err := sl.getLease(ctx, partitionID)
+	if isBlobNotFound(err) {
+		return persist.NewCheckpointFromStartOfStream(), false, nil
+	}
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return persist.Checkpoint{}, false, err
+	}
+	if lease.Checkpoint == nil {
+		return persist.NewCheckpointFromStartOfStream(), false, nil
+	}
+	return *lease.Checkpoint, true, nil
+}
+
+// WriteCheckpoint writes the checkpoint of the partition to its blob, leasing the blob for the time of the write. It
+// fails if an Event Processor Host holds the lease of the partition, as the host would overwrite the checkpoint with
+// its own.
+func (sl *LeaserCheckpointer) WriteCheckpoint(ctx context.Context, partitionID string, checkpoint persist.Checkpoint) error {
+	span, ctx := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.WriteCheckpoint")
+	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
+
+	lease, err := sl.getLease(ctx, partitionID)
+	if isBlobNotFound(err) {
+		lease, err = sl.createOrGetLease(ctx, partitionID)
+	}
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return err
+	}
+	if lease.State == azblob.LeaseStateLeased {
+		err := fmt.Errorf("the lease of partition %q is held by %q; stop the hosts before writing its checkpoint", partitionID, lease.Owner)
+		tab.For(ctx).Error(err)
+		return err
+	}
+
+	token, err := uuid.NewV4()
+	if err != nil {
+		tab.For(ctx).Error(err)
+		return err
+	}
+	blobURL := sl.containerURL.NewBlobURL(sl.blobPathPrefix + partitionID)
+	if _, err := blobURL.AcquireLease(ctx, token.String(), int32(checkpointWriteLeaseDuration/time.Second), azblob.ModifiedAccessConditions{}); err != nil {
+		tab.For(ctx).Error(err)
+		return err
+	}
+
+	lease.Token = token.String()
+	lease.Checkpoint = &checkpoint
+	err = sl.uploadLease(ctx, lease)
+	if _, releaseErr := blobURL.ReleaseLease(ctx, lease.Token, azblob.ModifiedAccessConditions{}); releaseErr != nil && err == nil {
+		err = releaseErr
+	}
+	if err != nil {
+		tab.For(ctx).Error(err)
+	}
+	return err
+}
+
+func isBlobNotFound(err error) bool {
+	var storageErr azblob.StorageError
+	return errors.As(err, &storageErr) && storageErr.ServiceCode() == azblob.ServiceCodeBlobNotFound
+}
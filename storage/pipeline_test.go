@@ -0,0 +1,69 @@
+package storage
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+func TestRequestIDPolicy(t *testing.T) {
+	var sent []string
+	p := pipeline.NewPipeline([]pipeline.Factory{
+		requestIDPolicyFactory(),
+		azblob.NewUniqueRequestIDPolicyFactory(),
+	}, pipeline.Options{
+		HTTPSender: pipeline.FactoryFunc(func(_ pipeline.Policy, _ *pipeline.PolicyOptions) pipeline.PolicyFunc {
+			return func(_ context.Context, request pipeline.Request) (pipeline.Response, error) {
+				sent = append(sent, request.Header.Get(requestIDHeader))
+				return pipeline.NewHTTPResponse(&http.Response{StatusCode: http.StatusOK}), nil
+			}
+		}),
+	})
+	u, err := url.Parse("https://account.blob.core.windows.net/container/0")
+	require.NoError(t, err)
+
+	for _, ctx := range []context.Context{
+		eventhub.WithRequestID(context.Background(), "lease-op"),
+		context.Background(),
+	} {
+		req, err := pipeline.NewRequest(http.MethodPut, *u, nil)
+		require.NoError(t, err)
+		_, err = p.Do(ctx, nil, req)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, sent, 2)
+	assert.Equal(t, "lease-op", sent[0], "the request ID of the operation should be sent")
+	assert.NotEmpty(t, sent[1], "requests without an operation's request ID should get a generated one")
+	assert.NotEqual(t, "lease-op", sent[1])
+}
@@ -29,7 +29,6 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
 	"net/url"
 	"sync"
 	"time"
@@ -39,8 +38,10 @@ import (
 
 	"github.com/Azure/azure-event-hubs-go/v3"
 	"github.com/Azure/azure-event-hubs-go/v3/eph"
+	"github.com/Azure/azure-event-hubs-go/v3/internal/buffer"
 	"github.com/Azure/azure-event-hubs-go/v3/persist"
 
+	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/Azure/go-autorest/autorest/azure"
 )
@@ -95,6 +96,8 @@ type (
 
 const (
 	defaultLeasePersistenceInterval = 5 * time.Second
+
+	requestIDHeader = "x-ms-client-request-id"
 )
 
 // NewStorageLeaserCheckpointer builds an Azure Storage Leaser Checkpointer which handles leasing and checkpointing for
@@ -105,7 +108,7 @@ func NewStorageLeaserCheckpointer(credential Credential, accountName, containerN
 		return nil, err
 	}
 
-	svURL := azblob.NewServiceURL(*storageURL, azblob.NewPipeline(credential, azblob.PipelineOptions{}))
+	svURL := azblob.NewServiceURL(*storageURL, newPipeline(credential))
 	containerURL := svURL.NewContainerURL(containerName)
 
 	ls := &LeaserCheckpointer{
@@ -131,6 +134,32 @@ func NewStorageLeaserCheckpointer(credential Credential, accountName, containerN
 	return ls, nil
 }
 
+// newPipeline builds the pipeline azblob.NewPipeline would, with a policy sending the client request ID of the lease or
+// checkpoint operation a request is made for rather than one generated per request
+func newPipeline(credential Credential) pipeline.Pipeline {
+	return pipeline.NewPipeline([]pipeline.Factory{
+		azblob.NewTelemetryPolicyFactory(azblob.TelemetryOptions{}),
+		requestIDPolicyFactory(),
+		azblob.NewUniqueRequestIDPolicyFactory(),
+		azblob.NewRetryPolicyFactory(azblob.RetryOptions{}),
+		credential,
+		azblob.NewRequestLogPolicyFactory(azblob.RequestLogOptions{}),
+		pipeline.MethodFactoryMarker(),
+	}, pipeline.Options{})
+}
+
+// requestIDPolicyFactory sets the client request ID header of requests to the one carried by their context, if any
+func requestIDPolicyFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, _ *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			if id, ok := eventhub.RequestIDFromContext(ctx); ok {
+				request.Header.Set(requestIDHeader, id)
+			}
+			return next.Do(ctx, request)
+		}
+	})
+}
+
 // SetEventHostProcessor sets the EventHostProcessor on the instance of the LeaserCheckpointer
 func (sl *LeaserCheckpointer) SetEventHostProcessor(eph *eph.EventProcessorHost) {
 	sl.processor = eph
@@ -240,6 +269,7 @@ func (sl *LeaserCheckpointer) EnsureLease(ctx context.Context, partitionID strin
 	defer sl.leasesMu.Unlock()
 	span, ctx := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.EnsureLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	return sl.createOrGetLease(ctx, partitionID)
 }
@@ -251,6 +281,7 @@ func (sl *LeaserCheckpointer) DeleteLease(ctx context.Context, partitionID strin
 
 	span, ctx := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.DeleteLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	_, err := sl.containerURL.NewBlobURL(sl.blobPathPrefix+partitionID).Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
 	delete(sl.leases, partitionID)
@@ -264,6 +295,7 @@ func (sl *LeaserCheckpointer) AcquireLease(ctx context.Context, partitionID stri
 
 	span, ctx := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.AcquireLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	blobURL := sl.containerURL.NewBlobURL(sl.blobPathPrefix + partitionID)
 	lease, err := sl.getLease(ctx, partitionID)
@@ -318,17 +350,18 @@ func (sl *LeaserCheckpointer) RenewLease(ctx context.Context, partitionID string
 
 	span, ctx := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.RenewLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	blobURL := sl.containerURL.NewBlobURL(sl.blobPathPrefix + partitionID)
 	lease, ok := sl.leases[partitionID]
 	if !ok {
-		return nil, false, errors.New("lease was not found")
+		return nil, false, eph.ErrLeaseLost
 	}
 
 	_, err := blobURL.RenewLease(ctx, lease.Token, azblob.ModifiedAccessConditions{})
 	if err != nil {
 		tab.For(ctx).Error(err)
-		return nil, false, err
+		return nil, false, leaseError(err)
 	}
 	return lease, true, nil
 }
@@ -340,6 +373,7 @@ func (sl *LeaserCheckpointer) ReleaseLease(ctx context.Context, partitionID stri
 
 	span, ctx := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.ReleaseLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	blobURL := sl.containerURL.NewBlobURL(sl.blobPathPrefix + partitionID)
 	lease, ok := sl.leases[partitionID]
@@ -364,6 +398,7 @@ func (sl *LeaserCheckpointer) UpdateLease(ctx context.Context, partitionID strin
 
 	span, ctx := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.UpdateLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	return sl.updateLease(ctx, partitionID)
 }
@@ -371,17 +406,18 @@ func (sl *LeaserCheckpointer) UpdateLease(ctx context.Context, partitionID strin
 func (sl *LeaserCheckpointer) updateLease(ctx context.Context, partitionID string) (eph.LeaseMarker, bool, error) {
 	span, ctx := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.updateLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	blobURL := sl.containerURL.NewBlobURL(sl.blobPathPrefix + partitionID)
 	lease, ok := sl.leases[partitionID]
 	if !ok {
-		return nil, false, errors.New("lease was not found")
+		return nil, false, eph.ErrLeaseLost
 	}
 
 	_, err := blobURL.RenewLease(ctx, lease.Token, azblob.ModifiedAccessConditions{})
 	if err != nil {
 		tab.For(ctx).Error(err)
-		return nil, false, err
+		return nil, false, leaseError(err)
 	}
 
 	if !ok {
@@ -404,6 +440,7 @@ func (sl *LeaserCheckpointer) GetCheckpoint(ctx context.Context, partitionID str
 
 	span, _ := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.GetCheckpoint")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	lease, ok := sl.leases[partitionID]
 	if ok {
@@ -419,6 +456,7 @@ func (sl *LeaserCheckpointer) EnsureCheckpoint(ctx context.Context, partitionID
 
 	span, _ := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.EnsureCheckpoint")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	lease, ok := sl.leases[partitionID]
 	if ok {
@@ -438,10 +476,11 @@ func (sl *LeaserCheckpointer) UpdateCheckpoint(ctx context.Context, partitionID
 
 	span, _ := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.UpdateCheckpoint")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	lease, ok := sl.leases[partitionID]
 	if !ok {
-		return errors.New("lease for partition isn't owned by this EventProcessorHost")
+		return eph.ErrCheckpointConflict
 	}
 
 	lease.Checkpoint = &checkpoint
@@ -460,10 +499,11 @@ func (sl *LeaserCheckpointer) DeleteCheckpoint(ctx context.Context, partitionID
 
 	span, ctx := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.DeleteCheckpoint")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	lease, ok := sl.leases[partitionID]
 	if !ok {
-		return errors.New("lease for partition isn't owned by this EventProcessorHost")
+		return eph.ErrCheckpointConflict
 	}
 
 	checkpoint := persist.NewCheckpointFromStartOfStream()
@@ -481,12 +521,13 @@ func (sl *LeaserCheckpointer) DeleteCheckpoint(ctx context.Context, partitionID
 
 }
 
-// Close will stop the leaser / checkpointer from persisting dirty leases & checkpoints to storage
-func (sl *LeaserCheckpointer) Close() error {
+// Close will stop the leaser / checkpointer from persisting dirty leases & checkpoints to storage in the background,
+// and persist the leases & checkpoints still dirty until ctx is done
+func (sl *LeaserCheckpointer) Close(ctx context.Context) error {
 	if sl.done != nil {
 		sl.done()
 	}
-	return nil
+	return sl.persistDirtyPartitions(ctx)
 }
 
 func (sl *LeaserCheckpointer) persistLeases(ctx context.Context) {
@@ -558,6 +599,7 @@ func (sl *LeaserCheckpointer) persistDirtyPartitions(ctx context.Context) error
 func (sl *LeaserCheckpointer) persistLease(ctx context.Context, partitionID string) error {
 	span, _ := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.persistLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
@@ -578,7 +620,9 @@ func (sl *LeaserCheckpointer) uploadLease(ctx context.Context, lease *storageLea
 	defer span.End()
 
 	blobURL := sl.containerURL.NewBlobURL(sl.blobPathPrefix + lease.PartitionID)
-	jsonLease, err := json.Marshal(lease)
+	buf := buffer.Get()
+	defer buffer.Put(buf)
+	jsonLease, err := marshalLease(buf, lease)
 	if err != nil {
 		return err
 	}
@@ -595,6 +639,7 @@ func (sl *LeaserCheckpointer) uploadLease(ctx context.Context, lease *storageLea
 func (sl *LeaserCheckpointer) createOrGetLease(ctx context.Context, partitionID string) (*storageLease, error) {
 	span, ctx := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.createOrGetLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	lease := &storageLease{
 		Lease: &eph.Lease{
@@ -602,7 +647,9 @@ func (sl *LeaserCheckpointer) createOrGetLease(ctx context.Context, partitionID
 		},
 	}
 	blobURL := sl.containerURL.NewBlobURL(sl.blobPathPrefix + partitionID)
-	jsonLease, err := json.Marshal(lease)
+	buf := buffer.Get()
+	defer buffer.Put(buf)
+	jsonLease, err := marshalLease(buf, lease)
 	if err != nil {
 		return nil, err
 	}
@@ -626,6 +673,7 @@ func (sl *LeaserCheckpointer) createOrGetLease(ctx context.Context, partitionID
 func (sl *LeaserCheckpointer) getLease(ctx context.Context, partitionID string) (*storageLease, error) {
 	span, ctx := startConsumerSpanFromContext(ctx, "storage.LeaserCheckpointer.getLease")
 	defer span.End()
+	span.AddAttributes(tab.StringAttribute("eh.partition", partitionID))
 
 	blobURL := sl.containerURL.NewBlobURL(sl.blobPathPrefix + partitionID)
 	res, err := blobURL.Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false)
@@ -636,13 +684,15 @@ func (sl *LeaserCheckpointer) getLease(ctx context.Context, partitionID string)
 }
 
 func (sl *LeaserCheckpointer) leaseFromResponse(res *azblob.DownloadResponse) (*storageLease, error) {
-	b, err := ioutil.ReadAll(res.Response().Body)
-	if err != nil {
+	// decoding copies what it keeps of the blob, so the blob is read into a pooled buffer
+	buf := buffer.Get()
+	defer buffer.Put(buf)
+	if _, err := buf.ReadFrom(res.Response().Body); err != nil {
 		return nil, err
 	}
 
 	var lease storageLease
-	if err := json.Unmarshal(b, &lease); err != nil {
+	if err := json.Unmarshal(buf.Bytes(), &lease); err != nil {
 		return nil, err
 	}
 	lease.leaser = sl
@@ -672,6 +722,16 @@ func (s *storageLease) IsExpired(ctx context.Context) bool {
 	return lease.State != azblob.LeaseStateLeased
 }
 
+// marshalLease encodes lease as JSON into buf, returning the bytes of buf, which are only valid until buf is returned
+// to the pool
+func marshalLease(buf *bytes.Buffer, lease *storageLease) ([]byte, error) {
+	if err := json.NewEncoder(buf).Encode(lease); err != nil {
+		return nil, err
+	}
+	// Encode ends the JSON with a newline, which json.Marshal didn't write
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
 func (s *storageLease) String() string {
 	bits, err := json.Marshal(s)
 	if err != nil {
@@ -705,3 +765,38 @@ func WithInitialCheckpoint(getInitialCheckpoint func() persist.Checkpoint) Lease
 		return nil
 	}
 }
+
+// leaseLostError is a storage error which means the lease of a partition was lost; it matches eph.ErrLeaseLost
+type leaseLostError struct {
+	cause error
+}
+
+func (e leaseLostError) Error() string {
+	return e.cause.Error()
+}
+
+// Is reports whether target is eph.ErrLeaseLost
+func (e leaseLostError) Is(target error) bool {
+	return target == eph.ErrLeaseLost
+}
+
+// Unwrap returns the storage error
+func (e leaseLostError) Unwrap() error {
+	return e.cause
+}
+
+// leaseError returns err so it matches eph.ErrLeaseLost if storage rejected a lease operation because another lease
+// holds the blob or the lease has expired
+func leaseError(err error) error {
+	var storageErr azblob.StorageError
+	if !errors.As(err, &storageErr) {
+		return err
+	}
+	switch storageErr.ServiceCode() {
+	case azblob.ServiceCodeLeaseIDMismatchWithLeaseOperation, azblob.ServiceCodeLeaseLost,
+		azblob.ServiceCodeLeaseNotPresentWithLeaseOperation, azblob.ServiceCodeLeaseIsBrokenAndCannotBeRenewed:
+		return leaseLostError{cause: err}
+	default:
+		return err
+	}
+}
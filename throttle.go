@@ -0,0 +1,182 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// retryAfterInfoKey is the key of the error info entry in which the service may suggest a delay, in seconds
+	retryAfterInfoKey = "retry-after"
+
+	defaultThrottleBaseDelay = 4 * time.Second
+	defaultThrottleMaxDelay  = time.Minute
+)
+
+type (
+	// serverBusyThrottle holds back the senders, receivers and management requests of a Hub after the service reports
+	// it is busy, so they don't retry in lockstep and prolong the throttling. The delay is the one suggested by the
+	// service, if any, and otherwise doubles with each consecutive busy response, up to max.
+	serverBusyThrottle struct {
+		base time.Duration
+		max  time.Duration
+		now  func() time.Time
+
+		mu          sync.Mutex
+		consecutive int
+		until       time.Time
+	}
+)
+
+func newServerBusyThrottle(base, max time.Duration) *serverBusyThrottle {
+	return &serverBusyThrottle{
+		base: base,
+		max:  max,
+		now:  time.Now,
+	}
+}
+
+// HubWithServerBusyBackoff configures the delay applied to every sender, receiver and management request of the Hub
+// after the service reports it is busy or timed out. Unless the service suggests a delay, the first busy response
+// delays operations by base, and each further consecutive one doubles the delay up to max.
+func HubWithServerBusyBackoff(base, max time.Duration) HubOption {
+	return func(h *Hub) error {
+		if base <= 0 || max < base {
+			return errors.New("server busy backoff requires 0 < base <= max")
+		}
+		h.throttle = newServerBusyThrottle(base, max)
+		return nil
+	}
+}
+
+// observe records the outcome of an operation. If err indicates throttling, the delay before operations resume is
+// returned.
+func (t *serverBusyThrottle) observe(err error) (time.Duration, bool) {
+	if t == nil {
+		return 0, false
+	}
+
+	if err == nil {
+		t.mu.Lock()
+		t.consecutive = 0
+		t.mu.Unlock()
+		return 0, false
+	}
+
+	if !isThrottled(err) {
+		return 0, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delay, ok := suggestedDelay(err)
+	if !ok {
+		delay = t.base << uint(t.consecutive)
+		if delay > t.max || delay <= 0 {
+			delay = t.max
+		}
+	}
+	t.consecutive++
+
+	if until := t.now().Add(delay); until.After(t.until) {
+		t.until = until
+	}
+	return delay, true
+}
+
+// remaining returns how long operations are still held back
+func (t *serverBusyThrottle) remaining() time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if d := t.until.Sub(t.now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// wait blocks until operations are no longer held back or the context is done
+func (t *serverBusyThrottle) wait(ctx context.Context) error {
+	d := t.remaining()
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isThrottled indicates whether err reports that the service is busy or timed out processing an operation
+func isThrottled(err error) bool {
+	if errors.Is(err, ErrServerBusy{}) {
+		return true
+	}
+
+	if remote := remoteAMQPError(err); remote != nil {
+		return remote.Condition == errorServerBusy || remote.Condition == errorTimeout
+	}
+	return false
+}
+
+// suggestedDelay returns the delay the service suggested in the error info, if any
+func suggestedDelay(err error) (time.Duration, bool) {
+	remote := remoteAMQPError(err)
+	if remote == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	switch v := remote.Info[retryAfterInfoKey].(type) {
+	case int32:
+		seconds = float64(v)
+	case int64:
+		seconds = float64(v)
+	case uint32:
+		seconds = float64(v)
+	case uint64:
+		seconds = float64(v)
+	case float64:
+		seconds = v
+	default:
+		return 0, false
+	}
+
+	if seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
@@ -0,0 +1,65 @@
+package eventhub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerBusyThrottle(t *testing.T) {
+	now := time.Now()
+	throttle := newServerBusyThrottle(time.Second, 5*time.Second)
+	throttle.now = func() time.Time { return now }
+
+	_, ok := throttle.observe(errors.New("not throttling"))
+	assert.False(t, ok)
+	assert.Zero(t, throttle.remaining())
+
+	busy := &amqp.Error{Condition: errorServerBusy}
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		delay, ok := throttle.observe(busy)
+		require.True(t, ok)
+		assert.Equal(t, expected, delay)
+	}
+	assert.Equal(t, 5*time.Second, throttle.remaining())
+
+	throttle.observe(nil)
+	delay, _ := throttle.observe(&amqp.DetachError{RemoteError: &amqp.Error{Condition: errorTimeout}})
+	assert.Equal(t, time.Second, delay, "a success resets the adaptive delay")
+
+	delay, _ = throttle.observe(&amqp.Error{Condition: errorServerBusy, Info: map[string]interface{}{retryAfterInfoKey: int64(30)}})
+	assert.Equal(t, 30*time.Second, delay, "the delay suggested by the service wins")
+
+	delay, ok = throttle.observe(ErrServerBusy{Description: "management node busy"})
+	assert.True(t, ok)
+	assert.Equal(t, 4*time.Second, delay, "suggested delays still count as consecutive busy responses")
+
+	now = now.Add(time.Minute)
+	assert.Zero(t, throttle.remaining())
+}
+
+func TestServerBusyThrottle_Wait(t *testing.T) {
+	var nilThrottle *serverBusyThrottle
+	assert.NoError(t, nilThrottle.wait(context.Background()))
+	_, ok := nilThrottle.observe(ErrServerBusy{})
+	assert.False(t, ok)
+
+	throttle := newServerBusyThrottle(time.Hour, time.Hour)
+	throttle.observe(ErrServerBusy{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, throttle.wait(ctx))
+}
+
+func TestHubWithServerBusyBackoff(t *testing.T) {
+	h := new(Hub)
+	assert.Error(t, HubWithServerBusyBackoff(0, time.Second)(h))
+	assert.Error(t, HubWithServerBusyBackoff(time.Minute, time.Second)(h))
+	require.NoError(t, HubWithServerBusyBackoff(time.Second, time.Minute)(h))
+	assert.Equal(t, time.Second, h.throttle.base)
+}
@@ -0,0 +1,82 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/go-amqp"
+)
+
+type (
+	// OperationTimeouts are the default time limits of the Hub's operations. A limit applies when the context passed
+	// to an operation has no deadline of its own; a zero limit leaves the operation bound only by its context.
+	OperationTimeouts struct {
+		// Connect bounds establishing a connection to the namespace, including the TLS handshake
+		Connect time.Duration
+		// Send bounds Send and SendBatch, including retries
+		Send time.Duration
+		// ReceiveAttach bounds setting up a receiver in Receive, including authorization and attaching the link
+		ReceiveAttach time.Duration
+		// Management bounds runtime information and other management requests, including retries
+		Management time.Duration
+	}
+)
+
+// HubWithOperationTimeouts configures default time limits for the Hub's operations, so callers which pass contexts
+// without deadlines don't hang indefinitely on an unresponsive network
+func HubWithOperationTimeouts(timeouts OperationTimeouts) HubOption {
+	return func(h *Hub) error {
+		for name, d := range map[string]time.Duration{
+			"connect":        timeouts.Connect,
+			"send":           timeouts.Send,
+			"receive attach": timeouts.ReceiveAttach,
+			"management":     timeouts.Management,
+		} {
+			if d < 0 {
+				return fmt.Errorf("%s timeout must not be negative, got %v", name, d)
+			}
+		}
+
+		h.timeouts = timeouts
+		h.namespace.connectTimeout = timeouts.Connect
+		if timeouts.Connect > 0 {
+			h.namespace.connOptions = append(h.namespace.connOptions, amqp.ConnConnectTimeout(timeouts.Connect))
+		}
+		return nil
+	}
+}
+
+// withDefaultTimeout bounds ctx by timeout unless ctx already has a deadline or timeout is 0
+func withDefaultTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
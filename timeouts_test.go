@@ -0,0 +1,42 @@
+package eventhub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubWithOperationTimeouts(t *testing.T) {
+	h := &Hub{namespace: &namespace{}}
+	assert.Error(t, HubWithOperationTimeouts(OperationTimeouts{Send: -time.Second})(h))
+
+	require.NoError(t, HubWithOperationTimeouts(OperationTimeouts{Connect: 10 * time.Second, Management: time.Minute})(h))
+	assert.Equal(t, 10*time.Second, h.namespace.connectTimeout)
+	assert.Len(t, h.namespace.connOptions, 1)
+	assert.Equal(t, time.Minute, h.timeouts.Management)
+}
+
+func TestWithDefaultTimeout(t *testing.T) {
+	ctx, cancel := withDefaultTimeout(context.Background(), 0)
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok, "a zero timeout leaves the context unbounded")
+
+	ctx, cancel = withDefaultTimeout(context.Background(), time.Minute)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	callerCtx, callerCancel := context.WithTimeout(context.Background(), time.Second)
+	defer callerCancel()
+	ctx, cancel = withDefaultTimeout(callerCtx, time.Minute)
+	defer cancel()
+	deadline, ok = ctx.Deadline()
+	require.True(t, ok)
+	callerDeadline, _ := callerCtx.Deadline()
+	assert.Equal(t, callerDeadline, deadline, "the caller's deadline takes precedence")
+}
@@ -0,0 +1,96 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"github.com/devigned/tab"
+)
+
+const (
+	// diagnosticIDKey is the application property the .NET and Java clients carry W3C trace context in
+	diagnosticIDKey = "Diagnostic-Id"
+	traceParentKey  = "traceparent"
+)
+
+type (
+	// diagnosticIDCarrier presents an event whose trace context is only in Diagnostic-Id as if it carried a
+	// traceparent, so tracers which propagate W3C trace context parent the handler span to the producer span
+	diagnosticIDCarrier struct {
+		tab.Carrier
+	}
+)
+
+// injectTraceContext writes the trace context of span into the properties of carrier, unless the application has
+// already set one. The context is written as traceparent, or in the tracer's own format, and mirrored into
+// Diagnostic-Id for consumers built with the other Azure SDKs.
+func injectTraceContext(span tab.Spanner, carrier tab.Carrier) error {
+	if hasTraceContext(carrier) {
+		return nil
+	}
+
+	if err := span.Inject(carrier); err != nil {
+		return err
+	}
+
+	if tp, ok := carrier.GetKeyValues()[traceParentKey]; ok {
+		carrier.Set(diagnosticIDKey, tp)
+	}
+	return nil
+}
+
+func hasTraceContext(carrier tab.Carrier) bool {
+	kvs := carrier.GetKeyValues()
+	for _, key := range []string{diagnosticIDKey, traceParentKey} {
+		if _, ok := kvs[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteTraceContext returns the carrier to start a handler span for event with
+func remoteTraceContext(event *Event) tab.Carrier {
+	if event == nil {
+		return nil
+	}
+	return diagnosticIDCarrier{Carrier: event}
+}
+
+// GetKeyValues returns the properties of the event, adding a traceparent taken from Diagnostic-Id if it has none
+func (c diagnosticIDCarrier) GetKeyValues() map[string]interface{} {
+	kvs := c.Carrier.GetKeyValues()
+	id, ok := kvs[diagnosticIDKey]
+	if !ok {
+		return kvs
+	}
+	if _, ok := kvs[traceParentKey]; ok {
+		return kvs
+	}
+
+	withParent := make(map[string]interface{}, len(kvs)+1)
+	for k, v := range kvs {
+		withParent[k] = v
+	}
+	withParent[traceParentKey] = id
+	return withParent
+}
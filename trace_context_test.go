@@ -0,0 +1,49 @@
+package eventhub
+
+import (
+	"testing"
+
+	"github.com/devigned/tab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type traceParentSpanner struct {
+	tab.Spanner
+	traceParent string
+}
+
+func (s traceParentSpanner) Inject(carrier tab.Carrier) error {
+	carrier.Set(traceParentKey, s.traceParent)
+	return nil
+}
+
+func TestInjectTraceContext(t *testing.T) {
+	const traceParent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	span := traceParentSpanner{traceParent: traceParent}
+
+	event := NewEventFromString("foo")
+	require.NoError(t, injectTraceContext(span, event))
+	assert.Equal(t, traceParent, event.Properties[traceParentKey])
+	assert.Equal(t, traceParent, event.Properties[diagnosticIDKey])
+
+	event = NewEventFromString("foo")
+	event.Set(diagnosticIDKey, "set-by-application")
+	require.NoError(t, injectTraceContext(span, event))
+	assert.Equal(t, "set-by-application", event.Properties[diagnosticIDKey])
+	assert.NotContains(t, event.Properties, traceParentKey)
+}
+
+func TestRemoteTraceContext(t *testing.T) {
+	assert.Nil(t, remoteTraceContext(nil))
+
+	event := NewEventFromString("foo")
+	event.Set(diagnosticIDKey, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	kvs := remoteTraceContext(event).GetKeyValues()
+	assert.Equal(t, event.Properties[diagnosticIDKey], kvs[traceParentKey])
+	assert.NotContains(t, event.Properties, traceParentKey, "the event itself is left untouched")
+
+	event.Set(traceParentKey, "00-11111111111111111111111111111111-2222222222222222-01")
+	kvs = remoteTraceContext(event).GetKeyValues()
+	assert.Equal(t, "00-11111111111111111111111111111111-2222222222222222-01", kvs[traceParentKey])
+}
@@ -12,6 +12,7 @@ import (
 func (h *Hub) startSpanFromContext(ctx context.Context, operationName string) (tab.Spanner, context.Context) {
 	ctx, span := tab.StartSpan(ctx, operationName)
 	ApplyComponentInfo(span)
+	applyHubInfo(span, h)
 	return span, ctx
 }
 
@@ -28,6 +29,10 @@ func (s *sender) startProducerSpanFromContext(ctx context.Context, operationName
 		tab.StringAttribute("span.kind", "producer"),
 		tab.StringAttribute("message_bus.destination", s.getFullIdentifier()),
 	)
+	applyHubInfo(span, s.hub)
+	if s.partitionID != nil {
+		span.AddAttributes(tab.StringAttribute("eh.partition", *s.partitionID))
+	}
 	return span, ctx
 }
 
@@ -37,7 +42,10 @@ func (r *receiver) startConsumerSpanFromContext(ctx context.Context, operationNa
 	span.AddAttributes(
 		tab.StringAttribute("span.kind", "consumer"),
 		tab.StringAttribute("message_bus.destination", r.getFullIdentifier()),
+		tab.StringAttribute("eh.partition", r.partitionID),
+		tab.StringAttribute("eh.consumer_group", r.consumerGroup),
 	)
+	applyHubInfo(span, r.hub)
 	return span, ctx
 }
 
@@ -48,6 +56,32 @@ func (em *entityManager) startSpanFromContext(ctx context.Context, operationName
 	return span, ctx
 }
 
+func (c *client) startSpanFromContext(ctx context.Context, operationName string) (tab.Spanner, context.Context) {
+	ctx, span := tab.StartSpan(ctx, operationName)
+	ApplyComponentInfo(span)
+	span.AddAttributes(
+		tab.StringAttribute("span.kind", "client"),
+		tab.StringAttribute("message_bus.destination", c.hubName+"/"+address),
+		tab.StringAttribute("eh.hub", c.hubName),
+	)
+	if c.namespace != nil {
+		span.AddAttributes(tab.StringAttribute("eh.namespace", c.namespace.name))
+	}
+	return span, ctx
+}
+
+// applyHubInfo applies the namespace and name of the Event Hub to the span
+func applyHubInfo(span tab.Spanner, h *Hub) {
+	if h == nil {
+		return
+	}
+
+	span.AddAttributes(tab.StringAttribute("eh.hub", h.name))
+	if h.namespace != nil {
+		span.AddAttributes(tab.StringAttribute("eh.namespace", h.namespace.name))
+	}
+}
+
 // ApplyComponentInfo applies eventhub library and network info to the span
 func ApplyComponentInfo(span tab.Spanner) {
 	span.AddAttributes(
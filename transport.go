@@ -0,0 +1,108 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+
+	"github.com/Azure/go-amqp"
+)
+
+type (
+	// amqpReceiver is the bare minimum we need from an AMQP based receiver.
+	// Implemented by *amqp.Receiver
+	amqpReceiver interface {
+		Receive(ctx context.Context) (*amqp.Message, error)
+		AcceptMessage(ctx context.Context, msg *amqp.Message) error
+		ModifyMessage(ctx context.Context, msg *amqp.Message, deliveryFailed, undeliverableHere bool, messageAnnotations amqp.Annotations) error
+		IssueCredit(credit uint32) error
+		DrainCredit(ctx context.Context) error
+		Close(ctx context.Context) error
+	}
+
+	// amqpSession opens the sender and receiver links of a session
+	amqpSession interface {
+		NewSender(opts ...amqp.LinkOption) (amqpSender, error)
+		NewReceiver(opts ...amqp.LinkOption) (amqpReceiver, error)
+		Close(ctx context.Context) error
+	}
+
+	// transport is the AMQP backend senders and receivers build their sessions and links with. Connections, claims
+	// and management requests stay on go-amqp clients, as the CBS and RPC support of azure-amqp-common-go is built on
+	// them; only the link traffic goes through the transport, which allows tests to supply an in-memory backend.
+	transport interface {
+		newSession(conn *amqp.Client) (amqpSession, error)
+	}
+
+	// goAMQPTransport is the transport implemented with github.com/Azure/go-amqp
+	goAMQPTransport struct{}
+
+	goAMQPSession struct {
+		session *amqp.Session
+	}
+)
+
+func (goAMQPTransport) newSession(conn *amqp.Client) (amqpSession, error) {
+	s, err := conn.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	return &goAMQPSession{session: s}, nil
+}
+
+func (s *goAMQPSession) NewSender(opts ...amqp.LinkOption) (amqpSender, error) {
+	sender, err := s.session.NewSender(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return sender, nil
+}
+
+func (s *goAMQPSession) NewReceiver(opts ...amqp.LinkOption) (amqpReceiver, error) {
+	receiver, err := s.session.NewReceiver(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return receiver, nil
+}
+
+func (s *goAMQPSession) Close(ctx context.Context) error {
+	return s.session.Close(ctx)
+}
+
+// amqpTransport returns the transport of the namespace, defaulting to go-amqp, with sessions shared between links if
+// the namespace multiplexes them
+func (ns *namespace) amqpTransport() transport {
+	var t transport = goAMQPTransport{}
+	if ns.transport != nil {
+		t = ns.transport
+	}
+	if ns.faults != nil {
+		t = faultTransport{base: t, faults: ns.faults}
+	}
+
+	if ns.sessionMux != nil {
+		return multiplexedTransport{base: t, mux: ns.sessionMux}
+	}
+	return t
+}
@@ -0,0 +1,142 @@
+package eventhub
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	memoryTransport struct {
+		sessions []*memorySession
+	}
+
+	memorySession struct {
+		receivers []*memoryReceiver
+		closed    bool
+	}
+
+	memoryReceiver struct {
+		mu       sync.Mutex
+		messages chan *amqp.Message
+		accepted []*amqp.Message
+		credit   uint32
+		drained  int
+		closed   bool
+		// detached is closed by Close; like go-amqp, receives then return the messages already buffered before failing
+		detached chan struct{}
+	}
+)
+
+func (t *memoryTransport) newSession(*amqp.Client) (amqpSession, error) {
+	s := new(memorySession)
+	t.sessions = append(t.sessions, s)
+	return s, nil
+}
+
+func (s *memorySession) NewSender(...amqp.LinkOption) (amqpSender, error) {
+	return nil, amqp.ErrLinkClosed
+}
+
+func (s *memorySession) NewReceiver(...amqp.LinkOption) (amqpReceiver, error) {
+	r := &memoryReceiver{messages: make(chan *amqp.Message, 1), detached: make(chan struct{})}
+	s.receivers = append(s.receivers, r)
+	return r, nil
+}
+
+func (s *memorySession) Close(context.Context) error {
+	s.closed = true
+	return nil
+}
+
+func (r *memoryReceiver) Receive(ctx context.Context) (*amqp.Message, error) {
+	select {
+	case msg := <-r.messages:
+		return msg, nil
+	default:
+	}
+
+	select {
+	case msg := <-r.messages:
+		return msg, nil
+	case <-r.detached:
+		return nil, amqp.ErrLinkClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *memoryReceiver) AcceptMessage(_ context.Context, msg *amqp.Message) error {
+	r.accepted = append(r.accepted, msg)
+	return nil
+}
+
+func (r *memoryReceiver) ModifyMessage(context.Context, *amqp.Message, bool, bool, amqp.Annotations) error {
+	return nil
+}
+
+func (r *memoryReceiver) IssueCredit(credit uint32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.credit += credit
+	return nil
+}
+
+func (r *memoryReceiver) DrainCredit(context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.credit = 0
+	r.drained++
+	return nil
+}
+
+func (r *memoryReceiver) state() (credit uint32, drained int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.credit, r.drained
+}
+
+func (r *memoryReceiver) Close(context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed {
+		r.closed = true
+		close(r.detached)
+	}
+	return nil
+}
+
+func TestNamespace_AMQPTransportDefaultsToGoAMQP(t *testing.T) {
+	ns := new(namespace)
+	assert.Equal(t, goAMQPTransport{}, ns.amqpTransport())
+
+	memory := new(memoryTransport)
+	ns.transport = memory
+	assert.Same(t, memory, ns.amqpTransport())
+}
+
+func TestReceiver_InMemoryTransport(t *testing.T) {
+	memory := new(memoryTransport)
+	ns := &namespace{transport: memory}
+
+	s, err := ns.amqpTransport().newSession(nil)
+	require.NoError(t, err)
+	link, err := s.NewReceiver()
+	require.NoError(t, err)
+	sess, err := newSession(s)
+	require.NoError(t, err)
+
+	r := &receiver{hub: &Hub{namespace: ns}, session: sess, receiver: link}
+	memory.sessions[0].receivers[0].messages <- &amqp.Message{Data: [][]byte{[]byte("hello")}}
+	msg, err := r.listenForMessage(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), msg.GetData())
+
+	require.NoError(t, r.Close(context.Background()))
+	assert.True(t, memory.sessions[0].receivers[0].closed)
+	assert.True(t, memory.sessions[0].closed)
+}
@@ -0,0 +1,379 @@
+// Package windowing aggregates the events received from the partitions of an Event Hub into windows of their enqueued
+// time, one series of windows per partition key. An Aggregator buffers the events of each window until the watermark
+// of its partition, the latest enqueued time received less the allowed lateness, passes the end of the window, then
+// hands the window to a flush callback. The checkpoint of a partition only advances through events whose windows have
+// all been flushed, so a restart replays the events of the windows which were still open:
+//
+//	aggregator, err := windowing.NewAggregator(windowing.Tumbling(time.Minute), flush,
+//		windowing.AggregatorWithAllowedLateness(10*time.Second))
+//	handle, err := hub.Receive(ctx, partitionID, aggregator.Handler(partitionID),
+//		eventhub.ReceiveWithCheckpointManager(aggregator.CheckpointManager()))
+//
+// Windows are flushed at least once: a window flushed before a restart is flushed again if the checkpoint was held
+// back by an earlier window still open at the time.
+package windowing
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/devigned/tab"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+)
+
+type (
+	// Windows describes the windows events are assigned to: windows of Size starting every Slide, aligned on the zero
+	// time. An event belongs to every window its enqueued time falls in.
+	Windows struct {
+		Size  time.Duration
+		Slide time.Duration
+	}
+
+	// Window is a window of the events of a partition key in a partition
+	Window struct {
+		PartitionID string
+		// Key is the partition key of the events, or eventhub.KeyOfNoPartitionKey for events sent without one
+		Key   string
+		Start time.Time
+		End   time.Time
+		// Events holds the events of the window in the order they were received. They are released once the flush
+		// succeeds, so they must not be retained past it.
+		Events []*eventhub.Event
+	}
+
+	// FlushFunc is called with each window once it is complete. A window whose flush fails stays open, and its flush
+	// is attempted again with the next event of its partition.
+	FlushFunc func(ctx context.Context, window Window) error
+
+	// LateEventFunc is called with the events received after all the windows they belong to have been flushed
+	LateEventFunc func(ctx context.Context, partitionID string, event *eventhub.Event)
+
+	// Aggregator buffers the events of the partitions of a consumer group into windows and flushes them as the
+	// watermarks of the partitions pass their end. It is safe for concurrent use by the receivers of the partitions.
+	Aggregator struct {
+		windows  Windows
+		flush    FlushFunc
+		lateness time.Duration
+		late     LateEventFunc
+		manager  *eventhub.CheckpointManager
+		now      func() time.Time
+
+		mu         sync.Mutex
+		partitions map[string]*partition
+	}
+
+	// AggregatorOption provides structure for configuring a new Aggregator
+	AggregatorOption func(a *Aggregator) error
+
+	// partition holds the open windows of a partition, keyed by partition key and start, and the number of windows
+	// each buffered event is still waiting on
+	partition struct {
+		mu           sync.Mutex
+		maxEnqueued  time.Time
+		lastSequence int64
+		received     bool
+		open         map[windowKey]*Window
+		waiting      map[*eventhub.Event]int
+	}
+
+	windowKey struct {
+		key   string
+		start int64
+	}
+)
+
+// Tumbling returns Windows of size which don't overlap: each event belongs to exactly one
+func Tumbling(size time.Duration) Windows {
+	return Windows{Size: size, Slide: size}
+}
+
+// Sliding returns Windows of size starting every slide. Windows overlap when slide is shorter than size, so an event
+// belongs to several of them.
+func Sliding(size, slide time.Duration) Windows {
+	return Windows{Size: size, Slide: slide}
+}
+
+// NewAggregator creates a new Aggregator flushing the windows described by windows to flush
+func NewAggregator(windows Windows, flush FlushFunc, opts ...AggregatorOption) (*Aggregator, error) {
+	if windows.Size <= 0 || windows.Slide <= 0 {
+		return nil, fmt.Errorf("windowing: window size and slide must be positive, got size %v and slide %v", windows.Size, windows.Slide)
+	}
+	if windows.Slide > windows.Size {
+		return nil, fmt.Errorf("windowing: window slide %v must not exceed its size %v", windows.Slide, windows.Size)
+	}
+	if flush == nil {
+		return nil, errors.New("windowing: a flush func is required")
+	}
+
+	a := &Aggregator{
+		windows:    windows,
+		flush:      flush,
+		manager:    eventhub.NewCheckpointManager(),
+		now:        time.Now,
+		partitions: make(map[string]*partition),
+	}
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// AggregatorWithAllowedLateness configures how long after the latest enqueued time received on a partition the events
+// of earlier windows are still accepted. Windows are flushed that much later.
+func AggregatorWithAllowedLateness(lateness time.Duration) AggregatorOption {
+	return func(a *Aggregator) error {
+		if lateness < 0 {
+			return fmt.Errorf("windowing: allowed lateness must not be negative, got %v", lateness)
+		}
+		a.lateness = lateness
+		return nil
+	}
+}
+
+// AggregatorWithLateEventFunc configures a func called with the events which arrive too late for any of their windows.
+// Without one, late events are dropped.
+func AggregatorWithLateEventFunc(late LateEventFunc) AggregatorOption {
+	return func(a *Aggregator) error {
+		if late == nil {
+			return errors.New("windowing: late event func must not be nil")
+		}
+		a.late = late
+		return nil
+	}
+}
+
+// CheckpointManager returns the manager the receivers of the Aggregator must be configured with, through
+// eventhub.ReceiveWithCheckpointManager, for their checkpoints to follow the flushed windows. Without it, receivers
+// checkpoint events as they are buffered, and the events of open windows are lost on restart.
+func (a *Aggregator) CheckpointManager() *eventhub.CheckpointManager {
+	return a.manager
+}
+
+// Watermark returns the watermark of a partition: windows ending at or before it have been flushed, or are being
+// flushed, and events enqueued before it are late
+func (a *Aggregator) Watermark(partitionID string) (time.Time, bool) {
+	p := a.partition(partitionID)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.received {
+		return time.Time{}, false
+	}
+	return p.watermark(a.lateness), true
+}
+
+// Handler returns the Handler of the receiver of a partition. It buffers each event in its windows and flushes the
+// windows its enqueued time closes, returning the error of the first flush to fail. Events without an enqueued time,
+// which weren't received from a hub, are assigned to windows by the time they are handled.
+func (a *Aggregator) Handler(partitionID string) eventhub.Handler {
+	return func(ctx context.Context, event *eventhub.Event) error {
+		return a.add(ctx, partitionID, event)
+	}
+}
+
+// Flush flushes every open window of every partition, whatever the watermarks, for instance before shutting down or
+// when partitions have gone idle. It returns the error of the first flush to fail.
+func (a *Aggregator) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	ids := make([]string, 0, len(a.partitions))
+	for id := range a.partitions {
+		ids = append(ids, id)
+	}
+	a.mu.Unlock()
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		p := a.partition(id)
+		p.mu.Lock()
+		err := a.flushWindows(ctx, id, p, func(*Window) bool { return true })
+		p.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Aggregator) add(ctx context.Context, partitionID string, event *eventhub.Event) error {
+	p := a.partition(partitionID)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if sequence, ok := event.GetSequenceNumber(); ok {
+		if p.received && sequence <= p.lastSequence {
+			// the receiver went back to the last checkpoint after recovering, and the events buffered since are
+			// received again
+			p.reset()
+		}
+		p.lastSequence = sequence
+	}
+
+	enqueued, ok := event.GetEnqueuedTime()
+	if !ok {
+		enqueued = a.now()
+	}
+	if !p.received || enqueued.After(p.maxEnqueued) {
+		p.maxEnqueued = enqueued
+	}
+	p.received = true
+	watermark := p.watermark(a.lateness)
+
+	key, ok := event.GetPartitionKey()
+	if !ok {
+		key = eventhub.KeyOfNoPartitionKey
+	}
+
+	windows := 0
+	for start := enqueued.Truncate(a.windows.Slide); start.Add(a.windows.Size).After(enqueued); start = start.Add(-a.windows.Slide) {
+		end := start.Add(a.windows.Size)
+		wk := windowKey{key: key, start: start.UnixNano()}
+		w, ok := p.open[wk]
+		if !ok {
+			if !end.After(watermark) {
+				continue
+			}
+			w = &Window{PartitionID: partitionID, Key: key, Start: start, End: end}
+			p.open[wk] = w
+		}
+		w.Events = append(w.Events, event)
+		windows++
+	}
+
+	if windows == 0 {
+		if a.late != nil {
+			a.late(ctx, partitionID, event)
+		}
+		if err := a.complete(event); err != nil {
+			return err
+		}
+	} else {
+		event.Retain()
+		p.waiting[event] = windows
+	}
+
+	return a.flushWindows(ctx, partitionID, p, func(w *Window) bool {
+		return !w.End.After(watermark)
+	})
+}
+
+// flushWindows flushes the open windows of p which are due, in the order they end, completing the events whose
+// windows have all been flushed. It stops at the first flush to fail, leaving its window open.
+func (a *Aggregator) flushWindows(ctx context.Context, partitionID string, p *partition, due func(*Window) bool) error {
+	var windows []*Window
+	for _, w := range p.open {
+		if due(w) {
+			windows = append(windows, w)
+		}
+	}
+	if len(windows) == 0 {
+		return nil
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		if !windows[i].End.Equal(windows[j].End) {
+			return windows[i].End.Before(windows[j].End)
+		}
+		return windows[i].Key < windows[j].Key
+	})
+
+	for _, w := range windows {
+		if err := a.flushWindow(ctx, w); err != nil {
+			return err
+		}
+		delete(p.open, windowKey{key: w.Key, start: w.Start.UnixNano()})
+
+		for _, event := range w.Events {
+			p.waiting[event]--
+			if p.waiting[event] > 0 {
+				continue
+			}
+			delete(p.waiting, event)
+			err := a.complete(event)
+			event.Release()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Aggregator) flushWindow(ctx context.Context, w *Window) error {
+	ctx, span := tab.StartSpan(ctx, "eh.windowing.Aggregator.flushWindow")
+	defer span.End()
+	span.AddAttributes(
+		tab.StringAttribute("eh.partition_id", w.PartitionID),
+		tab.StringAttribute("eh.partition_key", w.Key),
+		tab.Int64Attribute("eh.window_events", int64(len(w.Events))))
+
+	if err := a.flush(ctx, *w); err != nil {
+		tab.For(ctx).Error(err)
+		return fmt.Errorf("windowing: failed to flush window [%v, %v) of key %q in partition %s: %w",
+			w.Start, w.End, w.Key, w.PartitionID, err)
+	}
+	return nil
+}
+
+// complete completes event with the checkpoint manager. Events the manager doesn't track are those of receivers which
+// weren't configured with it, which checkpoint by themselves.
+func (a *Aggregator) complete(event *eventhub.Event) error {
+	if err := a.manager.Complete(event); err != nil && !errors.Is(err, eventhub.ErrEventNotInFlight) {
+		return err
+	}
+	return nil
+}
+
+func (a *Aggregator) partition(partitionID string) *partition {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, ok := a.partitions[partitionID]
+	if !ok {
+		p = &partition{
+			open:    make(map[windowKey]*Window),
+			waiting: make(map[*eventhub.Event]int),
+		}
+		a.partitions[partitionID] = p
+	}
+	return p
+}
+
+func (p *partition) watermark(lateness time.Duration) time.Time {
+	return p.maxEnqueued.Add(-lateness)
+}
+
+// reset drops the open windows of p and the events buffered in them
+func (p *partition) reset() {
+	for event := range p.waiting {
+		event.Release()
+	}
+	p.open = make(map[windowKey]*Window)
+	p.waiting = make(map[*eventhub.Event]int)
+	p.received = false
+}
@@ -0,0 +1,183 @@
+package windowing
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/Azure/azure-event-hubs-go/v3/persist"
+)
+
+var epoch = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type flushes struct {
+	windows []Window
+	err     error
+}
+
+func (f *flushes) flush(_ context.Context, w Window) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.windows = append(f.windows, w)
+	return nil
+}
+
+// spans returns the key, start and end of each window flushed, in seconds after epoch, with the data of its events
+func (f *flushes) spans() []string {
+	var spans []string
+	for _, w := range f.windows {
+		span := w.Key + " " + strconv.Itoa(int(w.Start.Sub(epoch)/time.Second)) + "-" + strconv.Itoa(int(w.End.Sub(epoch)/time.Second)) + ":"
+		for _, event := range w.Events {
+			span += " " + string(event.Data)
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+func newTestEvent(sequence int64, key string, seconds int) *eventhub.Event {
+	enqueued := epoch.Add(time.Duration(seconds) * time.Second)
+	offset := sequence * 100
+	event := eventhub.NewEventFromString(strconv.FormatInt(sequence, 10))
+	event.SystemProperties = &eventhub.SystemProperties{
+		SequenceNumber: &sequence,
+		EnqueuedTime:   &enqueued,
+		Offset:         &offset,
+		PartitionKey:   &key,
+	}
+	return event
+}
+
+func TestNewAggregator(t *testing.T) {
+	f := new(flushes)
+	_, err := NewAggregator(Tumbling(0), f.flush)
+	assert.Error(t, err)
+	_, err = NewAggregator(Sliding(time.Second, time.Minute), f.flush)
+	assert.Error(t, err)
+	_, err = NewAggregator(Tumbling(time.Second), nil)
+	assert.Error(t, err)
+	_, err = NewAggregator(Tumbling(time.Second), f.flush, AggregatorWithAllowedLateness(-time.Second))
+	assert.Error(t, err)
+	_, err = NewAggregator(Tumbling(time.Second), f.flush, AggregatorWithLateEventFunc(nil))
+	assert.Error(t, err)
+}
+
+func TestAggregator_Tumbling(t *testing.T) {
+	ctx := context.Background()
+	f := new(flushes)
+	a, err := NewAggregator(Tumbling(10*time.Second), f.flush)
+	require.NoError(t, err)
+	handler := a.Handler("0")
+
+	for _, event := range []*eventhub.Event{
+		newTestEvent(1, "a", 1),
+		newTestEvent(2, "b", 3),
+		newTestEvent(3, "a", 9),
+		newTestEvent(4, "a", 12),
+	} {
+		require.NoError(t, handler(ctx, event))
+	}
+	assert.Equal(t, []string{"a 0-10: 1 3", "b 0-10: 2"}, f.spans())
+
+	watermark, ok := a.Watermark("0")
+	assert.True(t, ok)
+	assert.Equal(t, epoch.Add(12*time.Second), watermark)
+	_, ok = a.Watermark("1")
+	assert.False(t, ok)
+
+	require.NoError(t, a.Flush(ctx))
+	assert.Equal(t, []string{"a 0-10: 1 3", "b 0-10: 2", "a 10-20: 4"}, f.spans())
+}
+
+func TestAggregator_Sliding(t *testing.T) {
+	ctx := context.Background()
+	f := new(flushes)
+	a, err := NewAggregator(Sliding(10*time.Second, 5*time.Second), f.flush)
+	require.NoError(t, err)
+	handler := a.Handler("0")
+
+	require.NoError(t, handler(ctx, newTestEvent(1, "a", 7)))
+	require.NoError(t, handler(ctx, newTestEvent(2, "a", 12)))
+	assert.Equal(t, []string{"a 0-10: 1"}, f.spans())
+	require.NoError(t, handler(ctx, newTestEvent(3, "a", 20)))
+	assert.Equal(t, []string{"a 0-10: 1", "a 5-15: 1 2", "a 10-20: 2"}, f.spans())
+}
+
+func TestAggregator_LateEvents(t *testing.T) {
+	ctx := context.Background()
+	f := new(flushes)
+	var late []string
+	a, err := NewAggregator(Tumbling(10*time.Second), f.flush,
+		AggregatorWithAllowedLateness(5*time.Second),
+		AggregatorWithLateEventFunc(func(_ context.Context, partitionID string, event *eventhub.Event) {
+			late = append(late, partitionID+" "+string(event.Data))
+		}))
+	require.NoError(t, err)
+	handler := a.Handler("0")
+
+	require.NoError(t, handler(ctx, newTestEvent(1, "a", 2)))
+	require.NoError(t, handler(ctx, newTestEvent(2, "a", 13)))
+	assert.Empty(t, f.spans(), "the window should stay open for the allowed lateness")
+	require.NoError(t, handler(ctx, newTestEvent(3, "a", 8)))
+	require.NoError(t, handler(ctx, newTestEvent(4, "a", 16)))
+	assert.Equal(t, []string{"a 0-10: 1 3"}, f.spans())
+	require.NoError(t, handler(ctx, newTestEvent(5, "a", 9)))
+	assert.Equal(t, []string{"0 5"}, late)
+}
+
+func TestAggregator_Checkpoints(t *testing.T) {
+	ctx := context.Background()
+	f := new(flushes)
+	a, err := NewAggregator(Tumbling(10*time.Second), f.flush)
+	require.NoError(t, err)
+	handler := a.Handler("0")
+
+	var stored []int64
+	store := func(checkpoint persist.Checkpoint) error {
+		stored = append(stored, checkpoint.SequenceNumber)
+		return nil
+	}
+	receive := func(event *eventhub.Event) error {
+		a.CheckpointManager().Track("0", event, store)
+		return handler(ctx, event)
+	}
+
+	require.NoError(t, receive(newTestEvent(1, "a", 1)))
+	require.NoError(t, receive(newTestEvent(2, "b", 11)))
+	require.NoError(t, receive(newTestEvent(3, "a", 12)))
+	assert.Equal(t, []int64{1}, stored, "only the events of the flushed window should be checkpointed")
+	assert.Equal(t, 2, a.CheckpointManager().Pending("0"))
+
+	f.err = errors.New("sink down")
+	err = receive(newTestEvent(4, "a", 25))
+	assert.True(t, errors.Is(err, f.err))
+	assert.Equal(t, []int64{1}, stored, "a window which failed to flush should hold the checkpoint back")
+
+	f.err = nil
+	require.NoError(t, receive(newTestEvent(5, "a", 26)))
+	assert.Equal(t, []int64{1, 3}, stored)
+	assert.Equal(t, []string{"a 0-10: 1", "a 10-20: 3", "b 10-20: 2"}, f.spans())
+}
+
+func TestAggregator_ReceivedAgain(t *testing.T) {
+	ctx := context.Background()
+	f := new(flushes)
+	a, err := NewAggregator(Tumbling(10*time.Second), f.flush)
+	require.NoError(t, err)
+	handler := a.Handler("0")
+
+	require.NoError(t, handler(ctx, newTestEvent(1, "a", 1)))
+	require.NoError(t, handler(ctx, newTestEvent(2, "a", 2)))
+	// the receiver recovers from the last checkpoint and receives the events again
+	require.NoError(t, handler(ctx, newTestEvent(1, "a", 1)))
+	require.NoError(t, handler(ctx, newTestEvent(2, "a", 2)))
+	require.NoError(t, handler(ctx, newTestEvent(3, "a", 10)))
+	assert.Equal(t, []string{"a 0-10: 1 2"}, f.spans())
+}
@@ -0,0 +1,450 @@
+package eventhub
+
+//	MIT License
+//
+//	Copyright (c) Microsoft Corporation. All rights reserved.
+//
+//	Permission is hereby granted, free of charge, to any person obtaining a copy
+//	of this software and associated documentation files (the "Software"), to deal
+//	in the Software without restriction, including without limitation the rights
+//	to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//	copies of the Software, and to permit persons to whom the Software is
+//	furnished to do so, subject to the following conditions:
+//
+//	The above copyright notice and this permission notice shall be included in all
+//	copies or substantial portions of the Software.
+//
+//	THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//	IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//	FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//	AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//	LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//	OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//	SOFTWARE
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+type (
+	// FrameTracer receives a one line summary of every AMQP frame a connection of the Hub sends or receives, for
+	// example `<- ch=1 detach handle=0 closed=true error=amqp:link:detach-forced "entity updated"`. Summaries never
+	// include message payloads, tokens or SASL responses. It is called synchronously from the connection's I/O, so it
+	// must not block.
+	FrameTracer func(summary string)
+
+	// tracingConn summarizes the AMQP frames flowing through a connection
+	tracingConn struct {
+		net.Conn
+		in  *frameTracer
+		out *frameTracer
+	}
+
+	// frameTracer reassembles frames written or read in arbitrary chunks and summarizes each complete frame
+	frameTracer struct {
+		direction string
+		trace     FrameTracer
+
+		mu  sync.Mutex
+		buf []byte
+	}
+
+	// described is a decoded AMQP described type
+	described struct {
+		descriptor uint64
+		value      interface{}
+	}
+
+	// binaryValue stands in for binary data, which is only summarized by its length
+	binaryValue int
+)
+
+const frameHeaderSize = 8
+
+var (
+	errShortFrame = errors.New("short AMQP frame")
+
+	performativeNames = map[uint64]string{
+		0x10: "open",
+		0x11: "begin",
+		0x12: "attach",
+		0x13: "flow",
+		0x14: "transfer",
+		0x15: "disposition",
+		0x16: "detach",
+		0x17: "end",
+		0x18: "close",
+		0x40: "sasl-mechanisms",
+		0x41: "sasl-init",
+		0x42: "sasl-challenge",
+		0x43: "sasl-response",
+		0x44: "sasl-outcome",
+	}
+)
+
+// HubWithFrameTracing reports a summary of every AMQP performative the Hub's connections send and receive to tracer.
+// It is meant for diagnosing protocol problems, such as links which are detached over and over, in production; it
+// adds overhead to every frame, so leave it off otherwise.
+func HubWithFrameTracing(tracer FrameTracer) HubOption {
+	return func(h *Hub) error {
+		if tracer == nil {
+			return errors.New("frame tracer must not be nil")
+		}
+		h.namespace.frameTracer = tracer
+		return nil
+	}
+}
+
+// instrumentConn wraps conn with the fault injection and the frame tracing configured on the namespace
+func (ns *namespace) instrumentConn(conn net.Conn) net.Conn {
+	return ns.traceConn(ns.injectFaults(conn))
+}
+
+// traceConn wraps conn so its frames are reported to the namespace's frame tracer, if one is configured
+func (ns *namespace) traceConn(conn net.Conn) net.Conn {
+	if ns.frameTracer == nil {
+		return conn
+	}
+
+	return &tracingConn{
+		Conn: conn,
+		in:   &frameTracer{direction: "<-", trace: ns.frameTracer},
+		out:  &frameTracer{direction: "->", trace: ns.frameTracer},
+	}
+}
+
+func (c *tracingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.in.feed(b[:n])
+	}
+	return n, err
+}
+
+func (c *tracingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.out.feed(b[:n])
+	}
+	return n, err
+}
+
+// feed adds bytes to the stream and summarizes every frame it completes
+func (t *frameTracer) feed(b []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, b...)
+	for {
+		if len(t.buf) >= frameHeaderSize && bytes.HasPrefix(t.buf, []byte("AMQP")) {
+			t.trace(fmt.Sprintf("%s protocol header %d.%d.%d id=%d", t.direction, t.buf[5], t.buf[6], t.buf[7], t.buf[4]))
+			t.buf = t.buf[frameHeaderSize:]
+			continue
+		}
+
+		if len(t.buf) < frameHeaderSize {
+			return
+		}
+
+		size := binary.BigEndian.Uint32(t.buf)
+		if size < frameHeaderSize {
+			// not a stream we understand; stop tracing rather than report garbage
+			t.trace(fmt.Sprintf("%s invalid frame size %d, frame tracing stopped", t.direction, size))
+			t.buf = nil
+			t.trace = func(string) {}
+			return
+		}
+
+		if uint32(len(t.buf)) < size {
+			return
+		}
+
+		t.trace(t.direction + " " + summarizeFrame(t.buf[:size]))
+		t.buf = t.buf[size:]
+	}
+}
+
+// summarizeFrame describes a complete frame, including its header
+func summarizeFrame(frame []byte) string {
+	doff := int(frame[4]) * 4
+	channel := binary.BigEndian.Uint16(frame[6:8])
+	if doff < frameHeaderSize || doff > len(frame) {
+		return fmt.Sprintf("ch=%d malformed frame", channel)
+	}
+
+	body := frame[doff:]
+	if len(body) == 0 {
+		return fmt.Sprintf("ch=%d empty frame (heartbeat)", channel)
+	}
+
+	value, rest, err := decodeAMQPValue(body)
+	perf, ok := value.(described)
+	if err != nil || !ok {
+		return fmt.Sprintf("ch=%d undecodable frame of %d bytes", channel, len(frame))
+	}
+
+	name, ok := performativeNames[perf.descriptor]
+	if !ok {
+		name = fmt.Sprintf("performative 0x%x", perf.descriptor)
+	}
+
+	fields, _ := perf.value.([]interface{})
+	summary := fmt.Sprintf("ch=%d %s", channel, name)
+	if details := summarizeFields(perf.descriptor, fields); details != "" {
+		summary += " " + details
+	}
+
+	if perf.descriptor == 0x14 {
+		summary += fmt.Sprintf(" payload=%dB", len(rest))
+	}
+	return summary
+}
+
+// summarizeFields picks the fields of a performative worth reporting. Payload bearing fields, such as SASL responses,
+// are never included.
+func summarizeFields(descriptor uint64, fields []interface{}) string {
+	field := func(i int) interface{} {
+		if i < len(fields) {
+			return fields[i]
+		}
+		return nil
+	}
+
+	var parts []string
+	add := func(name string, value interface{}) {
+		switch v := value.(type) {
+		case nil:
+		case string:
+			parts = append(parts, fmt.Sprintf("%s=%q", name, v))
+		case described:
+			if v.descriptor == 0x1d {
+				parts = append(parts, "error="+summarizeError(v))
+			}
+		default:
+			parts = append(parts, fmt.Sprintf("%s=%v", name, v))
+		}
+	}
+
+	switch descriptor {
+	case 0x10: // open
+		add("container-id", field(0))
+		add("max-frame-size", field(2))
+		add("channel-max", field(3))
+		add("idle-timeout-ms", field(4))
+	case 0x11: // begin
+		add("remote-channel", field(0))
+	case 0x12: // attach
+		add("name", field(0))
+		add("handle", field(1))
+		if role, ok := field(2).(bool); ok {
+			if role {
+				parts = append(parts, "role=receiver")
+			} else {
+				parts = append(parts, "role=sender")
+			}
+		}
+	case 0x13: // flow
+		add("handle", field(4))
+		add("delivery-count", field(5))
+		add("link-credit", field(6))
+		add("drain", field(8))
+	case 0x14: // transfer
+		add("handle", field(0))
+		add("delivery-id", field(1))
+		add("more", field(5))
+	case 0x15: // disposition
+		if role, ok := field(0).(bool); ok && role {
+			parts = append(parts, "role=receiver")
+		}
+		add("first", field(1))
+		add("last", field(2))
+		add("settled", field(3))
+	case 0x16: // detach
+		add("handle", field(0))
+		add("closed", field(1))
+		add("error", field(2))
+	case 0x17, 0x18: // end, close
+		add("error", field(0))
+	case 0x40: // sasl-mechanisms
+		add("mechanisms", field(0))
+	case 0x41: // sasl-init; the initial response may carry credentials
+		add("mechanism", field(0))
+	case 0x44: // sasl-outcome
+		add("code", field(0))
+	}
+	return strings.Join(parts, " ")
+}
+
+func summarizeError(e described) string {
+	fields, _ := e.value.([]interface{})
+	condition, _ := fieldAt(fields, 0).(string)
+	if description, ok := fieldAt(fields, 1).(string); ok && description != "" {
+		return fmt.Sprintf("%s %q", condition, description)
+	}
+	return condition
+}
+
+func fieldAt(fields []interface{}, i int) interface{} {
+	if i < len(fields) {
+		return fields[i]
+	}
+	return nil
+}
+
+// decodeAMQPValue decodes the AMQP value at the start of b, returning the remaining bytes. Only the types needed to
+// summarize performatives are decoded; maps and arrays are skipped and binary data is reduced to its length.
+func decodeAMQPValue(b []byte) (interface{}, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, errShortFrame
+	}
+
+	code, b := b[0], b[1:]
+	fixed := func(n int) ([]byte, []byte, error) {
+		if len(b) < n {
+			return nil, nil, errShortFrame
+		}
+		return b[:n], b[n:], nil
+	}
+	variable := func(width int) ([]byte, []byte, error) {
+		raw, rest, err := fixed(width)
+		if err != nil {
+			return nil, nil, err
+		}
+		var n uint32
+		if width == 1 {
+			n = uint32(raw[0])
+		} else {
+			n = binary.BigEndian.Uint32(raw)
+		}
+		if uint32(len(rest)) < n {
+			return nil, nil, errShortFrame
+		}
+		return rest[:n], rest[n:], nil
+	}
+
+	switch code {
+	case 0x00:
+		descriptor, rest, err := decodeAMQPValue(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		value, rest, err := decodeAMQPValue(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		d, _ := descriptor.(uint64)
+		return described{descriptor: d, value: value}, rest, nil
+	case 0x40: // null
+		return nil, b, nil
+	case 0x41: // true
+		return true, b, nil
+	case 0x42: // false
+		return false, b, nil
+	case 0x43: // uint0
+		return uint64(0), b, nil
+	case 0x44: // ulong0
+		return uint64(0), b, nil
+	case 0x45: // list0
+		return []interface{}{}, b, nil
+	case 0x56: // boolean
+		raw, rest, err := fixed(1)
+		if err != nil {
+			return nil, nil, err
+		}
+		return raw[0] != 0, rest, nil
+	case 0x50, 0x52, 0x53: // ubyte, smalluint, smallulong
+		raw, rest, err := fixed(1)
+		if err != nil {
+			return nil, nil, err
+		}
+		return uint64(raw[0]), rest, nil
+	case 0x60: // ushort
+		raw, rest, err := fixed(2)
+		if err != nil {
+			return nil, nil, err
+		}
+		return uint64(binary.BigEndian.Uint16(raw)), rest, nil
+	case 0x70: // uint
+		raw, rest, err := fixed(4)
+		if err != nil {
+			return nil, nil, err
+		}
+		return uint64(binary.BigEndian.Uint32(raw)), rest, nil
+	case 0x80: // ulong
+		raw, rest, err := fixed(8)
+		if err != nil {
+			return nil, nil, err
+		}
+		return binary.BigEndian.Uint64(raw), rest, nil
+	case 0x51, 0x54: // byte, smallint
+		_, rest, err := fixed(1)
+		return nil, rest, err
+	case 0x55: // smalllong
+		_, rest, err := fixed(1)
+		return nil, rest, err
+	case 0x61: // short
+		_, rest, err := fixed(2)
+		return nil, rest, err
+	case 0x71, 0x72, 0x73, 0x74: // int, float, char, decimal32
+		_, rest, err := fixed(4)
+		return nil, rest, err
+	case 0x81, 0x82, 0x83, 0x84: // long, double, timestamp, decimal64
+		_, rest, err := fixed(8)
+		return nil, rest, err
+	case 0x94, 0x98: // decimal128, uuid
+		_, rest, err := fixed(16)
+		return nil, rest, err
+	case 0xa1, 0xa3: // str8, sym8
+		raw, rest, err := variable(1)
+		return string(raw), rest, err
+	case 0xb1, 0xb3: // str32, sym32
+		raw, rest, err := variable(4)
+		return string(raw), rest, err
+	case 0xa0: // vbin8
+		raw, rest, err := variable(1)
+		return binaryValue(len(raw)), rest, err
+	case 0xb0: // vbin32
+		raw, rest, err := variable(4)
+		return binaryValue(len(raw)), rest, err
+	case 0xc0, 0xd0: // list8, list32
+		width := 1
+		if code == 0xd0 {
+			width = 4
+		}
+		raw, rest, err := variable(width)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(raw) < width {
+			return nil, nil, errShortFrame
+		}
+		var values []interface{}
+		for items := raw[width:]; len(items) > 0; {
+			var value interface{}
+			value, items, err = decodeAMQPValue(items)
+			if err != nil {
+				return nil, nil, err
+			}
+			values = append(values, value)
+		}
+		return values, rest, nil
+	case 0xc1, 0xe0: // map8, array8
+		_, rest, err := variable(1)
+		return nil, rest, err
+	case 0xd1, 0xf0: // map32, array32
+		_, rest, err := variable(4)
+		return nil, rest, err
+	default:
+		return nil, nil, fmt.Errorf("unsupported AMQP type 0x%x", code)
+	}
+}
+
+func (b binaryValue) String() string {
+	return fmt.Sprintf("<%d bytes>", int(b))
+}
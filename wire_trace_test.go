@@ -0,0 +1,105 @@
+package eventhub
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func amqpFrame(channel uint16, body []byte) []byte {
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(frameHeaderSize+len(body)))
+	frame[4] = 2
+	binary.BigEndian.PutUint16(frame[6:], channel)
+	return append(frame, body...)
+}
+
+func amqpList(items ...[]byte) []byte {
+	var body []byte
+	for _, item := range items {
+		body = append(body, item...)
+	}
+	return append([]byte{0xc0, byte(len(body) + 1), byte(len(items))}, body...)
+}
+
+func amqpString(code byte, s string) []byte {
+	return append([]byte{code, byte(len(s))}, s...)
+}
+
+func TestFrameTracer(t *testing.T) {
+	var summaries []string
+	tracer := &frameTracer{direction: "<-", trace: func(s string) { summaries = append(summaries, s) }}
+
+	attach := amqpFrame(1, append([]byte{0x00, 0x53, 0x12}, amqpList(
+		amqpString(0xa1, "receiver-link"),
+		[]byte{0x52, 0x03},
+		[]byte{0x41},
+	)...))
+	errorValue := append([]byte{0x00, 0x53, 0x1d}, amqpList(
+		amqpString(0xa3, "amqp:link:detach-forced"),
+		amqpString(0xa1, "entity updated"),
+	)...)
+	detach := amqpFrame(1, append([]byte{0x00, 0x53, 0x16}, amqpList(
+		[]byte{0x43},
+		[]byte{0x41},
+		errorValue,
+	)...))
+	transfer := amqpFrame(1, append(append([]byte{0x00, 0x53, 0x14}, amqpList([]byte{0x43}, []byte{0x52, 0x07})...), "secret payload"...))
+
+	stream := append([]byte("AMQP\x00\x01\x00\x00"), attach...)
+	stream = append(stream, amqpFrame(0, nil)...)
+	stream = append(stream, transfer...)
+	stream = append(stream, detach...)
+
+	// frames arrive in arbitrary chunks
+	for len(stream) > 0 {
+		n := 5
+		if n > len(stream) {
+			n = len(stream)
+		}
+		tracer.feed(stream[:n])
+		stream = stream[n:]
+	}
+
+	require.Len(t, summaries, 5)
+	assert.Equal(t, "<- protocol header 1.0.0 id=0", summaries[0])
+	assert.Equal(t, `<- ch=1 attach name="receiver-link" handle=3 role=receiver`, summaries[1])
+	assert.Equal(t, "<- ch=0 empty frame (heartbeat)", summaries[2])
+	assert.Equal(t, "<- ch=1 transfer handle=0 delivery-id=7 payload=14B", summaries[3])
+	assert.NotContains(t, summaries[3], "secret")
+	assert.Equal(t, `<- ch=1 detach handle=0 closed=true error=amqp:link:detach-forced "entity updated"`, summaries[4])
+}
+
+func TestFrameTracer_SASLInitOmitsResponse(t *testing.T) {
+	frame := amqpFrame(0, append([]byte{0x00, 0x53, 0x41}, amqpList(
+		amqpString(0xa3, "PLAIN"),
+		amqpString(0xa0, "\x00user\x00password"),
+	)...))
+	summary := summarizeFrame(frame)
+	assert.Equal(t, `ch=0 sasl-init mechanism="PLAIN"`, summary)
+}
+
+func TestNamespace_TraceConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	ns := new(namespace)
+	assert.Equal(t, client, ns.traceConn(client))
+
+	h := &Hub{namespace: ns}
+	assert.Error(t, HubWithFrameTracing(nil)(h))
+	traced := make(chan string, 1)
+	require.NoError(t, HubWithFrameTracing(func(s string) { traced <- s })(h))
+
+	conn := ns.traceConn(client)
+	go func() {
+		buf := make([]byte, 8)
+		_, _ = server.Read(buf)
+	}()
+	_, err := conn.Write(amqpFrame(0, nil))
+	require.NoError(t, err)
+	assert.Equal(t, "-> ch=0 empty frame (heartbeat)", <-traced)
+}